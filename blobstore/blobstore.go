@@ -0,0 +1,33 @@
+// Package blobstore keeps files that don't belong in the database, like uploaded images, behind an interface so
+// where they're kept can change without the code that reads and writes them knowing
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+)
+
+var (
+	ErrNotFound   = errors.New("blobstore: no blob found for key")
+	ErrInvalidKey = errors.New("blobstore: key is not valid")
+)
+
+// Store keeps blobs under slash separated keys like "avatars/12-abc.jpg"
+type Store interface {
+	// Put stores the blob at the key, replacing anything already there
+	Put(ctx context.Context, key string, r io.Reader) error
+	// Get opens the blob at the key, ErrNotFound is returned when there isn't one
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob at the key, it isn't an error when there isn't one
+	Delete(ctx context.Context, key string) error
+}
+
+// validateKey keeps keys relative and inside the store, "..", leading slashes and empty elements aren't allowed
+func validateKey(key string) error {
+	if !fs.ValidPath(key) || key == "." {
+		return ErrInvalidKey
+	}
+	return nil
+}
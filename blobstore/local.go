@@ -0,0 +1,110 @@
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+// LocalStore keeps blobs as files under a directory on the local filesystem, it's only shared between instances when
+// the directory is
+type LocalStore struct {
+	dir string
+}
+
+func NewLocalStore(dir string) (*LocalStore, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocalStore{dir: dir}, nil
+}
+
+func (l *LocalStore) Put(ctx context.Context, key string, r io.Reader) error {
+	_, span, _ := metrics.SpanFromContext(ctx, "LocalStore.Put")
+	defer span.End()
+
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+
+	// the blob is written next to where it goes and renamed into place so it's never read half written
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+
+	_, err = io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
+
+func (l *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	_, span, _ := metrics.SpanFromContext(ctx, "LocalStore.Get")
+	defer span.End()
+
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f, nil
+}
+
+func (l *LocalStore) Delete(ctx context.Context, key string) error {
+	_, span, _ := metrics.SpanFromContext(ctx, "LocalStore.Delete")
+	defer span.End()
+
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *LocalStore) path(key string) (string, error) {
+	err := validateKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
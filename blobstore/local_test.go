@@ -0,0 +1,64 @@
+package blobstore_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/blobstore"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestLocalStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	testhelpers.Ok(t, err, "failed to create store")
+
+	read := func(key string) string {
+		t.Helper()
+		r, err := store.Get(ctx, key)
+		testhelpers.Ok(t, err, "failed to get blob")
+		defer r.Close()
+
+		b, err := io.ReadAll(r)
+		testhelpers.Ok(t, err, "failed to read blob")
+		return string(b)
+	}
+
+	err = store.Put(ctx, "avatars/1.jpg", strings.NewReader("first"))
+	testhelpers.Ok(t, err, "failed to put blob")
+	testhelpers.Equals(t, "first", read("avatars/1.jpg"))
+
+	err = store.Put(ctx, "avatars/1.jpg", strings.NewReader("second"))
+	testhelpers.Ok(t, err, "failed to replace blob")
+	testhelpers.Equals(t, "second", read("avatars/1.jpg"))
+
+	err = store.Delete(ctx, "avatars/1.jpg")
+	testhelpers.Ok(t, err, "failed to delete blob")
+
+	_, err = store.Get(ctx, "avatars/1.jpg")
+	testhelpers.Assert(t, errors.Is(err, blobstore.ErrNotFound), "expected ErrNotFound, got %v", err)
+
+	err = store.Delete(ctx, "avatars/1.jpg")
+	testhelpers.Ok(t, err, "deleting a missing blob should not fail")
+}
+
+func TestLocalStoreInvalidKeys(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	store, err := blobstore.NewLocalStore(t.TempDir())
+	testhelpers.Ok(t, err, "failed to create store")
+
+	for _, key := range []string{"", ".", "../escape.jpg", "avatars/../../escape.jpg", "/etc/passwd", "avatars//1.jpg"} {
+		err := store.Put(ctx, key, strings.NewReader("nope"))
+		testhelpers.Assert(t, errors.Is(err, blobstore.ErrInvalidKey), "expected ErrInvalidKey for %q, got %v", key, err)
+
+		_, err = store.Get(ctx, key)
+		testhelpers.Assert(t, errors.Is(err, blobstore.ErrInvalidKey), "expected ErrInvalidKey for %q, got %v", key, err)
+	}
+}
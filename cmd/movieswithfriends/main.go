@@ -23,6 +23,7 @@ import (
 	"github.com/pressly/goose/v3"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 
+	"github.com/jm96441n/movieswithfriends/blobstore"
 	"github.com/jm96441n/movieswithfriends/identityaccess"
 	"github.com/jm96441n/movieswithfriends/identityaccess/services"
 	iamstore "github.com/jm96441n/movieswithfriends/identityaccess/store"
@@ -139,12 +140,14 @@ func main() {
 		os.Exit(1)
 	}
 
-	tmdbClient, err := partymgmt.NewTMDBClient("https://api.themoviedb.org/3", tmdbApiKey, logger)
-	if err != nil {
-		logger.Error(err.Error())
-		os.Exit(1)
+	// TMDB_BASE_URL lets the app run against the offline stand-in in ./cmd/tmdbfake
+	tmdbBaseURL := os.Getenv("TMDB_BASE_URL")
+	if tmdbBaseURL == "" {
+		tmdbBaseURL = "https://api.themoviedb.org/3"
 	}
 
+	tmdbClient := partymgmt.NewTMDBClient(tmdbBaseURL, tmdbApiKey, logger)
+
 	sessionKey := make([]byte, length)
 	sessionKeyVar := os.Getenv("SESSION_KEY")
 	if sessionKeyVar == "" {
@@ -161,6 +164,23 @@ func main() {
 	sessionStore := sessions.NewCookieStore(sessionKey)
 
 	moviesRepo := partymgmtstore.NewMoviesRepository(connPool)
+	genresRepo := partymgmtstore.NewGenresRepository(connPool)
+	watchProvidersRepo := partymgmtstore.NewWatchProvidersRepository(connPool)
+	movieSvc := partymgmt.NewMovieService(tmdbClient, moviesRepo, genresRepo, watchProvidersRepo)
+
+	err = movieSvc.WarmGenreCache(ctx, logger)
+	if err != nil {
+		// the cache fills itself lazily from TMDB so this isn't fatal
+		logger.Error("failed to warm genre cache", slog.Any("err", err))
+	}
+
+	genreRefreshInterval, err := durationFromEnv("GENRE_REFRESH_INTERVAL", 24*time.Hour)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	movieSvc.StartGenreRefresh(ctx, logger, genreRefreshInterval)
 
 	assetDir, err := fs.Sub(ui.TemplateFS, "dist")
 	if err != nil {
@@ -175,23 +195,107 @@ func main() {
 	}
 
 	profileRepo := iamstore.NewProfileRepository(connPool)
+
+	// uploads like avatars are kept on the local filesystem, BLOB_STORE_DIR should be on a volume shared by every
+	// instance when there's more than one
+	blobStoreDir := os.Getenv("BLOB_STORE_DIR")
+	if blobStoreDir == "" {
+		blobStoreDir = "data/blobs"
+	}
+
+	blobStore, err := blobstore.NewLocalStore(blobStoreDir)
+	if err != nil {
+		logger.Error("failed to set up blob store", slog.Any("err", err))
+		os.Exit(1)
+	}
+
 	watcherRepo := partymgmtstore.NewWatcherRepository(connPool)
 	partyRepo := partymgmtstore.NewPartyRepository(connPool)
 	invitationsRepo := partymgmtstore.NewInvitationsRepository(connPool)
 
-	partySvc := partymgmt.NewPartyService(logger, partyRepo)
+	// events go through postgres so members on other instances see them, PARTY_EVENTS_BACKEND=memory keeps them in this
+	// instance for running a single one without LISTEN/NOTIFY
+	var eventsRepo *partymgmtstore.EventsRepository
+	if os.Getenv("PARTY_EVENTS_BACKEND") != "memory" {
+		eventsRepo = partymgmtstore.NewEventsRepository(connPool)
+	}
+
+	notificationSvc := partymgmt.NewNotificationService(partymgmtstore.NewNotificationsRepository(connPool))
+
+	// webhooks can't be sent to private or local addresses, WEBHOOK_ALLOW_PRIVATE_ADDRESSES=true lets them be for
+	// sending to the fake receiver when running locally
+	webhookClient := partymgmt.NewWebhookClient(os.Getenv("WEBHOOK_ALLOW_PRIVATE_ADDRESSES") == "true")
+	webhookSvc := partymgmt.NewWebhookService(partymgmtstore.NewWebhooksRepository(connPool), webhookClient)
+
+	eventBus := partymgmt.NewEventBus(eventsRepo)
+	eventBus.AddHandler(notificationSvc.HandlePartyEvent)
+	eventBus.AddHandler(webhookSvc.HandlePartyEvent)
+	eventBus.StartListener(ctx, logger)
+
+	webhookPollInterval, err := durationFromEnv("WEBHOOK_POLL_INTERVAL", 15*time.Second)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	webhookSvc.StartDeliveryWorker(ctx, logger, webhookPollInterval)
+
+	reminderInterval, err := durationFromEnv("NOTIFICATION_REMINDER_INTERVAL", time.Minute)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	notificationSvc.StartReminderWorker(ctx, logger, reminderInterval)
+
+	partySvc := partymgmt.NewPartyService(logger, partyRepo, eventBus)
 	watcherSvc := partymgmt.NewWatcherService(watcherRepo)
 
+	importsRepo := partymgmtstore.NewImportsRepository(connPool)
+	importSvc := partymgmt.NewImportService(tmdbClient, movieSvc, partySvc, importsRepo)
+
+	importPollInterval, err := durationFromEnv("IMPORT_POLL_INTERVAL", 30*time.Second)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	importSvc.StartImportWorker(ctx, logger, importPollInterval)
+
+	profileSvc := identityaccess.NewProfileService(profileRepo, blobStore)
+	accountDeletionSvc := services.NewAccountDeletionService(
+		profileSvc,
+		partymgmt.NewDepartureService(partymgmtstore.NewDeparturesRepository(connPool), partyRepo),
+	)
+
+	accountDeletionInterval, err := durationFromEnv("ACCOUNT_DELETION_INTERVAL", time.Hour)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+	}
+
+	accountDeletionSvc.StartDeletionWorker(ctx, logger, accountDeletionInterval)
+
+	exportSvc := partymgmt.NewExportService(partymgmtstore.NewExportsRepository(connPool))
+	partyStatsRepo := partymgmtstore.NewPartyStatsRepository(connPool)
+
+	adminSvc := services.NewAdminService(
+		identityaccess.NewAdminService(iamstore.NewAdminRepository(connPool)),
+		partySvc,
+		movieSvc,
+		exportSvc,
+	)
+
 	app := web.NewApplication(
 		web.AppConfig{
 			Telemetry:         telemetry,
 			Logger:            logger,
 			SessionStore:      sessionStore,
-			MoviesService:     partymgmt.NewMovieService(tmdbClient, moviesRepo),
+			MoviesService:     movieSvc,
 			MoviesRepository:  moviesRepo,
 			PartyService:      partySvc,
 			PartiesRepository: partyRepo,
-			ProfilesService:   identityaccess.NewProfileService(profileRepo),
+			ProfilesService:   profileSvc,
 			WatcherService:    watcherSvc,
 			Auth: &identityaccess.Authenticator{
 				ProfileRepository: profileRepo,
@@ -202,8 +306,21 @@ func main() {
 				partySvc,
 				watcherSvc,
 			),
-			InvitationsService: partymgmt.NewInvitationsService(invitationsRepo),
-			AssetLoader:        loader,
+			InvitationsService:     partymgmt.NewInvitationsService(invitationsRepo, partyRepo),
+			ImportService:          importSvc,
+			ExportService:          exportSvc,
+			AccountExportService:   services.NewAccountExportService(profileRepo, exportSvc),
+			AccountDeletionService: accountDeletionSvc,
+			PartyStatsService:      partymgmt.NewPartyStatsService(partyStatsRepo),
+			RecapService:           partymgmt.NewRecapService(partymgmtstore.NewRecapsRepository(connPool), partyStatsRepo),
+			MovieNightService:      partymgmt.NewMovieNightService(partymgmtstore.NewMovieNightsRepository(connPool), partyRepo, eventBus),
+			NotificationService:    notificationSvc,
+			CommentService:         partymgmt.NewCommentService(partymgmtstore.NewCommentsRepository(connPool), partyRepo),
+			WebhookService:         webhookSvc,
+			FriendsService:         partymgmt.NewFriendsService(partymgmtstore.NewFriendsRepository(connPool), partyRepo),
+			PublicPartyService:     partymgmt.NewPublicPartyService(partymgmtstore.NewPublicPartiesRepository(connPool)),
+			AdminService:           adminSvc,
+			AssetLoader:            loader,
 		},
 	)
 
@@ -233,6 +350,21 @@ func main() {
 	os.Exit(1)
 }
 
+// durationFromEnv parses a duration from an env var, returning the fallback when it isn't set
+func durationFromEnv(key string, fallback time.Duration) (time.Duration, error) {
+	val := os.Getenv(key)
+	if val == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return 0, fmt.Errorf("%s is not a valid duration: %w", key, err)
+	}
+
+	return d, nil
+}
+
 type DBCreds struct {
 	Username string
 	Password string
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/jm96441n/movieswithfriends/tmdbfake"
+)
+
+func main() {
+	var (
+		addr   string
+		apiKey string
+	)
+
+	flag.StringVar(&addr, "addr", ":4100", "address to listen on")
+	flag.StringVar(&apiKey, "api-key", os.Getenv("TMDB_API_KEY"), "bearer token requests must send, any token is accepted when empty")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	handler, err := tmdbfake.NewHandler(tmdbfake.WithAPIKey(apiKey), tmdbfake.WithLogger(logger))
+	if err != nil {
+		logger.Error("failed to create handler", slog.Any("err", err))
+		os.Exit(1)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(tmdbfake.BasePath+"/", handler)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	logger.Info("serving fake tmdb", slog.String("addr", addr), slog.String("baseURL", "http://localhost"+addr+tmdbfake.BasePath))
+	err = http.ListenAndServe(addr, mux)
+	if err != nil {
+		logger.Error("server stopped", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
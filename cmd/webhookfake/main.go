@@ -0,0 +1,44 @@
+package main
+
+import (
+	"flag"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/jm96441n/movieswithfriends/webhookfake"
+)
+
+func main() {
+	var (
+		addr     string
+		secret   string
+		failures int
+	)
+
+	flag.StringVar(&addr, "addr", ":4200", "address to listen on")
+	flag.StringVar(&secret, "secret", "", "secret deliveries must be signed with, signatures aren't checked when empty")
+	flag.IntVar(&failures, "failures", 0, "how many deliveries to fail before accepting them")
+	flag.Parse()
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, nil))
+
+	handler := webhookfake.NewHandler(
+		webhookfake.WithSecret(secret),
+		webhookfake.WithFailures(failures),
+		webhookfake.WithLogger(logger),
+	)
+
+	mux := http.NewServeMux()
+	mux.Handle("/hooks/", handler)
+	mux.HandleFunc("GET /health", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	logger.Info("serving fake webhook receiver", slog.String("addr", addr), slog.String("url", "http://localhost"+addr+"/hooks/party"))
+	err := http.ListenAndServe(addr, mux)
+	if err != nil {
+		logger.Error("server stopped", slog.Any("err", err))
+		os.Exit(1)
+	}
+}
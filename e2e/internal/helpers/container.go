@@ -12,6 +12,8 @@ import (
 	"github.com/testcontainers/testcontainers-go/wait"
 )
 
+const tmdbTestKey = "e2e-tmdb-key"
+
 func SetupDBContainer(ctx context.Context, t *testing.T) *postgres.PostgresContainer {
 	t.Helper()
 	dbCtr, err := postgres.Run(
@@ -34,19 +36,46 @@ func SetupDBContainer(ctx context.Context, t *testing.T) *postgres.PostgresConta
 	return dbCtr
 }
 
+// SetupTMDBContainer runs the offline TMDB stand-in so the suite doesn't depend on the real API
+func SetupTMDBContainer(ctx context.Context, t *testing.T) testcontainers.Container {
+	t.Helper()
+	req := testcontainers.ContainerRequest{
+		Image:        "movieswithfriends-tmdbfake:test",
+		ExposedPorts: []string{"4100/tcp"},
+		Networks:     []string{"bridge", "test"},
+		Cmd:          []string{"/tmdbfake", "-api-key", tmdbTestKey},
+		WaitingFor:   wait.ForHTTP("/health").WithPort("4100/tcp"),
+	}
+
+	tmdbCtr, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	testcontainers.CleanupContainer(t, tmdbCtr)
+	if err != nil {
+		t.Fatalf("Failed to bring up fake tmdb container: %v", err)
+	}
+
+	return tmdbCtr
+}
+
 type logConsumer struct{}
 
 func (g *logConsumer) Accept(l testcontainers.Log) {
 	fmt.Println(string(l.Content))
 }
 
-func SetupAppContainer(ctx context.Context, t *testing.T, pgCtr *postgres.PostgresContainer) testcontainers.Container {
+func SetupAppContainer(ctx context.Context, t *testing.T, pgCtr *postgres.PostgresContainer, tmdbCtr testcontainers.Container) testcontainers.Container {
 	dbIP, err := pgCtr.ContainerIP(ctx)
 	if err != nil {
 		t.Fatalf("Failed to get container IP: %v", err)
 	}
 
-	tmdbKey := os.Getenv("TMDB_API_KEY")
+	tmdbIP, err := tmdbCtr.ContainerIP(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get fake tmdb container IP: %v", err)
+	}
+
 	sessionKey := os.Getenv("SESSION_KEY")
 
 	req := testcontainers.ContainerRequest{
@@ -61,7 +90,8 @@ func SetupAppContainer(ctx context.Context, t *testing.T, pgCtr *postgres.Postgr
 			"DB_MIGRATION_PASSWORD": "postgres",
 			"DB_HOST":               dbIP,
 			"DB_DATABASE_NAME":      "movieswithfriends",
-			"TMDB_API_KEY":          tmdbKey,
+			"TMDB_API_KEY":          tmdbTestKey,
+			"TMDB_BASE_URL":         fmt.Sprintf("http://%s:4100/3", tmdbIP),
 			"SESSION_KEY":           sessionKey,
 			"COLLECTOR_ENDPOINT":    "0.0.0.0:1500", // special signal to use no-op telemetry collector in test
 		},
@@ -22,7 +22,8 @@ func Setup(ctx context.Context, t *testing.T, testConn *pgxpool.Pool, page playw
 func SetupSuite(ctx context.Context, t *testing.T) (*pgxpool.Pool, playwright.Page, string) {
 	t.Helper()
 	dbCtr := SetupDBContainer(ctx, t)
-	appCtr := SetupAppContainer(ctx, t, dbCtr)
+	tmdbCtr := SetupTMDBContainer(ctx, t)
+	appCtr := SetupAppContainer(ctx, t, dbCtr, tmdbCtr)
 
 	pw, err := playwright.Run()
 	Ok(t, err, "could not start playwright")
@@ -11,7 +11,17 @@ import (
 )
 
 func TestMain(m *testing.M) {
-	cmd := exec.Command("docker", "build", "--target=prod", "-t", "movieswithfriends:test", ".")
+	buildImage("prod", "movieswithfriends:test")
+	buildImage("tmdbfake", "movieswithfriends-tmdbfake:test")
+
+	flag.BoolVar(&helpers.Headless, "headless", true, "run tests in headless mode")
+	flag.Parse()
+
+	m.Run()
+}
+
+func buildImage(target, tag string) {
+	cmd := exec.Command("docker", "build", "--target="+target, "-t", tag, ".")
 	cmd.Dir = ".."
 
 	cmd.Stdout = os.Stdout
@@ -19,11 +29,6 @@ func TestMain(m *testing.M) {
 	cmd.Stdin = os.Stdin
 	err := cmd.Run()
 	if err != nil {
-		log.Fatalf("could not build docker image: %v", err)
+		log.Fatalf("could not build docker image %s: %v", tag, err)
 	}
-
-	flag.BoolVar(&helpers.Headless, "headless", true, "run tests in headless mode")
-	flag.Parse()
-
-	m.Run()
 }
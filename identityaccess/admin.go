@@ -0,0 +1,383 @@
+package identityaccess
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess/store"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+const (
+	RoleMember = "member"
+	RoleAdmin  = "admin"
+)
+
+// AdminAction is something done in the admin area, each one is recorded in the audit log
+type AdminAction string
+
+const (
+	AdminActionSearch           AdminAction = "search"
+	AdminActionViewAccount      AdminAction = "view_account"
+	AdminActionViewFailedLogins AdminAction = "view_failed_logins"
+	AdminActionDisableAccount   AdminAction = "disable_account"
+	AdminActionEnableAccount    AdminAction = "enable_account"
+	AdminActionForceLogout      AdminAction = "force_logout"
+	AdminActionRefreshMovie     AdminAction = "refresh_movie"
+)
+
+var adminActionLabels = map[AdminAction]string{
+	AdminActionSearch:           "Searched",
+	AdminActionViewAccount:      "Viewed account",
+	AdminActionViewFailedLogins: "Viewed failed logins",
+	AdminActionDisableAccount:   "Disabled account",
+	AdminActionEnableAccount:    "Enabled account",
+	AdminActionForceLogout:      "Logged account out",
+	AdminActionRefreshMovie:     "Refreshed movie",
+}
+
+// Label is how the action is shown in the audit log
+func (a AdminAction) Label() string {
+	if label, ok := adminActionLabels[a]; ok {
+		return label
+	}
+	return string(a)
+}
+
+// how many rows the admin area shows at once
+const (
+	AdminSearchLimit         = 25
+	FailedLoginsLimit        = 100
+	AuditLogLimit            = 100
+	AccountFailedLoginsLimit = 20
+)
+
+var (
+	ErrNotAdmin                = errors.New("profile isn't an admin")
+	ErrAccountNotFound         = errors.New("account not found")
+	ErrCannotDisableOwnAccount = errors.New("admins can't disable their own account")
+	ErrAccountAlreadyDisabled  = errors.New("account is already disabled")
+	ErrAccountNotDisabled      = errors.New("account isn't disabled")
+)
+
+// IsAdmin is whether the account can use the admin area
+func (a Account) IsAdmin() bool {
+	return a.Role == RoleAdmin
+}
+
+// Disabled is whether an admin has disabled the account
+func (a Account) Disabled() bool {
+	return !a.DisabledAt.IsZero()
+}
+
+// CanUseSession is whether a session started with sessionVersion can still be used, it can't once the account has been
+// disabled or logged out of everywhere since it started
+func (a Account) CanUseSession(sessionVersion int) bool {
+	return !a.Disabled() && a.SessionVersion == sessionVersion
+}
+
+// AdminAccount is an account as the admin area sees it
+type AdminAccount struct {
+	ID             int
+	ProfileID      int
+	Email          string
+	FirstName      string
+	LastName       string
+	DisplayName    string
+	Role           string
+	SessionVersion int
+	CreatedAt      time.Time
+	// DisabledAt is zero when the account is enabled
+	DisabledAt time.Time
+	// DeletionScheduledFor is zero when the account isn't going to be deleted
+	DeletionScheduledFor time.Time
+}
+
+func (a AdminAccount) Disabled() bool {
+	return !a.DisabledAt.IsZero()
+}
+
+func (a AdminAccount) IsAdmin() bool {
+	return a.Role == RoleAdmin
+}
+
+type FailedLogin struct {
+	Email     string
+	IPAddress string
+	Reason    string
+	CreatedAt time.Time
+}
+
+type AuditEntry struct {
+	AdminEmail string
+	Action     AdminAction
+	// TargetID is the profile or movie the action was on, it's 0 when it wasn't on one
+	TargetID  int
+	Details   string
+	CreatedAt time.Time
+}
+
+// TargetsAccount is whether the entry's target is a profile, rather than a movie or nothing at all
+func (e AuditEntry) TargetsAccount() bool {
+	switch e.Action {
+	case AdminActionViewAccount, AdminActionDisableAccount, AdminActionEnableAccount, AdminActionForceLogout:
+		return true
+	}
+	return false
+}
+
+// AdminService is what admins can see and do to accounts, every method checks the profile using it is an admin no
+// matter how it was reached and every change to an account is recorded in the audit log along with it
+type AdminService struct {
+	db *store.AdminRepository
+}
+
+func NewAdminService(db *store.AdminRepository) *AdminService {
+	return &AdminService{db: db}
+}
+
+func (s *AdminService) authorize(ctx context.Context, logger *slog.Logger, admin *Profile) error {
+	if admin == nil || !admin.Account.IsAdmin() || admin.Account.Disabled() {
+		logger.WarnContext(ctx, "non admin tried to use the admin area")
+		return ErrNotAdmin
+	}
+	return nil
+}
+
+func auditEntryAttrs(admin *Profile, action AdminAction, targetID int, details string) store.AuditEntryAttrs {
+	return store.AuditEntryAttrs{
+		AdminID:    admin.Account.ID,
+		AdminEmail: admin.Account.Email,
+		Action:     string(action),
+		TargetID:   targetID,
+		Details:    details,
+	}
+}
+
+// RecordAction writes something the admin did that didn't change an account to the audit log, it's for what's done
+// outside of this service like searching parties or refreshing movies
+func (s *AdminService) RecordAction(ctx context.Context, logger *slog.Logger, admin *Profile, action AdminAction, targetID int, details string) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.RecordAction")
+	defer span.End()
+
+	err := s.authorize(ctx, logger, admin)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.RecordAuditEntry(ctx, auditEntryAttrs(admin, action, targetID, details))
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to record admin action", slog.Any("error", err), slog.String("action", string(action)))
+		return err
+	}
+
+	return nil
+}
+
+// SearchAccounts returns the accounts whose email or name has term in it, it isn't recorded in the audit log on its
+// own since it's part of a wider search
+func (s *AdminService) SearchAccounts(ctx context.Context, logger *slog.Logger, admin *Profile, term string) ([]AdminAccount, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.SearchAccounts")
+	defer span.End()
+
+	err := s.authorize(ctx, logger, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]AdminAccount, 0)
+	err = s.db.SearchAccounts(ctx, term, AdminSearchLimit, func(res store.AdminAccountResult) {
+		accounts = append(accounts, convertAdminAccountResult(res))
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to search accounts", slog.Any("error", err))
+		return nil, err
+	}
+
+	return accounts, nil
+}
+
+// GetAccount returns the account belonging to the profile
+func (s *AdminService) GetAccount(ctx context.Context, logger *slog.Logger, admin *Profile, profileID int) (AdminAccount, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.GetAccount")
+	defer span.End()
+
+	err := s.authorize(ctx, logger, admin)
+	if err != nil {
+		return AdminAccount{}, err
+	}
+
+	res, err := s.db.GetAccount(ctx, profileID)
+	if errors.Is(err, store.ErrNoRecord) {
+		return AdminAccount{}, ErrAccountNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get account", slog.Any("error", err), slog.Int("profileID", profileID))
+		return AdminAccount{}, err
+	}
+
+	return convertAdminAccountResult(res), nil
+}
+
+// DisableAccount stops the profile's account from logging in and logs it out of everywhere it's logged in
+func (s *AdminService) DisableAccount(ctx context.Context, logger *slog.Logger, admin *Profile, profileID int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.DisableAccount")
+	defer span.End()
+
+	account, err := s.GetAccount(ctx, logger, admin, profileID)
+	if err != nil {
+		return err
+	}
+
+	if account.ID == admin.Account.ID {
+		return ErrCannotDisableOwnAccount
+	}
+
+	err = s.db.DisableAccount(ctx, account.ID, time.Now().UTC(), auditEntryAttrs(admin, AdminActionDisableAccount, profileID, account.Email))
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrAccountAlreadyDisabled
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to disable account", slog.Any("error", err), slog.Int("profileID", profileID))
+		return err
+	}
+
+	logger.InfoContext(ctx, "disabled account", slog.Int("profileID", profileID), slog.Int("adminAccountID", admin.Account.ID))
+	return nil
+}
+
+// EnableAccount lets the profile's account log in again
+func (s *AdminService) EnableAccount(ctx context.Context, logger *slog.Logger, admin *Profile, profileID int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.EnableAccount")
+	defer span.End()
+
+	account, err := s.GetAccount(ctx, logger, admin, profileID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.EnableAccount(ctx, account.ID, auditEntryAttrs(admin, AdminActionEnableAccount, profileID, account.Email))
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrAccountNotDisabled
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to enable account", slog.Any("error", err), slog.Int("profileID", profileID))
+		return err
+	}
+
+	logger.InfoContext(ctx, "enabled account", slog.Int("profileID", profileID), slog.Int("adminAccountID", admin.Account.ID))
+	return nil
+}
+
+// ForceLogout logs the profile's account out of every session it has, they can log straight back in
+func (s *AdminService) ForceLogout(ctx context.Context, logger *slog.Logger, admin *Profile, profileID int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.ForceLogout")
+	defer span.End()
+
+	account, err := s.GetAccount(ctx, logger, admin, profileID)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.EndSessions(ctx, account.ID, auditEntryAttrs(admin, AdminActionForceLogout, profileID, account.Email))
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrAccountNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to end sessions", slog.Any("error", err), slog.Int("profileID", profileID))
+		return err
+	}
+
+	logger.InfoContext(ctx, "forced account logout", slog.Int("profileID", profileID), slog.Int("adminAccountID", admin.Account.ID))
+	return nil
+}
+
+// GetFailedLogins returns the most recent failed logins, only the ones for email when it isn't empty
+func (s *AdminService) GetFailedLogins(ctx context.Context, logger *slog.Logger, admin *Profile, email string, limit int) ([]FailedLogin, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.GetFailedLogins")
+	defer span.End()
+
+	err := s.authorize(ctx, logger, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	failedLogins := make([]FailedLogin, 0)
+	err = s.db.GetFailedLogins(ctx, email, limit, func(res store.FailedLoginResult) {
+		failedLogins = append(failedLogins, FailedLogin{
+			Email:     res.Email,
+			IPAddress: res.IPAddress,
+			Reason:    res.Reason,
+			CreatedAt: res.CreatedAt,
+		})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get failed logins", slog.Any("error", err))
+		return nil, err
+	}
+
+	return failedLogins, nil
+}
+
+// GetAuditLog returns the most recent entries in the audit log, looking at it isn't recorded
+func (s *AdminService) GetAuditLog(ctx context.Context, logger *slog.Logger, admin *Profile) ([]AuditEntry, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.GetAuditLog")
+	defer span.End()
+
+	err := s.authorize(ctx, logger, admin)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]AuditEntry, 0)
+	err = s.db.GetAuditLog(ctx, AuditLogLimit, func(res store.AuditEntryResult) {
+		entries = append(entries, AuditEntry{
+			AdminEmail: res.AdminEmail,
+			Action:     AdminAction(res.Action),
+			TargetID:   res.TargetID,
+			Details:    res.Details,
+			CreatedAt:  res.CreatedAt,
+		})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get audit log", slog.Any("error", err))
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+func convertAdminAccountResult(res store.AdminAccountResult) AdminAccount {
+	account := AdminAccount{
+		ID:             res.AccountID,
+		ProfileID:      res.ProfileID,
+		Email:          res.Email,
+		FirstName:      res.FirstName,
+		LastName:       res.LastName,
+		DisplayName:    res.DisplayName,
+		Role:           res.Role,
+		SessionVersion: res.SessionVersion,
+		CreatedAt:      res.CreatedAt,
+	}
+	if res.DisabledAt != nil {
+		account.DisabledAt = *res.DisabledAt
+	}
+	if res.DeletionScheduledFor != nil {
+		account.DeletionScheduledFor = *res.DeletionScheduledFor
+	}
+	return account
+}
@@ -0,0 +1,68 @@
+package identityaccess_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestCanUseSession(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		account        identityaccess.Account
+		sessionVersion int
+		expected       bool
+	}{
+		"session from before the version existed": {
+			account:  identityaccess.Account{},
+			expected: true,
+		},
+		"session from the current version": {
+			account:        identityaccess.Account{SessionVersion: 2},
+			sessionVersion: 2,
+			expected:       true,
+		},
+		"session from before a force logout": {
+			account:        identityaccess.Account{SessionVersion: 2},
+			sessionVersion: 1,
+		},
+		"disabled account": {
+			account: identityaccess.Account{DisabledAt: time.Now()},
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testhelpers.Equals(t, tc.expected, tc.account.CanUseSession(tc.sessionVersion))
+		})
+	}
+}
+
+func TestAuditEntryTargetsAccount(t *testing.T) {
+	t.Parallel()
+
+	tests := map[identityaccess.AdminAction]bool{
+		identityaccess.AdminActionSearch:           false,
+		identityaccess.AdminActionViewAccount:      true,
+		identityaccess.AdminActionViewFailedLogins: false,
+		identityaccess.AdminActionDisableAccount:   true,
+		identityaccess.AdminActionEnableAccount:    true,
+		identityaccess.AdminActionForceLogout:      true,
+		identityaccess.AdminActionRefreshMovie:     false,
+	}
+
+	for action, expected := range tests {
+		t.Run(string(action), func(t *testing.T) {
+			t.Parallel()
+
+			entry := identityaccess.AuditEntry{Action: action}
+			testhelpers.Equals(t, expected, entry.TargetsAccount())
+			testhelpers.Assert(t, action.Label() != string(action), "expected %s to have a label", action)
+		})
+	}
+}
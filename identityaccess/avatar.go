@@ -0,0 +1,230 @@
+package identityaccess
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"io"
+	"log/slog"
+	"regexp"
+
+	// gif and png are registered so uploads in either format can be decoded, jpeg is imported above for encoding
+	_ "image/gif"
+	_ "image/png"
+
+	"github.com/jm96441n/movieswithfriends/blobstore"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+const (
+	// AvatarSize is the width and height in pixels every avatar is stored at
+	AvatarSize = 256
+	// MaxAvatarUploadSize is the most bytes an uploaded image can be before it's decoded
+	MaxAvatarUploadSize = 5 << 20
+	// maxAvatarDimension stops images that are small on disk but huge once decoded from being decoded
+	maxAvatarDimension = 4096
+	avatarJPEGQuality  = 85
+	avatarKeyPrefix    = "avatars/"
+)
+
+var (
+	ErrAvatarTooLarge           = errors.New("avatar must be 5MB or smaller")
+	ErrAvatarDimensionsTooLarge = fmt.Errorf("avatar must be %dx%d pixels or smaller", maxAvatarDimension, maxAvatarDimension)
+	ErrUnsupportedAvatarFormat  = errors.New("avatar must be a JPEG, PNG or GIF")
+	ErrAvatarNotFound           = errors.New("avatar not found")
+)
+
+// avatarNamePattern matches the names avatars are stored under, anything else asked for isn't an avatar
+var avatarNamePattern = regexp.MustCompile(`^[0-9]+-[0-9a-f]{16}\.jpg$`)
+
+var supportedAvatarFormats = map[string]bool{"jpeg": true, "png": true, "gif": true}
+
+// AvatarPath is where the avatar stored at the key is served from, it's empty when there's no avatar. the key changes
+// every time a new avatar is uploaded so what's at the path never changes
+func AvatarPath(key string) string {
+	if key == "" {
+		return ""
+	}
+	return "/" + key
+}
+
+// ProcessAvatar checks an uploaded image is one that can be used as an avatar, crops it to a square from its centre,
+// resizes it to AvatarSize and re-encodes it as a JPEG so nothing from the original file is kept
+func ProcessAvatar(r io.Reader) ([]byte, error) {
+	raw, err := io.ReadAll(io.LimitReader(r, MaxAvatarUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(raw) > MaxAvatarUploadSize {
+		return nil, ErrAvatarTooLarge
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || !supportedAvatarFormats[format] {
+		return nil, ErrUnsupportedAvatarFormat
+	}
+
+	if cfg.Width > maxAvatarDimension || cfg.Height > maxAvatarDimension {
+		return nil, ErrAvatarDimensionsTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, ErrUnsupportedAvatarFormat
+	}
+
+	var buf bytes.Buffer
+	err = jpeg.Encode(&buf, resizeAvatar(img), &jpeg.Options{Quality: avatarJPEGQuality})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// resizeAvatar crops the image to the largest square in its centre and scales it to AvatarSize, each pixel is the
+// average of the pixels it covers. transparent parts are put on white since JPEGs can't be transparent
+func resizeAvatar(img image.Image) *image.RGBA {
+	bounds := img.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	origin := image.Pt(bounds.Min.X+(bounds.Dx()-side)/2, bounds.Min.Y+(bounds.Dy()-side)/2)
+
+	square := image.NewRGBA(image.Rect(0, 0, side, side))
+	draw.Draw(square, square.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), img, origin, draw.Over)
+
+	out := image.NewRGBA(image.Rect(0, 0, AvatarSize, AvatarSize))
+	for y := 0; y < AvatarSize; y++ {
+		y0 := y * side / AvatarSize
+		y1 := max((y+1)*side/AvatarSize, y0+1)
+		for x := 0; x < AvatarSize; x++ {
+			x0 := x * side / AvatarSize
+			x1 := max((x+1)*side/AvatarSize, x0+1)
+
+			var r, g, b, count int
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					offset := square.PixOffset(sx, sy)
+					r += int(square.Pix[offset])
+					g += int(square.Pix[offset+1])
+					b += int(square.Pix[offset+2])
+					count++
+				}
+			}
+
+			offset := out.PixOffset(x, y)
+			out.Pix[offset] = uint8(r / count)
+			out.Pix[offset+1] = uint8(g / count)
+			out.Pix[offset+2] = uint8(b / count)
+			out.Pix[offset+3] = 0xff
+		}
+	}
+
+	return out
+}
+
+func newAvatarKey(idProfile int) (string, error) {
+	b := make([]byte, 8)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s%d-%s.jpg", avatarKeyPrefix, idProfile, hex.EncodeToString(b)), nil
+}
+
+// SetAvatar replaces the profile's avatar with the uploaded image, the old one is removed once the new one is saved
+func (p *ProfileService) SetAvatar(ctx context.Context, logger *slog.Logger, profile *Profile, upload io.Reader) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "profileService.SetAvatar")
+	defer span.End()
+
+	avatar, err := ProcessAvatar(upload)
+	if err != nil {
+		return err
+	}
+
+	key, err := newAvatarKey(profile.ID)
+	if err != nil {
+		return err
+	}
+
+	err = p.blobs.Put(ctx, key, bytes.NewReader(avatar))
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to store avatar", slog.Any("error", err))
+		return err
+	}
+
+	err = p.db.SetAvatarKey(ctx, profile.ID, key)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to save avatar key", slog.Any("error", err))
+		p.deleteAvatar(ctx, logger, key)
+		return err
+	}
+
+	p.deleteAvatar(ctx, logger, profile.AvatarKey)
+	profile.AvatarKey = key
+
+	return nil
+}
+
+// RemoveAvatar takes the profile back to not having an avatar
+func (p *ProfileService) RemoveAvatar(ctx context.Context, logger *slog.Logger, profile *Profile) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "profileService.RemoveAvatar")
+	defer span.End()
+
+	err := p.db.SetAvatarKey(ctx, profile.ID, "")
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to remove avatar key", slog.Any("error", err))
+		return err
+	}
+
+	p.deleteAvatar(ctx, logger, profile.AvatarKey)
+	profile.AvatarKey = ""
+
+	return nil
+}
+
+// GetAvatar opens the avatar stored under the name, ErrAvatarNotFound is returned for names that aren't avatars
+func (p *ProfileService) GetAvatar(ctx context.Context, name string) (io.ReadCloser, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileService.GetAvatar")
+	defer span.End()
+
+	if !avatarNamePattern.MatchString(name) {
+		return nil, ErrAvatarNotFound
+	}
+
+	avatar, err := p.blobs.Get(ctx, avatarKeyPrefix+name)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		return nil, ErrAvatarNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return avatar, nil
+}
+
+// deleteAvatar removes an avatar that's no longer used, a failure only leaves an unused file behind so it's logged
+// rather than returned
+func (p *ProfileService) deleteAvatar(ctx context.Context, logger *slog.Logger, key string) {
+	if key == "" {
+		return
+	}
+
+	err := p.blobs.Delete(ctx, key)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to delete unused avatar", slog.Any("error", err), slog.String("key", key))
+	}
+}
@@ -0,0 +1,100 @@
+package identityaccess_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	testhelpers.Ok(t, err, "failed to encode png")
+	return buf.Bytes()
+}
+
+func TestProcessAvatar(t *testing.T) {
+	t.Parallel()
+
+	// a wide image with red on the left, blue in the middle and green on the right so the crop can be checked
+	wide := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	for x := 0; x < 300; x++ {
+		c := color.RGBA{R: 255, A: 255}
+		switch {
+		case x >= 200:
+			c = color.RGBA{G: 255, A: 255}
+		case x >= 100:
+			c = color.RGBA{B: 255, A: 255}
+		}
+		for y := 0; y < 100; y++ {
+			wide.Set(x, y, c)
+		}
+	}
+
+	tests := map[string]struct {
+		upload        []byte
+		expectedErr   error
+		expectedColor color.RGBA
+	}{
+		"crops to the centre and resizes": {
+			upload:        encodePNG(t, wide),
+			expectedColor: color.RGBA{B: 255, A: 255},
+		},
+		"puts transparent images on white": {
+			upload:        encodePNG(t, image.NewNRGBA(image.Rect(0, 0, 10, 10))),
+			expectedColor: color.RGBA{R: 255, G: 255, B: 255, A: 255},
+		},
+		"rejects files that aren't images": {
+			upload:      []byte("name,year\nHeat,1995\n"),
+			expectedErr: identityaccess.ErrUnsupportedAvatarFormat,
+		},
+		"rejects images that are too big once decoded": {
+			upload:      encodePNG(t, image.NewGray(image.Rect(0, 0, 5000, 1))),
+			expectedErr: identityaccess.ErrAvatarDimensionsTooLarge,
+		},
+		"rejects files that are too large": {
+			upload:      make([]byte, identityaccess.MaxAvatarUploadSize+1),
+			expectedErr: identityaccess.ErrAvatarTooLarge,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := identityaccess.ProcessAvatar(bytes.NewReader(tc.upload))
+			if tc.expectedErr != nil {
+				testhelpers.Assert(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+				return
+			}
+
+			testhelpers.Ok(t, err, "failed to process avatar")
+
+			avatar, err := jpeg.Decode(bytes.NewReader(got))
+			testhelpers.Ok(t, err, "avatar isn't a jpeg")
+			testhelpers.Equals(t, image.Rect(0, 0, identityaccess.AvatarSize, identityaccess.AvatarSize), avatar.Bounds())
+
+			// jpeg is lossy so the colour only has to be close
+			r, g, b, _ := avatar.At(identityaccess.AvatarSize/2, identityaccess.AvatarSize/2).RGBA()
+			for i, pair := range [][2]uint32{{r >> 8, uint32(tc.expectedColor.R)}, {g >> 8, uint32(tc.expectedColor.G)}, {b >> 8, uint32(tc.expectedColor.B)}} {
+				diff := int(pair[0]) - int(pair[1])
+				testhelpers.Assert(t, diff > -10 && diff < 10, "channel %d expected around %d, got %d", i, pair[1], pair[0])
+			}
+		})
+	}
+}
+
+func TestAvatarPath(t *testing.T) {
+	t.Parallel()
+
+	testhelpers.Equals(t, "/avatars/1-0123456789abcdef.jpg", identityaccess.AvatarPath("avatars/1-0123456789abcdef.jpg"))
+	testhelpers.Equals(t, "", identityaccess.AvatarPath(""))
+}
@@ -0,0 +1,149 @@
+package identityaccess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess/store"
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AccountDeletionGracePeriod is how long someone has to change their mind after asking for their account to be deleted
+const AccountDeletionGracePeriod = 14 * 24 * time.Hour
+
+// what a deleted account's profile is renamed to, it's still shown next to what they left in their parties
+const (
+	FormerMemberFirstName = "Former"
+	FormerMemberLastName  = "member"
+)
+
+var (
+	ErrAccountDeletionNotScheduled = errors.New("account isn't scheduled for deletion")
+	ErrAccountDeletionNotDue       = errors.New("account isn't due to be deleted")
+)
+
+// DeletionScheduled is whether the account is going to be deleted
+func (p *Profile) DeletionScheduled() bool {
+	return !p.DeletionScheduledFor.IsZero()
+}
+
+// CheckPassword makes sure the password is the account's, for confirming it's really them before anything drastic
+func (p *Profile) CheckPassword(ctx context.Context, logger *slog.Logger, password string) error {
+	_, span, _ := metrics.SpanFromContext(ctx, "profile.CheckPassword")
+	defer span.End()
+
+	err := bcrypt.CompareHashAndPassword(p.Account.Password, []byte(password))
+	if err != nil {
+		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+			return fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
+		}
+		logger.ErrorContext(ctx, "error comparing password", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// ScheduleDeletion marks the profile's account to be deleted once the grace period after now has passed, until then
+// the account works as normal and the deletion can be cancelled
+func (p *ProfileService) ScheduleDeletion(ctx context.Context, logger *slog.Logger, profile *Profile, now time.Time) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "profileService.ScheduleDeletion")
+	defer span.End()
+
+	scheduledFor := now.UTC().Add(AccountDeletionGracePeriod)
+	err := p.db.ScheduleAccountDeletion(ctx, profile.Account.ID, scheduledFor)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to schedule account deletion", slog.Any("error", err))
+		return err
+	}
+
+	profile.DeletionScheduledFor = scheduledFor
+	logger.InfoContext(ctx, "scheduled account deletion", slog.Time("scheduledFor", scheduledFor))
+
+	return nil
+}
+
+// CancelDeletion stops the profile's account from being deleted
+func (p *ProfileService) CancelDeletion(ctx context.Context, logger *slog.Logger, profile *Profile) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "profileService.CancelDeletion")
+	defer span.End()
+
+	err := p.db.CancelAccountDeletion(ctx, profile.Account.ID)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrAccountDeletionNotScheduled
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to cancel account deletion", slog.Any("error", err))
+		return err
+	}
+
+	profile.DeletionScheduledFor = time.Time{}
+	logger.InfoContext(ctx, "cancelled account deletion")
+
+	return nil
+}
+
+// AccountDeletion is an account whose grace period is over
+type AccountDeletion struct {
+	AccountID int
+	Email     string
+	ProfileID int
+	AvatarKey string
+}
+
+// GetAccountsDueForDeletion returns the accounts whose grace period had ended by now
+func (p *ProfileService) GetAccountsDueForDeletion(ctx context.Context, now time.Time) ([]AccountDeletion, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileService.GetAccountsDueForDeletion")
+	defer span.End()
+
+	deletions := make([]AccountDeletion, 0)
+	err := p.db.GetAccountsDueForDeletion(ctx, now, func(res store.AccountDeletionResult) {
+		deletions = append(deletions, AccountDeletion{
+			AccountID: res.AccountID,
+			Email:     res.AccountEmail,
+			ProfileID: res.ProfileID,
+			AvatarKey: res.AvatarKey,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return deletions, nil
+}
+
+// DeleteAccount deletes the account for good, its profile is kept as a "Former member" with nothing about who they
+// were left on it
+func (p *ProfileService) DeleteAccount(ctx context.Context, logger *slog.Logger, deletion AccountDeletion, now time.Time) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "profileService.DeleteAccount")
+	defer span.End()
+
+	err := p.db.DeleteAccount(ctx, store.DeleteAccountAttrs{
+		AccountID: deletion.AccountID,
+		ProfileID: deletion.ProfileID,
+		FirstName: FormerMemberFirstName,
+		LastName:  FormerMemberLastName,
+		Now:       now,
+	})
+	// they cancelled after it was picked up
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrAccountDeletionNotDue
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to delete account", slog.Any("error", err))
+		return err
+	}
+
+	p.deleteAvatar(ctx, logger, deletion.AvatarKey)
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package identityaccess_test
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestCheckPassword(t *testing.T) {
+	t.Parallel()
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte("Password1"), bcrypt.MinCost)
+	testhelpers.Ok(t, err, "failed to hash password")
+
+	profile := &identityaccess.Profile{Account: identityaccess.Account{Password: hashed}}
+
+	tests := map[string]struct {
+		password    string
+		expectedErr error
+	}{
+		"the account's password": {
+			password: "Password1",
+		},
+		"someone else's password": {
+			password:    "password1",
+			expectedErr: identityaccess.ErrInvalidCredentials,
+		},
+		"no password": {
+			expectedErr: identityaccess.ErrInvalidCredentials,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			err := profile.CheckPassword(context.Background(), slog.Default(), tc.password)
+			if tc.expectedErr == nil {
+				testhelpers.Ok(t, err, "expected password to match")
+				return
+			}
+			testhelpers.Assert(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+		})
+	}
+}
+
+func TestDeletionScheduled(t *testing.T) {
+	t.Parallel()
+
+	profile := &identityaccess.Profile{}
+	testhelpers.Assert(t, !profile.DeletionScheduled(), "expected a new profile not to be scheduled for deletion")
+
+	profile.DeletionScheduledFor = time.Now().Add(identityaccess.AccountDeletionGracePeriod)
+	testhelpers.Assert(t, profile.DeletionScheduled(), "expected profile to be scheduled for deletion")
+}
@@ -0,0 +1,30 @@
+package identityaccess
+
+// DefaultLanguage is the language a profile uses until they pick another one
+const DefaultLanguage = "en"
+
+type Language struct {
+	Code string
+	Name string
+}
+
+// SupportedLanguages are the ISO 639-1 codes TMDB has translations for that a profile can pick from
+var SupportedLanguages = []Language{
+	{Code: "en", Name: "English"},
+	{Code: "es", Name: "Español"},
+	{Code: "fr", Name: "Français"},
+	{Code: "de", Name: "Deutsch"},
+	{Code: "it", Name: "Italiano"},
+	{Code: "pt", Name: "Português"},
+	{Code: "ja", Name: "日本語"},
+	{Code: "ko", Name: "한국어"},
+}
+
+func IsSupportedLanguage(code string) bool {
+	for _, language := range SupportedLanguages {
+		if language.Code == code {
+			return true
+		}
+	}
+	return false
+}
@@ -50,6 +50,7 @@ var (
 
 	ErrInvalidCredentials = errors.New("invalid credentials")
 	ErrAccountExists      = errors.New("account already exists")
+	ErrAccountDisabled    = errors.New("account is disabled")
 )
 
 var numRegex = regexp.MustCompile("[0-9]+")
@@ -103,13 +104,16 @@ type Authenticator struct {
 	ProfileRepository *store.ProfileRepository
 }
 
-func (a *Authenticator) Authenticate(ctx context.Context, logger *slog.Logger, email, password string) (*Profile, error) {
+// Authenticate returns the profile for the email when the password is right, every login that doesn't work is recorded
+// along with the address it came from
+func (a *Authenticator) Authenticate(ctx context.Context, logger *slog.Logger, email, password, ipAddress string) (*Profile, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "authenticator.Authenticate")
 	defer span.End()
 	res, err := a.ProfileRepository.GetProfileByEmail(ctx, email)
 	if err != nil {
 		if errors.Is(err, store.ErrNoRecord) {
 			logger.ErrorContext(ctx, "account not found", slog.String("email", email))
+			a.recordFailedLogin(ctx, logger, email, ipAddress, failedLoginUnknownEmail)
 			return nil, ErrInvalidCredentials
 		}
 		logger.ErrorContext(ctx, "error finding profile by email", slog.Any("error", err), slog.String("email", email))
@@ -123,15 +127,38 @@ func (a *Authenticator) Authenticate(ctx context.Context, logger *slog.Logger, e
 	if err != nil {
 		if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
 			logger.ErrorContext(ctx, "incorrect password", slog.Any("error", err))
+			a.recordFailedLogin(ctx, logger, email, ipAddress, failedLoginWrongPassword)
 			return nil, fmt.Errorf("%w: %s", ErrInvalidCredentials, err)
 		}
 		logger.ErrorContext(ctx, "error comparing password", slog.Any("error", err))
 		return nil, err
 	}
 
+	// this is only checked once the password is right so it doesn't give away which emails have accounts
+	if profile.Account.Disabled() {
+		logger.WarnContext(ctx, "disabled account tried to log in", slog.Int("accountID", profile.Account.ID))
+		a.recordFailedLogin(ctx, logger, email, ipAddress, failedLoginAccountDisabled)
+		return nil, ErrAccountDisabled
+	}
+
 	return profile, nil
 }
 
+const (
+	failedLoginUnknownEmail    = "unknown_email"
+	failedLoginWrongPassword   = "wrong_password"
+	failedLoginAccountDisabled = "account_disabled"
+)
+
+// recordFailedLogin keeps the failed login for admins to look at, not being able to doesn't change the outcome of the
+// login so it's only logged
+func (a *Authenticator) recordFailedLogin(ctx context.Context, logger *slog.Logger, email, ipAddress, reason string) {
+	err := a.ProfileRepository.RecordFailedLogin(ctx, email, ipAddress, reason)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to record failed login", slog.Any("error", err))
+	}
+}
+
 func (a *Authenticator) AccountExists(ctx context.Context, accountID int) (bool, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "authenticator.AccountExists")
 	defer span.End()
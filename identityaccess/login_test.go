@@ -120,6 +120,10 @@ func TestAuthenticator_Authenticate(t *testing.T) {
 	testhelpers.Ok(t, err, "failed to generate password hash")
 
 	seedProfile(ctx, t, connPool, existingUserEmail, hashedPassword)
+	disabledUserEmail := "disabled@email.com"
+	disabledAccountID := seedProfile(ctx, t, connPool, disabledUserEmail, hashedPassword)
+	_, err = connPool.Exec(ctx, "update accounts set disabled_at = now() where id_account = $1", disabledAccountID)
+	testhelpers.Ok(t, err, "failed to disable account")
 
 	authenticator := &identityaccess.Authenticator{
 		ProfileRepository: store.NewProfileRepository(connPool),
@@ -145,15 +149,26 @@ func TestAuthenticator_Authenticate(t *testing.T) {
 			email:       existingUserEmail,
 			password:    "wrongPassword",
 		},
+		"accountIsDisabled": {
+			expectedErr: identityaccess.ErrAccountDisabled,
+			email:       disabledUserEmail,
+			password:    existingUserPassword,
+		},
 	}
 
 	logger := slog.New(slog.NewJSONHandler(io.Discard, nil))
 	for name, tc := range testCases {
 		t.Run(name, func(tt *testing.T) {
-			_, err := authenticator.Authenticate(ctx, logger, tc.email, tc.password)
+			_, err := authenticator.Authenticate(ctx, logger, tc.email, tc.password, "127.0.0.1")
 			testhelpers.Assert(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
 		})
 	}
+
+	// every login that didn't work is kept for admins
+	var failedLogins int
+	err = connPool.QueryRow(ctx, "select count(*) from failed_logins where ip_address = '127.0.0.1'").Scan(&failedLogins)
+	testhelpers.Ok(t, err, "failed to count failed logins")
+	testhelpers.Equals(t, 3, failedLogins)
 }
 
 func TestAccountExists(t *testing.T) {
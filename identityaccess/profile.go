@@ -1,12 +1,16 @@
 package identityaccess
 
 import (
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"log/slog"
+	"strings"
 	"time"
+	"unicode/utf8"
 
+	"github.com/jm96441n/movieswithfriends/blobstore"
 	"github.com/jm96441n/movieswithfriends/identityaccess/store"
 	"github.com/jm96441n/movieswithfriends/metrics"
 	"golang.org/x/crypto/bcrypt"
@@ -16,21 +20,59 @@ type Account struct {
 	ID       int
 	Email    string
 	Password []byte
+	Role     string
+	// DisabledAt is when an admin disabled the account, it's zero when it's enabled
+	DisabledAt time.Time
+	// SessionVersion is bumped to log the account out of every session it has
+	SessionVersion int
 }
 
+const (
+	MaxDisplayNameLength = 50
+	MaxBioLength         = 280
+	MaxFavoriteGenres    = 5
+)
+
 type Profile struct {
 	ID        int
 	FirstName string
 	LastName  string
-	CreatedAt time.Time
-	Stats     ProfileStats
-	Account   Account
-	db        *store.ProfileRepository
+	// DisplayName is what other watchers see instead of the legal name, it's empty when the profile hasn't set one
+	DisplayName string
+	Bio         string
+	// AvatarKey is where the profile's avatar is in the blob store, it's empty when they haven't uploaded one
+	AvatarKey         string
+	PreferredLanguage string
+	WatchRegion       string
+	// Subscriptions are the TMDB ids of the streaming services the profile pays for, they're only loaded by
+	// LoadSubscriptions
+	Subscriptions []int
+	// FavoriteGenres are the ids of the genres the profile likes most, they're only loaded by LoadFavoriteGenres
+	FavoriteGenres []int
+	CreatedAt      time.Time
+	// DeletionScheduledFor is when the account is going to be deleted, it's zero when it isn't
+	DeletionScheduledFor time.Time
+	Stats                ProfileStats
+	Account              Account
+	db                   *store.ProfileRepository
+}
+
+// Name is what the profile is shown as, the display name when there is one and the legal name otherwise
+func (p *Profile) Name() string {
+	return cmp.Or(p.DisplayName, p.FirstName+" "+p.LastName)
 }
 
 type ProfileUpdateReq struct {
-	FirstName               string
-	LastName                string
+	FirstName         string
+	LastName          string
+	DisplayName       string
+	Bio               string
+	PreferredLanguage string
+	WatchRegion       string
+	// Subscriptions replaces the profile's streaming services when it isn't nil, an empty slice removes them all
+	Subscriptions []int
+	// FavoriteGenres replaces the profile's favorite genres when it isn't nil, an empty slice removes them all
+	FavoriteGenres          []int
 	Email                   string
 	CurrentPassword         string
 	NewPassword             string
@@ -44,11 +86,12 @@ type ProfileStats struct {
 }
 
 type ProfileService struct {
-	db *store.ProfileRepository
+	db    *store.ProfileRepository
+	blobs blobstore.Store
 }
 
-func NewProfileService(db *store.ProfileRepository) *ProfileService {
-	return &ProfileService{db: db}
+func NewProfileService(db *store.ProfileRepository, blobs blobstore.Store) *ProfileService {
+	return &ProfileService{db: db, blobs: blobs}
 }
 
 func (p *ProfileService) GetProfileByID(ctx context.Context, profileID int) (*Profile, error) {
@@ -108,15 +151,47 @@ func hashPassword(password string) ([]byte, error) {
 func (p *Profile) Update(ctx context.Context, logger *slog.Logger, req ProfileUpdateReq) error {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "profile.Update")
 	defer span.End()
+
+	// the language is optional on the form, keep the current one when it isn't sent
+	if req.PreferredLanguage == "" {
+		req.PreferredLanguage = cmp.Or(p.PreferredLanguage, DefaultLanguage)
+	}
+
+	if req.WatchRegion == "" {
+		req.WatchRegion = cmp.Or(p.WatchRegion, DefaultWatchRegion)
+	}
+
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	req.Bio = strings.TrimSpace(req.Bio)
+
 	err := validateUpdateRequest(ctx, req)
 	if err != nil {
 		return err
 	}
 
+	// the ids come from the form so they're checked against the genres there are, rather than failing to save
+	if len(req.FavoriteGenres) > 0 {
+		exist, err := p.db.GenresExist(ctx, req.FavoriteGenres)
+		if err != nil {
+			logger.ErrorContext(ctx, "error checking favourite genres", slog.Any("error", err))
+			return err
+		}
+
+		if !exist {
+			return &ProfileEditValidationError{FavoriteGenresError: ErrInvalidFavoriteGenre}
+		}
+	}
+
 	updateProfileAttrs := store.ProfileUpdateAttrs{
-		ID:        p.ID,
-		FirstName: req.FirstName,
-		LastName:  req.LastName,
+		ID:                p.ID,
+		FirstName:         req.FirstName,
+		LastName:          req.LastName,
+		DisplayName:       req.DisplayName,
+		Bio:               req.Bio,
+		PreferredLanguage: req.PreferredLanguage,
+		WatchRegion:       req.WatchRegion,
+		Subscriptions:     req.Subscriptions,
+		FavoriteGenres:    req.FavoriteGenres,
 	}
 
 	updateAccountAttrs := store.AccountUpdateAttrs{
@@ -142,6 +217,16 @@ func (p *Profile) Update(ctx context.Context, logger *slog.Logger, req ProfileUp
 
 	p.FirstName = req.FirstName
 	p.LastName = req.LastName
+	p.DisplayName = req.DisplayName
+	p.Bio = req.Bio
+	p.PreferredLanguage = req.PreferredLanguage
+	p.WatchRegion = req.WatchRegion
+	if req.Subscriptions != nil {
+		p.Subscriptions = req.Subscriptions
+	}
+	if req.FavoriteGenres != nil {
+		p.FavoriteGenres = req.FavoriteGenres
+	}
 	p.Account.Email = req.Email
 
 	logger.InfoContext(ctx, "updated profile")
@@ -149,11 +234,52 @@ func (p *Profile) Update(ctx context.Context, logger *slog.Logger, req ProfileUp
 	return nil
 }
 
+// LoadSubscriptions fills in the streaming services the profile has
+func (p *Profile) LoadSubscriptions(ctx context.Context) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profile.LoadSubscriptions")
+	defer span.End()
+
+	subscriptions := make([]int, 0)
+	err := p.db.GetSubscriptions(ctx, p.ID, func(idProvider int) {
+		subscriptions = append(subscriptions, idProvider)
+	})
+	if err != nil {
+		return err
+	}
+
+	p.Subscriptions = subscriptions
+	return nil
+}
+
+// LoadFavoriteGenres fills in the genres the profile likes most
+func (p *Profile) LoadFavoriteGenres(ctx context.Context) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profile.LoadFavoriteGenres")
+	defer span.End()
+
+	genres := make([]int, 0)
+	err := p.db.GetFavoriteGenres(ctx, p.ID, func(idGenre int) {
+		genres = append(genres, idGenre)
+	})
+	if err != nil {
+		return err
+	}
+
+	p.FavoriteGenres = genres
+	return nil
+}
+
 var (
 	ErrFirstNameIsRequired            = errors.New("first name is required")
 	ErrLastNameIsRequired             = errors.New("last name is required")
 	ErrEmailIsRequired                = errors.New("email is required")
 	ErrNewPasswordMustMatchIsRequired = errors.New("email is required")
+	ErrUnsupportedLanguage            = errors.New("language is not supported")
+	ErrUnsupportedRegion              = errors.New("region is not supported")
+	ErrInvalidSubscription            = errors.New("subscription is not a valid streaming service")
+	ErrDisplayNameTooLong             = fmt.Errorf("display name must be %d characters or fewer", MaxDisplayNameLength)
+	ErrBioTooLong                     = fmt.Errorf("bio must be %d characters or fewer", MaxBioLength)
+	ErrTooManyFavoriteGenres          = fmt.Errorf("pick up to %d favourite genres", MaxFavoriteGenres)
+	ErrInvalidFavoriteGenre           = errors.New("favourite genre is not a valid genre")
 )
 
 type ProfileEditValidationError struct {
@@ -162,6 +288,12 @@ type ProfileEditValidationError struct {
 	NewPasswordMatchError error
 	FirstNameError        error
 	LastNameError         error
+	LanguageError         error
+	RegionError           error
+	SubscriptionsError    error
+	DisplayNameError      error
+	BioError              error
+	FavoriteGenresError   error
 }
 
 func (s *ProfileEditValidationError) Error() string {
@@ -169,7 +301,8 @@ func (s *ProfileEditValidationError) Error() string {
 }
 
 func (s *ProfileEditValidationError) IsNil() bool {
-	return s.EmailError == nil && s.PasswordError == nil && s.NewPasswordMatchError == nil && s.FirstNameError == nil && s.LastNameError == nil
+	return s.EmailError == nil && s.PasswordError == nil && s.NewPasswordMatchError == nil && s.FirstNameError == nil && s.LastNameError == nil && s.LanguageError == nil &&
+		s.RegionError == nil && s.SubscriptionsError == nil && s.DisplayNameError == nil && s.BioError == nil && s.FavoriteGenresError == nil
 }
 
 func validateUpdateRequest(ctx context.Context, req ProfileUpdateReq) error {
@@ -188,6 +321,40 @@ func validateUpdateRequest(ctx context.Context, req ProfileUpdateReq) error {
 		err.EmailError = ErrEmailIsRequired
 	}
 
+	if utf8.RuneCountInString(req.DisplayName) > MaxDisplayNameLength {
+		err.DisplayNameError = ErrDisplayNameTooLong
+	}
+
+	if utf8.RuneCountInString(req.Bio) > MaxBioLength {
+		err.BioError = ErrBioTooLong
+	}
+
+	if len(req.FavoriteGenres) > MaxFavoriteGenres {
+		err.FavoriteGenresError = ErrTooManyFavoriteGenres
+	}
+
+	for _, idGenre := range req.FavoriteGenres {
+		if idGenre <= 0 {
+			err.FavoriteGenresError = ErrInvalidFavoriteGenre
+			break
+		}
+	}
+
+	if !IsSupportedLanguage(req.PreferredLanguage) {
+		err.LanguageError = ErrUnsupportedLanguage
+	}
+
+	if !IsSupportedRegion(req.WatchRegion) {
+		err.RegionError = ErrUnsupportedRegion
+	}
+
+	for _, idProvider := range req.Subscriptions {
+		if idProvider <= 0 {
+			err.SubscriptionsError = ErrInvalidSubscription
+			break
+		}
+	}
+
 	if !err.IsNil() {
 		return &err
 	}
@@ -198,15 +365,32 @@ func validateUpdateRequest(ctx context.Context, req ProfileUpdateReq) error {
 func convertGetProfileResultToProfile(ctx context.Context, res store.GetProfileResult) *Profile {
 	_, span, _ := metrics.SpanFromContext(ctx, "convertGetProfileResultToProfile")
 	defer span.End()
+	var deletionScheduledFor, disabledAt time.Time
+	if res.DeletionScheduledFor != nil {
+		deletionScheduledFor = *res.DeletionScheduledFor
+	}
+	if res.AccountDisabledAt != nil {
+		disabledAt = *res.AccountDisabledAt
+	}
+
 	return &Profile{
-		ID:        res.ID,
-		FirstName: res.FirstName,
-		LastName:  res.LastName,
-		CreatedAt: res.CreatedAt,
+		ID:                   res.ID,
+		FirstName:            res.FirstName,
+		LastName:             res.LastName,
+		DisplayName:          res.DisplayName,
+		Bio:                  res.Bio,
+		AvatarKey:            res.AvatarKey,
+		PreferredLanguage:    res.PreferredLanguage,
+		WatchRegion:          res.WatchRegion,
+		CreatedAt:            res.CreatedAt,
+		DeletionScheduledFor: deletionScheduledFor,
 		Account: Account{
-			ID:       res.AccountID,
-			Email:    res.AccountEmail,
-			Password: res.AccountPassword,
+			ID:             res.AccountID,
+			Email:          res.AccountEmail,
+			Password:       res.AccountPassword,
+			Role:           res.AccountRole,
+			DisabledAt:     disabledAt,
+			SessionVersion: res.AccountSessionVersion,
 		},
 	}
 }
@@ -0,0 +1,38 @@
+package identityaccess
+
+// DefaultWatchRegion is the region used to look up streaming availability until a profile picks another one
+const DefaultWatchRegion = "US"
+
+type Region struct {
+	Code string
+	Name string
+}
+
+// SupportedRegions are the ISO 3166-1 codes TMDB has streaming availability for that a profile can pick from
+var SupportedRegions = []Region{
+	{Code: "US", Name: "United States"},
+	{Code: "CA", Name: "Canada"},
+	{Code: "GB", Name: "United Kingdom"},
+	{Code: "IE", Name: "Ireland"},
+	{Code: "AU", Name: "Australia"},
+	{Code: "NZ", Name: "New Zealand"},
+	{Code: "DE", Name: "Germany"},
+	{Code: "FR", Name: "France"},
+	{Code: "ES", Name: "Spain"},
+	{Code: "IT", Name: "Italy"},
+	{Code: "NL", Name: "Netherlands"},
+	{Code: "BR", Name: "Brazil"},
+	{Code: "MX", Name: "Mexico"},
+	{Code: "IN", Name: "India"},
+	{Code: "JP", Name: "Japan"},
+	{Code: "KR", Name: "South Korea"},
+}
+
+func IsSupportedRegion(code string) bool {
+	for _, region := range SupportedRegions {
+		if region.Code == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// AccountDeletionService deletes accounts when their owners ask, the parties they own are handed to someone else or
+// deleted and what they left in the rest is kept under a "Former member"
+type AccountDeletionService struct {
+	profileService   *identityaccess.ProfileService
+	departureService partymgmt.DepartureService
+}
+
+func NewAccountDeletionService(profileService *identityaccess.ProfileService, departureService partymgmt.DepartureService) *AccountDeletionService {
+	return &AccountDeletionService{
+		profileService:   profileService,
+		departureService: departureService,
+	}
+}
+
+// GetOwnedParties returns the parties the profile owns, something has to happen to each of them before the account
+// can go
+func (s *AccountDeletionService) GetOwnedParties(ctx context.Context, profileID int) ([]partymgmt.OwnedParty, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "accountDeletionService.GetOwnedParties")
+	defer span.End()
+
+	return s.departureService.GetOwnedParties(ctx, profileID)
+}
+
+// RequestDeletion schedules the profile's account to be deleted once the grace period is over, password has to be
+// theirs. newOwners says who each of their parties goes to, keyed by party, with 0 deleting the party
+func (s *AccountDeletionService) RequestDeletion(ctx context.Context, logger *slog.Logger, profile *identityaccess.Profile, password string, newOwners map[int]int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "accountDeletionService.RequestDeletion")
+	defer span.End()
+
+	err := profile.CheckPassword(ctx, logger, password)
+	if err != nil {
+		return err
+	}
+
+	err = s.departureService.PlanHandoffs(ctx, logger, profile.ID, newOwners)
+	if err != nil {
+		return err
+	}
+
+	return s.profileService.ScheduleDeletion(ctx, logger, profile, time.Now())
+}
+
+// CancelDeletion keeps the profile's account, their parties stay theirs
+func (s *AccountDeletionService) CancelDeletion(ctx context.Context, logger *slog.Logger, profile *identityaccess.Profile) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "accountDeletionService.CancelDeletion")
+	defer span.End()
+
+	err := s.profileService.CancelDeletion(ctx, logger, profile)
+	if err != nil {
+		return err
+	}
+
+	// the account is kept either way, but handoffs left behind would still be shown as planned
+	return s.departureService.CancelHandoffs(ctx, logger, profile.ID)
+}
+
+// StartDeletionWorker deletes accounts whose grace period is over in the background until the context is cancelled
+func (s *AccountDeletionService) StartDeletionWorker(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.deleteDueAccounts(ctx, logger)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *AccountDeletionService) deleteDueAccounts(ctx context.Context, logger *slog.Logger) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "accountDeletionService.deleteDueAccounts")
+	defer span.End()
+
+	now := time.Now().UTC()
+	deletions, err := s.profileService.GetAccountsDueForDeletion(ctx, now)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get accounts due for deletion", slog.Any("error", err))
+		return
+	}
+
+	for _, deletion := range deletions {
+		logger := logger.With(slog.Int("accountID", deletion.AccountID), slog.Int("profileID", deletion.ProfileID))
+
+		// the account is only deleted once they're out of their parties, when either fails the account is still due so
+		// the whole thing is tried again on the next run
+		err := s.departureService.RemoveWatcher(ctx, logger, deletion.ProfileID, deletion.Email, now)
+		if errors.Is(err, partymgmt.ErrDepartureNotDue) {
+			logger.InfoContext(ctx, "account deletion was cancelled before they were removed from their parties")
+			continue
+		}
+
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			logger.ErrorContext(ctx, "failed to remove account from their parties", slog.Any("error", err))
+			continue
+		}
+
+		err = s.profileService.DeleteAccount(ctx, logger, deletion, now)
+		// they cancelled in the moment between being taken out of their parties and the account going, they keep the
+		// account but will have to be invited back
+		if errors.Is(err, identityaccess.ErrAccountDeletionNotDue) {
+			logger.WarnContext(ctx, "account deletion was cancelled after they were removed from their parties")
+			continue
+		}
+
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			logger.ErrorContext(ctx, "failed to delete account", slog.Any("error", err))
+			continue
+		}
+
+		logger.InfoContext(ctx, "deleted account")
+	}
+}
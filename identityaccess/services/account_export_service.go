@@ -0,0 +1,172 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+
+	iamstore "github.com/jm96441n/movieswithfriends/identityaccess/store"
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// AccountExportService builds the archive of everything stored about an account so it can be handed back to the person
+// it belongs to
+type AccountExportService struct {
+	profileRepository *iamstore.ProfileRepository
+	exportService     partymgmt.ExportService
+}
+
+func NewAccountExportService(profileRepository *iamstore.ProfileRepository, exportService partymgmt.ExportService) *AccountExportService {
+	return &AccountExportService{
+		profileRepository: profileRepository,
+		exportService:     exportService,
+	}
+}
+
+type accountExportProfile struct {
+	Email             string    `json:"email"`
+	FirstName         string    `json:"first_name"`
+	LastName          string    `json:"last_name"`
+	PreferredLanguage string    `json:"preferred_language"`
+	WatchRegion       string    `json:"watch_region"`
+	Subscriptions     []int     `json:"streaming_subscriptions"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+const accountExportReadme = `This archive has everything Movies With Friends stores about your account.
+
+profile.json                    your account and profile settings, streaming subscriptions are TMDB provider ids
+parties.json                    the parties you're in and the ones you've been invited to
+watch_history.csv               every movie watched by the parties you're in
+watch_history.json              the same history as JSON
+watch_history_letterboxd.csv    the same history in a format Letterboxd can import
+movies_added.csv                every movie you've added to a party
+movies_added.json               the same movies as JSON
+`
+
+// AccountExport is the data for one account, it's loaded up front so a problem reading the account can be reported
+// before any of the archive is sent
+type AccountExport struct {
+	profileID     int
+	profile       accountExportProfile
+	memberships   []partymgmt.Membership
+	exportService partymgmt.ExportService
+}
+
+func (s *AccountExportService) LoadAccountExport(ctx context.Context, profileID int) (AccountExport, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "accountExportService.LoadAccountExport")
+	defer span.End()
+
+	profile, err := s.getProfile(ctx, profileID)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return AccountExport{}, err
+	}
+
+	memberships, err := s.exportService.GetMemberships(ctx, profileID)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return AccountExport{}, err
+	}
+
+	return AccountExport{
+		profileID:     profileID,
+		profile:       profile,
+		memberships:   memberships,
+		exportService: s.exportService,
+	}, nil
+}
+
+// Write writes the account's data to w as a zip, the movie lists are streamed into the archive as they're read so the
+// export is never held in memory
+func (e AccountExport) Write(ctx context.Context, logger *slog.Logger, w io.Writer) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "AccountExport.Write")
+	defer span.End()
+
+	zw := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{name: "README.txt", write: func(w io.Writer) error {
+			_, err := io.WriteString(w, accountExportReadme)
+			return err
+		}},
+		{name: "profile.json", write: writeJSON(e.profile)},
+		{name: "parties.json", write: writeJSON(e.memberships)},
+		{name: "watch_history.csv", write: func(w io.Writer) error {
+			return e.exportService.ExportWatchHistory(ctx, logger, e.profileID, partymgmt.ExportFormatCSV, w)
+		}},
+		{name: "watch_history.json", write: func(w io.Writer) error {
+			return e.exportService.ExportWatchHistory(ctx, logger, e.profileID, partymgmt.ExportFormatJSON, w)
+		}},
+		{name: "watch_history_letterboxd.csv", write: func(w io.Writer) error {
+			return e.exportService.ExportWatchHistory(ctx, logger, e.profileID, partymgmt.ExportFormatLetterboxd, w)
+		}},
+		{name: "movies_added.csv", write: func(w io.Writer) error {
+			return e.exportService.ExportMoviesAddedBy(ctx, logger, e.profileID, partymgmt.ExportFormatCSV, w)
+		}},
+		{name: "movies_added.json", write: func(w io.Writer) error {
+			return e.exportService.ExportMoviesAddedBy(ctx, logger, e.profileID, partymgmt.ExportFormatJSON, w)
+		}},
+	}
+
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			return fmt.Errorf("creating %s: %w", file.name, err)
+		}
+
+		err = file.write(fw)
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			return fmt.Errorf("writing %s: %w", file.name, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+func (s *AccountExportService) getProfile(ctx context.Context, profileID int) (accountExportProfile, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "accountExportService.getProfile")
+	defer span.End()
+
+	res, err := s.profileRepository.GetProfileByID(ctx, profileID)
+	if err != nil {
+		return accountExportProfile{}, err
+	}
+
+	profile := accountExportProfile{
+		Email:             res.AccountEmail,
+		FirstName:         res.FirstName,
+		LastName:          res.LastName,
+		PreferredLanguage: res.PreferredLanguage,
+		WatchRegion:       res.WatchRegion,
+		Subscriptions:     make([]int, 0),
+		CreatedAt:         res.CreatedAt,
+	}
+
+	err = s.profileRepository.GetSubscriptions(ctx, profileID, func(idProvider int) {
+		profile.Subscriptions = append(profile.Subscriptions, idProvider)
+	})
+	if err != nil {
+		return accountExportProfile{}, err
+	}
+
+	return profile, nil
+}
+
+func writeJSON(v any) func(io.Writer) error {
+	return func(w io.Writer) error {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// AdminService is everything the admin area does across accounts, parties and movies. What's looked at is recorded in
+// the audit log before it's loaded so looking is recorded even when loading fails
+type AdminService struct {
+	adminService  *identityaccess.AdminService
+	partyService  partymgmt.PartyService
+	movieService  *partymgmt.MovieService
+	exportService partymgmt.ExportService
+}
+
+func NewAdminService(adminService *identityaccess.AdminService, partyService partymgmt.PartyService, movieService *partymgmt.MovieService, exportService partymgmt.ExportService) *AdminService {
+	return &AdminService{
+		adminService:  adminService,
+		partyService:  partyService,
+		movieService:  movieService,
+		exportService: exportService,
+	}
+}
+
+type AdminSearchResults struct {
+	Accounts []identityaccess.AdminAccount
+	Parties  []partymgmt.PartySummary
+	Movies   []partymgmt.StoredMovie
+}
+
+// Search looks for term in the accounts, parties and saved movies
+func (s *AdminService) Search(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile, term string) (AdminSearchResults, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.Search")
+	defer span.End()
+
+	term = strings.TrimSpace(term)
+	err := s.adminService.RecordAction(ctx, logger, admin, identityaccess.AdminActionSearch, 0, term)
+	if err != nil {
+		return AdminSearchResults{}, err
+	}
+
+	accounts, err := s.adminService.SearchAccounts(ctx, logger, admin, term)
+	if err != nil {
+		return AdminSearchResults{}, err
+	}
+
+	parties, err := s.partyService.SearchParties(ctx, term, identityaccess.AdminSearchLimit)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to search parties", slog.Any("error", err))
+		return AdminSearchResults{}, err
+	}
+
+	movies, err := s.movieService.SearchStoredMovies(ctx, term, identityaccess.AdminSearchLimit)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to search movies", slog.Any("error", err))
+		return AdminSearchResults{}, err
+	}
+
+	return AdminSearchResults{Accounts: accounts, Parties: parties, Movies: movies}, nil
+}
+
+type AdminAccountDetails struct {
+	Account identityaccess.AdminAccount
+	// Memberships are the parties they're in and the ones they've been invited to
+	Memberships  []partymgmt.Membership
+	FailedLogins []identityaccess.FailedLogin
+}
+
+// GetAccount returns the profile's account along with their parties, invites and recent failed logins
+func (s *AdminService) GetAccount(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile, profileID int) (AdminAccountDetails, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "adminService.GetAccount")
+	defer span.End()
+
+	err := s.adminService.RecordAction(ctx, logger, admin, identityaccess.AdminActionViewAccount, profileID, "")
+	if err != nil {
+		return AdminAccountDetails{}, err
+	}
+
+	account, err := s.adminService.GetAccount(ctx, logger, admin, profileID)
+	if err != nil {
+		return AdminAccountDetails{}, err
+	}
+
+	memberships, err := s.exportService.GetMemberships(ctx, profileID)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get memberships", slog.Any("error", err), slog.Int("profileID", profileID))
+		return AdminAccountDetails{}, err
+	}
+
+	failedLogins, err := s.adminService.GetFailedLogins(ctx, logger, admin, account.Email, identityaccess.AccountFailedLoginsLimit)
+	if err != nil {
+		return AdminAccountDetails{}, err
+	}
+
+	return AdminAccountDetails{Account: account, Memberships: memberships, FailedLogins: failedLogins}, nil
+}
+
+func (s *AdminService) DisableAccount(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile, profileID int) error {
+	return s.adminService.DisableAccount(ctx, logger, admin, profileID)
+}
+
+func (s *AdminService) EnableAccount(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile, profileID int) error {
+	return s.adminService.EnableAccount(ctx, logger, admin, profileID)
+}
+
+func (s *AdminService) ForceLogout(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile, profileID int) error {
+	return s.adminService.ForceLogout(ctx, logger, admin, profileID)
+}
+
+// GetFailedLogins returns the most recent failed logins for every account
+func (s *AdminService) GetFailedLogins(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile) ([]identityaccess.FailedLogin, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminService.GetFailedLogins")
+	defer span.End()
+
+	err := s.adminService.RecordAction(ctx, logger, admin, identityaccess.AdminActionViewFailedLogins, 0, "")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.adminService.GetFailedLogins(ctx, logger, admin, "", identityaccess.FailedLoginsLimit)
+}
+
+func (s *AdminService) GetAuditLog(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile) ([]identityaccess.AuditEntry, error) {
+	return s.adminService.GetAuditLog(ctx, logger, admin)
+}
+
+// RefreshMovie refetches a saved movie from TMDB, the attempt is recorded whether it works or not
+func (s *AdminService) RefreshMovie(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile, idMovie int) (partymgmt.Movie, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminService.RefreshMovie")
+	defer span.End()
+
+	err := s.adminService.RecordAction(ctx, logger, admin, identityaccess.AdminActionRefreshMovie, idMovie, "")
+	if err != nil {
+		return partymgmt.Movie{}, err
+	}
+
+	return s.movieService.RefreshMovie(ctx, logger, idMovie)
+}
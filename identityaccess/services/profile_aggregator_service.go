@@ -27,20 +27,12 @@ func NewProfileAggregatorService(profileRepository *iamstore.ProfileRepository,
 	}
 }
 
-type MovieData struct {
-	NumPages      int
-	CurPage       int
-	WatchedMovies []partymgmt.PartyMovie
-}
-
 type ProfilePageData struct {
 	Profile        *identityaccess.Profile
 	Parties        []partymgmt.Party
 	InvitedParties []partymgmt.Party
 
-	NumPages      int
-	CurPage       int
-	WatchedMovies []partymgmt.PartyMovie
+	WatchHistory partymgmt.WatchHistoryPage
 }
 
 type profileResult struct {
@@ -60,8 +52,8 @@ type partiesResult struct {
 }
 
 type watchHistoryResult struct {
-	movieData MovieData
-	err       error
+	page partymgmt.WatchHistoryPage
+	err  error
 }
 
 func (p *ProfileAggregatorService) GetProfilePageData(ctx context.Context, logger *slog.Logger, profileID int) (ProfilePageData, error) {
@@ -88,8 +80,8 @@ func (p *ProfileAggregatorService) GetProfilePageData(ctx context.Context, logge
 	}()
 
 	go func() {
-		movieData, err := p.GetWatchPaginatedHistory(ctx, logger, profileID, PageInfo{PageNum: 1})
-		watchHistoryResultCh <- watchHistoryResult{movieData: movieData, err: err}
+		page, err := p.GetWatchHistory(ctx, logger, profileID, partymgmt.WatchHistoryQuery{PageSize: recentWatchHistoryPageSize})
+		watchHistoryResultCh <- watchHistoryResult{page: page, err: err}
 	}()
 
 	profRes := <-profResultCh
@@ -116,11 +108,9 @@ func (p *ProfileAggregatorService) GetProfilePageData(ctx context.Context, logge
 	profRes.profile.Stats = statsRes.stats
 
 	return ProfilePageData{
-		Profile:       profRes.profile,
-		Parties:       partiesRes.parties,
-		NumPages:      movieDataRes.movieData.NumPages,
-		CurPage:       movieDataRes.movieData.CurPage,
-		WatchedMovies: movieDataRes.movieData.WatchedMovies,
+		Profile:      profRes.profile,
+		Parties:      partiesRes.parties,
+		WatchHistory: movieDataRes.page,
 	}, nil
 }
 
@@ -132,11 +122,25 @@ func (p *ProfileAggregatorService) getProfile(ctx context.Context, profileID int
 		return nil, err
 	}
 
+	favoriteGenres := make([]int, 0)
+	err = p.profileRepository.GetFavoriteGenres(ctx, profileID, func(idGenre int) {
+		favoriteGenres = append(favoriteGenres, idGenre)
+	})
+	if err != nil {
+		return nil, err
+	}
+
 	return &identityaccess.Profile{
-		ID:        profileID,
-		FirstName: getProfResult.FirstName,
-		LastName:  getProfResult.LastName,
-		CreatedAt: getProfResult.CreatedAt,
+		ID:                profileID,
+		FirstName:         getProfResult.FirstName,
+		LastName:          getProfResult.LastName,
+		DisplayName:       getProfResult.DisplayName,
+		Bio:               getProfResult.Bio,
+		AvatarKey:         getProfResult.AvatarKey,
+		PreferredLanguage: getProfResult.PreferredLanguage,
+		WatchRegion:       getProfResult.WatchRegion,
+		FavoriteGenres:    favoriteGenres,
+		CreatedAt:         getProfResult.CreatedAt,
 		Account: identityaccess.Account{
 			ID:    getProfResult.AccountID,
 			Email: getProfResult.AccountEmail,
@@ -176,50 +180,17 @@ func (p *ProfileAggregatorService) getParties(ctx context.Context, profileID int
 	return parties, invites, nil
 }
 
-type PageInfo struct {
-	PageNum  int
-	PageSize int
-}
-
-const defaultPageSize = 5
+// recentWatchHistoryPageSize is how many movies are shown in the watch history on the profile page
+const recentWatchHistoryPageSize = 5
 
-func (p *ProfileAggregatorService) GetWatchPaginatedHistory(ctx context.Context, logger *slog.Logger, profileID int, pageInfo PageInfo) (MovieData, error) {
-	ctx, span, _ := metrics.SpanFromContext(ctx, "profileAggregatorService.GetWatchPaginatedHistory")
+func (p *ProfileAggregatorService) GetWatchHistory(ctx context.Context, logger *slog.Logger, profileID int, query partymgmt.WatchHistoryQuery) (partymgmt.WatchHistoryPage, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileAggregatorService.GetWatchHistory")
 	defer span.End()
-	pageNum := pageInfo.PageNum
-	pageSize := max(defaultPageSize, pageInfo.PageSize)
-
-	offset := pageSize * (pageNum - 1)
 
 	watcher, err := p.watcherService.NewWatcher(ctx, profileID)
 	if err != nil {
-		return MovieData{}, err
+		return partymgmt.WatchHistoryPage{}, err
 	}
 
-	watchedMovies, numMovies, err := watcher.GetWatchHistory(ctx, logger, offset)
-	if err != nil {
-		return MovieData{}, err
-	}
-
-	numPages := numMovies / pageSize
-	if numMovies > numPages*pageSize {
-		numPages++
-	}
-
-	movies := make([]partymgmt.PartyMovie, 0, len(watchedMovies))
-
-	for _, movie := range watchedMovies {
-		movies = append(movies, partymgmt.PartyMovie{
-			ID:        movie.IDMovie,
-			Title:     movie.Title,
-			WatchDate: movie.WatchDate,
-			PartyName: movie.PartyName,
-		})
-	}
-
-	return MovieData{
-		NumPages:      numPages,
-		CurPage:       pageNum,
-		WatchedMovies: movies,
-	}, nil
+	return watcher.GetWatchHistory(ctx, logger, query)
 }
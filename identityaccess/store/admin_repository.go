@@ -0,0 +1,269 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+// AdminRepository is what the admin area reads and changes about accounts, every change is written to the audit log
+// in the same transaction as the change itself
+type AdminRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAdminRepository(db *pgxpool.Pool) *AdminRepository {
+	return &AdminRepository{db: db}
+}
+
+type AdminAccountResult struct {
+	AccountID      int
+	ProfileID      int
+	Email          string
+	FirstName      string
+	LastName       string
+	DisplayName    string
+	Role           string
+	SessionVersion int
+	CreatedAt      time.Time
+	// DisabledAt is nil when the account is enabled
+	DisabledAt *time.Time
+	// DeletionScheduledFor is nil when the account isn't going to be deleted
+	DeletionScheduledFor *time.Time
+}
+
+const adminAccountColumns = `
+    accounts.id_account,
+    profiles.id_profile,
+    accounts.email,
+    profiles.first_name,
+    profiles.last_name,
+    coalesce(profiles.display_name, ''),
+    accounts.role,
+    accounts.session_version,
+    accounts.created_at,
+    accounts.disabled_at,
+    accounts.deletion_scheduled_for`
+
+func scanAdminAccount(row pgx.Row) (AdminAccountResult, error) {
+	res := AdminAccountResult{}
+	err := row.Scan(&res.AccountID, &res.ProfileID, &res.Email, &res.FirstName, &res.LastName, &res.DisplayName, &res.Role, &res.SessionVersion, &res.CreatedAt, &res.DisabledAt, &res.DeletionScheduledFor)
+	return res, err
+}
+
+const searchAccountsQuery = `
+  select` + adminAccountColumns + `
+  from accounts
+  join profiles on profiles.id_account = accounts.id_account
+  where accounts.email ilike '%' || $1 || '%'
+    or profiles.first_name || ' ' || profiles.last_name ilike '%' || $1 || '%'
+    or profiles.display_name ilike '%' || $1 || '%'
+  order by accounts.email
+  limit $2`
+
+// SearchAccounts returns the accounts whose email, name or display name has term in it
+func (a *AdminRepository) SearchAccounts(ctx context.Context, term string, limit int, assignFn func(AdminAccountResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.SearchAccounts")
+	defer span.End()
+	rows, err := a.db.Query(ctx, searchAccountsQuery, term, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanAdminAccount(rows)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const getAdminAccountQuery = `
+  select` + adminAccountColumns + `
+  from accounts
+  join profiles on profiles.id_account = accounts.id_account
+  where profiles.id_profile = $1`
+
+// GetAccount returns the account belonging to the profile, ErrNoRecord is returned when there isn't one
+func (a *AdminRepository) GetAccount(ctx context.Context, profileID int) (AdminAccountResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.GetAccount")
+	defer span.End()
+	res, err := scanAdminAccount(a.db.QueryRow(ctx, getAdminAccountQuery, profileID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return AdminAccountResult{}, ErrNoRecord
+		}
+		return AdminAccountResult{}, err
+	}
+
+	return res, nil
+}
+
+type AuditEntryAttrs struct {
+	AdminID    int
+	AdminEmail string
+	Action     string
+	// TargetID is the profile or movie the action was on, 0 when it wasn't on one
+	TargetID int
+	Details  string
+}
+
+const recordAuditEntryQuery = `
+  insert into admin_audit_log (id_admin, admin_email, action, id_target, details)
+  values ($1, $2, $3, nullif($4, 0), $5)`
+
+// RecordAuditEntry writes an entry to the audit log for something an admin did that didn't change an account
+func (a *AdminRepository) RecordAuditEntry(ctx context.Context, attrs AuditEntryAttrs) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.RecordAuditEntry")
+	defer span.End()
+	_, err := a.db.Exec(ctx, recordAuditEntryQuery, attrs.AdminID, attrs.AdminEmail, attrs.Action, attrs.TargetID, attrs.Details)
+	return err
+}
+
+const (
+	// disabling also bumps the session version so the account is logged out of every session it has, enabling it
+	// again doesn't bring them back
+	disableAccountQuery = `
+  update accounts
+  set disabled_at = $2, session_version = session_version + 1
+  where id_account = $1 and disabled_at is null`
+	enableAccountQuery = `
+  update accounts
+  set disabled_at = null
+  where id_account = $1 and disabled_at is not null`
+	endSessionsQuery = `
+  update accounts
+  set session_version = session_version + 1
+  where id_account = $1`
+)
+
+// DisableAccount stops the account from logging in, ErrNoRecord is returned when it's already disabled
+func (a *AdminRepository) DisableAccount(ctx context.Context, accountID int, now time.Time, audit AuditEntryAttrs) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.DisableAccount")
+	defer span.End()
+	return a.updateAccount(ctx, audit, disableAccountQuery, accountID, now)
+}
+
+// EnableAccount lets a disabled account log in again, ErrNoRecord is returned when it isn't disabled
+func (a *AdminRepository) EnableAccount(ctx context.Context, accountID int, audit AuditEntryAttrs) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.EnableAccount")
+	defer span.End()
+	return a.updateAccount(ctx, audit, enableAccountQuery, accountID)
+}
+
+// EndSessions logs the account out of every session it has, ErrNoRecord is returned when there's no such account
+func (a *AdminRepository) EndSessions(ctx context.Context, accountID int, audit AuditEntryAttrs) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.EndSessions")
+	defer span.End()
+	return a.updateAccount(ctx, audit, endSessionsQuery, accountID)
+}
+
+// updateAccount runs a query changing one account and records it in the audit log, nothing is recorded when the query
+// didn't change anything
+func (a *AdminRepository) updateAccount(ctx context.Context, audit AuditEntryAttrs, query string, args ...any) error {
+	txn, err := a.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer txn.Rollback(ctx)
+
+	tag, err := txn.Exec(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = txn.Exec(ctx, recordAuditEntryQuery, audit.AdminID, audit.AdminEmail, audit.Action, audit.TargetID, audit.Details)
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit(ctx)
+}
+
+type FailedLoginResult struct {
+	Email     string
+	IPAddress string
+	Reason    string
+	CreatedAt time.Time
+}
+
+const getFailedLoginsQuery = `
+  select email, ip_address, reason, created_at
+  from failed_logins
+  where $1 = '' or lower(email) = lower($1)
+  order by created_at desc
+  limit $2`
+
+// GetFailedLogins returns the most recent failed logins, only the ones for email when it isn't empty
+func (a *AdminRepository) GetFailedLogins(ctx context.Context, email string, limit int, assignFn func(FailedLoginResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.GetFailedLogins")
+	defer span.End()
+	rows, err := a.db.Query(ctx, getFailedLoginsQuery, email, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		res := FailedLoginResult{}
+		err := rows.Scan(&res.Email, &res.IPAddress, &res.Reason, &res.CreatedAt)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+type AuditEntryResult struct {
+	AdminEmail string
+	Action     string
+	TargetID   int
+	Details    string
+	CreatedAt  time.Time
+}
+
+const getAuditLogQuery = `
+  select admin_email, action, coalesce(id_target, 0), details, created_at
+  from admin_audit_log
+  order by created_at desc, id_admin_audit_log desc
+  limit $1`
+
+// GetAuditLog returns the most recent entries in the audit log
+func (a *AdminRepository) GetAuditLog(ctx context.Context, limit int, assignFn func(AuditEntryResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "adminRepository.GetAuditLog")
+	defer span.End()
+	rows, err := a.db.Query(ctx, getAuditLogQuery, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		res := AuditEntryResult{}
+		err := rows.Scan(&res.AdminEmail, &res.Action, &res.TargetID, &res.Details, &res.CreatedAt)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
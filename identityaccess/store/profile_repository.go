@@ -28,13 +28,24 @@ func NewProfileRepository(db *pgxpool.Pool) *ProfileRepository {
 }
 
 type GetProfileResult struct {
-	ID              int
-	FirstName       string
-	LastName        string
-	CreatedAt       time.Time
-	AccountID       int
-	AccountEmail    string
-	AccountPassword []byte
+	ID                int
+	FirstName         string
+	LastName          string
+	DisplayName       string
+	Bio               string
+	AvatarKey         string
+	PreferredLanguage string
+	WatchRegion       string
+	CreatedAt         time.Time
+	AccountID         int
+	AccountEmail      string
+	AccountPassword   []byte
+	AccountRole       string
+	// AccountDisabledAt is when an admin disabled the account, it's nil when it's enabled
+	AccountDisabledAt     *time.Time
+	AccountSessionVersion int
+	// DeletionScheduledFor is when the account is going to be deleted, it's nil when it isn't
+	DeletionScheduledFor *time.Time
 }
 
 const getProfileByIDQuery = `
@@ -42,10 +53,19 @@ const getProfileByIDQuery = `
     profiles.id_profile,
     profiles.first_name,
     profiles.last_name,
+    coalesce(profiles.display_name, ''),
+    profiles.bio,
+    coalesce(profiles.avatar_key, ''),
+    profiles.preferred_language,
+    profiles.watch_region,
     profiles.created_at,
     accounts.id_account,
     accounts.email,
-    accounts.password
+    accounts.password,
+    accounts.role,
+    accounts.disabled_at,
+    accounts.session_version,
+    accounts.deletion_scheduled_for
   from profiles
   join accounts on profiles.id_account = accounts.id_account
   where profiles.id_profile = $1`
@@ -56,7 +76,7 @@ func (p *ProfileRepository) GetProfileByID(ctx context.Context, profileID int) (
 	res := GetProfileResult{}
 
 	err := p.db.QueryRow(ctx, getProfileByIDQuery, profileID).
-		Scan(&res.ID, &res.FirstName, &res.LastName, &res.CreatedAt, &res.AccountID, &res.AccountEmail, &res.AccountPassword)
+		Scan(&res.ID, &res.FirstName, &res.LastName, &res.DisplayName, &res.Bio, &res.AvatarKey, &res.PreferredLanguage, &res.WatchRegion, &res.CreatedAt, &res.AccountID, &res.AccountEmail, &res.AccountPassword, &res.AccountRole, &res.AccountDisabledAt, &res.AccountSessionVersion, &res.DeletionScheduledFor)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return GetProfileResult{}, ErrNoRecord
@@ -73,10 +93,19 @@ const getProfileByEmailQuery = `
     profiles.id_profile,
     profiles.first_name,
     profiles.last_name,
+    coalesce(profiles.display_name, ''),
+    profiles.bio,
+    coalesce(profiles.avatar_key, ''),
+    profiles.preferred_language,
+    profiles.watch_region,
     profiles.created_at,
     accounts.id_account,
     accounts.email,
-  accounts.password
+    accounts.password,
+    accounts.role,
+    accounts.disabled_at,
+    accounts.session_version,
+    accounts.deletion_scheduled_for
   from profiles
   join accounts on profiles.id_account = accounts.id_account
   where accounts.email = $1`
@@ -86,7 +115,7 @@ func (p *ProfileRepository) GetProfileByEmail(ctx context.Context, email string)
 	defer span.End()
 	res := GetProfileResult{}
 	err := p.db.QueryRow(ctx, getProfileByEmailQuery, email).
-		Scan(&res.ID, &res.FirstName, &res.LastName, &res.CreatedAt, &res.AccountID, &res.AccountEmail, &res.AccountPassword)
+		Scan(&res.ID, &res.FirstName, &res.LastName, &res.DisplayName, &res.Bio, &res.AvatarKey, &res.PreferredLanguage, &res.WatchRegion, &res.CreatedAt, &res.AccountID, &res.AccountEmail, &res.AccountPassword, &res.AccountRole, &res.AccountDisabledAt, &res.AccountSessionVersion, &res.DeletionScheduledFor)
 	if err != nil {
 		if err == pgx.ErrNoRows {
 			return GetProfileResult{}, ErrNoRecord
@@ -104,11 +133,13 @@ type GetProfileStatsResult struct {
 
 const getNumPartiesForProfileQuery = `select count(*) from party_members where id_member = $1;`
 
+// watch time counts every viewing the profile was there for, rewatches included, but a movie is only counted once
 const getTotalWatchTimeQuery = `
-  select coalesce(sum(movies.runtime), 0), count(movies.*) from movies
+  select coalesce(sum(movies.runtime), 0), count(distinct movies.id_movie) from movies
   join party_movies on party_movies.id_movie = movies.id_movie
-  join party_members on party_members.id_party = party_movies.id_party
-  where party_members.id_member = $1 AND party_movies.watch_status = 'watched';
+  join party_movie_viewings on party_movie_viewings.id_party_movie = party_movies.id
+  join party_movie_attendance on party_movie_attendance.id_viewing = party_movie_viewings.id_viewing
+  where party_movie_attendance.id_profile = $1 AND party_movie_attendance.attended;
 `
 
 func (p *ProfileRepository) GetProfileStats(ctx context.Context, logger *slog.Logger, profileID int) (GetProfileStatsResult, error) {
@@ -211,9 +242,17 @@ type AccountUpdateAttrs struct {
 }
 
 type ProfileUpdateAttrs struct {
-	ID        int
-	FirstName string
-	LastName  string
+	ID                int
+	FirstName         string
+	LastName          string
+	DisplayName       string
+	Bio               string
+	PreferredLanguage string
+	WatchRegion       string
+	// Subscriptions replaces the stored subscriptions when it isn't nil
+	Subscriptions []int
+	// FavoriteGenres replaces the stored favorite genres when it isn't nil
+	FavoriteGenres []int
 }
 
 func (p *ProfileRepository) UpdateProfile(ctx context.Context, accountAttrs AccountUpdateAttrs, profileAttrs ProfileUpdateAttrs) error {
@@ -236,6 +275,20 @@ func (p *ProfileRepository) UpdateProfile(ctx context.Context, accountAttrs Acco
 		return err
 	}
 
+	if profileAttrs.Subscriptions != nil {
+		err = replaceSubscriptions(ctx, txn, profileAttrs.ID, profileAttrs.Subscriptions)
+		if err != nil {
+			return err
+		}
+	}
+
+	if profileAttrs.FavoriteGenres != nil {
+		err = replaceFavoriteGenres(ctx, txn, profileAttrs.ID, profileAttrs.FavoriteGenres)
+		if err != nil {
+			return err
+		}
+	}
+
 	err = txn.Commit(ctx)
 	if err != nil {
 		return err
@@ -276,13 +329,304 @@ func handleUniqueConstraintForEmail(err error) error {
 	return err
 }
 
+// an empty display name is stored as null so the profile goes back to showing the legal name
+const updateProfileQuery = `
+  update profiles
+  set first_name = $1,
+    last_name = $2,
+    preferred_language = coalesce(nullif($3, ''), preferred_language),
+    watch_region = coalesce(nullif($4, ''), watch_region),
+    display_name = nullif($5, ''),
+    bio = $6
+  where id_profile = $7`
+
 func updateProfile(ctx context.Context, txn pgx.Tx, attrs ProfileUpdateAttrs) error {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.updateProfile")
 	defer span.End()
-	_, err := txn.Exec(ctx, `update profiles set first_name = $1, last_name = $2 where id_profile = $3`, attrs.FirstName, attrs.LastName, attrs.ID)
+	_, err := txn.Exec(ctx, updateProfileQuery, attrs.FirstName, attrs.LastName, attrs.PreferredLanguage, attrs.WatchRegion, attrs.DisplayName, attrs.Bio, attrs.ID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func replaceSubscriptions(ctx context.Context, txn pgx.Tx, idProfile int, providerIDs []int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.replaceSubscriptions")
+	defer span.End()
+	_, err := txn.Exec(ctx, `delete from profile_subscriptions where id_profile = $1`, idProfile)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Exec(ctx, `insert into profile_subscriptions (id_profile, id_provider) select $1, unnest($2::int[]) on conflict do nothing`, idProfile, providerIDs)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const getSubscriptionsQuery = `select id_provider from profile_subscriptions where id_profile = $1 order by id_provider`
+
+func (p *ProfileRepository) GetSubscriptions(ctx context.Context, profileID int, assignFn func(int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.GetSubscriptions")
+	defer span.End()
+	rows, err := p.db.Query(ctx, getSubscriptionsQuery, profileID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var idProvider int
+		err := rows.Scan(&idProvider)
+		if err != nil {
+			return err
+		}
+		assignFn(idProvider)
+	}
+
+	return rows.Err()
+}
+
+func replaceFavoriteGenres(ctx context.Context, txn pgx.Tx, idProfile int, genreIDs []int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.replaceFavoriteGenres")
+	defer span.End()
+	_, err := txn.Exec(ctx, `delete from profile_favorite_genres where id_profile = $1`, idProfile)
+	if err != nil {
+		return err
+	}
+
+	// the genres are checked before updating, any that have gone since can't be referenced so they're skipped rather
+	// than failing the whole update
+	_, err = txn.Exec(ctx, `insert into profile_favorite_genres (id_profile, id_genre) select $1, id_genre from genres where id_genre = any($2::int[]) on conflict do nothing`, idProfile, genreIDs)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const getFavoriteGenresQuery = `select id_genre from profile_favorite_genres where id_profile = $1 order by id_genre`
+
+func (p *ProfileRepository) GetFavoriteGenres(ctx context.Context, profileID int, assignFn func(int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.GetFavoriteGenres")
+	defer span.End()
+	rows, err := p.db.Query(ctx, getFavoriteGenresQuery, profileID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var idGenre int
+		err := rows.Scan(&idGenre)
+		if err != nil {
+			return err
+		}
+		assignFn(idGenre)
+	}
+
+	return rows.Err()
+}
+
+const countGenresQuery = `select count(*) from genres where id_genre = any($1::int[])`
+
+// GenresExist is whether every one of the genre ids is a genre
+func (p *ProfileRepository) GenresExist(ctx context.Context, genreIDs []int) (bool, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.GenresExist")
+	defer span.End()
+
+	unique := make(map[int]struct{}, len(genreIDs))
+	for _, idGenre := range genreIDs {
+		unique[idGenre] = struct{}{}
+	}
+
+	var count int
+	err := p.db.QueryRow(ctx, countGenresQuery, genreIDs).Scan(&count)
+	if err != nil {
+		return false, err
+	}
+
+	return count == len(unique), nil
+}
+
+const setAvatarKeyQuery = `update profiles set avatar_key = nullif($2, '') where id_profile = $1`
+
+// SetAvatarKey points the profile at its avatar in the blob store, an empty key removes it
+func (p *ProfileRepository) SetAvatarKey(ctx context.Context, profileID int, key string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.SetAvatarKey")
+	defer span.End()
+	tag, err := p.db.Exec(ctx, setAvatarKeyQuery, profileID, key)
 	if err != nil {
 		return err
 	}
 
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const scheduleAccountDeletionQuery = `
+  update accounts
+  set deletion_requested_at = clock_timestamp() at time zone 'UTC',
+    deletion_scheduled_for = $2
+  where id_account = $1`
+
+// ScheduleAccountDeletion marks the account to be deleted once scheduledFor has passed
+func (p *ProfileRepository) ScheduleAccountDeletion(ctx context.Context, accountID int, scheduledFor time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.ScheduleAccountDeletion")
+	defer span.End()
+	tag, err := p.db.Exec(ctx, scheduleAccountDeletionQuery, accountID, scheduledFor)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const cancelAccountDeletionQuery = `
+  update accounts
+  set deletion_requested_at = null,
+    deletion_scheduled_for = null
+  where id_account = $1 and deletion_scheduled_for is not null`
+
+// CancelAccountDeletion stops the account from being deleted, ErrNoRecord is returned when it wasn't going to be
+func (p *ProfileRepository) CancelAccountDeletion(ctx context.Context, accountID int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.CancelAccountDeletion")
+	defer span.End()
+	tag, err := p.db.Exec(ctx, cancelAccountDeletionQuery, accountID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
 	return nil
 }
+
+type AccountDeletionResult struct {
+	AccountID    int
+	AccountEmail string
+	ProfileID    int
+	AvatarKey    string
+}
+
+const getAccountsDueForDeletionQuery = `
+  select
+    accounts.id_account,
+    accounts.email,
+    profiles.id_profile,
+    coalesce(profiles.avatar_key, '')
+  from accounts
+  join profiles on profiles.id_account = accounts.id_account
+  where accounts.deletion_scheduled_for <= $1
+  order by accounts.deletion_scheduled_for`
+
+// GetAccountsDueForDeletion returns the accounts whose grace period had ended by now
+func (p *ProfileRepository) GetAccountsDueForDeletion(ctx context.Context, now time.Time, assignFn func(AccountDeletionResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.GetAccountsDueForDeletion")
+	defer span.End()
+	rows, err := p.db.Query(ctx, getAccountsDueForDeletionQuery, now)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res AccountDeletionResult
+		err := rows.Scan(&res.AccountID, &res.AccountEmail, &res.ProfileID, &res.AvatarKey)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+// the account is only deleted when it's still due so a deletion cancelled at the last moment is kept
+const deleteDueAccountQuery = `delete from accounts where id_account = $1 and deletion_scheduled_for <= $2`
+
+// the profile is kept so what they left in their parties still belongs to someone, but nothing about who they were is
+const anonymizeProfileQuery = `
+  update profiles
+  set first_name = $2,
+    last_name = $3,
+    display_name = null,
+    bio = '',
+    avatar_key = null,
+    id_account = null,
+    deleted_at = clock_timestamp() at time zone 'UTC'
+  where id_profile = $1`
+
+type DeleteAccountAttrs struct {
+	AccountID int
+	ProfileID int
+	// FirstName and LastName are what the profile is renamed to
+	FirstName string
+	LastName  string
+	// Now is when the account is being deleted, it isn't deleted if its grace period hasn't ended by then
+	Now time.Time
+}
+
+// DeleteAccount deletes the account and strips its profile down to a name, ErrNoRecord is returned when the account
+// isn't due to be deleted
+func (p *ProfileRepository) DeleteAccount(ctx context.Context, attrs DeleteAccountAttrs) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.DeleteAccount")
+	defer span.End()
+	txn, err := p.db.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return err
+	}
+
+	defer txn.Rollback(ctx)
+
+	tag, err := txn.Exec(ctx, deleteDueAccountQuery, attrs.AccountID, attrs.Now)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	_, err = txn.Exec(ctx, anonymizeProfileQuery, attrs.ProfileID, attrs.FirstName, attrs.LastName)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Exec(ctx, `delete from profile_subscriptions where id_profile = $1`, attrs.ProfileID)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Exec(ctx, `delete from profile_favorite_genres where id_profile = $1`, attrs.ProfileID)
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit(ctx)
+}
+
+const recordFailedLoginQuery = `insert into failed_logins (email, ip_address, reason) values ($1, $2, $3)`
+
+// RecordFailedLogin keeps a login that didn't work so admins can see it, reason is one of the failed_login_reason values
+func (p *ProfileRepository) RecordFailedLogin(ctx context.Context, email, ipAddress, reason string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "profileRepository.RecordFailedLogin")
+	defer span.End()
+	_, err := p.db.Exec(ctx, recordFailedLoginQuery, email, ipAddress, reason)
+	return err
+}
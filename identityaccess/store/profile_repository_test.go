@@ -320,6 +320,177 @@ func TestUpdateProfile(t *testing.T) {
 	}
 }
 
+func TestProfileDisplayDetails(t *testing.T) {
+	ctx := context.Background()
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_display_details_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+
+	t.Cleanup(func() { testhelpers.CleanupAndResetDB(ctx, t, connPool, schemaName) })
+
+	profileID, accountID, _ := seedProfile(ctx, t, connPool, "Ada", "Lovelace", "ada@email.com", []byte("password"))
+
+	_, err := connPool.Exec(ctx, "INSERT INTO genres (id_genre) VALUES (18), (27), (35)")
+	testhelpers.Ok(t, err, "failed to insert genres")
+
+	repo := store.NewProfileRepository(connPool)
+
+	getFavoriteGenres := func() []int {
+		t.Helper()
+		genres := make([]int, 0)
+		err := repo.GetFavoriteGenres(ctx, profileID, func(idGenre int) {
+			genres = append(genres, idGenre)
+		})
+		testhelpers.Ok(t, err, "failed to get favorite genres")
+		return genres
+	}
+
+	exist, err := repo.GenresExist(ctx, []int{27, 18, 27})
+	testhelpers.Ok(t, err, "failed to check genres")
+	testhelpers.Assert(t, exist, "expected stored genres to exist")
+
+	exist, err = repo.GenresExist(ctx, []int{27, 9999})
+	testhelpers.Ok(t, err, "failed to check genres")
+	testhelpers.Assert(t, !exist, "expected a genre that isn't stored not to exist")
+
+	err = repo.UpdateProfile(ctx, store.AccountUpdateAttrs{ID: accountID, Email: "ada@email.com"}, store.ProfileUpdateAttrs{
+		ID:             profileID,
+		FirstName:      "Ada",
+		LastName:       "Lovelace",
+		DisplayName:    "Countess",
+		Bio:            "Mostly here for the noir",
+		FavoriteGenres: []int{27, 18, 9999},
+	})
+	testhelpers.Ok(t, err, "failed to update profile")
+
+	got, err := repo.GetProfileByID(ctx, profileID)
+	testhelpers.Ok(t, err, "failed to get profile")
+	testhelpers.Equals(t, "Countess", got.DisplayName)
+	testhelpers.Equals(t, "Mostly here for the noir", got.Bio)
+	// genres that aren't stored are skipped
+	testhelpers.Equals(t, []int{18, 27}, getFavoriteGenres())
+
+	// leaving the favorite genres off the update keeps them, clearing the display name goes back to the legal name
+	err = repo.UpdateProfile(ctx, store.AccountUpdateAttrs{ID: accountID, Email: "ada@email.com"}, store.ProfileUpdateAttrs{
+		ID:        profileID,
+		FirstName: "Ada",
+		LastName:  "Lovelace",
+	})
+	testhelpers.Ok(t, err, "failed to update profile")
+
+	got, err = repo.GetProfileByID(ctx, profileID)
+	testhelpers.Ok(t, err, "failed to get profile")
+	testhelpers.Equals(t, "", got.DisplayName)
+	testhelpers.Equals(t, []int{18, 27}, getFavoriteGenres())
+
+	err = repo.SetAvatarKey(ctx, profileID, "avatars/1-0123456789abcdef.jpg")
+	testhelpers.Ok(t, err, "failed to set avatar key")
+
+	got, err = repo.GetProfileByID(ctx, profileID)
+	testhelpers.Ok(t, err, "failed to get profile")
+	testhelpers.Equals(t, "avatars/1-0123456789abcdef.jpg", got.AvatarKey)
+
+	err = repo.SetAvatarKey(ctx, profileID, "")
+	testhelpers.Ok(t, err, "failed to remove avatar key")
+
+	got, err = repo.GetProfileByID(ctx, profileID)
+	testhelpers.Ok(t, err, "failed to get profile")
+	testhelpers.Equals(t, "", got.AvatarKey)
+
+	err = repo.SetAvatarKey(ctx, 0, "avatars/0-0123456789abcdef.jpg")
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected ErrNoRecord, got %v", err)
+}
+
+func TestAccountDeletion(t *testing.T) {
+	ctx := context.Background()
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_account_deletion_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+
+	t.Cleanup(func() { testhelpers.CleanupAndResetDB(ctx, t, connPool, schemaName) })
+
+	profileID, accountID, _ := seedProfile(ctx, t, connPool, "Ada", "Lovelace", "ada@email.com", []byte("password"))
+	stayingProfileID, _, _ := seedProfile(ctx, t, connPool, "Grace", "Hopper", "grace@email.com", []byte("password"))
+
+	repo := store.NewProfileRepository(connPool)
+
+	getDue := func(now time.Time) []store.AccountDeletionResult {
+		t.Helper()
+		due := make([]store.AccountDeletionResult, 0)
+		err := repo.GetAccountsDueForDeletion(ctx, now, func(res store.AccountDeletionResult) {
+			due = append(due, res)
+		})
+		testhelpers.Ok(t, err, "failed to get accounts due for deletion")
+		return due
+	}
+
+	err := repo.CancelAccountDeletion(ctx, accountID)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected ErrNoRecord, got %v", err)
+
+	scheduledFor := time.Now().UTC().Add(time.Hour).Truncate(time.Microsecond)
+	err = repo.ScheduleAccountDeletion(ctx, accountID, scheduledFor)
+	testhelpers.Ok(t, err, "failed to schedule deletion")
+
+	got, err := repo.GetProfileByID(ctx, profileID)
+	testhelpers.Ok(t, err, "failed to get profile")
+	testhelpers.Assert(t, got.DeletionScheduledFor != nil && got.DeletionScheduledFor.Equal(scheduledFor), "expected deletion scheduled for %v, got %v", scheduledFor, got.DeletionScheduledFor)
+
+	// nothing is due until the grace period is over
+	testhelpers.Equals(t, 0, len(getDue(time.Now())))
+
+	err = repo.DeleteAccount(ctx, store.DeleteAccountAttrs{AccountID: accountID, ProfileID: profileID, FirstName: "Former", LastName: "member", Now: time.Now()})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected ErrNoRecord, got %v", err)
+
+	err = repo.CancelAccountDeletion(ctx, accountID)
+	testhelpers.Ok(t, err, "failed to cancel deletion")
+
+	got, err = repo.GetProfileByID(ctx, profileID)
+	testhelpers.Ok(t, err, "failed to get profile")
+	testhelpers.Assert(t, got.DeletionScheduledFor == nil, "expected deletion to be cancelled, got %v", got.DeletionScheduledFor)
+
+	err = repo.ScheduleAccountDeletion(ctx, accountID, scheduledFor)
+	testhelpers.Ok(t, err, "failed to schedule deletion")
+
+	err = repo.SetAvatarKey(ctx, profileID, "avatars/1-0123456789abcdef.jpg")
+	testhelpers.Ok(t, err, "failed to set avatar key")
+
+	due := getDue(scheduledFor.Add(time.Minute))
+	testhelpers.Equals(t, []store.AccountDeletionResult{
+		{AccountID: accountID, AccountEmail: "ada@email.com", ProfileID: profileID, AvatarKey: "avatars/1-0123456789abcdef.jpg"},
+	}, due)
+
+	err = repo.DeleteAccount(ctx, store.DeleteAccountAttrs{AccountID: accountID, ProfileID: profileID, FirstName: "Former", LastName: "member", Now: scheduledFor.Add(time.Minute)})
+	testhelpers.Ok(t, err, "failed to delete account")
+
+	exists, err := repo.AccountExists(ctx, accountID)
+	testhelpers.Ok(t, err, "failed to check account exists")
+	testhelpers.Assert(t, !exists, "expected account to be deleted")
+
+	// the profile stays behind with nothing about who they were
+	var (
+		firstName, lastName, bio string
+		displayName, avatarKey   *string
+		idAccount                *int
+		deletedAt                *time.Time
+	)
+	err = connPool.QueryRow(ctx, "SELECT first_name, last_name, display_name, bio, avatar_key, id_account, deleted_at FROM profiles WHERE id_profile = $1", profileID).
+		Scan(&firstName, &lastName, &displayName, &bio, &avatarKey, &idAccount, &deletedAt)
+	testhelpers.Ok(t, err, "failed to get anonymized profile")
+	testhelpers.Equals(t, "Former", firstName)
+	testhelpers.Equals(t, "member", lastName)
+	testhelpers.Equals(t, "", bio)
+	testhelpers.Assert(t, displayName == nil && avatarKey == nil && idAccount == nil, "expected profile to be anonymized")
+	testhelpers.Assert(t, deletedAt != nil, "expected profile to be marked deleted")
+
+	// the email can be used to sign up again
+	_, err = repo.CreateProfile(ctx, "ada@email.com", "Ada", "Lovelace", []byte("password"))
+	testhelpers.Ok(t, err, "failed to sign up with a deleted account's email")
+
+	_, err = repo.GetProfileByID(ctx, stayingProfileID)
+	testhelpers.Ok(t, err, "expected other profiles to be left alone")
+	testhelpers.Equals(t, 0, len(getDue(scheduledFor.Add(time.Minute))))
+}
+
 func TestGetProfileStats(t *testing.T) {
 	// TODO: Add tests
 }
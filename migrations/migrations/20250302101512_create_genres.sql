@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+create table genres (
+    id_genre INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY(id_genre)
+);
+
+create table genre_names (
+    id_genre INT NOT NULL,
+    language VARCHAR(10) NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY(id_genre, language),
+    CONSTRAINT fk_genre_names_genres FOREIGN KEY(id_genre) REFERENCES genres(id_genre)
+);
+
+create table movie_genres (
+    id_movie INT NOT NULL,
+    id_genre INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_movie, id_genre),
+    CONSTRAINT fk_movie_genres_movies FOREIGN KEY(id_movie) REFERENCES movies(id_movie),
+    CONSTRAINT fk_movie_genres_genres FOREIGN KEY(id_genre) REFERENCES genres(id_genre)
+);
+
+CREATE INDEX idx_movie_genres_id_genre ON movie_genres(id_genre);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+drop table if exists movie_genres;
+drop table if exists genre_names;
+drop table if exists genres;
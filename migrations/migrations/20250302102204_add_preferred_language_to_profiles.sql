@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TABLE profiles ADD COLUMN preferred_language VARCHAR(10) NOT NULL DEFAULT 'en';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+ALTER TABLE profiles DROP COLUMN preferred_language;
+-- +goose StatementEnd
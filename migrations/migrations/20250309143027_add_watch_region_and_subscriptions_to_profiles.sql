@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE profiles ADD COLUMN watch_region VARCHAR(2) NOT NULL DEFAULT 'US';
+
+create table profile_subscriptions (
+    id_profile INT NOT NULL,
+    id_provider INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_profile, id_provider),
+    CONSTRAINT fk_profile_subscriptions_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_profile_subscriptions_id_provider ON profile_subscriptions(id_provider);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+drop table if exists profile_subscriptions;
+ALTER TABLE profiles DROP COLUMN watch_region;
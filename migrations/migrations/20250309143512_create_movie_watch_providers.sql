@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- null until the providers have been fetched from TMDB, used to know when to refetch them
+ALTER TABLE movies ADD COLUMN watch_providers_checked_at TIMESTAMPTZ;
+
+create table movie_watch_providers (
+    id_movie INT NOT NULL,
+    region VARCHAR(2) NOT NULL,
+    id_provider INT NOT NULL,
+    monetization_type VARCHAR(10) NOT NULL,
+    provider_name VARCHAR(255) NOT NULL,
+    logo_path VARCHAR(255) NOT NULL DEFAULT '',
+    display_priority INT NOT NULL DEFAULT 0,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_movie, region, id_provider, monetization_type),
+    CONSTRAINT fk_movie_watch_providers_movies FOREIGN KEY(id_movie) REFERENCES movies(id_movie) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_movie_watch_providers_region_id_provider ON movie_watch_providers(region, id_provider);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+drop table if exists movie_watch_providers;
+ALTER TABLE movies DROP COLUMN watch_providers_checked_at;
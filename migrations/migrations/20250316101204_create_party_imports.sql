@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+CREATE TYPE party_import_status AS ENUM ('previewing', 'queued', 'running', 'completed', 'failed');
+CREATE TYPE import_match_status AS ENUM ('matched', 'ambiguous', 'not_found', 'failed');
+CREATE TYPE import_row_status AS ENUM ('pending', 'skipped', 'added', 'already_added', 'failed');
+
+create table party_imports (
+    id_party_import INT GENERATED ALWAYS AS IDENTITY,
+    id_party INT NOT NULL,
+    id_profile INT NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    filename VARCHAR(255) NOT NULL DEFAULT '',
+    status party_import_status NOT NULL DEFAULT 'previewing',
+    total_rows INT NOT NULL DEFAULT 0,
+    processed_rows INT NOT NULL DEFAULT 0,
+    error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ,
+    -- bumped as rows are processed so an import left running by a crashed worker can be found and picked back up
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY(id_party_import),
+    CONSTRAINT fk_party_imports_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE,
+    CONSTRAINT fk_party_imports_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_party_imports_id_party ON party_imports(id_party);
+CREATE INDEX idx_party_imports_status ON party_imports(status) WHERE status IN ('queued', 'running');
+
+create table party_import_rows (
+    id_party_import INT NOT NULL,
+    line INT NOT NULL,
+    title VARCHAR(255) NOT NULL DEFAULT '',
+    year INT,
+    imdb_id VARCHAR(20) NOT NULL DEFAULT '',
+    match_status import_match_status NOT NULL,
+    -- the movies to choose between when a row is ambiguous
+    candidates JSONB NOT NULL DEFAULT '[]',
+    -- the matched movie, or the one picked from the candidates when the import was confirmed
+    tmdb_id INT,
+    status import_row_status NOT NULL DEFAULT 'pending',
+    error TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY(id_party_import, line),
+    CONSTRAINT fk_party_import_rows_party_imports FOREIGN KEY(id_party_import) REFERENCES party_imports(id_party_import) ON DELETE CASCADE
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+drop table if exists party_import_rows;
+drop table if exists party_imports;
+DROP TYPE if exists import_row_status;
+DROP TYPE if exists import_match_status;
+DROP TYPE if exists party_import_status;
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+create table party_movie_ratings (
+    id_party INT NOT NULL,
+    id_movie INT NOT NULL,
+    id_profile INT NOT NULL,
+    rating SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY(id_party, id_movie, id_profile),
+    CONSTRAINT fk_party_movie_ratings_party_movies FOREIGN KEY(id_party, id_movie) REFERENCES party_movies(id_party, id_movie) ON DELETE CASCADE,
+    CONSTRAINT fk_party_movie_ratings_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_party_movie_ratings_id_profile ON party_movie_ratings(id_profile);
+CREATE INDEX idx_party_movies_watch_date ON party_movies(id_party, watch_date) WHERE watch_status = 'watched';
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP INDEX IF EXISTS idx_party_movies_watch_date;
+DROP TABLE IF EXISTS party_movie_ratings;
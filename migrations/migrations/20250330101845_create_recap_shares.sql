@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+create table recap_shares (
+    token VARCHAR(32) NOT NULL,
+    id_profile INT,
+    id_party INT,
+    year INT NOT NULL,
+    id_shared_by INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(token),
+    CONSTRAINT recap_shares_one_subject CHECK ((id_profile IS NULL) != (id_party IS NULL)),
+    CONSTRAINT fk_recap_shares_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE,
+    CONSTRAINT fk_recap_shares_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE,
+    CONSTRAINT fk_recap_shares_shared_by FOREIGN KEY(id_shared_by) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+CREATE UNIQUE INDEX idx_recap_shares_profile_year ON recap_shares(id_profile, year) WHERE id_profile IS NOT NULL;
+CREATE UNIQUE INDEX idx_recap_shares_party_year ON recap_shares(id_party, year) WHERE id_party IS NOT NULL;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS recap_shares;
@@ -0,0 +1,56 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE rsvp_response AS ENUM ('going', 'maybe', 'not_going');
+-- +goose StatementEnd
+
+create table movie_nights (
+    id_movie_night SERIAL,
+    id_party INT NOT NULL,
+    id_movie INT,
+    id_created_by INT NOT NULL,
+    starts_at TIMESTAMPTZ NOT NULL,
+    time_zone TEXT NOT NULL DEFAULT 'UTC',
+    location TEXT NOT NULL DEFAULT '',
+    stream_url TEXT NOT NULL DEFAULT '',
+    reminder_minutes INT NOT NULL DEFAULT 60 CHECK (reminder_minutes >= 0),
+    sequence INT NOT NULL DEFAULT 0,
+    completed_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_movie_night),
+    CONSTRAINT fk_movie_nights_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE,
+    CONSTRAINT fk_movie_nights_party_movies FOREIGN KEY(id_party, id_movie) REFERENCES party_movies(id_party, id_movie) ON DELETE SET NULL (id_movie),
+    CONSTRAINT fk_movie_nights_profiles FOREIGN KEY(id_created_by) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_movie_nights_id_party_starts_at ON movie_nights(id_party, starts_at);
+
+create table movie_night_rsvps (
+    id_movie_night INT NOT NULL,
+    id_profile INT NOT NULL,
+    response rsvp_response NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_movie_night, id_profile),
+    CONSTRAINT fk_movie_night_rsvps_movie_nights FOREIGN KEY(id_movie_night) REFERENCES movie_nights(id_movie_night) ON DELETE CASCADE,
+    CONSTRAINT fk_movie_night_rsvps_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+create table calendar_feeds (
+    id_profile INT NOT NULL,
+    token VARCHAR(32) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_profile),
+    CONSTRAINT unique_calendar_feeds_token UNIQUE(token),
+    CONSTRAINT fk_calendar_feeds_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS calendar_feeds;
+DROP TABLE IF EXISTS movie_night_rsvps;
+DROP TABLE IF EXISTS movie_nights;
+DROP TYPE IF EXISTS rsvp_response;
@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+create table party_movie_attendance (
+    id_party_movie INT NOT NULL,
+    id_profile INT NOT NULL,
+    attended BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY(id_party_movie, id_profile),
+    CONSTRAINT fk_party_movie_attendance_party_movies FOREIGN KEY(id_party_movie) REFERENCES party_movies(id) ON DELETE CASCADE,
+    CONSTRAINT fk_party_movie_attendance_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_party_movie_attendance_id_profile ON party_movie_attendance(id_profile) WHERE attended;
+
+-- movies already watched were watched by whoever was in the party on the day, or by everyone in it now when the day
+-- wasn't recorded
+INSERT INTO party_movie_attendance (id_party_movie, id_profile)
+SELECT party_movies.id, party_members.id_member
+FROM party_movies
+JOIN party_members ON party_members.id_party = party_movies.id_party
+WHERE party_movies.watch_status = 'watched'
+  AND (party_movies.watch_date IS NULL OR party_members.created_at <= party_movies.watch_date);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS party_movie_attendance;
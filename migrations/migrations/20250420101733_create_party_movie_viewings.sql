@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+create table party_movie_viewings (
+    id_viewing INT GENERATED ALWAYS AS IDENTITY,
+    id_party_movie INT NOT NULL,
+    watch_date TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_viewing),
+    CONSTRAINT fk_party_movie_viewings_party_movies FOREIGN KEY(id_party_movie) REFERENCES party_movies(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_party_movie_viewings_id_party_movie ON party_movie_viewings(id_party_movie, watch_date);
+
+-- every movie watched so far has been watched once
+INSERT INTO party_movie_viewings (id_party_movie, watch_date)
+SELECT party_movies.id, coalesce(party_movies.watch_date, party_movies.created_at)
+FROM party_movies
+WHERE party_movies.watch_status = 'watched';
+
+-- attendance is for a viewing rather than the movie so each rewatch has its own
+ALTER TABLE party_movie_attendance ADD COLUMN id_viewing INT;
+
+UPDATE party_movie_attendance
+SET id_viewing = party_movie_viewings.id_viewing
+FROM party_movie_viewings
+WHERE party_movie_viewings.id_party_movie = party_movie_attendance.id_party_movie;
+
+DELETE FROM party_movie_attendance WHERE id_viewing IS NULL;
+
+ALTER TABLE party_movie_attendance DROP CONSTRAINT party_movie_attendance_pkey;
+ALTER TABLE party_movie_attendance DROP COLUMN id_party_movie;
+ALTER TABLE party_movie_attendance ALTER COLUMN id_viewing SET NOT NULL;
+ALTER TABLE party_movie_attendance ADD PRIMARY KEY (id_viewing, id_profile);
+ALTER TABLE party_movie_attendance ADD CONSTRAINT fk_party_movie_attendance_viewings FOREIGN KEY(id_viewing) REFERENCES party_movie_viewings(id_viewing) ON DELETE CASCADE;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+-- only the attendance for the latest viewing of each movie can be kept
+DELETE FROM party_movie_attendance
+USING party_movie_viewings
+WHERE party_movie_viewings.id_viewing = party_movie_attendance.id_viewing
+  AND EXISTS (
+    SELECT 1 FROM party_movie_viewings later
+    WHERE later.id_party_movie = party_movie_viewings.id_party_movie AND later.id_viewing > party_movie_viewings.id_viewing
+  );
+
+ALTER TABLE party_movie_attendance ADD COLUMN id_party_movie INT;
+
+UPDATE party_movie_attendance
+SET id_party_movie = party_movie_viewings.id_party_movie
+FROM party_movie_viewings
+WHERE party_movie_viewings.id_viewing = party_movie_attendance.id_viewing;
+
+ALTER TABLE party_movie_attendance DROP CONSTRAINT party_movie_attendance_pkey;
+ALTER TABLE party_movie_attendance DROP COLUMN id_viewing;
+ALTER TABLE party_movie_attendance ALTER COLUMN id_party_movie SET NOT NULL;
+ALTER TABLE party_movie_attendance ADD PRIMARY KEY (id_party_movie, id_profile);
+ALTER TABLE party_movie_attendance ADD CONSTRAINT fk_party_movie_attendance_party_movies FOREIGN KEY(id_party_movie) REFERENCES party_movies(id) ON DELETE CASCADE;
+
+DROP TABLE IF EXISTS party_movie_viewings;
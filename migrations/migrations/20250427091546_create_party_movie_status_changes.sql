@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE party_movie_status_change AS ENUM ('select', 'unselect', 'watch', 'unwatch', 'edit_watch_date');
+-- +goose StatementEnd
+
+-- the audit log of who changed the status or watch date of a party's movies so mistakes can be tracked down and undone
+create table party_movie_status_changes (
+    id_status_change INT GENERATED ALWAYS AS IDENTITY,
+    id_party INT NOT NULL,
+    id_movie INT NOT NULL,
+    id_changed_by INT,
+    change party_movie_status_change NOT NULL,
+    from_status watch_status NOT NULL,
+    to_status watch_status NOT NULL,
+    from_watch_date TIMESTAMPTZ,
+    to_watch_date TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_status_change),
+    CONSTRAINT fk_party_movie_status_changes_party_movies FOREIGN KEY(id_party, id_movie) REFERENCES party_movies(id_party, id_movie) ON DELETE CASCADE,
+    CONSTRAINT fk_party_movie_status_changes_profiles FOREIGN KEY(id_changed_by) REFERENCES profiles(id_profile) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_party_movie_status_changes_id_party_created_at ON party_movie_status_changes(id_party, created_at);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS party_movie_status_changes;
+DROP TYPE IF EXISTS party_movie_status_change;
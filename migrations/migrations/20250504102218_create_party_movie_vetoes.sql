@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- a vetoed movie can't be picked for the party until every veto on it is lifted
+create table party_movie_vetoes (
+    id_party INT NOT NULL,
+    id_movie INT NOT NULL,
+    id_profile INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_party, id_movie, id_profile),
+    CONSTRAINT fk_party_movie_vetoes_party_movies FOREIGN KEY(id_party, id_movie) REFERENCES party_movies(id_party, id_movie) ON DELETE CASCADE,
+    CONSTRAINT fk_party_movie_vetoes_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS party_movie_vetoes;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE party_movie_flag AS ENUM ('seen_it', 'must_watch', 'not_interested');
+-- +goose StatementEnd
+
+-- how each member feels about watching the party's movies, the flags of the members watching decide what's picked
+create table party_movie_flags (
+    id_party INT NOT NULL,
+    id_movie INT NOT NULL,
+    id_profile INT NOT NULL,
+    flag party_movie_flag NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_party, id_movie, id_profile),
+    CONSTRAINT fk_party_movie_flags_party_movies FOREIGN KEY(id_party, id_movie) REFERENCES party_movies(id_party, id_movie) ON DELETE CASCADE,
+    CONSTRAINT fk_party_movie_flags_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS party_movie_flags;
+DROP TYPE IF EXISTS party_movie_flag;
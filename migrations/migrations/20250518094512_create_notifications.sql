@@ -0,0 +1,50 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE notification_type AS ENUM ('party_invite', 'invite_accepted', 'movie_selected', 'movie_night_reminder');
+-- +goose StatementEnd
+
+-- what's happened that a watcher should know about, id_actor is who did it and the rest is what it's about
+create table notifications (
+    id_notification INT GENERATED ALWAYS AS IDENTITY,
+    id_profile INT NOT NULL,
+    type notification_type NOT NULL,
+    id_actor INT,
+    id_party INT,
+    id_movie INT,
+    id_movie_night INT,
+    read_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_notification),
+    CONSTRAINT fk_notifications_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE,
+    CONSTRAINT fk_notifications_actors FOREIGN KEY(id_actor) REFERENCES profiles(id_profile) ON DELETE SET NULL,
+    CONSTRAINT fk_notifications_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE,
+    CONSTRAINT fk_notifications_movies FOREIGN KEY(id_movie) REFERENCES movies(id_movie) ON DELETE CASCADE,
+    CONSTRAINT fk_notifications_movie_nights FOREIGN KEY(id_movie_night) REFERENCES movie_nights(id_movie_night) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_notifications_id_profile_created_at ON notifications(id_profile, created_at);
+CREATE INDEX idx_notifications_unread ON notifications(id_profile) WHERE read_at IS NULL;
+
+-- a watcher only gets the types of notification they haven't turned off, a type without a row is on
+create table notification_preferences (
+    id_profile INT NOT NULL,
+    type notification_type NOT NULL,
+    enabled BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_profile, type),
+    CONSTRAINT fk_notification_preferences_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+-- when the movie night's members were reminded it's coming up, it's cleared when the time or reminder changes
+ALTER TABLE movie_nights ADD COLUMN reminder_sent_at TIMESTAMPTZ;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE movie_nights DROP COLUMN IF EXISTS reminder_sent_at;
+DROP TABLE IF EXISTS notification_preferences;
+DROP TABLE IF EXISTS notifications;
+DROP TYPE IF EXISTS notification_type;
@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE party_activity_type AS ENUM ('party_created', 'member_joined', 'movie_added', 'movie_selected', 'movie_watched', 'invite_sent');
+-- +goose StatementEnd
+
+-- everything that's happened in a party, rows are only ever added. id_actor is who did it, id_subject is who it was
+-- done to, like the watcher who was invited, when they have an account
+create table party_activities (
+    id_party_activity INT GENERATED ALWAYS AS IDENTITY,
+    id_party INT NOT NULL,
+    type party_activity_type NOT NULL,
+    id_actor INT,
+    id_movie INT,
+    id_subject INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_party_activity),
+    CONSTRAINT fk_party_activities_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE,
+    CONSTRAINT fk_party_activities_actors FOREIGN KEY(id_actor) REFERENCES profiles(id_profile) ON DELETE SET NULL,
+    CONSTRAINT fk_party_activities_movies FOREIGN KEY(id_movie) REFERENCES movies(id_movie) ON DELETE CASCADE,
+    CONSTRAINT fk_party_activities_subjects FOREIGN KEY(id_subject) REFERENCES profiles(id_profile) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_party_activities_id_party_id_party_activity ON party_activities(id_party, id_party_activity);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS party_activities;
+DROP TYPE IF EXISTS party_activity_type;
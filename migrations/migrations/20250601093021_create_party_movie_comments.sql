@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+ALTER TYPE notification_type ADD VALUE IF NOT EXISTS 'comment_mention';
+-- +goose StatementEnd
+
+-- members discussing the party's movies, replies are only ever one level deep so id_parent_comment is always a comment
+-- without a parent. deleted comments are kept with their body cleared so the replies to them still make sense,
+-- id_deleted_by is who deleted it which is either the author or the party's owner
+create table party_movie_comments (
+    id_comment INT GENERATED ALWAYS AS IDENTITY,
+    id_party INT NOT NULL,
+    id_movie INT NOT NULL,
+    id_parent_comment INT,
+    id_author INT,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    edited_at TIMESTAMPTZ,
+    deleted_at TIMESTAMPTZ,
+    id_deleted_by INT,
+    PRIMARY KEY(id_comment),
+    CONSTRAINT fk_party_movie_comments_party_movies FOREIGN KEY(id_party, id_movie) REFERENCES party_movies(id_party, id_movie) ON DELETE CASCADE,
+    CONSTRAINT fk_party_movie_comments_parents FOREIGN KEY(id_parent_comment) REFERENCES party_movie_comments(id_comment) ON DELETE CASCADE,
+    CONSTRAINT fk_party_movie_comments_authors FOREIGN KEY(id_author) REFERENCES profiles(id_profile) ON DELETE SET NULL,
+    CONSTRAINT fk_party_movie_comments_deleted_by FOREIGN KEY(id_deleted_by) REFERENCES profiles(id_profile) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_party_movie_comments_id_party_id_movie ON party_movie_comments(id_party, id_movie, id_comment);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS party_movie_comments;
+-- postgres can't drop a value from an enum so comment_mention is left on notification_type, nothing creates it anymore
+DELETE FROM notifications WHERE type = 'comment_mention';
@@ -0,0 +1,57 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE webhook_format AS ENUM ('json', 'slack', 'discord');
+CREATE TYPE webhook_delivery_status AS ENUM ('pending', 'succeeded', 'failed');
+-- +goose StatementEnd
+
+-- urls the party's owner wants told when something happens in the party, secret signs the json payloads so receivers
+-- can check they came from us. the on_ columns are which events are sent to it
+create table party_webhooks (
+    id_webhook INT GENERATED ALWAYS AS IDENTITY,
+    id_party INT NOT NULL,
+    url TEXT NOT NULL,
+    format webhook_format NOT NULL,
+    secret TEXT NOT NULL,
+    on_movie_added BOOLEAN NOT NULL DEFAULT TRUE,
+    on_movie_selected BOOLEAN NOT NULL DEFAULT TRUE,
+    on_movie_watched BOOLEAN NOT NULL DEFAULT TRUE,
+    id_created_by INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_webhook),
+    CONSTRAINT fk_party_webhooks_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE,
+    CONSTRAINT fk_party_webhooks_profiles FOREIGN KEY(id_created_by) REFERENCES profiles(id_profile) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_party_webhooks_id_party ON party_webhooks(id_party);
+
+-- every event sent to a webhook with what happened when it was sent. the body is built when the event happens so
+-- retries send exactly the same thing, pending deliveries are sent once next_attempt_at has passed
+create table webhook_deliveries (
+    id_delivery INT GENERATED ALWAYS AS IDENTITY,
+    id_webhook INT NOT NULL,
+    event_type TEXT NOT NULL,
+    body TEXT NOT NULL,
+    status webhook_delivery_status NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    last_attempt_at TIMESTAMPTZ,
+    response_status INT,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_delivery),
+    CONSTRAINT fk_webhook_deliveries_party_webhooks FOREIGN KEY(id_webhook) REFERENCES party_webhooks(id_webhook) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_webhook_deliveries_id_webhook ON webhook_deliveries(id_webhook, id_delivery);
+CREATE INDEX idx_webhook_deliveries_pending ON webhook_deliveries(next_attempt_at) WHERE status = 'pending';
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS party_webhooks;
+DROP TYPE IF EXISTS webhook_delivery_status;
+DROP TYPE IF EXISTS webhook_format;
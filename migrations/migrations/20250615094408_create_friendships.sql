@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE friendship_status AS ENUM ('pending', 'accepted');
+-- +goose StatementEnd
+
+-- friend requests between watchers, id_requester is who sent it and id_addressee is who has to accept it. declining
+-- or removing a friend deletes the row so there's only ever one friendship between two watchers whichever way round
+create table friendships (
+    id_friendship INT GENERATED ALWAYS AS IDENTITY,
+    id_requester INT NOT NULL,
+    id_addressee INT NOT NULL,
+    status friendship_status NOT NULL DEFAULT 'pending',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    responded_at TIMESTAMPTZ,
+    PRIMARY KEY(id_friendship),
+    CONSTRAINT fk_friendships_requesters FOREIGN KEY(id_requester) REFERENCES profiles(id_profile) ON DELETE CASCADE,
+    CONSTRAINT fk_friendships_addressees FOREIGN KEY(id_addressee) REFERENCES profiles(id_profile) ON DELETE CASCADE,
+    CONSTRAINT chk_friendships_not_self CHECK (id_requester <> id_addressee)
+);
+
+CREATE UNIQUE INDEX idx_friendships_pair ON friendships(least(id_requester, id_addressee), greatest(id_requester, id_addressee));
+CREATE INDEX idx_friendships_id_addressee ON friendships(id_addressee);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+DROP TABLE IF EXISTS friendships;
+DROP TYPE IF EXISTS friendship_status;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- public parties can be seen by anyone at a link made from their short_id
+ALTER TABLE parties ADD COLUMN is_public BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE parties DROP COLUMN is_public;
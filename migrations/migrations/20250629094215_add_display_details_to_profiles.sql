@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE profiles ADD COLUMN display_name VARCHAR(50);
+ALTER TABLE profiles ADD COLUMN bio VARCHAR(280) NOT NULL DEFAULT '';
+ALTER TABLE profiles ADD COLUMN avatar_key VARCHAR(255);
+
+create table profile_favorite_genres (
+    id_profile INT NOT NULL,
+    id_genre INT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_profile, id_genre),
+    CONSTRAINT fk_profile_favorite_genres_profiles FOREIGN KEY(id_profile) REFERENCES profiles(id_profile) ON DELETE CASCADE,
+    CONSTRAINT fk_profile_favorite_genres_genres FOREIGN KEY(id_genre) REFERENCES genres(id_genre)
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+drop table if exists profile_favorite_genres;
+ALTER TABLE profiles DROP COLUMN avatar_key;
+ALTER TABLE profiles DROP COLUMN bio;
+ALTER TABLE profiles DROP COLUMN display_name;
@@ -0,0 +1,54 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- an account is deleted once deletion_scheduled_for has passed, clearing it cancels the deletion
+ALTER TABLE accounts ADD COLUMN deletion_requested_at TIMESTAMPTZ;
+ALTER TABLE accounts ADD COLUMN deletion_scheduled_for TIMESTAMPTZ;
+CREATE INDEX idx_accounts_deletion_scheduled_for ON accounts(deletion_scheduled_for) WHERE deletion_scheduled_for IS NOT NULL;
+
+-- the profile of a deleted account is kept as a "Former member" so the movies, ratings and comments they left behind
+-- still have someone to belong to, deleted_at is when it was anonymized
+ALTER TABLE profiles ADD COLUMN deleted_at TIMESTAMPTZ;
+
+-- what happens to each party when its owner's account is deleted, it's handed to id_new_owner or deleted when that's
+-- null. Parties without a handoff go to the member who has been in them longest
+create table party_handoffs (
+    id_party INT NOT NULL,
+    id_owner INT NOT NULL,
+    id_new_owner INT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_party),
+    CONSTRAINT fk_party_handoffs_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE,
+    CONSTRAINT fk_party_handoffs_owners FOREIGN KEY(id_owner) REFERENCES profiles(id_profile) ON DELETE CASCADE,
+    CONSTRAINT fk_party_handoffs_new_owners FOREIGN KEY(id_new_owner) REFERENCES profiles(id_profile) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_party_handoffs_id_owner ON party_handoffs(id_owner);
+
+-- deleting a party takes its movies, members and invitations with it
+ALTER TABLE party_movies DROP CONSTRAINT fk_party_movies_parties;
+ALTER TABLE party_movies ADD CONSTRAINT fk_party_movies_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE;
+ALTER TABLE party_members DROP CONSTRAINT fk_party_members_parties;
+ALTER TABLE party_members ADD CONSTRAINT fk_party_members_parties FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE;
+ALTER TABLE invitations DROP CONSTRAINT fk_invitations_party;
+ALTER TABLE invitations ADD CONSTRAINT fk_invitations_party FOREIGN KEY(id_party) REFERENCES parties(id_party) ON DELETE CASCADE;
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+ALTER TABLE invitations DROP CONSTRAINT fk_invitations_party;
+ALTER TABLE invitations ADD CONSTRAINT fk_invitations_party FOREIGN KEY(id_party) REFERENCES parties(id_party);
+ALTER TABLE party_members DROP CONSTRAINT fk_party_members_parties;
+ALTER TABLE party_members ADD CONSTRAINT fk_party_members_parties FOREIGN KEY(id_party) REFERENCES parties(id_party);
+ALTER TABLE party_movies DROP CONSTRAINT fk_party_movies_parties;
+ALTER TABLE party_movies ADD CONSTRAINT fk_party_movies_parties FOREIGN KEY(id_party) REFERENCES parties(id_party);
+
+drop table if exists party_handoffs;
+ALTER TABLE profiles DROP COLUMN deleted_at;
+DROP INDEX IF EXISTS idx_accounts_deletion_scheduled_for;
+ALTER TABLE accounts DROP COLUMN deletion_scheduled_for;
+ALTER TABLE accounts DROP COLUMN deletion_requested_at;
@@ -0,0 +1,66 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+CREATE TYPE account_role AS ENUM ('member', 'admin');
+CREATE TYPE failed_login_reason AS ENUM ('unknown_email', 'wrong_password', 'account_disabled');
+CREATE TYPE admin_action AS ENUM (
+    'search',
+    'view_account',
+    'view_failed_logins',
+    'disable_account',
+    'enable_account',
+    'force_logout',
+    'refresh_movie'
+);
+-- +goose StatementEnd
+
+-- admins can use /admin, there's no way to become one from the app so the first is made with
+-- UPDATE accounts SET role = 'admin' WHERE email = '...';
+ALTER TABLE accounts ADD COLUMN role account_role NOT NULL DEFAULT 'member';
+-- a disabled account can't log in and is logged out of everywhere it already is
+ALTER TABLE accounts ADD COLUMN disabled_at TIMESTAMPTZ;
+-- sessions remember the version they were started with, bumping it logs the account out of all of them
+ALTER TABLE accounts ADD COLUMN session_version INT NOT NULL DEFAULT 0;
+
+-- every login that didn't work, email is what was typed so it may not belong to an account
+create table failed_logins (
+    id_failed_login INT GENERATED ALWAYS AS IDENTITY,
+    email VARCHAR(255) NOT NULL,
+    ip_address VARCHAR(64) NOT NULL DEFAULT '',
+    reason failed_login_reason NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_failed_login)
+);
+
+CREATE INDEX idx_failed_logins_created_at ON failed_logins(created_at);
+
+-- everything done in /admin, admin_email is kept so the entry still says who it was after their account is deleted.
+-- id_target is the profile or movie the action was on and details is anything else worth knowing, like what was
+-- searched for
+create table admin_audit_log (
+    id_admin_audit_log INT GENERATED ALWAYS AS IDENTITY,
+    id_admin INT,
+    admin_email VARCHAR(255) NOT NULL,
+    action admin_action NOT NULL,
+    id_target INT,
+    details TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    PRIMARY KEY(id_admin_audit_log),
+    CONSTRAINT fk_admin_audit_log_accounts FOREIGN KEY(id_admin) REFERENCES accounts(id_account) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_admin_audit_log_created_at ON admin_audit_log(created_at);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+drop table if exists admin_audit_log;
+drop table if exists failed_logins;
+ALTER TABLE accounts DROP COLUMN session_version;
+ALTER TABLE accounts DROP COLUMN disabled_at;
+ALTER TABLE accounts DROP COLUMN role;
+DROP TYPE IF EXISTS admin_action;
+DROP TYPE IF EXISTS failed_login_reason;
+DROP TYPE IF EXISTS account_role;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+
+-- movies are stored in the default language, the details in any other language are kept here the first time someone
+-- looks at the movie in it
+create table movie_translations (
+    id_movie INT NOT NULL,
+    language VARCHAR(10) NOT NULL,
+    title TEXT NOT NULL,
+    overview TEXT NOT NULL,
+    tagline TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT (clock_timestamp() AT TIME ZONE 'UTC'),
+    updated_at TIMESTAMPTZ,
+    PRIMARY KEY(id_movie, language),
+    CONSTRAINT fk_movie_translations_movies FOREIGN KEY(id_movie) REFERENCES movies(id_movie) ON DELETE CASCADE
+);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+
+drop table if exists movie_translations;
@@ -0,0 +1,172 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+// ErrActivityFeedNotFound is returned when an activity feed's token doesn't belong to a member of the party
+var ErrActivityFeedNotFound = errors.New("activity feed not found")
+
+const (
+	// activityPageSize is how much of a party's activity is shown at a time
+	activityPageSize = 20
+
+	// activityFeedSize is how much of a party's activity is in its feed, feed readers keep what they've already seen
+	activityFeedSize = 50
+)
+
+// Activity is something that happened in a party
+type Activity struct {
+	ID   int
+	Type store.ActivityTypeEnum
+	// Actor is who did it and Subject is who it was done to, either is empty once they've deleted their account and
+	// Subject is empty when it wasn't done to anyone who has one
+	Actor     FullName
+	Subject   FullName
+	IDMovie   int
+	Title     string
+	CreatedAt time.Time
+}
+
+// ActorName is who did it, to be shown before the description
+func (a Activity) ActorName() string {
+	return nameOrSomeone(a.Actor)
+}
+
+// Description is what was done, to be shown after who did it and before the movie when it's about one
+func (a Activity) Description() string {
+	switch a.Type {
+	case store.ActivityTypePartyCreated:
+		return "created the party"
+	case store.ActivityTypeMemberJoined:
+		return "joined the party"
+	case store.ActivityTypeMovieAdded:
+		return "added"
+	case store.ActivityTypeMovieSelected:
+		return "picked"
+	case store.ActivityTypeMovieWatched:
+		return "watched"
+	case store.ActivityTypeInviteSent:
+		if a.Subject.FirstName == "" {
+			return "invited someone new to join"
+		}
+		return "invited " + a.Subject.FirstName + " " + a.Subject.LastName + " to join"
+	default:
+		return string(a.Type)
+	}
+}
+
+// Summary is the whole of what happened in a sentence
+func (a Activity) Summary() string {
+	summary := a.ActorName() + " " + a.Description()
+	if a.Title != "" {
+		summary += " " + a.Title
+	}
+	return summary
+}
+
+// ActivityPage is some of a party's activity, newest first
+type ActivityPage struct {
+	IDParty    int
+	Activities []Activity
+	// NextBefore is what loads the activity before this page, it's 0 when there isn't any
+	NextBefore int
+}
+
+// ActivityFeed is a party's latest activity for feed readers
+type ActivityFeed struct {
+	IDParty    int
+	PartyName  string
+	Activities []Activity
+}
+
+// GetActivity returns a page of what's happened in the party from before the activity with the id idBefore, an
+// idBefore of 0 starts from the latest. Only members of the party can see it
+func (s PartyService) GetActivity(ctx context.Context, idParty, idWatcher, idBefore int) (ActivityPage, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.GetActivity")
+	defer span.End()
+
+	err := checkPartyMembership(ctx, s.db, idParty, idWatcher)
+	if err != nil {
+		return ActivityPage{}, err
+	}
+
+	// one more than a page is read to know whether there's another page after it
+	activities, err := getActivities(ctx, s.db, idParty, idBefore, activityPageSize+1)
+	if err != nil {
+		return ActivityPage{}, err
+	}
+
+	page := ActivityPage{IDParty: idParty, Activities: activities}
+	if len(activities) > activityPageSize {
+		page.Activities = activities[:activityPageSize]
+		page.NextBefore = page.Activities[activityPageSize-1].ID
+	}
+
+	return page, nil
+}
+
+// GetActivityFeed returns the party's latest activity for the watcher's feed reader, ErrActivityFeedNotFound is
+// returned once they aren't a member of the party
+func (s PartyService) GetActivityFeed(ctx context.Context, logger *slog.Logger, idParty, idWatcher int) (ActivityFeed, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "PartyService.GetActivityFeed")
+	defer span.End()
+
+	err := checkPartyMembership(ctx, s.db, idParty, idWatcher)
+	if errors.Is(err, ErrNotPartyMember) {
+		return ActivityFeed{}, ErrActivityFeedNotFound
+	}
+
+	if err != nil {
+		return ActivityFeed{}, err
+	}
+
+	party, err := s.db.GetPartyByID(ctx, idParty)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get party for activity feed", slog.Any("error", err))
+		return ActivityFeed{}, err
+	}
+
+	activities, err := getActivities(ctx, s.db, idParty, 0, activityFeedSize)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get activity for feed", slog.Any("error", err))
+		return ActivityFeed{}, err
+	}
+
+	return ActivityFeed{IDParty: idParty, PartyName: party.Name, Activities: activities}, nil
+}
+
+func getActivities(ctx context.Context, db store.PartyRepository, idParty, idBefore, limit int) ([]Activity, error) {
+	activities := make([]Activity, 0, limit)
+	err := db.GetActivities(ctx, idParty, idBefore, limit, func(res store.ActivityResult) {
+		activities = append(activities, Activity{
+			ID:        res.ID,
+			Type:      res.Type,
+			Actor:     FullName{FirstName: res.ActorFirstName, LastName: res.ActorLastName},
+			Subject:   FullName{FirstName: res.SubjectFirstName, LastName: res.SubjectLastName},
+			IDMovie:   res.IDMovie,
+			Title:     res.Title,
+			CreatedAt: res.CreatedAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return activities, nil
+}
+
+func nameOrSomeone(name FullName) string {
+	if name.FirstName == "" {
+		return "Someone"
+	}
+	return name.FirstName + " " + name.LastName
+}
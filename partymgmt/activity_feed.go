@@ -0,0 +1,85 @@
+package partymgmt
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Author  atomAuthor  `xml:"author"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr"`
+	Href string `xml:"href,attr"`
+}
+
+type atomEntry struct {
+	ID      string     `xml:"id"`
+	Title   string     `xml:"title"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	Link    atomLink   `xml:"link"`
+}
+
+// WriteAtom writes the feed as an Atom (RFC 4287) feed, feedURL is where the feed itself is found and partyURL is the
+// party's page that every entry links to. now is used as when the feed was updated when the party hasn't any activity
+func (f ActivityFeed) WriteAtom(w io.Writer, feedURL, partyURL string, now time.Time) error {
+	updated := now
+	if len(f.Activities) > 0 {
+		updated = f.Activities[0].CreatedAt
+	}
+
+	feed := atomFeed{
+		XMLNS: atomNamespace,
+		// the feed's url has the watcher's token in it so the party's page is what identifies it instead
+		ID:      partyURL + "/activity",
+		Title:   f.PartyName + " activity",
+		Updated: updated.UTC().Format(time.RFC3339),
+		Author:  atomAuthor{Name: "MoviesWithFriends"},
+		Links: []atomLink{
+			{Rel: "self", Href: feedURL},
+			{Rel: "alternate", Href: partyURL},
+		},
+		Entries: make([]atomEntry, 0, len(f.Activities)),
+	}
+
+	for _, activity := range f.Activities {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("%s/activity/%d", partyURL, activity.ID),
+			Title:   activity.Summary(),
+			Updated: activity.CreatedAt.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: activity.ActorName()},
+			Link:    atomLink{Rel: "alternate", Href: partyURL},
+		})
+	}
+
+	_, err := io.WriteString(w, xml.Header)
+	if err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	err = enc.Encode(feed)
+	if err != nil {
+		return err
+	}
+
+	return enc.Close()
+}
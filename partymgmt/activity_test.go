@@ -0,0 +1,100 @@
+package partymgmt_test
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestActivitySummary(t *testing.T) {
+	t.Parallel()
+
+	sam := partymgmt.FullName{FirstName: "Sam", LastName: "Smith"}
+	alex := partymgmt.FullName{FirstName: "Alex", LastName: "Jones"}
+
+	tests := map[string]struct {
+		activity partymgmt.Activity
+		expected string
+	}{
+		"party created":     {activity: partymgmt.Activity{Type: store.ActivityTypePartyCreated, Actor: sam}, expected: "Sam Smith created the party"},
+		"member joined":     {activity: partymgmt.Activity{Type: store.ActivityTypeMemberJoined, Actor: alex}, expected: "Alex Jones joined the party"},
+		"movie added":       {activity: partymgmt.Activity{Type: store.ActivityTypeMovieAdded, Actor: sam, Title: "Heat"}, expected: "Sam Smith added Heat"},
+		"movie selected":    {activity: partymgmt.Activity{Type: store.ActivityTypeMovieSelected, Actor: sam, Title: "Heat"}, expected: "Sam Smith picked Heat"},
+		"movie watched":     {activity: partymgmt.Activity{Type: store.ActivityTypeMovieWatched, Actor: sam, Title: "Heat"}, expected: "Sam Smith watched Heat"},
+		"invite sent":       {activity: partymgmt.Activity{Type: store.ActivityTypeInviteSent, Actor: sam, Subject: alex}, expected: "Sam Smith invited Alex Jones to join"},
+		"invite to someone": {activity: partymgmt.Activity{Type: store.ActivityTypeInviteSent, Actor: sam}, expected: "Sam Smith invited someone new to join"},
+		"deleted actor":     {activity: partymgmt.Activity{Type: store.ActivityTypeMovieAdded, Title: "Heat"}, expected: "Someone added Heat"},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testhelpers.Equals(t, tc.expected, tc.activity.Summary())
+		})
+	}
+}
+
+func TestActivityFeedWriteAtom(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	feed := partymgmt.ActivityFeed{
+		IDParty:   3,
+		PartyName: "Friday <Club>",
+		Activities: []partymgmt.Activity{
+			{
+				ID:        12,
+				Type:      store.ActivityTypeMovieAdded,
+				Actor:     partymgmt.FullName{FirstName: "Sam", LastName: "Smith"},
+				IDMovie:   4,
+				Title:     "Fast & Furious",
+				CreatedAt: time.Date(2025, 5, 2, 20, 0, 0, 0, time.UTC),
+			},
+			{
+				ID:        11,
+				Type:      store.ActivityTypePartyCreated,
+				CreatedAt: time.Date(2025, 5, 1, 20, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	var b strings.Builder
+	err := feed.WriteAtom(&b, "https://example.com/feeds/token/parties/3/activity.atom", "https://example.com/parties/3", now)
+	testhelpers.Ok(t, err, "failed to write feed")
+
+	var parsed struct {
+		Title   string `xml:"title"`
+		ID      string `xml:"id"`
+		Updated string `xml:"updated"`
+		Entries []struct {
+			ID      string `xml:"id"`
+			Title   string `xml:"title"`
+			Updated string `xml:"updated"`
+			Author  string `xml:"author>name"`
+		} `xml:"entry"`
+	}
+	err = xml.Unmarshal([]byte(b.String()), &parsed)
+	testhelpers.Ok(t, err, "failed to parse feed")
+
+	testhelpers.Equals(t, "Friday <Club> activity", parsed.Title)
+	testhelpers.Equals(t, "https://example.com/parties/3/activity", parsed.ID)
+	testhelpers.Equals(t, "2025-05-02T20:00:00Z", parsed.Updated)
+	testhelpers.Equals(t, 2, len(parsed.Entries))
+	testhelpers.Equals(t, "https://example.com/parties/3/activity/12", parsed.Entries[0].ID)
+	testhelpers.Equals(t, "Sam Smith added Fast & Furious", parsed.Entries[0].Title)
+	testhelpers.Equals(t, "Sam Smith", parsed.Entries[0].Author)
+	testhelpers.Equals(t, "Someone", parsed.Entries[1].Author)
+	testhelpers.Assert(t, strings.Contains(b.String(), `<feed xmlns="http://www.w3.org/2005/Atom">`), "expected the atom namespace")
+
+	// a party without any activity was last updated whenever it's asked for
+	b.Reset()
+	err = partymgmt.ActivityFeed{PartyName: "Empty"}.WriteAtom(&b, "https://example.com/feed", "https://example.com/parties/4", now)
+	testhelpers.Ok(t, err, "failed to write empty feed")
+	testhelpers.Assert(t, strings.Contains(b.String(), "<updated>2025-06-01T12:00:00Z</updated>"), "expected the feed to be updated now")
+}
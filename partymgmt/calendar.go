@@ -0,0 +1,147 @@
+package partymgmt
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icsTimeLayout = "20060102T150405Z"
+
+	// icsLineLength is the most octets a line in an iCalendar file can have before it has to be folded
+	icsLineLength = 75
+)
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteICS writes the calendar as an iCalendar (RFC 5545) feed, now is used as the time every event was generated at
+func (c Calendar) WriteICS(w io.Writer, now time.Time) error {
+	var b strings.Builder
+
+	writeICSLine(&b, "BEGIN", "VCALENDAR")
+	writeICSLine(&b, "VERSION", "2.0")
+	writeICSLine(&b, "PRODID", "-//MoviesWithFriends//Movie Nights//EN")
+	writeICSLine(&b, "CALSCALE", "GREGORIAN")
+	writeICSLine(&b, "METHOD", "PUBLISH")
+	writeICSLine(&b, "X-WR-CALNAME", escapeICSText(c.Name))
+	// calendar apps that honour these check for changes hourly rather than whenever they like
+	writeICSLine(&b, "REFRESH-INTERVAL;VALUE=DURATION", "PT1H")
+	writeICSLine(&b, "X-PUBLISHED-TTL", "PT1H")
+
+	for _, night := range c.MovieNights {
+		writeICSEvent(&b, night, now)
+	}
+
+	writeICSLine(&b, "END", "VCALENDAR")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeICSEvent(b *strings.Builder, night MovieNight, now time.Time) {
+	summary := night.PartyName + " movie night"
+	if night.Movie != nil {
+		summary = night.PartyName + ": " + night.Movie.Title
+	}
+
+	writeICSLine(b, "BEGIN", "VEVENT")
+	writeICSLine(b, "UID", fmt.Sprintf("movie-night-%d@movieswithfriends", night.ID))
+	writeICSLine(b, "DTSTAMP", now.UTC().Format(icsTimeLayout))
+	writeICSLine(b, "DTSTART", night.StartsAt.UTC().Format(icsTimeLayout))
+	writeICSLine(b, "DTEND", night.EndsAt().UTC().Format(icsTimeLayout))
+	writeICSLine(b, "LAST-MODIFIED", night.UpdatedAt.UTC().Format(icsTimeLayout))
+	writeICSLine(b, "SEQUENCE", fmt.Sprint(night.Sequence))
+	writeICSLine(b, "SUMMARY", escapeICSText(summary))
+	writeICSLine(b, "STATUS", "CONFIRMED")
+
+	switch {
+	case night.Location != "":
+		writeICSLine(b, "LOCATION", escapeICSText(night.Location))
+	case night.StreamURL != "":
+		writeICSLine(b, "LOCATION", escapeICSText(night.StreamURL))
+	}
+
+	if night.StreamURL != "" {
+		writeICSLine(b, "URL", night.StreamURL)
+	}
+
+	writeICSLine(b, "DESCRIPTION", escapeICSText(icsDescription(night)))
+
+	// there's nothing to be reminded of once it's happened
+	if night.ReminderMinutes > 0 && !night.IsCompleted() {
+		writeICSLine(b, "BEGIN", "VALARM")
+		writeICSLine(b, "ACTION", "DISPLAY")
+		writeICSLine(b, "DESCRIPTION", escapeICSText(summary))
+		writeICSLine(b, "TRIGGER", fmt.Sprintf("-PT%dM", night.ReminderMinutes))
+		writeICSLine(b, "END", "VALARM")
+	}
+
+	writeICSLine(b, "END", "VEVENT")
+}
+
+// icsDescription lists what's being watched, where to stream it and who's coming, only names are included so the feed
+// never has anyone's email in it
+func icsDescription(night MovieNight) string {
+	lines := make([]string, 0, 4)
+
+	if night.Movie != nil {
+		lines = append(lines, "Watching "+night.Movie.Title)
+	} else {
+		lines = append(lines, "The movie hasn't been picked yet")
+	}
+
+	if night.StreamURL != "" {
+		lines = append(lines, "Stream: "+night.StreamURL)
+	}
+
+	for _, group := range []struct {
+		label    string
+		response RSVPResponse
+	}{
+		{label: "Going", response: RSVPGoing},
+		{label: "Maybe", response: RSVPMaybe},
+	} {
+		rsvps := night.RSVPsWith(group.response)
+		if len(rsvps) == 0 {
+			continue
+		}
+
+		names := make([]string, 0, len(rsvps))
+		for _, rsvp := range rsvps {
+			names = append(names, strings.TrimSpace(rsvp.Name.FirstName+" "+rsvp.Name.LastName))
+		}
+		lines = append(lines, group.label+": "+strings.Join(names, ", "))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func escapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+// writeICSLine writes a content line, folding it onto continuation lines that start with a space when it's too long
+// and never splitting a character across lines
+func writeICSLine(b *strings.Builder, name, value string) {
+	line := name + ":" + value
+
+	// continuation lines lose an octet to the leading space
+	limit := icsLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = icsLineLength - 1
+	}
+
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,98 @@
+package partymgmt_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestCalendarWriteICS(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	loc, err := time.LoadLocation("America/New_York")
+	testhelpers.Ok(t, err, "failed to load time zone")
+
+	calendar := partymgmt.Calendar{
+		Name: "Friday Club Movie Nights",
+		MovieNights: []partymgmt.MovieNight{
+			{
+				ID:              7,
+				PartyName:       "Friday Club",
+				Movie:           &partymgmt.MovieNightMovie{ID: 1, Title: "Heat", Runtime: 170},
+				StartsAt:        time.Date(2025, 4, 4, 19, 30, 0, 0, loc),
+				Location:        "Sam's place; bring snacks, please",
+				StreamURL:       "https://example.com/watch",
+				ReminderMinutes: 60,
+				Sequence:        2,
+				UpdatedAt:       now,
+				RSVPs: []partymgmt.MovieNightRSVP{
+					{Name: partymgmt.FullName{FirstName: "Sam", LastName: "Smith"}, Response: partymgmt.RSVPGoing},
+					{Name: partymgmt.FullName{FirstName: "Alex", LastName: "Jones"}, Response: partymgmt.RSVPMaybe},
+					{Name: partymgmt.FullName{FirstName: "Jo", LastName: "Brown"}, Response: partymgmt.RSVPNotGoing},
+				},
+			},
+			{
+				ID:              8,
+				PartyName:       "Friday Club",
+				StartsAt:        time.Date(2025, 4, 11, 19, 30, 0, 0, loc),
+				ReminderMinutes: 0,
+				UpdatedAt:       now,
+			},
+		},
+	}
+
+	var b strings.Builder
+	err = calendar.WriteICS(&b, now)
+	testhelpers.Ok(t, err, "failed to write calendar")
+	ics := b.String()
+
+	testhelpers.Assert(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\n"), "expected calendar to start with BEGIN:VCALENDAR")
+	testhelpers.Assert(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"), "expected calendar to end with END:VCALENDAR")
+	testhelpers.Equals(t, 2, strings.Count(ics, "BEGIN:VEVENT"))
+	testhelpers.Equals(t, 1, strings.Count(ics, "BEGIN:VALARM"))
+
+	unfolded := strings.ReplaceAll(ics, "\r\n ", "")
+	expectedLines := []string{
+		"UID:movie-night-7@movieswithfriends",
+		"DTSTAMP:20250401T120000Z",
+		"DTSTART:20250404T233000Z",
+		"DTEND:20250405T022000Z",
+		"SEQUENCE:2",
+		"SUMMARY:Friday Club: Heat",
+		`LOCATION:Sam's place\; bring snacks\, please`,
+		"URL:https://example.com/watch",
+		`DESCRIPTION:Watching Heat\nStream: https://example.com/watch\nGoing: Sam Smith\nMaybe: Alex Jones`,
+		"TRIGGER:-PT60M",
+		"SUMMARY:Friday Club movie night",
+		"DTEND:20250412T013000Z",
+	}
+	for _, line := range expectedLines {
+		testhelpers.Assert(t, strings.Contains(unfolded, line+"\r\n"), "expected calendar to contain %q", line)
+	}
+
+	for _, line := range strings.Split(strings.TrimSuffix(ics, "\r\n"), "\r\n") {
+		testhelpers.Assert(t, len(line) <= 75, "expected line to be folded at 75 octets, got %d: %q", len(line), line)
+	}
+}
+
+func TestCalendarWriteICSFoldsMultiByteCharacters(t *testing.T) {
+	t.Parallel()
+
+	calendar := partymgmt.Calendar{Name: strings.Repeat("é", 80)}
+
+	var b strings.Builder
+	err := calendar.WriteICS(&b, time.Now())
+	testhelpers.Ok(t, err, "failed to write calendar")
+
+	for _, line := range strings.Split(b.String(), "\r\n") {
+		testhelpers.Assert(t, len(line) <= 75, "expected line to be folded at 75 octets, got %d", len(line))
+		testhelpers.Assert(t, !strings.ContainsRune(line, '�'), "expected folding not to split a character")
+	}
+
+	unfolded := strings.ReplaceAll(b.String(), "\r\n ", "")
+	testhelpers.Assert(t, strings.Contains(unfolded, "X-WR-CALNAME:"+calendar.Name+"\r\n"), "expected the name to survive folding")
+}
@@ -0,0 +1,413 @@
+package partymgmt
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrInvalidComment      = errors.New("comments can't be empty or longer than 2000 characters")
+	ErrCommentNotFound     = errors.New("comment not found")
+	ErrCannotEditComment   = errors.New("only the member who wrote the comment can edit it")
+	ErrCannotDeleteComment = errors.New("only the member who wrote the comment or the party's owner can delete it")
+)
+
+// MaxCommentLength is the most characters a comment can have
+const MaxCommentLength = 2000
+
+// Comment is a member's comment on one of the party's movies
+type Comment struct {
+	ID      int
+	IDParty int
+	IDMovie int
+	// IDParent is the comment this is a reply to, it's 0 for comments that start a thread
+	IDParent int
+	IDAuthor int
+	// Author is empty once they've deleted their account
+	Author FullName
+	// AuthorDisplayName and AuthorAvatarKey are empty when the author hasn't set them
+	AuthorDisplayName string
+	AuthorAvatarKey   string
+	Body              string
+	CreatedAt         time.Time
+	EditedAt          *time.Time
+	DeletedAt         *time.Time
+	// DeletedByOwner is set when the party's owner deleted someone else's comment
+	DeletedByOwner bool
+	// Replies are only set on comments that start a thread, oldest first
+	Replies []Comment
+	// CanEdit and CanDelete are what the current watcher can do with the comment
+	CanEdit   bool
+	CanDelete bool
+	html      template.HTML
+}
+
+// HTML is the comment's body with its markup applied, it's escaped when it's rendered so it's safe to show as is
+func (c Comment) HTML() template.HTML {
+	return c.html
+}
+
+func (c Comment) IsDeleted() bool {
+	return c.DeletedAt != nil
+}
+
+func (c Comment) AuthorName() string {
+	return cmp.Or(c.AuthorDisplayName, nameOrSomeone(c.Author))
+}
+
+// CommentThread is the discussion about one of the party's movies
+type CommentThread struct {
+	IDParty    int
+	IDMovie    int
+	MovieTitle string
+	// Comments are the comments starting each thread with their replies, oldest first. deleted comments are only kept
+	// when there are replies to them
+	Comments []Comment
+	// Count is how many comments haven't been deleted
+	Count int
+	// Handles are how the members can be mentioned
+	Handles []string
+}
+
+// CommentsSummary is how much discussion there's been about one of the party's movies
+type CommentsSummary struct {
+	IDParty int
+	IDMovie int
+	Count   int
+}
+
+type CommentService struct {
+	db      *store.CommentsRepository
+	partyDB store.PartyRepository
+}
+
+func NewCommentService(db *store.CommentsRepository, partyDB store.PartyRepository) CommentService {
+	return CommentService{db: db, partyDB: partyDB}
+}
+
+// GetThread returns the discussion about the party's movie as the watcher sees it
+func (s CommentService) GetThread(ctx context.Context, idParty, idMovie, idWatcher int) (CommentThread, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentService.GetThread")
+	defer span.End()
+
+	movie, err := s.getCommentedMovie(ctx, idParty, idMovie, idWatcher)
+	if err != nil {
+		return CommentThread{}, err
+	}
+
+	handles, suggested, err := s.getMentionHandles(ctx, idParty)
+	if err != nil {
+		return CommentThread{}, err
+	}
+
+	thread := CommentThread{
+		IDParty:    idParty,
+		IDMovie:    idMovie,
+		MovieTitle: movie.Title,
+		Comments:   make([]Comment, 0),
+		Handles:    suggested,
+	}
+
+	// replies always come after what they're replying to since they're read in the order they were written
+	threadIndex := make(map[int]int)
+	err = s.db.GetComments(ctx, idParty, idMovie, func(res store.CommentResult) {
+		comment := newComment(res, handles, idWatcher, movie.IDPartyOwner)
+		if !comment.IsDeleted() {
+			thread.Count++
+		}
+
+		if res.IDParent == 0 {
+			threadIndex[comment.ID] = len(thread.Comments)
+			thread.Comments = append(thread.Comments, comment)
+			return
+		}
+
+		idx, ok := threadIndex[res.IDParent]
+		if !ok || comment.IsDeleted() {
+			return
+		}
+		thread.Comments[idx].Replies = append(thread.Comments[idx].Replies, comment)
+	})
+	if err != nil {
+		return CommentThread{}, err
+	}
+
+	thread.Comments = slices.DeleteFunc(thread.Comments, func(c Comment) bool {
+		return c.IsDeleted() && len(c.Replies) == 0
+	})
+
+	return thread, nil
+}
+
+// CreateComment adds the watcher's comment to the discussion about the party's movie, replying to idParent when it's
+// set. the members mentioned in it other than the watcher are returned so they can be notified
+func (s CommentService) CreateComment(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher, idParent int, body string) ([]int, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "CommentService.CreateComment")
+	defer span.End()
+
+	body, err := validateCommentBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.getCommentedMovie(ctx, idParty, idMovie, idWatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	if idParent != 0 {
+		parent, err := s.getComment(ctx, idParty, idMovie, idParent)
+		if err != nil {
+			return nil, err
+		}
+
+		// replies to replies are added to the thread they're in so threads stay one level deep
+		if parent.IDParent != 0 {
+			idParent = parent.IDParent
+		}
+	}
+
+	handles, _, err := s.getMentionHandles(ctx, idParty)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.db.CreateComment(ctx, store.CommentParams{
+		IDParty:  idParty,
+		IDMovie:  idMovie,
+		IDParent: idParent,
+		IDAuthor: idWatcher,
+		Body:     body,
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to create comment", slog.Any("error", err))
+		return nil, err
+	}
+
+	_, mentioned := renderComment(body, handles)
+	return withoutWatcher(mentioned, idWatcher), nil
+}
+
+// EditComment changes what the watcher's comment says, the members who weren't mentioned in it before are returned
+// so only they're notified
+func (s CommentService) EditComment(ctx context.Context, logger *slog.Logger, idParty, idMovie, idComment, idWatcher int, body string) ([]int, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "CommentService.EditComment")
+	defer span.End()
+
+	body, err := validateCommentBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.getCommentedMovie(ctx, idParty, idMovie, idWatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	comment, err := s.getComment(ctx, idParty, idMovie, idComment)
+	if err != nil {
+		return nil, err
+	}
+
+	if comment.IDAuthor != idWatcher {
+		return nil, ErrCannotEditComment
+	}
+
+	handles, _, err := s.getMentionHandles(ctx, idParty)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.UpdateComment(ctx, idComment, body, time.Now())
+	// it was deleted in the meantime
+	if errors.Is(err, store.ErrNoRecord) {
+		return nil, ErrCommentNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to edit comment", slog.Any("error", err))
+		return nil, err
+	}
+
+	_, before := renderComment(comment.Body, handles)
+	_, after := renderComment(body, handles)
+
+	newlyMentioned := make([]int, 0, len(after))
+	for _, id := range withoutWatcher(after, idWatcher) {
+		if !slices.Contains(before, id) {
+			newlyMentioned = append(newlyMentioned, id)
+		}
+	}
+
+	return newlyMentioned, nil
+}
+
+// DeleteComment deletes the comment, only its author or the party's owner can delete it. the replies to it are kept
+func (s CommentService) DeleteComment(ctx context.Context, logger *slog.Logger, idParty, idMovie, idComment, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "CommentService.DeleteComment")
+	defer span.End()
+
+	movie, err := s.getCommentedMovie(ctx, idParty, idMovie, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	comment, err := s.getComment(ctx, idParty, idMovie, idComment)
+	if err != nil {
+		return err
+	}
+
+	if comment.IDAuthor != idWatcher && movie.IDPartyOwner != idWatcher {
+		return ErrCannotDeleteComment
+	}
+
+	err = s.db.DeleteComment(ctx, idComment, idWatcher, time.Now())
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrCommentNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to delete comment", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// LoadCommentCounts sets how much each of the party's movies has been discussed
+func (s CommentService) LoadCommentCounts(ctx context.Context, party *Party) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentService.LoadCommentCounts")
+	defer span.End()
+
+	counts := make(map[int]int)
+	err := s.db.GetCommentCounts(ctx, party.ID, func(idMovie, count int) {
+		counts[idMovie] = count
+	})
+	if err != nil {
+		return err
+	}
+
+	setCount := func(movie *PartyMovie) {
+		movie.Comments = CommentsSummary{IDParty: party.ID, IDMovie: movie.ID, Count: counts[movie.ID]}
+	}
+
+	for idx := range party.MoviesByStatus.UnwatchedMovies {
+		setCount(&party.MoviesByStatus.UnwatchedMovies[idx])
+	}
+
+	for idx := range party.MoviesByStatus.WatchedMovies {
+		setCount(&party.MoviesByStatus.WatchedMovies[idx])
+	}
+
+	if party.MoviesByStatus.SelectedMovie != nil {
+		setCount(party.MoviesByStatus.SelectedMovie)
+	}
+
+	return nil
+}
+
+// getCommentedMovie returns the party's movie being discussed after checking the watcher is in the party
+func (s CommentService) getCommentedMovie(ctx context.Context, idParty, idMovie, idWatcher int) (store.CommentedMovieResult, error) {
+	err := checkPartyMembership(ctx, s.partyDB, idParty, idWatcher)
+	if err != nil {
+		return store.CommentedMovieResult{}, err
+	}
+
+	movie, err := s.db.GetCommentedMovie(ctx, idParty, idMovie)
+	if errors.Is(err, store.ErrNoRecord) {
+		return store.CommentedMovieResult{}, ErrPartyMovieNotFound
+	}
+
+	if err != nil {
+		return store.CommentedMovieResult{}, err
+	}
+
+	return movie, nil
+}
+
+// getComment returns a comment on the party's movie that hasn't been deleted
+func (s CommentService) getComment(ctx context.Context, idParty, idMovie, idComment int) (store.CommentResult, error) {
+	comment, err := s.db.GetComment(ctx, idParty, idComment)
+	if errors.Is(err, store.ErrNoRecord) {
+		return store.CommentResult{}, ErrCommentNotFound
+	}
+
+	if err != nil {
+		return store.CommentResult{}, err
+	}
+
+	if comment.IDMovie != idMovie || comment.DeletedAt != nil {
+		return store.CommentResult{}, ErrCommentNotFound
+	}
+
+	return comment, nil
+}
+
+func (s CommentService) getMentionHandles(ctx context.Context, idParty int) (mentionHandles, []string, error) {
+	members := make([]PartyMember, 0)
+	err := s.partyDB.GetPartyMembers(ctx, idParty, func(res store.PartyMemberResult) {
+		members = append(members, newPartyMember(res))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	handles, suggested := newMentionHandles(members)
+	return handles, suggested, nil
+}
+
+func newComment(res store.CommentResult, handles mentionHandles, idWatcher, idPartyOwner int) Comment {
+	comment := Comment{
+		ID:                res.ID,
+		IDParty:           res.IDParty,
+		IDMovie:           res.IDMovie,
+		IDParent:          res.IDParent,
+		IDAuthor:          res.IDAuthor,
+		Author:            FullName{FirstName: res.AuthorFirstName, LastName: res.AuthorLastName},
+		AuthorDisplayName: res.AuthorDisplayName,
+		AuthorAvatarKey:   res.AuthorAvatarKey,
+		Body:              res.Body,
+		CreatedAt:         res.CreatedAt,
+		EditedAt:          res.EditedAt,
+		DeletedAt:         res.DeletedAt,
+		DeletedByOwner:    res.DeletedAt != nil && res.IDDeletedBy != 0 && res.IDDeletedBy != res.IDAuthor,
+	}
+
+	if comment.IsDeleted() {
+		return comment
+	}
+
+	rendered, _ := renderComment(res.Body, handles)
+	comment.html = template.HTML(rendered)
+	comment.CanEdit = res.IDAuthor == idWatcher
+	comment.CanDelete = res.IDAuthor == idWatcher || idPartyOwner == idWatcher
+
+	return comment
+}
+
+func validateCommentBody(body string) (string, error) {
+	body = strings.TrimSpace(body)
+	if body == "" || utf8.RuneCountInString(body) > MaxCommentLength {
+		return "", ErrInvalidComment
+	}
+	return body, nil
+}
+
+func withoutWatcher(ids []int, idWatcher int) []int {
+	return slices.DeleteFunc(slices.Clone(ids), func(id int) bool {
+		return id == idWatcher
+	})
+}
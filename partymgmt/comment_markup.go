@@ -0,0 +1,132 @@
+package partymgmt
+
+import (
+	"html"
+	"html/template"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+var (
+	// commentVerbatimPattern finds the parts of a comment nothing else is applied inside of, `inline code` and
+	// [links](https://example.com). only http(s) links are kept so nothing else can be run when they're clicked
+	commentVerbatimPattern = regexp.MustCompile("`([^`\n]+)`|\\[([^\\]\n]+)\\]\\((https?://[^\\s()<>\"]+)\\)")
+
+	commentBoldPattern    = regexp.MustCompile(`\*\*([^*\n]+)\*\*`)
+	commentItalicPattern  = regexp.MustCompile(`\*([^*\n]+)\*`)
+	commentMentionPattern = regexp.MustCompile(`(^|[^\p{L}\p{N}_@])@([\p{L}\p{N}]+)`)
+)
+
+// mentionHandles are who can be mentioned in a party keyed by their lowercased handle
+type mentionHandles map[string]PartyMember
+
+// newMentionHandles lets every member be mentioned by their first and last name run together, e.g. @AdaLovelace, and
+// by only their first name when no one else in the party has it. the handles members are suggested to use are
+// returned with them, sorted so they're always listed the same way
+func newMentionHandles(members []PartyMember) (mentionHandles, []string) {
+	handles := make(mentionHandles, len(members)*2)
+	suggested := make([]string, 0, len(members))
+
+	firstNames := make(map[string]int, len(members))
+	for _, member := range members {
+		firstNames[handleFor(member.FirstName)]++
+	}
+
+	for _, member := range members {
+		fullName := strings.Map(keepHandleRune, member.FirstName+member.LastName)
+		handles[strings.ToLower(fullName)] = member
+
+		firstName := strings.Map(keepHandleRune, member.FirstName)
+		if firstName != "" && firstNames[strings.ToLower(firstName)] == 1 {
+			handles[strings.ToLower(firstName)] = member
+			suggested = append(suggested, "@"+firstName)
+			continue
+		}
+		suggested = append(suggested, "@"+fullName)
+	}
+
+	slices.Sort(suggested)
+	return handles, suggested
+}
+
+func handleFor(name string) string {
+	return strings.ToLower(strings.Map(keepHandleRune, name))
+}
+
+func keepHandleRune(r rune) rune {
+	if unicode.IsLetter(r) || unicode.IsNumber(r) {
+		return r
+	}
+	return -1
+}
+
+// RenderComment renders the comment as it's shown in a party with the members, the ids of the members mentioned in it
+// are returned with it
+func RenderComment(body string, members []PartyMember) (template.HTML, []int) {
+	handles, _ := newMentionHandles(members)
+	rendered, mentioned := renderComment(body, handles)
+	return template.HTML(rendered), mentioned
+}
+
+// renderComment turns a comment written with a small part of markdown, **bold**, *italic*, `code` and
+// [links](https://example.com), into html that's safe to show as is. everything is escaped before any markup is added
+// so nothing the author wrote can end up as html. @mentions of members are highlighted and returned, mentions inside
+// code and links aren't counted
+func renderComment(body string, handles mentionHandles) (string, []int) {
+	body = strings.ReplaceAll(body, "\r\n", "\n")
+
+	var (
+		b         strings.Builder
+		mentioned []int
+		last      int
+	)
+
+	renderText := func(text string) {
+		rendered, ids := renderCommentText(text, handles)
+		b.WriteString(rendered)
+		for _, id := range ids {
+			if !slices.Contains(mentioned, id) {
+				mentioned = append(mentioned, id)
+			}
+		}
+	}
+
+	for _, match := range commentVerbatimPattern.FindAllStringSubmatchIndex(body, -1) {
+		renderText(body[last:match[0]])
+		last = match[1]
+
+		if match[2] >= 0 {
+			b.WriteString("<code>" + html.EscapeString(body[match[2]:match[3]]) + "</code>")
+			continue
+		}
+
+		b.WriteString(`<a href="` + html.EscapeString(body[match[6]:match[7]]) + `" rel="nofollow noopener" target="_blank">`)
+		b.WriteString(html.EscapeString(body[match[4]:match[5]]))
+		b.WriteString("</a>")
+	}
+	renderText(body[last:])
+
+	return strings.ReplaceAll(b.String(), "\n", "<br>\n"), mentioned
+}
+
+func renderCommentText(text string, handles mentionHandles) (string, []int) {
+	escaped := html.EscapeString(text)
+	escaped = commentBoldPattern.ReplaceAllString(escaped, "<strong>$1</strong>")
+	escaped = commentItalicPattern.ReplaceAllString(escaped, "<em>$1</em>")
+
+	mentioned := make([]int, 0)
+	escaped = commentMentionPattern.ReplaceAllStringFunc(escaped, func(match string) string {
+		groups := commentMentionPattern.FindStringSubmatch(match)
+		member, ok := handles[strings.ToLower(groups[2])]
+		if !ok {
+			return match
+		}
+
+		mentioned = append(mentioned, member.IDWatcher)
+		return groups[1] + `<span class="mention fw-semibold text-primary">@` + groups[2] + "</span>"
+	})
+
+	return escaped, mentioned
+}
@@ -0,0 +1,87 @@
+package partymgmt_test
+
+import (
+	"html/template"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestRenderComment(t *testing.T) {
+	t.Parallel()
+
+	members := []partymgmt.PartyMember{
+		{FirstName: "Ada", LastName: "Lovelace", IDWatcher: 1},
+		{FirstName: "Sam", LastName: "Smith", IDWatcher: 2},
+		{FirstName: "Sam", LastName: "Jones", IDWatcher: 3},
+		{FirstName: "Mary Jo", LastName: "O'Brien", IDWatcher: 4},
+	}
+
+	tests := map[string]struct {
+		body      string
+		expected  template.HTML
+		mentioned []int
+	}{
+		"plain text": {
+			body:      "Loved it",
+			expected:  "Loved it",
+			mentioned: nil,
+		},
+		"html is escaped": {
+			body:      `<script>alert("hi")</script> & <b>bold</b>`,
+			expected:  `&lt;script&gt;alert(&#34;hi&#34;)&lt;/script&gt; &amp; &lt;b&gt;bold&lt;/b&gt;`,
+			mentioned: nil,
+		},
+		"bold and italic": {
+			body:      "**so** good, *really*",
+			expected:  "<strong>so</strong> good, <em>really</em>",
+			mentioned: nil,
+		},
+		"inline code is left as it is": {
+			body:      "run `**not bold** <i>` now",
+			expected:  "run <code>**not bold** &lt;i&gt;</code> now",
+			mentioned: nil,
+		},
+		"links": {
+			body:      `see [the "trailer"](https://example.com/watch?v=1&t=2)`,
+			expected:  `see <a href="https://example.com/watch?v=1&amp;t=2" rel="nofollow noopener" target="_blank">the &#34;trailer&#34;</a>`,
+			mentioned: nil,
+		},
+		"only http links": {
+			body:      "[click](javascript:alert(1))",
+			expected:  "[click](javascript:alert(1))",
+			mentioned: nil,
+		},
+		"line breaks": {
+			body:      "first\r\nsecond",
+			expected:  "first<br>\nsecond",
+			mentioned: nil,
+		},
+		"mentions by first name and full name": {
+			body:      "@ada and @SamSmith, not @Sam or @nobody",
+			expected:  `<span class="mention fw-semibold text-primary">@ada</span> and <span class="mention fw-semibold text-primary">@SamSmith</span>, not @Sam or @nobody`,
+			mentioned: []int{1, 2},
+		},
+		"names are run together": {
+			body:      "**@MaryJoOBrien** @MaryJo @ada @ada",
+			expected:  `<strong><span class="mention fw-semibold text-primary">@MaryJoOBrien</span></strong> <span class="mention fw-semibold text-primary">@MaryJo</span> <span class="mention fw-semibold text-primary">@ada</span> <span class="mention fw-semibold text-primary">@ada</span>`,
+			mentioned: []int{4, 1},
+		},
+		"emails aren't mentions": {
+			body:      "ada@ada.com `@ada`",
+			expected:  "ada@ada.com <code>@ada</code>",
+			mentioned: nil,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			rendered, mentioned := partymgmt.RenderComment(tc.body, members)
+			testhelpers.Equals(t, tc.expected, rendered)
+			testhelpers.Equals(t, tc.mentioned, mentioned)
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrInvalidHandoff  = errors.New("parties can only be handed to one of their other members")
+	ErrDepartureNotDue = errors.New("watcher's account is no longer due to be deleted")
+)
+
+// OwnedParty is a party the watcher owns and what happens to it when they leave
+type OwnedParty struct {
+	ID   int
+	Name string
+	// Members are the other members of the party, any of them can be handed it
+	Members []PartyMember
+	// HasHandoff is whether the owner has said what should happen to the party
+	HasHandoff bool
+	// IDNewOwner is who the party is handed to, it's 0 when it's going to be deleted
+	IDNewOwner int
+}
+
+// DepartureService looks after a watcher's parties when they leave for good, the parties they own are handed to
+// someone else in them or deleted
+type DepartureService struct {
+	db      *store.DeparturesRepository
+	parties store.PartyRepository
+}
+
+func NewDepartureService(db *store.DeparturesRepository, parties store.PartyRepository) DepartureService {
+	return DepartureService{db: db, parties: parties}
+}
+
+// GetOwnedParties returns the parties the watcher owns with the other members who could take them over
+func (s DepartureService) GetOwnedParties(ctx context.Context, idOwner int) ([]OwnedParty, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "DepartureService.GetOwnedParties")
+	defer span.End()
+
+	parties := make([]OwnedParty, 0)
+	err := s.db.GetOwnedParties(ctx, idOwner, func(res store.OwnedPartyResult) {
+		parties = append(parties, OwnedParty{
+			ID:         res.ID,
+			Name:       res.Name,
+			Members:    make([]PartyMember, 0),
+			HasHandoff: res.HasHandoff,
+			IDNewOwner: res.IDNewOwner,
+		})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return nil, err
+	}
+
+	for i := range parties {
+		err = s.parties.GetPartyMembers(ctx, parties[i].ID, func(res store.PartyMemberResult) {
+			if res.IDProfile == idOwner {
+				return
+			}
+			parties[i].Members = append(parties[i].Members, newPartyMember(res))
+		})
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			return nil, err
+		}
+	}
+
+	return parties, nil
+}
+
+// PlanHandoffs records what happens to the watcher's parties when they leave, newOwners is keyed by party and a new
+// owner of 0 deletes it. Parties left out go to the member who has been in them longest
+func (s DepartureService) PlanHandoffs(ctx context.Context, logger *slog.Logger, idOwner int, newOwners map[int]int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "DepartureService.PlanHandoffs")
+	defer span.End()
+
+	err := s.db.ReplaceHandoffs(ctx, idOwner, newOwners)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrInvalidHandoff
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to plan party handoffs", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// CancelHandoffs forgets what the watcher said should happen to their parties, they keep owning them
+func (s DepartureService) CancelHandoffs(ctx context.Context, logger *slog.Logger, idOwner int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "DepartureService.CancelHandoffs")
+	defer span.End()
+
+	err := s.db.DeleteHandoffs(ctx, idOwner)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to cancel party handoffs", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// RemoveWatcher hands over or deletes the parties the watcher owns and takes them out of the rest. What they added,
+// rated and said in their parties is left where it is. ErrDepartureNotDue is returned when their account isn't due to
+// be deleted at now any more
+func (s DepartureService) RemoveWatcher(ctx context.Context, logger *slog.Logger, idWatcher int, email string, now time.Time) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "DepartureService.RemoveWatcher")
+	defer span.End()
+
+	res, err := s.db.RemoveWatcher(ctx, idWatcher, email, now)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrDepartureNotDue
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to remove watcher from their parties", slog.Any("error", err))
+		return err
+	}
+
+	logger.InfoContext(ctx, "removed watcher from their parties", slog.Int64("partiesDeleted", res.PartiesDeleted), slog.Int64("partiesTransferred", res.PartiesTransferred))
+
+	return nil
+}
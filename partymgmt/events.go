@@ -0,0 +1,227 @@
+package partymgmt
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+// PartyEventType is something that happened in a party that members looking at it should see straight away
+type PartyEventType string
+
+const (
+	EventMovieAdded    PartyEventType = "movie_added"
+	EventMovieSelected PartyEventType = "movie_selected"
+	EventMovieWatched  PartyEventType = "movie_watched"
+	EventMemberJoined  PartyEventType = "member_joined"
+)
+
+// PartyEventTypes are every type of event that's published
+var PartyEventTypes = []PartyEventType{EventMovieAdded, EventMovieSelected, EventMovieWatched, EventMemberJoined}
+
+// subscriberBufferSize is how many events a subscriber can fall behind by before events are dropped for it
+const subscriberBufferSize = 16
+
+// listenRetryInterval is how long to wait before listening again when the connection to postgres is lost
+const listenRetryInterval = 5 * time.Second
+
+type PartyEvent struct {
+	IDParty int            `json:"id_party"`
+	Type    PartyEventType `json:"type"`
+	// IDWatcher is the member who did it
+	IDWatcher int      `json:"id_watcher"`
+	Watcher   FullName `json:"watcher"`
+	// IDMovie and MovieTitle are only set for events about a movie
+	IDMovie    int       `json:"id_movie,omitempty"`
+	MovieTitle string    `json:"movie_title,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Description is what happened, to be shown after who did it
+func (e PartyEvent) Description() string {
+	switch e.Type {
+	case EventMovieAdded:
+		return "added"
+	case EventMovieSelected:
+		return "picked"
+	case EventMovieWatched:
+		return "marked as watched"
+	case EventMemberJoined:
+		return "joined the party"
+	default:
+		return string(e.Type)
+	}
+}
+
+// PartyEventHandler is run for every event published
+type PartyEventHandler func(ctx context.Context, logger *slog.Logger, event PartyEvent)
+
+// EventBus hands party events to everyone subscribed to the party. Without a repository events only reach subscribers
+// of this instance of the app, with one they're sent through postgres and every instance delivers them to its own
+// subscribers once it hears them back
+type EventBus struct {
+	db *store.EventsRepository
+	// handlers run on the instance that published the event so each event is only handled once
+	handlers []PartyEventHandler
+
+	mu          sync.Mutex
+	subscribers map[int]map[chan PartyEvent]struct{}
+}
+
+func NewEventBus(db *store.EventsRepository) *EventBus {
+	return &EventBus{
+		db:          db,
+		subscribers: make(map[int]map[chan PartyEvent]struct{}),
+	}
+}
+
+// AddHandler runs the handler for every event published from now on, handlers have to be added before anything is
+// published
+func (b *EventBus) AddHandler(handler PartyEventHandler) {
+	b.handlers = append(b.handlers, handler)
+}
+
+// Subscribe returns a channel of the party's events, unsubscribe has to be called once they're no longer wanted
+func (b *EventBus) Subscribe(idParty int) (<-chan PartyEvent, func()) {
+	events := make(chan PartyEvent, subscriberBufferSize)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[idParty] == nil {
+		b.subscribers[idParty] = make(map[chan PartyEvent]struct{})
+	}
+	b.subscribers[idParty][events] = struct{}{}
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		delete(b.subscribers[idParty], events)
+		if len(b.subscribers[idParty]) == 0 {
+			delete(b.subscribers, idParty)
+		}
+	}
+
+	return events, unsubscribe
+}
+
+// Publish sends the event to the party's subscribers, it never fails the change the event is about so errors are only
+// logged. A nil bus drops every event
+func (b *EventBus) Publish(ctx context.Context, logger *slog.Logger, event PartyEvent) {
+	if b == nil {
+		return
+	}
+
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "EventBus.Publish")
+	defer span.End()
+
+	for _, handler := range b.handlers {
+		handler(ctx, logger, event)
+	}
+
+	if b.db == nil {
+		b.deliver(event)
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to marshal party event", slog.Any("error", err))
+		return
+	}
+
+	err = b.db.Notify(ctx, store.PartyEventsChannel, string(payload))
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to send party event, only delivering it locally", slog.Any("error", err))
+		b.deliver(event)
+	}
+}
+
+// StartListener delivers the events sent through postgres to this instance's subscribers until the context is
+// cancelled, it does nothing when the bus has no repository
+func (b *EventBus) StartListener(ctx context.Context, logger *slog.Logger) {
+	if b.db == nil {
+		return
+	}
+
+	go func() {
+		for {
+			err := b.db.Listen(ctx, store.PartyEventsChannel, func(payload string) {
+				var event PartyEvent
+				err := json.Unmarshal([]byte(payload), &event)
+				if err != nil {
+					logger.ErrorContext(ctx, "failed to unmarshal party event", slog.Any("error", err))
+					return
+				}
+				b.deliver(event)
+			})
+
+			if ctx.Err() != nil {
+				return
+			}
+
+			logger.ErrorContext(ctx, "stopped listening for party events, retrying", slog.Any("error", err))
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(listenRetryInterval):
+			}
+		}
+	}()
+}
+
+// deliver hands the event to each of the party's subscribers, a subscriber that's fallen too far behind misses it
+// rather than holding up everyone else
+func (b *EventBus) deliver(event PartyEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for events := range b.subscribers[event.IDParty] {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// SubscribeToEvents returns the events for the party as they happen, only members of the party can subscribe
+func (s PartyService) SubscribeToEvents(ctx context.Context, idParty, idWatcher int) (<-chan PartyEvent, func(), error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.SubscribeToEvents")
+	defer span.End()
+
+	err := checkPartyMembership(ctx, s.db, idParty, idWatcher)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	events, unsubscribe := s.events.Subscribe(idParty)
+	return events, unsubscribe, nil
+}
+
+// publishPartyEvent fills in who did it and the movie's title before publishing the event, the event is still
+// published without them if they can't be found
+func publishPartyEvent(ctx context.Context, logger *slog.Logger, db store.PartyRepository, events *EventBus, event PartyEvent) {
+	if events == nil {
+		return
+	}
+
+	details, err := db.GetPartyEventDetails(ctx, event.IDWatcher, event.IDMovie)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party event details", slog.Any("error", err))
+	}
+
+	event.Watcher = FullName{FirstName: details.FirstName, LastName: details.LastName}
+	event.MovieTitle = details.Title
+	event.OccurredAt = time.Now().UTC()
+
+	events.Publish(ctx, logger, event)
+}
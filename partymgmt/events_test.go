@@ -0,0 +1,81 @@
+package partymgmt_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestEventBus(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	bus := partymgmt.NewEventBus(nil)
+
+	events, unsubscribe := bus.Subscribe(1)
+	otherEvents, unsubscribeOther := bus.Subscribe(2)
+	defer unsubscribeOther()
+
+	event := partymgmt.PartyEvent{IDParty: 1, Type: partymgmt.EventMovieAdded, IDWatcher: 3, IDMovie: 4}
+	bus.Publish(ctx, logger, event)
+
+	testhelpers.Equals(t, event, <-events)
+	testhelpers.Equals(t, 0, len(otherEvents))
+
+	// a subscriber that isn't keeping up misses events instead of blocking whoever published them
+	for range 100 {
+		bus.Publish(ctx, logger, event)
+	}
+	testhelpers.Assert(t, len(events) < 100, "expected events to be dropped, got %d", len(events))
+
+	// nothing is delivered once they've unsubscribed
+	for len(events) > 0 {
+		<-events
+	}
+
+	unsubscribe()
+	bus.Publish(ctx, logger, event)
+	testhelpers.Equals(t, 0, len(events))
+}
+
+func TestEventBusHandlers(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	bus := partymgmt.NewEventBus(nil)
+
+	handled := make([]partymgmt.PartyEvent, 0)
+	bus.AddHandler(func(ctx context.Context, logger *slog.Logger, event partymgmt.PartyEvent) {
+		handled = append(handled, event)
+	})
+
+	// handlers run whether or not anyone is subscribed to the party
+	event := partymgmt.PartyEvent{IDParty: 1, Type: partymgmt.EventMovieSelected, IDWatcher: 3, IDMovie: 4}
+	bus.Publish(ctx, logger, event)
+
+	testhelpers.Equals(t, []partymgmt.PartyEvent{event}, handled)
+}
+
+func TestEventBusNil(t *testing.T) {
+	t.Parallel()
+
+	var bus *partymgmt.EventBus
+
+	// publishing without a bus does nothing
+	bus.Publish(context.Background(), slog.New(slog.NewTextHandler(io.Discard, nil)), partymgmt.PartyEvent{IDParty: 1, Type: partymgmt.EventMemberJoined})
+}
+
+func TestPartyEventDescription(t *testing.T) {
+	t.Parallel()
+
+	for _, eventType := range partymgmt.PartyEventTypes {
+		description := partymgmt.PartyEvent{Type: eventType}.Description()
+		testhelpers.Assert(t, description != string(eventType), "expected %s to be described", eventType)
+	}
+}
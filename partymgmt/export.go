@@ -0,0 +1,360 @@
+package partymgmt
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrUnknownExportFormat = errors.New("unknown export format")
+	ErrNotPartyMember      = errors.New("watcher is not a member of the party")
+)
+
+// ExportFormat is how exported movies are written out
+type ExportFormat string
+
+const (
+	ExportFormatCSV  ExportFormat = "csv"
+	ExportFormatJSON ExportFormat = "json"
+	// ExportFormatLetterboxd is a CSV that letterboxd's importer understands
+	ExportFormatLetterboxd ExportFormat = "letterboxd"
+)
+
+func ParseExportFormat(format string) (ExportFormat, error) {
+	switch f := ExportFormat(strings.ToLower(format)); f {
+	case ExportFormatCSV, ExportFormatJSON, ExportFormatLetterboxd:
+		return f, nil
+	case "":
+		return ExportFormatCSV, nil
+	default:
+		return "", fmt.Errorf("%w: %q", ErrUnknownExportFormat, format)
+	}
+}
+
+func (f ExportFormat) ContentType() string {
+	if f == ExportFormatJSON {
+		return "application/json"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Filename is the name to download an export called name as
+func (f ExportFormat) Filename(name string) string {
+	switch f {
+	case ExportFormatJSON:
+		return name + ".json"
+	case ExportFormatLetterboxd:
+		return name + "-letterboxd.csv"
+	default:
+		return name + ".csv"
+	}
+}
+
+type ExportedMovie struct {
+	TMDBID      int
+	Title       string
+	ReleaseDate *time.Time
+	Status      store.WatchStatusEnum
+	AddedBy     string
+	AddedAt     time.Time
+	WatchDate   *time.Time
+	PartyName   string
+}
+
+// Year is the year the movie was released, or 0 when TMDB doesn't know
+func (m ExportedMovie) Year() int {
+	if m.ReleaseDate == nil {
+		return 0
+	}
+	return m.ReleaseDate.Year()
+}
+
+var (
+	csvExportHeader        = []string{"Title", "Year", "Release Date", "TMDB ID", "Status", "Added By", "Added On", "Watch Date", "Party"}
+	letterboxdExportHeader = []string{"tmdbID", "Title", "Year", "WatchedDate"}
+)
+
+const exportDateFormat = "2006-01-02"
+
+type exportedMovieJSON struct {
+	TMDBID      int        `json:"tmdb_id"`
+	Title       string     `json:"title"`
+	Year        int        `json:"year,omitempty"`
+	ReleaseDate string     `json:"release_date,omitempty"`
+	Status      string     `json:"status"`
+	AddedBy     string     `json:"added_by,omitempty"`
+	AddedAt     time.Time  `json:"added_at"`
+	WatchDate   *time.Time `json:"watch_date,omitempty"`
+	Party       string     `json:"party"`
+}
+
+// MovieExporter writes movies one at a time so an export never has to be held in memory, Close must be called once
+// every movie has been written to finish the file
+type MovieExporter struct {
+	format  ExportFormat
+	w       io.Writer
+	csv     *csv.Writer
+	written int
+}
+
+func NewMovieExporter(format ExportFormat, w io.Writer) (*MovieExporter, error) {
+	e := &MovieExporter{format: format, w: w}
+
+	switch format {
+	case ExportFormatJSON:
+		_, err := io.WriteString(w, "[")
+		if err != nil {
+			return nil, err
+		}
+	case ExportFormatCSV:
+		e.csv = csv.NewWriter(w)
+		err := e.csv.Write(csvExportHeader)
+		if err != nil {
+			return nil, err
+		}
+	case ExportFormatLetterboxd:
+		e.csv = csv.NewWriter(w)
+		err := e.csv.Write(letterboxdExportHeader)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownExportFormat, format)
+	}
+
+	return e, nil
+}
+
+func (e *MovieExporter) Write(movie ExportedMovie) error {
+	defer func() { e.written++ }()
+
+	switch e.format {
+	case ExportFormatJSON:
+		return e.writeJSON(movie)
+	case ExportFormatLetterboxd:
+		return e.csv.Write([]string{
+			strconv.Itoa(movie.TMDBID),
+			movie.Title,
+			formatYear(movie.Year()),
+			formatExportDate(movie.WatchDate),
+		})
+	default:
+		return e.csv.Write([]string{
+			movie.Title,
+			formatYear(movie.Year()),
+			formatExportDate(movie.ReleaseDate),
+			strconv.Itoa(movie.TMDBID),
+			string(movie.Status),
+			escapeCSVFormula(movie.AddedBy),
+			movie.AddedAt.Format(exportDateFormat),
+			formatExportDate(movie.WatchDate),
+			escapeCSVFormula(movie.PartyName),
+		})
+	}
+}
+
+func (e *MovieExporter) writeJSON(movie ExportedMovie) error {
+	if e.written > 0 {
+		_, err := io.WriteString(e.w, ",")
+		if err != nil {
+			return err
+		}
+	}
+
+	b, err := json.Marshal(exportedMovieJSON{
+		TMDBID:      movie.TMDBID,
+		Title:       movie.Title,
+		Year:        movie.Year(),
+		ReleaseDate: formatExportDate(movie.ReleaseDate),
+		Status:      string(movie.Status),
+		AddedBy:     movie.AddedBy,
+		AddedAt:     movie.AddedAt,
+		WatchDate:   movie.WatchDate,
+		Party:       movie.PartyName,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(e.w, "\n  %s", b)
+	return err
+}
+
+func (e *MovieExporter) Close() error {
+	if e.csv != nil {
+		e.csv.Flush()
+		return e.csv.Error()
+	}
+
+	closing := "]\n"
+	if e.written > 0 {
+		closing = "\n]\n"
+	}
+
+	_, err := io.WriteString(e.w, closing)
+	return err
+}
+
+func formatYear(year int) string {
+	if year == 0 {
+		return ""
+	}
+	return strconv.Itoa(year)
+}
+
+func formatExportDate(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(exportDateFormat)
+}
+
+// escapeCSVFormula stops names people typed in from being run as formulas when the export is opened in a spreadsheet
+func escapeCSVFormula(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+type ExportService struct {
+	db *store.ExportsRepository
+}
+
+func NewExportService(db *store.ExportsRepository) ExportService {
+	return ExportService{db: db}
+}
+
+// CanExportParty returns ErrNotPartyMember unless the watcher is in the party
+func (s ExportService) CanExportParty(ctx context.Context, idParty, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ExportService.CanExportParty")
+	defer span.End()
+
+	isMember, err := s.db.IsPartyMember(ctx, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if !isMember {
+		return ErrNotPartyMember
+	}
+
+	return nil
+}
+
+// ExportPartyMovies writes every movie in the party to w, in the order they were added
+func (s ExportService) ExportPartyMovies(ctx context.Context, logger *slog.Logger, idParty int, format ExportFormat, w io.Writer) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "ExportService.ExportPartyMovies")
+	defer span.End()
+
+	err := s.export(ctx, format, w, func(assignFn func(store.ExportedMovieResult) error) error {
+		return s.db.StreamPartyMovies(ctx, idParty, assignFn)
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to export party movies", slog.Any("error", err), slog.Int("idParty", idParty))
+		return err
+	}
+
+	return nil
+}
+
+// ExportWatchHistory writes every movie the watcher's parties have watched to w, newest first
+func (s ExportService) ExportWatchHistory(ctx context.Context, logger *slog.Logger, idWatcher int, format ExportFormat, w io.Writer) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "ExportService.ExportWatchHistory")
+	defer span.End()
+
+	err := s.export(ctx, format, w, func(assignFn func(store.ExportedMovieResult) error) error {
+		return s.db.StreamWatchHistory(ctx, idWatcher, assignFn)
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to export watch history", slog.Any("error", err), slog.Int("idWatcher", idWatcher))
+		return err
+	}
+
+	return nil
+}
+
+// ExportMoviesAddedBy writes every movie the watcher has added to any party to w
+func (s ExportService) ExportMoviesAddedBy(ctx context.Context, logger *slog.Logger, idWatcher int, format ExportFormat, w io.Writer) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "ExportService.ExportMoviesAddedBy")
+	defer span.End()
+
+	err := s.export(ctx, format, w, func(assignFn func(store.ExportedMovieResult) error) error {
+		return s.db.StreamMoviesAddedBy(ctx, idWatcher, assignFn)
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to export added movies", slog.Any("error", err), slog.Int("idWatcher", idWatcher))
+		return err
+	}
+
+	return nil
+}
+
+func (s ExportService) export(ctx context.Context, format ExportFormat, w io.Writer, stream func(func(store.ExportedMovieResult) error) error) error {
+	exporter, err := NewMovieExporter(format, w)
+	if err != nil {
+		return err
+	}
+
+	err = stream(func(res store.ExportedMovieResult) error {
+		return exporter.Write(ExportedMovie{
+			TMDBID:      res.TMDBID,
+			Title:       res.Title,
+			ReleaseDate: res.ReleaseDate,
+			Status:      res.WatchStatus,
+			AddedBy:     strings.TrimSpace(res.AddedByFirstName + " " + res.AddedByLastName),
+			AddedAt:     res.AddedAt,
+			WatchDate:   res.WatchDate,
+			PartyName:   res.PartyName,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	return exporter.Close()
+}
+
+// Membership is a party a watcher has joined or been invited to
+type Membership struct {
+	IDParty   int       `json:"-"`
+	PartyName string    `json:"party"`
+	Kind      string    `json:"kind"`
+	Since     time.Time `json:"since"`
+	IsOwner   bool      `json:"is_owner"`
+}
+
+func (s ExportService) GetMemberships(ctx context.Context, idWatcher int) ([]Membership, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ExportService.GetMemberships")
+	defer span.End()
+
+	memberships := make([]Membership, 0)
+	err := s.db.GetMembershipsForWatcher(ctx, idWatcher, func(idParty int, name, kind string, since time.Time, isOwner bool) {
+		memberships = append(memberships, Membership{
+			IDParty:   idParty,
+			PartyName: name,
+			Kind:      kind,
+			Since:     since,
+			IsOwner:   isOwner,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return memberships, nil
+}
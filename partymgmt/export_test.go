@@ -0,0 +1,130 @@
+package partymgmt_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestParseExportFormat(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		format         string
+		expectedFormat partymgmt.ExportFormat
+		expectedError  error
+	}{
+		"csv":             {format: "csv", expectedFormat: partymgmt.ExportFormatCSV},
+		"json":            {format: "JSON", expectedFormat: partymgmt.ExportFormatJSON},
+		"letterboxd":      {format: "letterboxd", expectedFormat: partymgmt.ExportFormatLetterboxd},
+		"defaults to csv": {format: "", expectedFormat: partymgmt.ExportFormatCSV},
+		"unknown":         {format: "xml", expectedError: partymgmt.ErrUnknownExportFormat},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			format, err := partymgmt.ParseExportFormat(tc.format)
+			testhelpers.Assert(t, errors.Is(err, tc.expectedError), "expected %v, got %v", tc.expectedError, err)
+			testhelpers.Equals(t, tc.expectedFormat, format)
+		})
+	}
+}
+
+func TestMovieExporter(t *testing.T) {
+	t.Parallel()
+
+	releaseDate := time.Date(1999, 3, 31, 0, 0, 0, 0, time.UTC)
+	watchDate := time.Date(2024, 1, 2, 20, 0, 0, 0, time.UTC)
+	movies := []partymgmt.ExportedMovie{
+		{
+			TMDBID:      603,
+			Title:       "The Matrix",
+			ReleaseDate: &releaseDate,
+			Status:      store.WatchStatusWatched,
+			AddedBy:     "Jane Doe",
+			AddedAt:     time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC),
+			WatchDate:   &watchDate,
+			PartyName:   "=Movie Night",
+		},
+		{
+			TMDBID:    1234567,
+			Title:     "Crouching Tiger, Hidden Dragon",
+			Status:    store.WatchStatusUnwatched,
+			AddedAt:   time.Date(2023, 12, 2, 0, 0, 0, 0, time.UTC),
+			PartyName: "Movie Night",
+		},
+	}
+
+	testCases := map[string]struct {
+		format   partymgmt.ExportFormat
+		expected string
+	}{
+		"csv": {
+			format: partymgmt.ExportFormatCSV,
+			expected: "Title,Year,Release Date,TMDB ID,Status,Added By,Added On,Watch Date,Party\n" +
+				"The Matrix,1999,1999-03-31,603,watched,Jane Doe,2023-12-01,2024-01-02,'=Movie Night\n" +
+				"\"Crouching Tiger, Hidden Dragon\",,,1234567,unwatched,,2023-12-02,,Movie Night\n",
+		},
+		"letterboxd": {
+			format: partymgmt.ExportFormatLetterboxd,
+			expected: "tmdbID,Title,Year,WatchedDate\n" +
+				"603,The Matrix,1999,2024-01-02\n" +
+				"1234567,\"Crouching Tiger, Hidden Dragon\",,\n",
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var b strings.Builder
+			exporter, err := partymgmt.NewMovieExporter(tc.format, &b)
+			testhelpers.Ok(t, err, "failed to create exporter")
+			for _, movie := range movies {
+				testhelpers.Ok(t, exporter.Write(movie), "failed to write movie")
+			}
+			testhelpers.Ok(t, exporter.Close(), "failed to close exporter")
+			testhelpers.Equals(t, tc.expected, b.String())
+		})
+	}
+
+	t.Run("json", func(t *testing.T) {
+		t.Parallel()
+		var b strings.Builder
+		exporter, err := partymgmt.NewMovieExporter(partymgmt.ExportFormatJSON, &b)
+		testhelpers.Ok(t, err, "failed to create exporter")
+		for _, movie := range movies {
+			testhelpers.Ok(t, exporter.Write(movie), "failed to write movie")
+		}
+		testhelpers.Ok(t, exporter.Close(), "failed to close exporter")
+
+		var exported []struct {
+			Title     string     `json:"title"`
+			Year      int        `json:"year"`
+			Party     string     `json:"party"`
+			WatchDate *time.Time `json:"watch_date"`
+		}
+		testhelpers.Ok(t, json.Unmarshal([]byte(b.String()), &exported), "export isn't valid json")
+		testhelpers.Equals(t, 2, len(exported))
+		testhelpers.Equals(t, "The Matrix", exported[0].Title)
+		testhelpers.Equals(t, 1999, exported[0].Year)
+		testhelpers.Equals(t, "=Movie Night", exported[0].Party)
+		testhelpers.Assert(t, exported[0].WatchDate.Equal(watchDate), "expected watch date %v, got %v", watchDate, exported[0].WatchDate)
+		testhelpers.Assert(t, exported[1].WatchDate == nil, "expected unwatched movie to not have a watch date")
+	})
+
+	t.Run("empty json", func(t *testing.T) {
+		t.Parallel()
+		var b strings.Builder
+		exporter, err := partymgmt.NewMovieExporter(partymgmt.ExportFormatJSON, &b)
+		testhelpers.Ok(t, err, "failed to create exporter")
+		testhelpers.Ok(t, exporter.Close(), "failed to close exporter")
+		testhelpers.Equals(t, "[]\n", b.String())
+	})
+}
@@ -0,0 +1,298 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrFriendNotFound        = errors.New("friend not found")
+	ErrFriendRequestNotFound = errors.New("friend request not found")
+	ErrCannotFriendSelf      = errors.New("you can't send yourself a friend request")
+	ErrAlreadyFriends        = errors.New("you're already friends")
+	ErrFriendRequestPending  = errors.New("your friend request is still waiting on them")
+)
+
+// inviteSuggestionsLimit is the most contacts suggested at once when inviting someone to a party
+const inviteSuggestionsLimit = 8
+
+// SharedParty is a party the watcher and one of their contacts are both in
+type SharedParty struct {
+	ID   int
+	Name string
+}
+
+// Contact is someone the watcher knows
+type Contact struct {
+	// ID is the contact's watcher id
+	ID   int
+	Name FullName
+	// SharedParties are the parties the watcher is in with them, ordered by name
+	SharedParties []SharedParty
+}
+
+func (c Contact) DisplayName() string {
+	return c.Name.FirstName + " " + c.Name.LastName
+}
+
+// Friend is a contact who has accepted a friend request from the watcher or sent one the watcher accepted
+type Friend struct {
+	Contact
+	IDFriendship int
+	Since        time.Time
+}
+
+// FriendRequest is a friend request that hasn't been answered yet
+type FriendRequest struct {
+	Contact
+	IDFriendship int
+	SentAt       time.Time
+}
+
+// Friends is everyone the watcher is connected to
+type Friends struct {
+	Friends  []Friend
+	Incoming []FriendRequest
+	Outgoing []FriendRequest
+	// Suggested are the people the watcher shares a party with who they haven't sent or been sent a request by
+	Suggested []Contact
+}
+
+// InviteSuggestion is a contact who could be invited to a party
+type InviteSuggestion struct {
+	ID       int
+	Name     FullName
+	IsFriend bool
+}
+
+func (s InviteSuggestion) DisplayName() string {
+	return s.Name.FirstName + " " + s.Name.LastName
+}
+
+type FriendsService struct {
+	db      *store.FriendsRepository
+	partyDB store.PartyRepository
+}
+
+func NewFriendsService(db *store.FriendsRepository, partyDB store.PartyRepository) FriendsService {
+	return FriendsService{db: db, partyDB: partyDB}
+}
+
+// GetFriends returns the watcher's friends, the requests waiting on them or someone else, and everyone they share a
+// party with who they could send a request to
+func (s FriendsService) GetFriends(ctx context.Context, idWatcher int) (Friends, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsService.GetFriends")
+	defer span.End()
+
+	sharedParties := make(map[int][]SharedParty)
+	var shared []Contact
+	err := s.db.GetSharedParties(ctx, idWatcher, func(res store.SharedPartyResult) {
+		if _, ok := sharedParties[res.IDMember]; !ok {
+			shared = append(shared, Contact{
+				ID:   res.IDMember,
+				Name: FullName{FirstName: res.FirstName, LastName: res.LastName},
+			})
+		}
+		sharedParties[res.IDMember] = append(sharedParties[res.IDMember], SharedParty{ID: res.IDParty, Name: res.PartyName})
+	})
+	if err != nil {
+		return Friends{}, err
+	}
+
+	friends := Friends{
+		Friends:   make([]Friend, 0),
+		Incoming:  make([]FriendRequest, 0),
+		Outgoing:  make([]FriendRequest, 0),
+		Suggested: make([]Contact, 0),
+	}
+	connected := make(map[int]bool)
+	err = s.db.GetFriendships(ctx, idWatcher, func(res store.FriendshipResult) {
+		connected[res.IDFriend] = true
+		contact := Contact{
+			ID:            res.IDFriend,
+			Name:          FullName{FirstName: res.FirstName, LastName: res.LastName},
+			SharedParties: sharedParties[res.IDFriend],
+		}
+
+		switch {
+		case res.Status == store.FriendshipStatusAccepted:
+			since := res.CreatedAt
+			if res.RespondedAt != nil {
+				since = *res.RespondedAt
+			}
+			friends.Friends = append(friends.Friends, Friend{Contact: contact, IDFriendship: res.ID, Since: since})
+		case res.IDRequester == idWatcher:
+			friends.Outgoing = append(friends.Outgoing, FriendRequest{Contact: contact, IDFriendship: res.ID, SentAt: res.CreatedAt})
+		default:
+			friends.Incoming = append(friends.Incoming, FriendRequest{Contact: contact, IDFriendship: res.ID, SentAt: res.CreatedAt})
+		}
+	})
+	if err != nil {
+		return Friends{}, err
+	}
+
+	for _, contact := range shared {
+		if connected[contact.ID] {
+			continue
+		}
+		contact.SharedParties = sharedParties[contact.ID]
+		friends.Suggested = append(friends.Suggested, contact)
+	}
+
+	return friends, nil
+}
+
+// SendFriendRequest asks the other watcher to be the watcher's friend, if they've already asked the watcher their
+// request is accepted instead
+func (s FriendsService) SendFriendRequest(ctx context.Context, logger *slog.Logger, idWatcher, idOther int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "FriendsService.SendFriendRequest")
+	defer span.End()
+
+	if idWatcher == idOther {
+		return ErrCannotFriendSelf
+	}
+
+	existing, err := s.db.GetFriendshipBetween(ctx, idWatcher, idOther)
+	switch {
+	case errors.Is(err, store.ErrNoRecord):
+		// nobody has asked yet
+	case err != nil:
+		return err
+	case existing.Status == store.FriendshipStatusAccepted:
+		return ErrAlreadyFriends
+	case existing.IDRequester == idWatcher:
+		return ErrFriendRequestPending
+	default:
+		return s.AcceptFriendRequest(ctx, logger, idWatcher, existing.ID)
+	}
+
+	_, err = s.db.CreateFriendRequest(ctx, idWatcher, idOther)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrWatcherNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to create friend request", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// SendFriendRequestByEmail asks whoever has the email to be the watcher's friend. Like invites, nothing is sent when
+// nobody has the email but it isn't an error, so the form can't be used to find out who has an account
+func (s FriendsService) SendFriendRequestByEmail(ctx context.Context, logger *slog.Logger, watcherService WatcherService, idWatcher int, email string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsService.SendFriendRequestByEmail")
+	defer span.End()
+
+	other, err := watcherService.GetWatcherByEmail(ctx, strings.TrimSpace(email))
+	if errors.Is(err, ErrWatcherNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = s.SendFriendRequest(ctx, logger, idWatcher, other.ID)
+	if errors.Is(err, ErrWatcherNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// AcceptFriendRequest accepts a request that was sent to the watcher
+func (s FriendsService) AcceptFriendRequest(ctx context.Context, logger *slog.Logger, idWatcher, idFriendship int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "FriendsService.AcceptFriendRequest")
+	defer span.End()
+
+	err := s.db.AcceptFriendRequest(ctx, idFriendship, idWatcher)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrFriendRequestNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to accept friend request", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// RemoveFriend removes a friend, declines a request sent to the watcher or cancels one they sent
+func (s FriendsService) RemoveFriend(ctx context.Context, logger *slog.Logger, idWatcher, idFriendship int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "FriendsService.RemoveFriend")
+	defer span.End()
+
+	err := s.db.DeleteFriendship(ctx, idFriendship, idWatcher)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrFriendNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to remove friend", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// GetInviteSuggestions returns the watcher's friends and the people they share a party with whose names match the
+// search and who aren't in or invited to the party yet, friends come first
+func (s FriendsService) GetInviteSuggestions(ctx context.Context, idParty, idWatcher int, search string) ([]InviteSuggestion, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsService.GetInviteSuggestions")
+	defer span.End()
+
+	err := checkPartyMembership(ctx, s.partyDB, idParty, idWatcher)
+	if err != nil {
+		return nil, err
+	}
+
+	suggestions := make([]InviteSuggestion, 0)
+	err = s.db.SearchContacts(ctx, store.ContactSearchParams{
+		IDWatcher: idWatcher,
+		IDParty:   idParty,
+		Search:    strings.TrimSpace(search),
+		Limit:     inviteSuggestionsLimit,
+	}, func(res store.ContactResult) {
+		suggestions = append(suggestions, InviteSuggestion{
+			ID:       res.ID,
+			Name:     FullName{FirstName: res.FirstName, LastName: res.LastName},
+			IsFriend: res.IsFriend,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
+
+// GetContactEmail returns the email to invite one of the watcher's contacts with, it's never shown so watchers can
+// only invite the people they know without learning their email
+func (s FriendsService) GetContactEmail(ctx context.Context, idWatcher, idContact int) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsService.GetContactEmail")
+	defer span.End()
+
+	email, err := s.db.GetContactEmail(ctx, idWatcher, idContact)
+	if errors.Is(err, store.ErrNoRecord) {
+		return "", fmt.Errorf("%w: %d", ErrFriendNotFound, idContact)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return email, nil
+}
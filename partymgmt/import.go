@@ -0,0 +1,603 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrImportNotFound         = errors.New("import not found")
+	ErrImportAlreadyConfirmed = errors.New("import has already been confirmed")
+	ErrInvalidImportSelection = errors.New("selected movie doesn't match the import")
+)
+
+const (
+	// maxConcurrentImportMatches limits how many rows are looked up on TMDB at once while building a preview
+	maxConcurrentImportMatches = 4
+
+	// maxImportCandidates is how many movies are offered to pick from when a row is ambiguous
+	maxImportCandidates = 5
+
+	// recentImportWindow is how long a finished import keeps showing on the party page
+	recentImportWindow = time.Hour
+
+	// stalledImportTimeout is how long a running import can go without progress before another worker picks it up
+	stalledImportTimeout = 10 * time.Minute
+)
+
+type ImportService struct {
+	db         *store.ImportsRepository
+	tmdbClient movieFetcher
+	movies     *MovieService
+	parties    PartyService
+	// wake lets a newly confirmed import start without waiting for the worker's next poll
+	wake chan struct{}
+}
+
+func NewImportService(client *TMDBClient, movieService *MovieService, partyService PartyService, importsRepository *store.ImportsRepository) *ImportService {
+	return &ImportService{
+		db:         importsRepository,
+		tmdbClient: client,
+		movies:     movieService,
+		parties:    partyService,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// ImportCandidate is a movie on TMDB that a row in an import might be
+type ImportCandidate struct {
+	TMDBID      int
+	Title       string
+	ReleaseDate string
+	PosterURL   string
+}
+
+// ImportMatch is a row from an imported file along with the movie it matched on TMDB
+type ImportMatch struct {
+	Row         ImportRow
+	MatchStatus store.ImportMatchStatusEnum
+	// TMDBID is the matched movie, or for a confirmed import the movie that was picked
+	TMDBID int
+	// Candidates are the movies to pick from when the row is ambiguous
+	Candidates []ImportCandidate
+	Status     store.ImportRowStatusEnum
+	Error      string
+}
+
+type Import struct {
+	ID            int
+	IDParty       int
+	IDWatcher     int
+	Source        ImportSource
+	Filename      string
+	Status        store.ImportStatusEnum
+	TotalRows     int
+	ProcessedRows int
+	AddedRows     int
+	SkippedRows   int
+	DuplicateRows int
+	FailedRows    int
+	Error         string
+	CreatedAt     time.Time
+	FinishedAt    *time.Time
+	Matches       []ImportMatch
+}
+
+func (i Import) IsPreviewing() bool {
+	return i.Status == store.ImportStatusPreviewing
+}
+
+func (i Import) IsFinished() bool {
+	return i.Status == store.ImportStatusCompleted || i.Status == store.ImportStatusFailed
+}
+
+// PercentComplete is how much of a confirmed import has been added to the party
+func (i Import) PercentComplete() int {
+	if i.IsFinished() || i.TotalRows == 0 {
+		return 100
+	}
+	return i.ProcessedRows * 100 / i.TotalRows
+}
+
+// MatchesWithStatus returns the rows of the import that matched with the status, used to group the preview
+func (i Import) MatchesWithStatus(status store.ImportMatchStatusEnum) []ImportMatch {
+	matches := make([]ImportMatch, 0)
+	for _, match := range i.Matches {
+		if match.MatchStatus == status {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// PreviewImport reads the movies from an uploaded file and matches each one against TMDB, nothing is added to the
+// party until the import is confirmed with ConfirmImport
+func (s *ImportService) PreviewImport(ctx context.Context, logger *slog.Logger, idParty, idWatcher int, filename string, file io.Reader) (int, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "ImportService.PreviewImport")
+	defer span.End()
+
+	rows, source, err := ParseWatchlist(file)
+	if err != nil {
+		return 0, err
+	}
+
+	matches := s.MatchRows(ctx, logger, rows)
+
+	params := store.CreateImportParams{
+		IDParty:   idParty,
+		IDProfile: idWatcher,
+		Source:    string(source),
+		Filename:  filename,
+		Rows:      make([]store.CreateImportRowParams, 0, len(matches)),
+	}
+
+	for _, match := range matches {
+		row := store.CreateImportRowParams{
+			Line:        match.Row.Line,
+			Title:       match.Row.Title,
+			IMDbID:      match.Row.IMDbID,
+			MatchStatus: match.MatchStatus,
+			Candidates:  make([]store.ImportCandidate, 0, len(match.Candidates)),
+			Error:       match.Error,
+		}
+
+		if match.Row.Year > 0 {
+			row.Year = &match.Row.Year
+		}
+
+		if match.TMDBID > 0 {
+			row.TMDBID = &match.TMDBID
+		}
+
+		for _, candidate := range match.Candidates {
+			row.Candidates = append(row.Candidates, store.ImportCandidate{
+				TMDBID:      candidate.TMDBID,
+				Title:       candidate.Title,
+				ReleaseDate: candidate.ReleaseDate,
+				PosterURL:   candidate.PosterURL,
+			})
+		}
+
+		params.Rows = append(params.Rows, row)
+	}
+
+	id, err := s.db.CreateImport(ctx, params)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return 0, err
+	}
+
+	logger.InfoContext(ctx, "created import preview", slog.Int("importID", id), slog.Int("rows", len(rows)), slog.String("source", string(source)))
+
+	return id, nil
+}
+
+// MatchRows looks up each row on TMDB, first by IMDb ID when the row has one and then by title and year
+func (s *ImportService) MatchRows(ctx context.Context, logger *slog.Logger, rows []ImportRow) []ImportMatch {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportService.MatchRows")
+	defer span.End()
+
+	matches := make([]ImportMatch, len(rows))
+
+	var (
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxConcurrentImportMatches)
+	)
+
+	for idx, row := range rows {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			match, err := s.matchRow(ctx, row)
+			if err != nil {
+				logger.ErrorContext(ctx, "Failed to match import row", slog.Any("err", err), slog.Int("line", row.Line))
+				match = ImportMatch{Row: row, MatchStatus: store.ImportMatchStatusFailed, Error: "Couldn't reach TMDB to look this movie up"}
+			}
+			matches[idx] = match
+		}()
+	}
+
+	wg.Wait()
+
+	return matches
+}
+
+func (s *ImportService) matchRow(ctx context.Context, row ImportRow) (ImportMatch, error) {
+	match := ImportMatch{Row: row, Status: store.ImportRowStatusPending}
+
+	if row.IMDbID != "" {
+		movies, err := s.tmdbClient.FindByIMDbID(ctx, row.IMDbID)
+		if err != nil {
+			return ImportMatch{}, err
+		}
+
+		if len(movies) > 0 {
+			return resolveMatch(match, movies), nil
+		}
+	}
+
+	if row.Title == "" {
+		match.MatchStatus = store.ImportMatchStatusNotFound
+		return match, nil
+	}
+
+	movies, err := s.tmdbClient.SearchByYear(ctx, row.Title, row.Year, DefaultLanguage)
+	if err != nil {
+		return ImportMatch{}, err
+	}
+
+	// exports don't always agree with TMDB on the release year, so look a year either side before giving up
+	if len(movies) == 0 && row.Year > 0 {
+		movies, err = s.tmdbClient.SearchByYear(ctx, row.Title, 0, DefaultLanguage)
+		if err != nil {
+			return ImportMatch{}, err
+		}
+		movies = releasedAround(movies, row.Year)
+	}
+
+	if len(movies) == 0 {
+		match.MatchStatus = store.ImportMatchStatusNotFound
+		return match, nil
+	}
+
+	exact := make([]TMDBMovie, 0)
+	for _, movie := range movies {
+		if normalizeTitle(movie.Title) == normalizeTitle(row.Title) {
+			exact = append(exact, movie)
+		}
+	}
+
+	// a search result that isn't an exact title match could be the wrong movie so it's left for someone to confirm
+	if len(exact) == 1 {
+		return resolveMatch(match, exact), nil
+	}
+
+	if len(exact) > 1 {
+		movies = exact
+	}
+
+	match.MatchStatus = store.ImportMatchStatusAmbiguous
+	match.Candidates = toImportCandidates(movies[:min(len(movies), maxImportCandidates)])
+	return match, nil
+}
+
+// resolveMatch matches a row when there's only one movie, otherwise the movies become the candidates to pick from
+func resolveMatch(match ImportMatch, movies []TMDBMovie) ImportMatch {
+	match.Candidates = toImportCandidates(movies[:min(len(movies), maxImportCandidates)])
+	if len(movies) == 1 {
+		match.MatchStatus = store.ImportMatchStatusMatched
+		match.TMDBID = movies[0].TMDBID
+		return match
+	}
+
+	match.MatchStatus = store.ImportMatchStatusAmbiguous
+	return match
+}
+
+func toImportCandidates(movies []TMDBMovie) []ImportCandidate {
+	candidates := make([]ImportCandidate, 0, len(movies))
+	for _, movie := range movies {
+		candidates = append(candidates, ImportCandidate{
+			TMDBID:      movie.TMDBID,
+			Title:       movie.Title,
+			ReleaseDate: movie.ReleaseDate,
+			PosterURL:   posterURL(movie.PosterURL),
+		})
+	}
+	return candidates
+}
+
+func releasedAround(movies []TMDBMovie, year int) []TMDBMovie {
+	filtered := make([]TMDBMovie, 0, len(movies))
+	for _, movie := range movies {
+		for _, y := range []int{year - 1, year + 1} {
+			if strings.HasPrefix(movie.ReleaseDate, fmt.Sprintf("%d-", y)) {
+				filtered = append(filtered, movie)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// normalizeTitle drops case and punctuation so titles like "Spider-Man: No Way Home" and "spider man no way home" match
+func normalizeTitle(title string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(title) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// GetImport returns an import along with every row in it
+func (s *ImportService) GetImport(ctx context.Context, idImport int) (Import, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "ImportService.GetImport")
+	defer span.End()
+
+	var imp Import
+	err := s.db.GetImport(ctx, idImport, func(res *store.ImportResult) {
+		imp = importFromResult(res)
+	})
+	if errors.Is(err, store.ErrNoRecord) {
+		return Import{}, fmt.Errorf("%w: %s", ErrImportNotFound, err)
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return Import{}, err
+	}
+
+	err = s.db.GetImportRows(ctx, idImport, func(res store.ImportRowResult) {
+		match := ImportMatch{
+			Row: ImportRow{
+				Line:   res.Line,
+				Title:  res.Title,
+				IMDbID: res.IMDbID,
+			},
+			MatchStatus: res.MatchStatus,
+			Candidates:  make([]ImportCandidate, 0, len(res.Candidates)),
+			Status:      res.Status,
+			Error:       res.Error,
+		}
+
+		if res.Year != nil {
+			match.Row.Year = *res.Year
+		}
+
+		if res.TMDBID != nil {
+			match.TMDBID = *res.TMDBID
+		}
+
+		for _, candidate := range res.Candidates {
+			match.Candidates = append(match.Candidates, ImportCandidate{
+				TMDBID:      candidate.TMDBID,
+				Title:       candidate.Title,
+				ReleaseDate: candidate.ReleaseDate,
+				PosterURL:   candidate.PosterURL,
+			})
+		}
+
+		imp.Matches = append(imp.Matches, match)
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return Import{}, err
+	}
+
+	return imp, nil
+}
+
+// GetRecentImports returns the imports for a party that are still running or finished recently
+func (s *ImportService) GetRecentImports(ctx context.Context, idParty int) ([]Import, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportService.GetRecentImports")
+	defer span.End()
+
+	imports := make([]Import, 0)
+	err := s.db.GetRecentImportsForParty(ctx, idParty, time.Now().UTC().Add(-recentImportWindow), func(res *store.ImportResult) {
+		imports = append(imports, importFromResult(res))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return imports, nil
+}
+
+func importFromResult(res *store.ImportResult) Import {
+	return Import{
+		ID:            res.ID,
+		IDParty:       res.IDParty,
+		IDWatcher:     res.IDProfile,
+		Source:        ImportSource(res.Source),
+		Filename:      res.Filename,
+		Status:        res.Status,
+		TotalRows:     res.TotalRows,
+		ProcessedRows: res.ProcessedRows,
+		AddedRows:     res.AddedRows,
+		SkippedRows:   res.SkippedRows,
+		DuplicateRows: res.DuplicateRows,
+		FailedRows:    res.FailedRows,
+		Error:         res.Error,
+		CreatedAt:     res.CreatedAt,
+		FinishedAt:    res.FinishedAt,
+	}
+}
+
+// ConfirmImport queues an import to be added to the party. selections maps the line of each row to import to the
+// movie picked for it, a matched row can only be imported as the movie it matched and an ambiguous row as one of its
+// candidates. Rows that aren't selected are skipped.
+func (s *ImportService) ConfirmImport(ctx context.Context, logger *slog.Logger, imp Import, selections map[int]int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "ImportService.ConfirmImport")
+	defer span.End()
+
+	if !imp.IsPreviewing() {
+		return ErrImportAlreadyConfirmed
+	}
+
+	byLine := make(map[int]ImportMatch, len(imp.Matches))
+	for _, match := range imp.Matches {
+		byLine[match.Row.Line] = match
+	}
+
+	for line, tmdbID := range selections {
+		match, ok := byLine[line]
+		if !ok || !match.allows(tmdbID) {
+			return fmt.Errorf("%w: line %d", ErrInvalidImportSelection, line)
+		}
+	}
+
+	err := s.db.QueueImport(ctx, imp.ID, selections)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrImportAlreadyConfirmed
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return err
+	}
+
+	logger.InfoContext(ctx, "queued import", slog.Int("importID", imp.ID), slog.Int("rows", len(selections)))
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (m ImportMatch) allows(tmdbID int) bool {
+	switch m.MatchStatus {
+	case store.ImportMatchStatusMatched:
+		return m.TMDBID == tmdbID
+	case store.ImportMatchStatusAmbiguous:
+		for _, candidate := range m.Candidates {
+			if candidate.TMDBID == tmdbID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// StartImportWorker adds confirmed imports to their parties in the background until the context is cancelled, queued
+// imports are checked for on an interval and as soon as one is confirmed
+func (s *ImportService) StartImportWorker(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.runQueuedImports(ctx, logger)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// runQueuedImports works through every queued import, including any a crashed worker left running
+func (s *ImportService) runQueuedImports(ctx context.Context, logger *slog.Logger) {
+	requeued, err := s.db.RequeueStalledImports(ctx, time.Now().UTC().Add(-stalledImportTimeout))
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to requeue stalled imports", slog.Any("err", err))
+	}
+
+	if requeued > 0 {
+		logger.InfoContext(ctx, "requeued stalled imports", slog.Int64("count", requeued))
+	}
+
+	for ctx.Err() == nil {
+		var idImport, idParty, idWatcher int
+		err := s.db.ClaimQueuedImport(ctx, func(id, party, watcher int) {
+			idImport, idParty, idWatcher = id, party, watcher
+		})
+		if errors.Is(err, store.ErrNoRecord) {
+			return
+		}
+
+		if err != nil {
+			logger.ErrorContext(ctx, "Failed to claim queued import", slog.Any("err", err))
+			return
+		}
+
+		err = s.runImport(ctx, logger, idImport, idParty, idWatcher)
+		if err != nil {
+			// the import is left running so it's retried from where it got to once it's considered stalled
+			logger.ErrorContext(ctx, "Failed to run import", slog.Any("err", err), slog.Int("importID", idImport))
+		}
+	}
+}
+
+type pendingImportRow struct {
+	line   int
+	tmdbID int
+}
+
+func (s *ImportService) runImport(ctx context.Context, logger *slog.Logger, idImport, idParty, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "ImportService.runImport")
+	defer span.End()
+
+	logger = logger.With(slog.Int("importID", idImport), slog.Int("partyID", idParty))
+
+	pending := make([]pendingImportRow, 0)
+	err := s.db.GetPendingImportRows(ctx, idImport, func(line, tmdbID int) {
+		pending = append(pending, pendingImportRow{line: line, tmdbID: tmdbID})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return err
+	}
+
+	party := s.parties.NewParty(ctx, idParty, "", 0, 0, 0)
+
+	for _, row := range pending {
+		status, errMsg := s.importRow(ctx, logger, party, idWatcher, row.tmdbID)
+
+		err := s.db.SetImportRowResult(ctx, idImport, row.line, status, errMsg)
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			return err
+		}
+	}
+
+	err = s.db.FinishImport(ctx, idImport, store.ImportStatusCompleted, "")
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return err
+	}
+
+	logger.InfoContext(ctx, "finished import", slog.Int("rows", len(pending)))
+	return nil
+}
+
+// importRow adds a single movie to the party, a failure only fails the row so the rest of the import carries on
+func (s *ImportService) importRow(ctx context.Context, logger *slog.Logger, party Party, idWatcher, tmdbID int) (store.ImportRowStatusEnum, string) {
+	movieID, err := s.movies.GetOrCreateMovie(ctx, logger, MovieID{TMDBID: &tmdbID})
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get or create imported movie", slog.Any("err", err), slog.Int("tmdbID", tmdbID))
+		return store.ImportRowStatusFailed, "Couldn't get this movie from TMDB"
+	}
+
+	exists, err := party.HasMovieAdded(ctx, movieID)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to check if imported movie is in party", slog.Any("err", err), slog.Int("movieID", movieID))
+		return store.ImportRowStatusFailed, "Couldn't add this movie to the party"
+	}
+
+	if exists {
+		return store.ImportRowStatusAlreadyAdded, ""
+	}
+
+	err = party.AddMovie(ctx, logger, idWatcher, movieID)
+	// someone else added it since it was checked
+	if errors.Is(err, ErrMovieAlreadyInParty) {
+		return store.ImportRowStatusAlreadyAdded, ""
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to add imported movie to party", slog.Any("err", err), slog.Int("movieID", movieID))
+		return store.ImportRowStatusFailed, "Couldn't add this movie to the party"
+	}
+
+	return store.ImportRowStatusAdded, ""
+}
@@ -0,0 +1,163 @@
+package partymgmt
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// MaxImportRows is the most movies that can be imported from one file, each row is looked up on TMDB while the
+	// preview is built so this keeps the preview from taking too long
+	MaxImportRows = 250
+
+	// MaxImportFileSize is the largest file that will be read for an import
+	MaxImportFileSize = 1 << 20
+)
+
+var (
+	ErrImportEmpty          = errors.New("the file doesn't have any movies in it")
+	ErrImportMissingColumns = errors.New("the file needs a title or IMDb ID column")
+	ErrImportTooManyRows    = fmt.Errorf("only %d movies can be imported at once", MaxImportRows)
+)
+
+// ImportSource is where an imported file came from, it's only used to tell the party how the movies were imported
+type ImportSource string
+
+const (
+	ImportSourceCSV        ImportSource = "csv"
+	ImportSourceLetterboxd ImportSource = "letterboxd"
+	ImportSourceIMDb       ImportSource = "imdb"
+)
+
+// ImportRow is a movie read from an imported file
+type ImportRow struct {
+	// Line is the line in the file the movie was on, the header is line 1
+	Line   int
+	Title  string
+	Year   int
+	IMDbID string
+}
+
+var imdbIDPattern = regexp.MustCompile(`tt\d{7,}`)
+
+// the column names each export uses, compared case insensitively with spaces and underscores removed
+var (
+	titleColumns  = []string{"title", "name", "movie", "film"}
+	yearColumns   = []string{"year", "releaseyear"}
+	imdbIDColumns = []string{"imdbid", "const", "imdb", "imdburl", "url"}
+)
+
+// ParseWatchlist reads the movies out of a CSV export. Letterboxd and IMDb exports are recognized by their columns, any
+// other CSV works as long as it has a header with a title or IMDb ID column.
+func ParseWatchlist(r io.Reader) ([]ImportRow, ImportSource, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, "", ErrImportEmpty
+	}
+
+	if err != nil {
+		return nil, "", fmt.Errorf("reading header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for idx, name := range header {
+		name = strings.TrimPrefix(name, "\ufeff")
+		columns[normalizeColumnName(name)] = idx
+	}
+
+	source := detectImportSource(columns)
+	titleIdx := findColumn(columns, titleColumns)
+	yearIdx := findColumn(columns, yearColumns)
+	imdbIdx := findColumn(columns, imdbIDColumns)
+
+	// letterboxd's URI column links to letterboxd so it can't be mistaken for an imdb url
+	if source == ImportSourceLetterboxd {
+		imdbIdx = -1
+	}
+
+	if titleIdx < 0 && imdbIdx < 0 {
+		return nil, "", ErrImportMissingColumns
+	}
+
+	rows := make([]ImportRow, 0)
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+
+		if err != nil {
+			return nil, "", fmt.Errorf("reading file: %w", err)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{
+			Line:   line,
+			Title:  field(record, titleIdx),
+			IMDbID: imdbIDPattern.FindString(field(record, imdbIdx)),
+		}
+
+		if year, err := strconv.Atoi(field(record, yearIdx)); err == nil && year > 0 {
+			row.Year = year
+		}
+
+		if row.Title == "" && row.IMDbID == "" {
+			continue
+		}
+
+		if len(rows) == MaxImportRows {
+			return nil, "", ErrImportTooManyRows
+		}
+
+		rows = append(rows, row)
+	}
+
+	if len(rows) == 0 {
+		return nil, "", ErrImportEmpty
+	}
+
+	return rows, source, nil
+}
+
+func detectImportSource(columns map[string]int) ImportSource {
+	if _, ok := columns["letterboxduri"]; ok {
+		return ImportSourceLetterboxd
+	}
+
+	if _, ok := columns["const"]; ok {
+		return ImportSourceIMDb
+	}
+
+	return ImportSourceCSV
+}
+
+func normalizeColumnName(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	return strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name)
+}
+
+func findColumn(columns map[string]int, names []string) int {
+	for _, name := range names {
+		if idx, ok := columns[name]; ok {
+			return idx
+		}
+	}
+	return -1
+}
+
+func field(record []string, idx int) string {
+	if idx < 0 || idx >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[idx])
+}
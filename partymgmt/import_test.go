@@ -0,0 +1,188 @@
+package partymgmt_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestParseWatchlist(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		file           string
+		expectedSource partymgmt.ImportSource
+		expectedRows   []partymgmt.ImportRow
+	}{
+		"letterboxd": {
+			file: "Date,Name,Year,Letterboxd URI\n" +
+				"2024-01-02,The Matrix,1999,https://boxd.it/28Q8\n" +
+				"2024-01-03,\"Crouching Tiger, Hidden Dragon\",2000,https://boxd.it/1Yvq\n",
+			expectedSource: partymgmt.ImportSourceLetterboxd,
+			expectedRows: []partymgmt.ImportRow{
+				{Line: 2, Title: "The Matrix", Year: 1999},
+				{Line: 3, Title: "Crouching Tiger, Hidden Dragon", Year: 2000},
+			},
+		},
+		"imdb": {
+			file: "Position,Const,Created,Modified,Description,Title,Original Title,URL,Title Type,IMDb Rating,Runtime (mins),Year\n" +
+				"1,tt0133093,2024-01-02,2024-01-02,,The Matrix,The Matrix,https://www.imdb.com/title/tt0133093/,Movie,8.7,136,1999\n",
+			expectedSource: partymgmt.ImportSourceIMDb,
+			expectedRows: []partymgmt.ImportRow{
+				{Line: 2, Title: "The Matrix", Year: 1999, IMDbID: "tt0133093"},
+			},
+		},
+		"generic csv with a byte order mark and blank lines": {
+			file:           "\ufeffTitle,Year,IMDb ID\nInception,2010,\n\n,,\nElf,,tt0319343\n",
+			expectedSource: partymgmt.ImportSourceCSV,
+			expectedRows: []partymgmt.ImportRow{
+				{Line: 2, Title: "Inception", Year: 2010},
+				{Line: 5, Title: "Elf", IMDbID: "tt0319343"},
+			},
+		},
+		"imdb urls only": {
+			file:           "url\nhttps://www.imdb.com/title/tt0133093/\n",
+			expectedSource: partymgmt.ImportSourceCSV,
+			expectedRows: []partymgmt.ImportRow{
+				{Line: 2, IMDbID: "tt0133093"},
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			rows, source, err := partymgmt.ParseWatchlist(strings.NewReader(tc.file))
+			testhelpers.Ok(t, err, "failed to parse watchlist")
+			testhelpers.Equals(t, tc.expectedSource, source)
+			testhelpers.Equals(t, tc.expectedRows, rows)
+		})
+	}
+}
+
+func TestParseWatchlist_Errors(t *testing.T) {
+	t.Parallel()
+
+	tooMany := strings.Builder{}
+	tooMany.WriteString("title\n")
+	for range partymgmt.MaxImportRows + 1 {
+		tooMany.WriteString("The Matrix\n")
+	}
+
+	testCases := map[string]struct {
+		file          string
+		expectedError error
+	}{
+		"empty file": {
+			file:          "",
+			expectedError: partymgmt.ErrImportEmpty,
+		},
+		"header only": {
+			file:          "Title,Year\n",
+			expectedError: partymgmt.ErrImportEmpty,
+		},
+		"no usable columns": {
+			file:          "Date,Rating\n2024-01-02,5\n",
+			expectedError: partymgmt.ErrImportMissingColumns,
+		},
+		"too many rows": {
+			file:          tooMany.String(),
+			expectedError: partymgmt.ErrImportTooManyRows,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, _, err := partymgmt.ParseWatchlist(strings.NewReader(tc.file))
+			testhelpers.Assert(t, errors.Is(err, tc.expectedError), "expected %v, got %v", tc.expectedError, err)
+		})
+	}
+}
+
+func TestImportService_MatchRows(t *testing.T) {
+	t.Parallel()
+	client := newTestTMDBClient(t)
+	svc := partymgmt.NewImportService(client, nil, partymgmt.PartyService{}, nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	testCases := map[string]struct {
+		row                partymgmt.ImportRow
+		expectedStatus     store.ImportMatchStatusEnum
+		expectedTMDBID     int
+		expectedCandidates []int
+	}{
+		"imdb id": {
+			row:                partymgmt.ImportRow{IMDbID: "tt0133093"},
+			expectedStatus:     store.ImportMatchStatusMatched,
+			expectedTMDBID:     603,
+			expectedCandidates: []int{603},
+		},
+		"unknown imdb id falls back to the title": {
+			row:                partymgmt.ImportRow{IMDbID: "tt9999999", Title: "Inception", Year: 2010},
+			expectedStatus:     store.ImportMatchStatusMatched,
+			expectedTMDBID:     27205,
+			expectedCandidates: []int{27205},
+		},
+		"title and year": {
+			row:                partymgmt.ImportRow{Title: "Dune", Year: 2021},
+			expectedStatus:     store.ImportMatchStatusMatched,
+			expectedTMDBID:     438631,
+			expectedCandidates: []int{438631},
+		},
+		"title ignores case": {
+			row:                partymgmt.ImportRow{Title: "the MATRIX reloaded"},
+			expectedStatus:     store.ImportMatchStatusMatched,
+			expectedTMDBID:     604,
+			expectedCandidates: []int{604},
+		},
+		"year off by one": {
+			row:                partymgmt.ImportRow{Title: "Elf", Year: 2004},
+			expectedStatus:     store.ImportMatchStatusMatched,
+			expectedTMDBID:     10719,
+			expectedCandidates: []int{10719},
+		},
+		"same title in different years": {
+			row:                partymgmt.ImportRow{Title: "Dune"},
+			expectedStatus:     store.ImportMatchStatusAmbiguous,
+			expectedCandidates: []int{841, 438631},
+		},
+		"no exact title match": {
+			row:                partymgmt.ImportRow{Title: "Matrix"},
+			expectedStatus:     store.ImportMatchStatusAmbiguous,
+			expectedCandidates: []int{603, 604, 605, 624860},
+		},
+		"not found": {
+			row:            partymgmt.ImportRow{Title: "Not A Real Movie", Year: 1999},
+			expectedStatus: store.ImportMatchStatusNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			matches := svc.MatchRows(context.Background(), logger, []partymgmt.ImportRow{tc.row})
+			testhelpers.Equals(t, 1, len(matches))
+
+			match := matches[0]
+			testhelpers.Equals(t, tc.expectedStatus, match.MatchStatus)
+			testhelpers.Equals(t, tc.expectedTMDBID, match.TMDBID)
+
+			candidates := make([]int, 0, len(match.Candidates))
+			for _, candidate := range match.Candidates {
+				candidates = append(candidates, candidate.TMDBID)
+			}
+			if tc.expectedCandidates == nil {
+				tc.expectedCandidates = []int{}
+			}
+			testhelpers.Equals(t, tc.expectedCandidates, candidates)
+		})
+	}
+}
@@ -3,17 +3,19 @@ package partymgmt
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/jm96441n/movieswithfriends/partymgmt/store"
 )
 
 type InvitationsService struct {
-	db store.InvitationsRepository
+	db      store.InvitationsRepository
+	partyDB store.PartyRepository
 }
 
-func NewInvitationsService(db store.InvitationsRepository) InvitationsService {
-	return InvitationsService{db: db}
+func NewInvitationsService(db store.InvitationsRepository, partyDB store.PartyRepository) InvitationsService {
+	return InvitationsService{db: db, partyDB: partyDB}
 }
 
 type Invite struct {
@@ -36,7 +38,9 @@ func (i InvitationsService) GetInvitationsForParty(ctx context.Context, idParty
 	return invites, nil
 }
 
-func (i InvitationsService) CreateInvite(ctx context.Context, watcherService WatcherService, idParty int, email string) error {
+// CreateInvite invites email to the party on behalf of idInviter, the invite stands even when it can't be added to the
+// party's activity log
+func (i InvitationsService) CreateInvite(ctx context.Context, logger *slog.Logger, watcherService WatcherService, idParty, idInviter int, email string) error {
 	watcher, err := watcherService.GetWatcherByEmail(ctx, email)
 
 	if err != nil && !errors.Is(err, ErrWatcherNotFound) {
@@ -49,6 +53,7 @@ func (i InvitationsService) CreateInvite(ctx context.Context, watcherService Wat
 		if err != nil {
 			return err
 		}
+		i.recordInviteSent(ctx, logger, idParty, idInviter, 0)
 		return nil
 	}
 
@@ -57,5 +62,18 @@ func (i InvitationsService) CreateInvite(ctx context.Context, watcherService Wat
 	if err != nil {
 		return err
 	}
+	i.recordInviteSent(ctx, logger, idParty, idInviter, watcher.ID)
 	return nil
 }
+
+func (i InvitationsService) recordInviteSent(ctx context.Context, logger *slog.Logger, idParty, idInviter, idInvitee int) {
+	err := i.partyDB.RecordActivity(ctx, store.ActivityParams{
+		IDParty:   idParty,
+		Type:      store.ActivityTypeInviteSent,
+		IDActor:   idInviter,
+		IDSubject: idInvitee,
+	})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to record invite in activity log", slog.Any("error", err))
+	}
+}
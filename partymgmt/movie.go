@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"github.com/jm96441n/movieswithfriends/metrics"
 	"github.com/jm96441n/movieswithfriends/partymgmt/store"
@@ -32,48 +34,77 @@ type Movie struct {
 }
 
 type movieFetcher interface {
-	Search(ctx context.Context, searchTerm string, page int) (SearchResults, error)
-	GetMovie(ctx context.Context, tmdbID int) (*TMDBMovie, error)
-	GetGenre(int) (Genre, error)
+	Search(ctx context.Context, searchTerm string, page int, language string) (SearchResults, error)
+	GetMovie(ctx context.Context, tmdbID int, language string) (*TMDBMovie, error)
+	GetGenre(ctx context.Context, language string, genreID int) (Genre, error)
+	LoadGenres(language string, genres []Genre)
+	RefreshGenres(ctx context.Context, extraLanguages ...string) (map[string][]Genre, error)
+	GetWatchProviders(ctx context.Context, tmdbID int) (map[string]RegionWatchProviders, error)
+	ListWatchProviders(ctx context.Context, region string) ([]WatchProvider, error)
+	GetRecommendations(ctx context.Context, tmdbID int, language string) ([]TMDBMovie, error)
+	GetSimilar(ctx context.Context, tmdbID int, language string) ([]TMDBMovie, error)
+	SearchByYear(ctx context.Context, term string, year int, language string) ([]TMDBMovie, error)
+	FindByIMDbID(ctx context.Context, imdbID string) ([]TMDBMovie, error)
 }
 
 type MovieService struct {
-	db         *store.MoviesRepository
-	tmdbClient movieFetcher
+	db               *store.MoviesRepository
+	genresDB         *store.GenresRepository
+	watchProvidersDB *store.WatchProvidersRepository
+	tmdbClient       movieFetcher
+	// partyRefreshes is when each party's watch providers were last refreshed in the background
+	partyRefreshes *refreshTracker
 }
 
-func NewMovieService(client *TMDBClient, moviesRepository *store.MoviesRepository) *MovieService {
+func NewMovieService(client *TMDBClient, moviesRepository *store.MoviesRepository, genresRepository *store.GenresRepository, watchProvidersRepository *store.WatchProvidersRepository) *MovieService {
 	return &MovieService{
-		tmdbClient: client,
-		db:         moviesRepository,
+		tmdbClient:       client,
+		db:               moviesRepository,
+		genresDB:         genresRepository,
+		watchProvidersDB: watchProvidersRepository,
+		partyRefreshes:   newRefreshTracker(watchProvidersRefreshInterval),
 	}
 }
 
-func (m *MovieService) SearchMovies(ctx context.Context, logger *slog.Logger, searchTerm string) ([]TMDBMovie, error) {
-	result, err := m.tmdbClient.Search(ctx, searchTerm, 1)
+func (m *MovieService) SearchMovies(ctx context.Context, logger *slog.Logger, searchTerm, language string) ([]TMDBMovie, error) {
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	result, err := m.tmdbClient.Search(ctx, searchTerm, 1, language)
 	if err != nil {
 		return nil, err
 	}
 
-	for idx := range result.Movies {
-		result.Movies[idx].URL = fmt.Sprintf("/movies/%d", result.Movies[idx].TMDBID)
-		if result.Movies[idx].PosterURL != "" {
-			result.Movies[idx].PosterURL = fmt.Sprintf("https://image.tmdb.org/t/p/w500/%s", result.Movies[idx].PosterURL)
-		} else {
-			result.Movies[idx].PosterURL = "https://placehold.co/270x400?text=No+Poster+Available"
-		}
-		result.Movies[idx].Genres = make([]Genre, 0, len(result.Movies[idx].GenreIDs))
-		for _, genreID := range result.Movies[idx].GenreIDs {
-			genre, err := m.tmdbClient.GetGenre(genreID)
+	m.decorateMovies(ctx, logger, result.Movies, language)
+
+	return result.Movies, nil
+}
+
+// decorateMovies fills in the links, poster URLs and genre names for movies returned from a TMDB listing
+func (m *MovieService) decorateMovies(ctx context.Context, logger *slog.Logger, movies []TMDBMovie, language string) {
+	for idx := range movies {
+		movies[idx].URL = fmt.Sprintf("/movies/%d", movies[idx].TMDBID)
+		movies[idx].PosterURL = posterURL(movies[idx].PosterURL)
+		movies[idx].Genres = make([]Genre, 0, len(movies[idx].GenreIDs))
+		for _, genreID := range movies[idx].GenreIDs {
+			genre, err := m.tmdbClient.GetGenre(ctx, language, genreID)
 			if err != nil {
 				logger.ErrorContext(ctx, "Failed to get genre", slog.Any("err", err), slog.Any("genreID", genreID))
 				continue
 			}
-			result.Movies[idx].Genres = append(result.Movies[idx].Genres, genre)
+			movies[idx].Genres = append(movies[idx].Genres, genre)
 		}
 	}
+}
 
-	return result.Movies, nil
+// posterURL turns the poster path from TMDB into a link to the image, falling back to a placeholder when the movie
+// doesn't have one
+func posterURL(posterPath string) string {
+	if posterPath == "" {
+		return "https://placehold.co/270x400?text=No+Poster+Available"
+	}
+	return fmt.Sprintf("https://image.tmdb.org/t/p/w500/%s", posterPath)
 }
 
 func (m *MovieService) GetMovieTMDBIDsFromCurrentParty(ctx context.Context, logger *slog.Logger, partyID int, movies []TMDBMovie) (map[int]struct{}, error) {
@@ -227,7 +258,9 @@ func (m *MovieService) CreateMovie(ctx context.Context, logger *slog.Logger, tmd
 
 	err = nil
 
-	tmdbMovie, err := m.tmdbClient.GetMovie(ctx, tmdbID)
+	// movies are shared between every user so they're always stored in the default language, the details are
+	// localized when the movie is read with LocalizeMovie and genre names with GetLocalizedGenres
+	tmdbMovie, err := m.tmdbClient.GetMovie(ctx, tmdbID, DefaultLanguage)
 	if err != nil || tmdbMovie == nil {
 		logger.ErrorContext(ctx, "Failed to get movie from tmdb", slog.Any("err", err), slog.Any("tmdbID", tmdbID))
 		return 0, err
@@ -242,3 +275,450 @@ func (m *MovieService) CreateMovie(ctx context.Context, logger *slog.Logger, tmd
 
 	return movieID, nil
 }
+
+// GetLocalizedGenres returns the genre names for a movie in the requested language, if the genres for the movie haven't
+// been normalized yet the names stored on the movie are returned
+func (m *MovieService) GetLocalizedGenres(ctx context.Context, logger *slog.Logger, movie Movie, language string) []string {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.GetLocalizedGenres")
+	defer span.End()
+
+	if language == "" || language == DefaultLanguage {
+		return movie.Genres
+	}
+
+	genres, err := m.genresDB.GetMovieGenres(ctx, movie.ID, language, DefaultLanguage)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get localized genres", slog.Any("err", err), slog.Any("movieID", movie.ID))
+		return movie.Genres
+	}
+
+	if len(genres) == 0 {
+		return movie.Genres
+	}
+
+	return genres
+}
+
+// LocalizeMovie returns the movie with its title, overview and tagline in the requested language. They're fetched from
+// TMDB the first time the movie is looked at in the language, anything TMDB has no translation for or a failure to
+// fetch them keeps the default language's details
+func (m *MovieService) LocalizeMovie(ctx context.Context, logger *slog.Logger, movie Movie, language string) Movie {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.LocalizeMovie")
+	defer span.End()
+
+	if language == "" || language == DefaultLanguage {
+		return movie
+	}
+
+	translation, err := m.db.GetMovieTranslation(ctx, movie.ID, language)
+	if errors.Is(err, store.ErrNoRecord) {
+		translation, err = m.fetchMovieTranslation(ctx, logger, movie, language)
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get movie translation", slog.Any("err", err), slog.Any("movieID", movie.ID), slog.String("language", language))
+		return movie
+	}
+
+	if translation.Title != "" {
+		movie.Title = translation.Title
+	}
+	if translation.Overview != "" {
+		movie.Overview = translation.Overview
+	}
+	if translation.Tagline != "" {
+		movie.Tagline = translation.Tagline
+	}
+
+	return movie
+}
+
+func (m *MovieService) fetchMovieTranslation(ctx context.Context, logger *slog.Logger, movie Movie, language string) (store.MovieTranslation, error) {
+	tmdbMovie, err := m.tmdbClient.GetMovie(ctx, movie.TMDBID, language)
+	// an error response from TMDB decodes to a movie without an id, it mustn't be kept as the translation
+	if err == nil && (tmdbMovie == nil || tmdbMovie.TMDBID != movie.TMDBID) {
+		err = fmt.Errorf("%w: tmdb has no movie %d", ErrMovieDoesNotExist, movie.TMDBID)
+	}
+	if err != nil {
+		return store.MovieTranslation{}, err
+	}
+
+	translation := store.MovieTranslation{
+		Title:    tmdbMovie.Title,
+		Overview: tmdbMovie.Overview,
+		Tagline:  tmdbMovie.Tagline,
+	}
+
+	// it's still shown when it can't be kept, it'll be fetched again next time
+	err = m.db.UpsertMovieTranslation(ctx, movie.ID, language, translation)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to store movie translation", slog.Any("err", err), slog.Any("movieID", movie.ID), slog.String("language", language))
+	}
+
+	return translation, nil
+}
+
+// ListGenres returns every stored genre in the language, or in the default language when none are stored for it yet
+func (m *MovieService) ListGenres(ctx context.Context, language string) ([]Genre, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.ListGenres")
+	defer span.End()
+
+	for _, lang := range []string{language, DefaultLanguage} {
+		genres := make([]Genre, 0)
+		err := m.genresDB.GetGenres(ctx, lang, func(id int, name string) {
+			genres = append(genres, Genre{ID: id, Name: name})
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if len(genres) > 0 {
+			return genres, nil
+		}
+	}
+
+	return []Genre{}, nil
+}
+
+// WarmGenreCache loads the persisted genres into the TMDB client's cache so languages other than the default
+// don't need a request to TMDB the first time they're used
+func (m *MovieService) WarmGenreCache(ctx context.Context, logger *slog.Logger) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.WarmGenreCache")
+	defer span.End()
+
+	languages := make([]string, 0)
+	err := m.genresDB.GetPreferredLanguages(ctx, func(language string) {
+		languages = append(languages, language)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, language := range languages {
+		genres := make([]Genre, 0)
+		err := m.genresDB.GetGenres(ctx, language, func(id int, name string) {
+			genres = append(genres, Genre{ID: id, Name: name})
+		})
+		if err != nil {
+			return err
+		}
+		m.tmdbClient.LoadGenres(language, genres)
+	}
+
+	logger.InfoContext(ctx, "warmed genre cache", slog.Any("languages", languages))
+	return nil
+}
+
+// RefreshGenres fetches the latest genres from TMDB for every language in use, persists them and links any movies that
+// only have genre names stored to the genres table
+func (m *MovieService) RefreshGenres(ctx context.Context, logger *slog.Logger) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieService.RefreshGenres")
+	defer span.End()
+
+	languages := []string{DefaultLanguage}
+	err := m.genresDB.GetPreferredLanguages(ctx, func(language string) {
+		languages = append(languages, language)
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return err
+	}
+
+	// a failure for one language shouldn't stop the rest from being persisted
+	refreshed, refreshErr := m.tmdbClient.RefreshGenres(ctx, languages...)
+	if refreshErr != nil {
+		logger.ErrorContext(ctx, "Failed to refresh some genres", slog.Any("err", refreshErr))
+	}
+
+	for language, genres := range refreshed {
+		names := make([]store.GenreName, 0, len(genres))
+		for _, genre := range genres {
+			names = append(names, store.GenreName{ID: genre.ID, Name: genre.Name})
+		}
+
+		err = m.genresDB.UpsertGenres(ctx, language, names)
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			return err
+		}
+	}
+
+	linked, err := m.genresDB.BackfillMovieGenres(ctx, DefaultLanguage)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return err
+	}
+
+	logger.InfoContext(ctx, "refreshed genres", slog.Int("languages", len(refreshed)), slog.Int64("moviesGenresLinked", linked))
+
+	if refreshErr != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return refreshErr
+	}
+
+	return nil
+}
+
+// StartGenreRefresh refreshes the genres on an interval until the context is cancelled
+func (m *MovieService) StartGenreRefresh(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				err := m.RefreshGenres(ctx, logger)
+				if err != nil {
+					logger.ErrorContext(ctx, "Failed to refresh genres", slog.Any("err", err))
+				}
+			}
+		}
+	}()
+}
+
+const (
+	// watchProvidersTTL is how long the providers fetched for a movie are trusted before they're fetched again
+	watchProvidersTTL = 24 * time.Hour
+
+	// maxConcurrentWatchProviderFetches limits how many requests are made to TMDB at once when refreshing a party
+	maxConcurrentWatchProviderFetches = 4
+
+	// watchProvidersRefreshInterval is how long after a party's background refresh starts before another one can,
+	// every member reloading the party page on an event would otherwise each go to TMDB
+	watchProvidersRefreshInterval = time.Minute
+
+	// watchProvidersBackgroundTimeout bounds a background refresh so a struggling TMDB can't pile up requests
+	watchProvidersBackgroundTimeout = 30 * time.Second
+)
+
+// WatchProvidersRefreshTimeout is how long a request waits on TMDB for watch providers before going ahead with the
+// ones that are already stored
+const WatchProvidersRefreshTimeout = 5 * time.Second
+
+// refreshTracker allows one refresh per key per interval
+type refreshTracker struct {
+	mu          sync.Mutex
+	interval    time.Duration
+	lastStarted map[int]time.Time
+}
+
+func newRefreshTracker(interval time.Duration) *refreshTracker {
+	return &refreshTracker{interval: interval, lastStarted: make(map[int]time.Time)}
+}
+
+// tryStart records a refresh for key starting at now, it returns false when one already started within the interval.
+// Refreshes that are past the interval are forgotten so only keys refreshed recently are held on to
+func (r *refreshTracker) tryStart(key int, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if last, ok := r.lastStarted[key]; ok && now.Sub(last) < r.interval {
+		return false
+	}
+
+	for otherKey, last := range r.lastStarted {
+		if now.Sub(last) >= r.interval {
+			delete(r.lastStarted, otherKey)
+		}
+	}
+
+	r.lastStarted[key] = now
+	return true
+}
+
+// RefreshPartyWatchProvidersInBackground starts refreshing the party's stale watch providers without waiting for it to
+// finish, the caller goes ahead with the providers already stored and the fresh ones show up on the next load. A
+// party is refreshed at most once per watchProvidersRefreshInterval.
+func (m *MovieService) RefreshPartyWatchProvidersInBackground(ctx context.Context, logger *slog.Logger, idParty int) {
+	if !m.partyRefreshes.tryStart(idParty, time.Now()) {
+		return
+	}
+
+	// the refresh outlives the request that started it
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), watchProvidersBackgroundTimeout)
+	go func() {
+		defer cancel()
+
+		err := m.RefreshPartyWatchProviders(ctx, logger, idParty)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to refresh watch providers", slog.Int("partyID", idParty), slog.Any("error", err))
+		}
+	}()
+}
+
+// RefreshPartyWatchProviders fetches the providers for any unwatched movie in the party that hasn't been checked recently.
+// Only the ways of watching that come with a subscription (or are free) are stored, renting and buying are ignored.
+func (m *MovieService) RefreshPartyWatchProviders(ctx context.Context, logger *slog.Logger, idParty int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieService.RefreshPartyWatchProviders")
+	defer span.End()
+
+	staleMovies := make(map[int]int)
+	err := m.watchProvidersDB.GetMoviesNeedingWatchProviders(ctx, idParty, time.Now().UTC().Add(-watchProvidersTTL), func(idMovie, tmdbID int) {
+		staleMovies[idMovie] = tmdbID
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return err
+	}
+
+	if len(staleMovies) == 0 {
+		return nil
+	}
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs error
+		sem  = make(chan struct{}, maxConcurrentWatchProviderFetches)
+	)
+
+	for idMovie, tmdbID := range staleMovies {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := m.refreshMovieWatchProviders(ctx, idMovie, tmdbID)
+			if err != nil {
+				mu.Lock()
+				errs = errors.Join(errs, fmt.Errorf("refreshing watch providers for movie %d: %w", idMovie, err))
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	logger.InfoContext(ctx, "refreshed watch providers", slog.Int("partyID", idParty), slog.Int("movies", len(staleMovies)))
+
+	if errs != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return errs
+	}
+
+	return nil
+}
+
+func (m *MovieService) refreshMovieWatchProviders(ctx context.Context, idMovie, tmdbID int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.refreshMovieWatchProviders")
+	defer span.End()
+
+	byRegion, err := m.tmdbClient.GetWatchProviders(ctx, tmdbID)
+	if err != nil {
+		return err
+	}
+
+	providers := make([]store.MovieWatchProvider, 0)
+	for region, regionProviders := range byRegion {
+		for monetizationType, offered := range map[string][]WatchProvider{
+			"flatrate": regionProviders.Flatrate,
+			"free":     regionProviders.Free,
+			"ads":      regionProviders.Ads,
+		} {
+			for _, provider := range offered {
+				providers = append(providers, store.MovieWatchProvider{
+					Region:           region,
+					ID:               provider.ID,
+					Name:             provider.Name,
+					LogoPath:         provider.LogoPath,
+					MonetizationType: monetizationType,
+					DisplayPriority:  provider.DisplayPriority,
+				})
+			}
+		}
+	}
+
+	return m.watchProvidersDB.ReplaceMovieWatchProviders(ctx, idMovie, providers)
+}
+
+// ListWatchProviders returns the services a profile can subscribe to in a region
+func (m *MovieService) ListWatchProviders(ctx context.Context, region string) ([]WatchProvider, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.ListWatchProviders")
+	defer span.End()
+
+	return m.tmdbClient.ListWatchProviders(ctx, region)
+}
+
+// StoredMovie is a movie that's been saved from TMDB, as admins see it
+type StoredMovie struct {
+	ID          int
+	TMDBID      int
+	Title       string
+	ReleaseDate time.Time
+	CreatedAt   time.Time
+	// RefreshedAt is when the movie was last refreshed from TMDB, it's zero when it never has been
+	RefreshedAt time.Time
+}
+
+// SearchStoredMovies returns up to limit saved movies whose title has term in it or whose TMDB id is term
+func (m *MovieService) SearchStoredMovies(ctx context.Context, term string, limit int) ([]StoredMovie, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.SearchStoredMovies")
+	defer span.End()
+
+	movies := make([]StoredMovie, 0)
+	err := m.db.SearchMovies(ctx, term, limit, func(res store.StoredMovieResult) {
+		movie := StoredMovie{
+			ID:        res.ID,
+			TMDBID:    res.TMDBID,
+			Title:     res.Title,
+			CreatedAt: res.CreatedAt,
+		}
+		if res.ReleaseDate != nil {
+			movie.ReleaseDate = *res.ReleaseDate
+		}
+		if res.UpdatedAt != nil {
+			movie.RefreshedAt = *res.UpdatedAt
+		}
+		movies = append(movies, movie)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// RefreshMovie refetches a saved movie's details, genres and watch providers from TMDB, for when they've changed
+// since it was saved. The details are refetched in the default language, other languages are fetched again by
+// LocalizeMovie the next time they're looked at
+func (m *MovieService) RefreshMovie(ctx context.Context, logger *slog.Logger, idMovie int) (Movie, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieService.RefreshMovie")
+	defer span.End()
+
+	movie, err := m.GetMovie(ctx, logger, MovieID{MovieID: &idMovie})
+	if err != nil {
+		return Movie{}, err
+	}
+
+	tmdbMovie, err := m.tmdbClient.GetMovie(ctx, movie.TMDBID, DefaultLanguage)
+	if err == nil && tmdbMovie == nil {
+		err = fmt.Errorf("%w: tmdb has no movie %d", ErrMovieDoesNotExist, movie.TMDBID)
+	}
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "Failed to get movie from tmdb", slog.Any("err", err), slog.Any("tmdbID", movie.TMDBID))
+		return Movie{}, err
+	}
+
+	err = m.db.UpdateMovie(ctx, idMovie, tmdbMovie.ToStoreMovie())
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "Failed to update movie", slog.Any("err", err), slog.Any("movieID", idMovie))
+		return Movie{}, err
+	}
+
+	err = m.refreshMovieWatchProviders(ctx, idMovie, movie.TMDBID)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "Failed to refresh watch providers", slog.Any("err", err), slog.Any("movieID", idMovie))
+		return Movie{}, err
+	}
+
+	logger.InfoContext(ctx, "refreshed movie from tmdb", slog.Any("movieID", idMovie), slog.Any("tmdbID", movie.TMDBID))
+
+	return m.GetMovie(ctx, logger, MovieID{MovieID: &idMovie})
+}
@@ -0,0 +1,182 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrInvalidMovieFlag = errors.New("invalid movie flag")
+	ErrCannotFlagMovie  = errors.New("only movies the party hasn't watched can be flagged")
+)
+
+// MovieFlag is how a member feels about watching one of the party's movies, it's taken into account when a movie is
+// picked for the members who are watching
+type MovieFlag string
+
+const (
+	MovieFlagNone          MovieFlag = ""
+	MovieFlagSeenIt        MovieFlag = "seen_it"
+	MovieFlagMustWatch     MovieFlag = "must_watch"
+	MovieFlagNotInterested MovieFlag = "not_interested"
+)
+
+// ParseMovieFlag parses a flag from a form, an empty string is MovieFlagNone which clears the watcher's flag
+func ParseMovieFlag(s string) (MovieFlag, error) {
+	flag := MovieFlag(s)
+	switch flag {
+	case MovieFlagNone, MovieFlagSeenIt, MovieFlagMustWatch, MovieFlagNotInterested:
+		return flag, nil
+	}
+	return "", ErrInvalidMovieFlag
+}
+
+// MovieFlags is who in the party has flagged a movie they haven't watched together yet
+type MovieFlags struct {
+	IDParty       int
+	IDMovie       int
+	SeenIt        []FullName
+	MustWatch     []FullName
+	NotInterested []FullName
+	// Own is the current watcher's flag
+	Own MovieFlag
+}
+
+// MovieFlagOption is a flag the watcher can set on the movie, setting the one that's Active clears it
+type MovieFlagOption struct {
+	IDParty int
+	IDMovie int
+	Flag    MovieFlag
+	Label   string
+	Active  bool
+}
+
+var movieFlagLabels = []struct {
+	flag  MovieFlag
+	label string
+}{
+	{flag: MovieFlagMustWatch, label: "Must watch"},
+	{flag: MovieFlagSeenIt, label: "Seen it"},
+	{flag: MovieFlagNotInterested, label: "Not interested"},
+}
+
+// Options are the flags the current watcher can set on the movie
+func (f MovieFlags) Options() []MovieFlagOption {
+	options := make([]MovieFlagOption, 0, len(movieFlagLabels))
+	for _, l := range movieFlagLabels {
+		options = append(options, MovieFlagOption{
+			IDParty: f.IDParty,
+			IDMovie: f.IDMovie,
+			Flag:    l.flag,
+			Label:   l.label,
+			Active:  f.Own == l.flag,
+		})
+	}
+	return options
+}
+
+// SetMovieFlag sets how the watcher feels about watching the party's movie, MovieFlagNone clears their flag
+func (s PartyService) SetMovieFlag(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher int, flag MovieFlag) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "PartyService.SetMovieFlag")
+	defer span.End()
+
+	err := checkPartyMembership(ctx, s.db, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if flag == MovieFlagNone {
+		err = s.db.ClearMovieFlag(ctx, idParty, idMovie, idWatcher)
+	} else {
+		err = s.db.SetMovieFlag(ctx, idParty, idMovie, idWatcher, store.MovieFlagEnum(flag))
+	}
+
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrCannotFlagMovie
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to set movie flag", slog.String("flag", string(flag)), slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// GetMovieFlags returns who has flagged the party's movie, idWatcher is the watcher looking at it
+func (s PartyService) GetMovieFlags(ctx context.Context, idParty, idMovie, idWatcher int) (MovieFlags, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.GetMovieFlags")
+	defer span.End()
+
+	flagsByMovie, err := getMovieFlags(ctx, s.db, idParty, idMovie, idWatcher)
+	if err != nil {
+		return MovieFlags{}, err
+	}
+
+	flags := flagsByMovie[idMovie]
+	flags.IDParty = idParty
+	flags.IDMovie = idMovie
+	return flags, nil
+}
+
+// LoadMovieFlags fills in who has flagged each of the movies the party hasn't watched, idWatcher is the watcher looking
+// at the party
+func (p *Party) LoadMovieFlags(ctx context.Context, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "Party.LoadMovieFlags")
+	defer span.End()
+
+	flagsByMovie, err := getMovieFlags(ctx, p.db, p.ID, 0, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	setFlags := func(movie *PartyMovie) {
+		movie.Flags = flagsByMovie[movie.ID]
+		movie.Flags.IDParty = p.ID
+		movie.Flags.IDMovie = movie.ID
+	}
+
+	for idx := range p.MoviesByStatus.UnwatchedMovies {
+		setFlags(&p.MoviesByStatus.UnwatchedMovies[idx])
+	}
+
+	if p.MoviesByStatus.SelectedMovie != nil {
+		setFlags(p.MoviesByStatus.SelectedMovie)
+	}
+
+	return nil
+}
+
+// getMovieFlags returns the flags on the party's unwatched movies keyed by movie, or only for idMovie when it's set
+func getMovieFlags(ctx context.Context, db store.PartyRepository, idParty, idMovie, idWatcher int) (map[int]MovieFlags, error) {
+	flagsByMovie := make(map[int]MovieFlags)
+	err := db.GetMovieFlags(ctx, idParty, idMovie, func(res store.MovieFlagResult) {
+		flags := flagsByMovie[res.IDMovie]
+		name := FullName{FirstName: res.FirstName, LastName: res.LastName}
+
+		switch MovieFlag(res.Flag) {
+		case MovieFlagSeenIt:
+			flags.SeenIt = append(flags.SeenIt, name)
+		case MovieFlagMustWatch:
+			flags.MustWatch = append(flags.MustWatch, name)
+		case MovieFlagNotInterested:
+			flags.NotInterested = append(flags.NotInterested, name)
+		}
+
+		if res.IDWatcher == idWatcher {
+			flags.Own = MovieFlag(res.Flag)
+		}
+
+		flagsByMovie[res.IDMovie] = flags
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return flagsByMovie, nil
+}
@@ -0,0 +1,41 @@
+package partymgmt_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestParseMovieFlag(t *testing.T) {
+	t.Parallel()
+
+	for _, flag := range []partymgmt.MovieFlag{partymgmt.MovieFlagNone, partymgmt.MovieFlagSeenIt, partymgmt.MovieFlagMustWatch, partymgmt.MovieFlagNotInterested} {
+		got, err := partymgmt.ParseMovieFlag(string(flag))
+		testhelpers.Ok(t, err, "failed to parse flag")
+		testhelpers.Equals(t, flag, got)
+	}
+
+	_, err := partymgmt.ParseMovieFlag("meh")
+	testhelpers.Assert(t, errors.Is(err, partymgmt.ErrInvalidMovieFlag), "expected %v, got %v", partymgmt.ErrInvalidMovieFlag, err)
+}
+
+func TestMovieFlagsOptions(t *testing.T) {
+	t.Parallel()
+
+	flags := partymgmt.MovieFlags{IDParty: 1, IDMovie: 2, Own: partymgmt.MovieFlagSeenIt}
+
+	active := make(map[partymgmt.MovieFlag]bool)
+	for _, option := range flags.Options() {
+		testhelpers.Equals(t, 1, option.IDParty)
+		testhelpers.Equals(t, 2, option.IDMovie)
+		active[option.Flag] = option.Active
+	}
+
+	testhelpers.Equals(t, map[partymgmt.MovieFlag]bool{
+		partymgmt.MovieFlagMustWatch:     false,
+		partymgmt.MovieFlagSeenIt:        true,
+		partymgmt.MovieFlagNotInterested: false,
+	}, active)
+}
@@ -0,0 +1,788 @@
+package partymgmt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrMovieNightNotFound     = errors.New("movie night not found")
+	ErrMovieNightCompleted    = errors.New("movie night has already been completed")
+	ErrMovieNightHasNoMovie   = errors.New("movie night doesn't have a movie")
+	ErrNotMovieNightOrganizer = errors.New("only the person who scheduled the movie night or the party owner can change it")
+	ErrInvalidRSVPResponse    = errors.New("invalid rsvp response")
+	ErrCalendarNotFound       = errors.New("calendar not found")
+)
+
+const (
+	// MovieNightInputLayout is the layout of the date and time a movie night starts at in a form
+	MovieNightInputLayout = "2006-01-02T15:04"
+
+	// defaultMovieNightLength is how long a movie night lasts when the movie's runtime isn't known
+	defaultMovieNightLength = 2 * time.Hour
+
+	maxMovieNightLocationLength = 200
+
+	// completedMovieNightsLimit is how many of a party's past movie nights are shown
+	completedMovieNightsLimit = 10
+
+	// upcomingMovieNightsWindow is how far ahead movie nights are shown as reminders
+	upcomingMovieNightsWindow = 7 * 24 * time.Hour
+
+	// calendarHistory is how far back calendar feeds go so recent movie nights don't vanish from calendars
+	calendarHistory = 90 * 24 * time.Hour
+)
+
+// MovieNightValidationError is returned when a movie night can't be saved because of what was entered for it
+type MovieNightValidationError struct {
+	Reason string
+}
+
+func (e *MovieNightValidationError) Error() string {
+	return e.Reason
+}
+
+type RSVPResponse string
+
+const (
+	RSVPGoing    RSVPResponse = "going"
+	RSVPMaybe    RSVPResponse = "maybe"
+	RSVPNotGoing RSVPResponse = "not_going"
+)
+
+func ParseRSVPResponse(s string) (RSVPResponse, error) {
+	response := RSVPResponse(s)
+	switch response {
+	case RSVPGoing, RSVPMaybe, RSVPNotGoing:
+		return response, nil
+	}
+	return "", ErrInvalidRSVPResponse
+}
+
+type ReminderOption struct {
+	Minutes int
+	Label   string
+}
+
+// ReminderOptions are how long before a movie night its calendar reminder can go off, 0 is no reminder
+var ReminderOptions = []ReminderOption{
+	{Minutes: 0, Label: "No reminder"},
+	{Minutes: 15, Label: "15 minutes before"},
+	{Minutes: 30, Label: "30 minutes before"},
+	{Minutes: 60, Label: "1 hour before"},
+	{Minutes: 120, Label: "2 hours before"},
+	{Minutes: 24 * 60, Label: "1 day before"},
+}
+
+type MovieNightMovie struct {
+	ID      int
+	Title   string
+	Runtime int
+}
+
+type MovieNightRSVP struct {
+	IDWatcher int
+	Name      FullName
+	Response  RSVPResponse
+}
+
+type MovieNight struct {
+	ID           int
+	IDParty      int
+	PartyName    string
+	IDPartyOwner int
+	// Movie is nil until a movie has been chosen
+	Movie       *MovieNightMovie
+	IDCreatedBy int
+	CreatedBy   FullName
+	// StartsAt is in the movie night's time zone
+	StartsAt        time.Time
+	TimeZone        string
+	Location        string
+	StreamURL       string
+	ReminderMinutes int
+	// Sequence goes up every time the movie night changes, calendar apps use it to tell which copy is newest
+	Sequence    int
+	CompletedAt *time.Time
+	UpdatedAt   time.Time
+	RSVPs       []MovieNightRSVP
+	// OwnRSVP and CanManage are for the watcher the movie night was loaded for
+	OwnRSVP   RSVPResponse
+	CanManage bool
+}
+
+// EndsAt is when the movie is expected to finish, movie nights without a runtime are given a couple of hours
+func (m MovieNight) EndsAt() time.Time {
+	if m.Movie != nil && m.Movie.Runtime > 0 {
+		return m.StartsAt.Add(time.Duration(m.Movie.Runtime) * time.Minute)
+	}
+	return m.StartsAt.Add(defaultMovieNightLength)
+}
+
+func (m MovieNight) IsCompleted() bool {
+	return m.CompletedAt != nil
+}
+
+// RSVPsWith are the responses that match response
+func (m MovieNight) RSVPsWith(response RSVPResponse) []MovieNightRSVP {
+	rsvps := make([]MovieNightRSVP, 0, len(m.RSVPs))
+	for _, rsvp := range m.RSVPs {
+		if rsvp.Response == response {
+			rsvps = append(rsvps, rsvp)
+		}
+	}
+	return rsvps
+}
+
+// MovieNightInput is what's entered when scheduling or changing a movie night
+type MovieNightInput struct {
+	// IDMovie is 0 when the movie hasn't been decided
+	IDMovie         int
+	StartsAt        time.Time
+	TimeZone        string
+	Location        string
+	StreamURL       string
+	ReminderMinutes int
+}
+
+// ParseMovieNightStart reads the date and time a movie night starts at as entered in a form, it's the wall clock time in
+// the time zone
+func ParseMovieNightStart(value, timeZone string) (time.Time, error) {
+	loc, err := time.LoadLocation(timeZone)
+	if err != nil || timeZone == "" || timeZone == "Local" {
+		return time.Time{}, &MovieNightValidationError{Reason: "the time zone isn't one we recognise"}
+	}
+
+	startsAt, err := time.ParseInLocation(MovieNightInputLayout, value, loc)
+	if err != nil {
+		return time.Time{}, &MovieNightValidationError{Reason: "enter the date and time it starts"}
+	}
+
+	return startsAt, nil
+}
+
+// Validate checks everything about the input that doesn't need the party, now is used to make sure new movie nights
+// aren't in the past
+func (in MovieNightInput) Validate(now time.Time) error {
+	if !in.StartsAt.After(now) {
+		return &MovieNightValidationError{Reason: "it has to start in the future"}
+	}
+
+	if utf8.RuneCountInString(in.Location) > maxMovieNightLocationLength {
+		return &MovieNightValidationError{Reason: "the location can be at most 200 characters"}
+	}
+
+	if in.StreamURL != "" {
+		streamURL, err := url.Parse(in.StreamURL)
+		if err != nil || (streamURL.Scheme != "http" && streamURL.Scheme != "https") || streamURL.Host == "" {
+			return &MovieNightValidationError{Reason: "the stream link has to be a web address"}
+		}
+	}
+
+	validReminder := slices.ContainsFunc(ReminderOptions, func(option ReminderOption) bool {
+		return option.Minutes == in.ReminderMinutes
+	})
+	if !validReminder {
+		return &MovieNightValidationError{Reason: "pick one of the reminder options"}
+	}
+
+	return nil
+}
+
+type SchedulableMovie struct {
+	ID    int
+	Title string
+}
+
+type PartyMovieNights struct {
+	// Scheduled are the movie nights that haven't been completed, soonest first
+	Scheduled []MovieNight
+	// Completed are the most recently completed movie nights, newest first
+	Completed []MovieNight
+}
+
+// Calendar is a set of movie nights that's published as a calendar feed
+type Calendar struct {
+	Name        string
+	MovieNights []MovieNight
+}
+
+type MovieNightService struct {
+	db      *store.MovieNightsRepository
+	partyDB store.PartyRepository
+	events  *EventBus
+}
+
+func NewMovieNightService(db *store.MovieNightsRepository, partyDB store.PartyRepository, events *EventBus) MovieNightService {
+	return MovieNightService{db: db, partyDB: partyDB, events: events}
+}
+
+// GetPartyMovieNights returns the party's movie nights, ErrNotPartyMember is returned when the watcher isn't in it
+func (s MovieNightService) GetPartyMovieNights(ctx context.Context, logger *slog.Logger, idParty, idWatcher int) (PartyMovieNights, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.GetPartyMovieNights")
+	defer span.End()
+
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return PartyMovieNights{}, err
+	}
+
+	nights := PartyMovieNights{
+		Scheduled: make([]MovieNight, 0),
+		Completed: make([]MovieNight, 0),
+	}
+
+	err = s.db.GetScheduledMovieNights(ctx, idParty, func(res store.MovieNightResult) {
+		nights.Scheduled = append(nights.Scheduled, newMovieNight(res, idWatcher))
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get scheduled movie nights", slog.Any("error", err))
+		return PartyMovieNights{}, err
+	}
+
+	err = s.db.GetCompletedMovieNights(ctx, idParty, completedMovieNightsLimit, func(res store.MovieNightResult) {
+		nights.Completed = append(nights.Completed, newMovieNight(res, idWatcher))
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get completed movie nights", slog.Any("error", err))
+		return PartyMovieNights{}, err
+	}
+
+	err = s.loadRSVPs(ctx, idWatcher, nights.Scheduled, nights.Completed)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get movie night rsvps", slog.Any("error", err))
+		return PartyMovieNights{}, err
+	}
+
+	return nights, nil
+}
+
+// GetMovieNight returns one of the party's movie nights, ErrNotPartyMember is returned when the watcher isn't in the
+// party
+func (s MovieNightService) GetMovieNight(ctx context.Context, logger *slog.Logger, idParty, idMovieNight, idWatcher int) (MovieNight, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.GetMovieNight")
+	defer span.End()
+
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	night, err := s.getMovieNight(ctx, idParty, idMovieNight, idWatcher)
+	if err != nil {
+		if !errors.Is(err, ErrMovieNightNotFound) {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			logger.ErrorContext(ctx, "failed to get movie night", slog.Any("error", err))
+		}
+		return MovieNight{}, err
+	}
+
+	return night, nil
+}
+
+// GetSchedulableMovies returns the movies in the party that a movie night can be for
+func (s MovieNightService) GetSchedulableMovies(ctx context.Context, idParty int) ([]SchedulableMovie, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightService.GetSchedulableMovies")
+	defer span.End()
+
+	movies := make([]SchedulableMovie, 0)
+	err := s.db.GetSchedulableMovies(ctx, idParty, func(idMovie int, title string) {
+		movies = append(movies, SchedulableMovie{ID: idMovie, Title: title})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// CreateMovieNight schedules a movie night for the party, the watcher scheduling it is marked as going
+func (s MovieNightService) CreateMovieNight(ctx context.Context, logger *slog.Logger, idParty, idWatcher int, input MovieNightInput) (int, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.CreateMovieNight")
+	defer span.End()
+
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return 0, err
+	}
+
+	params, err := s.movieNightParams(ctx, idParty, input)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := s.db.CreateMovieNight(ctx, idParty, idWatcher, params)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to create movie night", slog.Any("error", err))
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// UpdateMovieNight changes a movie night, only the watcher who scheduled it or the party's owner can change it
+func (s MovieNightService) UpdateMovieNight(ctx context.Context, logger *slog.Logger, idParty, idMovieNight, idWatcher int, input MovieNightInput) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.UpdateMovieNight")
+	defer span.End()
+
+	_, err := s.getManageableMovieNight(ctx, idParty, idMovieNight, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	params, err := s.movieNightParams(ctx, idParty, input)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.UpdateMovieNight(ctx, idMovieNight, params)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrMovieNightCompleted
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to update movie night", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteMovieNight cancels a movie night, only the watcher who scheduled it or the party's owner can cancel it
+func (s MovieNightService) DeleteMovieNight(ctx context.Context, logger *slog.Logger, idParty, idMovieNight, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.DeleteMovieNight")
+	defer span.End()
+
+	_, err := s.getManageableMovieNight(ctx, idParty, idMovieNight, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.DeleteMovieNight(ctx, idMovieNight)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to delete movie night", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// SetRSVP records whether the watcher is coming to the movie night and returns the movie night with their response
+func (s MovieNightService) SetRSVP(ctx context.Context, logger *slog.Logger, idParty, idMovieNight, idWatcher int, response RSVPResponse) (MovieNight, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.SetRSVP")
+	defer span.End()
+
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	night, err := s.getMovieNight(ctx, idParty, idMovieNight, idWatcher)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	if night.IsCompleted() {
+		return MovieNight{}, ErrMovieNightCompleted
+	}
+
+	err = s.db.SetRSVP(ctx, idMovieNight, idWatcher, store.RSVPResponseEnum(response))
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to set rsvp", slog.Any("error", err))
+		return MovieNight{}, err
+	}
+
+	return s.getMovieNight(ctx, idParty, idMovieNight, idWatcher)
+}
+
+// CompleteMovieNight marks the movie night's movie as watched by the party on the night and the movie night as done, any
+// member of the party can complete it
+func (s MovieNightService) CompleteMovieNight(ctx context.Context, logger *slog.Logger, idParty, idMovieNight, idWatcher int) (MovieNight, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.CompleteMovieNight")
+	defer span.End()
+
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	night, err := s.getMovieNight(ctx, idParty, idMovieNight, idWatcher)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	if night.IsCompleted() {
+		return MovieNight{}, ErrMovieNightCompleted
+	}
+
+	if night.Movie == nil {
+		return MovieNight{}, ErrMovieNightHasNoMovie
+	}
+
+	err = markMovieAsWatched(ctx, logger, s.partyDB, idParty, night.Movie.ID, idWatcher, night.StartsAt)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to mark movie night movie as watched", slog.Any("error", err))
+		return MovieNight{}, err
+	}
+
+	publishPartyEvent(ctx, logger, s.partyDB, s.events, PartyEvent{IDParty: idParty, Type: EventMovieWatched, IDWatcher: idWatcher, IDMovie: night.Movie.ID})
+
+	// the movie is already marked as watched so completing it again after a failure here is safe
+	err = s.db.CompleteMovieNight(ctx, idMovieNight, time.Now())
+	if errors.Is(err, store.ErrNoRecord) {
+		return MovieNight{}, ErrMovieNightCompleted
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to complete movie night", slog.Any("error", err))
+		return MovieNight{}, err
+	}
+
+	return night, nil
+}
+
+// GetUpcomingMovieNights returns the movie nights in the next week across every party the watcher is in, soonest first
+func (s MovieNightService) GetUpcomingMovieNights(ctx context.Context, logger *slog.Logger, idWatcher int) ([]MovieNight, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.GetUpcomingMovieNights")
+	defer span.End()
+
+	now := time.Now()
+	until := now.Add(upcomingMovieNightsWindow)
+
+	nights, err := s.watcherMovieNights(ctx, idWatcher, now, &until)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get upcoming movie nights", slog.Any("error", err))
+		return nil, err
+	}
+
+	// completed movie nights have already happened, even if they were meant to start later
+	return slices.DeleteFunc(nights, MovieNight.IsCompleted), nil
+}
+
+// GetCalendarToken returns the token for the watcher's calendar feeds, one is made the first time they ask for it
+func (s MovieNightService) GetCalendarToken(ctx context.Context, idWatcher int) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightService.GetCalendarToken")
+	defer span.End()
+
+	token, err := s.db.GetCalendarToken(ctx, idWatcher)
+	if err == nil {
+		return token, nil
+	}
+
+	if !errors.Is(err, store.ErrNoRecord) {
+		return "", err
+	}
+
+	token, err = newCalendarToken()
+	if err != nil {
+		return "", err
+	}
+
+	// a token made at the same time by another request is kept so both get the same one back
+	err = s.db.CreateCalendarToken(ctx, idWatcher, token)
+	if err != nil {
+		return "", err
+	}
+
+	return s.db.GetCalendarToken(ctx, idWatcher)
+}
+
+// ResetCalendarToken gives the watcher a new token for their calendar feeds, feeds using the old one stop working
+func (s MovieNightService) ResetCalendarToken(ctx context.Context, idWatcher int) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightService.ResetCalendarToken")
+	defer span.End()
+
+	token, err := newCalendarToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.ReplaceCalendarToken(ctx, idWatcher, token)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GetWatcherCalendar returns the movie nights in every party the token's watcher is in, ErrCalendarNotFound is returned
+// when the token doesn't belong to anyone
+func (s MovieNightService) GetWatcherCalendar(ctx context.Context, logger *slog.Logger, token string) (Calendar, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.GetWatcherCalendar")
+	defer span.End()
+
+	idWatcher, err := s.calendarOwner(ctx, token)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	nights, err := s.watcherMovieNights(ctx, idWatcher, time.Now().Add(-calendarHistory), nil)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get movie nights for calendar", slog.Any("error", err))
+		return Calendar{}, err
+	}
+
+	return Calendar{Name: "Movie Nights", MovieNights: nights}, nil
+}
+
+// GetPartyCalendar returns the party's movie nights, ErrCalendarNotFound is returned when the token doesn't belong to a
+// member of the party
+func (s MovieNightService) GetPartyCalendar(ctx context.Context, logger *slog.Logger, token string, idParty int) (Calendar, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieNightService.GetPartyCalendar")
+	defer span.End()
+
+	idWatcher, err := s.calendarOwner(ctx, token)
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	// the feed stops working once the watcher leaves the party
+	err = s.checkMembership(ctx, idParty, idWatcher)
+	if errors.Is(err, ErrNotPartyMember) {
+		return Calendar{}, ErrCalendarNotFound
+	}
+
+	if err != nil {
+		return Calendar{}, err
+	}
+
+	calendar := Calendar{Name: "Movie Nights", MovieNights: make([]MovieNight, 0)}
+	err = s.db.GetPartyCalendarMovieNights(ctx, idParty, time.Now().Add(-calendarHistory), func(res store.MovieNightResult) {
+		calendar.Name = res.PartyName + " Movie Nights"
+		calendar.MovieNights = append(calendar.MovieNights, newMovieNight(res, idWatcher))
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get party movie nights for calendar", slog.Any("error", err))
+		return Calendar{}, err
+	}
+
+	err = s.loadRSVPs(ctx, idWatcher, calendar.MovieNights)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get rsvps for calendar", slog.Any("error", err))
+		return Calendar{}, err
+	}
+
+	return calendar, nil
+}
+
+// GetCalendarOwner returns who the calendar token belongs to so their other feeds can be found by it too,
+// ErrCalendarNotFound is returned when it doesn't belong to anyone
+func (s MovieNightService) GetCalendarOwner(ctx context.Context, token string) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightService.GetCalendarOwner")
+	defer span.End()
+
+	return s.calendarOwner(ctx, token)
+}
+
+func (s MovieNightService) calendarOwner(ctx context.Context, token string) (int, error) {
+	idWatcher, err := s.db.GetCalendarTokenOwner(ctx, token)
+	if errors.Is(err, store.ErrNoRecord) {
+		return 0, ErrCalendarNotFound
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return idWatcher, nil
+}
+
+func (s MovieNightService) watcherMovieNights(ctx context.Context, idWatcher int, from time.Time, to *time.Time) ([]MovieNight, error) {
+	nights := make([]MovieNight, 0)
+	err := s.db.GetWatcherMovieNights(ctx, idWatcher, from, to, func(res store.MovieNightResult) {
+		nights = append(nights, newMovieNight(res, idWatcher))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.loadRSVPs(ctx, idWatcher, nights)
+	if err != nil {
+		return nil, err
+	}
+
+	return nights, nil
+}
+
+func (s MovieNightService) getMovieNight(ctx context.Context, idParty, idMovieNight, idWatcher int) (MovieNight, error) {
+	res, err := s.db.GetMovieNight(ctx, idParty, idMovieNight)
+	if errors.Is(err, store.ErrNoRecord) {
+		return MovieNight{}, ErrMovieNightNotFound
+	}
+
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	nights := []MovieNight{newMovieNight(res, idWatcher)}
+	err = s.loadRSVPs(ctx, idWatcher, nights)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	return nights[0], nil
+}
+
+func (s MovieNightService) getManageableMovieNight(ctx context.Context, idParty, idMovieNight, idWatcher int) (MovieNight, error) {
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	night, err := s.getMovieNight(ctx, idParty, idMovieNight, idWatcher)
+	if err != nil {
+		return MovieNight{}, err
+	}
+
+	if !night.CanManage {
+		return MovieNight{}, ErrNotMovieNightOrganizer
+	}
+
+	if night.IsCompleted() {
+		return MovieNight{}, ErrMovieNightCompleted
+	}
+
+	return night, nil
+}
+
+// movieNightParams validates the input and turns it into what's stored
+func (s MovieNightService) movieNightParams(ctx context.Context, idParty int, input MovieNightInput) (store.MovieNightParams, error) {
+	err := input.Validate(time.Now())
+	if err != nil {
+		return store.MovieNightParams{}, err
+	}
+
+	params := store.MovieNightParams{
+		StartsAt:        input.StartsAt,
+		TimeZone:        input.TimeZone,
+		Location:        input.Location,
+		StreamURL:       input.StreamURL,
+		ReminderMinutes: input.ReminderMinutes,
+	}
+
+	if input.IDMovie == 0 {
+		return params, nil
+	}
+
+	schedulable, err := s.db.IsSchedulableMovie(ctx, idParty, input.IDMovie)
+	if err != nil {
+		return store.MovieNightParams{}, err
+	}
+
+	if !schedulable {
+		return store.MovieNightParams{}, &MovieNightValidationError{Reason: "the movie has to be one the party hasn't watched yet or vetoed"}
+	}
+
+	params.IDMovie = &input.IDMovie
+	return params, nil
+}
+
+func (s MovieNightService) checkMembership(ctx context.Context, idParty, idWatcher int) error {
+	isMember, err := s.db.IsPartyMember(ctx, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if !isMember {
+		return ErrNotPartyMember
+	}
+
+	return nil
+}
+
+// loadRSVPs fills in the responses to every movie night in the groups
+func (s MovieNightService) loadRSVPs(ctx context.Context, idWatcher int, groups ...[]MovieNight) error {
+	indexes := make(map[int]*MovieNight)
+	ids := make([]int, 0)
+	for _, nights := range groups {
+		for i := range nights {
+			indexes[nights[i].ID] = &nights[i]
+			ids = append(ids, nights[i].ID)
+		}
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return s.db.GetRSVPs(ctx, ids, func(res store.RSVPResult) {
+		night := indexes[res.IDMovieNight]
+		night.RSVPs = append(night.RSVPs, MovieNightRSVP{
+			IDWatcher: res.IDWatcher,
+			Name:      FullName{FirstName: res.FirstName, LastName: res.LastName},
+			Response:  RSVPResponse(res.Response),
+		})
+
+		if res.IDWatcher == idWatcher {
+			night.OwnRSVP = RSVPResponse(res.Response)
+		}
+	})
+}
+
+func newMovieNight(res store.MovieNightResult, idWatcher int) MovieNight {
+	// the time zone was checked when the movie night was saved, UTC is only a fallback if the zone database changes
+	loc, err := time.LoadLocation(res.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	night := MovieNight{
+		ID:              res.ID,
+		IDParty:         res.IDParty,
+		PartyName:       res.PartyName,
+		IDPartyOwner:    res.IDPartyOwner,
+		IDCreatedBy:     res.IDCreatedBy,
+		CreatedBy:       FullName{FirstName: res.CreatedFirstName, LastName: res.CreatedLastName},
+		StartsAt:        res.StartsAt.In(loc),
+		TimeZone:        res.TimeZone,
+		Location:        res.Location,
+		StreamURL:       res.StreamURL,
+		ReminderMinutes: res.ReminderMinutes,
+		Sequence:        res.Sequence,
+		CompletedAt:     res.CompletedAt,
+		UpdatedAt:       res.UpdatedAt,
+		RSVPs:           make([]MovieNightRSVP, 0),
+		CanManage:       idWatcher == res.IDCreatedBy || idWatcher == res.IDPartyOwner,
+	}
+
+	if res.IDMovie != nil {
+		night.Movie = &MovieNightMovie{ID: *res.IDMovie, Title: res.MovieTitle, Runtime: res.MovieRuntime}
+	}
+
+	return night
+}
+
+// newCalendarToken is a random token for a watcher's calendar feeds, it's in the feed's URL so it's long enough that
+// feeds can't be found by guessing
+func newCalendarToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
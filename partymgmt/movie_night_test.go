@@ -0,0 +1,135 @@
+package partymgmt_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestParseMovieNightStart(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		value       string
+		timeZone    string
+		expected    time.Time
+		expectedErr bool
+	}{
+		"wall clock time in the zone": {
+			value:    "2025-04-04T19:30",
+			timeZone: "Europe/London",
+			expected: time.Date(2025, 4, 4, 18, 30, 0, 0, time.UTC),
+		},
+		"unknown time zone":   {value: "2025-04-04T19:30", timeZone: "Mars/Olympus_Mons", expectedErr: true},
+		"missing time zone":   {value: "2025-04-04T19:30", timeZone: "", expectedErr: true},
+		"server's local zone": {value: "2025-04-04T19:30", timeZone: "Local", expectedErr: true},
+		"missing time":        {value: "2025-04-04", timeZone: "UTC", expectedErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := partymgmt.ParseMovieNightStart(tc.value, tc.timeZone)
+			if tc.expectedErr {
+				var validationErr *partymgmt.MovieNightValidationError
+				testhelpers.Assert(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+				return
+			}
+
+			testhelpers.Ok(t, err, "failed to parse start")
+			testhelpers.Assert(t, tc.expected.Equal(got), "expected %v, got %v", tc.expected, got)
+			testhelpers.Equals(t, tc.timeZone, got.Location().String())
+		})
+	}
+}
+
+func TestMovieNightInputValidate(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2025, 4, 1, 12, 0, 0, 0, time.UTC)
+	valid := partymgmt.MovieNightInput{
+		StartsAt:        now.Add(time.Hour),
+		TimeZone:        "UTC",
+		Location:        "The basement",
+		StreamURL:       "https://example.com/watch",
+		ReminderMinutes: 60,
+	}
+
+	testCases := map[string]struct {
+		modify      func(in *partymgmt.MovieNightInput)
+		expectedErr bool
+	}{
+		"valid":                    {modify: func(in *partymgmt.MovieNightInput) {}},
+		"no stream link":           {modify: func(in *partymgmt.MovieNightInput) { in.StreamURL = "" }},
+		"no reminder":              {modify: func(in *partymgmt.MovieNightInput) { in.ReminderMinutes = 0 }},
+		"starts now":               {modify: func(in *partymgmt.MovieNightInput) { in.StartsAt = now }, expectedErr: true},
+		"started already":          {modify: func(in *partymgmt.MovieNightInput) { in.StartsAt = now.Add(-time.Hour) }, expectedErr: true},
+		"stream link isn't a url":  {modify: func(in *partymgmt.MovieNightInput) { in.StreamURL = "not a link" }, expectedErr: true},
+		"stream link isn't on web": {modify: func(in *partymgmt.MovieNightInput) { in.StreamURL = "javascript:alert(1)" }, expectedErr: true},
+		"unknown reminder":         {modify: func(in *partymgmt.MovieNightInput) { in.ReminderMinutes = 7 }, expectedErr: true},
+		"location too long": {
+			modify: func(in *partymgmt.MovieNightInput) {
+				in.Location = string(make([]rune, 201))
+			},
+			expectedErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			in := valid
+			tc.modify(&in)
+
+			err := in.Validate(now)
+			if tc.expectedErr {
+				var validationErr *partymgmt.MovieNightValidationError
+				testhelpers.Assert(t, errors.As(err, &validationErr), "expected a validation error, got %v", err)
+				return
+			}
+
+			testhelpers.Ok(t, err, "expected input to be valid")
+		})
+	}
+}
+
+func TestMovieNightEndsAt(t *testing.T) {
+	t.Parallel()
+
+	startsAt := time.Date(2025, 4, 4, 19, 30, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		movie    *partymgmt.MovieNightMovie
+		expected time.Time
+	}{
+		"movie with a runtime":    {movie: &partymgmt.MovieNightMovie{Runtime: 95}, expected: startsAt.Add(95 * time.Minute)},
+		"movie without a runtime": {movie: &partymgmt.MovieNightMovie{}, expected: startsAt.Add(2 * time.Hour)},
+		"no movie picked":         {movie: nil, expected: startsAt.Add(2 * time.Hour)},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			night := partymgmt.MovieNight{StartsAt: startsAt, Movie: tc.movie}
+			testhelpers.Equals(t, tc.expected, night.EndsAt())
+		})
+	}
+}
+
+func TestParseRSVPResponse(t *testing.T) {
+	t.Parallel()
+
+	for _, response := range []partymgmt.RSVPResponse{partymgmt.RSVPGoing, partymgmt.RSVPMaybe, partymgmt.RSVPNotGoing} {
+		got, err := partymgmt.ParseRSVPResponse(string(response))
+		testhelpers.Ok(t, err, "failed to parse response")
+		testhelpers.Equals(t, response, got)
+	}
+
+	_, err := partymgmt.ParseRSVPResponse("perhaps")
+	testhelpers.Assert(t, errors.Is(err, partymgmt.ErrInvalidRSVPResponse), "expected %v, got %v", partymgmt.ErrInvalidRSVPResponse, err)
+}
@@ -0,0 +1,383 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrNotificationNotFound    = errors.New("notification not found")
+	ErrInvalidNotificationType = errors.New("invalid notification type")
+)
+
+const (
+	// NotificationsPageLimit is how many notifications the notifications page shows
+	NotificationsPageLimit = 50
+
+	// NotificationsMenuLimit is how many notifications the nav's dropdown shows
+	NotificationsMenuLimit = 5
+
+	// reminderTimeLayout is how a movie night's start is written in its reminder
+	reminderTimeLayout = "Mon, Jan 2 at 3:04 PM MST"
+)
+
+// NotificationType is what a watcher is being notified about, each one can be turned off
+type NotificationType string
+
+const (
+	NotificationPartyInvite        NotificationType = "party_invite"
+	NotificationInviteAccepted     NotificationType = "invite_accepted"
+	NotificationMovieSelected      NotificationType = "movie_selected"
+	NotificationMovieNightReminder NotificationType = "movie_night_reminder"
+	NotificationCommentMention     NotificationType = "comment_mention"
+)
+
+func ParseNotificationType(s string) (NotificationType, error) {
+	notificationType := NotificationType(s)
+	switch notificationType {
+	case NotificationPartyInvite, NotificationInviteAccepted, NotificationMovieSelected, NotificationMovieNightReminder, NotificationCommentMention:
+		return notificationType, nil
+	}
+	return "", ErrInvalidNotificationType
+}
+
+var notificationTypeLabels = []struct {
+	notificationType NotificationType
+	label            string
+}{
+	{notificationType: NotificationPartyInvite, label: "Someone invites me to a party"},
+	{notificationType: NotificationInviteAccepted, label: "Someone accepts an invite to my party"},
+	{notificationType: NotificationMovieSelected, label: "A movie is picked in one of my parties"},
+	{notificationType: NotificationMovieNightReminder, label: "A movie night is coming up"},
+	{notificationType: NotificationCommentMention, label: "Someone mentions me in a comment"},
+}
+
+// NotificationPreference is whether the watcher gets a type of notification
+type NotificationPreference struct {
+	Type    NotificationType
+	Label   string
+	Enabled bool
+}
+
+type Notification struct {
+	ID   int
+	Type NotificationType
+	// Actor is who did what the notification is about, it's empty for reminders and once they've deleted their account
+	Actor        FullName
+	IDParty      int
+	PartyName    string
+	IDMovie      int
+	MovieTitle   string
+	IDMovieNight int
+	// StartsAt is in the movie night's time zone, it's only set for reminders
+	StartsAt  *time.Time
+	ReadAt    *time.Time
+	CreatedAt time.Time
+}
+
+func (n Notification) IsRead() bool {
+	return n.ReadAt != nil
+}
+
+// Message is what the notification says happened
+func (n Notification) Message() string {
+	actor := "Someone"
+	if n.Actor.FirstName != "" {
+		actor = n.Actor.FirstName + " " + n.Actor.LastName
+	}
+
+	switch n.Type {
+	case NotificationPartyInvite:
+		return fmt.Sprintf("%s invited you to join %s", actor, n.PartyName)
+	case NotificationInviteAccepted:
+		return fmt.Sprintf("%s accepted your invite to %s", actor, n.PartyName)
+	case NotificationMovieSelected:
+		return fmt.Sprintf("%s picked %s for %s", actor, n.MovieTitle, n.PartyName)
+	case NotificationMovieNightReminder:
+		movie := "Movie night"
+		if n.MovieTitle != "" {
+			movie = n.MovieTitle
+		}
+
+		if n.StartsAt == nil {
+			return fmt.Sprintf("%s with %s is coming up", movie, n.PartyName)
+		}
+		return fmt.Sprintf("%s with %s starts %s", movie, n.PartyName, n.StartsAt.Format(reminderTimeLayout))
+	case NotificationCommentMention:
+		return fmt.Sprintf("%s mentioned you in a comment on %s in %s", actor, n.MovieTitle, n.PartyName)
+	default:
+		return string(n.Type)
+	}
+}
+
+// URL is where the watcher goes to see what the notification is about
+func (n Notification) URL() string {
+	switch n.Type {
+	case NotificationPartyInvite:
+		// invites are accepted from the list of parties
+		return "/parties"
+	case NotificationMovieNightReminder:
+		return fmt.Sprintf("/parties/%d/movie_nights", n.IDParty)
+	case NotificationCommentMention:
+		return fmt.Sprintf("/parties/%d/movies/%d/comments", n.IDParty, n.IDMovie)
+	default:
+		return fmt.Sprintf("/parties/%d", n.IDParty)
+	}
+}
+
+// NotificationService records what's happened that watchers should know about, notifying someone never fails the
+// change they're being notified about so errors creating notifications are only logged
+type NotificationService struct {
+	db *store.NotificationsRepository
+}
+
+func NewNotificationService(db *store.NotificationsRepository) NotificationService {
+	return NotificationService{db: db}
+}
+
+// NotifyInvited lets the watcher with the email know they've been invited to the party, nobody is notified when they
+// haven't signed up yet
+func (s NotificationService) NotifyInvited(ctx context.Context, logger *slog.Logger, idParty, idInviter int, email string) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "NotificationService.NotifyInvited")
+	defer span.End()
+
+	err := s.db.CreateEmailNotification(ctx, email, store.NotificationParams{
+		Type:    store.NotificationTypePartyInvite,
+		IDActor: idInviter,
+		IDParty: idParty,
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to notify invited watcher", slog.Any("error", err))
+	}
+}
+
+// NotifyMentioned lets the members mentioned in a comment on the party's movie know about it
+func (s NotificationService) NotifyMentioned(ctx context.Context, logger *slog.Logger, idParty, idMovie, idAuthor int, idMentioned []int) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "NotificationService.NotifyMentioned")
+	defer span.End()
+
+	if len(idMentioned) == 0 {
+		return
+	}
+
+	err := s.db.CreatePartyMembersByIDNotification(ctx, idMentioned, store.NotificationParams{
+		Type:    store.NotificationTypeCommentMention,
+		IDActor: idAuthor,
+		IDParty: idParty,
+		IDMovie: idMovie,
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to notify mentioned members", slog.Any("error", err))
+	}
+}
+
+// HandlePartyEvent notifies the party's owner when someone joins it and the rest of the party when a movie is picked,
+// it's added to the event bus so it runs once for every event published
+func (s NotificationService) HandlePartyEvent(ctx context.Context, logger *slog.Logger, event PartyEvent) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "NotificationService.HandlePartyEvent")
+	defer span.End()
+
+	var err error
+	switch event.Type {
+	case EventMemberJoined:
+		err = s.db.CreatePartyOwnerNotification(ctx, store.NotificationParams{
+			Type:    store.NotificationTypeInviteAccepted,
+			IDActor: event.IDWatcher,
+			IDParty: event.IDParty,
+		})
+	case EventMovieSelected:
+		err = s.db.CreatePartyMembersNotification(ctx, store.NotificationParams{
+			Type:    store.NotificationTypeMovieSelected,
+			IDActor: event.IDWatcher,
+			IDParty: event.IDParty,
+			IDMovie: event.IDMovie,
+		})
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to create notifications for party event", slog.String("type", string(event.Type)), slog.Any("error", err))
+	}
+}
+
+// StartReminderWorker reminds members about their movie nights in the background until the context is cancelled,
+// reminders go out on the first check after they're due
+func (s NotificationService) StartReminderWorker(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.sendMovieNightReminders(ctx, logger)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s NotificationService) sendMovieNightReminders(ctx context.Context, logger *slog.Logger) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "NotificationService.sendMovieNightReminders")
+	defer span.End()
+
+	created, err := s.db.CreateMovieNightReminders(ctx, time.Now().UTC())
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to create movie night reminders", slog.Any("error", err))
+		return
+	}
+
+	if created > 0 {
+		logger.InfoContext(ctx, "sent movie night reminders", slog.Int64("count", created))
+	}
+}
+
+// GetNotifications returns the watcher's latest notifications, newest first
+func (s NotificationService) GetNotifications(ctx context.Context, idWatcher, limit int) ([]Notification, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationService.GetNotifications")
+	defer span.End()
+
+	notifications := make([]Notification, 0)
+	err := s.db.GetNotifications(ctx, idWatcher, limit, func(res store.NotificationResult) {
+		notifications = append(notifications, newNotification(res))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (s NotificationService) GetUnreadCount(ctx context.Context, idWatcher int) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationService.GetUnreadCount")
+	defer span.End()
+
+	return s.db.GetUnreadCount(ctx, idWatcher)
+}
+
+// OpenNotification marks the watcher's notification as read and returns it so they can be taken to what it's about
+func (s NotificationService) OpenNotification(ctx context.Context, idWatcher, idNotification int) (Notification, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationService.OpenNotification")
+	defer span.End()
+
+	err := s.MarkAsRead(ctx, idWatcher, idNotification)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	res, err := s.db.GetNotification(ctx, idWatcher, idNotification)
+	if errors.Is(err, store.ErrNoRecord) {
+		return Notification{}, ErrNotificationNotFound
+	}
+
+	if err != nil {
+		return Notification{}, err
+	}
+
+	return newNotification(res), nil
+}
+
+func (s NotificationService) MarkAsRead(ctx context.Context, idWatcher, idNotification int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationService.MarkAsRead")
+	defer span.End()
+
+	err := s.db.MarkAsRead(ctx, idWatcher, idNotification, time.Now().UTC())
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrNotificationNotFound
+	}
+
+	return err
+}
+
+func (s NotificationService) MarkAllAsRead(ctx context.Context, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationService.MarkAllAsRead")
+	defer span.End()
+
+	return s.db.MarkAllAsRead(ctx, idWatcher, time.Now().UTC())
+}
+
+// GetPreferences returns whether the watcher gets each type of notification, every type is on until they turn it off
+func (s NotificationService) GetPreferences(ctx context.Context, idWatcher int) ([]NotificationPreference, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationService.GetPreferences")
+	defer span.End()
+
+	enabled := make(map[NotificationType]bool)
+	err := s.db.GetPreferences(ctx, idWatcher, func(notificationType store.NotificationTypeEnum, on bool) {
+		enabled[NotificationType(notificationType)] = on
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	preferences := make([]NotificationPreference, 0, len(notificationTypeLabels))
+	for _, l := range notificationTypeLabels {
+		on, ok := enabled[l.notificationType]
+		preferences = append(preferences, NotificationPreference{
+			Type:    l.notificationType,
+			Label:   l.label,
+			Enabled: !ok || on,
+		})
+	}
+
+	return preferences, nil
+}
+
+// SetPreferences turns on the types of notification in enabled and turns off every other type
+func (s NotificationService) SetPreferences(ctx context.Context, logger *slog.Logger, idWatcher int, enabled []NotificationType) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "NotificationService.SetPreferences")
+	defer span.End()
+
+	on := make(map[NotificationType]bool, len(enabled))
+	for _, notificationType := range enabled {
+		on[notificationType] = true
+	}
+
+	for _, l := range notificationTypeLabels {
+		err := s.db.SetPreference(ctx, idWatcher, store.NotificationTypeEnum(l.notificationType), on[l.notificationType])
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			logger.ErrorContext(ctx, "failed to set notification preference", slog.String("type", string(l.notificationType)), slog.Any("error", err))
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newNotification(res store.NotificationResult) Notification {
+	notification := Notification{
+		ID:           res.ID,
+		Type:         NotificationType(res.Type),
+		Actor:        FullName{FirstName: res.ActorFirstName, LastName: res.ActorLastName},
+		IDParty:      res.IDParty,
+		PartyName:    res.PartyName,
+		IDMovie:      res.IDMovie,
+		MovieTitle:   res.MovieTitle,
+		IDMovieNight: res.IDMovieNight,
+		ReadAt:       res.ReadAt,
+		CreatedAt:    res.CreatedAt,
+	}
+
+	if res.StartsAt != nil {
+		// the time zone was checked when the movie night was saved, UTC is only a fallback if the zone database changes
+		loc, err := time.LoadLocation(res.TimeZone)
+		if err != nil {
+			loc = time.UTC
+		}
+		startsAt := res.StartsAt.In(loc)
+		notification.StartsAt = &startsAt
+	}
+
+	return notification
+}
@@ -0,0 +1,91 @@
+package partymgmt_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestParseNotificationType(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		input    string
+		expected partymgmt.NotificationType
+		err      error
+	}{
+		"party invite":         {input: "party_invite", expected: partymgmt.NotificationPartyInvite},
+		"invite accepted":      {input: "invite_accepted", expected: partymgmt.NotificationInviteAccepted},
+		"movie selected":       {input: "movie_selected", expected: partymgmt.NotificationMovieSelected},
+		"movie night reminder": {input: "movie_night_reminder", expected: partymgmt.NotificationMovieNightReminder},
+		"comment mention":      {input: "comment_mention", expected: partymgmt.NotificationCommentMention},
+		"empty":                {input: "", err: partymgmt.ErrInvalidNotificationType},
+		"unknown":              {input: "party_deleted", err: partymgmt.ErrInvalidNotificationType},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			notificationType, err := partymgmt.ParseNotificationType(tc.input)
+			testhelpers.Assert(t, errors.Is(err, tc.err), "expected %v, got %v", tc.err, err)
+			testhelpers.Equals(t, tc.expected, notificationType)
+		})
+	}
+}
+
+func TestNotificationMessage(t *testing.T) {
+	t.Parallel()
+
+	startsAt := time.Date(2025, 5, 24, 20, 0, 0, 0, time.UTC)
+	actor := partymgmt.FullName{FirstName: "Ada", LastName: "Lovelace"}
+
+	tests := map[string]struct {
+		notification partymgmt.Notification
+		message      string
+		url          string
+	}{
+		"party invite": {
+			notification: partymgmt.Notification{Type: partymgmt.NotificationPartyInvite, Actor: actor, IDParty: 3, PartyName: "Film Club"},
+			message:      "Ada Lovelace invited you to join Film Club",
+			url:          "/parties",
+		},
+		"invite accepted by a deleted account": {
+			notification: partymgmt.Notification{Type: partymgmt.NotificationInviteAccepted, IDParty: 3, PartyName: "Film Club"},
+			message:      "Someone accepted your invite to Film Club",
+			url:          "/parties/3",
+		},
+		"movie selected": {
+			notification: partymgmt.Notification{Type: partymgmt.NotificationMovieSelected, Actor: actor, IDParty: 3, PartyName: "Film Club", MovieTitle: "Heat"},
+			message:      "Ada Lovelace picked Heat for Film Club",
+			url:          "/parties/3",
+		},
+		"movie night reminder": {
+			notification: partymgmt.Notification{Type: partymgmt.NotificationMovieNightReminder, IDParty: 3, PartyName: "Film Club", MovieTitle: "Heat", StartsAt: &startsAt},
+			message:      "Heat with Film Club starts Sat, May 24 at 8:00 PM UTC",
+			url:          "/parties/3/movie_nights",
+		},
+		"movie night reminder without a movie": {
+			notification: partymgmt.Notification{Type: partymgmt.NotificationMovieNightReminder, IDParty: 3, PartyName: "Film Club", StartsAt: &startsAt},
+			message:      "Movie night with Film Club starts Sat, May 24 at 8:00 PM UTC",
+			url:          "/parties/3/movie_nights",
+		},
+		"comment mention": {
+			notification: partymgmt.Notification{Type: partymgmt.NotificationCommentMention, Actor: actor, IDParty: 3, PartyName: "Film Club", IDMovie: 8, MovieTitle: "Heat"},
+			message:      "Ada Lovelace mentioned you in a comment on Heat in Film Club",
+			url:          "/parties/3/movies/8/comments",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testhelpers.Equals(t, tc.message, tc.notification.Message())
+			testhelpers.Equals(t, tc.url, tc.notification.URL())
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package partymgmt
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
@@ -12,13 +13,17 @@ import (
 	"github.com/jm96441n/movieswithfriends/partymgmt/store"
 )
 
-var ErrMemberExistsInParty = errors.New("member already exists in party")
+var (
+	ErrMemberExistsInParty = errors.New("member already exists in party")
+	ErrMovieAlreadyInParty = errors.New("movie is already on the party's list")
+)
 
 const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
 type PartyService struct {
 	logger *slog.Logger
 	db     store.PartyRepository
+	events *EventBus
 }
 
 type PartyMovie struct {
@@ -32,9 +37,45 @@ type PartyMovie struct {
 	Tagline     string    `json:"tagline"`
 	Genres      []string  `json:"genres"`
 	WatchDate   time.Time `json:"watch_date"`
-	AddedBy     FullName  `json:"added_by"`
-	AddedOn     time.Time `json:"created_at"`
-	PartyName   string
+	// ViewingDates are every time the party has watched the movie, newest first
+	ViewingDates []time.Time `json:"viewing_dates"`
+	IDAddedBy    int         `json:"id_added_by"`
+	AddedBy      FullName    `json:"added_by"`
+	AddedOn      time.Time   `json:"created_at"`
+	IDParty      int
+	PartyName    string
+	// OwnRating is the current watcher's rating of the movie, only loaded for the watch history
+	OwnRating int
+	// StreamingServices are the services members subscribe to that the movie is streaming on, only loaded for movies
+	// that haven't been watched
+	StreamingServices []StreamingService `json:"-"`
+	// Attendance is who was there when the movie was watched, only loaded for watched movies
+	Attendance MovieAttendance `json:"-"`
+	// Vetoes are the members stopping the movie from being picked, only loaded for unwatched movies
+	Vetoes []Veto `json:"-"`
+	// Flags are how the members feel about watching the movie, only loaded for movies that haven't been watched
+	Flags MovieFlags `json:"-"`
+	// Comments is how much the members have discussed the movie
+	Comments CommentsSummary `json:"-"`
+}
+
+// MovieAttendance is who was there when a party watched a movie, members who had joined the party by the watch date
+// are counted as being there until they say they weren't
+type MovieAttendance struct {
+	IDParty   int
+	IDMovie   int
+	Attendees []FullName
+	Absentees []FullName
+	// Attended is whether the current watcher was there
+	Attended bool
+}
+
+// StreamingService is a service a movie can be streamed on along with the members of the party who subscribe to it
+type StreamingService struct {
+	ID          int
+	Name        string
+	LogoURL     string
+	Subscribers []string
 }
 
 type MoviesByStatus struct {
@@ -46,9 +87,35 @@ type MoviesByStatus struct {
 type PartyMember struct {
 	FirstName string
 	LastName  string
-	ID        int
-	IDWatcher int
-	JoinedOn  time.Time
+	// DisplayName is what the member has asked to be shown as, it's empty when they haven't set one
+	DisplayName string
+	Bio         string
+	// AvatarKey is where the member's avatar is kept, it's empty when they haven't uploaded one
+	AvatarKey string
+	// FavoriteGenres are the ids of the genres the member likes most
+	FavoriteGenres []int
+	ID             int
+	IDWatcher      int
+	JoinedOn       time.Time
+}
+
+// Name is what the member is shown as in the party, their display name when they've set one
+func (m PartyMember) Name() string {
+	return cmp.Or(m.DisplayName, m.FirstName+" "+m.LastName)
+}
+
+func newPartyMember(res store.PartyMemberResult) PartyMember {
+	return PartyMember{
+		FirstName:      res.FirstName,
+		LastName:       res.LastName,
+		DisplayName:    res.DisplayName,
+		Bio:            res.Bio,
+		AvatarKey:      res.AvatarKey,
+		FavoriteGenres: res.FavoriteGenres,
+		ID:             res.ID,
+		IDWatcher:      res.IDProfile,
+		JoinedOn:       res.JoinedOn,
+	}
 }
 
 type Party struct {
@@ -63,12 +130,14 @@ type Party struct {
 
 	MoviesByStatus MoviesByStatus
 	db             store.PartyRepository
+	events         *EventBus
 }
 
-func NewPartyService(logger *slog.Logger, db store.PartyRepository) PartyService {
+func NewPartyService(logger *slog.Logger, db store.PartyRepository, events *EventBus) PartyService {
 	return PartyService{
 		logger: logger,
 		db:     db,
+		events: events,
 	}
 }
 
@@ -83,9 +152,19 @@ func (s PartyService) NewParty(ctx context.Context, id int, name string, movieCo
 		MemberCount: memberCount,
 		IDOwner:     idOwner,
 		db:          s.db,
+		events:      s.events,
 	}
 }
 
+// CheckMembership returns ErrNotPartyMember when the watcher isn't in the party, for anything that has to be ruled
+// out before work is done on the party's behalf
+func (s PartyService) CheckMembership(ctx context.Context, idParty, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.CheckMembership")
+	defer span.End()
+
+	return checkPartyMembership(ctx, s.db, idParty, idWatcher)
+}
+
 func (s PartyService) CreateParty(ctx context.Context, idMember int, name string) (int, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.CreateParty")
 	defer span.End()
@@ -94,22 +173,29 @@ func (s PartyService) CreateParty(ctx context.Context, idMember int, name string
 		id  int
 		err error
 	)
-	for i := 0; i < 5; i++ {
-		shortID := generateRandomString()
-		id, err = s.db.CreateParty(ctx, idMember, name, shortID)
-		if errors.Is(err, store.ErrDuplicatePartyShortID) {
-			continue
-		}
-		if err != nil {
-			return 0, err
+	err = s.db.RunInTransaction(ctx, func(ctx context.Context, db store.PartyRepository) error {
+		for i := 0; i < 5; i++ {
+			shortID := generateRandomString()
+			id, err = db.CreateParty(ctx, idMember, name, shortID)
+			if errors.Is(err, store.ErrDuplicatePartyShortID) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			successFullyCreated = true
+			break
 		}
 
-		successFullyCreated = true
-		break
-	}
+		if !successFullyCreated {
+			return errors.New("failed to create party")
+		}
 
-	if !successFullyCreated {
-		return 0, errors.New("failed to create party")
+		return db.RecordActivity(ctx, store.ActivityParams{IDParty: id, Type: store.ActivityTypePartyCreated, IDActor: idMember})
+	})
+	if err != nil {
+		return 0, err
 	}
 
 	return id, nil
@@ -142,9 +228,57 @@ func (s PartyService) GetPartyWithMovies(ctx context.Context, logger *slog.Logge
 
 	party.MoviesByStatus = moviesByStatus
 
+	// where the movies can be streamed is nice to have, the party is still usable without it
+	err = party.LoadStreamingServices(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get streaming services for party", slog.Any("error", err))
+	}
+
 	return party, nil
 }
 
+// GetMovieAttendance returns who was there when the party watched the movie, idWatcher is the watcher looking at it
+func (s PartyService) GetMovieAttendance(ctx context.Context, idParty, idMovie, idWatcher int) (MovieAttendance, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.GetMovieAttendance")
+	defer span.End()
+
+	attendanceByMovie, err := getAttendance(ctx, s.db, idParty, idMovie, idWatcher)
+	if err != nil {
+		return MovieAttendance{}, err
+	}
+
+	attendance := attendanceByMovie[idMovie]
+	attendance.IDParty = idParty
+	attendance.IDMovie = idMovie
+	return attendance, nil
+}
+
+// getAttendance returns the attendance for the party's watched movies keyed by movie, or only for idMovie when it's set
+func getAttendance(ctx context.Context, db store.PartyRepository, idParty, idMovie, idWatcher int) (map[int]MovieAttendance, error) {
+	attendanceByMovie := make(map[int]MovieAttendance)
+	err := db.GetAttendance(ctx, idParty, idMovie, func(res store.AttendanceResult) {
+		attendance := attendanceByMovie[res.IDMovie]
+		name := FullName{FirstName: res.FirstName, LastName: res.LastName}
+
+		if res.Attended {
+			attendance.Attendees = append(attendance.Attendees, name)
+		} else {
+			attendance.Absentees = append(attendance.Absentees, name)
+		}
+
+		if res.IDWatcher == idWatcher {
+			attendance.Attended = res.Attended
+		}
+
+		attendanceByMovie[res.IDMovie] = attendance
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return attendanceByMovie, nil
+}
+
 func (s PartyService) GetPartyByShortID(ctx context.Context, shortID string) (Party, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.GetPartyByShortID")
 	defer span.End()
@@ -159,6 +293,7 @@ func (s PartyService) GetPartyByShortID(ctx context.Context, shortID string) (Pa
 		Name:    res.Name,
 		ShortID: res.ShortID,
 		db:      s.db,
+		events:  s.events,
 	}, nil
 }
 
@@ -166,6 +301,7 @@ func (p Party) AcceptInvite(ctx context.Context, logger *slog.Logger, watcherID
 	ctx, span, _ := metrics.SpanFromContext(ctx, "Party.AddMember")
 	defer span.End()
 
+	joined := false
 	err := p.db.RunInTransaction(ctx, func(ctx context.Context, db store.PartyRepository) error {
 		err := db.DeleteInvite(ctx, watcherID, p.ID)
 		if err != nil {
@@ -185,12 +321,17 @@ func (p Party) AcceptInvite(ctx context.Context, logger *slog.Logger, watcherID
 			return err
 		}
 
-		return nil
+		joined = true
+		return db.RecordActivity(ctx, store.ActivityParams{IDParty: p.ID, Type: store.ActivityTypeMemberJoined, IDActor: watcherID})
 	})
 	if err != nil {
 		return err
 	}
 
+	if joined {
+		publishPartyEvent(ctx, logger, p.db, p.events, PartyEvent{IDParty: p.ID, Type: EventMemberJoined, IDWatcher: watcherID})
+	}
+
 	return nil
 }
 
@@ -238,14 +379,79 @@ func (p Party) GetMoviesByStatus(ctx context.Context, logger *slog.Logger) (Movi
 	return moviesByStatus, nil
 }
 
-func (p Party) AddMovie(ctx context.Context, watcherID, idMovie int) error {
+// LoadStreamingServices fills in which of the members' subscriptions the unwatched and selected movies can be streamed on
+func (p *Party) LoadStreamingServices(ctx context.Context) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "Party.LoadStreamingServices")
+	defer span.End()
+
+	servicesByMovie := make(map[int][]StreamingService)
+	err := p.db.GetStreamingServicesForParty(ctx, p.ID, func(idMovie, idProvider int, name, logoPath string, subscribers []string) {
+		servicesByMovie[idMovie] = append(servicesByMovie[idMovie], StreamingService{
+			ID:          idProvider,
+			Name:        name,
+			LogoURL:     WatchProvider{LogoPath: logoPath}.LogoURL(),
+			Subscribers: subscribers,
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for idx := range p.MoviesByStatus.UnwatchedMovies {
+		movie := &p.MoviesByStatus.UnwatchedMovies[idx]
+		movie.StreamingServices = servicesByMovie[movie.ID]
+	}
+
+	if p.MoviesByStatus.SelectedMovie != nil {
+		p.MoviesByStatus.SelectedMovie.StreamingServices = servicesByMovie[p.MoviesByStatus.SelectedMovie.ID]
+	}
+
+	return nil
+}
+
+// LoadAttendance fills in who was there for each of the watched movies, idWatcher is the watcher looking at the party
+func (p *Party) LoadAttendance(ctx context.Context, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "Party.LoadAttendance")
+	defer span.End()
+
+	attendanceByMovie, err := getAttendance(ctx, p.db, p.ID, 0, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	for idx := range p.MoviesByStatus.WatchedMovies {
+		movie := &p.MoviesByStatus.WatchedMovies[idx]
+		movie.Attendance = attendanceByMovie[movie.ID]
+		movie.Attendance.IDParty = p.ID
+		movie.Attendance.IDMovie = movie.ID
+	}
+
+	return nil
+}
+
+// AddMovie puts the movie on the party's list, ErrMovieAlreadyInParty is returned without anything being recorded when
+// it's already there and hasn't been watched
+func (p Party) AddMovie(ctx context.Context, logger *slog.Logger, watcherID, idMovie int) error {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "Party.AddMovie")
 	defer span.End()
 
-	err := p.db.CreatePartyMovie(ctx, p.ID, idMovie, watcherID)
+	err := p.db.RunInTransaction(ctx, func(ctx context.Context, db store.PartyRepository) error {
+		err := db.CreatePartyMovie(ctx, p.ID, idMovie, watcherID)
+		if errors.Is(err, store.ErrDuplicatePartyMovie) {
+			return ErrMovieAlreadyInParty
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return db.RecordActivity(ctx, store.ActivityParams{IDParty: p.ID, Type: store.ActivityTypeMovieAdded, IDActor: watcherID, IDMovie: idMovie})
+	})
 	if err != nil {
 		return err
 	}
+
+	publishPartyEvent(ctx, logger, p.db, p.events, PartyEvent{IDParty: p.ID, Type: EventMovieAdded, IDWatcher: watcherID, IDMovie: idMovie})
 	return nil
 }
 
@@ -264,14 +470,8 @@ func (p *Party) GetPartyMembers(ctx context.Context) error {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "Party.GetPartyMembers")
 	defer span.End()
 
-	err := p.db.GetPartyMembers(ctx, p.ID, func(firstName, lastName string, id int, joinedAt time.Time, idWatcher int) {
-		p.Members = append(p.Members, PartyMember{
-			FirstName: firstName,
-			LastName:  lastName,
-			ID:        id,
-			JoinedOn:  joinedAt,
-			IDWatcher: idWatcher,
-		})
+	err := p.db.GetPartyMembers(ctx, p.ID, func(res store.PartyMemberResult) {
+		p.Members = append(p.Members, newPartyMember(res))
 	})
 	if err != nil {
 		return err
@@ -279,6 +479,45 @@ func (p *Party) GetPartyMembers(ctx context.Context) error {
 	return nil
 }
 
+// PartySummary is a party as admins see it when searching, without any of its movies
+type PartySummary struct {
+	ID          int
+	Name        string
+	ShortID     string
+	IDOwner     int
+	OwnerName   FullName
+	MemberCount int
+	MovieCount  int
+	IsPublic    bool
+	CreatedAt   time.Time
+}
+
+// SearchParties returns up to limit parties whose name has term in it or whose short id is term
+func (s PartyService) SearchParties(ctx context.Context, term string, limit int) ([]PartySummary, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.SearchParties")
+	defer span.End()
+
+	parties := make([]PartySummary, 0)
+	err := s.db.SearchParties(ctx, term, limit, func(res store.PartySearchResult) {
+		parties = append(parties, PartySummary{
+			ID:          res.ID,
+			Name:        res.Name,
+			ShortID:     res.ShortID,
+			IDOwner:     res.IDOwner,
+			OwnerName:   FullName{FirstName: res.OwnerFirstName, LastName: res.OwnerLastName},
+			MemberCount: res.MemberCount,
+			MovieCount:  res.MovieCount,
+			IsPublic:    res.IsPublic,
+			CreatedAt:   res.CreatedAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parties, nil
+}
+
 // generate a random 6 character string
 func generateRandomString() string {
 	b := make([]byte, 6)
@@ -0,0 +1,190 @@
+package partymgmt
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+const (
+	// statsMonths is how many months, including the current one, the watches per month cover
+	statsMonths = 12
+
+	// maxWaitingMovies is how many of the longest waiting unwatched movies are shown
+	maxWaitingMovies = 5
+)
+
+type GenreCount struct {
+	Name  string
+	Count int
+}
+
+type MonthCount struct {
+	Month time.Time
+	Count int
+}
+
+// MemberPicks is how the movies a member has added to a party have fared
+type MemberPicks struct {
+	ID           int
+	Name         FullName
+	AddedCount   int
+	WatchedCount int
+}
+
+// SuccessRate is the percentage of the member's picks that have been watched
+func (m MemberPicks) SuccessRate() int {
+	if m.AddedCount == 0 {
+		return 0
+	}
+	return m.WatchedCount * 100 / m.AddedCount
+}
+
+type WaitingMovie struct {
+	ID      int
+	Title   string
+	AddedBy FullName
+	AddedOn time.Time
+}
+
+type PartyStats struct {
+	IDParty        int
+	PartyName      string
+	WatchedCount   int
+	UnwatchedCount int
+	MinutesWatched int
+	// AverageTimeToWatch is how long movies sit in the party before being watched, nil until something has been
+	// watched with a watch date
+	AverageTimeToWatch *time.Duration
+	Genres             []GenreCount
+	// WatchesPerMonth has an entry for each of the last 12 months, oldest first, even ones where nothing was watched
+	WatchesPerMonth []MonthCount
+	Members         []MemberPicks
+	LongestWaiting  []WaitingMovie
+}
+
+// HoursWatched is the total runtime of every watched movie rounded to the nearest hour
+func (s PartyStats) HoursWatched() int {
+	return (s.MinutesWatched + 30) / 60
+}
+
+// AverageDaysToWatch is AverageTimeToWatch rounded to whole days
+func (s PartyStats) AverageDaysToWatch() int {
+	if s.AverageTimeToWatch == nil {
+		return 0
+	}
+	return int(s.AverageTimeToWatch.Round(24*time.Hour) / (24 * time.Hour))
+}
+
+type PartyStatsService struct {
+	db *store.PartyStatsRepository
+}
+
+func NewPartyStatsService(db *store.PartyStatsRepository) PartyStatsService {
+	return PartyStatsService{db: db}
+}
+
+// GetPartyStats returns the stats for a party, ErrNotPartyMember is returned when the watcher isn't in it
+func (s PartyStatsService) GetPartyStats(ctx context.Context, logger *slog.Logger, idParty, idWatcher int, language string) (PartyStats, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "PartyStatsService.GetPartyStats")
+	defer span.End()
+
+	isMember, err := s.db.IsPartyMember(ctx, idParty, idWatcher)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to check party membership", slog.Any("error", err))
+		return PartyStats{}, err
+	}
+
+	if !isMember {
+		return PartyStats{}, ErrNotPartyMember
+	}
+
+	totals, err := s.db.GetPartyTotals(ctx, idParty)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get party totals", slog.Any("error", err))
+		return PartyStats{}, err
+	}
+
+	stats := PartyStats{
+		IDParty:         idParty,
+		PartyName:       totals.Name,
+		WatchedCount:    totals.WatchedCount,
+		UnwatchedCount:  totals.UnwatchedCount,
+		MinutesWatched:  totals.MinutesWatched,
+		Genres:          make([]GenreCount, 0),
+		Members:         make([]MemberPicks, 0),
+		LongestWaiting:  make([]WaitingMovie, 0),
+		WatchesPerMonth: make([]MonthCount, 0, statsMonths),
+	}
+
+	if totals.SecondsToWatch != nil {
+		avg := time.Duration(*totals.SecondsToWatch * float64(time.Second))
+		stats.AverageTimeToWatch = &avg
+	}
+
+	err = s.db.GetWatchedGenreCounts(ctx, idParty, language, DefaultLanguage, func(name string, count int) {
+		stats.Genres = append(stats.Genres, GenreCount{Name: name, Count: count})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get genre breakdown", slog.Any("error", err))
+		return PartyStats{}, err
+	}
+
+	firstMonth := startOfMonth(time.Now()).AddDate(0, 1-statsMonths, 0)
+	watchesByMonth := make(map[time.Time]int, statsMonths)
+	err = s.db.GetWatchesPerMonth(ctx, idParty, firstMonth, func(month time.Time, count int) {
+		watchesByMonth[startOfMonth(month)] = count
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get watches per month", slog.Any("error", err))
+		return PartyStats{}, err
+	}
+
+	for i := range statsMonths {
+		month := firstMonth.AddDate(0, i, 0)
+		stats.WatchesPerMonth = append(stats.WatchesPerMonth, MonthCount{Month: month, Count: watchesByMonth[month]})
+	}
+
+	err = s.db.GetMemberPicks(ctx, idParty, func(res store.MemberPicksResult) {
+		stats.Members = append(stats.Members, MemberPicks{
+			ID:           res.IDMember,
+			Name:         FullName{FirstName: res.FirstName, LastName: res.LastName},
+			AddedCount:   res.AddedCount,
+			WatchedCount: res.WatchedCount,
+		})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get member picks", slog.Any("error", err))
+		return PartyStats{}, err
+	}
+
+	err = s.db.GetLongestWaitingMovies(ctx, idParty, maxWaitingMovies, func(res store.WaitingMovieResult) {
+		stats.LongestWaiting = append(stats.LongestWaiting, WaitingMovie{
+			ID:      res.IDMovie,
+			Title:   res.Title,
+			AddedBy: FullName{FirstName: res.AddedByFirstName, LastName: res.AddedByLastName},
+			AddedOn: res.AddedAt,
+		})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get longest waiting movies", slog.Any("error", err))
+		return PartyStats{}, err
+	}
+
+	return stats, nil
+}
+
+// startOfMonth is midnight on the first of t's month, it's always in local time so months truncated by the database,
+// which are in the session's time zone, can be used as map keys alongside ones made here
+func startOfMonth(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}
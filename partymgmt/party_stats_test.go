@@ -0,0 +1,41 @@
+package partymgmt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestMemberPicksSuccessRate(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		picks    partymgmt.MemberPicks
+		expected int
+	}{
+		"no picks":          {picks: partymgmt.MemberPicks{}, expected: 0},
+		"none watched":      {picks: partymgmt.MemberPicks{AddedCount: 4}, expected: 0},
+		"some watched":      {picks: partymgmt.MemberPicks{AddedCount: 3, WatchedCount: 1}, expected: 33},
+		"every one watched": {picks: partymgmt.MemberPicks{AddedCount: 2, WatchedCount: 2}, expected: 100},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testhelpers.Equals(t, tc.expected, tc.picks.SuccessRate())
+		})
+	}
+}
+
+func TestPartyStatsRounding(t *testing.T) {
+	t.Parallel()
+
+	wait := 36*time.Hour + time.Minute
+	stats := partymgmt.PartyStats{MinutesWatched: 150, AverageTimeToWatch: &wait}
+
+	testhelpers.Equals(t, 3, stats.HoursWatched())
+	testhelpers.Equals(t, 2, stats.AverageDaysToWatch())
+	testhelpers.Equals(t, 0, partymgmt.PartyStats{}.AverageDaysToWatch())
+}
@@ -0,0 +1,202 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrPublicPartyNotFound    = errors.New("public party not found")
+	ErrCannotChangeVisibility = errors.New("only the party's owner can change who can see it")
+)
+
+// PublicPartyPath is where anyone can see a public party, it's made from the party's short id so it doesn't change
+// when the party is renamed
+func PublicPartyPath(shortID string) string {
+	return "/p/" + shortID
+}
+
+// PartyVisibility is whether anyone can see the party
+type PartyVisibility struct {
+	IsPublic bool
+	// Path is where the party can be seen, it's set even when the party is private so it can be shown before it's
+	// made public
+	Path string
+}
+
+// PublicMovie is one of a public party's movies as anyone can see it
+type PublicMovie struct {
+	Title       string
+	PosterURL   string
+	ReleaseDate *time.Time
+	Runtime     int
+	// Selected is whether the party is watching the movie next
+	Selected  bool
+	AddedAt   time.Time
+	WatchDate *time.Time
+	Viewings  int
+	// AverageRating is the average of the members' ratings, it's 0 when nobody has rated the movie
+	AverageRating float64
+	Ratings       int
+}
+
+func (m PublicMovie) IsRated() bool {
+	return m.Ratings > 0
+}
+
+// RatingLabel is the movie's average rating out of MaxMovieRating
+func (m PublicMovie) RatingLabel() string {
+	return fmt.Sprintf("%.1f / %d", m.AverageRating, MaxMovieRating)
+}
+
+// PublicParty is what anyone can see of a party its owner has made public, it doesn't say who is in the party
+type PublicParty struct {
+	Name        string
+	Path        string
+	MemberCount int
+	// Watchlist are the movies the party hasn't watched yet, the one being watched next is first
+	Watchlist []PublicMovie
+	// History are the movies the party has watched, the most recently watched first
+	History []PublicMovie
+}
+
+// Ratings is how many ratings the members have given the party's movies
+func (p PublicParty) Ratings() int {
+	total := 0
+	for _, movies := range [][]PublicMovie{p.Watchlist, p.History} {
+		for _, movie := range movies {
+			total += movie.Ratings
+		}
+	}
+	return total
+}
+
+// AverageRating is the average of every rating the members have given the party's movies, it's 0 when there aren't
+// any
+func (p PublicParty) AverageRating() float64 {
+	total, count := 0.0, 0
+	for _, movies := range [][]PublicMovie{p.Watchlist, p.History} {
+		for _, movie := range movies {
+			total += movie.AverageRating * float64(movie.Ratings)
+			count += movie.Ratings
+		}
+	}
+
+	if count == 0 {
+		return 0
+	}
+
+	return total / float64(count)
+}
+
+// RatingLabel is the party's average rating out of MaxMovieRating
+func (p PublicParty) RatingLabel() string {
+	return fmt.Sprintf("%.1f / %d", p.AverageRating(), MaxMovieRating)
+}
+
+type PublicPartyService struct {
+	db *store.PublicPartiesRepository
+}
+
+func NewPublicPartyService(db *store.PublicPartiesRepository) PublicPartyService {
+	return PublicPartyService{db: db}
+}
+
+// GetPublicParty returns the party at the short id when it's public
+func (s PublicPartyService) GetPublicParty(ctx context.Context, shortID string) (PublicParty, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PublicPartyService.GetPublicParty")
+	defer span.End()
+
+	res, err := s.db.GetPublicParty(ctx, shortID)
+	if errors.Is(err, store.ErrNoRecord) {
+		return PublicParty{}, ErrPublicPartyNotFound
+	}
+
+	if err != nil {
+		return PublicParty{}, err
+	}
+
+	party := PublicParty{
+		Name:        res.Name,
+		Path:        PublicPartyPath(res.ShortID),
+		MemberCount: res.MemberCount,
+		Watchlist:   make([]PublicMovie, 0),
+		History:     make([]PublicMovie, 0),
+	}
+
+	err = s.db.GetPublicPartyMovies(ctx, res.ID, func(res store.PublicMovieResult) {
+		movie := PublicMovie{
+			Title:         res.Title,
+			PosterURL:     res.PosterURL,
+			ReleaseDate:   res.ReleaseDate,
+			Runtime:       res.Runtime,
+			Selected:      res.Status == store.WatchStatusSelected,
+			AddedAt:       res.AddedAt,
+			WatchDate:     res.WatchDate,
+			Viewings:      res.Viewings,
+			AverageRating: res.AverageRating,
+			Ratings:       res.Ratings,
+		}
+
+		switch {
+		case res.Status == store.WatchStatusWatched:
+			party.History = append(party.History, movie)
+		case movie.Selected:
+			party.Watchlist = append([]PublicMovie{movie}, party.Watchlist...)
+		default:
+			party.Watchlist = append(party.Watchlist, movie)
+		}
+	})
+	if err != nil {
+		return PublicParty{}, err
+	}
+
+	return party, nil
+}
+
+// GetVisibility returns whether anyone can see the party
+func (s PublicPartyService) GetVisibility(ctx context.Context, idParty int) (PartyVisibility, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PublicPartyService.GetVisibility")
+	defer span.End()
+
+	res, err := s.db.GetPartyVisibility(ctx, idParty)
+	if err != nil {
+		return PartyVisibility{}, err
+	}
+
+	return PartyVisibility{IsPublic: res.IsPublic, Path: PublicPartyPath(res.ShortID)}, nil
+}
+
+// SetPublic lets anyone see the party or takes it back to only its members, only the party's owner can change it
+func (s PublicPartyService) SetPublic(ctx context.Context, logger *slog.Logger, idParty, idWatcher int, isPublic bool) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "PublicPartyService.SetPublic")
+	defer span.End()
+
+	visibility, err := s.db.GetPartyVisibility(ctx, idParty)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrCannotChangeVisibility
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if visibility.IDOwner != idWatcher {
+		return ErrCannotChangeVisibility
+	}
+
+	err = s.db.SetPartyPublic(ctx, idParty, isPublic)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to change party visibility", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
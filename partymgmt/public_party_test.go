@@ -0,0 +1,56 @@
+package partymgmt_test
+
+import (
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestPublicPartyPath(t *testing.T) {
+	t.Parallel()
+
+	testhelpers.Equals(t, "/p/aBc123", partymgmt.PublicPartyPath("aBc123"))
+}
+
+func TestPublicPartyAverageRating(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		party         partymgmt.PublicParty
+		expected      float64
+		expectedCount int
+		expectedLabel string
+	}{
+		"no ratings": {
+			party: partymgmt.PublicParty{
+				Watchlist: []partymgmt.PublicMovie{{Title: "Heat"}},
+			},
+			expected:      0,
+			expectedCount: 0,
+			expectedLabel: "0.0 / 5",
+		},
+		"weighted by how many times each movie was rated": {
+			party: partymgmt.PublicParty{
+				Watchlist: []partymgmt.PublicMovie{{Title: "Heat", AverageRating: 2, Ratings: 1}},
+				History: []partymgmt.PublicMovie{
+					{Title: "Jaws", AverageRating: 5, Ratings: 3},
+					{Title: "Alien"},
+				},
+			},
+			expected:      4.25,
+			expectedCount: 4,
+			expectedLabel: "4.2 / 5",
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			testhelpers.Equals(t, tc.expected, tc.party.AverageRating())
+			testhelpers.Equals(t, tc.expectedCount, tc.party.Ratings())
+			testhelpers.Equals(t, tc.expectedLabel, tc.party.RatingLabel())
+		})
+	}
+}
@@ -0,0 +1,310 @@
+package partymgmt
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+// recapListLimit is how many genres and companions a recap shows
+const recapListLimit = 3
+
+var ErrRecapNotShared = errors.New("recap isn't shared")
+
+type RecapKind string
+
+const (
+	RecapKindWatcher RecapKind = "watcher"
+	RecapKindParty   RecapKind = "party"
+)
+
+type RecapMovie struct {
+	Title     string
+	Runtime   int
+	WatchDate time.Time
+}
+
+type RecapCompanion struct {
+	Name  FullName
+	Count int
+}
+
+// Recap is a year of watching for a watcher or a party, it only ever holds names so it's safe to show publicly
+type Recap struct {
+	Kind RecapKind
+	// ID is the id of the watcher or party the recap is for
+	ID             int
+	Name           string
+	Year           int
+	MoviesWatched  int
+	MinutesWatched int
+	TopGenres      []GenreCount
+	// LongestMovie is nil when nothing watched has a runtime
+	LongestMovie *RecapMovie
+	// MostActiveMonth is nil when nothing was watched
+	MostActiveMonth *MonthCount
+	// Companions are the members watched with the most for a watcher, and the members whose picks were watched the
+	// most for a party
+	Companions []RecapCompanion
+	// ShareToken is set when the recap has a public page
+	ShareToken string
+	// Years are the years that have a recap, newest first
+	Years []int
+}
+
+func (r Recap) HoursWatched() int {
+	return (r.MinutesWatched + 30) / 60
+}
+
+type RecapService struct {
+	db *store.RecapsRepository
+	// membership is checked the same way for recaps as it is for the party's stats
+	statsDB *store.PartyStatsRepository
+}
+
+func NewRecapService(db *store.RecapsRepository, statsDB *store.PartyStatsRepository) RecapService {
+	return RecapService{db: db, statsDB: statsDB}
+}
+
+// GetWatcherRecap returns the watcher's recap for the year along with the years they can see a recap for, a year of 0
+// is the latest year they watched something in
+func (s RecapService) GetWatcherRecap(ctx context.Context, logger *slog.Logger, idWatcher, year int, language string) (Recap, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "RecapService.GetWatcherRecap")
+	defer span.End()
+
+	recap := Recap{
+		Kind: RecapKindWatcher,
+		ID:   idWatcher,
+		Year: year,
+	}
+
+	err := s.loadRecap(ctx, &recap, store.RecapScope{IDWatcher: idWatcher}, language, true)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get watcher recap", slog.Any("error", err), slog.Int("year", year))
+		return Recap{}, err
+	}
+
+	return recap, nil
+}
+
+// GetPartyRecap returns the party's recap for the year, ErrNotPartyMember is returned when the watcher isn't in it
+func (s RecapService) GetPartyRecap(ctx context.Context, logger *slog.Logger, idParty, idWatcher, year int, language string) (Recap, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "RecapService.GetPartyRecap")
+	defer span.End()
+
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return Recap{}, err
+	}
+
+	recap := Recap{
+		Kind: RecapKindParty,
+		ID:   idParty,
+		Year: year,
+	}
+
+	err = s.loadRecap(ctx, &recap, store.RecapScope{IDParty: idParty}, language, true)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get party recap", slog.Any("error", err), slog.Int("year", year))
+		return Recap{}, err
+	}
+
+	return recap, nil
+}
+
+// GetSharedRecap returns the recap shared with the token, ErrRecapNotShared is returned when nothing is shared with it
+func (s RecapService) GetSharedRecap(ctx context.Context, logger *slog.Logger, token, language string) (Recap, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "RecapService.GetSharedRecap")
+	defer span.End()
+
+	share, err := s.db.GetRecapShare(ctx, token)
+	if errors.Is(err, store.ErrNoRecord) {
+		return Recap{}, ErrRecapNotShared
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get recap share", slog.Any("error", err))
+		return Recap{}, err
+	}
+
+	recap := Recap{
+		Kind:       RecapKindWatcher,
+		ID:         share.Scope.IDWatcher,
+		Year:       share.Year,
+		ShareToken: token,
+	}
+
+	if share.Scope.IDParty != 0 {
+		recap.Kind = RecapKindParty
+		recap.ID = share.Scope.IDParty
+	}
+
+	// the other years aren't shared so they're left out of the public page
+	err = s.loadRecap(ctx, &recap, share.Scope, language, false)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get shared recap", slog.Any("error", err))
+		return Recap{}, err
+	}
+
+	return recap, nil
+}
+
+// SetWatcherRecapShared turns the public page for the watcher's recap on or off, the token for the page is returned
+// when it's turned on
+func (s RecapService) SetWatcherRecapShared(ctx context.Context, idWatcher, year int, shared bool) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapService.SetWatcherRecapShared")
+	defer span.End()
+
+	return s.setShared(ctx, store.RecapScope{IDWatcher: idWatcher}, year, idWatcher, shared)
+}
+
+// SetPartyRecapShared turns the public page for the party's recap on or off, any member of the party can change it
+func (s RecapService) SetPartyRecapShared(ctx context.Context, idParty, idWatcher, year int, shared bool) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapService.SetPartyRecapShared")
+	defer span.End()
+
+	err := s.checkMembership(ctx, idParty, idWatcher)
+	if err != nil {
+		return "", err
+	}
+
+	return s.setShared(ctx, store.RecapScope{IDParty: idParty}, year, idWatcher, shared)
+}
+
+func (s RecapService) setShared(ctx context.Context, scope store.RecapScope, year, idSharedBy int, shared bool) (string, error) {
+	if !shared {
+		return "", s.db.DeleteRecapShare(ctx, scope, year)
+	}
+
+	token, err := s.db.GetRecapShareToken(ctx, scope, year)
+	if err == nil {
+		return token, nil
+	}
+
+	if !errors.Is(err, store.ErrNoRecord) {
+		return "", err
+	}
+
+	token, err = newRecapShareToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.CreateRecapShare(ctx, token, scope, year, idSharedBy)
+	// sharing twice at the same time leaves whichever was first
+	if errors.Is(err, store.ErrRecapAlreadyShared) {
+		return s.db.GetRecapShareToken(ctx, scope, year)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func (s RecapService) checkMembership(ctx context.Context, idParty, idWatcher int) error {
+	isMember, err := s.statsDB.IsPartyMember(ctx, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if !isMember {
+		return ErrNotPartyMember
+	}
+
+	return nil
+}
+
+func (s RecapService) loadRecap(ctx context.Context, recap *Recap, scope store.RecapScope, language string, withPrivate bool) error {
+	name, err := s.db.GetRecapName(ctx, scope)
+	if err != nil {
+		return err
+	}
+	recap.Name = name
+
+	if withPrivate {
+		recap.Years, err = s.db.GetRecapYears(ctx, scope)
+		if err != nil {
+			return err
+		}
+	}
+
+	// without a year the latest one with something watched is shown
+	if recap.Year == 0 {
+		recap.Year = time.Now().Year()
+		if len(recap.Years) > 0 {
+			recap.Year = recap.Years[0]
+		}
+	}
+
+	from := time.Date(recap.Year, time.January, 1, 0, 0, 0, 0, time.Local)
+	res, err := s.db.GetRecap(ctx, scope, from, from.AddDate(1, 0, 0), language, DefaultLanguage, recapListLimit)
+	if err != nil {
+		return err
+	}
+
+	recap.MoviesWatched = res.MoviesWatched
+	recap.MinutesWatched = res.MinutesWatched
+	recap.TopGenres = make([]GenreCount, 0, len(res.TopGenres))
+	recap.Companions = make([]RecapCompanion, 0, len(res.Companions))
+
+	for _, genre := range res.TopGenres {
+		recap.TopGenres = append(recap.TopGenres, GenreCount{Name: genre.Name, Count: genre.Count})
+	}
+
+	for _, companion := range res.Companions {
+		recap.Companions = append(recap.Companions, RecapCompanion{
+			Name:  FullName{FirstName: companion.FirstName, LastName: companion.LastName},
+			Count: companion.Count,
+		})
+	}
+
+	if res.LongestMovieTitle != "" {
+		recap.LongestMovie = &RecapMovie{
+			Title:     res.LongestMovieTitle,
+			Runtime:   res.LongestMovieRuntime,
+			WatchDate: res.LongestMovieWatchDate,
+		}
+	}
+
+	if res.MostActiveMonth != 0 {
+		recap.MostActiveMonth = &MonthCount{
+			Month: time.Date(recap.Year, res.MostActiveMonth, 1, 0, 0, 0, 0, time.Local),
+			Count: res.MostActiveMonthCount,
+		}
+	}
+
+	if !withPrivate {
+		return nil
+	}
+
+	recap.ShareToken, err = s.db.GetRecapShareToken(ctx, scope, recap.Year)
+	if err != nil && !errors.Is(err, store.ErrNoRecord) {
+		return err
+	}
+
+	return nil
+}
+
+// newRecapShareToken is a random token for a recap's public page, it's long enough that pages can't be found by
+// guessing
+func newRecapShareToken() (string, error) {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,28 @@
+package partymgmt_test
+
+import (
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestRecapHoursWatched(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		minutes  int
+		expected int
+	}{
+		"nothing watched": {minutes: 0, expected: 0},
+		"rounds down":     {minutes: 89, expected: 1},
+		"rounds up":       {minutes: 90, expected: 2},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testhelpers.Equals(t, tc.expected, partymgmt.Recap{MinutesWatched: tc.minutes}.HoursWatched())
+		})
+	}
+}
@@ -0,0 +1,302 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+const (
+	// recommendationSeedRating is the rating a watched movie needs to be preferred as a seed for recommendations
+	recommendationSeedRating = 7.0
+
+	// recommendationDislikedRating is the rating below which the party didn't like a movie they watched so it's never a
+	// seed, it's out of 10 like recommendationSeedRating so a party average below 2.5 stars
+	recommendationDislikedRating = 5.0
+
+	// maxRecommendationSeeds is how many watched movies recommendations are fetched for
+	maxRecommendationSeeds = 5
+
+	// maxRecommendations is how many recommendations are shown for a party
+	maxRecommendations = 12
+
+	// weights for where a candidate came from, TMDB's recommendations are based on what people actually watched
+	// together so they're trusted more than similar which only looks at genres and keywords
+	recommendedSourceWeight = 1.0
+	similarSourceWeight     = 0.5
+
+	// weights for combining the parts of a candidate's score
+	sourceScoreWeight = 0.6
+	genreScoreWeight  = 0.3
+	ratingScoreWeight = 0.1
+
+	// watched movies say more about what a party likes than the ones sitting in their watchlist
+	watchedGenreWeight   = 2.0
+	unwatchedGenreWeight = 1.0
+)
+
+// Recommendation is a movie suggested for a party along with why it was suggested
+type Recommendation struct {
+	Movie TMDBMovie
+	Score float64
+	// Because are the titles of the party's watched movies that led to this recommendation
+	Because []string
+}
+
+// genreAffinity is how much a party likes each genre scaled between 0 and 1
+type genreAffinity map[int]float64
+
+type genreCounts struct {
+	watched   int
+	unwatched int
+}
+
+func newGenreAffinity(counts map[int]genreCounts) genreAffinity {
+	affinity := make(genreAffinity, len(counts))
+	maxWeight := 0.0
+	for idGenre, count := range counts {
+		weight := float64(count.watched)*watchedGenreWeight + float64(count.unwatched)*unwatchedGenreWeight
+		affinity[idGenre] = weight
+		maxWeight = max(maxWeight, weight)
+	}
+
+	if maxWeight == 0 {
+		return affinity
+	}
+
+	for idGenre := range affinity {
+		affinity[idGenre] /= maxWeight
+	}
+
+	return affinity
+}
+
+// score is the average affinity for a movie's genres, movies without genres get no boost
+func (a genreAffinity) score(genreIDs []int) float64 {
+	if len(genreIDs) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, genreID := range genreIDs {
+		total += a[genreID]
+	}
+
+	return total / float64(len(genreIDs))
+}
+
+type recommendationCandidate struct {
+	movie       TMDBMovie
+	sourceScore float64
+	because     []string
+}
+
+// recommendationCandidates collects the movies returned for each seed, a movie that shows up for several seeds
+// builds up a higher source score
+type recommendationCandidates map[int]*recommendationCandidate
+
+// add records the movies returned for a seed, earlier results are TMDB's stronger matches so they count for more
+func (c recommendationCandidates) add(seedTitle string, movies []TMDBMovie, sourceWeight float64) {
+	for position, movie := range movies {
+		candidate, ok := c[movie.TMDBID]
+		if !ok {
+			candidate = &recommendationCandidate{movie: movie}
+			c[movie.TMDBID] = candidate
+		}
+
+		candidate.sourceScore += sourceWeight / float64(position+1)
+		if !slices.Contains(candidate.because, seedTitle) {
+			candidate.because = append(candidate.because, seedTitle)
+		}
+	}
+}
+
+// rank scores every candidate that isn't excluded and returns the best ones first
+func (c recommendationCandidates) rank(affinity genreAffinity, exclude map[int]struct{}, limit int) []Recommendation {
+	maxSourceScore := 0.0
+	for _, candidate := range c {
+		maxSourceScore = max(maxSourceScore, candidate.sourceScore)
+	}
+
+	recommendations := make([]Recommendation, 0, len(c))
+	for tmdbID, candidate := range c {
+		if _, ok := exclude[tmdbID]; ok {
+			continue
+		}
+
+		sourceScore := 0.0
+		if maxSourceScore > 0 {
+			sourceScore = candidate.sourceScore / maxSourceScore
+		}
+
+		recommendations = append(recommendations, Recommendation{
+			Movie: candidate.movie,
+			Score: sourceScoreWeight*sourceScore +
+				genreScoreWeight*affinity.score(candidate.movie.GenreIDs) +
+				ratingScoreWeight*(candidate.movie.Rating/10),
+			Because: candidate.because,
+		})
+	}
+
+	// ties are broken by id so the order is stable between page loads
+	sort.Slice(recommendations, func(i, j int) bool {
+		if recommendations[i].Score != recommendations[j].Score {
+			return recommendations[i].Score > recommendations[j].Score
+		}
+		return recommendations[i].Movie.TMDBID < recommendations[j].Movie.TMDBID
+	})
+
+	if len(recommendations) > limit {
+		recommendations = recommendations[:limit]
+	}
+
+	return recommendations
+}
+
+type recommendationSeed struct {
+	tmdbID int
+	title  string
+}
+
+// RecommendForParty suggests movies for a party based on the movies they've watched and the genres they tend to add,
+// movies already in the party are never recommended. A party that hasn't watched anything yet gets no recommendations.
+func (m *MovieService) RecommendForParty(ctx context.Context, logger *slog.Logger, idParty int, language string) ([]Recommendation, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "MovieService.RecommendForParty")
+	defer span.End()
+
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	seeds := make([]recommendationSeed, 0, maxRecommendationSeeds)
+	err := m.db.GetRecommendationSeeds(ctx, idParty, recommendationSeedRating, recommendationDislikedRating, maxRecommendationSeeds, func(tmdbID int, title string) {
+		seeds = append(seeds, recommendationSeed{tmdbID: tmdbID, title: title})
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return nil, err
+	}
+
+	if len(seeds) == 0 {
+		return []Recommendation{}, nil
+	}
+
+	candidates, err := m.fetchRecommendationCandidates(ctx, seeds, language)
+	if err != nil {
+		// a seed failing to load still leaves the others to recommend from
+		logger.ErrorContext(ctx, "Failed to get recommendations for some movies", slog.Any("err", err), slog.Any("partyID", idParty))
+		if len(candidates) == 0 {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			return nil, err
+		}
+	}
+
+	counts := make(map[int]genreCounts)
+	err = m.db.GetPartyGenreCounts(ctx, idParty, func(idGenre, watched, unwatched int) {
+		counts[idGenre] = genreCounts{watched: watched, unwatched: unwatched}
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return nil, err
+	}
+
+	movies := make([]TMDBMovie, 0, len(candidates))
+	for _, candidate := range candidates {
+		movies = append(movies, candidate.movie)
+	}
+
+	inParty, err := m.GetMovieTMDBIDsFromCurrentParty(ctx, logger, idParty, movies)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		return nil, err
+	}
+
+	recommendations := candidates.rank(newGenreAffinity(counts), inParty, maxRecommendations)
+
+	recommendedMovies := make([]TMDBMovie, 0, len(recommendations))
+	for _, recommendation := range recommendations {
+		recommendedMovies = append(recommendedMovies, recommendation.Movie)
+	}
+
+	m.decorateMovies(ctx, logger, recommendedMovies, language)
+
+	for idx := range recommendations {
+		recommendations[idx].Movie = recommendedMovies[idx]
+	}
+
+	return recommendations, nil
+}
+
+// fetchRecommendationCandidates gets TMDB's recommended and similar movies for every seed at once
+func (m *MovieService) fetchRecommendationCandidates(ctx context.Context, seeds []recommendationSeed, language string) (recommendationCandidates, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieService.fetchRecommendationCandidates")
+	defer span.End()
+
+	type seedResult struct {
+		seed         recommendationSeed
+		movies       []TMDBMovie
+		sourceWeight float64
+	}
+
+	sources := []struct {
+		fetch  func(ctx context.Context, tmdbID int, language string) ([]TMDBMovie, error)
+		weight float64
+		name   string
+	}{
+		{fetch: m.tmdbClient.GetRecommendations, weight: recommendedSourceWeight, name: "recommendations"},
+		{fetch: m.tmdbClient.GetSimilar, weight: similarSourceWeight, name: "similar"},
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		errs    error
+		results = make([]seedResult, 0, len(seeds)*len(sources))
+	)
+
+	for _, seed := range seeds {
+		for _, source := range sources {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				movies, err := source.fetch(ctx, seed.tmdbID, language)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					errs = errors.Join(errs, fmt.Errorf("getting %s for movie %d: %w", source.name, seed.tmdbID, err))
+					return
+				}
+				results = append(results, seedResult{seed: seed, movies: movies, sourceWeight: source.weight})
+			}()
+		}
+	}
+
+	wg.Wait()
+
+	// seeds are added in the order they were ranked so the reasons for a recommendation lead with the best rated movie
+	seedOrder := make(map[int]int, len(seeds))
+	for idx, seed := range seeds {
+		seedOrder[seed.tmdbID] = idx
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].seed.tmdbID != results[j].seed.tmdbID {
+			return seedOrder[results[i].seed.tmdbID] < seedOrder[results[j].seed.tmdbID]
+		}
+		return results[i].sourceWeight > results[j].sourceWeight
+	})
+
+	candidates := make(recommendationCandidates)
+	for _, result := range results {
+		candidates.add(result.seed.title, result.movies, result.sourceWeight)
+	}
+
+	return candidates, errs
+}
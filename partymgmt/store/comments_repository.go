@@ -0,0 +1,262 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type CommentsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewCommentsRepository(db *pgxpool.Pool) *CommentsRepository {
+	return &CommentsRepository{db: db}
+}
+
+type CommentedMovieResult struct {
+	Title        string
+	IDPartyOwner int
+}
+
+const getCommentedMovieQuery = `
+  SELECT movies.title, coalesce(parties.id_owner, 0)
+  FROM party_movies
+  JOIN movies ON movies.id_movie = party_movies.id_movie
+  JOIN parties ON parties.id_party = party_movies.id_party
+  WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2;
+`
+
+// GetCommentedMovie returns the party's movie that's being discussed, ErrNoRecord is returned when it isn't in the party
+func (c *CommentsRepository) GetCommentedMovie(ctx context.Context, idParty, idMovie int) (CommentedMovieResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentsRepository.GetCommentedMovie")
+	defer span.End()
+
+	var res CommentedMovieResult
+	err := c.db.QueryRow(ctx, getCommentedMovieQuery, idParty, idMovie).Scan(&res.Title, &res.IDPartyOwner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CommentedMovieResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return CommentedMovieResult{}, err
+	}
+
+	return res, nil
+}
+
+// CommentParams is a new comment on a party's movie, IDParent is 0 when it isn't a reply
+type CommentParams struct {
+	IDParty  int
+	IDMovie  int
+	IDParent int
+	IDAuthor int
+	Body     string
+}
+
+const createCommentQuery = `
+  INSERT INTO party_movie_comments (id_party, id_movie, id_parent_comment, id_author, body)
+  VALUES ($1, $2, nullif($3::int, 0), $4, $5)
+  RETURNING id_comment;
+`
+
+func (c *CommentsRepository) CreateComment(ctx context.Context, params CommentParams) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentsRepository.CreateComment")
+	defer span.End()
+
+	var id int
+	err := c.db.QueryRow(ctx, createCommentQuery, params.IDParty, params.IDMovie, params.IDParent, params.IDAuthor, params.Body).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+type CommentResult struct {
+	ID       int
+	IDParty  int
+	IDMovie  int
+	IDParent int
+	// IDAuthor is 0 and the names are empty once the author has deleted their account
+	IDAuthor        int
+	AuthorFirstName string
+	AuthorLastName  string
+	// AuthorDisplayName and AuthorAvatarKey are empty when the author hasn't set them
+	AuthorDisplayName string
+	AuthorAvatarKey   string
+	Body              string
+	CreatedAt         time.Time
+	EditedAt          *time.Time
+	DeletedAt         *time.Time
+	IDDeletedBy       int
+}
+
+// commentColumns is shared by every query that reads comments so their rows can be scanned the same way
+const commentColumns = `
+    party_movie_comments.id_comment,
+    party_movie_comments.id_party,
+    party_movie_comments.id_movie,
+    coalesce(party_movie_comments.id_parent_comment, 0),
+    coalesce(party_movie_comments.id_author, 0),
+    coalesce(profiles.first_name, ''),
+    coalesce(profiles.last_name, ''),
+    coalesce(profiles.display_name, ''),
+    coalesce(profiles.avatar_key, ''),
+    party_movie_comments.body,
+    party_movie_comments.created_at,
+    party_movie_comments.edited_at,
+    party_movie_comments.deleted_at,
+    coalesce(party_movie_comments.id_deleted_by, 0)
+  FROM party_movie_comments
+  LEFT JOIN profiles ON profiles.id_profile = party_movie_comments.id_author
+`
+
+func scanComment(row pgx.Row) (CommentResult, error) {
+	var res CommentResult
+	err := row.Scan(
+		&res.ID,
+		&res.IDParty,
+		&res.IDMovie,
+		&res.IDParent,
+		&res.IDAuthor,
+		&res.AuthorFirstName,
+		&res.AuthorLastName,
+		&res.AuthorDisplayName,
+		&res.AuthorAvatarKey,
+		&res.Body,
+		&res.CreatedAt,
+		&res.EditedAt,
+		&res.DeletedAt,
+		&res.IDDeletedBy,
+	)
+	return res, err
+}
+
+const getCommentQuery = `SELECT` + commentColumns + `WHERE party_movie_comments.id_party = $1 AND party_movie_comments.id_comment = $2;`
+
+func (c *CommentsRepository) GetComment(ctx context.Context, idParty, idComment int) (CommentResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentsRepository.GetComment")
+	defer span.End()
+
+	res, err := scanComment(c.db.QueryRow(ctx, getCommentQuery, idParty, idComment))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CommentResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return CommentResult{}, err
+	}
+
+	return res, nil
+}
+
+const getCommentsQuery = `SELECT` + commentColumns + `
+  WHERE party_movie_comments.id_party = $1 AND party_movie_comments.id_movie = $2
+  ORDER BY party_movie_comments.id_comment;
+`
+
+// GetComments reads every comment on the party's movie, oldest first, deleted comments are included
+func (c *CommentsRepository) GetComments(ctx context.Context, idParty, idMovie int, assignFn func(CommentResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentsRepository.GetComments")
+	defer span.End()
+
+	rows, err := c.db.Query(ctx, getCommentsQuery, idParty, idMovie)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanComment(rows)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const updateCommentQuery = `
+  UPDATE party_movie_comments
+  SET body = $2, edited_at = $3
+  WHERE id_comment = $1 AND deleted_at IS NULL;
+`
+
+// UpdateComment changes what the comment says, ErrNoRecord is returned when it doesn't exist or has been deleted
+func (c *CommentsRepository) UpdateComment(ctx context.Context, idComment int, body string, editedAt time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentsRepository.UpdateComment")
+	defer span.End()
+
+	tag, err := c.db.Exec(ctx, updateCommentQuery, idComment, body, editedAt)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// the body is cleared so nothing that was said is kept once it's deleted
+const deleteCommentQuery = `
+  UPDATE party_movie_comments
+  SET body = '', deleted_at = $3, id_deleted_by = $2
+  WHERE id_comment = $1 AND deleted_at IS NULL;
+`
+
+// DeleteComment deletes the comment on behalf of idDeletedBy, ErrNoRecord is returned when it doesn't exist or has
+// already been deleted
+func (c *CommentsRepository) DeleteComment(ctx context.Context, idComment, idDeletedBy int, deletedAt time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentsRepository.DeleteComment")
+	defer span.End()
+
+	tag, err := c.db.Exec(ctx, deleteCommentQuery, idComment, idDeletedBy, deletedAt)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const getCommentCountsQuery = `
+  SELECT id_movie, count(*)
+  FROM party_movie_comments
+  WHERE id_party = $1 AND deleted_at IS NULL
+  GROUP BY id_movie;
+`
+
+// GetCommentCounts reads how many comments that haven't been deleted each of the party's movies has, movies without
+// any are left out
+func (c *CommentsRepository) GetCommentCounts(ctx context.Context, idParty int, assignFn func(idMovie, count int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "CommentsRepository.GetCommentCounts")
+	defer span.End()
+
+	rows, err := c.db.Query(ctx, getCommentCountsQuery, idParty)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var idMovie, count int
+		err = rows.Scan(&idMovie, &count)
+		if err != nil {
+			return err
+		}
+		assignFn(idMovie, count)
+	}
+
+	return rows.Err()
+}
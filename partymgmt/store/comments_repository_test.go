@@ -0,0 +1,94 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestPartyMovieComments(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_comments_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewCommentsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "chatty-party", "chattya")
+	idOwner := seedProfile(ctx, t, connPool)
+	idMember := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idOwner)
+	seedPartyMember(ctx, t, connPool, idParty, idMember)
+
+	_, err := connPool.Exec(ctx, "update parties set id_owner = $2 where id_party = $1", idParty, idOwner)
+	testhelpers.Ok(t, err, "failed to set party owner")
+
+	addedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idOwner, "Heat", 170, addedAt, nil)
+
+	var idMovie int
+	err = connPool.QueryRow(ctx, "select id_movie from movies where title = 'Heat'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie id")
+
+	movie, err := repo.GetCommentedMovie(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to get commented movie")
+	testhelpers.Equals(t, store.CommentedMovieResult{Title: "Heat", IDPartyOwner: idOwner}, movie)
+
+	_, err = repo.GetCommentedMovie(ctx, idParty, idMovie+1000)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	idRoot, err := repo.CreateComment(ctx, store.CommentParams{IDParty: idParty, IDMovie: idMovie, IDAuthor: idOwner, Body: "best heist movie"})
+	testhelpers.Ok(t, err, "failed to create comment")
+
+	idReply, err := repo.CreateComment(ctx, store.CommentParams{IDParty: idParty, IDMovie: idMovie, IDParent: idRoot, IDAuthor: idMember, Body: "too long"})
+	testhelpers.Ok(t, err, "failed to create reply")
+
+	reply, err := repo.GetComment(ctx, idParty, idReply)
+	testhelpers.Ok(t, err, "failed to get reply")
+	testhelpers.Equals(t, idRoot, reply.IDParent)
+	testhelpers.Equals(t, idMember, reply.IDAuthor)
+	testhelpers.Equals(t, "tom", reply.AuthorFirstName)
+	testhelpers.Assert(t, reply.EditedAt == nil, "expected reply to not be edited, got %v", reply.EditedAt)
+
+	_, err = repo.GetComment(ctx, idParty+1000, idReply)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	editedAt := time.Date(2025, 2, 1, 12, 0, 0, 0, time.UTC)
+	err = repo.UpdateComment(ctx, idReply, "a little long", editedAt)
+	testhelpers.Ok(t, err, "failed to update reply")
+
+	deletedAt := time.Date(2025, 2, 2, 12, 0, 0, 0, time.UTC)
+	err = repo.DeleteComment(ctx, idRoot, idOwner, deletedAt)
+	testhelpers.Ok(t, err, "failed to delete comment")
+
+	// deleted comments can't be changed or deleted again
+	err = repo.UpdateComment(ctx, idRoot, "changed my mind", editedAt)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+	err = repo.DeleteComment(ctx, idRoot, idOwner, deletedAt)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	comments := make([]store.CommentResult, 0)
+	err = repo.GetComments(ctx, idParty, idMovie, func(res store.CommentResult) {
+		comments = append(comments, res)
+	})
+	testhelpers.Ok(t, err, "failed to get comments")
+	testhelpers.Equals(t, 2, len(comments))
+	testhelpers.Equals(t, idRoot, comments[0].ID)
+	testhelpers.Equals(t, "", comments[0].Body)
+	testhelpers.Equals(t, idOwner, comments[0].IDDeletedBy)
+	testhelpers.Assert(t, comments[0].DeletedAt != nil && deletedAt.Equal(*comments[0].DeletedAt), "expected deleted at %v, got %v", deletedAt, comments[0].DeletedAt)
+	testhelpers.Equals(t, "a little long", comments[1].Body)
+	testhelpers.Assert(t, comments[1].EditedAt != nil && editedAt.Equal(*comments[1].EditedAt), "expected edited at %v, got %v", editedAt, comments[1].EditedAt)
+
+	counts := make(map[int]int)
+	err = repo.GetCommentCounts(ctx, idParty, func(idMovie, count int) {
+		counts[idMovie] = count
+	})
+	testhelpers.Ok(t, err, "failed to get comment counts")
+	testhelpers.Equals(t, map[int]int{idMovie: 1}, counts)
+}
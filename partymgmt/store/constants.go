@@ -13,6 +13,9 @@ var (
 	ErrDuplicatePartyName              = errors.New("party name already exists")
 	ErrDuplicatePartyShortID           = errors.New("party short id already exists")
 	ErrDuplicateEmailAddress           = errors.New("email address already exists")
+	ErrRecapAlreadyShared              = errors.New("recap is already shared")
+	ErrDuplicateCalendarToken          = errors.New("calendar token already exists")
+	ErrDuplicatePartyMovie             = errors.New("movie is already in the party")
 )
 
 const (
@@ -20,3 +23,64 @@ const (
 	WatchStatusSelected  WatchStatusEnum = "selected"
 	WatchStatusWatched   WatchStatusEnum = "watched"
 )
+
+// StatusChangeEnum is a change made to the status or watch date of one of a party's movies
+type StatusChangeEnum string
+
+const (
+	StatusChangeSelect        StatusChangeEnum = "select"
+	StatusChangeUnselect      StatusChangeEnum = "unselect"
+	StatusChangeWatch         StatusChangeEnum = "watch"
+	StatusChangeUnwatch       StatusChangeEnum = "unwatch"
+	StatusChangeEditWatchDate StatusChangeEnum = "edit_watch_date"
+)
+
+// MovieFlagEnum is how a member feels about watching one of the party's movies
+type MovieFlagEnum string
+
+const (
+	MovieFlagSeenIt        MovieFlagEnum = "seen_it"
+	MovieFlagMustWatch     MovieFlagEnum = "must_watch"
+	MovieFlagNotInterested MovieFlagEnum = "not_interested"
+)
+
+// ActivityTypeEnum is something that happened in a party that's kept in its activity log
+type ActivityTypeEnum string
+
+const (
+	ActivityTypePartyCreated  ActivityTypeEnum = "party_created"
+	ActivityTypeMemberJoined  ActivityTypeEnum = "member_joined"
+	ActivityTypeMovieAdded    ActivityTypeEnum = "movie_added"
+	ActivityTypeMovieSelected ActivityTypeEnum = "movie_selected"
+	ActivityTypeMovieWatched  ActivityTypeEnum = "movie_watched"
+	ActivityTypeInviteSent    ActivityTypeEnum = "invite_sent"
+)
+
+type ImportStatusEnum string
+
+const (
+	ImportStatusPreviewing ImportStatusEnum = "previewing"
+	ImportStatusQueued     ImportStatusEnum = "queued"
+	ImportStatusRunning    ImportStatusEnum = "running"
+	ImportStatusCompleted  ImportStatusEnum = "completed"
+	ImportStatusFailed     ImportStatusEnum = "failed"
+)
+
+type ImportMatchStatusEnum string
+
+const (
+	ImportMatchStatusMatched   ImportMatchStatusEnum = "matched"
+	ImportMatchStatusAmbiguous ImportMatchStatusEnum = "ambiguous"
+	ImportMatchStatusNotFound  ImportMatchStatusEnum = "not_found"
+	ImportMatchStatusFailed    ImportMatchStatusEnum = "failed"
+)
+
+type ImportRowStatusEnum string
+
+const (
+	ImportRowStatusPending      ImportRowStatusEnum = "pending"
+	ImportRowStatusSkipped      ImportRowStatusEnum = "skipped"
+	ImportRowStatusAdded        ImportRowStatusEnum = "added"
+	ImportRowStatusAlreadyAdded ImportRowStatusEnum = "already_added"
+	ImportRowStatusFailed       ImportRowStatusEnum = "failed"
+)
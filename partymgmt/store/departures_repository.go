@@ -0,0 +1,252 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+// DeparturesRepository looks after a watcher's parties when they leave Movies With Friends for good
+type DeparturesRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewDeparturesRepository(db *pgxpool.Pool) *DeparturesRepository {
+	return &DeparturesRepository{db: db}
+}
+
+type OwnedPartyResult struct {
+	ID   int
+	Name string
+	// HasHandoff is whether the owner has said what should happen to the party
+	HasHandoff bool
+	// IDNewOwner is who the party is handed to, it's 0 when it's going to be deleted
+	IDNewOwner int
+}
+
+const getOwnedPartiesQuery = `
+  SELECT
+    parties.id_party,
+    parties.name,
+    party_handoffs.id_party IS NOT NULL,
+    coalesce(party_handoffs.id_new_owner, 0)
+  FROM parties
+  LEFT JOIN party_handoffs ON party_handoffs.id_party = parties.id_party AND party_handoffs.id_owner = parties.id_owner
+  WHERE parties.id_owner = $1
+  ORDER BY parties.name, parties.id_party;
+`
+
+// GetOwnedParties returns the parties the watcher owns along with what they've said should happen to them
+func (d *DeparturesRepository) GetOwnedParties(ctx context.Context, idOwner int, assignFn func(OwnedPartyResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "DeparturesRepository.GetOwnedParties")
+	defer span.End()
+
+	rows, err := d.db.Query(ctx, getOwnedPartiesQuery, idOwner)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res OwnedPartyResult
+		err = rows.Scan(&res.ID, &res.Name, &res.HasHandoff, &res.IDNewOwner)
+		if err != nil {
+			return err
+		}
+
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const deleteHandoffsQuery = `DELETE FROM party_handoffs WHERE id_owner = $1;`
+
+// a handoff is only stored for a party the watcher owns and, when it isn't being deleted, a new owner who is one of its
+// other members
+const insertHandoffQuery = `
+  INSERT INTO party_handoffs (id_party, id_owner, id_new_owner)
+  SELECT parties.id_party, parties.id_owner, nullif($3, 0)
+  FROM parties
+  WHERE parties.id_party = $1 AND parties.id_owner = $2
+  AND (
+    $3 = 0
+    OR EXISTS (
+      SELECT 1 FROM party_members
+      WHERE party_members.id_party = parties.id_party AND party_members.id_member = $3 AND party_members.id_member != $2
+    )
+  );
+`
+
+// ReplaceHandoffs replaces what happens to the watcher's parties when they leave, newOwners is keyed by party and a
+// new owner of 0 deletes the party. ErrNoRecord is returned when one of the parties isn't theirs or the new owner
+// isn't one of its members
+func (d *DeparturesRepository) ReplaceHandoffs(ctx context.Context, idOwner int, newOwners map[int]int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "DeparturesRepository.ReplaceHandoffs")
+	defer span.End()
+
+	txn, err := d.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer txn.Rollback(ctx)
+
+	_, err = txn.Exec(ctx, deleteHandoffsQuery, idOwner)
+	if err != nil {
+		return err
+	}
+
+	for idParty, idNewOwner := range newOwners {
+		tag, err := txn.Exec(ctx, insertHandoffQuery, idParty, idOwner, idNewOwner)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrNoRecord
+		}
+	}
+
+	return txn.Commit(ctx)
+}
+
+// DeleteHandoffs forgets what the watcher said should happen to their parties
+func (d *DeparturesRepository) DeleteHandoffs(ctx context.Context, idOwner int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "DeparturesRepository.DeleteHandoffs")
+	defer span.End()
+
+	_, err := d.db.Exec(ctx, deleteHandoffsQuery, idOwner)
+	return err
+}
+
+// parties the owner asked to be deleted go, along with ones nobody else is left in to take over
+const deleteDepartingOwnersPartiesQuery = `
+  DELETE FROM parties
+  WHERE parties.id_owner = $1
+  AND (
+    EXISTS (
+      SELECT 1 FROM party_handoffs
+      WHERE party_handoffs.id_party = parties.id_party AND party_handoffs.id_owner = $1 AND party_handoffs.id_new_owner IS NULL
+    )
+    OR NOT EXISTS (
+      SELECT 1 FROM party_members
+      WHERE party_members.id_party = parties.id_party AND party_members.id_member != $1
+    )
+  );
+`
+
+// the rest go to who the owner picked, or the member who has been in the party longest when they didn't pick anyone or
+// the person they picked has left since
+const transferDepartingOwnersPartiesQuery = `
+  UPDATE parties
+  SET id_owner = coalesce(
+    (
+      SELECT party_handoffs.id_new_owner
+      FROM party_handoffs
+      JOIN party_members ON party_members.id_party = party_handoffs.id_party AND party_members.id_member = party_handoffs.id_new_owner
+      WHERE party_handoffs.id_party = parties.id_party AND party_handoffs.id_owner = $1
+    ),
+    (
+      SELECT party_members.id_member
+      FROM party_members
+      WHERE party_members.id_party = parties.id_party AND party_members.id_member != $1
+      ORDER BY party_members.created_at, party_members.id_member
+      LIMIT 1
+    )
+  )
+  WHERE parties.id_owner = $1;
+`
+
+const deleteDepartingWatchersInvitationsQuery = `DELETE FROM invitations WHERE id_profile = $1 OR lower(email) = lower($2);`
+
+// everything else that only matters to the watcher while they're around, what they've added to parties, rated and said
+// stays behind under their anonymized profile
+var removeDepartingWatcherQueries = []string{
+	`DELETE FROM party_handoffs WHERE id_owner = $1 OR id_new_owner = $1;`,
+	`DELETE FROM party_members WHERE id_member = $1;`,
+	`DELETE FROM party_movie_vetoes WHERE id_profile = $1;`,
+	`DELETE FROM party_movie_flags WHERE id_profile = $1;`,
+	`DELETE FROM movie_night_rsvps WHERE id_profile = $1;`,
+	`DELETE FROM calendar_feeds WHERE id_profile = $1;`,
+	`DELETE FROM notifications WHERE id_profile = $1;`,
+	`DELETE FROM notification_preferences WHERE id_profile = $1;`,
+	`DELETE FROM recap_shares WHERE id_profile = $1 OR id_shared_by = $1;`,
+	`DELETE FROM friendships WHERE id_requester = $1 OR id_addressee = $1;`,
+	`DELETE FROM party_imports WHERE id_profile = $1;`,
+}
+
+// the watcher is only removed while their account is still due to be deleted, they could have cancelled since the
+// account was picked up for deletion
+const checkDepartureDueQuery = `
+  SELECT 1
+  FROM accounts
+  JOIN profiles ON profiles.id_account = accounts.id_account
+  WHERE profiles.id_profile = $1 AND accounts.deletion_scheduled_for <= $2;
+`
+
+type RemoveWatcherResult struct {
+	PartiesDeleted     int64
+	PartiesTransferred int64
+}
+
+// RemoveWatcher takes the watcher out of every party, handing over or deleting the parties they own, and deletes what
+// they had in them that only mattered to them. ErrNoRecord is returned, and nothing is removed, when their account
+// isn't due to be deleted at now. It's safe to run again for a watcher who has already been removed
+func (d *DeparturesRepository) RemoveWatcher(ctx context.Context, idWatcher int, email string, now time.Time) (RemoveWatcherResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "DeparturesRepository.RemoveWatcher")
+	defer span.End()
+
+	txn, err := d.db.Begin(ctx)
+	if err != nil {
+		return RemoveWatcherResult{}, err
+	}
+
+	defer txn.Rollback(ctx)
+
+	var due int
+	err = txn.QueryRow(ctx, checkDepartureDueQuery, idWatcher, now).Scan(&due)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RemoveWatcherResult{}, ErrNoRecord
+		}
+		return RemoveWatcherResult{}, err
+	}
+
+	var res RemoveWatcherResult
+
+	tag, err := txn.Exec(ctx, deleteDepartingOwnersPartiesQuery, idWatcher)
+	if err != nil {
+		return RemoveWatcherResult{}, err
+	}
+	res.PartiesDeleted = tag.RowsAffected()
+
+	tag, err = txn.Exec(ctx, transferDepartingOwnersPartiesQuery, idWatcher)
+	if err != nil {
+		return RemoveWatcherResult{}, err
+	}
+	res.PartiesTransferred = tag.RowsAffected()
+
+	_, err = txn.Exec(ctx, deleteDepartingWatchersInvitationsQuery, idWatcher, email)
+	if err != nil {
+		return RemoveWatcherResult{}, err
+	}
+
+	for _, query := range removeDepartingWatcherQueries {
+		_, err = txn.Exec(ctx, query, idWatcher)
+		if err != nil {
+			return RemoveWatcherResult{}, err
+		}
+	}
+
+	err = txn.Commit(ctx)
+	if err != nil {
+		return RemoveWatcherResult{}, err
+	}
+
+	return res, nil
+}
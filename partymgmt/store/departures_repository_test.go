@@ -0,0 +1,120 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestRemoveWatcher(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_departures_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewDeparturesRepository(connPool)
+
+	idLeaver := seedProfile(ctx, t, connPool)
+	idPicked := seedProfile(ctx, t, connPool)
+	idOldest := seedProfile(ctx, t, connPool)
+	idNewest := seedProfile(ctx, t, connPool)
+
+	seedOwnedParty := func(name, shortID string, members ...int) int {
+		t.Helper()
+		idParty := seedParty(ctx, t, connPool, name, shortID)
+		_, err := connPool.Exec(ctx, "update parties set id_owner = $1 where id_party = $2", idLeaver, idParty)
+		testhelpers.Ok(t, err, "failed to set party owner")
+		for _, idMember := range append([]int{idLeaver}, members...) {
+			seedPartyMember(ctx, t, connPool, idParty, idMember)
+		}
+		return idParty
+	}
+
+	idHandedOver := seedOwnedParty("handed-over", "handed", idOldest, idPicked)
+	idDeleted := seedOwnedParty("deleted", "deleted", idOldest)
+	idUnplanned := seedOwnedParty("unplanned", "unplann", idOldest, idNewest)
+	idAlone := seedOwnedParty("alone", "alone")
+	idMember := seedParty(ctx, t, connPool, "member", "member")
+	seedPartyMember(ctx, t, connPool, idMember, idOldest)
+	seedPartyMember(ctx, t, connPool, idMember, idLeaver)
+	seedPartyMovie(ctx, t, connPool, idMember, idLeaver, "Heat", 170, time.Now(), nil)
+
+	// the new owner has to be one of the party's other members
+	err := repo.ReplaceHandoffs(ctx, idLeaver, map[int]int{idHandedOver: idNewest})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+	err = repo.ReplaceHandoffs(ctx, idLeaver, map[int]int{idMember: 0})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.ReplaceHandoffs(ctx, idLeaver, map[int]int{idHandedOver: idPicked, idDeleted: 0})
+	testhelpers.Ok(t, err, "failed to plan handoffs")
+
+	owned := make(map[int]store.OwnedPartyResult)
+	err = repo.GetOwnedParties(ctx, idLeaver, func(res store.OwnedPartyResult) {
+		owned[res.ID] = res
+	})
+	testhelpers.Ok(t, err, "failed to get owned parties")
+	testhelpers.Equals(t, 4, len(owned))
+	testhelpers.Equals(t, store.OwnedPartyResult{ID: idHandedOver, Name: "handed-over", HasHandoff: true, IDNewOwner: idPicked}, owned[idHandedOver])
+	testhelpers.Equals(t, store.OwnedPartyResult{ID: idDeleted, Name: "deleted", HasHandoff: true}, owned[idDeleted])
+	testhelpers.Equals(t, store.OwnedPartyResult{ID: idUnplanned, Name: "unplanned"}, owned[idUnplanned])
+
+	_, err = connPool.Exec(ctx, "insert into invitations (id_party, email) values ($1, $2)", idMember, "Tom@Bomba.com")
+	testhelpers.Ok(t, err, "failed to insert invitation")
+
+	var idAccount int
+	err = connPool.QueryRow(ctx, "insert into accounts (email, password) values ($1, $2) returning id_account", "tom@bomba.com", []byte("password")).Scan(&idAccount)
+	testhelpers.Ok(t, err, "failed to insert account")
+	_, err = connPool.Exec(ctx, "update profiles set id_account = $1 where id_profile = $2", idAccount, idLeaver)
+	testhelpers.Ok(t, err, "failed to link account")
+
+	// nothing is removed while their account isn't due to be deleted, they could have cancelled
+	now := time.Now().UTC()
+	_, err = repo.RemoveWatcher(ctx, idLeaver, "tom@bomba.com", now)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	_, err = connPool.Exec(ctx, "update accounts set deletion_scheduled_for = $2 where id_account = $1", idAccount, now.Add(time.Hour))
+	testhelpers.Ok(t, err, "failed to schedule deletion")
+	_, err = repo.RemoveWatcher(ctx, idLeaver, "tom@bomba.com", now)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+	testhelpers.Equals(t, 5, getPartyCount(ctx, t, connPool))
+
+	_, err = connPool.Exec(ctx, "update accounts set deletion_scheduled_for = $2 where id_account = $1", idAccount, now.Add(-time.Hour))
+	testhelpers.Ok(t, err, "failed to schedule deletion")
+
+	res, err := repo.RemoveWatcher(ctx, idLeaver, "tom@bomba.com", now)
+	testhelpers.Ok(t, err, "failed to remove watcher")
+	testhelpers.Equals(t, store.RemoveWatcherResult{PartiesDeleted: 2, PartiesTransferred: 2}, res)
+
+	testhelpers.Equals(t, idPicked, getOwnerForParty(ctx, t, connPool, idHandedOver))
+	// without a handoff the party goes to whoever has been in it longest
+	testhelpers.Equals(t, idOldest, getOwnerForParty(ctx, t, connPool, idUnplanned))
+	testhelpers.Equals(t, 3, getPartyCount(ctx, t, connPool))
+
+	var exists bool
+	err = connPool.QueryRow(ctx, "select exists(select 1 from parties where id_party in ($1, $2))", idDeleted, idAlone).Scan(&exists)
+	testhelpers.Ok(t, err, "failed to check for deleted parties")
+	testhelpers.Assert(t, !exists, "expected parties to be deleted")
+
+	var memberships, invitations, movies int
+	err = connPool.QueryRow(ctx, "select count(*) from party_members where id_member = $1", idLeaver).Scan(&memberships)
+	testhelpers.Ok(t, err, "failed to count memberships")
+	testhelpers.Equals(t, 0, memberships)
+	err = connPool.QueryRow(ctx, "select count(*) from invitations").Scan(&invitations)
+	testhelpers.Ok(t, err, "failed to count invitations")
+	testhelpers.Equals(t, 0, invitations)
+
+	// what they added stays in the party
+	err = connPool.QueryRow(ctx, "select count(*) from party_movies where id_party = $1 and id_added_by = $2", idMember, idLeaver).Scan(&movies)
+	testhelpers.Ok(t, err, "failed to count party movies")
+	testhelpers.Equals(t, 1, movies)
+
+	// running it again does nothing
+	res, err = repo.RemoveWatcher(ctx, idLeaver, "tom@bomba.com", now)
+	testhelpers.Ok(t, err, "failed to remove watcher again")
+	testhelpers.Equals(t, store.RemoveWatcherResult{}, res)
+}
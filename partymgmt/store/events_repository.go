@@ -0,0 +1,56 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+// PartyEventsChannel is the postgres channel party events are sent on so every instance of the app hears about them
+const PartyEventsChannel = "party_events"
+
+type EventsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewEventsRepository(db *pgxpool.Pool) *EventsRepository {
+	return &EventsRepository{db: db}
+}
+
+const notifyQuery = `SELECT pg_notify($1, $2);`
+
+// Notify sends the payload to everything listening on the channel, postgres caps payloads at 8000 bytes
+func (e *EventsRepository) Notify(ctx context.Context, channel, payload string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "EventsRepository.Notify")
+	defer span.End()
+
+	_, err := e.db.Exec(ctx, notifyQuery, channel, payload)
+	return err
+}
+
+// Listen holds a connection listening on the channel and calls assignFn with the payload of every notification sent on
+// it, it only returns when the context is cancelled or the connection is lost
+func (e *EventsRepository) Listen(ctx context.Context, channel string, assignFn func(payload string)) error {
+	conn, err := e.db.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+
+	// the connection is still listening so it can't go back to the pool
+	defer conn.Hijack().Close(context.Background())
+
+	_, err = conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		assignFn(notification.Payload)
+	}
+}
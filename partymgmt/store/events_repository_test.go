@@ -0,0 +1,55 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestEventsNotifyAndListen(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_events_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewEventsRepository(connPool)
+
+	// the channel is per database so the test gets its own to not hear other tests
+	channel := schemaName + "_events"
+
+	listenCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+
+	listening := make(chan error, 1)
+	payloads := make(chan string, 1)
+	go func() {
+		listening <- repo.Listen(listenCtx, channel, func(payload string) {
+			payloads <- payload
+		})
+	}()
+
+	// keep notifying until the listener has started since notifications sent before then are missed
+	for received := false; !received; {
+		err := repo.Notify(ctx, channel, "hello")
+		testhelpers.Ok(t, err, "failed to notify")
+
+		select {
+		case payload := <-payloads:
+			testhelpers.Equals(t, "hello", payload)
+			received = true
+		case <-time.After(100 * time.Millisecond):
+		case <-listenCtx.Done():
+			t.Fatal("never heard the notification")
+		}
+	}
+
+	cancel()
+
+	err := <-listening
+	testhelpers.Assert(t, errors.Is(err, context.Canceled), "expected %v, got %v", context.Canceled, err)
+}
@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type ExportsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewExportsRepository(db *pgxpool.Pool) *ExportsRepository {
+	return &ExportsRepository{db: db}
+}
+
+type ExportedMovieResult struct {
+	TMDBID      int
+	Title       string
+	ReleaseDate *time.Time
+	WatchStatus WatchStatusEnum
+	// AddedByFirstName and AddedByLastName are empty when the person who added the movie no longer has a profile
+	AddedByFirstName string
+	AddedByLastName  string
+	AddedAt          time.Time
+	WatchDate        *time.Time
+	PartyName        string
+}
+
+// exportedMovieColumns is shared by every export query so their rows can be scanned the same way
+const exportedMovieColumns = `
+    movies.tmdb_id,
+    movies.title,
+    movies.release_date,
+    party_movies.watch_status::text,
+    coalesce(profiles.first_name, ''),
+    coalesce(profiles.last_name, ''),
+    party_movies.created_at,
+    party_movies.watch_date,
+    parties.name
+`
+
+const isPartyMemberQuery = `SELECT EXISTS(select 1 from party_members where id_party = $1 and id_member = $2)`
+
+func (e *ExportsRepository) IsPartyMember(ctx context.Context, idParty, idWatcher int) (bool, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ExportsRepository.IsPartyMember")
+	defer span.End()
+
+	var isMember bool
+	err := e.db.QueryRow(ctx, isPartyMemberQuery, idParty, idWatcher).Scan(&isMember)
+	if err != nil {
+		return false, err
+	}
+
+	return isMember, nil
+}
+
+const streamPartyMoviesQuery = `
+  SELECT` + exportedMovieColumns + `
+  FROM party_movies
+  JOIN movies ON movies.id_movie = party_movies.id_movie
+  JOIN parties ON parties.id_party = party_movies.id_party
+  LEFT JOIN profiles ON profiles.id_profile = party_movies.id_added_by
+  WHERE party_movies.id_party = $1
+  ORDER BY party_movies.created_at, party_movies.id;
+`
+
+// StreamPartyMovies calls assignFn with every movie in the party as it's read, returning an error from assignFn stops
+// the stream
+func (e *ExportsRepository) StreamPartyMovies(ctx context.Context, idParty int, assignFn func(ExportedMovieResult) error) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ExportsRepository.StreamPartyMovies")
+	defer span.End()
+
+	return e.streamExportedMovies(ctx, streamPartyMoviesQuery, idParty, assignFn)
+}
+
+// the watch history has a row for every viewing so it's the same columns as exportedMovieColumns with the date of the
+// viewing instead of the latest one
+const streamWatchHistoryQuery = `
+  SELECT
+    movies.tmdb_id,
+    movies.title,
+    movies.release_date,
+    party_movies.watch_status::text,
+    coalesce(profiles.first_name, ''),
+    coalesce(profiles.last_name, ''),
+    party_movies.created_at,
+    party_movie_viewings.watch_date,
+    parties.name
+  FROM party_movie_viewings
+  JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+  JOIN movies ON movies.id_movie = party_movies.id_movie
+  JOIN parties ON parties.id_party = party_movies.id_party
+  JOIN party_movie_attendance ON party_movie_attendance.id_viewing = party_movie_viewings.id_viewing
+  LEFT JOIN profiles ON profiles.id_profile = party_movies.id_added_by
+  WHERE party_movie_attendance.id_profile = $1 AND party_movie_attendance.attended
+  ORDER BY party_movie_viewings.watch_date DESC, party_movie_viewings.id_viewing;
+`
+
+// StreamWatchHistory calls assignFn with every viewing the watcher was there for when their party watched a movie,
+// newest first
+func (e *ExportsRepository) StreamWatchHistory(ctx context.Context, idWatcher int, assignFn func(ExportedMovieResult) error) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ExportsRepository.StreamWatchHistory")
+	defer span.End()
+
+	return e.streamExportedMovies(ctx, streamWatchHistoryQuery, idWatcher, assignFn)
+}
+
+const streamMoviesAddedByQuery = `
+  SELECT` + exportedMovieColumns + `
+  FROM party_movies
+  JOIN movies ON movies.id_movie = party_movies.id_movie
+  JOIN parties ON parties.id_party = party_movies.id_party
+  LEFT JOIN profiles ON profiles.id_profile = party_movies.id_added_by
+  WHERE party_movies.id_added_by = $1
+  ORDER BY party_movies.created_at, party_movies.id;
+`
+
+// StreamMoviesAddedBy calls assignFn with every movie the watcher has added to a party
+func (e *ExportsRepository) StreamMoviesAddedBy(ctx context.Context, idWatcher int, assignFn func(ExportedMovieResult) error) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ExportsRepository.StreamMoviesAddedBy")
+	defer span.End()
+
+	return e.streamExportedMovies(ctx, streamMoviesAddedByQuery, idWatcher, assignFn)
+}
+
+func (e *ExportsRepository) streamExportedMovies(ctx context.Context, query string, id int, assignFn func(ExportedMovieResult) error) error {
+	rows, err := e.db.Query(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var movie ExportedMovieResult
+		err := rows.Scan(
+			&movie.TMDBID,
+			&movie.Title,
+			&movie.ReleaseDate,
+			&movie.WatchStatus,
+			&movie.AddedByFirstName,
+			&movie.AddedByLastName,
+			&movie.AddedAt,
+			&movie.WatchDate,
+			&movie.PartyName,
+		)
+		if err != nil {
+			return err
+		}
+
+		err = assignFn(movie)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+const getMembershipsForWatcherQuery = `
+  SELECT parties.id_party, parties.name, 'member', party_members.created_at, coalesce(parties.id_owner = $1, false)
+  FROM party_members
+  JOIN parties ON parties.id_party = party_members.id_party
+  WHERE party_members.id_member = $1
+  UNION ALL
+  SELECT parties.id_party, parties.name, 'invited', invitations.created_at, false
+  FROM invitations
+  JOIN parties ON parties.id_party = invitations.id_party
+  WHERE invitations.id_profile = $1
+  ORDER BY 4;
+`
+
+// GetMembershipsForWatcher returns every party the watcher is in or has been invited to, kind is either member or
+// invited and since is when they joined or were invited
+func (e *ExportsRepository) GetMembershipsForWatcher(ctx context.Context, idWatcher int, assignFn func(idParty int, name, kind string, since time.Time, isOwner bool)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ExportsRepository.GetMembershipsForWatcher")
+	defer span.End()
+
+	rows, err := e.db.Query(ctx, getMembershipsForWatcherQuery, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			idParty int
+			name    string
+			kind    string
+			since   time.Time
+			isOwner bool
+		)
+		err := rows.Scan(&idParty, &name, &kind, &since, &isOwner)
+		if err != nil {
+			return err
+		}
+
+		assignFn(idParty, name, kind, since, isOwner)
+	}
+
+	return rows.Err()
+}
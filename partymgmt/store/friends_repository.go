@@ -0,0 +1,349 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type FriendshipStatusEnum string
+
+const (
+	FriendshipStatusPending  FriendshipStatusEnum = "pending"
+	FriendshipStatusAccepted FriendshipStatusEnum = "accepted"
+)
+
+type FriendsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewFriendsRepository(db *pgxpool.Pool) *FriendsRepository {
+	return &FriendsRepository{db: db}
+}
+
+// FriendshipResult is a friendship as one of the watchers in it sees it, IDFriend and the names are the other watcher's
+type FriendshipResult struct {
+	ID          int
+	IDRequester int
+	Status      FriendshipStatusEnum
+	CreatedAt   time.Time
+	RespondedAt *time.Time
+	IDFriend    int
+	FirstName   string
+	LastName    string
+}
+
+const getFriendshipsQuery = `
+  SELECT
+    friendships.id_friendship,
+    friendships.id_requester,
+    friendships.status::text,
+    friendships.created_at,
+    friendships.responded_at,
+    profiles.id_profile,
+    profiles.first_name,
+    profiles.last_name
+  FROM friendships
+  JOIN profiles ON profiles.id_profile = CASE
+    WHEN friendships.id_requester = $1 THEN friendships.id_addressee
+    ELSE friendships.id_requester
+  END
+  WHERE friendships.id_requester = $1 OR friendships.id_addressee = $1
+  ORDER BY profiles.first_name, profiles.last_name;
+`
+
+// GetFriendships returns the watcher's friends and the friend requests they've sent or been sent
+func (f *FriendsRepository) GetFriendships(ctx context.Context, idWatcher int, assignFn func(FriendshipResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.GetFriendships")
+	defer span.End()
+
+	rows, err := f.db.Query(ctx, getFriendshipsQuery, idWatcher)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res FriendshipResult
+		err = rows.Scan(
+			&res.ID,
+			&res.IDRequester,
+			&res.Status,
+			&res.CreatedAt,
+			&res.RespondedAt,
+			&res.IDFriend,
+			&res.FirstName,
+			&res.LastName,
+		)
+		if err != nil {
+			return err
+		}
+
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const getFriendshipBetweenQuery = `
+  SELECT id_friendship, id_requester, status::text, created_at, responded_at
+  FROM friendships
+  WHERE least(id_requester, id_addressee) = least($1::int, $2::int)
+    AND greatest(id_requester, id_addressee) = greatest($1::int, $2::int);
+`
+
+// GetFriendshipBetween returns the friendship between the two watchers whoever asked, ErrNoRecord is returned when
+// neither has sent a request
+func (f *FriendsRepository) GetFriendshipBetween(ctx context.Context, idWatcher, idOther int) (FriendshipResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.GetFriendshipBetween")
+	defer span.End()
+
+	res := FriendshipResult{IDFriend: idOther}
+	err := f.db.QueryRow(ctx, getFriendshipBetweenQuery, idWatcher, idOther).Scan(
+		&res.ID,
+		&res.IDRequester,
+		&res.Status,
+		&res.CreatedAt,
+		&res.RespondedAt,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return FriendshipResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return FriendshipResult{}, err
+	}
+
+	return res, nil
+}
+
+// only watchers that exist can be sent a request so a missing one comes back as no rows
+const createFriendRequestQuery = `
+  INSERT INTO friendships (id_requester, id_addressee)
+  SELECT $1, id_profile FROM profiles WHERE id_profile = $2
+  RETURNING id_friendship;
+`
+
+// CreateFriendRequest sends a friend request from the requester, ErrNoRecord is returned when the addressee doesn't
+// exist
+func (f *FriendsRepository) CreateFriendRequest(ctx context.Context, idRequester, idAddressee int) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.CreateFriendRequest")
+	defer span.End()
+
+	var id int
+	err := f.db.QueryRow(ctx, createFriendRequestQuery, idRequester, idAddressee).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNoRecord
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+const acceptFriendRequestQuery = `
+  UPDATE friendships
+  SET status = 'accepted', responded_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_friendship = $1 AND id_addressee = $2 AND status = 'pending';
+`
+
+// AcceptFriendRequest accepts a request sent to the addressee, ErrNoRecord is returned when there isn't a pending
+// request for them to accept
+func (f *FriendsRepository) AcceptFriendRequest(ctx context.Context, idFriendship, idAddressee int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.AcceptFriendRequest")
+	defer span.End()
+
+	tag, err := f.db.Exec(ctx, acceptFriendRequestQuery, idFriendship, idAddressee)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const deleteFriendshipQuery = `
+  DELETE FROM friendships
+  WHERE id_friendship = $1 AND (id_requester = $2 OR id_addressee = $2);
+`
+
+// DeleteFriendship removes a friend or a request either watcher in it is done with, ErrNoRecord is returned when the
+// watcher isn't in the friendship
+func (f *FriendsRepository) DeleteFriendship(ctx context.Context, idFriendship, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.DeleteFriendship")
+	defer span.End()
+
+	tag, err := f.db.Exec(ctx, deleteFriendshipQuery, idFriendship, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+// SharedPartyResult is a party the watcher shares with another member of it
+type SharedPartyResult struct {
+	IDMember  int
+	FirstName string
+	LastName  string
+	IDParty   int
+	PartyName string
+}
+
+const getSharedPartiesQuery = `
+  SELECT profiles.id_profile, profiles.first_name, profiles.last_name, parties.id_party, parties.name
+  FROM party_members mine
+  JOIN party_members theirs ON theirs.id_party = mine.id_party AND theirs.id_member <> mine.id_member
+  JOIN parties ON parties.id_party = mine.id_party
+  JOIN profiles ON profiles.id_profile = theirs.id_member
+  WHERE mine.id_member = $1
+  ORDER BY profiles.first_name, profiles.last_name, parties.name;
+`
+
+// GetSharedParties returns everyone the watcher is in a party with once for each party they share
+func (f *FriendsRepository) GetSharedParties(ctx context.Context, idWatcher int, assignFn func(SharedPartyResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.GetSharedParties")
+	defer span.End()
+
+	rows, err := f.db.Query(ctx, getSharedPartiesQuery, idWatcher)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res SharedPartyResult
+		err = rows.Scan(&res.IDMember, &res.FirstName, &res.LastName, &res.IDParty, &res.PartyName)
+		if err != nil {
+			return err
+		}
+
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+// ContactResult is someone the watcher knows, either as a friend or from a party they share
+type ContactResult struct {
+	ID        int
+	FirstName string
+	LastName  string
+	IsFriend  bool
+}
+
+// ContactSearchParams looks for the watcher's contacts by name who could be invited to the party
+type ContactSearchParams struct {
+	IDWatcher int
+	IDParty   int
+	// Search is matched anywhere in their full name, it matches everyone when it's empty
+	Search string
+	Limit  int
+}
+
+// contacts are accepted friends plus everyone the watcher shares a party with, members of the party and anyone
+// already invited to it are left out since there's no point suggesting them
+const searchContactsQuery = `
+  WITH contacts AS (
+    SELECT
+      CASE WHEN friendships.id_requester = $1 THEN friendships.id_addressee ELSE friendships.id_requester END AS id_profile,
+      true AS is_friend
+    FROM friendships
+    WHERE (friendships.id_requester = $1 OR friendships.id_addressee = $1) AND friendships.status = 'accepted'
+    UNION ALL
+    SELECT theirs.id_member, false
+    FROM party_members mine
+    JOIN party_members theirs ON theirs.id_party = mine.id_party AND theirs.id_member <> mine.id_member
+    WHERE mine.id_member = $1
+  )
+  SELECT profiles.id_profile, profiles.first_name, profiles.last_name, bool_or(contacts.is_friend)
+  FROM contacts
+  JOIN profiles ON profiles.id_profile = contacts.id_profile
+  WHERE (profiles.first_name || ' ' || profiles.last_name) ILIKE '%' || $3 || '%'
+    AND NOT EXISTS (
+      SELECT 1 FROM party_members WHERE party_members.id_party = $2 AND party_members.id_member = profiles.id_profile
+    )
+    AND NOT EXISTS (
+      SELECT 1 FROM invitations WHERE invitations.id_party = $2 AND invitations.id_profile = profiles.id_profile
+    )
+  GROUP BY profiles.id_profile, profiles.first_name, profiles.last_name
+  ORDER BY bool_or(contacts.is_friend) DESC, profiles.first_name, profiles.last_name
+  LIMIT $4;
+`
+
+// SearchContacts returns the watcher's contacts matching the search who aren't in or invited to the party, friends
+// come first
+func (f *FriendsRepository) SearchContacts(ctx context.Context, params ContactSearchParams, assignFn func(ContactResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.SearchContacts")
+	defer span.End()
+
+	rows, err := f.db.Query(ctx, searchContactsQuery, params.IDWatcher, params.IDParty, escapeLike(params.Search), params.Limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res ContactResult
+		err = rows.Scan(&res.ID, &res.FirstName, &res.LastName, &res.IsFriend)
+		if err != nil {
+			return err
+		}
+
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const getContactEmailQuery = `
+  SELECT accounts.email
+  FROM profiles
+  JOIN accounts ON accounts.id_account = profiles.id_account
+  WHERE profiles.id_profile = $2
+    AND (
+      EXISTS (
+        SELECT 1 FROM friendships
+        WHERE friendships.status = 'accepted'
+          AND least(friendships.id_requester, friendships.id_addressee) = least($1::int, $2::int)
+          AND greatest(friendships.id_requester, friendships.id_addressee) = greatest($1::int, $2::int)
+      )
+      OR EXISTS (
+        SELECT 1 FROM party_members mine
+        JOIN party_members theirs ON theirs.id_party = mine.id_party
+        WHERE mine.id_member = $1 AND theirs.id_member = $2
+      )
+    );
+`
+
+// GetContactEmail returns the email of one of the watcher's contacts, ErrNoRecord is returned when they aren't friends
+// and don't share a party
+func (f *FriendsRepository) GetContactEmail(ctx context.Context, idWatcher, idContact int) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "FriendsRepository.GetContactEmail")
+	defer span.End()
+
+	var email string
+	err := f.db.QueryRow(ctx, getContactEmailQuery, idWatcher, idContact).Scan(&email)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNoRecord
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return email, nil
+}
@@ -0,0 +1,115 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestFriendships(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_friends_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewFriendsRepository(connPool)
+
+	idWatcher := seedProfile(ctx, t, connPool)
+	idPartyMate := seedProfile(ctx, t, connPool)
+	idFriend := seedProfile(ctx, t, connPool)
+	idStranger := seedProfile(ctx, t, connPool)
+	for id, name := range map[int]string{idWatcher: "walt", idPartyMate: "pam", idFriend: "fran", idStranger: "stan"} {
+		_, err := connPool.Exec(ctx, "update profiles set first_name = $2 where id_profile = $1", id, name)
+		testhelpers.Ok(t, err, "failed to name profile")
+	}
+
+	idParty := seedParty(ctx, t, connPool, "friendly-party", "friendl")
+	idOtherParty := seedParty(ctx, t, connPool, "another-party", "anothep")
+	seedPartyMember(ctx, t, connPool, idParty, idWatcher)
+	seedPartyMember(ctx, t, connPool, idParty, idPartyMate)
+	seedPartyMember(ctx, t, connPool, idOtherParty, idWatcher)
+
+	shared := make([]store.SharedPartyResult, 0)
+	err := repo.GetSharedParties(ctx, idWatcher, func(res store.SharedPartyResult) {
+		shared = append(shared, res)
+	})
+	testhelpers.Ok(t, err, "failed to get shared parties")
+	testhelpers.Equals(t, []store.SharedPartyResult{
+		{IDMember: idPartyMate, FirstName: "pam", LastName: "bomba", IDParty: idParty, PartyName: "friendly-party"},
+	}, shared)
+
+	_, err = repo.GetFriendshipBetween(ctx, idWatcher, idFriend)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	idFriendship, err := repo.CreateFriendRequest(ctx, idFriend, idWatcher)
+	testhelpers.Ok(t, err, "failed to create friend request")
+
+	_, err = repo.CreateFriendRequest(ctx, idWatcher, idStranger+1000)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	// there's only ever one friendship between two watchers whoever sent it
+	_, err = repo.CreateFriendRequest(ctx, idWatcher, idFriend)
+	testhelpers.Assert(t, err != nil, "expected a second request between the same watchers to fail")
+
+	between, err := repo.GetFriendshipBetween(ctx, idWatcher, idFriend)
+	testhelpers.Ok(t, err, "failed to get friendship")
+	testhelpers.Equals(t, idFriendship, between.ID)
+	testhelpers.Equals(t, idFriend, between.IDRequester)
+	testhelpers.Equals(t, store.FriendshipStatusPending, between.Status)
+
+	// only the watcher the request was sent to can accept it
+	err = repo.AcceptFriendRequest(ctx, idFriendship, idFriend)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.AcceptFriendRequest(ctx, idFriendship, idWatcher)
+	testhelpers.Ok(t, err, "failed to accept friend request")
+
+	_, err = repo.CreateFriendRequest(ctx, idWatcher, idStranger)
+	testhelpers.Ok(t, err, "failed to create friend request")
+
+	friendships := make([]store.FriendshipResult, 0)
+	err = repo.GetFriendships(ctx, idWatcher, func(res store.FriendshipResult) {
+		friendships = append(friendships, res)
+	})
+	testhelpers.Ok(t, err, "failed to get friendships")
+	testhelpers.Equals(t, 2, len(friendships))
+	testhelpers.Equals(t, idFriend, friendships[0].IDFriend)
+	testhelpers.Equals(t, store.FriendshipStatusAccepted, friendships[0].Status)
+	testhelpers.Assert(t, friendships[0].RespondedAt != nil, "expected accepted friendship to have a responded at")
+	testhelpers.Equals(t, idStranger, friendships[1].IDFriend)
+	testhelpers.Equals(t, store.FriendshipStatusPending, friendships[1].Status)
+
+	// pending requests don't make someone a contact, and members of the party are left out
+	contacts := make([]store.ContactResult, 0)
+	err = repo.SearchContacts(ctx, store.ContactSearchParams{IDWatcher: idWatcher, IDParty: idOtherParty, Limit: 10}, func(res store.ContactResult) {
+		contacts = append(contacts, res)
+	})
+	testhelpers.Ok(t, err, "failed to search contacts")
+	testhelpers.Equals(t, []store.ContactResult{
+		{ID: idFriend, FirstName: "fran", LastName: "bomba", IsFriend: true},
+		{ID: idPartyMate, FirstName: "pam", LastName: "bomba", IsFriend: false},
+	}, contacts)
+
+	contacts = make([]store.ContactResult, 0)
+	err = repo.SearchContacts(ctx, store.ContactSearchParams{IDWatcher: idWatcher, IDParty: idParty, Search: "FR", Limit: 10}, func(res store.ContactResult) {
+		contacts = append(contacts, res)
+	})
+	testhelpers.Ok(t, err, "failed to search contacts")
+	testhelpers.Equals(t, []store.ContactResult{{ID: idFriend, FirstName: "fran", LastName: "bomba", IsFriend: true}}, contacts)
+
+	_, err = repo.GetContactEmail(ctx, idWatcher, idStranger)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.DeleteFriendship(ctx, idFriendship, idStranger)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.DeleteFriendship(ctx, idFriendship, idWatcher)
+	testhelpers.Ok(t, err, "failed to delete friendship")
+
+	_, err = repo.GetFriendshipBetween(ctx, idFriend, idWatcher)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+}
@@ -0,0 +1,178 @@
+package store
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type GenresRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewGenresRepository(db *pgxpool.Pool) *GenresRepository {
+	return &GenresRepository{db: db}
+}
+
+type GenreName struct {
+	ID   int
+	Name string
+}
+
+const (
+	upsertGenreQuery = `INSERT INTO genres (id_genre) VALUES ($1) ON CONFLICT (id_genre) DO NOTHING`
+
+	upsertGenreNameQuery = `
+  INSERT INTO genre_names (id_genre, language, name) VALUES ($1, $2, $3)
+  ON CONFLICT (id_genre, language) DO UPDATE
+  SET name = excluded.name, updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE genre_names.name <> excluded.name`
+)
+
+// UpsertGenres stores the names of the given genres for a language, creating the genres if they don't exist yet
+func (g *GenresRepository) UpsertGenres(ctx context.Context, language string, genres []GenreName) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "GenresRepository.UpsertGenres")
+	defer span.End()
+
+	txn, err := g.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer txn.Rollback(ctx)
+
+	err = upsertGenresWithTxn(ctx, txn, language, genres)
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit(ctx)
+}
+
+func upsertGenresWithTxn(ctx context.Context, txn pgx.Tx, language string, genres []GenreName) error {
+	batch := &pgx.Batch{}
+	for _, genre := range genres {
+		batch.Queue(upsertGenreQuery, genre.ID)
+		batch.Queue(upsertGenreNameQuery, genre.ID, language, genre.Name)
+	}
+
+	return txn.SendBatch(ctx, batch).Close()
+}
+
+const getGenresForLanguageQuery = `SELECT id_genre, name FROM genre_names WHERE language = $1 ORDER BY name`
+
+// GetGenres returns every stored genre name for a language
+func (g *GenresRepository) GetGenres(ctx context.Context, language string, assignFn func(id int, name string)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "GenresRepository.GetGenres")
+	defer span.End()
+
+	rows, err := g.db.Query(ctx, getGenresForLanguageQuery, language)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return err
+		}
+		assignFn(id, name)
+	}
+
+	return rows.Err()
+}
+
+const getLanguagesQuery = `SELECT DISTINCT preferred_language FROM profiles`
+
+// GetPreferredLanguages returns every language a profile has asked for so they can be kept warm
+func (g *GenresRepository) GetPreferredLanguages(ctx context.Context, assignFn func(string)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "GenresRepository.GetPreferredLanguages")
+	defer span.End()
+
+	rows, err := g.db.Query(ctx, getLanguagesQuery)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var language string
+		err := rows.Scan(&language)
+		if err != nil {
+			return err
+		}
+		assignFn(language)
+	}
+
+	return rows.Err()
+}
+
+// movies created before genres were normalized only have the genre names stored on the movie,
+// match those against the names in the default language to fill in movie_genres
+const backfillMovieGenresQuery = `
+  INSERT INTO movie_genres (id_movie, id_genre)
+  SELECT movies.id_movie, genre_names.id_genre
+  FROM movies
+  CROSS JOIN LATERAL unnest(movies.genres) AS movie_genre(name)
+  JOIN genre_names ON genre_names.name = movie_genre.name AND genre_names.language = $1
+  ON CONFLICT (id_movie, id_genre) DO NOTHING`
+
+// BackfillMovieGenres links existing movies to the genres table using the genre names stored on the movie
+func (g *GenresRepository) BackfillMovieGenres(ctx context.Context, language string) (int64, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "GenresRepository.BackfillMovieGenres")
+	defer span.End()
+
+	tag, err := g.db.Exec(ctx, backfillMovieGenresQuery, language)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+const getMovieGenresQuery = `
+  SELECT coalesce(localized.name, fallback.name)
+  FROM movie_genres
+  JOIN genre_names fallback ON fallback.id_genre = movie_genres.id_genre AND fallback.language = $3
+  LEFT JOIN genre_names localized ON localized.id_genre = movie_genres.id_genre AND localized.language = $2
+  WHERE movie_genres.id_movie = $1
+  ORDER BY movie_genres.id_genre`
+
+// GetMovieGenres returns the genre names for a movie in the given language, falling back to the fallback language
+// when there is no translation stored
+func (g *GenresRepository) GetMovieGenres(ctx context.Context, idMovie int, language, fallbackLanguage string) ([]string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "GenresRepository.GetMovieGenres")
+	defer span.End()
+
+	rows, err := g.db.Query(ctx, getMovieGenresQuery, idMovie, language, fallbackLanguage)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var genres []string
+	for rows.Next() {
+		var name string
+		err := rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, name)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
@@ -0,0 +1,450 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type ImportsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewImportsRepository(db *pgxpool.Pool) *ImportsRepository {
+	return &ImportsRepository{db: db}
+}
+
+// ImportCandidate is a movie a row in an import might be referring to
+type ImportCandidate struct {
+	TMDBID      int    `json:"tmdb_id"`
+	Title       string `json:"title"`
+	ReleaseDate string `json:"release_date"`
+	PosterURL   string `json:"poster_url"`
+}
+
+type CreateImportRowParams struct {
+	Line        int
+	Title       string
+	Year        *int
+	IMDbID      string
+	MatchStatus ImportMatchStatusEnum
+	Candidates  []ImportCandidate
+	TMDBID      *int
+	Error       string
+}
+
+type CreateImportParams struct {
+	IDParty   int
+	IDProfile int
+	Source    string
+	Filename  string
+	Rows      []CreateImportRowParams
+}
+
+type ImportResult struct {
+	ID            int
+	IDParty       int
+	IDProfile     int
+	Source        string
+	Filename      string
+	Status        ImportStatusEnum
+	TotalRows     int
+	ProcessedRows int
+	Error         string
+	CreatedAt     time.Time
+	FinishedAt    *time.Time
+	AddedRows     int
+	SkippedRows   int
+	// DuplicateRows were already in the party when the import got to them
+	DuplicateRows int
+	FailedRows    int
+}
+
+type ImportRowResult struct {
+	Line        int
+	Title       string
+	Year        *int
+	IMDbID      string
+	MatchStatus ImportMatchStatusEnum
+	Candidates  []ImportCandidate
+	TMDBID      *int
+	Status      ImportRowStatusEnum
+	Error       string
+}
+
+const (
+	createImportQuery = `
+  INSERT INTO party_imports (id_party, id_profile, source, filename, total_rows)
+  VALUES ($1, $2, $3, $4, $5)
+  RETURNING id_party_import`
+
+	createImportRowQuery = `
+  INSERT INTO party_import_rows (id_party_import, line, title, year, imdb_id, match_status, candidates, tmdb_id, error)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+)
+
+// CreateImport stores an import along with how each of its rows matched, the import waits to be confirmed before
+// anything is added to the party
+func (i *ImportsRepository) CreateImport(ctx context.Context, params CreateImportParams) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.CreateImport")
+	defer span.End()
+
+	txn, err := i.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer txn.Rollback(ctx)
+
+	var id int
+	err = txn.QueryRow(ctx, createImportQuery, params.IDParty, params.IDProfile, params.Source, params.Filename, len(params.Rows)).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	batch := &pgx.Batch{}
+	for _, row := range params.Rows {
+		candidates := row.Candidates
+		if candidates == nil {
+			candidates = []ImportCandidate{}
+		}
+
+		batch.Queue(
+			createImportRowQuery,
+			id,
+			row.Line,
+			row.Title,
+			row.Year,
+			row.IMDbID,
+			row.MatchStatus,
+			candidates,
+			row.TMDBID,
+			row.Error,
+		)
+	}
+
+	err = txn.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, txn.Commit(ctx)
+}
+
+const importColumns = `
+  party_imports.id_party_import,
+  party_imports.id_party,
+  party_imports.id_profile,
+  party_imports.source,
+  party_imports.filename,
+  party_imports.status::text,
+  party_imports.total_rows,
+  party_imports.processed_rows,
+  party_imports.error,
+  party_imports.created_at,
+  party_imports.finished_at,
+  count(party_import_rows.line) FILTER (WHERE party_import_rows.status = 'added'),
+  count(party_import_rows.line) FILTER (WHERE party_import_rows.status = 'skipped'),
+  count(party_import_rows.line) FILTER (WHERE party_import_rows.status = 'already_added'),
+  count(party_import_rows.line) FILTER (WHERE party_import_rows.status = 'failed')`
+
+func scanImport(row pgx.Row) (*ImportResult, error) {
+	res := &ImportResult{}
+	var status string
+	err := row.Scan(
+		&res.ID,
+		&res.IDParty,
+		&res.IDProfile,
+		&res.Source,
+		&res.Filename,
+		&status,
+		&res.TotalRows,
+		&res.ProcessedRows,
+		&res.Error,
+		&res.CreatedAt,
+		&res.FinishedAt,
+		&res.AddedRows,
+		&res.SkippedRows,
+		&res.DuplicateRows,
+		&res.FailedRows,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res.Status = ImportStatusEnum(status)
+	return res, nil
+}
+
+const getImportQuery = `
+  SELECT ` + importColumns + `
+  FROM party_imports
+  LEFT JOIN party_import_rows ON party_import_rows.id_party_import = party_imports.id_party_import
+  WHERE party_imports.id_party_import = $1
+  GROUP BY party_imports.id_party_import`
+
+func (i *ImportsRepository) GetImport(ctx context.Context, idImport int, assignFn func(*ImportResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.GetImport")
+	defer span.End()
+
+	res, err := scanImport(i.db.QueryRow(ctx, getImportQuery, idImport))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoRecord
+	}
+
+	if err != nil {
+		return err
+	}
+
+	assignFn(res)
+	return nil
+}
+
+// imports that are still running along with any that finished recently so the party can see how they went
+const getRecentImportsForPartyQuery = `
+  SELECT ` + importColumns + `
+  FROM party_imports
+  LEFT JOIN party_import_rows ON party_import_rows.id_party_import = party_imports.id_party_import
+  WHERE party_imports.id_party = $1
+  AND (party_imports.status IN ('queued', 'running') OR party_imports.finished_at > $2)
+  GROUP BY party_imports.id_party_import
+  ORDER BY party_imports.created_at DESC`
+
+// GetRecentImportsForParty returns the confirmed imports for a party that haven't finished or finished after
+// finishedAfter
+func (i *ImportsRepository) GetRecentImportsForParty(ctx context.Context, idParty int, finishedAfter time.Time, assignFn func(*ImportResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.GetRecentImportsForParty")
+	defer span.End()
+
+	rows, err := i.db.Query(ctx, getRecentImportsForPartyQuery, idParty, finishedAfter)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanImport(rows)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const getImportRowsQuery = `
+  SELECT line, title, year, imdb_id, match_status::text, candidates, tmdb_id, status::text, error
+  FROM party_import_rows
+  WHERE id_party_import = $1
+  ORDER BY line`
+
+func (i *ImportsRepository) GetImportRows(ctx context.Context, idImport int, assignFn func(ImportRowResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.GetImportRows")
+	defer span.End()
+
+	rows, err := i.db.Query(ctx, getImportRowsQuery, idImport)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			res         ImportRowResult
+			matchStatus string
+			status      string
+		)
+		err := rows.Scan(&res.Line, &res.Title, &res.Year, &res.IMDbID, &matchStatus, &res.Candidates, &res.TMDBID, &status, &res.Error)
+		if err != nil {
+			return err
+		}
+		res.MatchStatus = ImportMatchStatusEnum(matchStatus)
+		res.Status = ImportRowStatusEnum(status)
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const (
+	queueImportQuery = `
+  UPDATE party_imports
+  SET status = 'queued', total_rows = $2, updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_party_import = $1 AND status = 'previewing'`
+
+	setImportRowMovieQuery = `UPDATE party_import_rows SET tmdb_id = $3 WHERE id_party_import = $1 AND line = $2`
+
+	skipUnselectedImportRowsQuery = `
+  UPDATE party_import_rows
+  SET status = 'skipped'
+  WHERE id_party_import = $1 AND NOT (line = ANY($2))`
+)
+
+// QueueImport confirms an import with the movie picked for each line, any line without a movie is skipped. Returns
+// ErrNoRecord if the import doesn't exist or has already been confirmed.
+func (i *ImportsRepository) QueueImport(ctx context.Context, idImport int, selections map[int]int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.QueueImport")
+	defer span.End()
+
+	txn, err := i.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer txn.Rollback(ctx)
+
+	tag, err := txn.Exec(ctx, queueImportQuery, idImport, len(selections))
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	lines := make([]int, 0, len(selections))
+	batch := &pgx.Batch{}
+	for line, tmdbID := range selections {
+		lines = append(lines, line)
+		batch.Queue(setImportRowMovieQuery, idImport, line, tmdbID)
+	}
+	batch.Queue(skipUnselectedImportRowsQuery, idImport, lines)
+
+	err = txn.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit(ctx)
+}
+
+// the oldest queued import is taken, skip locked lets several workers claim imports at the same time without
+// taking the same one
+const claimQueuedImportQuery = `
+  UPDATE party_imports
+  SET
+    status = 'running',
+    started_at = coalesce(started_at, (clock_timestamp() AT TIME ZONE 'UTC')),
+    updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_party_import = (
+    SELECT id_party_import
+    FROM party_imports
+    WHERE status = 'queued'
+    ORDER BY created_at
+    LIMIT 1
+    FOR UPDATE SKIP LOCKED
+  )
+  RETURNING id_party_import, id_party, id_profile`
+
+// ClaimQueuedImport marks the oldest queued import as running and returns it, ErrNoRecord is returned when nothing is
+// queued
+func (i *ImportsRepository) ClaimQueuedImport(ctx context.Context, assignFn func(idImport, idParty, idProfile int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.ClaimQueuedImport")
+	defer span.End()
+
+	var idImport, idParty, idProfile int
+	err := i.db.QueryRow(ctx, claimQueuedImportQuery).Scan(&idImport, &idParty, &idProfile)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return ErrNoRecord
+	}
+
+	if err != nil {
+		return err
+	}
+
+	assignFn(idImport, idParty, idProfile)
+	return nil
+}
+
+const requeueStalledImportsQuery = `
+  UPDATE party_imports
+  SET status = 'queued'
+  WHERE status = 'running' AND updated_at < $1`
+
+// RequeueStalledImports puts running imports that haven't made progress since stalledBefore back in the queue, rows
+// that were already processed aren't processed again
+func (i *ImportsRepository) RequeueStalledImports(ctx context.Context, stalledBefore time.Time) (int64, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.RequeueStalledImports")
+	defer span.End()
+
+	tag, err := i.db.Exec(ctx, requeueStalledImportsQuery, stalledBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+const getPendingImportRowsQuery = `
+  SELECT line, tmdb_id
+  FROM party_import_rows
+  WHERE id_party_import = $1 AND status = 'pending' AND tmdb_id IS NOT NULL
+  ORDER BY line`
+
+// GetPendingImportRows returns the lines of a confirmed import that still need to be added to the party
+func (i *ImportsRepository) GetPendingImportRows(ctx context.Context, idImport int, assignFn func(line, tmdbID int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.GetPendingImportRows")
+	defer span.End()
+
+	rows, err := i.db.Query(ctx, getPendingImportRowsQuery, idImport)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var line, tmdbID int
+		err := rows.Scan(&line, &tmdbID)
+		if err != nil {
+			return err
+		}
+		assignFn(line, tmdbID)
+	}
+
+	return rows.Err()
+}
+
+// the progress is only bumped when the row was still pending so a row can't be counted twice
+const setImportRowResultQuery = `
+  WITH updated AS (
+    UPDATE party_import_rows
+    SET status = $3, error = $4
+    WHERE id_party_import = $1 AND line = $2 AND status = 'pending'
+    RETURNING line
+  )
+  UPDATE party_imports
+  SET
+    processed_rows = processed_rows + (SELECT count(*) FROM updated),
+    updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_party_import = $1`
+
+// SetImportRowResult records how adding a line to the party went and counts it towards the import's progress
+func (i *ImportsRepository) SetImportRowResult(ctx context.Context, idImport, line int, status ImportRowStatusEnum, errMsg string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.SetImportRowResult")
+	defer span.End()
+
+	_, err := i.db.Exec(ctx, setImportRowResultQuery, idImport, line, status, errMsg)
+	return err
+}
+
+const finishImportQuery = `
+  UPDATE party_imports
+  SET status = $2, error = $3, finished_at = (clock_timestamp() AT TIME ZONE 'UTC'), updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_party_import = $1`
+
+// FinishImport marks an import as completed or failed
+func (i *ImportsRepository) FinishImport(ctx context.Context, idImport int, status ImportStatusEnum, errMsg string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "ImportsRepository.FinishImport")
+	defer span.End()
+
+	_, err := i.db.Exec(ctx, finishImportQuery, idImport, status, errMsg)
+	return err
+}
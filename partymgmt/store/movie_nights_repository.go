@@ -0,0 +1,545 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type RSVPResponseEnum string
+
+const (
+	RSVPResponseGoing    RSVPResponseEnum = "going"
+	RSVPResponseMaybe    RSVPResponseEnum = "maybe"
+	RSVPResponseNotGoing RSVPResponseEnum = "not_going"
+)
+
+type MovieNightsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewMovieNightsRepository(db *pgxpool.Pool) *MovieNightsRepository {
+	return &MovieNightsRepository{db: db}
+}
+
+func (m *MovieNightsRepository) IsPartyMember(ctx context.Context, idParty, idWatcher int) (bool, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.IsPartyMember")
+	defer span.End()
+
+	var isMember bool
+	err := m.db.QueryRow(ctx, isPartyMemberQuery, idParty, idWatcher).Scan(&isMember)
+	if err != nil {
+		return false, err
+	}
+
+	return isMember, nil
+}
+
+type MovieNightResult struct {
+	ID           int
+	IDParty      int
+	PartyName    string
+	IDPartyOwner int
+	// IDMovie is nil when no movie has been chosen or the chosen one was removed from the party
+	IDMovie          *int
+	MovieTitle       string
+	MovieRuntime     int
+	IDCreatedBy      int
+	CreatedFirstName string
+	CreatedLastName  string
+	StartsAt         time.Time
+	TimeZone         string
+	Location         string
+	StreamURL        string
+	ReminderMinutes  int
+	Sequence         int
+	CompletedAt      *time.Time
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// movieNightColumns is shared by every query that reads movie nights so their rows can be scanned the same way
+const movieNightColumns = `
+    movie_nights.id_movie_night,
+    movie_nights.id_party,
+    parties.name,
+    coalesce(parties.id_owner, 0),
+    movie_nights.id_movie,
+    coalesce(movies.title, ''),
+    coalesce(movies.runtime, 0),
+    movie_nights.id_created_by,
+    profiles.first_name,
+    profiles.last_name,
+    movie_nights.starts_at,
+    movie_nights.time_zone,
+    movie_nights.location,
+    movie_nights.stream_url,
+    movie_nights.reminder_minutes,
+    movie_nights.sequence,
+    movie_nights.completed_at,
+    movie_nights.created_at,
+    movie_nights.updated_at
+  FROM movie_nights
+  JOIN parties ON parties.id_party = movie_nights.id_party
+  JOIN profiles ON profiles.id_profile = movie_nights.id_created_by
+  LEFT JOIN movies ON movies.id_movie = movie_nights.id_movie
+`
+
+func scanMovieNight(row pgx.Row) (MovieNightResult, error) {
+	var res MovieNightResult
+	err := row.Scan(
+		&res.ID,
+		&res.IDParty,
+		&res.PartyName,
+		&res.IDPartyOwner,
+		&res.IDMovie,
+		&res.MovieTitle,
+		&res.MovieRuntime,
+		&res.IDCreatedBy,
+		&res.CreatedFirstName,
+		&res.CreatedLastName,
+		&res.StartsAt,
+		&res.TimeZone,
+		&res.Location,
+		&res.StreamURL,
+		&res.ReminderMinutes,
+		&res.Sequence,
+		&res.CompletedAt,
+		&res.CreatedAt,
+		&res.UpdatedAt,
+	)
+	return res, err
+}
+
+const getMovieNightQuery = `SELECT` + movieNightColumns + `WHERE movie_nights.id_party = $1 AND movie_nights.id_movie_night = $2;`
+
+func (m *MovieNightsRepository) GetMovieNight(ctx context.Context, idParty, idMovieNight int) (MovieNightResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetMovieNight")
+	defer span.End()
+
+	res, err := scanMovieNight(m.db.QueryRow(ctx, getMovieNightQuery, idParty, idMovieNight))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return MovieNightResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return MovieNightResult{}, err
+	}
+
+	return res, nil
+}
+
+const (
+	getScheduledMovieNightsQuery = `SELECT` + movieNightColumns + `
+  WHERE movie_nights.id_party = $1 AND movie_nights.completed_at IS NULL
+  ORDER BY movie_nights.starts_at, movie_nights.id_movie_night;
+`
+
+	getCompletedMovieNightsQuery = `SELECT` + movieNightColumns + `
+  WHERE movie_nights.id_party = $1 AND movie_nights.completed_at IS NOT NULL
+  ORDER BY movie_nights.starts_at DESC, movie_nights.id_movie_night DESC
+  LIMIT $2;
+`
+)
+
+// GetScheduledMovieNights reads every movie night in the party that hasn't been completed, soonest first
+func (m *MovieNightsRepository) GetScheduledMovieNights(ctx context.Context, idParty int, assignFn func(MovieNightResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetScheduledMovieNights")
+	defer span.End()
+
+	return m.queryMovieNights(ctx, getScheduledMovieNightsQuery, assignFn, idParty)
+}
+
+// GetCompletedMovieNights reads the party's most recently completed movie nights, newest first
+func (m *MovieNightsRepository) GetCompletedMovieNights(ctx context.Context, idParty, limit int, assignFn func(MovieNightResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetCompletedMovieNights")
+	defer span.End()
+
+	return m.queryMovieNights(ctx, getCompletedMovieNightsQuery, assignFn, idParty, limit)
+}
+
+// a nil $3 reads every movie night from $2 onwards
+const getWatcherMovieNightsQuery = `SELECT` + movieNightColumns + `
+  JOIN party_members ON party_members.id_party = movie_nights.id_party AND party_members.id_member = $1
+  WHERE movie_nights.starts_at >= $2
+  AND ($3::timestamptz IS NULL OR movie_nights.starts_at < $3)
+  ORDER BY movie_nights.starts_at, movie_nights.id_movie_night;
+`
+
+// GetWatcherMovieNights reads the movie nights in every party the watcher is a member of that start from from and
+// before to, a nil to has no upper bound
+func (m *MovieNightsRepository) GetWatcherMovieNights(ctx context.Context, idWatcher int, from time.Time, to *time.Time, assignFn func(MovieNightResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetWatcherMovieNights")
+	defer span.End()
+
+	return m.queryMovieNights(ctx, getWatcherMovieNightsQuery, assignFn, idWatcher, from, to)
+}
+
+const getPartyCalendarMovieNightsQuery = `SELECT` + movieNightColumns + `
+  WHERE movie_nights.id_party = $1 AND movie_nights.starts_at >= $2
+  ORDER BY movie_nights.starts_at, movie_nights.id_movie_night;
+`
+
+// GetPartyCalendarMovieNights reads the party's movie nights that start from from onwards
+func (m *MovieNightsRepository) GetPartyCalendarMovieNights(ctx context.Context, idParty int, from time.Time, assignFn func(MovieNightResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetPartyCalendarMovieNights")
+	defer span.End()
+
+	return m.queryMovieNights(ctx, getPartyCalendarMovieNightsQuery, assignFn, idParty, from)
+}
+
+func (m *MovieNightsRepository) queryMovieNights(ctx context.Context, query string, assignFn func(MovieNightResult), args ...any) error {
+	rows, err := m.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanMovieNight(rows)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+type RSVPResult struct {
+	IDMovieNight int
+	IDWatcher    int
+	FirstName    string
+	LastName     string
+	Response     RSVPResponseEnum
+}
+
+const getRSVPsQuery = `
+  SELECT
+    movie_night_rsvps.id_movie_night,
+    movie_night_rsvps.id_profile,
+    profiles.first_name,
+    profiles.last_name,
+    movie_night_rsvps.response::text
+  FROM movie_night_rsvps
+  JOIN profiles ON profiles.id_profile = movie_night_rsvps.id_profile
+  WHERE movie_night_rsvps.id_movie_night = any($1)
+  ORDER BY profiles.first_name, profiles.last_name, movie_night_rsvps.id_profile;
+`
+
+// GetRSVPs reads the responses to every one of the movie nights
+func (m *MovieNightsRepository) GetRSVPs(ctx context.Context, idMovieNights []int, assignFn func(RSVPResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetRSVPs")
+	defer span.End()
+
+	rows, err := m.db.Query(ctx, getRSVPsQuery, idMovieNights)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res RSVPResult
+		err = rows.Scan(&res.IDMovieNight, &res.IDWatcher, &res.FirstName, &res.LastName, &res.Response)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const getSchedulableMoviesQuery = `
+  SELECT movies.id_movie, movies.title
+  FROM party_movies
+  JOIN movies ON movies.id_movie = party_movies.id_movie
+  WHERE party_movies.id_party = $1 AND party_movies.watch_status != 'watched'
+    AND NOT EXISTS (
+      SELECT 1 FROM party_movie_vetoes
+      WHERE party_movie_vetoes.id_party = party_movies.id_party AND party_movie_vetoes.id_movie = party_movies.id_movie
+    )
+  ORDER BY party_movies.watch_status = 'selected' DESC, movies.title;
+`
+
+// GetSchedulableMovies reads the movies in the party that haven't been watched yet or vetoed, the selected movie is
+// first
+func (m *MovieNightsRepository) GetSchedulableMovies(ctx context.Context, idParty int, assignFn func(idMovie int, title string)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetSchedulableMovies")
+	defer span.End()
+
+	rows, err := m.db.Query(ctx, getSchedulableMoviesQuery, idParty)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			idMovie int
+			title   string
+		)
+		err = rows.Scan(&idMovie, &title)
+		if err != nil {
+			return err
+		}
+		assignFn(idMovie, title)
+	}
+
+	return rows.Err()
+}
+
+const isSchedulableMovieQuery = `
+  SELECT EXISTS(
+    select 1 from party_movies where id_party = $1 and id_movie = $2 and watch_status != 'watched'
+    and not exists (select 1 from party_movie_vetoes where id_party = $1 and id_movie = $2)
+  );
+`
+
+func (m *MovieNightsRepository) IsSchedulableMovie(ctx context.Context, idParty, idMovie int) (bool, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.IsSchedulableMovie")
+	defer span.End()
+
+	var schedulable bool
+	err := m.db.QueryRow(ctx, isSchedulableMovieQuery, idParty, idMovie).Scan(&schedulable)
+	if err != nil {
+		return false, err
+	}
+
+	return schedulable, nil
+}
+
+type MovieNightParams struct {
+	// IDMovie is nil when no movie has been chosen yet
+	IDMovie         *int
+	StartsAt        time.Time
+	TimeZone        string
+	Location        string
+	StreamURL       string
+	ReminderMinutes int
+}
+
+const (
+	createMovieNightQuery = `
+  INSERT INTO movie_nights (id_party, id_movie, id_created_by, starts_at, time_zone, location, stream_url, reminder_minutes)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  RETURNING id_movie_night;
+`
+
+	setRSVPQuery = `
+  INSERT INTO movie_night_rsvps (id_movie_night, id_profile, response)
+  VALUES ($1, $2, $3)
+  ON CONFLICT (id_movie_night, id_profile)
+  DO UPDATE SET response = excluded.response, updated_at = (clock_timestamp() AT TIME ZONE 'UTC');
+`
+)
+
+// CreateMovieNight schedules a movie night for the party, the person scheduling it is marked as going
+func (m *MovieNightsRepository) CreateMovieNight(ctx context.Context, idParty, idCreatedBy int, params MovieNightParams) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.CreateMovieNight")
+	defer span.End()
+
+	txn, err := m.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer txn.Rollback(ctx)
+
+	var id int
+	err = txn.QueryRow(
+		ctx,
+		createMovieNightQuery,
+		idParty,
+		params.IDMovie,
+		idCreatedBy,
+		params.StartsAt,
+		params.TimeZone,
+		params.Location,
+		params.StreamURL,
+		params.ReminderMinutes,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = txn.Exec(ctx, setRSVPQuery, id, idCreatedBy, RSVPResponseGoing)
+	if err != nil {
+		return 0, err
+	}
+
+	err = txn.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// the sequence is bumped on every change so calendar apps replace their copy of the event, members are reminded again
+// when it's moved or its reminder changes
+const updateMovieNightQuery = `
+  UPDATE movie_nights
+  SET id_movie = $2, starts_at = $3, time_zone = $4, location = $5, stream_url = $6, reminder_minutes = $7,
+    sequence = sequence + 1, updated_at = (clock_timestamp() AT TIME ZONE 'UTC'),
+    reminder_sent_at = CASE WHEN starts_at = $3 AND reminder_minutes = $7 THEN reminder_sent_at END
+  WHERE id_movie_night = $1 AND completed_at IS NULL;
+`
+
+// UpdateMovieNight changes the details of a movie night, ErrNoRecord is returned when it doesn't exist or has been
+// completed
+func (m *MovieNightsRepository) UpdateMovieNight(ctx context.Context, idMovieNight int, params MovieNightParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.UpdateMovieNight")
+	defer span.End()
+
+	tag, err := m.db.Exec(
+		ctx,
+		updateMovieNightQuery,
+		idMovieNight,
+		params.IDMovie,
+		params.StartsAt,
+		params.TimeZone,
+		params.Location,
+		params.StreamURL,
+		params.ReminderMinutes,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const deleteMovieNightQuery = `DELETE FROM movie_nights WHERE id_movie_night = $1;`
+
+func (m *MovieNightsRepository) DeleteMovieNight(ctx context.Context, idMovieNight int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.DeleteMovieNight")
+	defer span.End()
+
+	_, err := m.db.Exec(ctx, deleteMovieNightQuery, idMovieNight)
+	return err
+}
+
+func (m *MovieNightsRepository) SetRSVP(ctx context.Context, idMovieNight, idWatcher int, response RSVPResponseEnum) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.SetRSVP")
+	defer span.End()
+
+	_, err := m.db.Exec(ctx, setRSVPQuery, idMovieNight, idWatcher, response)
+	return err
+}
+
+const completeMovieNightQuery = `
+  UPDATE movie_nights
+  SET completed_at = $2, sequence = sequence + 1, updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_movie_night = $1 AND completed_at IS NULL;
+`
+
+// CompleteMovieNight marks the movie night as having happened, ErrNoRecord is returned when it doesn't exist or has
+// already been completed
+func (m *MovieNightsRepository) CompleteMovieNight(ctx context.Context, idMovieNight int, completedAt time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.CompleteMovieNight")
+	defer span.End()
+
+	tag, err := m.db.Exec(ctx, completeMovieNightQuery, idMovieNight, completedAt)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const getCalendarTokenQuery = `SELECT token FROM calendar_feeds WHERE id_profile = $1;`
+
+// GetCalendarToken returns the token for the watcher's calendar feed, ErrNoRecord is returned when they don't have one
+func (m *MovieNightsRepository) GetCalendarToken(ctx context.Context, idWatcher int) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetCalendarToken")
+	defer span.End()
+
+	var token string
+	err := m.db.QueryRow(ctx, getCalendarTokenQuery, idWatcher).Scan(&token)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", ErrNoRecord
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+const (
+	createCalendarTokenQuery = `
+  INSERT INTO calendar_feeds (id_profile, token) VALUES ($1, $2)
+  ON CONFLICT (id_profile) DO NOTHING;
+`
+
+	replaceCalendarTokenQuery = `
+  INSERT INTO calendar_feeds (id_profile, token) VALUES ($1, $2)
+  ON CONFLICT (id_profile)
+  DO UPDATE SET token = excluded.token, created_at = (clock_timestamp() AT TIME ZONE 'UTC');
+`
+)
+
+// CreateCalendarToken gives the watcher a calendar feed token, a token they already have is kept
+func (m *MovieNightsRepository) CreateCalendarToken(ctx context.Context, idWatcher int, token string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.CreateCalendarToken")
+	defer span.End()
+
+	return m.execCalendarToken(ctx, createCalendarTokenQuery, idWatcher, token)
+}
+
+// ReplaceCalendarToken gives the watcher a new calendar feed token, the old one stops working
+func (m *MovieNightsRepository) ReplaceCalendarToken(ctx context.Context, idWatcher int, token string) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.ReplaceCalendarToken")
+	defer span.End()
+
+	return m.execCalendarToken(ctx, replaceCalendarTokenQuery, idWatcher, token)
+}
+
+func (m *MovieNightsRepository) execCalendarToken(ctx context.Context, query string, idWatcher int, token string) error {
+	_, err := m.db.Exec(ctx, query, idWatcher, token)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolationCode {
+			return ErrDuplicateCalendarToken
+		}
+		return err
+	}
+
+	return nil
+}
+
+const getCalendarTokenOwnerQuery = `SELECT id_profile FROM calendar_feeds WHERE token = $1;`
+
+// GetCalendarTokenOwner returns the watcher the calendar feed token belongs to, ErrNoRecord is returned when it doesn't
+// belong to anyone
+func (m *MovieNightsRepository) GetCalendarTokenOwner(ctx context.Context, token string) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MovieNightsRepository.GetCalendarTokenOwner")
+	defer span.End()
+
+	var idWatcher int
+	err := m.db.QueryRow(ctx, getCalendarTokenOwnerQuery, token).Scan(&idWatcher)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrNoRecord
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return idWatcher, nil
+}
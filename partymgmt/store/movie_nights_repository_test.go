@@ -0,0 +1,141 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestMovieNightLifecycle(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_movie_night_lifecycle_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewMovieNightsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "night-party", "nighta")
+	idHost := seedProfile(ctx, t, connPool)
+	idGuest := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idHost)
+	seedPartyMember(ctx, t, connPool, idParty, idGuest)
+
+	addedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idHost, "Heat", 170, addedAt, nil)
+	seedPartyMovie(ctx, t, connPool, idParty, idHost, "Thief", 123, addedAt, ptr(addedAt))
+
+	movies := make([]string, 0)
+	err := repo.GetSchedulableMovies(ctx, idParty, func(idMovie int, title string) {
+		movies = append(movies, title)
+	})
+	testhelpers.Ok(t, err, "failed to get schedulable movies")
+	testhelpers.Equals(t, []string{"Heat"}, movies)
+
+	var idMovie int
+	err = connPool.QueryRow(ctx, "select id_movie from movies where title = 'Heat'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie id")
+
+	startsAt := time.Date(2025, 5, 2, 19, 0, 0, 0, time.UTC)
+	idNight, err := repo.CreateMovieNight(ctx, idParty, idHost, store.MovieNightParams{
+		IDMovie:         &idMovie,
+		StartsAt:        startsAt,
+		TimeZone:        "Europe/London",
+		Location:        "The basement",
+		ReminderMinutes: 30,
+	})
+	testhelpers.Ok(t, err, "failed to create movie night")
+
+	night, err := repo.GetMovieNight(ctx, idParty, idNight)
+	testhelpers.Ok(t, err, "failed to get movie night")
+	testhelpers.Equals(t, "Heat", night.MovieTitle)
+	testhelpers.Equals(t, 170, night.MovieRuntime)
+	testhelpers.Equals(t, "Europe/London", night.TimeZone)
+	testhelpers.Equals(t, 0, night.Sequence)
+	testhelpers.Assert(t, startsAt.Equal(night.StartsAt), "expected %v, got %v", startsAt, night.StartsAt)
+
+	_, err = repo.GetMovieNight(ctx, idParty+1, idNight)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.SetRSVP(ctx, idNight, idGuest, store.RSVPResponseMaybe)
+	testhelpers.Ok(t, err, "failed to set rsvp")
+	err = repo.SetRSVP(ctx, idNight, idGuest, store.RSVPResponseNotGoing)
+	testhelpers.Ok(t, err, "failed to change rsvp")
+
+	responses := make(map[int]store.RSVPResponseEnum)
+	err = repo.GetRSVPs(ctx, []int{idNight}, func(res store.RSVPResult) {
+		responses[res.IDWatcher] = res.Response
+	})
+	testhelpers.Ok(t, err, "failed to get rsvps")
+	testhelpers.Equals(t, map[int]store.RSVPResponseEnum{idHost: store.RSVPResponseGoing, idGuest: store.RSVPResponseNotGoing}, responses)
+
+	err = repo.UpdateMovieNight(ctx, idNight, store.MovieNightParams{StartsAt: startsAt.Add(time.Hour), TimeZone: "UTC"})
+	testhelpers.Ok(t, err, "failed to update movie night")
+
+	night, err = repo.GetMovieNight(ctx, idParty, idNight)
+	testhelpers.Ok(t, err, "failed to get updated movie night")
+	testhelpers.Equals(t, (*int)(nil), night.IDMovie)
+	testhelpers.Equals(t, 1, night.Sequence)
+
+	err = repo.CompleteMovieNight(ctx, idNight, startsAt)
+	testhelpers.Ok(t, err, "failed to complete movie night")
+
+	err = repo.CompleteMovieNight(ctx, idNight, startsAt)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.UpdateMovieNight(ctx, idNight, store.MovieNightParams{StartsAt: startsAt, TimeZone: "UTC"})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	completed := make([]int, 0)
+	err = repo.GetCompletedMovieNights(ctx, idParty, 10, func(res store.MovieNightResult) {
+		completed = append(completed, res.ID)
+	})
+	testhelpers.Ok(t, err, "failed to get completed movie nights")
+	testhelpers.Equals(t, []int{idNight}, completed)
+
+	upcoming := make([]int, 0)
+	err = repo.GetWatcherMovieNights(ctx, idGuest, startsAt.Add(-time.Hour), nil, func(res store.MovieNightResult) {
+		upcoming = append(upcoming, res.ID)
+	})
+	testhelpers.Ok(t, err, "failed to get watcher movie nights")
+	testhelpers.Equals(t, []int{idNight}, upcoming)
+}
+
+func TestCalendarTokens(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_calendar_tokens_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewMovieNightsRepository(connPool)
+
+	idWatcher := seedProfile(ctx, t, connPool)
+
+	_, err := repo.GetCalendarToken(ctx, idWatcher)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.CreateCalendarToken(ctx, idWatcher, "first-token")
+	testhelpers.Ok(t, err, "failed to create calendar token")
+
+	// a second create keeps the token that's already there
+	err = repo.CreateCalendarToken(ctx, idWatcher, "second-token")
+	testhelpers.Ok(t, err, "failed to create calendar token again")
+
+	token, err := repo.GetCalendarToken(ctx, idWatcher)
+	testhelpers.Ok(t, err, "failed to get calendar token")
+	testhelpers.Equals(t, "first-token", token)
+
+	err = repo.ReplaceCalendarToken(ctx, idWatcher, "third-token")
+	testhelpers.Ok(t, err, "failed to replace calendar token")
+
+	_, err = repo.GetCalendarTokenOwner(ctx, "first-token")
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	owner, err := repo.GetCalendarTokenOwner(ctx, "third-token")
+	testhelpers.Ok(t, err, "failed to get calendar token owner")
+	testhelpers.Equals(t, idWatcher, owner)
+}
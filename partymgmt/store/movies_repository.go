@@ -124,6 +124,8 @@ type CreateMovieParams struct {
 	GenreIDs    []int
 	TMDBID      int
 	Budget      int
+	// Language is the language the genre names are in
+	Language string
 }
 
 // CreateMovie creates a movie in the database
@@ -144,7 +146,14 @@ func (p *MoviesRepository) CreateMovie(ctx context.Context, createParams CreateM
 
 	var movieID int
 
-	err = p.db.QueryRow(ctx, insertMovieQuery,
+	txn, err := p.db.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	defer txn.Rollback(ctx)
+
+	err = txn.QueryRow(ctx, insertMovieQuery,
 		createParams.Title,
 		releaseDate,
 		createParams.Overview,
@@ -161,9 +170,47 @@ func (p *MoviesRepository) CreateMovie(ctx context.Context, createParams CreateM
 		return 0, err
 	}
 
+	err = createMovieGenresWithTxn(ctx, txn, movieID, createParams)
+	if err != nil {
+		return 0, err
+	}
+
+	err = txn.Commit(ctx)
+	if err != nil {
+		return 0, err
+	}
+
 	return movieID, nil
 }
 
+const insertMovieGenreQuery = `INSERT INTO movie_genres (id_movie, id_genre) VALUES ($1, $2) ON CONFLICT DO NOTHING`
+
+func createMovieGenresWithTxn(ctx context.Context, txn pgx.Tx, movieID int, createParams CreateMovieParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MoviesRepository.createMovieGenresWithTxn")
+	defer span.End()
+
+	if len(createParams.GenreIDs) == 0 || len(createParams.GenreIDs) != len(createParams.Genres) {
+		return nil
+	}
+
+	genres := make([]GenreName, len(createParams.GenreIDs))
+	for i, id := range createParams.GenreIDs {
+		genres[i] = GenreName{ID: id, Name: createParams.Genres[i]}
+	}
+
+	err := upsertGenresWithTxn(ctx, txn, createParams.Language, genres)
+	if err != nil {
+		return err
+	}
+
+	batch := &pgx.Batch{}
+	for _, id := range createParams.GenreIDs {
+		batch.Queue(insertMovieGenreQuery, movieID, id)
+	}
+
+	return txn.SendBatch(ctx, batch).Close()
+}
+
 const getMovieTMDBIDsFromPartyQuery = `select movies.tmdb_id from movies
 join party_movies on movies.id_movie = party_movies.id_movie
 where party_movies.id_party = $1 AND movies.tmdb_id = any($2);`
@@ -192,3 +239,252 @@ func (p *MoviesRepository) GetMovieTMDBIDsFromParty(ctx context.Context, partyID
 
 	return nil
 }
+
+// highly rated movies are preferred, when the party hasn't watched enough of them the rest of their watched movies are
+// used. The party's own ratings are out of 5 so they're doubled to line up with TMDB's, a movie nobody in the party has
+// rated goes by TMDB's rating and one the party rated below $4 is never used
+const getRecommendationSeedsQuery = `
+  SELECT movies.tmdb_id, movies.title
+  FROM movies
+  JOIN party_movies ON party_movies.id_movie = movies.id_movie
+  LEFT JOIN LATERAL (
+    SELECT avg(party_movie_ratings.rating)::float8 * 2 AS rating
+    FROM party_movie_ratings
+    WHERE party_movie_ratings.id_party = party_movies.id_party AND party_movie_ratings.id_movie = party_movies.id_movie
+  ) party_rating ON true
+  WHERE party_movies.id_party = $1
+    AND EXISTS (SELECT 1 FROM party_movie_viewings WHERE party_movie_viewings.id_party_movie = party_movies.id)
+    AND (party_rating.rating IS NULL OR party_rating.rating >= $4)
+  ORDER BY
+    (coalesce(party_rating.rating, movies.rating) >= $2) DESC NULLS LAST,
+    coalesce(party_rating.rating, movies.rating) DESC NULLS LAST,
+    party_movies.watch_date DESC NULLS LAST
+  LIMIT $3`
+
+// GetRecommendationSeeds returns the watched movies in a party to base recommendations on, best rated by the party first.
+// Ratings are out of 10, movies the party rated below dislikedRating are left out
+func (p *MoviesRepository) GetRecommendationSeeds(ctx context.Context, partyID int, minRating, dislikedRating float64, limit int, assignFn func(tmdbID int, title string)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MoviesRepository.GetRecommendationSeeds")
+	defer span.End()
+	rows, err := p.db.Query(ctx, getRecommendationSeedsQuery, partyID, minRating, limit, dislikedRating)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			tmdbID int
+			title  string
+		)
+		err := rows.Scan(&tmdbID, &title)
+		if err != nil {
+			return err
+		}
+		assignFn(tmdbID, title)
+	}
+
+	return rows.Err()
+}
+
+const getPartyGenreCountsQuery = `
+  SELECT
+    movie_genres.id_genre,
+    count(*) FILTER (WHERE party_movies.watch_status = 'watched') AS watched,
+    count(*) FILTER (WHERE party_movies.watch_status <> 'watched') AS unwatched
+  FROM party_movies
+  JOIN movie_genres ON movie_genres.id_movie = party_movies.id_movie
+  WHERE party_movies.id_party = $1
+  GROUP BY movie_genres.id_genre`
+
+// GetPartyGenreCounts returns how many of the movies in a party are in each genre split by whether they've been watched
+func (p *MoviesRepository) GetPartyGenreCounts(ctx context.Context, partyID int, assignFn func(idGenre, watched, unwatched int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MoviesRepository.GetPartyGenreCounts")
+	defer span.End()
+	rows, err := p.db.Query(ctx, getPartyGenreCountsQuery, partyID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var idGenre, watched, unwatched int
+		err := rows.Scan(&idGenre, &watched, &unwatched)
+		if err != nil {
+			return err
+		}
+		assignFn(idGenre, watched, unwatched)
+	}
+
+	return rows.Err()
+}
+
+type StoredMovieResult struct {
+	ID          int
+	TMDBID      int
+	Title       string
+	ReleaseDate *time.Time
+	CreatedAt   time.Time
+	// UpdatedAt is when the movie was last refreshed from TMDB, nil when it never has been
+	UpdatedAt *time.Time
+}
+
+const searchMoviesQuery = `
+  SELECT id_movie, tmdb_id, title, release_date, created_at, updated_at
+  FROM movies
+  WHERE title ILIKE '%' || $1 || '%' OR tmdb_id::text = $1
+  ORDER BY title
+  LIMIT $2`
+
+// SearchMovies returns the stored movies whose title has term in it or whose TMDB id is term
+func (p *MoviesRepository) SearchMovies(ctx context.Context, term string, limit int, assignFn func(StoredMovieResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MoviesRepository.SearchMovies")
+	defer span.End()
+	rows, err := p.db.Query(ctx, searchMoviesQuery, term, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res StoredMovieResult
+		err := rows.Scan(&res.ID, &res.TMDBID, &res.Title, &res.ReleaseDate, &res.CreatedAt, &res.UpdatedAt)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const (
+	updateMovieQuery = `UPDATE movies SET
+  title = $2,
+  release_date = $3,
+  overview = $4,
+  tagline = $5,
+  poster_url = $6,
+  trailer_url = $7,
+  rating = $8,
+  runtime = $9,
+  genres = $10,
+  budget = $11,
+  updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_movie = $1`
+
+	deleteMovieGenresQuery = `DELETE FROM movie_genres WHERE id_movie = $1`
+
+	deleteMovieTranslationsQuery = `DELETE FROM movie_translations WHERE id_movie = $1`
+)
+
+// UpdateMovie replaces a stored movie's details and genres with the ones in updateParams, ErrNoRecord is returned when
+// there's no such movie. Its translations are dropped so they're fetched again the next time they're needed
+func (p *MoviesRepository) UpdateMovie(ctx context.Context, idMovie int, updateParams CreateMovieParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MoviesRepository.UpdateMovie")
+	defer span.End()
+	var releaseDate *time.Time
+	if updateParams.ReleaseDate != "" {
+		parsed, err := time.Parse("2006-01-02", updateParams.ReleaseDate)
+		if err != nil {
+			return err
+		}
+		releaseDate = &parsed
+	}
+
+	txn, err := p.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer txn.Rollback(ctx)
+
+	tag, err := txn.Exec(ctx, updateMovieQuery,
+		idMovie,
+		updateParams.Title,
+		releaseDate,
+		updateParams.Overview,
+		updateParams.Tagline,
+		updateParams.PosterURL,
+		updateParams.TrailerURL,
+		updateParams.Rating,
+		updateParams.Runtime,
+		updateParams.Genres,
+		updateParams.Budget,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	// genres TMDB no longer has the movie in are dropped, a refresh without any keeps the old ones
+	if len(updateParams.GenreIDs) > 0 {
+		_, err = txn.Exec(ctx, deleteMovieGenresQuery, idMovie)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = createMovieGenresWithTxn(ctx, txn, idMovie, updateParams)
+	if err != nil {
+		return err
+	}
+
+	_, err = txn.Exec(ctx, deleteMovieTranslationsQuery, idMovie)
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit(ctx)
+}
+
+// MovieTranslation is a movie's details in a language other than the one it's stored in
+type MovieTranslation struct {
+	Title    string
+	Overview string
+	Tagline  string
+}
+
+const getMovieTranslationQuery = `
+  SELECT title, overview, tagline
+  FROM movie_translations
+  WHERE id_movie = $1 AND language = $2`
+
+// GetMovieTranslation returns the movie's details in the language, ErrNoRecord is returned when they haven't been
+// stored yet
+func (p *MoviesRepository) GetMovieTranslation(ctx context.Context, idMovie int, language string) (MovieTranslation, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MoviesRepository.GetMovieTranslation")
+	defer span.End()
+
+	var translation MovieTranslation
+	err := p.db.QueryRow(ctx, getMovieTranslationQuery, idMovie, language).Scan(&translation.Title, &translation.Overview, &translation.Tagline)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return MovieTranslation{}, ErrNoRecord
+		}
+		return MovieTranslation{}, err
+	}
+
+	return translation, nil
+}
+
+const upsertMovieTranslationQuery = `
+  INSERT INTO movie_translations (id_movie, language, title, overview, tagline) VALUES ($1, $2, $3, $4, $5)
+  ON CONFLICT (id_movie, language) DO UPDATE
+  SET title = excluded.title, overview = excluded.overview, tagline = excluded.tagline,
+    updated_at = (clock_timestamp() AT TIME ZONE 'UTC')`
+
+// UpsertMovieTranslation stores the movie's details in the language
+func (p *MoviesRepository) UpsertMovieTranslation(ctx context.Context, idMovie int, language string, translation MovieTranslation) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "MoviesRepository.UpsertMovieTranslation")
+	defer span.End()
+
+	_, err := p.db.Exec(ctx, upsertMovieTranslationQuery, idMovie, language, translation.Title, translation.Overview, translation.Tagline)
+	return err
+}
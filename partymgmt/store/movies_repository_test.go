@@ -0,0 +1,121 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestGetRecommendationSeeds(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_recommendation_seeds_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewMoviesRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "seeds-party", "seedsa")
+	idFirst := seedProfile(ctx, t, connPool)
+	idSecond := seedProfile(ctx, t, connPool)
+
+	// the party loved it even though TMDB didn't think much of it
+	idLoved := seedRatedPartyMovie(ctx, t, connPool, idParty, idFirst, "Loved", 10, 5.0, true)
+	rateMovie(ctx, t, connPool, idParty, idLoved, idFirst, 5)
+	rateMovie(ctx, t, connPool, idParty, idLoved, idSecond, 5)
+
+	seedRatedPartyMovie(ctx, t, connPool, idParty, idFirst, "Popular", 11, 8.5, true)
+
+	// TMDB rates it highly but the whole party disliked it
+	idDisliked := seedRatedPartyMovie(ctx, t, connPool, idParty, idFirst, "Disliked", 12, 9.0, true)
+	rateMovie(ctx, t, connPool, idParty, idDisliked, idFirst, 1)
+	rateMovie(ctx, t, connPool, idParty, idDisliked, idSecond, 2)
+
+	seedRatedPartyMovie(ctx, t, connPool, idParty, idFirst, "Meh", 13, 6.0, true)
+	seedRatedPartyMovie(ctx, t, connPool, idParty, idFirst, "Unwatched", 14, 9.5, false)
+
+	var titles []string
+	err := repo.GetRecommendationSeeds(ctx, idParty, 7.0, 5.0, 5, func(tmdbID int, title string) {
+		titles = append(titles, title)
+	})
+	testhelpers.Ok(t, err, "failed to get recommendation seeds")
+	testhelpers.Equals(t, []string{"Loved", "Popular", "Meh"}, titles)
+}
+
+func TestMovieTranslations(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_movie_translations_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewMoviesRepository(connPool)
+
+	idMovie, err := repo.CreateMovie(ctx, store.CreateMovieParams{Title: "Spirited Away", TMDBID: 129, Overview: "A girl wanders into a world of spirits."})
+	testhelpers.Ok(t, err, "failed to create movie")
+
+	_, err = repo.GetMovieTranslation(ctx, idMovie, "ja")
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	translation := store.MovieTranslation{Title: "千と千尋の神隠し", Overview: "少女は神々の世界に迷い込む。"}
+	err = repo.UpsertMovieTranslation(ctx, idMovie, "ja", translation)
+	testhelpers.Ok(t, err, "failed to store translation")
+
+	stored, err := repo.GetMovieTranslation(ctx, idMovie, "ja")
+	testhelpers.Ok(t, err, "failed to get translation")
+	testhelpers.Equals(t, translation, stored)
+
+	// refreshing the movie drops its translations so they're fetched again
+	err = repo.UpdateMovie(ctx, idMovie, store.CreateMovieParams{Title: "Spirited Away", TMDBID: 129})
+	testhelpers.Ok(t, err, "failed to update movie")
+
+	_, err = repo.GetMovieTranslation(ctx, idMovie, "ja")
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+}
+
+// seedRatedPartyMovie adds a movie with TMDB's rating to the party, it has a single viewing when watched
+func seedRatedPartyMovie(ctx context.Context, t *testing.T, conn *pgxpool.Pool, idParty, idAddedBy int, title string, tmdbID int, rating float64, watched bool) int {
+	t.Helper()
+	var idMovie int
+	err := conn.QueryRow(
+		ctx,
+		"insert into movies (title, poster_url, tmdb_id, overview, tagline, rating) values($1, '', $2, '', '', $3) returning id_movie",
+		title,
+		tmdbID,
+		rating,
+	).Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to insert movie")
+
+	status := "unwatched"
+	if watched {
+		status = "watched"
+	}
+
+	var idPartyMovie int
+	err = conn.QueryRow(
+		ctx,
+		"insert into party_movies (id_party, id_movie, id_added_by, watch_status) values($1, $2, $3, $4) returning id",
+		idParty,
+		idMovie,
+		idAddedBy,
+		status,
+	).Scan(&idPartyMovie)
+	testhelpers.Ok(t, err, "failed to insert party movie")
+
+	if watched {
+		_, err = conn.Exec(ctx, "insert into party_movie_viewings (id_party_movie, watch_date) values($1, $2)", idPartyMovie, time.Now())
+		testhelpers.Ok(t, err, "failed to insert party movie viewing")
+	}
+
+	return idMovie
+}
+
+func rateMovie(ctx context.Context, t *testing.T, conn *pgxpool.Pool, idParty, idMovie, idProfile, rating int) {
+	t.Helper()
+	_, err := conn.Exec(ctx, "insert into party_movie_ratings (id_party, id_movie, id_profile, rating) values($1, $2, $3, $4)", idParty, idMovie, idProfile, rating)
+	testhelpers.Ok(t, err, "failed to insert rating")
+}
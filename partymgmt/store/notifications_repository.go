@@ -0,0 +1,372 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+// NotificationTypeEnum is what a notification is about
+type NotificationTypeEnum string
+
+const (
+	NotificationTypePartyInvite        NotificationTypeEnum = "party_invite"
+	NotificationTypeInviteAccepted     NotificationTypeEnum = "invite_accepted"
+	NotificationTypeMovieSelected      NotificationTypeEnum = "movie_selected"
+	NotificationTypeMovieNightReminder NotificationTypeEnum = "movie_night_reminder"
+	NotificationTypeCommentMention     NotificationTypeEnum = "comment_mention"
+)
+
+type NotificationsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewNotificationsRepository(db *pgxpool.Pool) *NotificationsRepository {
+	return &NotificationsRepository{db: db}
+}
+
+// NotificationParams is what a notification is about, ids that don't apply to its type are 0
+type NotificationParams struct {
+	Type         NotificationTypeEnum
+	IDActor      int
+	IDParty      int
+	IDMovie      int
+	IDMovieNight int
+}
+
+// createNotificationsQuery is finished by a query selecting the id_profile of each recipient, the actor is never
+// notified about what they did and recipients who've turned the type off are skipped
+const (
+	createNotificationsQuery = `
+  INSERT INTO notifications (id_profile, type, id_actor, id_party, id_movie, id_movie_night)
+  SELECT recipients.id_profile, $1::notification_type, nullif($2::int, 0), nullif($3::int, 0), nullif($4::int, 0), nullif($5::int, 0)
+  FROM (`
+
+	notificationRecipientsFilter = `) recipients
+  WHERE recipients.id_profile IS DISTINCT FROM nullif($2::int, 0)
+  AND NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE notification_preferences.id_profile = recipients.id_profile
+    AND notification_preferences.type = $1::notification_type
+    AND NOT notification_preferences.enabled
+  );
+`
+
+	createPartyMembersNotificationQuery = createNotificationsQuery + `
+    SELECT party_members.id_member AS id_profile FROM party_members WHERE party_members.id_party = $3` +
+		notificationRecipientsFilter
+
+	createPartyOwnerNotificationQuery = createNotificationsQuery + `
+    SELECT parties.id_owner AS id_profile FROM parties WHERE parties.id_party = $3 AND parties.id_owner IS NOT NULL` +
+		notificationRecipientsFilter
+
+	createEmailNotificationQuery = createNotificationsQuery + `
+    SELECT profiles.id_profile
+    FROM profiles
+    JOIN accounts ON accounts.id_account = profiles.id_account
+    WHERE accounts.email = $6` +
+		notificationRecipientsFilter
+
+	createPartyMembersByIDNotificationQuery = createNotificationsQuery + `
+    SELECT party_members.id_member AS id_profile
+    FROM party_members
+    WHERE party_members.id_party = $3 AND party_members.id_member = ANY($6::int[])` +
+		notificationRecipientsFilter
+)
+
+// CreatePartyMembersNotification notifies every member of the party in params
+func (n *NotificationsRepository) CreatePartyMembersNotification(ctx context.Context, params NotificationParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.CreatePartyMembersNotification")
+	defer span.End()
+
+	_, err := n.db.Exec(ctx, createPartyMembersNotificationQuery, params.Type, params.IDActor, params.IDParty, params.IDMovie, params.IDMovieNight)
+	return err
+}
+
+// CreatePartyOwnerNotification notifies the owner of the party in params
+func (n *NotificationsRepository) CreatePartyOwnerNotification(ctx context.Context, params NotificationParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.CreatePartyOwnerNotification")
+	defer span.End()
+
+	_, err := n.db.Exec(ctx, createPartyOwnerNotificationQuery, params.Type, params.IDActor, params.IDParty, params.IDMovie, params.IDMovieNight)
+	return err
+}
+
+// CreateEmailNotification notifies the watcher with the email, nobody is notified when they haven't signed up
+func (n *NotificationsRepository) CreateEmailNotification(ctx context.Context, email string, params NotificationParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.CreateEmailNotification")
+	defer span.End()
+
+	_, err := n.db.Exec(ctx, createEmailNotificationQuery, params.Type, params.IDActor, params.IDParty, params.IDMovie, params.IDMovieNight, email)
+	return err
+}
+
+// CreatePartyMembersByIDNotification notifies the members of the party in params with the ids, ids of anyone who
+// isn't a member are skipped
+func (n *NotificationsRepository) CreatePartyMembersByIDNotification(ctx context.Context, idProfiles []int, params NotificationParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.CreatePartyMembersByIDNotification")
+	defer span.End()
+
+	_, err := n.db.Exec(ctx, createPartyMembersByIDNotificationQuery, params.Type, params.IDActor, params.IDParty, params.IDMovie, params.IDMovieNight, idProfiles)
+	return err
+}
+
+// claiming the movie nights and notifying their members happens in one statement so a movie night is only ever
+// claimed by one instance and its reminders can't be lost in between, members who said they aren't going are left out
+const createMovieNightRemindersQuery = `
+  WITH due AS (
+    UPDATE movie_nights
+    SET reminder_sent_at = $1
+    WHERE movie_nights.completed_at IS NULL
+    AND movie_nights.reminder_sent_at IS NULL
+    AND movie_nights.reminder_minutes > 0
+    AND movie_nights.starts_at > $1
+    AND movie_nights.starts_at - make_interval(mins => movie_nights.reminder_minutes) <= $1
+    RETURNING movie_nights.id_movie_night, movie_nights.id_party, movie_nights.id_movie
+  )
+  INSERT INTO notifications (id_profile, type, id_party, id_movie, id_movie_night)
+  SELECT party_members.id_member, 'movie_night_reminder', due.id_party, due.id_movie, due.id_movie_night
+  FROM due
+  JOIN party_members ON party_members.id_party = due.id_party
+  LEFT JOIN movie_night_rsvps ON movie_night_rsvps.id_movie_night = due.id_movie_night
+    AND movie_night_rsvps.id_profile = party_members.id_member
+  WHERE movie_night_rsvps.response IS DISTINCT FROM 'not_going'
+  AND NOT EXISTS (
+    SELECT 1 FROM notification_preferences
+    WHERE notification_preferences.id_profile = party_members.id_member
+    AND notification_preferences.type = 'movie_night_reminder'
+    AND NOT notification_preferences.enabled
+  );
+`
+
+// CreateMovieNightReminders reminds the members of every movie night whose reminder is due at now, returning how many
+// reminders were created
+func (n *NotificationsRepository) CreateMovieNightReminders(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.CreateMovieNightReminders")
+	defer span.End()
+
+	tag, err := n.db.Exec(ctx, createMovieNightRemindersQuery, now)
+	if err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+type NotificationResult struct {
+	ID   int
+	Type NotificationTypeEnum
+	// IDActor is 0 when nobody did it or they've deleted their account
+	IDActor        int
+	ActorFirstName string
+	ActorLastName  string
+	IDParty        int
+	PartyName      string
+	IDMovie        int
+	MovieTitle     string
+	IDMovieNight   int
+	// StartsAt and TimeZone are only set for notifications about a movie night
+	StartsAt  *time.Time
+	TimeZone  string
+	ReadAt    *time.Time
+	CreatedAt time.Time
+}
+
+const notificationColumns = `
+    notifications.id_notification,
+    notifications.type::text,
+    coalesce(notifications.id_actor, 0),
+    coalesce(actors.first_name, ''),
+    coalesce(actors.last_name, ''),
+    coalesce(notifications.id_party, 0),
+    coalesce(parties.name, ''),
+    coalesce(notifications.id_movie, 0),
+    coalesce(movies.title, ''),
+    coalesce(notifications.id_movie_night, 0),
+    movie_nights.starts_at,
+    coalesce(movie_nights.time_zone, ''),
+    notifications.read_at,
+    notifications.created_at
+  FROM notifications
+  LEFT JOIN profiles actors ON actors.id_profile = notifications.id_actor
+  LEFT JOIN parties ON parties.id_party = notifications.id_party
+  LEFT JOIN movies ON movies.id_movie = notifications.id_movie
+  LEFT JOIN movie_nights ON movie_nights.id_movie_night = notifications.id_movie_night
+`
+
+func scanNotification(row pgx.Row) (NotificationResult, error) {
+	var res NotificationResult
+	err := row.Scan(
+		&res.ID,
+		&res.Type,
+		&res.IDActor,
+		&res.ActorFirstName,
+		&res.ActorLastName,
+		&res.IDParty,
+		&res.PartyName,
+		&res.IDMovie,
+		&res.MovieTitle,
+		&res.IDMovieNight,
+		&res.StartsAt,
+		&res.TimeZone,
+		&res.ReadAt,
+		&res.CreatedAt,
+	)
+	return res, err
+}
+
+const getNotificationQuery = `SELECT` + notificationColumns + `
+  WHERE notifications.id_profile = $1 AND notifications.id_notification = $2;
+`
+
+// GetNotification reads one of the watcher's notifications, ErrNoRecord is returned when it isn't theirs
+func (n *NotificationsRepository) GetNotification(ctx context.Context, idProfile, idNotification int) (NotificationResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.GetNotification")
+	defer span.End()
+
+	res, err := scanNotification(n.db.QueryRow(ctx, getNotificationQuery, idProfile, idNotification))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return NotificationResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return NotificationResult{}, err
+	}
+
+	return res, nil
+}
+
+const getNotificationsQuery = `SELECT` + notificationColumns + `
+  WHERE notifications.id_profile = $1
+  ORDER BY notifications.created_at DESC, notifications.id_notification DESC
+  LIMIT $2;
+`
+
+// GetNotifications reads the watcher's latest notifications, newest first
+func (n *NotificationsRepository) GetNotifications(ctx context.Context, idProfile, limit int, assignFn func(NotificationResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.GetNotifications")
+	defer span.End()
+
+	rows, err := n.db.Query(ctx, getNotificationsQuery, idProfile, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanNotification(rows)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const getUnreadNotificationCountQuery = `
+  SELECT count(*) FROM notifications WHERE id_profile = $1 AND read_at IS NULL;
+`
+
+func (n *NotificationsRepository) GetUnreadCount(ctx context.Context, idProfile int) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.GetUnreadCount")
+	defer span.End()
+
+	var count int
+	err := n.db.QueryRow(ctx, getUnreadNotificationCountQuery, idProfile).Scan(&count)
+	if err != nil {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// a notification that's already been read keeps when it was first read
+const (
+	markNotificationAsReadQuery = `
+  UPDATE notifications
+  SET read_at = coalesce(read_at, $3)
+  WHERE id_profile = $1 AND id_notification = $2;
+`
+
+	markAllNotificationsAsReadQuery = `
+  UPDATE notifications
+  SET read_at = $2
+  WHERE id_profile = $1 AND read_at IS NULL;
+`
+)
+
+// MarkAsRead marks one of the watcher's notifications as read, ErrNoRecord is returned when it isn't theirs
+func (n *NotificationsRepository) MarkAsRead(ctx context.Context, idProfile, idNotification int, readAt time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.MarkAsRead")
+	defer span.End()
+
+	tag, err := n.db.Exec(ctx, markNotificationAsReadQuery, idProfile, idNotification, readAt)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+func (n *NotificationsRepository) MarkAllAsRead(ctx context.Context, idProfile int, readAt time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.MarkAllAsRead")
+	defer span.End()
+
+	_, err := n.db.Exec(ctx, markAllNotificationsAsReadQuery, idProfile, readAt)
+	return err
+}
+
+const getNotificationPreferencesQuery = `
+  SELECT type::text, enabled FROM notification_preferences WHERE id_profile = $1;
+`
+
+// GetPreferences reads the types of notification the watcher has turned on or off, types they've never changed aren't
+// read
+func (n *NotificationsRepository) GetPreferences(ctx context.Context, idProfile int, assignFn func(notificationType NotificationTypeEnum, enabled bool)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.GetPreferences")
+	defer span.End()
+
+	rows, err := n.db.Query(ctx, getNotificationPreferencesQuery, idProfile)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			notificationType NotificationTypeEnum
+			enabled          bool
+		)
+		err = rows.Scan(&notificationType, &enabled)
+		if err != nil {
+			return err
+		}
+		assignFn(notificationType, enabled)
+	}
+
+	return rows.Err()
+}
+
+const setNotificationPreferenceQuery = `
+  INSERT INTO notification_preferences (id_profile, type, enabled)
+  VALUES ($1, $2, $3)
+  ON CONFLICT (id_profile, type) DO UPDATE
+  SET enabled = excluded.enabled, updated_at = (clock_timestamp() AT TIME ZONE 'UTC');
+`
+
+func (n *NotificationsRepository) SetPreference(ctx context.Context, idProfile int, notificationType NotificationTypeEnum, enabled bool) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "NotificationsRepository.SetPreference")
+	defer span.End()
+
+	_, err := n.db.Exec(ctx, setNotificationPreferenceQuery, idProfile, notificationType, enabled)
+	return err
+}
@@ -0,0 +1,163 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestNotifications(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_notifications_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewNotificationsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "notified-party", "notifya")
+	idOwner := seedProfile(ctx, t, connPool)
+	idPicker := seedProfile(ctx, t, connPool)
+	idMuted := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idOwner)
+	seedPartyMember(ctx, t, connPool, idParty, idPicker)
+	seedPartyMember(ctx, t, connPool, idParty, idMuted)
+
+	_, err := connPool.Exec(ctx, "update parties set id_owner = $2 where id_party = $1", idParty, idOwner)
+	testhelpers.Ok(t, err, "failed to set party owner")
+
+	addedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idOwner, "Heat", 170, addedAt, nil)
+
+	var idMovie int
+	err = connPool.QueryRow(ctx, "select id_movie from movies where title = 'Heat'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie id")
+
+	err = repo.SetPreference(ctx, idMuted, store.NotificationTypeMovieSelected, false)
+	testhelpers.Ok(t, err, "failed to turn off notifications")
+
+	err = repo.CreatePartyMembersNotification(ctx, store.NotificationParams{
+		Type:    store.NotificationTypeMovieSelected,
+		IDActor: idPicker,
+		IDParty: idParty,
+		IDMovie: idMovie,
+	})
+	testhelpers.Ok(t, err, "failed to notify party members")
+
+	err = repo.CreatePartyOwnerNotification(ctx, store.NotificationParams{
+		Type:    store.NotificationTypeInviteAccepted,
+		IDActor: idMuted,
+		IDParty: idParty,
+	})
+	testhelpers.Ok(t, err, "failed to notify party owner")
+
+	// only the owner was notified since the picker picked it and the muted member turned the type off
+	testhelpers.Equals(t, 0, getUnreadCount(ctx, t, repo, idPicker))
+	testhelpers.Equals(t, 0, getUnreadCount(ctx, t, repo, idMuted))
+	testhelpers.Equals(t, 2, getUnreadCount(ctx, t, repo, idOwner))
+
+	notifications := make([]store.NotificationResult, 0)
+	err = repo.GetNotifications(ctx, idOwner, 10, func(res store.NotificationResult) {
+		notifications = append(notifications, res)
+	})
+	testhelpers.Ok(t, err, "failed to get notifications")
+	testhelpers.Equals(t, 2, len(notifications))
+	testhelpers.Equals(t, store.NotificationTypeInviteAccepted, notifications[0].Type)
+	testhelpers.Equals(t, store.NotificationTypeMovieSelected, notifications[1].Type)
+	testhelpers.Equals(t, "notified-party", notifications[1].PartyName)
+	testhelpers.Equals(t, "Heat", notifications[1].MovieTitle)
+	testhelpers.Equals(t, idPicker, notifications[1].IDActor)
+
+	readAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	err = repo.MarkAsRead(ctx, idPicker, notifications[0].ID, readAt)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.MarkAsRead(ctx, idOwner, notifications[0].ID, readAt)
+	testhelpers.Ok(t, err, "failed to mark notification as read")
+	testhelpers.Equals(t, 1, getUnreadCount(ctx, t, repo, idOwner))
+
+	read, err := repo.GetNotification(ctx, idOwner, notifications[0].ID)
+	testhelpers.Ok(t, err, "failed to get notification")
+	testhelpers.Assert(t, read.ReadAt != nil && readAt.Equal(*read.ReadAt), "expected read at %v, got %v", readAt, read.ReadAt)
+
+	err = repo.MarkAllAsRead(ctx, idOwner, readAt)
+	testhelpers.Ok(t, err, "failed to mark all notifications as read")
+	testhelpers.Equals(t, 0, getUnreadCount(ctx, t, repo, idOwner))
+
+	preferences := make(map[store.NotificationTypeEnum]bool)
+	err = repo.GetPreferences(ctx, idMuted, func(notificationType store.NotificationTypeEnum, enabled bool) {
+		preferences[notificationType] = enabled
+	})
+	testhelpers.Ok(t, err, "failed to get preferences")
+	testhelpers.Equals(t, map[store.NotificationTypeEnum]bool{store.NotificationTypeMovieSelected: false}, preferences)
+}
+
+func TestMovieNightReminders(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_movie_night_reminders_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewNotificationsRepository(connPool)
+	movieNights := store.NewMovieNightsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "reminded-party", "remindb")
+	idHost := seedProfile(ctx, t, connPool)
+	idGuest := seedProfile(ctx, t, connPool)
+	idAbsent := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idHost)
+	seedPartyMember(ctx, t, connPool, idParty, idGuest)
+	seedPartyMember(ctx, t, connPool, idParty, idAbsent)
+
+	startsAt := time.Date(2025, 5, 2, 19, 0, 0, 0, time.UTC)
+	params := store.MovieNightParams{StartsAt: startsAt, TimeZone: "UTC", ReminderMinutes: 60}
+	idNight, err := movieNights.CreateMovieNight(ctx, idParty, idHost, params)
+	testhelpers.Ok(t, err, "failed to create movie night")
+
+	err = movieNights.SetRSVP(ctx, idNight, idAbsent, store.RSVPResponseNotGoing)
+	testhelpers.Ok(t, err, "failed to set rsvp")
+
+	// too early for the reminder
+	created, err := repo.CreateMovieNightReminders(ctx, startsAt.Add(-2*time.Hour))
+	testhelpers.Ok(t, err, "failed to create reminders")
+	testhelpers.Equals(t, int64(0), created)
+
+	created, err = repo.CreateMovieNightReminders(ctx, startsAt.Add(-30*time.Minute))
+	testhelpers.Ok(t, err, "failed to create reminders")
+	testhelpers.Equals(t, int64(2), created)
+	testhelpers.Equals(t, 0, getUnreadCount(ctx, t, repo, idAbsent))
+
+	// members are only reminded once
+	created, err = repo.CreateMovieNightReminders(ctx, startsAt.Add(-20*time.Minute))
+	testhelpers.Ok(t, err, "failed to create reminders")
+	testhelpers.Equals(t, int64(0), created)
+
+	// moving the movie night reminds them again
+	params.StartsAt = startsAt.Add(time.Hour)
+	err = movieNights.UpdateMovieNight(ctx, idNight, params)
+	testhelpers.Ok(t, err, "failed to update movie night")
+
+	created, err = repo.CreateMovieNightReminders(ctx, startsAt.Add(15*time.Minute))
+	testhelpers.Ok(t, err, "failed to create reminders")
+	testhelpers.Equals(t, int64(2), created)
+
+	var reminder store.NotificationResult
+	err = repo.GetNotifications(ctx, idGuest, 1, func(res store.NotificationResult) {
+		reminder = res
+	})
+	testhelpers.Ok(t, err, "failed to get notifications")
+	testhelpers.Equals(t, store.NotificationTypeMovieNightReminder, reminder.Type)
+	testhelpers.Equals(t, idNight, reminder.IDMovieNight)
+	testhelpers.Assert(t, reminder.StartsAt != nil && params.StartsAt.Equal(*reminder.StartsAt), "expected starts at %v, got %v", params.StartsAt, reminder.StartsAt)
+}
+
+func getUnreadCount(ctx context.Context, t *testing.T, repo *store.NotificationsRepository, idProfile int) int {
+	t.Helper()
+	count, err := repo.GetUnreadCount(ctx, idProfile)
+	testhelpers.Ok(t, err, "failed to get unread count")
+	return count
+}
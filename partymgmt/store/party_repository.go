@@ -44,7 +44,7 @@ func (p PartyRepository) withTransaction(ctx context.Context, tx pgx.Tx) (PartyR
 
 	var err error
 	if tx == nil {
-		tx, err = p.db.Begin(ctx)
+		tx, err = p.begin(ctx)
 		if err != nil {
 			return p, err
 		}
@@ -80,6 +80,15 @@ func (p PartyRepository) rollback(ctx context.Context) error {
 	return nil
 }
 
+// begin starts a transaction, or a savepoint in the repository's transaction when it's running in one so the work can
+// be committed along with everything else in it
+func (p PartyRepository) begin(ctx context.Context) (pgx.Tx, error) {
+	if p.tx != nil {
+		return p.tx.Begin(ctx)
+	}
+	return p.db.Begin(ctx)
+}
+
 type querier interface {
 	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
 	Exec(ctx context.Context, sql string, arguments ...any) (pgconn.CommandTag, error)
@@ -201,7 +210,8 @@ const (
 func (p PartyRepository) CreateParty(ctx context.Context, idWatcher int, name, shortID string) (int, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.CreateParty")
 	defer span.End()
-	txn, err := p.db.Begin(ctx)
+	// begin makes this a savepoint when creating the party is part of a bigger transaction
+	txn, err := p.begin(ctx)
 	if err != nil {
 		return 0, err
 	}
@@ -247,62 +257,383 @@ func (p PartyRepository) AddMovieToParty(ctx context.Context, idParty, idMovie,
 	return nil
 }
 
-func (p PartyRepository) MarkPartyMovieAsWatched(ctx context.Context, idParty, idMovie int) error {
+const recordViewingQuery = `
+  INSERT INTO party_movie_viewings (id_party_movie, watch_date)
+  SELECT party_movies.id, $3
+  FROM party_movies
+  WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2
+  RETURNING id_viewing, id_party_movie;
+`
+
+// the watch date on the party movie is always its latest viewing
+const markWatchedQuery = `
+  UPDATE party_movies
+  SET watch_status = 'watched',
+    watch_date = (SELECT max(watch_date) FROM party_movie_viewings WHERE id_party_movie = party_movies.id)
+  WHERE party_movies.id = $1;
+`
+
+// everyone who had joined the party by the watch date is counted as being there until they say otherwise
+const recordDefaultAttendanceQuery = `
+  INSERT INTO party_movie_attendance (id_viewing, id_profile)
+  SELECT party_movie_viewings.id_viewing, party_members.id_member
+  FROM party_movie_viewings
+  JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+  JOIN party_members ON party_members.id_party = party_movies.id_party
+  WHERE party_movie_viewings.id_viewing = $1 AND party_members.created_at <= party_movie_viewings.watch_date;
+`
+
+// MarkPartyMovieAsWatched records a viewing of the movie by the party on watchDate, the members of the party on that
+// date are recorded as having been there. Movies can be watched any number of times, ErrNoRecord is returned when the
+// movie isn't in the party
+func (p PartyRepository) MarkPartyMovieAsWatched(ctx context.Context, idParty, idMovie int, watchDate time.Time) error {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.MarkPartyMovieAsWatched")
 	defer span.End()
-	curTime := time.Now().UTC()
-	return p.updatePartyMovieStatus(ctx, idParty, idMovie, WatchStatusWatched, &curTime)
+
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback(ctx)
+
+	var idViewing, idPartyMovie int
+	err = tx.QueryRow(ctx, recordViewingQuery, idParty, idMovie, watchDate.UTC()).Scan(&idViewing, &idPartyMovie)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	_, err = tx.Exec(ctx, markWatchedQuery, idPartyMovie)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, recordDefaultAttendanceQuery, idViewing)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+type AttendanceResult struct {
+	IDMovie   int
+	IDWatcher int
+	FirstName string
+	LastName  string
+	Attended  bool
 }
 
-const setCurrentSelectMoviesToUnwatched = `
+const getAttendanceQuery = `
+  SELECT party_movies.id_movie, profiles.id_profile, profiles.first_name, profiles.last_name, party_movie_attendance.attended
+  FROM party_movies
+  JOIN LATERAL (
+    SELECT party_movie_viewings.id_viewing
+    FROM party_movie_viewings
+    WHERE party_movie_viewings.id_party_movie = party_movies.id
+    ORDER BY party_movie_viewings.watch_date DESC, party_movie_viewings.id_viewing DESC
+    LIMIT 1
+  ) latest_viewing ON true
+  JOIN party_movie_attendance ON party_movie_attendance.id_viewing = latest_viewing.id_viewing
+  JOIN profiles ON profiles.id_profile = party_movie_attendance.id_profile
+  WHERE party_movies.id_party = $1 AND party_movies.watch_status = 'watched'
+    AND ($2 = 0 OR party_movies.id_movie = $2)
+  ORDER BY party_movies.id_movie, profiles.first_name, profiles.last_name;
+`
+
+// GetAttendance returns who was and wasn't there for the latest viewing of the movies the party has watched, when
+// idMovie is 0 the attendance for every watched movie is returned
+func (p PartyRepository) GetAttendance(ctx context.Context, idParty, idMovie int, assignFn func(AttendanceResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetAttendance")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getAttendanceQuery, idParty, idMovie)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res AttendanceResult
+		err := rows.Scan(&res.IDMovie, &res.IDWatcher, &res.FirstName, &res.LastName, &res.Attended)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+// a rewatch that was picked goes back to being watched, when $2 is 0 whichever movie is selected is un-selected
+const unselectMovieQuery = `
 UPDATE party_movies
-SET watch_status = 'unwatched'
-WHERE id_party = $1 AND watch_status = 'selected';
+SET watch_status = CASE
+    WHEN EXISTS (SELECT 1 FROM party_movie_viewings WHERE id_party_movie = party_movies.id) THEN 'watched'::watch_status
+    ELSE 'unwatched'::watch_status
+  END
+WHERE id_party = $1 AND ($2 = 0 OR id_movie = $2) AND watch_status = 'selected'
+RETURNING id_movie, watch_status;
 `
 
+// a member is picked at random from those who added a movie that can be selected, then one of their movies is picked.
+// Vetoed movies are never picked, when $2 is true only movies that are streaming on a service someone watching
+// subscribes to in their region can be picked and when $3 is more than 0 movies last watched more than that many
+// months ago can be picked for a rewatch.
+// $4 is the members watching, or every member when it's empty. Movies any of them aren't interested in are never
+// picked and the rest are weighted by the flags they've set, a movie two of them must watch weighs 3 and one they've
+// both seen weighs a third against 1 for a movie nobody has flagged. Members are weighted by the average weight of
+// their movies and their movie by its own weight, so a movie's chance is its weight over how many movies its member
+// added. A movie two of them must watch is three times as likely as an unflagged one added by the same member or by a
+// member who added as many movies, and without any flags every member is as likely to be picked as the others.
+// Weighted picks order by -ln(u)/weight which is the same as drawing each row with a chance proportional to its
+// weight. Flags are about watching a movie for the first time so they're kept but ignored once the party has watched it
 const selectMovieForPartyQuery = `
-WITH party_members_for_selection AS (
-  select distinct(id_added_by) as id_member
+WITH attendees AS (
+  select party_members.id_member
+  from party_members
+  where party_members.id_party = $1
+  and (coalesce(cardinality($4::int[]), 0) = 0 or party_members.id_member = any($4::int[]))
+), attendee_flags AS (
+  select party_movie_flags.id_movie, party_movie_flags.flag
+  from party_movie_flags
+  join attendees on attendees.id_member = party_movie_flags.id_profile
+  join party_movies on party_movies.id_party = party_movie_flags.id_party and party_movies.id_movie = party_movie_flags.id_movie
+  where party_movie_flags.id_party = $1 and party_movies.watch_status != 'watched'
+), selectable_movies AS (
+  select
+    party_movies.id_movie,
+    party_movies.id_added_by,
+    party_movies.watch_status,
+    (1.0 + (select count(*) from attendee_flags where attendee_flags.id_movie = party_movies.id_movie and attendee_flags.flag = 'must_watch'))
+      / (1.0 + (select count(*) from attendee_flags where attendee_flags.id_movie = party_movies.id_movie and attendee_flags.flag = 'seen_it')) as weight
   from party_movies
-  where id_party = $1
+  where party_movies.id_party = $1
+  and (
+    party_movies.watch_status = 'unwatched'
+    or (
+      $3 > 0
+      and party_movies.watch_status = 'watched'
+      and party_movies.watch_date < (clock_timestamp() - make_interval(months => $3))
+    )
+  )
+  and not exists (
+    select 1
+    from party_movie_vetoes
+    where party_movie_vetoes.id_party = party_movies.id_party and party_movie_vetoes.id_movie = party_movies.id_movie
+  )
+  and not exists (
+    select 1
+    from attendee_flags
+    where attendee_flags.id_movie = party_movies.id_movie and attendee_flags.flag = 'not_interested'
+  )
+  and (
+    not $2
+    or exists (
+      select 1
+      from attendees
+      join profiles on profiles.id_profile = attendees.id_member
+      join profile_subscriptions on profile_subscriptions.id_profile = profiles.id_profile
+      join movie_watch_providers on movie_watch_providers.id_movie = party_movies.id_movie
+        and movie_watch_providers.region = profiles.watch_region
+        and movie_watch_providers.id_provider = profile_subscriptions.id_provider
+    )
+  )
 ), selected_member_id AS (
-  select id_member 
-  from party_members_for_selection
-  order by random()
+  select id_added_by as id_member
+  from selectable_movies
+  group by id_added_by
+  order by -ln(1.0 - random()) / avg(weight)
   limit 1
 ), selected_movie AS (
-  select id_movie 
-  from party_movies 
-  where id_party = $1 
-  and id_added_by = (select id_member from selected_member_id) 
-  AND watch_status = 'unwatched'
-  order by random()
+  select id_movie, watch_status
+  from selectable_movies
+  where id_added_by = (select id_member from selected_member_id)
+  order by -ln(1.0 - random()) / weight
   limit 1
 )
 UPDATE party_movies
 SET watch_status = 'selected'
 WHERE id_movie = (select id_movie from selected_movie)
 AND id_party = $1
-RETURNING id_movie;
+RETURNING id_movie, (select watch_status from selected_movie);
 `
 
-func (p PartyRepository) SelectMovieForParty(ctx context.Context, idParty int) error {
+type SelectMovieParams struct {
+	// OnlyStreamable limits the selection to movies a member who's watching can stream with their subscriptions
+	OnlyStreamable bool
+	// RewatchAfterMonths lets movies the party last watched more than this many months ago be picked again, watched
+	// movies are never picked when it's 0
+	RewatchAfterMonths int
+	// AttendeeIDs are the members who are watching, a movie any of them isn't interested in is never picked and the
+	// movies they must watch or have seen are more or less likely to be. Every member is watching when it's empty
+	AttendeeIDs []int
+}
+
+type SelectMovieResult struct {
+	IDMovie int
+	// FromStatus is the status the movie had before it was selected
+	FromStatus WatchStatusEnum
+	// IDUnselectedMovie is the movie that was selected before, it's 0 when there wasn't one
+	IDUnselectedMovie int
+	// UnselectedStatus is the status the movie that was selected before went back to
+	UnselectedStatus WatchStatusEnum
+}
+
+// SelectMovieForParty randomly selects a movie for the party to watch in place of the one that's selected, returning
+// ErrNoRecord when there's no movie that can be selected
+func (p PartyRepository) SelectMovieForParty(ctx context.Context, idParty int, params SelectMovieParams) (SelectMovieResult, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.SelectMovieForParty")
 	defer span.End()
-	tx, err := p.db.Begin(ctx)
+	tx, err := p.begin(ctx)
+	if err != nil {
+		return SelectMovieResult{}, err
+	}
+
+	defer tx.Rollback(ctx)
+
+	var res SelectMovieResult
+	err = tx.QueryRow(ctx, unselectMovieQuery, idParty, 0).Scan(&res.IDUnselectedMovie, &res.UnselectedStatus)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return SelectMovieResult{}, err
+	}
+
+	err = tx.QueryRow(ctx, selectMovieForPartyQuery, idParty, params.OnlyStreamable, params.RewatchAfterMonths, params.AttendeeIDs).Scan(&res.IDMovie, &res.FromStatus)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return SelectMovieResult{}, ErrNoRecord
+		}
+		return SelectMovieResult{}, err
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return SelectMovieResult{}, err
+	}
+
+	return res, nil
+}
+
+// UnselectMovieForParty puts the selected movie back to the status it had before it was selected, ErrNoRecord is
+// returned when the movie isn't selected
+func (p PartyRepository) UnselectMovieForParty(ctx context.Context, idParty, idMovie int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.UnselectMovieForParty")
+	defer span.End()
+
+	var (
+		id     int
+		status WatchStatusEnum
+	)
+	err := p.getQuerier(ctx).QueryRow(ctx, unselectMovieQuery, idParty, idMovie).Scan(&id, &status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (p PartyRepository) IsPartyMember(ctx context.Context, idParty, idWatcher int) (bool, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.IsPartyMember")
+	defer span.End()
+
+	var isMember bool
+	err := p.getQuerier(ctx).QueryRow(ctx, isPartyMemberQuery, idParty, idWatcher).Scan(&isMember)
+	if err != nil {
+		return false, err
+	}
+
+	return isMember, nil
+}
+
+type PartyMovieStatusResult struct {
+	Status WatchStatusEnum
+	// WatchDate is when the latest viewing was, it's nil when the party hasn't watched the movie
+	WatchDate *time.Time
+	Viewings  int
+}
+
+// the movie is locked so its status can't change under whoever is changing it
+const getPartyMovieStatusQuery = `
+  SELECT
+    party_movies.watch_status,
+    (SELECT max(watch_date) FROM party_movie_viewings WHERE id_party_movie = party_movies.id),
+    (SELECT count(*) FROM party_movie_viewings WHERE id_party_movie = party_movies.id)
+  FROM party_movies
+  WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2
+  FOR UPDATE OF party_movies;
+`
+
+// GetPartyMovieStatus returns the status of the party's movie, ErrNoRecord is returned when the movie isn't in the party
+func (p PartyRepository) GetPartyMovieStatus(ctx context.Context, idParty, idMovie int) (PartyMovieStatusResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetPartyMovieStatus")
+	defer span.End()
+
+	var res PartyMovieStatusResult
+	err := p.getQuerier(ctx).QueryRow(ctx, getPartyMovieStatusQuery, idParty, idMovie).Scan(&res.Status, &res.WatchDate, &res.Viewings)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PartyMovieStatusResult{}, ErrNoRecord
+		}
+		return PartyMovieStatusResult{}, err
+	}
+
+	return res, nil
+}
+
+const deleteLatestViewingQuery = `
+  DELETE FROM party_movie_viewings
+  WHERE id_viewing = (
+    SELECT party_movie_viewings.id_viewing
+    FROM party_movie_viewings
+    JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+    WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2
+    ORDER BY party_movie_viewings.watch_date DESC, party_movie_viewings.id_viewing DESC
+    LIMIT 1
+  )
+  RETURNING id_party_movie;
+`
+
+// a movie with no viewings left hasn't been watched
+const restoreWatchStatusQuery = `
+  UPDATE party_movies
+  SET watch_date = (SELECT max(watch_date) FROM party_movie_viewings WHERE id_party_movie = party_movies.id),
+    watch_status = CASE
+      WHEN EXISTS (SELECT 1 FROM party_movie_viewings WHERE id_party_movie = party_movies.id) THEN 'watched'::watch_status
+      ELSE 'unwatched'::watch_status
+    END
+  WHERE party_movies.id = $1;
+`
+
+// UnwatchPartyMovie removes the latest viewing of the movie along with who was there for it, the movie goes back to
+// being unwatched when it was the only viewing. ErrNoRecord is returned when the party hasn't watched the movie
+func (p PartyRepository) UnwatchPartyMovie(ctx context.Context, idParty, idMovie int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.UnwatchPartyMovie")
+	defer span.End()
+
+	tx, err := p.begin(ctx)
 	if err != nil {
 		return err
 	}
 
 	defer tx.Rollback(ctx)
 
-	_, err = tx.Exec(ctx, setCurrentSelectMoviesToUnwatched, idParty)
+	var idPartyMovie int
+	err = tx.QueryRow(ctx, deleteLatestViewingQuery, idParty, idMovie).Scan(&idPartyMovie)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
 		return err
 	}
 
-	var selectedMovieID int
-	err = tx.QueryRow(ctx, selectMovieForPartyQuery, idParty).Scan(&selectedMovieID)
+	_, err = tx.Exec(ctx, restoreWatchStatusQuery, idPartyMovie)
 	if err != nil {
 		return err
 	}
@@ -310,20 +641,152 @@ func (p PartyRepository) SelectMovieForParty(ctx context.Context, idParty int) e
 	return tx.Commit(ctx)
 }
 
-const updateWatchStatusQuery = `
-  update party_movies
-  set watch_status = $1, watch_date = $4
-  where id_party = $2 and id_movie = $3
+const updateLatestViewingDateQuery = `
+  UPDATE party_movie_viewings
+  SET watch_date = $3
+  WHERE id_viewing = (
+    SELECT party_movie_viewings.id_viewing
+    FROM party_movie_viewings
+    JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+    WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2
+    ORDER BY party_movie_viewings.watch_date DESC, party_movie_viewings.id_viewing DESC
+    LIMIT 1
+  )
+  RETURNING id_party_movie;
 `
 
-func (p PartyRepository) updatePartyMovieStatus(ctx context.Context, idParty, idMovie int, status WatchStatusEnum, watchDate *time.Time) error {
-	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.updatePartyMovieStatus")
+// UpdateWatchDate changes when the latest viewing of the movie was, ErrNoRecord is returned when the party hasn't
+// watched the movie
+func (p PartyRepository) UpdateWatchDate(ctx context.Context, idParty, idMovie int, watchDate time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.UpdateWatchDate")
 	defer span.End()
-	_, err := p.db.Exec(ctx, updateWatchStatusQuery, status, idParty, idMovie, watchDate)
+
+	tx, err := p.begin(ctx)
 	if err != nil {
 		return err
 	}
-	return nil
+
+	defer tx.Rollback(ctx)
+
+	var idPartyMovie int
+	err = tx.QueryRow(ctx, updateLatestViewingDateQuery, idParty, idMovie, watchDate.UTC()).Scan(&idPartyMovie)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNoRecord
+		}
+		return err
+	}
+
+	_, err = tx.Exec(ctx, restoreWatchStatusQuery, idPartyMovie)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+type StatusChangeParams struct {
+	IDParty   int
+	IDMovie   int
+	IDWatcher int
+	Change    StatusChangeEnum
+	From      PartyMovieStatusResult
+	To        PartyMovieStatusResult
+}
+
+const recordStatusChangeQuery = `
+  INSERT INTO party_movie_status_changes
+    (id_party, id_movie, id_changed_by, change, from_status, to_status, from_watch_date, to_watch_date)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
+`
+
+// RecordStatusChange adds a change to the party movie's status to the audit log
+func (p PartyRepository) RecordStatusChange(ctx context.Context, params StatusChangeParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.RecordStatusChange")
+	defer span.End()
+
+	_, err := p.getQuerier(ctx).Exec(
+		ctx,
+		recordStatusChangeQuery,
+		params.IDParty,
+		params.IDMovie,
+		params.IDWatcher,
+		params.Change,
+		params.From.Status,
+		params.To.Status,
+		params.From.WatchDate,
+		params.To.WatchDate,
+	)
+	return err
+}
+
+type StatusChangeResult struct {
+	IDMovie int
+	Title   string
+	// FirstName and LastName are empty when whoever made the change has deleted their account
+	FirstName     string
+	LastName      string
+	Change        StatusChangeEnum
+	FromStatus    WatchStatusEnum
+	ToStatus      WatchStatusEnum
+	FromWatchDate *time.Time
+	ToWatchDate   *time.Time
+	ChangedAt     time.Time
+}
+
+const getStatusChangesQuery = `
+  SELECT
+    party_movie_status_changes.id_movie,
+    movies.title,
+    coalesce(profiles.first_name, ''),
+    coalesce(profiles.last_name, ''),
+    party_movie_status_changes.change,
+    party_movie_status_changes.from_status,
+    party_movie_status_changes.to_status,
+    party_movie_status_changes.from_watch_date,
+    party_movie_status_changes.to_watch_date,
+    party_movie_status_changes.created_at
+  FROM party_movie_status_changes
+  JOIN movies ON movies.id_movie = party_movie_status_changes.id_movie
+  LEFT JOIN profiles ON profiles.id_profile = party_movie_status_changes.id_changed_by
+  WHERE party_movie_status_changes.id_party = $1
+  ORDER BY party_movie_status_changes.created_at DESC, party_movie_status_changes.id_status_change DESC
+  LIMIT $2;
+`
+
+// GetStatusChanges returns the latest changes made to the party's movies, newest first
+func (p PartyRepository) GetStatusChanges(ctx context.Context, idParty, limit int, assignFn func(StatusChangeResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetStatusChanges")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getStatusChangesQuery, idParty, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res StatusChangeResult
+		err := rows.Scan(
+			&res.IDMovie,
+			&res.Title,
+			&res.FirstName,
+			&res.LastName,
+			&res.Change,
+			&res.FromStatus,
+			&res.ToStatus,
+			&res.FromWatchDate,
+			&res.ToWatchDate,
+			&res.ChangedAt,
+		)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
 }
 
 const createPartyMemberQuery = `insert into party_members (id_member, id_party) values($1, $2)`
@@ -351,11 +814,13 @@ SELECT watch_status, jsonb_agg(
         'id_movie', id_movie,
         'title', title,
         'poster_url', poster_url,
+        'id_added_by', id_added_by,
         'added_by', jsonb_build_object(
             'first_name', first_name,
             'last_name', last_name
         ),
         'watch_date', watch_date,
+        'viewing_dates', viewing_dates,
         'genres', genres,
         'created_at', created_at
     )
@@ -368,10 +833,16 @@ FROM (
           movies.title,
           movies.poster_url,
           movies.genres,
+          party_movies.id_added_by,
           profiles.first_name,
           profiles.last_name,
           party_movies.watch_status,
           party_movies.watch_date,
+          (
+            SELECT jsonb_agg(party_movie_viewings.watch_date ORDER BY party_movie_viewings.watch_date DESC)
+            FROM party_movie_viewings
+            WHERE party_movie_viewings.id_party_movie = party_movies.id
+          ) as viewing_dates,
           party_movies.created_at,
           ROW_NUMBER() OVER (PARTITION BY party_movies.watch_status ORDER BY party_movies.created_at DESC) as rn
         FROM movies
@@ -420,19 +891,246 @@ func (p PartyRepository) GetMoviesForParty(ctx context.Context, logger *slog.Log
 	return nil
 }
 
-const createPartyMovieQuery = `insert into party_movies (id_party, id_movie, id_added_by) values ($1, $2, $3)`
+// adding a movie the party has already watched puts it back on the list to be watched again
+const createPartyMovieQuery = `
+  insert into party_movies (id_party, id_movie, id_added_by) values ($1, $2, $3)
+  on conflict (id_party, id_movie) do update
+    set watch_status = 'unwatched', id_added_by = excluded.id_added_by, updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+    where party_movies.watch_status = 'watched'
+`
 
-// CreatePartMovie creates a movie within a party
+// CreatePartMovie creates a movie within a party, a movie the party has watched is added back to be rewatched.
+// ErrDuplicatePartyMovie is returned when the movie is already on the party's list
 func (p PartyRepository) CreatePartyMovie(ctx context.Context, idParty, idMovie, idAddedBy int) error {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.CreatePartyMovie")
 	defer span.End()
-	_, err := p.db.Exec(ctx, createPartyMovieQuery, idParty, idMovie, idAddedBy)
+	tag, err := p.getQuerier(ctx).Exec(ctx, createPartyMovieQuery, idParty, idMovie, idAddedBy)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrDuplicatePartyMovie
+	}
+
+	return nil
+}
+
+type PartyMovieOwnersResult struct {
+	IDAddedBy    int
+	IDPartyOwner int
+	// Watched is whether the party has watched the movie at least once
+	Watched bool
+}
+
+const getPartyMovieOwnersQuery = `
+  SELECT
+    party_movies.id_added_by,
+    coalesce(parties.id_owner, 0),
+    EXISTS (SELECT 1 FROM party_movie_viewings WHERE id_party_movie = party_movies.id)
+  FROM party_movies
+  JOIN parties ON parties.id_party = party_movies.id_party
+  WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2;
+`
+
+// GetPartyMovieOwners returns who added the movie to the party and who owns the party, ErrNoRecord is returned when
+// the movie isn't in the party
+func (p PartyRepository) GetPartyMovieOwners(ctx context.Context, idParty, idMovie int) (PartyMovieOwnersResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetPartyMovieOwners")
+	defer span.End()
+
+	var res PartyMovieOwnersResult
+	err := p.getQuerier(ctx).QueryRow(ctx, getPartyMovieOwnersQuery, idParty, idMovie).Scan(&res.IDAddedBy, &res.IDPartyOwner, &res.Watched)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PartyMovieOwnersResult{}, ErrNoRecord
+		}
+		return PartyMovieOwnersResult{}, err
+	}
+
+	return res, nil
+}
+
+// a movie that's been watched is part of the party's history so it's never removed
+const deletePartyMovieQuery = `
+  DELETE FROM party_movies
+  WHERE id_party = $1 AND id_movie = $2
+    AND NOT EXISTS (SELECT 1 FROM party_movie_viewings WHERE id_party_movie = party_movies.id);
+`
+
+// DeletePartyMovie removes a movie the party hasn't watched from it, ErrNoRecord is returned when the movie isn't in
+// the party or has been watched
+func (p PartyRepository) DeletePartyMovie(ctx context.Context, idParty, idMovie int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.DeletePartyMovie")
+	defer span.End()
+
+	tag, err := p.getQuerier(ctx).Exec(ctx, deletePartyMovieQuery, idParty, idMovie)
 	if err != nil {
 		return err
 	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
 	return nil
 }
 
+const vetoPartyMovieQuery = `
+  INSERT INTO party_movie_vetoes (id_party, id_movie, id_profile) VALUES ($1, $2, $3)
+  ON CONFLICT (id_party, id_movie, id_profile) DO NOTHING;
+`
+
+// VetoPartyMovie stops the movie being picked for the party until the watcher's veto is lifted, vetoing it again does
+// nothing
+func (p PartyRepository) VetoPartyMovie(ctx context.Context, idParty, idMovie, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.VetoPartyMovie")
+	defer span.End()
+
+	_, err := p.getQuerier(ctx).Exec(ctx, vetoPartyMovieQuery, idParty, idMovie, idWatcher)
+	return err
+}
+
+const liftVetoQuery = `DELETE FROM party_movie_vetoes WHERE id_party = $1 AND id_movie = $2 AND id_profile = $3;`
+
+// LiftVeto removes the veto idVetoedBy cast on the movie, ErrNoRecord is returned when there isn't one
+func (p PartyRepository) LiftVeto(ctx context.Context, idParty, idMovie, idVetoedBy int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.LiftVeto")
+	defer span.End()
+
+	tag, err := p.getQuerier(ctx).Exec(ctx, liftVetoQuery, idParty, idMovie, idVetoedBy)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+type VetoResult struct {
+	IDMovie   int
+	IDWatcher int
+	FirstName string
+	LastName  string
+}
+
+const getVetoesQuery = `
+  SELECT party_movie_vetoes.id_movie, profiles.id_profile, profiles.first_name, profiles.last_name
+  FROM party_movie_vetoes
+  JOIN profiles ON profiles.id_profile = party_movie_vetoes.id_profile
+  WHERE party_movie_vetoes.id_party = $1
+  ORDER BY party_movie_vetoes.id_movie, party_movie_vetoes.created_at, profiles.id_profile;
+`
+
+// GetVetoes returns every veto on the party's movies, oldest first for each movie
+func (p PartyRepository) GetVetoes(ctx context.Context, idParty int, assignFn func(VetoResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetVetoes")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getVetoesQuery, idParty)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res VetoResult
+		err := rows.Scan(&res.IDMovie, &res.IDWatcher, &res.FirstName, &res.LastName)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+// only movies the party hasn't watched can be flagged
+const setMovieFlagQuery = `
+  INSERT INTO party_movie_flags (id_party, id_movie, id_profile, flag)
+  SELECT party_movies.id_party, party_movies.id_movie, $3, $4
+  FROM party_movies
+  WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2 AND party_movies.watch_status != 'watched'
+  ON CONFLICT (id_party, id_movie, id_profile) DO UPDATE
+  SET flag = excluded.flag, updated_at = (clock_timestamp() AT TIME ZONE 'UTC');
+`
+
+// SetMovieFlag sets how the watcher feels about watching the party's movie in place of any flag they'd set before,
+// ErrNoRecord is returned when the movie isn't in the party or the party has watched it
+func (p PartyRepository) SetMovieFlag(ctx context.Context, idParty, idMovie, idWatcher int, flag MovieFlagEnum) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.SetMovieFlag")
+	defer span.End()
+
+	tag, err := p.getQuerier(ctx).Exec(ctx, setMovieFlagQuery, idParty, idMovie, idWatcher, flag)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const clearMovieFlagQuery = `DELETE FROM party_movie_flags WHERE id_party = $1 AND id_movie = $2 AND id_profile = $3;`
+
+// ClearMovieFlag removes the watcher's flag from the party's movie, clearing a flag that isn't set does nothing
+func (p PartyRepository) ClearMovieFlag(ctx context.Context, idParty, idMovie, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.ClearMovieFlag")
+	defer span.End()
+
+	_, err := p.getQuerier(ctx).Exec(ctx, clearMovieFlagQuery, idParty, idMovie, idWatcher)
+	return err
+}
+
+type MovieFlagResult struct {
+	IDMovie   int
+	IDWatcher int
+	FirstName string
+	LastName  string
+	Flag      MovieFlagEnum
+}
+
+const getMovieFlagsQuery = `
+  SELECT party_movie_flags.id_movie, profiles.id_profile, profiles.first_name, profiles.last_name, party_movie_flags.flag
+  FROM party_movie_flags
+  JOIN party_movies ON party_movies.id_party = party_movie_flags.id_party AND party_movies.id_movie = party_movie_flags.id_movie
+  JOIN profiles ON profiles.id_profile = party_movie_flags.id_profile
+  WHERE party_movie_flags.id_party = $1 AND party_movies.watch_status != 'watched'
+    AND ($2 = 0 OR party_movie_flags.id_movie = $2)
+  ORDER BY party_movie_flags.id_movie, profiles.first_name, profiles.last_name;
+`
+
+// GetMovieFlags returns the flags members have set on the movies the party hasn't watched, when idMovie is 0 the flags
+// for every one of them are returned
+func (p PartyRepository) GetMovieFlags(ctx context.Context, idParty, idMovie int, assignFn func(MovieFlagResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetMovieFlags")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getMovieFlagsQuery, idParty, idMovie)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res MovieFlagResult
+		err := rows.Scan(&res.IDMovie, &res.IDWatcher, &res.FirstName, &res.LastName, &res.Flag)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
 const movieInPartyQuery = `SELECT EXISTS(select 1 from party_movies where id_movie = $1 and id_party = $2)`
 
 func (p PartyRepository) MovieAddedToParty(ctx context.Context, idParty, idMovie int) (bool, error) {
@@ -448,22 +1146,91 @@ func (p PartyRepository) MovieAddedToParty(ctx context.Context, idParty, idMovie
 	return exists, nil
 }
 
+// the streaming services members subscribe to that each unwatched movie is on in that member's region
+const getStreamingServicesForPartyQuery = `
+SELECT
+  party_movies.id_movie,
+  movie_watch_providers.id_provider,
+  min(movie_watch_providers.provider_name),
+  min(movie_watch_providers.logo_path),
+  array_agg(DISTINCT profiles.first_name ORDER BY profiles.first_name)
+FROM party_movies
+JOIN party_members ON party_members.id_party = party_movies.id_party
+JOIN profiles ON profiles.id_profile = party_members.id_member
+JOIN profile_subscriptions ON profile_subscriptions.id_profile = profiles.id_profile
+JOIN movie_watch_providers ON movie_watch_providers.id_movie = party_movies.id_movie
+  AND movie_watch_providers.region = profiles.watch_region
+  AND movie_watch_providers.id_provider = profile_subscriptions.id_provider
+WHERE party_movies.id_party = $1
+AND party_movies.watch_status IN ('unwatched', 'selected')
+GROUP BY party_movies.id_movie, movie_watch_providers.id_provider
+ORDER BY party_movies.id_movie, min(movie_watch_providers.display_priority);
+`
+
+type getStreamingServicesAssignFn func(idMovie, idProvider int, name, logoPath string, subscribers []string)
+
+// GetStreamingServicesForParty returns which of the party members' subscriptions each unwatched movie can be streamed on
+func (p PartyRepository) GetStreamingServicesForParty(ctx context.Context, idParty int, assignFn getStreamingServicesAssignFn) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetStreamingServicesForParty")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getStreamingServicesForPartyQuery, idParty)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			idMovie     int
+			idProvider  int
+			name        string
+			logoPath    string
+			subscribers []string
+		)
+		err := rows.Scan(&idMovie, &idProvider, &name, &logoPath, &subscribers)
+		if err != nil {
+			return err
+		}
+		assignFn(idMovie, idProvider, name, logoPath, subscribers)
+	}
+
+	return rows.Err()
+}
+
 const getPartiesByMembersQuery = `
 select 
     pm.id_member,
     pm.created_at,
     p.first_name,
     p.last_name,
-    p.id_profile
+    p.id_profile,
+    coalesce(p.display_name, ''),
+    p.bio,
+    coalesce(p.avatar_key, ''),
+    array(select id_genre from profile_favorite_genres where profile_favorite_genres.id_profile = p.id_profile order by id_genre)
 from party_members pm
 join profiles p on p.id_profile = pm.id_member
 where pm.id_party = $1
 order by pm.created_at asc;
 `
 
-type getMembersAssignFn func(string, string, int, time.Time, int)
+type PartyMemberResult struct {
+	ID        int
+	JoinedOn  time.Time
+	FirstName string
+	LastName  string
+	IDProfile int
+	// DisplayName is empty when the member hasn't set one
+	DisplayName string
+	Bio         string
+	// AvatarKey is empty when the member hasn't uploaded an avatar
+	AvatarKey      string
+	FavoriteGenres []int
+}
 
-func (p PartyRepository) GetPartyMembers(ctx context.Context, idParty int, assignFn getMembersAssignFn) error {
+func (p PartyRepository) GetPartyMembers(ctx context.Context, idParty int, assignFn func(PartyMemberResult)) error {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetPartyMembers")
 	defer span.End()
 
@@ -473,18 +1240,22 @@ func (p PartyRepository) GetPartyMembers(ctx context.Context, idParty int, assig
 	}
 
 	for rows.Next() {
-		var (
-			firstName string
-			lastName  string
-			id        int
-			idProfile int
-			joinDate  time.Time
+		var res PartyMemberResult
+		err = rows.Scan(
+			&res.ID,
+			&res.JoinedOn,
+			&res.FirstName,
+			&res.LastName,
+			&res.IDProfile,
+			&res.DisplayName,
+			&res.Bio,
+			&res.AvatarKey,
+			&res.FavoriteGenres,
 		)
-		err = rows.Scan(&id, &joinDate, &firstName, &lastName, &idProfile)
 		if err != nil {
 			return err
 		}
-		assignFn(firstName, lastName, id, joinDate, idProfile)
+		assignFn(res)
 	}
 	return nil
 }
@@ -502,3 +1273,189 @@ func (p PartyRepository) DeleteInvite(ctx context.Context, idWatcher, idParty in
 	}
 	return nil
 }
+
+type PartyEventDetailsResult struct {
+	FirstName string
+	LastName  string
+	Title     string
+}
+
+const getPartyEventDetailsQuery = `
+  SELECT
+    coalesce((SELECT first_name FROM profiles WHERE id_profile = $1), ''),
+    coalesce((SELECT last_name FROM profiles WHERE id_profile = $1), ''),
+    coalesce((SELECT title FROM movies WHERE id_movie = $2), '');
+`
+
+// GetPartyEventDetails returns the name of the watcher and the title of the movie an event is about, either is empty
+// when it can't be found
+func (p PartyRepository) GetPartyEventDetails(ctx context.Context, idWatcher, idMovie int) (PartyEventDetailsResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetPartyEventDetails")
+	defer span.End()
+
+	var res PartyEventDetailsResult
+	err := p.db.QueryRow(ctx, getPartyEventDetailsQuery, idWatcher, idMovie).Scan(&res.FirstName, &res.LastName, &res.Title)
+	if err != nil {
+		return PartyEventDetailsResult{}, err
+	}
+
+	return res, nil
+}
+
+// ActivityParams is what happened in the party, ids that don't apply to its type are 0
+type ActivityParams struct {
+	IDParty   int
+	Type      ActivityTypeEnum
+	IDActor   int
+	IDMovie   int
+	IDSubject int
+}
+
+const recordActivityQuery = `
+  INSERT INTO party_activities (id_party, type, id_actor, id_movie, id_subject)
+  VALUES ($1, $2, nullif($3::int, 0), nullif($4::int, 0), nullif($5::int, 0));
+`
+
+// RecordActivity adds what happened to the party's activity log
+func (p PartyRepository) RecordActivity(ctx context.Context, params ActivityParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.RecordActivity")
+	defer span.End()
+
+	_, err := p.getQuerier(ctx).Exec(ctx, recordActivityQuery, params.IDParty, params.Type, params.IDActor, params.IDMovie, params.IDSubject)
+	return err
+}
+
+type ActivityResult struct {
+	ID   int
+	Type ActivityTypeEnum
+	// the names are empty when whoever it was has deleted their account
+	ActorFirstName   string
+	ActorLastName    string
+	IDMovie          int
+	Title            string
+	SubjectFirstName string
+	SubjectLastName  string
+	CreatedAt        time.Time
+}
+
+// ids only ever go up so they're what the log is paged by, a $2 of 0 starts from the newest
+const getActivitiesQuery = `
+  SELECT
+    party_activities.id_party_activity,
+    party_activities.type::text,
+    coalesce(actors.first_name, ''),
+    coalesce(actors.last_name, ''),
+    coalesce(party_activities.id_movie, 0),
+    coalesce(movies.title, ''),
+    coalesce(subjects.first_name, ''),
+    coalesce(subjects.last_name, ''),
+    party_activities.created_at
+  FROM party_activities
+  LEFT JOIN profiles actors ON actors.id_profile = party_activities.id_actor
+  LEFT JOIN profiles subjects ON subjects.id_profile = party_activities.id_subject
+  LEFT JOIN movies ON movies.id_movie = party_activities.id_movie
+  WHERE party_activities.id_party = $1
+  AND ($2 = 0 OR party_activities.id_party_activity < $2)
+  ORDER BY party_activities.id_party_activity DESC
+  LIMIT $3;
+`
+
+// GetActivities returns the party's activity from before the activity with the id idBefore, newest first. An idBefore
+// of 0 starts from the newest
+func (p PartyRepository) GetActivities(ctx context.Context, idParty, idBefore, limit int, assignFn func(ActivityResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.GetActivities")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getActivitiesQuery, idParty, idBefore, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res ActivityResult
+		err = rows.Scan(
+			&res.ID,
+			&res.Type,
+			&res.ActorFirstName,
+			&res.ActorLastName,
+			&res.IDMovie,
+			&res.Title,
+			&res.SubjectFirstName,
+			&res.SubjectLastName,
+			&res.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+type PartySearchResult struct {
+	ID             int
+	Name           string
+	ShortID        string
+	IDOwner        int
+	OwnerFirstName string
+	OwnerLastName  string
+	MemberCount    int
+	MovieCount     int
+	IsPublic       bool
+	CreatedAt      time.Time
+}
+
+const searchPartiesQuery = `
+  select
+    parties.id_party,
+    parties.name,
+    parties.short_id,
+    coalesce(parties.id_owner, 0),
+    coalesce(owners.first_name, ''),
+    coalesce(owners.last_name, ''),
+    (select count(*) from party_members where party_members.id_party = parties.id_party),
+    (select count(*) from party_movies where party_movies.id_party = parties.id_party),
+    parties.is_public,
+    parties.created_at
+  from parties
+  left join profiles owners on owners.id_profile = parties.id_owner
+  where parties.name ilike '%' || $1 || '%' or parties.short_id = $1
+  order by parties.name
+  limit $2`
+
+// SearchParties returns the parties whose name has term in it or whose short id is term, for admins looking for a
+// party they aren't in
+func (p PartyRepository) SearchParties(ctx context.Context, term string, limit int, assignFn func(PartySearchResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyRepository.SearchParties")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, searchPartiesQuery, term, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res PartySearchResult
+		err = rows.Scan(
+			&res.ID,
+			&res.Name,
+			&res.ShortID,
+			&res.IDOwner,
+			&res.OwnerFirstName,
+			&res.OwnerLastName,
+			&res.MemberCount,
+			&res.MovieCount,
+			&res.IsPublic,
+			&res.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jm96441n/movieswithfriends/partymgmt/store"
@@ -165,6 +166,562 @@ func TestCreatePartyMember(t *testing.T) {
 	}
 }
 
+func TestMarkPartyMovieAsWatchedRecordsAttendance(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_mark_watched_attendance_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+	watcherRepo := store.NewWatcherRepository(connPool)
+
+	watchDate := time.Date(2024, 5, 4, 20, 0, 0, 0, time.UTC)
+
+	idParty := seedParty(ctx, t, connPool, "attendance-party", "attend")
+	idFounder := seedProfile(ctx, t, connPool)
+	idLateJoiner := seedProfile(ctx, t, connPool)
+	idOutsider := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idFounder)
+	seedPartyMember(ctx, t, connPool, idParty, idLateJoiner)
+
+	_, err := connPool.Exec(ctx, "update party_members set created_at = $1 where id_member = $2", watchDate.AddDate(0, -1, 0), idFounder)
+	testhelpers.Ok(t, err, "failed to backdate party member")
+
+	seedPartyMovie(ctx, t, connPool, idParty, idFounder, "Heat", 170, watchDate.AddDate(0, -1, 0), nil)
+
+	var idMovie int
+	err = connPool.QueryRow(ctx, "select id_movie from movies where title = 'Heat'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+
+	err = repo.MarkPartyMovieAsWatched(ctx, idParty, idMovie, watchDate)
+	testhelpers.Ok(t, err, "failed to mark movie as watched")
+
+	// only the members in the party on the watch date are counted as being there
+	testhelpers.Equals(t, map[int]bool{idFounder: true}, getAttendance(ctx, t, repo, idParty, idMovie))
+
+	err = watcherRepo.SetAttendance(ctx, idParty, idMovie, idLateJoiner, true)
+	testhelpers.Ok(t, err, "failed to mark late joiner as there")
+
+	err = watcherRepo.SetAttendance(ctx, idParty, idMovie, idFounder, false)
+	testhelpers.Ok(t, err, "failed to mark founder as absent")
+
+	testhelpers.Equals(t, map[int]bool{idFounder: false, idLateJoiner: true}, getAttendance(ctx, t, repo, idParty, idMovie))
+
+	err = watcherRepo.SetAttendance(ctx, idParty, idMovie, idOutsider, true)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	// a rewatch is a new viewing with its own attendance
+	err = repo.MarkPartyMovieAsWatched(ctx, idParty, idMovie, watchDate.AddDate(0, 2, 0))
+	testhelpers.Ok(t, err, "failed to mark movie as watched again")
+
+	testhelpers.Equals(t, map[int]bool{idFounder: true}, getAttendance(ctx, t, repo, idParty, idMovie))
+}
+
+func TestRewatchPartyMovie(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_rewatch_party_movie_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+
+	firstWatch := time.Now().UTC().AddDate(-2, 0, 0).Truncate(time.Second)
+
+	idParty := seedParty(ctx, t, connPool, "rewatch-party", "rewatch")
+	idMember := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idMember)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Alien", 117, firstWatch.AddDate(0, -1, 0), &firstWatch)
+
+	var idMovie int
+	err := connPool.QueryRow(ctx, "select id_movie from movies where title = 'Alien'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+
+	_, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	_, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{RewatchAfterMonths: 36})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	selected, err := repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{RewatchAfterMonths: 12})
+	testhelpers.Ok(t, err, "failed to select a movie due a rewatch")
+	testhelpers.Equals(t, store.SelectMovieResult{IDMovie: idMovie, FromStatus: store.WatchStatusWatched}, selected)
+	testhelpers.Equals(t, "selected", getWatchStatus(ctx, t, connPool, idParty, idMovie))
+
+	secondWatch := firstWatch.AddDate(1, 0, 0)
+	err = repo.MarkPartyMovieAsWatched(ctx, idParty, idMovie, secondWatch)
+	testhelpers.Ok(t, err, "failed to mark movie as rewatched")
+
+	// adding it again puts it back on the list without losing the viewings, credited to whoever added it back
+	idRewatcher := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idRewatcher)
+	err = repo.CreatePartyMovie(ctx, idParty, idMovie, idRewatcher)
+	testhelpers.Ok(t, err, "failed to add watched movie again")
+	testhelpers.Equals(t, "unwatched", getWatchStatus(ctx, t, connPool, idParty, idMovie))
+
+	var idAddedBy int
+	err = connPool.QueryRow(ctx, "select id_added_by from party_movies where id_party = $1 and id_movie = $2", idParty, idMovie).Scan(&idAddedBy)
+	testhelpers.Ok(t, err, "failed to get who added the movie")
+	testhelpers.Equals(t, idRewatcher, idAddedBy)
+
+	// adding it while it's still on the list changes nothing
+	err = repo.CreatePartyMovie(ctx, idParty, idMovie, idMember)
+	testhelpers.Assert(t, errors.Is(err, store.ErrDuplicatePartyMovie), "expected %v, got %v", store.ErrDuplicatePartyMovie, err)
+	err = connPool.QueryRow(ctx, "select id_added_by from party_movies where id_party = $1 and id_movie = $2", idParty, idMovie).Scan(&idAddedBy)
+	testhelpers.Ok(t, err, "failed to get who added the movie")
+	testhelpers.Equals(t, idRewatcher, idAddedBy)
+
+	thirdWatch := secondWatch.AddDate(0, 6, 0)
+	err = repo.MarkPartyMovieAsWatched(ctx, idParty, idMovie, thirdWatch)
+	testhelpers.Ok(t, err, "failed to mark movie as watched a third time")
+
+	var watchDate time.Time
+	var viewingDates []time.Time
+	err = connPool.QueryRow(
+		ctx,
+		`select
+		   party_movies.watch_date,
+		   array_agg(party_movie_viewings.watch_date order by party_movie_viewings.watch_date desc)
+		 from party_movies
+		 join party_movie_viewings on party_movie_viewings.id_party_movie = party_movies.id
+		 where party_movies.id_party = $1 and party_movies.id_movie = $2
+		 group by party_movies.watch_date`,
+		idParty,
+		idMovie,
+	).Scan(&watchDate, &viewingDates)
+	testhelpers.Ok(t, err, "failed to get viewings")
+
+	testhelpers.Assert(t, watchDate.Equal(thirdWatch), "expected watch date %v, got %v", thirdWatch, watchDate)
+	testhelpers.Equals(t, 3, len(viewingDates))
+	for idx, want := range []time.Time{thirdWatch, secondWatch, firstWatch} {
+		testhelpers.Assert(t, viewingDates[idx].Equal(want), "expected viewing %d on %v, got %v", idx, want, viewingDates[idx])
+	}
+}
+
+func TestUndoPartyMovieStatusChanges(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_undo_status_changes_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+
+	firstWatch := time.Date(2024, 2, 3, 20, 0, 0, 0, time.UTC)
+	secondWatch := time.Date(2024, 9, 7, 20, 0, 0, 0, time.UTC)
+
+	idParty := seedParty(ctx, t, connPool, "undo-party", "undo")
+	idMember := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idMember)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Jaws", 124, firstWatch.AddDate(0, -1, 0), &firstWatch)
+
+	var idMovie int
+	err := connPool.QueryRow(ctx, "select id_movie from movies where title = 'Jaws'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+
+	err = repo.MarkPartyMovieAsWatched(ctx, idParty, idMovie, secondWatch)
+	testhelpers.Ok(t, err, "failed to mark movie as rewatched")
+
+	// only the latest viewing is changed
+	editedWatch := secondWatch.AddDate(0, 0, -1)
+	err = repo.UpdateWatchDate(ctx, idParty, idMovie, editedWatch)
+	testhelpers.Ok(t, err, "failed to update watch date")
+
+	status, err := repo.GetPartyMovieStatus(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to get status")
+	testhelpers.Equals(t, store.WatchStatusWatched, status.Status)
+	testhelpers.Equals(t, 2, status.Viewings)
+	testhelpers.Assert(t, status.WatchDate.Equal(editedWatch), "expected watch date %v, got %v", editedWatch, status.WatchDate)
+
+	// undoing the rewatch leaves the first viewing
+	err = repo.UnwatchPartyMovie(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to undo the rewatch")
+
+	status, err = repo.GetPartyMovieStatus(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to get status")
+	testhelpers.Equals(t, store.WatchStatusWatched, status.Status)
+	testhelpers.Equals(t, 1, status.Viewings)
+	testhelpers.Assert(t, status.WatchDate.Equal(firstWatch), "expected watch date %v, got %v", firstWatch, status.WatchDate)
+
+	err = repo.UnwatchPartyMovie(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to undo the first watch")
+
+	status, err = repo.GetPartyMovieStatus(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to get status")
+	testhelpers.Equals(t, store.PartyMovieStatusResult{Status: store.WatchStatusUnwatched}, status)
+
+	err = repo.UnwatchPartyMovie(ctx, idParty, idMovie)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	selected, err := repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Ok(t, err, "failed to select a movie")
+	testhelpers.Equals(t, store.SelectMovieResult{IDMovie: idMovie, FromStatus: store.WatchStatusUnwatched}, selected)
+
+	// picking again un-picks the movie that was picked before
+	selected, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Ok(t, err, "failed to select a movie again")
+	testhelpers.Equals(t, store.SelectMovieResult{
+		IDMovie:           idMovie,
+		FromStatus:        store.WatchStatusUnwatched,
+		IDUnselectedMovie: idMovie,
+		UnselectedStatus:  store.WatchStatusUnwatched,
+	}, selected)
+
+	err = repo.UnselectMovieForParty(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to un-select the movie")
+	testhelpers.Equals(t, "unwatched", getWatchStatus(ctx, t, connPool, idParty, idMovie))
+
+	err = repo.UnselectMovieForParty(ctx, idParty, idMovie)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.RecordStatusChange(ctx, store.StatusChangeParams{
+		IDParty:   idParty,
+		IDMovie:   idMovie,
+		IDWatcher: idMember,
+		Change:    store.StatusChangeEditWatchDate,
+		From:      store.PartyMovieStatusResult{Status: store.WatchStatusWatched, WatchDate: &secondWatch},
+		To:        store.PartyMovieStatusResult{Status: store.WatchStatusWatched, WatchDate: &editedWatch},
+	})
+	testhelpers.Ok(t, err, "failed to record status change")
+
+	changes := make([]store.StatusChangeResult, 0, 1)
+	err = repo.GetStatusChanges(ctx, idParty, 10, func(res store.StatusChangeResult) {
+		changes = append(changes, res)
+	})
+	testhelpers.Ok(t, err, "failed to get status changes")
+	testhelpers.Equals(t, 1, len(changes))
+	testhelpers.Equals(t, "Jaws", changes[0].Title)
+	testhelpers.Equals(t, "tom", changes[0].FirstName)
+	testhelpers.Equals(t, store.StatusChangeEditWatchDate, changes[0].Change)
+	testhelpers.Assert(t, changes[0].FromWatchDate.Equal(secondWatch), "expected from date %v, got %v", secondWatch, changes[0].FromWatchDate)
+	testhelpers.Assert(t, changes[0].ToWatchDate.Equal(editedWatch), "expected to date %v, got %v", editedWatch, changes[0].ToWatchDate)
+}
+
+func TestVetoAndRemovePartyMovies(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_veto_remove_movies_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+	movieNightsRepo := store.NewMovieNightsRepository(connPool)
+
+	addedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	watchDate := time.Date(2024, 3, 4, 20, 0, 0, 0, time.UTC)
+
+	idParty := seedParty(ctx, t, connPool, "veto-party", "veto")
+	idMember := seedProfile(ctx, t, connPool)
+	idOtherMember := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idMember)
+	seedPartyMember(ctx, t, connPool, idParty, idOtherMember)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Cats", 110, addedAt, nil)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Rocky", 120, addedAt, &watchDate)
+
+	var idMovie, idWatchedMovie int
+	err := connPool.QueryRow(ctx, "select id_movie from movies where title = 'Cats'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+	err = connPool.QueryRow(ctx, "select id_movie from movies where title = 'Rocky'").Scan(&idWatchedMovie)
+	testhelpers.Ok(t, err, "failed to get watched movie")
+
+	err = repo.VetoPartyMovie(ctx, idParty, idMovie, idMember)
+	testhelpers.Ok(t, err, "failed to veto movie")
+	err = repo.VetoPartyMovie(ctx, idParty, idMovie, idOtherMember)
+	testhelpers.Ok(t, err, "failed to veto movie")
+
+	// vetoing it twice is fine
+	err = repo.VetoPartyMovie(ctx, idParty, idMovie, idOtherMember)
+	testhelpers.Ok(t, err, "failed to veto movie again")
+
+	vetoes := make([]int, 0, 2)
+	err = repo.GetVetoes(ctx, idParty, func(res store.VetoResult) {
+		testhelpers.Equals(t, idMovie, res.IDMovie)
+		vetoes = append(vetoes, res.IDWatcher)
+	})
+	testhelpers.Ok(t, err, "failed to get vetoes")
+	testhelpers.Equals(t, []int{idMember, idOtherMember}, vetoes)
+
+	_, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	schedulable, err := movieNightsRepo.IsSchedulableMovie(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to check if the movie is schedulable")
+	testhelpers.Assert(t, !schedulable, "expected a vetoed movie not to be schedulable")
+
+	// every veto has to be lifted before it can be picked
+	err = repo.LiftVeto(ctx, idParty, idMovie, idMember)
+	testhelpers.Ok(t, err, "failed to lift veto")
+
+	_, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.LiftVeto(ctx, idParty, idMovie, idOtherMember)
+	testhelpers.Ok(t, err, "failed to lift veto")
+
+	err = repo.LiftVeto(ctx, idParty, idMovie, idOtherMember)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	selected, err := repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Ok(t, err, "failed to select movie")
+	testhelpers.Equals(t, idMovie, selected.IDMovie)
+
+	owners, err := repo.GetPartyMovieOwners(ctx, idParty, idWatchedMovie)
+	testhelpers.Ok(t, err, "failed to get party movie owners")
+	testhelpers.Equals(t, store.PartyMovieOwnersResult{IDAddedBy: idMember, Watched: true}, owners)
+
+	// the watch history is never removed
+	err = repo.DeletePartyMovie(ctx, idParty, idWatchedMovie)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.DeletePartyMovie(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to delete party movie")
+
+	_, err = repo.GetPartyMovieOwners(ctx, idParty, idMovie)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+}
+
+func TestMovieFlags(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_movie_flags_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+
+	addedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	watchDate := time.Date(2024, 3, 4, 20, 0, 0, 0, time.UTC)
+
+	idParty := seedParty(ctx, t, connPool, "flags-party", "flags")
+	idMember := seedProfile(ctx, t, connPool)
+	idOtherMember := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idMember)
+	seedPartyMember(ctx, t, connPool, idParty, idOtherMember)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Cats", 110, addedAt, nil)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Rocky", 120, addedAt, &watchDate)
+
+	var idMovie, idWatchedMovie int
+	err := connPool.QueryRow(ctx, "select id_movie from movies where title = 'Cats'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+	err = connPool.QueryRow(ctx, "select id_movie from movies where title = 'Rocky'").Scan(&idWatchedMovie)
+	testhelpers.Ok(t, err, "failed to get watched movie")
+
+	err = repo.SetMovieFlag(ctx, idParty, idMovie, idMember, store.MovieFlagSeenIt)
+	testhelpers.Ok(t, err, "failed to flag movie")
+
+	// setting another flag replaces the first
+	err = repo.SetMovieFlag(ctx, idParty, idMovie, idMember, store.MovieFlagNotInterested)
+	testhelpers.Ok(t, err, "failed to flag movie again")
+
+	err = repo.SetMovieFlag(ctx, idParty, idMovie, idOtherMember, store.MovieFlagMustWatch)
+	testhelpers.Ok(t, err, "failed to flag movie")
+
+	err = repo.SetMovieFlag(ctx, idParty, idWatchedMovie, idMember, store.MovieFlagSeenIt)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	flags := make(map[int]store.MovieFlagEnum)
+	err = repo.GetMovieFlags(ctx, idParty, 0, func(res store.MovieFlagResult) {
+		testhelpers.Equals(t, idMovie, res.IDMovie)
+		flags[res.IDWatcher] = res.Flag
+	})
+	testhelpers.Ok(t, err, "failed to get movie flags")
+	testhelpers.Equals(t, map[int]store.MovieFlagEnum{idMember: store.MovieFlagNotInterested, idOtherMember: store.MovieFlagMustWatch}, flags)
+
+	// a movie someone watching isn't interested in is never picked
+	_, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	_, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{AttendeeIDs: []int{idMember}})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	selected, err := repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{AttendeeIDs: []int{idOtherMember}})
+	testhelpers.Ok(t, err, "failed to select movie")
+	testhelpers.Equals(t, idMovie, selected.IDMovie)
+
+	err = repo.UnselectMovieForParty(ctx, idParty, idMovie)
+	testhelpers.Ok(t, err, "failed to unselect movie")
+
+	err = repo.ClearMovieFlag(ctx, idParty, idMovie, idMember)
+	testhelpers.Ok(t, err, "failed to clear movie flag")
+
+	// clearing it again does nothing
+	err = repo.ClearMovieFlag(ctx, idParty, idMovie, idMember)
+	testhelpers.Ok(t, err, "failed to clear movie flag again")
+
+	selected, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+	testhelpers.Ok(t, err, "failed to select movie")
+	testhelpers.Equals(t, idMovie, selected.IDMovie)
+}
+
+func TestSelectMovieWeightsFlagsAcrossMembers(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_select_movie_weights_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+
+	addedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	idParty := seedParty(ctx, t, connPool, "weights-party", "weights")
+	idMember := seedProfile(ctx, t, connPool)
+	idOtherMember := seedProfile(ctx, t, connPool)
+	idThirdMember := seedProfile(ctx, t, connPool)
+	for _, id := range []int{idMember, idOtherMember, idThirdMember} {
+		seedPartyMember(ctx, t, connPool, idParty, id)
+	}
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Heat", 170, addedAt, nil)
+	seedPartyMovie(ctx, t, connPool, idParty, idOtherMember, "Ronin", 122, addedAt, nil)
+
+	var idFlaggedMovie int
+	err := connPool.QueryRow(ctx, "select id_movie from movies where title = 'Heat'").Scan(&idFlaggedMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+
+	// with everyone having to watch it the flagged movie weighs 4 to the unflagged movie's 1, picking a member first
+	// without weighting them would make it a coin flip
+	for _, id := range []int{idMember, idOtherMember, idThirdMember} {
+		err = repo.SetMovieFlag(ctx, idParty, idFlaggedMovie, id, store.MovieFlagMustWatch)
+		testhelpers.Ok(t, err, "failed to flag movie")
+	}
+
+	const draws = 200
+	flaggedPicks := 0
+	for range draws {
+		selected, err := repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{})
+		testhelpers.Ok(t, err, "failed to select movie")
+		if selected.IDMovie == idFlaggedMovie {
+			flaggedPicks++
+		}
+	}
+
+	// 160 picks are expected, 130 is more than four standard deviations from both 160 and an unweighted 100
+	testhelpers.Assert(t, flaggedPicks > 130, "expected the flagged movie to be picked most of the time, it was picked %d of %d times", flaggedPicks, draws)
+}
+
+func TestSelectStreamableMovieForAttendees(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_select_streamable_movie_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+
+	addedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+
+	idParty := seedParty(ctx, t, connPool, "streamable-party", "streamable")
+	idSubscriber := seedProfile(ctx, t, connPool)
+	idMember := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idSubscriber)
+	seedPartyMember(ctx, t, connPool, idParty, idMember)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Jaws", 124, addedAt, nil)
+
+	var idMovie int
+	err := connPool.QueryRow(ctx, "select id_movie from movies where title = 'Jaws'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+
+	_, err = connPool.Exec(ctx, "insert into profile_subscriptions (id_profile, id_provider) values ($1, 8)", idSubscriber)
+	testhelpers.Ok(t, err, "failed to subscribe")
+	_, err = connPool.Exec(
+		ctx,
+		"insert into movie_watch_providers (id_movie, region, id_provider, monetization_type, provider_name) values ($1, 'US', 8, 'flatrate', 'Netflix')",
+		idMovie,
+	)
+	testhelpers.Ok(t, err, "failed to add watch provider")
+
+	// the only member who can stream it isn't watching
+	_, err = repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{OnlyStreamable: true, AttendeeIDs: []int{idMember}})
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	selected, err := repo.SelectMovieForParty(ctx, idParty, store.SelectMovieParams{OnlyStreamable: true, AttendeeIDs: []int{idMember, idSubscriber}})
+	testhelpers.Ok(t, err, "failed to select movie")
+	testhelpers.Equals(t, idMovie, selected.IDMovie)
+}
+
+func TestPartyActivities(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_party_activities_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyRepository(connPool)
+
+	idCreator := seedProfile(ctx, t, connPool)
+	idInvitee := seedProfile(ctx, t, connPool)
+
+	var idParty int
+	err := repo.RunInTransaction(ctx, func(ctx context.Context, repo store.PartyRepository) error {
+		var err error
+		idParty, err = repo.CreateParty(ctx, idCreator, "activity-party", "activ")
+		if err != nil {
+			return err
+		}
+
+		return repo.RecordActivity(ctx, store.ActivityParams{IDParty: idParty, Type: store.ActivityTypePartyCreated, IDActor: idCreator})
+	})
+	testhelpers.Ok(t, err, "failed to create party")
+
+	addedAt := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idCreator, "Alien", 117, addedAt, nil)
+
+	var idMovie int
+	err = connPool.QueryRow(ctx, "select id_movie from movies where title = 'Alien'").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to get movie")
+
+	err = repo.RecordActivity(ctx, store.ActivityParams{IDParty: idParty, Type: store.ActivityTypeMovieAdded, IDActor: idCreator, IDMovie: idMovie})
+	testhelpers.Ok(t, err, "failed to record movie added")
+
+	err = repo.RecordActivity(ctx, store.ActivityParams{IDParty: idParty, Type: store.ActivityTypeInviteSent, IDActor: idCreator, IDSubject: idInvitee})
+	testhelpers.Ok(t, err, "failed to record invite sent")
+
+	// nothing is recorded when the transaction it's part of is rolled back
+	rollbackErr := errors.New("rolled back")
+	err = repo.RunInTransaction(ctx, func(ctx context.Context, repo store.PartyRepository) error {
+		err := repo.RecordActivity(ctx, store.ActivityParams{IDParty: idParty, Type: store.ActivityTypeMemberJoined, IDActor: idInvitee})
+		if err != nil {
+			return err
+		}
+		return rollbackErr
+	})
+	testhelpers.Assert(t, errors.Is(err, rollbackErr), "expected %v, got %v", rollbackErr, err)
+
+	activities := make([]store.ActivityResult, 0)
+	err = repo.GetActivities(ctx, idParty, 0, 2, func(res store.ActivityResult) {
+		activities = append(activities, res)
+	})
+	testhelpers.Ok(t, err, "failed to get activities")
+	testhelpers.Equals(t, 2, len(activities))
+	testhelpers.Equals(t, store.ActivityTypeInviteSent, activities[0].Type)
+	testhelpers.Equals(t, "tom", activities[0].ActorFirstName)
+	testhelpers.Equals(t, "tom", activities[0].SubjectFirstName)
+	testhelpers.Equals(t, 0, activities[0].IDMovie)
+	testhelpers.Equals(t, store.ActivityTypeMovieAdded, activities[1].Type)
+	testhelpers.Equals(t, idMovie, activities[1].IDMovie)
+	testhelpers.Equals(t, "Alien", activities[1].Title)
+	testhelpers.Equals(t, "", activities[1].SubjectFirstName)
+
+	before := make([]store.ActivityResult, 0)
+	err = repo.GetActivities(ctx, idParty, activities[1].ID, 2, func(res store.ActivityResult) {
+		before = append(before, res)
+	})
+	testhelpers.Ok(t, err, "failed to get earlier activities")
+	testhelpers.Equals(t, 1, len(before))
+	testhelpers.Equals(t, store.ActivityTypePartyCreated, before[0].Type)
+}
+
+func getWatchStatus(ctx context.Context, t *testing.T, conn *pgxpool.Pool, idParty, idMovie int) string {
+	t.Helper()
+	var status string
+	err := conn.QueryRow(ctx, "select watch_status from party_movies where id_party = $1 and id_movie = $2", idParty, idMovie).Scan(&status)
+	testhelpers.Ok(t, err, "failed to get watch status")
+	return status
+}
+
+func getAttendance(ctx context.Context, t *testing.T, repo store.PartyRepository, idParty, idMovie int) map[int]bool {
+	t.Helper()
+	attendance := make(map[int]bool)
+	err := repo.GetAttendance(ctx, idParty, idMovie, func(res store.AttendanceResult) {
+		attendance[res.IDWatcher] = res.Attended
+	})
+	testhelpers.Ok(t, err, "failed to get attendance")
+	return attendance
+}
+
 func seedParty(ctx context.Context, t *testing.T, conn *pgxpool.Pool, name, shortID string) int {
 	t.Helper()
 	var idParty int
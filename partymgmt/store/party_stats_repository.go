@@ -0,0 +1,266 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type PartyStatsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPartyStatsRepository(db *pgxpool.Pool) *PartyStatsRepository {
+	return &PartyStatsRepository{db: db}
+}
+
+func (p *PartyStatsRepository) IsPartyMember(ctx context.Context, idParty, idWatcher int) (bool, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyStatsRepository.IsPartyMember")
+	defer span.End()
+
+	var isMember bool
+	err := p.db.QueryRow(ctx, isPartyMemberQuery, idParty, idWatcher).Scan(&isMember)
+	if err != nil {
+		return false, err
+	}
+
+	return isMember, nil
+}
+
+type PartyTotalsResult struct {
+	Name string
+	// WatchedCount is how many different movies have been watched, a rewatch isn't counted again
+	WatchedCount   int
+	UnwatchedCount int
+	// MinutesWatched is the sum of the runtimes of every viewing, rewatches included
+	MinutesWatched int
+	// SecondsToWatch is the average time between a movie being added and first watched, nil when nothing's been watched
+	SecondsToWatch *float64
+}
+
+const getPartyTotalsQuery = `
+  WITH first_viewings AS (
+    SELECT party_movies.id, party_movies.created_at, min(party_movie_viewings.watch_date) AS watch_date
+    FROM party_movies
+    JOIN party_movie_viewings ON party_movie_viewings.id_party_movie = party_movies.id
+    WHERE party_movies.id_party = $1
+    GROUP BY party_movies.id
+  )
+  SELECT
+    parties.name,
+    (SELECT count(*) FROM first_viewings),
+    (SELECT count(*) FROM party_movies WHERE party_movies.id_party = parties.id_party AND party_movies.watch_status != 'watched'),
+    (
+      SELECT coalesce(sum(movies.runtime), 0)
+      FROM party_movie_viewings
+      JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+      JOIN movies ON movies.id_movie = party_movies.id_movie
+      WHERE party_movies.id_party = parties.id_party
+    ),
+    (SELECT extract(epoch FROM avg(first_viewings.watch_date - first_viewings.created_at))::float8 FROM first_viewings)
+  FROM parties
+  WHERE parties.id_party = $1;
+`
+
+func (p *PartyStatsRepository) GetPartyTotals(ctx context.Context, idParty int) (PartyTotalsResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyStatsRepository.GetPartyTotals")
+	defer span.End()
+
+	var res PartyTotalsResult
+	err := p.db.QueryRow(ctx, getPartyTotalsQuery, idParty).Scan(
+		&res.Name,
+		&res.WatchedCount,
+		&res.UnwatchedCount,
+		&res.MinutesWatched,
+		&res.SecondsToWatch,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return PartyTotalsResult{}, ErrNoRecord
+		}
+		return PartyTotalsResult{}, err
+	}
+
+	return res, nil
+}
+
+const getWatchedGenreCountsQuery = `
+  SELECT coalesce(localized.name, fallback.name), count(*)
+  FROM party_movies
+  JOIN movie_genres ON movie_genres.id_movie = party_movies.id_movie
+  JOIN genre_names fallback ON fallback.id_genre = movie_genres.id_genre AND fallback.language = $3
+  LEFT JOIN genre_names localized ON localized.id_genre = movie_genres.id_genre AND localized.language = $2
+  WHERE party_movies.id_party = $1
+    AND EXISTS (SELECT 1 FROM party_movie_viewings WHERE party_movie_viewings.id_party_movie = party_movies.id)
+  GROUP BY movie_genres.id_genre, localized.name, fallback.name
+  ORDER BY count(*) DESC, 1;
+`
+
+// GetWatchedGenreCounts calls assignFn with each genre and how many of the party's watched movies are in it, the most
+// watched genres come first
+func (p *PartyStatsRepository) GetWatchedGenreCounts(ctx context.Context, idParty int, language, fallbackLanguage string, assignFn func(name string, count int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyStatsRepository.GetWatchedGenreCounts")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getWatchedGenreCountsQuery, idParty, language, fallbackLanguage)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name  string
+			count int
+		)
+		err := rows.Scan(&name, &count)
+		if err != nil {
+			return err
+		}
+		assignFn(name, count)
+	}
+
+	return rows.Err()
+}
+
+const getWatchesPerMonthQuery = `
+  SELECT date_trunc('month', party_movie_viewings.watch_date), count(*)
+  FROM party_movie_viewings
+  JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+  WHERE party_movies.id_party = $1
+    AND party_movie_viewings.watch_date >= $2
+  GROUP BY 1
+  ORDER BY 1;
+`
+
+// GetWatchesPerMonth calls assignFn with the start of each month since the given time that the party watched
+// something in and how many viewings there were, months without any watches are skipped
+func (p *PartyStatsRepository) GetWatchesPerMonth(ctx context.Context, idParty int, since time.Time, assignFn func(month time.Time, count int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyStatsRepository.GetWatchesPerMonth")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getWatchesPerMonthQuery, idParty, since)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			month time.Time
+			count int
+		)
+		err := rows.Scan(&month, &count)
+		if err != nil {
+			return err
+		}
+		assignFn(month, count)
+	}
+
+	return rows.Err()
+}
+
+type MemberPicksResult struct {
+	IDMember     int
+	FirstName    string
+	LastName     string
+	AddedCount   int
+	WatchedCount int
+}
+
+const getMemberPicksQuery = `
+  SELECT
+    profiles.id_profile,
+    profiles.first_name,
+    profiles.last_name,
+    count(party_movies.id),
+    count(party_movies.id) FILTER (
+      WHERE EXISTS (SELECT 1 FROM party_movie_viewings WHERE party_movie_viewings.id_party_movie = party_movies.id)
+    )
+  FROM party_members
+  JOIN profiles ON profiles.id_profile = party_members.id_member
+  LEFT JOIN party_movies ON party_movies.id_party = party_members.id_party
+    AND party_movies.id_added_by = party_members.id_member
+  WHERE party_members.id_party = $1
+  GROUP BY profiles.id_profile
+  ORDER BY 5 DESC, 4 DESC, profiles.first_name, profiles.last_name;
+`
+
+// GetMemberPicks calls assignFn with how many movies each member has added to the party and how many of those have
+// been watched, the members with the most watched picks come first
+func (p *PartyStatsRepository) GetMemberPicks(ctx context.Context, idParty int, assignFn func(MemberPicksResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyStatsRepository.GetMemberPicks")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getMemberPicksQuery, idParty)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res MemberPicksResult
+		err := rows.Scan(&res.IDMember, &res.FirstName, &res.LastName, &res.AddedCount, &res.WatchedCount)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+type WaitingMovieResult struct {
+	IDMovie int
+	Title   string
+	// AddedByFirstName and AddedByLastName are empty when the person who added the movie no longer has a profile
+	AddedByFirstName string
+	AddedByLastName  string
+	AddedAt          time.Time
+}
+
+const getLongestWaitingMoviesQuery = `
+  SELECT
+    movies.id_movie,
+    movies.title,
+    coalesce(profiles.first_name, ''),
+    coalesce(profiles.last_name, ''),
+    party_movies.created_at
+  FROM party_movies
+  JOIN movies ON movies.id_movie = party_movies.id_movie
+  LEFT JOIN profiles ON profiles.id_profile = party_movies.id_added_by
+  WHERE party_movies.id_party = $1 AND party_movies.watch_status != 'watched'
+  ORDER BY party_movies.created_at, party_movies.id
+  LIMIT $2;
+`
+
+// GetLongestWaitingMovies calls assignFn with the party's unwatched movies that were added the longest time ago
+func (p *PartyStatsRepository) GetLongestWaitingMovies(ctx context.Context, idParty, limit int, assignFn func(WaitingMovieResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyStatsRepository.GetLongestWaitingMovies")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getLongestWaitingMoviesQuery, idParty, limit)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var res WaitingMovieResult
+		err := rows.Scan(&res.IDMovie, &res.Title, &res.AddedByFirstName, &res.AddedByLastName, &res.AddedAt)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
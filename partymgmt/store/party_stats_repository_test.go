@@ -0,0 +1,145 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestGetPartyTotals(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_get_party_totals_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyStatsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "stats-party", "statsa")
+	idEmptyParty := seedParty(ctx, t, connPool, "empty-party", "statsb")
+	idMember := seedProfile(ctx, t, connPool)
+
+	addedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Alien", 117, addedAt, ptr(addedAt.Add(48*time.Hour)))
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Aliens", 137, addedAt, ptr(addedAt.Add(96*time.Hour)))
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Alien 3", 114, addedAt, nil)
+
+	testCases := map[string]struct {
+		idParty                int
+		expectedErr            error
+		expectedWatched        int
+		expectedUnwatched      int
+		expectedMinutesWatched int
+		expectedSecondsToWatch *float64
+	}{
+		"partyWithMovies": {
+			idParty:                idParty,
+			expectedWatched:        2,
+			expectedUnwatched:      1,
+			expectedMinutesWatched: 254,
+			expectedSecondsToWatch: ptr(float64(72 * 60 * 60)),
+		},
+		"partyWithoutMovies": {
+			idParty: idEmptyParty,
+		},
+		"partyDoesNotExist": {
+			idParty:     0,
+			expectedErr: store.ErrNoRecord,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			res, err := repo.GetPartyTotals(ctx, tc.idParty)
+			testhelpers.Assert(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+			testhelpers.Equals(t, tc.expectedWatched, res.WatchedCount)
+			testhelpers.Equals(t, tc.expectedUnwatched, res.UnwatchedCount)
+			testhelpers.Equals(t, tc.expectedMinutesWatched, res.MinutesWatched)
+			testhelpers.Equals(t, tc.expectedSecondsToWatch, res.SecondsToWatch)
+		})
+	}
+}
+
+func TestGetMemberPicks(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_get_member_picks_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPartyStatsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "picks-party", "picksa")
+	idPicker := seedProfile(ctx, t, connPool)
+	idLurker := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idPicker)
+	seedPartyMember(ctx, t, connPool, idParty, idLurker)
+
+	addedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idPicker, "Heat", 170, addedAt, ptr(addedAt.Add(time.Hour)))
+	seedPartyMovie(ctx, t, connPool, idParty, idPicker, "Collateral", 120, addedAt, nil)
+
+	picks := make([]store.MemberPicksResult, 0)
+	err := repo.GetMemberPicks(ctx, idParty, func(res store.MemberPicksResult) {
+		picks = append(picks, res)
+	})
+	testhelpers.Ok(t, err, "failed to get member picks")
+
+	testhelpers.Equals(t, 2, len(picks))
+	testhelpers.Equals(t, idPicker, picks[0].IDMember)
+	testhelpers.Equals(t, 2, picks[0].AddedCount)
+	testhelpers.Equals(t, 1, picks[0].WatchedCount)
+	testhelpers.Equals(t, idLurker, picks[1].IDMember)
+	testhelpers.Equals(t, 0, picks[1].AddedCount)
+	testhelpers.Equals(t, 0, picks[1].WatchedCount)
+}
+
+func seedPartyMember(ctx context.Context, t *testing.T, conn *pgxpool.Pool, idParty, idMember int) {
+	t.Helper()
+	_, err := conn.Exec(ctx, "insert into party_members (id_party, id_member) values($1, $2)", idParty, idMember)
+	testhelpers.Ok(t, err, "failed to insert party member")
+}
+
+// seedPartyMovie adds a movie to the party, it's marked as watched with a single viewing when watchDate is set
+func seedPartyMovie(ctx context.Context, t *testing.T, conn *pgxpool.Pool, idParty, idAddedBy int, title string, runtime int, addedAt time.Time, watchDate *time.Time) {
+	t.Helper()
+	var idMovie int
+	err := conn.QueryRow(
+		ctx,
+		"insert into movies (title, poster_url, tmdb_id, overview, tagline, runtime) values($1, '', 1, '', '', $2) returning id_movie",
+		title,
+		runtime,
+	).Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to insert movie")
+
+	status := "unwatched"
+	if watchDate != nil {
+		status = "watched"
+	}
+
+	var idPartyMovie int
+	err = conn.QueryRow(
+		ctx,
+		"insert into party_movies (id_party, id_movie, id_added_by, created_at, watch_date, watch_status) values($1, $2, $3, $4, $5, $6) returning id",
+		idParty,
+		idMovie,
+		idAddedBy,
+		addedAt,
+		watchDate,
+		status,
+	).Scan(&idPartyMovie)
+	testhelpers.Ok(t, err, "failed to insert party movie")
+
+	if watchDate != nil {
+		_, err = conn.Exec(ctx, "insert into party_movie_viewings (id_party_movie, watch_date) values($1, $2)", idPartyMovie, *watchDate)
+		testhelpers.Ok(t, err, "failed to insert party movie viewing")
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,185 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+// PublicPartiesRepository reads what anyone can see of a public party, every query only returns rows for parties that
+// are public and only the columns that are safe to show to someone who isn't in the party
+type PublicPartiesRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewPublicPartiesRepository(db *pgxpool.Pool) *PublicPartiesRepository {
+	return &PublicPartiesRepository{db: db}
+}
+
+type PartyVisibilityResult struct {
+	IsPublic bool
+	ShortID  string
+	IDOwner  int
+}
+
+const getPartyVisibilityQuery = `
+  SELECT is_public, short_id, coalesce(id_owner, 0)
+  FROM parties
+  WHERE id_party = $1;
+`
+
+// GetPartyVisibility returns whether the party is public, ErrNoRecord is returned when the party doesn't exist
+func (p *PublicPartiesRepository) GetPartyVisibility(ctx context.Context, idParty int) (PartyVisibilityResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PublicPartiesRepository.GetPartyVisibility")
+	defer span.End()
+
+	var res PartyVisibilityResult
+	err := p.db.QueryRow(ctx, getPartyVisibilityQuery, idParty).Scan(&res.IsPublic, &res.ShortID, &res.IDOwner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PartyVisibilityResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return PartyVisibilityResult{}, err
+	}
+
+	return res, nil
+}
+
+const setPartyPublicQuery = `
+  UPDATE parties
+  SET is_public = $2, updated_at = (clock_timestamp() AT TIME ZONE 'UTC')
+  WHERE id_party = $1;
+`
+
+func (p *PublicPartiesRepository) SetPartyPublic(ctx context.Context, idParty int, isPublic bool) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PublicPartiesRepository.SetPartyPublic")
+	defer span.End()
+
+	tag, err := p.db.Exec(ctx, setPartyPublicQuery, idParty, isPublic)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+type PublicPartyResult struct {
+	ID          int
+	Name        string
+	ShortID     string
+	MemberCount int
+}
+
+const getPublicPartyQuery = `
+  SELECT
+    parties.id_party,
+    parties.name,
+    parties.short_id,
+    (SELECT count(*) FROM party_members WHERE party_members.id_party = parties.id_party)
+  FROM parties
+  WHERE parties.short_id = $1 AND parties.is_public;
+`
+
+// GetPublicParty returns the public party with the short id, ErrNoRecord is returned when there isn't one or it's
+// private
+func (p *PublicPartiesRepository) GetPublicParty(ctx context.Context, shortID string) (PublicPartyResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PublicPartiesRepository.GetPublicParty")
+	defer span.End()
+
+	var res PublicPartyResult
+	err := p.db.QueryRow(ctx, getPublicPartyQuery, shortID).Scan(&res.ID, &res.Name, &res.ShortID, &res.MemberCount)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PublicPartyResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return PublicPartyResult{}, err
+	}
+
+	return res, nil
+}
+
+// PublicMovieResult is one of a public party's movies, who added it and who rated it are left out so only the
+// ratings' average and count are read
+type PublicMovieResult struct {
+	Title       string
+	PosterURL   string
+	ReleaseDate *time.Time
+	Runtime     int
+	Status      WatchStatusEnum
+	AddedAt     time.Time
+	WatchDate   *time.Time
+	Viewings    int
+	// AverageRating is 0 when nobody has rated the movie
+	AverageRating float64
+	Ratings       int
+}
+
+const getPublicPartyMoviesQuery = `
+  SELECT
+    movies.title,
+    movies.poster_url,
+    movies.release_date,
+    coalesce(movies.runtime, 0),
+    party_movies.watch_status::text,
+    party_movies.created_at,
+    party_movies.watch_date,
+    (SELECT count(*) FROM party_movie_viewings WHERE party_movie_viewings.id_party_movie = party_movies.id),
+    coalesce(ratings.average, 0),
+    ratings.count
+  FROM party_movies
+  JOIN parties ON parties.id_party = party_movies.id_party
+  JOIN movies ON movies.id_movie = party_movies.id_movie
+  CROSS JOIN LATERAL (
+    SELECT avg(party_movie_ratings.rating)::float8 AS average, count(*) AS count
+    FROM party_movie_ratings
+    WHERE party_movie_ratings.id_party = party_movies.id_party AND party_movie_ratings.id_movie = party_movies.id_movie
+  ) ratings
+  WHERE party_movies.id_party = $1 AND parties.is_public
+  ORDER BY party_movies.watch_date DESC NULLS LAST, party_movies.created_at DESC;
+`
+
+// GetPublicPartyMovies returns the party's movies, the most recently watched first followed by the rest newest first.
+// nothing is returned when the party is private
+func (p *PublicPartiesRepository) GetPublicPartyMovies(ctx context.Context, idParty int, assignFn func(PublicMovieResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PublicPartiesRepository.GetPublicPartyMovies")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getPublicPartyMoviesQuery, idParty)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res PublicMovieResult
+		err = rows.Scan(
+			&res.Title,
+			&res.PosterURL,
+			&res.ReleaseDate,
+			&res.Runtime,
+			&res.Status,
+			&res.AddedAt,
+			&res.WatchDate,
+			&res.Viewings,
+			&res.AverageRating,
+			&res.Ratings,
+		)
+		if err != nil {
+			return err
+		}
+
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
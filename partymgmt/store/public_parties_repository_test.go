@@ -0,0 +1,85 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestPublicParties(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_public_parties_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewPublicPartiesRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "open-party", "opEnPa")
+	idOwner := seedProfile(ctx, t, connPool)
+	idMember := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idOwner)
+	seedPartyMember(ctx, t, connPool, idParty, idMember)
+
+	_, err := connPool.Exec(ctx, "update parties set id_owner = $2 where id_party = $1", idParty, idOwner)
+	testhelpers.Ok(t, err, "failed to set party owner")
+
+	addedAt := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	watchedAt := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idOwner, "Heat", 170, addedAt, nil)
+	seedPartyMovie(ctx, t, connPool, idParty, idMember, "Jaws", 124, addedAt, &watchedAt)
+
+	_, err = connPool.Exec(ctx, `
+		insert into party_movie_ratings (id_party, id_movie, id_profile, rating)
+		select $1, id_movie, profile.id, profile.rating
+		from movies, (values ($2::int, 5), ($3::int, 4)) as profile(id, rating)
+		where title = 'Jaws'`, idParty, idOwner, idMember)
+	testhelpers.Ok(t, err, "failed to rate movie")
+
+	visibility, err := repo.GetPartyVisibility(ctx, idParty)
+	testhelpers.Ok(t, err, "failed to get party visibility")
+	testhelpers.Equals(t, store.PartyVisibilityResult{IsPublic: false, ShortID: "opEnPa", IDOwner: idOwner}, visibility)
+
+	// private parties can't be read through the public queries
+	_, err = repo.GetPublicParty(ctx, "opEnPa")
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	movies := make([]store.PublicMovieResult, 0)
+	err = repo.GetPublicPartyMovies(ctx, idParty, func(res store.PublicMovieResult) {
+		movies = append(movies, res)
+	})
+	testhelpers.Ok(t, err, "failed to get public party movies")
+	testhelpers.Equals(t, 0, len(movies))
+
+	err = repo.SetPartyPublic(ctx, idParty, true)
+	testhelpers.Ok(t, err, "failed to make party public")
+
+	err = repo.SetPartyPublic(ctx, idParty+1000, true)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	party, err := repo.GetPublicParty(ctx, "opEnPa")
+	testhelpers.Ok(t, err, "failed to get public party")
+	testhelpers.Equals(t, store.PublicPartyResult{ID: idParty, Name: "open-party", ShortID: "opEnPa", MemberCount: 2}, party)
+
+	// short ids are case sensitive so a differently cased slug is a different party
+	_, err = repo.GetPublicParty(ctx, "openpa")
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.GetPublicPartyMovies(ctx, idParty, func(res store.PublicMovieResult) {
+		movies = append(movies, res)
+	})
+	testhelpers.Ok(t, err, "failed to get public party movies")
+	testhelpers.Equals(t, 2, len(movies))
+	testhelpers.Equals(t, "Jaws", movies[0].Title)
+	testhelpers.Equals(t, store.WatchStatusWatched, movies[0].Status)
+	testhelpers.Equals(t, 4.5, movies[0].AverageRating)
+	testhelpers.Equals(t, 2, movies[0].Ratings)
+	testhelpers.Equals(t, 1, movies[0].Viewings)
+	testhelpers.Equals(t, "Heat", movies[1].Title)
+	testhelpers.Equals(t, 0.0, movies[1].AverageRating)
+	testhelpers.Equals(t, 0, movies[1].Ratings)
+}
@@ -0,0 +1,374 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type RecapsRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRecapsRepository(db *pgxpool.Pool) *RecapsRepository {
+	return &RecapsRepository{db: db}
+}
+
+// RecapScope is who a recap is for, exactly one of IDWatcher and IDParty is set
+type RecapScope struct {
+	IDWatcher int
+	IDParty   int
+}
+
+// every recap query starts from the viewings in the scope during the year, the id of the watcher or party is $1 and
+// the year runs from $2 up to $3
+const (
+	recapWatchedByWatcher = `
+  WITH watched AS (
+    SELECT party_movie_viewings.id_viewing, party_movies.id_party, party_movies.id_movie, party_movies.id_added_by, party_movie_viewings.watch_date
+    FROM party_movie_viewings
+    JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+    JOIN party_movie_attendance ON party_movie_attendance.id_viewing = party_movie_viewings.id_viewing
+      AND party_movie_attendance.id_profile = $1 AND party_movie_attendance.attended
+    WHERE party_movie_viewings.watch_date >= $2 AND party_movie_viewings.watch_date < $3
+  )`
+
+	recapWatchedByParty = `
+  WITH watched AS (
+    SELECT party_movie_viewings.id_viewing, party_movies.id_party, party_movies.id_movie, party_movies.id_added_by, party_movie_viewings.watch_date
+    FROM party_movie_viewings
+    JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+    WHERE party_movies.id_party = $1
+      AND party_movie_viewings.watch_date >= $2 AND party_movie_viewings.watch_date < $3
+  )`
+)
+
+const recapTotalsQuery = `
+  SELECT count(*), coalesce(sum(movies.runtime), 0)
+  FROM watched
+  JOIN movies ON movies.id_movie = watched.id_movie;
+`
+
+const recapTopGenresQuery = `
+  SELECT coalesce(localized.name, fallback.name), count(*)
+  FROM watched
+  JOIN movie_genres ON movie_genres.id_movie = watched.id_movie
+  JOIN genre_names fallback ON fallback.id_genre = movie_genres.id_genre AND fallback.language = $5
+  LEFT JOIN genre_names localized ON localized.id_genre = movie_genres.id_genre AND localized.language = $4
+  GROUP BY movie_genres.id_genre, localized.name, fallback.name
+  ORDER BY count(*) DESC, 1
+  LIMIT $6;
+`
+
+const recapLongestMovieQuery = `
+  SELECT movies.title, movies.runtime, watched.watch_date
+  FROM watched
+  JOIN movies ON movies.id_movie = watched.id_movie
+  WHERE movies.runtime IS NOT NULL
+  ORDER BY movies.runtime DESC, watched.watch_date
+  LIMIT 1;
+`
+
+const recapMostActiveMonthQuery = `
+  SELECT extract(month FROM watched.watch_date)::int, count(*)
+  FROM watched
+  GROUP BY 1
+  ORDER BY 2 DESC, 1
+  LIMIT 1;
+`
+
+// for a watcher the companions are the other people who were there for the movies they watched
+const recapCoWatchersQuery = `
+  SELECT profiles.first_name, profiles.last_name, count(*)
+  FROM watched
+  JOIN party_movie_attendance ON party_movie_attendance.id_viewing = watched.id_viewing
+    AND party_movie_attendance.id_profile != $1 AND party_movie_attendance.attended
+  JOIN profiles ON profiles.id_profile = party_movie_attendance.id_profile
+  GROUP BY profiles.id_profile
+  ORDER BY 3 DESC, profiles.first_name, profiles.last_name
+  LIMIT $4;
+`
+
+// for a party the companions are the members whose picks were watched the most
+const recapTopPickersQuery = `
+  SELECT profiles.first_name, profiles.last_name, count(*)
+  FROM watched
+  JOIN profiles ON profiles.id_profile = watched.id_added_by
+  GROUP BY profiles.id_profile
+  ORDER BY 3 DESC, profiles.first_name, profiles.last_name
+  LIMIT $4;
+`
+
+type RecapGenreResult struct {
+	Name  string
+	Count int
+}
+
+type RecapCompanionResult struct {
+	FirstName string
+	LastName  string
+	Count     int
+}
+
+type RecapResult struct {
+	MoviesWatched  int
+	MinutesWatched int
+	TopGenres      []RecapGenreResult
+	// LongestMovieTitle is empty when nothing watched has a runtime
+	LongestMovieTitle     string
+	LongestMovieRuntime   int
+	LongestMovieWatchDate time.Time
+	// MostActiveMonth is 0 when nothing was watched
+	MostActiveMonth      time.Month
+	MostActiveMonthCount int
+	// Companions are the co-watchers for a watcher's recap and the top pickers for a party's
+	Companions []RecapCompanionResult
+}
+
+// GetRecap reads everything in a recap for the movies watched in the scope between from and to
+func (r *RecapsRepository) GetRecap(ctx context.Context, scope RecapScope, from, to time.Time, language, fallbackLanguage string, limit int) (RecapResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapsRepository.GetRecap")
+	defer span.End()
+
+	watched, id, companionsQuery := recapWatchedByWatcher, scope.IDWatcher, recapCoWatchersQuery
+	if scope.IDParty != 0 {
+		watched, id, companionsQuery = recapWatchedByParty, scope.IDParty, recapTopPickersQuery
+	}
+
+	res := RecapResult{
+		TopGenres:  make([]RecapGenreResult, 0),
+		Companions: make([]RecapCompanionResult, 0),
+	}
+
+	err := r.db.QueryRow(ctx, watched+recapTotalsQuery, id, from, to).Scan(&res.MoviesWatched, &res.MinutesWatched)
+	if err != nil {
+		return RecapResult{}, err
+	}
+
+	if res.MoviesWatched == 0 {
+		return res, nil
+	}
+
+	err = r.queryRecapRows(ctx, watched+recapTopGenresQuery, func(rows pgx.Rows) error {
+		var genre RecapGenreResult
+		err := rows.Scan(&genre.Name, &genre.Count)
+		res.TopGenres = append(res.TopGenres, genre)
+		return err
+	}, id, from, to, language, fallbackLanguage, limit)
+	if err != nil {
+		return RecapResult{}, err
+	}
+
+	err = r.db.QueryRow(ctx, watched+recapLongestMovieQuery, id, from, to).
+		Scan(&res.LongestMovieTitle, &res.LongestMovieRuntime, &res.LongestMovieWatchDate)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return RecapResult{}, err
+	}
+
+	var month int
+	err = r.db.QueryRow(ctx, watched+recapMostActiveMonthQuery, id, from, to).Scan(&month, &res.MostActiveMonthCount)
+	if err != nil {
+		return RecapResult{}, err
+	}
+	res.MostActiveMonth = time.Month(month)
+
+	err = r.queryRecapRows(ctx, watched+companionsQuery, func(rows pgx.Rows) error {
+		var companion RecapCompanionResult
+		err := rows.Scan(&companion.FirstName, &companion.LastName, &companion.Count)
+		res.Companions = append(res.Companions, companion)
+		return err
+	}, id, from, to, limit)
+	if err != nil {
+		return RecapResult{}, err
+	}
+
+	return res, nil
+}
+
+func (r *RecapsRepository) queryRecapRows(ctx context.Context, query string, scanFn func(pgx.Rows) error, args ...any) error {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		err := scanFn(rows)
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+const (
+	getRecapNameForWatcherQuery = `SELECT first_name || ' ' || last_name FROM profiles WHERE id_profile = $1`
+	getRecapNameForPartyQuery   = `SELECT name FROM parties WHERE id_party = $1`
+)
+
+// GetRecapName returns the party's name or the watcher's first and last name, ErrNoRecord is returned when they don't
+// exist
+func (r *RecapsRepository) GetRecapName(ctx context.Context, scope RecapScope) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapsRepository.GetRecapName")
+	defer span.End()
+
+	query, id := getRecapNameForWatcherQuery, scope.IDWatcher
+	if scope.IDParty != 0 {
+		query, id = getRecapNameForPartyQuery, scope.IDParty
+	}
+
+	var name string
+	err := r.db.QueryRow(ctx, query, id).Scan(&name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	return name, nil
+}
+
+const (
+	recapYearsForWatcherQuery = `
+  SELECT DISTINCT extract(year FROM party_movie_viewings.watch_date)::int
+  FROM party_movie_viewings
+  JOIN party_movie_attendance ON party_movie_attendance.id_viewing = party_movie_viewings.id_viewing
+    AND party_movie_attendance.id_profile = $1 AND party_movie_attendance.attended
+  ORDER BY 1 DESC;
+`
+
+	recapYearsForPartyQuery = `
+  SELECT DISTINCT extract(year FROM party_movie_viewings.watch_date)::int
+  FROM party_movie_viewings
+  JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
+  WHERE party_movies.id_party = $1
+  ORDER BY 1 DESC;
+`
+)
+
+// GetRecapYears returns the years something was watched in the scope, newest first
+func (r *RecapsRepository) GetRecapYears(ctx context.Context, scope RecapScope) ([]int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapsRepository.GetRecapYears")
+	defer span.End()
+
+	query, id := recapYearsForWatcherQuery, scope.IDWatcher
+	if scope.IDParty != 0 {
+		query, id = recapYearsForPartyQuery, scope.IDParty
+	}
+
+	years := make([]int, 0)
+	err := r.queryRecapRows(ctx, query, func(rows pgx.Rows) error {
+		var year int
+		err := rows.Scan(&year)
+		years = append(years, year)
+		return err
+	}, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return years, nil
+}
+
+const (
+	getRecapShareTokenForWatcherQuery = `SELECT token FROM recap_shares WHERE id_profile = $1 AND year = $2`
+	getRecapShareTokenForPartyQuery   = `SELECT token FROM recap_shares WHERE id_party = $1 AND year = $2`
+)
+
+// GetRecapShareToken returns the token a recap is shared with, ErrNoRecord is returned when it isn't shared
+func (r *RecapsRepository) GetRecapShareToken(ctx context.Context, scope RecapScope, year int) (string, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapsRepository.GetRecapShareToken")
+	defer span.End()
+
+	query, id := getRecapShareTokenForWatcherQuery, scope.IDWatcher
+	if scope.IDParty != 0 {
+		query, id = getRecapShareTokenForPartyQuery, scope.IDParty
+	}
+
+	var token string
+	err := r.db.QueryRow(ctx, query, id, year).Scan(&token)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrNoRecord
+		}
+		return "", err
+	}
+
+	return token, nil
+}
+
+const createRecapShareQuery = `
+  INSERT INTO recap_shares (token, id_profile, id_party, year, id_shared_by)
+  VALUES ($1, nullif($2, 0), nullif($3, 0), $4, $5);
+`
+
+// CreateRecapShare shares a recap with the token, ErrRecapAlreadyShared is returned when it's already shared
+func (r *RecapsRepository) CreateRecapShare(ctx context.Context, token string, scope RecapScope, year, idSharedBy int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapsRepository.CreateRecapShare")
+	defer span.End()
+
+	_, err := r.db.Exec(ctx, createRecapShareQuery, token, scope.IDWatcher, scope.IDParty, year, idSharedBy)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == pgUniqueViolationCode {
+			return ErrRecapAlreadyShared
+		}
+		return err
+	}
+
+	return nil
+}
+
+const (
+	deleteRecapShareForWatcherQuery = `DELETE FROM recap_shares WHERE id_profile = $1 AND year = $2`
+	deleteRecapShareForPartyQuery   = `DELETE FROM recap_shares WHERE id_party = $1 AND year = $2`
+)
+
+func (r *RecapsRepository) DeleteRecapShare(ctx context.Context, scope RecapScope, year int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapsRepository.DeleteRecapShare")
+	defer span.End()
+
+	query, id := deleteRecapShareForWatcherQuery, scope.IDWatcher
+	if scope.IDParty != 0 {
+		query, id = deleteRecapShareForPartyQuery, scope.IDParty
+	}
+
+	_, err := r.db.Exec(ctx, query, id, year)
+	return err
+}
+
+type RecapShareResult struct {
+	Scope RecapScope
+	Year  int
+}
+
+const getRecapShareQuery = `
+  SELECT coalesce(id_profile, 0), coalesce(id_party, 0), year
+  FROM recap_shares
+  WHERE token = $1;
+`
+
+// GetRecapShare returns the recap a token shares, ErrNoRecord is returned when there isn't one
+func (r *RecapsRepository) GetRecapShare(ctx context.Context, token string) (RecapShareResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "RecapsRepository.GetRecapShare")
+	defer span.End()
+
+	var res RecapShareResult
+	err := r.db.QueryRow(ctx, getRecapShareQuery, token).Scan(&res.Scope.IDWatcher, &res.Scope.IDParty, &res.Year)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return RecapShareResult{}, ErrNoRecord
+		}
+		return RecapShareResult{}, err
+	}
+
+	return res, nil
+}
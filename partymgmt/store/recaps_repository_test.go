@@ -0,0 +1,84 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestGetRecap(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_get_recap_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewRecapsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "recap-party", "recapa")
+	idPicker := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idPicker)
+
+	addedAt := time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC)
+	seedPartyMovie(ctx, t, connPool, idParty, idPicker, "Heat", 170, addedAt, ptr(time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)))
+	seedPartyMovie(ctx, t, connPool, idParty, idPicker, "Thief", 123, addedAt, ptr(time.Date(2024, 3, 9, 0, 0, 0, 0, time.UTC)))
+	seedPartyMovie(ctx, t, connPool, idParty, idPicker, "Collateral", 120, addedAt, ptr(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)))
+	seedPartyMovie(ctx, t, connPool, idParty, idPicker, "Ali", 157, addedAt, nil)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	res, err := repo.GetRecap(ctx, store.RecapScope{IDParty: idParty}, from, from.AddDate(1, 0, 0), "en-US", "en-US", 3)
+	testhelpers.Ok(t, err, "failed to get recap")
+
+	testhelpers.Equals(t, 2, res.MoviesWatched)
+	testhelpers.Equals(t, 293, res.MinutesWatched)
+	testhelpers.Equals(t, "Heat", res.LongestMovieTitle)
+	testhelpers.Equals(t, time.March, res.MostActiveMonth)
+	testhelpers.Equals(t, 2, res.MostActiveMonthCount)
+	testhelpers.Equals(t, 1, len(res.Companions))
+	testhelpers.Equals(t, 2, res.Companions[0].Count)
+
+	years, err := repo.GetRecapYears(ctx, store.RecapScope{IDParty: idParty})
+	testhelpers.Ok(t, err, "failed to get recap years")
+	testhelpers.Equals(t, []int{2024, 2023}, years)
+}
+
+func TestRecapShares(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_recap_shares_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewRecapsRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "shared-party", "recapb")
+	idMember := seedProfile(ctx, t, connPool)
+	scope := store.RecapScope{IDParty: idParty}
+
+	_, err := repo.GetRecapShareToken(ctx, scope, 2024)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	err = repo.CreateRecapShare(ctx, "token-one", scope, 2024, idMember)
+	testhelpers.Ok(t, err, "failed to create recap share")
+
+	err = repo.CreateRecapShare(ctx, "token-two", scope, 2024, idMember)
+	testhelpers.Assert(t, errors.Is(err, store.ErrRecapAlreadyShared), "expected %v, got %v", store.ErrRecapAlreadyShared, err)
+
+	token, err := repo.GetRecapShareToken(ctx, scope, 2024)
+	testhelpers.Ok(t, err, "failed to get recap share token")
+	testhelpers.Equals(t, "token-one", token)
+
+	share, err := repo.GetRecapShare(ctx, "token-one")
+	testhelpers.Ok(t, err, "failed to get recap share")
+	testhelpers.Equals(t, scope, share.Scope)
+	testhelpers.Equals(t, 2024, share.Year)
+
+	err = repo.DeleteRecapShare(ctx, scope, 2024)
+	testhelpers.Ok(t, err, "failed to delete recap share")
+
+	_, err = repo.GetRecapShare(ctx, "token-one")
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type WatchProvidersRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWatchProvidersRepository(db *pgxpool.Pool) *WatchProvidersRepository {
+	return &WatchProvidersRepository{db: db}
+}
+
+type MovieWatchProvider struct {
+	Region           string
+	ID               int
+	Name             string
+	LogoPath         string
+	MonetizationType string
+	DisplayPriority  int
+}
+
+// movies that haven't been watched yet that either have never had their providers fetched or were fetched before the cutoff
+const getMoviesNeedingWatchProvidersQuery = `
+  SELECT DISTINCT movies.id_movie, movies.tmdb_id
+  FROM movies
+  JOIN party_movies ON party_movies.id_movie = movies.id_movie
+  WHERE party_movies.id_party = $1
+  AND party_movies.watch_status IN ('unwatched', 'selected')
+  AND (movies.watch_providers_checked_at IS NULL OR movies.watch_providers_checked_at < $2)`
+
+// GetMoviesNeedingWatchProviders returns the unwatched movies in a party whose providers are missing or were last
+// fetched before checkedBefore
+func (w *WatchProvidersRepository) GetMoviesNeedingWatchProviders(ctx context.Context, idParty int, checkedBefore time.Time, assignFn func(idMovie, tmdbID int)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WatchProvidersRepository.GetMoviesNeedingWatchProviders")
+	defer span.End()
+
+	rows, err := w.db.Query(ctx, getMoviesNeedingWatchProvidersQuery, idParty, checkedBefore)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var idMovie, tmdbID int
+		err := rows.Scan(&idMovie, &tmdbID)
+		if err != nil {
+			return err
+		}
+		assignFn(idMovie, tmdbID)
+	}
+
+	return rows.Err()
+}
+
+const (
+	deleteMovieWatchProvidersQuery = `DELETE FROM movie_watch_providers WHERE id_movie = $1`
+
+	insertMovieWatchProviderQuery = `
+  INSERT INTO movie_watch_providers (id_movie, region, id_provider, monetization_type, provider_name, logo_path, display_priority)
+  VALUES ($1, $2, $3, $4, $5, $6, $7)
+  ON CONFLICT DO NOTHING`
+
+	setWatchProvidersCheckedAtQuery = `UPDATE movies SET watch_providers_checked_at = (clock_timestamp() AT TIME ZONE 'UTC') WHERE id_movie = $1`
+)
+
+// ReplaceMovieWatchProviders swaps out every stored provider for a movie and records when they were fetched, an empty
+// list is still recorded so the movie isn't refetched until it's stale
+func (w *WatchProvidersRepository) ReplaceMovieWatchProviders(ctx context.Context, idMovie int, providers []MovieWatchProvider) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WatchProvidersRepository.ReplaceMovieWatchProviders")
+	defer span.End()
+
+	txn, err := w.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer txn.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	batch.Queue(deleteMovieWatchProvidersQuery, idMovie)
+	for _, provider := range providers {
+		batch.Queue(
+			insertMovieWatchProviderQuery,
+			idMovie,
+			provider.Region,
+			provider.ID,
+			provider.MonetizationType,
+			provider.Name,
+			provider.LogoPath,
+			provider.DisplayPriority,
+		)
+	}
+	batch.Queue(setWatchProvidersCheckedAtQuery, idMovie)
+
+	err = txn.SendBatch(ctx, batch).Close()
+	if err != nil {
+		return err
+	}
+
+	return txn.Commit(ctx)
+}
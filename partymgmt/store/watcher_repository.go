@@ -3,7 +3,10 @@ package store
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -19,75 +22,299 @@ func NewWatcherRepository(db *pgxpool.Pool) *WatcherRepository {
 	return &WatcherRepository{db: db}
 }
 
-type WatchedMoviesForWatcherResult struct {
+// WatchHistorySortColumn is what the watch history can be ordered by
+type WatchHistorySortColumn string
+
+const (
+	WatchHistorySortWatchDate WatchHistorySortColumn = "watch_date"
+	WatchHistorySortTitle     WatchHistorySortColumn = "title"
+	WatchHistorySortRuntime   WatchHistorySortColumn = "runtime"
+)
+
+// watchHistorySortExpressions are the expressions each sort orders by along with the type their cursor value is cast
+// to, the cursor value is the expression read back as text
+var watchHistorySortExpressions = map[WatchHistorySortColumn]struct {
+	expr string
+	cast string
+}{
+	WatchHistorySortWatchDate: {expr: "party_movie_viewings.watch_date", cast: "timestamptz"},
+	WatchHistorySortTitle:     {expr: "lower(movies.title)", cast: "text"},
+	WatchHistorySortRuntime:   {expr: "coalesce(movies.runtime, 0)", cast: "int"},
+}
+
+// WatchHistoryCursor is the position of a row in the watch history for a sort, the id of the viewing breaks ties
+// between rows with the same value
+type WatchHistoryCursor struct {
+	Value     string
+	IDViewing int
+}
+
+type WatchHistoryParams struct {
+	IDWatcher int
+	IDParty   int
+	IDGenre   int
+	// WatchedFrom and WatchedBefore bound the watch date, WatchedBefore is exclusive
+	WatchedFrom   *time.Time
+	WatchedBefore *time.Time
+	MinRating     int
+	Search        string
+	Sort          WatchHistorySortColumn
+	Descending    bool
+	Limit         int
+	// After and Before page forwards or backwards from a row, only one is used and After wins when both are set
+	After  *WatchHistoryCursor
+	Before *WatchHistoryCursor
+}
+
+type WatchHistoryResult struct {
 	IDMovie   int
 	Title     string
+	Runtime   int
 	WatchDate time.Time
+	IDParty   int
 	PartyName string
+	// Rating is the watcher's own rating for the movie, 0 when they haven't rated it
+	Rating int
+	Cursor WatchHistoryCursor
 }
 
-const getWatchedMoviesForWatcher = `
+const getWatchHistoryQuery = `
   SELECT
     movies.id_movie,
     movies.title,
-    party_movies.watch_date ,
-    parties.name
-  FROM party_movies
+    coalesce(movies.runtime, 0),
+    party_movie_viewings.watch_date,
+    parties.id_party,
+    parties.name,
+    coalesce(party_movie_ratings.rating, 0),
+    (%[1]s)::text,
+    party_movie_viewings.id_viewing
+  FROM party_movie_viewings
+  JOIN party_movies ON party_movies.id = party_movie_viewings.id_party_movie
   JOIN movies ON movies.id_movie = party_movies.id_movie
-  JOIN party_members ON party_members.id_party = party_movies.id_party
-  JOIN parties ON parties.id_party = party_movies.id_party 
-  WHERE party_members.id_member = $1 AND party_movies.watch_status = 'watched'
-  ORDER BY party_movies.watch_date DESC
-  LIMIT 5
-  OFFSET $2;
+  JOIN parties ON parties.id_party = party_movies.id_party
+  JOIN party_movie_attendance ON party_movie_attendance.id_viewing = party_movie_viewings.id_viewing
+    AND party_movie_attendance.id_profile = $1 AND party_movie_attendance.attended
+  LEFT JOIN party_movie_ratings ON party_movie_ratings.id_party = party_movies.id_party
+    AND party_movie_ratings.id_movie = party_movies.id_movie
+    AND party_movie_ratings.id_profile = $1
+  WHERE true%[2]s
+  ORDER BY %[1]s %[3]s, party_movie_viewings.id_viewing %[3]s
+  LIMIT %[4]d;
 `
 
-func (p *WatcherRepository) GetWatchedMoviesForWatcher(ctx context.Context, idWatcher int, offset int) ([]WatchedMoviesForWatcherResult, error) {
-	ctx, span, _ := metrics.SpanFromContext(ctx, "WatcherRepository.GetWatchedMoviesForWatcher")
+// GetWatchHistory returns a page of the viewings the watcher was there for when one of their parties watched a movie, a
+// movie that's been rewatched has a row for each viewing. Pages are found from the cursor of the row before or after
+// them so they don't shift as movies are watched, when paging backwards the rows are still passed to assignFn in the
+// order of the sort.
+func (p *WatcherRepository) GetWatchHistory(ctx context.Context, params WatchHistoryParams, assignFn func(WatchHistoryResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WatcherRepository.GetWatchHistory")
 	defer span.End()
-	rows, err := p.db.Query(ctx, getWatchedMoviesForWatcher, idWatcher, offset)
+
+	query, args := buildWatchHistoryQuery(params)
+
+	rows, err := p.db.Query(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	defer rows.Close()
 
-	var movies []WatchedMoviesForWatcherResult
+	results := make([]WatchHistoryResult, 0, params.Limit)
 	for rows.Next() {
-		var movie WatchedMoviesForWatcherResult
-		err := rows.Scan(&movie.IDMovie, &movie.Title, &movie.WatchDate, &movie.PartyName)
+		var res WatchHistoryResult
+		err := rows.Scan(
+			&res.IDMovie,
+			&res.Title,
+			&res.Runtime,
+			&res.WatchDate,
+			&res.IDParty,
+			&res.PartyName,
+			&res.Rating,
+			&res.Cursor.Value,
+			&res.Cursor.IDViewing,
+		)
 		if err != nil {
-			return nil, err
+			return err
 		}
-		movies = append(movies, movie)
+		results = append(results, res)
 	}
 
 	err = rows.Err()
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	if params.After == nil && params.Before != nil {
+		slices.Reverse(results)
+	}
+
+	for _, res := range results {
+		assignFn(res)
+	}
+
+	return nil
+}
+
+func buildWatchHistoryQuery(params WatchHistoryParams) (string, []any) {
+	sort, ok := watchHistorySortExpressions[params.Sort]
+	if !ok {
+		sort = watchHistorySortExpressions[WatchHistorySortWatchDate]
+	}
+
+	args := []any{params.IDWatcher}
+	var filters strings.Builder
+	addFilter := func(filter string, arg any) {
+		args = append(args, arg)
+		fmt.Fprintf(&filters, "\n    AND "+filter, len(args))
+	}
+
+	if params.IDParty != 0 {
+		addFilter("party_movies.id_party = $%d", params.IDParty)
+	}
+
+	if params.IDGenre != 0 {
+		addFilter("EXISTS (SELECT 1 FROM movie_genres WHERE movie_genres.id_movie = movies.id_movie AND movie_genres.id_genre = $%d)", params.IDGenre)
+	}
+
+	if params.WatchedFrom != nil {
+		addFilter("party_movie_viewings.watch_date >= $%d", *params.WatchedFrom)
+	}
+
+	if params.WatchedBefore != nil {
+		addFilter("party_movie_viewings.watch_date < $%d", *params.WatchedBefore)
+	}
+
+	if params.MinRating != 0 {
+		addFilter("party_movie_ratings.rating >= $%d", params.MinRating)
+	}
+
+	if params.Search != "" {
+		addFilter(`movies.title ILIKE '%%' || $%d || '%%'`, escapeLike(params.Search))
+	}
+
+	// paging backwards walks the sort in reverse from the cursor and the rows are flipped back once they're read
+	descending := params.Descending
+	cursor := params.After
+	if cursor == nil && params.Before != nil {
+		cursor = params.Before
+		descending = !descending
+	}
+
+	direction, comparison := "ASC", ">"
+	if descending {
+		direction, comparison = "DESC", "<"
+	}
+
+	if cursor != nil {
+		args = append(args, cursor.Value, cursor.IDViewing)
+		fmt.Fprintf(&filters, "\n    AND (%s, party_movie_viewings.id_viewing) %s ($%d::text::%s, $%d)", sort.expr, comparison, len(args)-1, sort.cast, len(args))
 	}
 
-	return movies, nil
+	return fmt.Sprintf(getWatchHistoryQuery, sort.expr, filters.String(), direction, params.Limit), args
 }
 
-const getWatchedMoviesCountForWatcher = `
-  SELECT count(party_movies.*)
+// escapeLike stops wildcards in a search term from matching everything
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+const getPartyNamesForWatcherQuery = `
+  SELECT parties.id_party, parties.name
+  FROM parties
+  JOIN party_members ON party_members.id_party = parties.id_party
+  WHERE party_members.id_member = $1
+  ORDER BY parties.name;
+`
+
+// GetPartyNamesForWatcher returns the id and name of every party the watcher is in
+func (p *WatcherRepository) GetPartyNamesForWatcher(ctx context.Context, idWatcher int, assignFn func(id int, name string)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WatcherRepository.GetPartyNamesForWatcher")
+	defer span.End()
+
+	rows, err := p.db.Query(ctx, getPartyNamesForWatcherQuery, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			id   int
+			name string
+		)
+		err := rows.Scan(&id, &name)
+		if err != nil {
+			return err
+		}
+		assignFn(id, name)
+	}
+
+	return rows.Err()
+}
+
+const rateMovieQuery = `
+  INSERT INTO party_movie_ratings (id_party, id_movie, id_profile, rating)
+  SELECT party_movies.id_party, party_movies.id_movie, party_members.id_member, $4
+  FROM party_movies
+  JOIN party_members ON party_members.id_party = party_movies.id_party AND party_members.id_member = $3
+  WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2 AND party_movies.watch_status = 'watched'
+  ON CONFLICT (id_party, id_movie, id_profile) DO UPDATE
+    SET rating = excluded.rating, updated_at = (clock_timestamp() AT TIME ZONE 'UTC');
+`
+
+// RateMovie sets the watcher's rating for a movie their party has watched, ErrNoRecord is returned when the watcher
+// isn't in the party or the party hasn't watched the movie
+func (p *WatcherRepository) RateMovie(ctx context.Context, idParty, idMovie, idWatcher, rating int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WatcherRepository.RateMovie")
+	defer span.End()
+
+	tag, err := p.db.Exec(ctx, rateMovieQuery, idParty, idMovie, idWatcher, rating)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const setAttendanceQuery = `
+  INSERT INTO party_movie_attendance (id_viewing, id_profile, attended)
+  SELECT latest_viewing.id_viewing, party_members.id_member, $4
   FROM party_movies
-  JOIN party_members ON party_members.id_party = party_movies.id_party
-  WHERE party_members.id_member = $1 AND party_movies.watch_status = 'watched'
+  JOIN party_members ON party_members.id_party = party_movies.id_party AND party_members.id_member = $3
+  JOIN LATERAL (
+    SELECT party_movie_viewings.id_viewing
+    FROM party_movie_viewings
+    WHERE party_movie_viewings.id_party_movie = party_movies.id
+    ORDER BY party_movie_viewings.watch_date DESC, party_movie_viewings.id_viewing DESC
+    LIMIT 1
+  ) latest_viewing ON true
+  WHERE party_movies.id_party = $1 AND party_movies.id_movie = $2 AND party_movies.watch_status = 'watched'
+  ON CONFLICT (id_viewing, id_profile) DO UPDATE
+    SET attended = excluded.attended, updated_at = (clock_timestamp() AT TIME ZONE 'UTC');
 `
 
-func (p *WatcherRepository) GetWatchedMoviesCountForMember(ctx context.Context, logger *slog.Logger, idMember int) (int, error) {
-	ctx, span, _ := metrics.SpanFromContext(ctx, "WatcherRepository.GetWatchedMoviesCountForMember")
+// SetAttendance records whether the watcher was there the last time their party watched a movie, ErrNoRecord is
+// returned when the watcher isn't in the party or the party hasn't watched the movie
+func (p *WatcherRepository) SetAttendance(ctx context.Context, idParty, idMovie, idWatcher int, attended bool) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WatcherRepository.SetAttendance")
 	defer span.End()
-	var count int
-	err := p.db.QueryRow(ctx, getWatchedMoviesCountForWatcher, idMember).Scan(&count)
+
+	tag, err := p.db.Exec(ctx, setAttendanceQuery, idParty, idMovie, idWatcher, attended)
 	if err != nil {
-		logger.ErrorContext(ctx, "failed to get watched movies count", "error", err)
-		return 0, err
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
 	}
 
-	return count, nil
+	return nil
 }
 
 const getPartiesForWatcherQuery = `
@@ -223,7 +450,7 @@ const getPartiesWithMovieQuery = `
   from parties
   join party_members on party_members.id_party = parties.id_party
   join party_movies on party_movies.id_party = parties.id_party 
-  where party_movies.id_movie = $1 AND party_members.id_member = $2;
+  where party_movies.id_movie = $1 AND party_members.id_member = $2 AND party_movies.watch_status != 'watched';
 `
 
 func (p *WatcherRepository) GetWatcherPartiesWithMovie(ctx context.Context, logger *slog.Logger, idMember int, idMovie int, assignFn func(int, string)) error {
@@ -252,10 +479,12 @@ func (p *WatcherRepository) GetWatcherPartiesWithMovie(ctx context.Context, logg
 	return nil
 }
 
+// parties that have already watched the movie can add it again to rewatch it
 const getPartiesWithoutMovieQuery = `
   SELECT parties.id_party, parties.name, COALESCE(COUNT(pm2.id_movie), 0)
   FROM parties
   LEFT JOIN party_movies ON parties.id_party = party_movies.id_party AND party_movies.id_movie = $1
+    AND party_movies.watch_status != 'watched'
   LEFT JOIN party_movies pm2 ON parties.id_party = pm2.id_party
   JOIN party_members ON party_members.id_party = parties.id_party
   WHERE party_movies.id_movie IS NULL AND party_members.id_member = $2
@@ -294,7 +523,7 @@ const getPartiesWithMovieQueryByTMDBID = `
   join party_members on party_members.id_party = parties.id_party
   join party_movies on party_movies.id_party = parties.id_party 
   join movies on party_movies.id_movie = movies.id_movie
-  where movies.tmdb_id = $1 AND party_members.id_member = $2;
+  where movies.tmdb_id = $1 AND party_members.id_member = $2 AND party_movies.watch_status != 'watched';
 `
 
 func (p *WatcherRepository) GetWatcherPartiesWithMovieByTMDBID(ctx context.Context, logger *slog.Logger, idMember int, tmdbID int, assignFn func(int, string)) error {
@@ -327,14 +556,19 @@ func (p *WatcherRepository) GetWatcherPartiesWithMovieByTMDBID(ctx context.Conte
 	return nil
 }
 
+// parties that have already watched the movie can add it again to rewatch it
 const getPartiesWithoutMovieQueryByTMDBID = `
 SELECT parties.id_party, parties.name, COALESCE(COUNT(pm2.id_movie), 0)
 FROM parties
-LEFT JOIN party_movies ON parties.id_party = party_movies.id_party
-LEFT JOIN movies ON party_movies.id_movie = movies.id_movie AND movies.tmdb_id = $1
 LEFT JOIN party_movies pm2 ON parties.id_party = pm2.id_party
 JOIN party_members ON party_members.id_party = parties.id_party
-WHERE movies.id_movie IS NULL AND party_members.id_member = $2
+WHERE party_members.id_member = $2
+  AND NOT EXISTS (
+    SELECT 1
+    FROM party_movies
+    JOIN movies ON movies.id_movie = party_movies.id_movie
+    WHERE party_movies.id_party = parties.id_party AND movies.tmdb_id = $1 AND party_movies.watch_status != 'watched'
+  )
 GROUP BY parties.id_party, parties.name;
 `
 
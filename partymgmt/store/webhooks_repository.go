@@ -0,0 +1,402 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/metrics"
+)
+
+type WebhookFormatEnum string
+
+const (
+	WebhookFormatJSON    WebhookFormatEnum = "json"
+	WebhookFormatSlack   WebhookFormatEnum = "slack"
+	WebhookFormatDiscord WebhookFormatEnum = "discord"
+)
+
+type WebhookDeliveryStatusEnum string
+
+const (
+	WebhookDeliveryStatusPending   WebhookDeliveryStatusEnum = "pending"
+	WebhookDeliveryStatusSucceeded WebhookDeliveryStatusEnum = "succeeded"
+	WebhookDeliveryStatusFailed    WebhookDeliveryStatusEnum = "failed"
+)
+
+type WebhooksRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewWebhooksRepository(db *pgxpool.Pool) *WebhooksRepository {
+	return &WebhooksRepository{db: db}
+}
+
+type WebhookPartyResult struct {
+	Name    string
+	IDOwner int
+}
+
+const getWebhookPartyQuery = `SELECT name, coalesce(id_owner, 0) FROM parties WHERE id_party = $1;`
+
+// GetWebhookParty returns the party webhooks are being sent for, ErrNoRecord is returned when it doesn't exist
+func (w *WebhooksRepository) GetWebhookParty(ctx context.Context, idParty int) (WebhookPartyResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.GetWebhookParty")
+	defer span.End()
+
+	var res WebhookPartyResult
+	err := w.db.QueryRow(ctx, getWebhookPartyQuery, idParty).Scan(&res.Name, &res.IDOwner)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return WebhookPartyResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return WebhookPartyResult{}, err
+	}
+
+	return res, nil
+}
+
+type WebhookParams struct {
+	IDParty         int
+	URL             string
+	Format          WebhookFormatEnum
+	Secret          string
+	OnMovieAdded    bool
+	OnMovieSelected bool
+	OnMovieWatched  bool
+	IDCreatedBy     int
+}
+
+const createWebhookQuery = `
+  INSERT INTO party_webhooks (id_party, url, format, secret, on_movie_added, on_movie_selected, on_movie_watched, id_created_by)
+  VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+  RETURNING id_webhook;
+`
+
+func (w *WebhooksRepository) CreateWebhook(ctx context.Context, params WebhookParams) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.CreateWebhook")
+	defer span.End()
+
+	var id int
+	err := w.db.QueryRow(
+		ctx,
+		createWebhookQuery,
+		params.IDParty,
+		params.URL,
+		params.Format,
+		params.Secret,
+		params.OnMovieAdded,
+		params.OnMovieSelected,
+		params.OnMovieWatched,
+		params.IDCreatedBy,
+	).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+type WebhookResult struct {
+	ID              int
+	IDParty         int
+	URL             string
+	Format          WebhookFormatEnum
+	Secret          string
+	OnMovieAdded    bool
+	OnMovieSelected bool
+	OnMovieWatched  bool
+	CreatedAt       time.Time
+}
+
+const webhookColumns = `
+    id_webhook,
+    id_party,
+    url,
+    format::text,
+    secret,
+    on_movie_added,
+    on_movie_selected,
+    on_movie_watched,
+    created_at
+  FROM party_webhooks
+`
+
+func scanWebhook(row pgx.Row) (WebhookResult, error) {
+	var res WebhookResult
+	err := row.Scan(
+		&res.ID,
+		&res.IDParty,
+		&res.URL,
+		&res.Format,
+		&res.Secret,
+		&res.OnMovieAdded,
+		&res.OnMovieSelected,
+		&res.OnMovieWatched,
+		&res.CreatedAt,
+	)
+	return res, err
+}
+
+const getWebhookQuery = `SELECT` + webhookColumns + `WHERE id_party = $1 AND id_webhook = $2;`
+
+func (w *WebhooksRepository) GetWebhook(ctx context.Context, idParty, idWebhook int) (WebhookResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.GetWebhook")
+	defer span.End()
+
+	res, err := scanWebhook(w.db.QueryRow(ctx, getWebhookQuery, idParty, idWebhook))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return WebhookResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return WebhookResult{}, err
+	}
+
+	return res, nil
+}
+
+const getWebhooksQuery = `SELECT` + webhookColumns + `WHERE id_party = $1 ORDER BY id_webhook;`
+
+// GetWebhooks reads the party's webhooks in the order they were added
+func (w *WebhooksRepository) GetWebhooks(ctx context.Context, idParty int, assignFn func(WebhookResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.GetWebhooks")
+	defer span.End()
+
+	rows, err := w.db.Query(ctx, getWebhooksQuery, idParty)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		res, err := scanWebhook(rows)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+const deleteWebhookQuery = `DELETE FROM party_webhooks WHERE id_party = $1 AND id_webhook = $2;`
+
+// DeleteWebhook removes the webhook along with its deliveries, ErrNoRecord is returned when it isn't one of the party's
+func (w *WebhooksRepository) DeleteWebhook(ctx context.Context, idParty, idWebhook int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.DeleteWebhook")
+	defer span.End()
+
+	tag, err := w.db.Exec(ctx, deleteWebhookQuery, idParty, idWebhook)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
+
+const createDeliveryQuery = `
+  INSERT INTO webhook_deliveries (id_webhook, event_type, body)
+  VALUES ($1, $2, $3)
+  RETURNING id_delivery;
+`
+
+// CreateDelivery queues the body to be sent to the webhook straight away
+func (w *WebhooksRepository) CreateDelivery(ctx context.Context, idWebhook int, eventType, body string) (int, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.CreateDelivery")
+	defer span.End()
+
+	var id int
+	err := w.db.QueryRow(ctx, createDeliveryQuery, idWebhook, eventType, body).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+type DeliveryResult struct {
+	ID             int
+	IDWebhook      int
+	URL            string
+	EventType      string
+	Status         WebhookDeliveryStatusEnum
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	LastError      string
+	CreatedAt      time.Time
+}
+
+const getDeliveriesQuery = `
+  SELECT
+    webhook_deliveries.id_delivery,
+    webhook_deliveries.id_webhook,
+    party_webhooks.url,
+    webhook_deliveries.event_type,
+    webhook_deliveries.status::text,
+    webhook_deliveries.attempts,
+    webhook_deliveries.next_attempt_at,
+    webhook_deliveries.last_attempt_at,
+    coalesce(webhook_deliveries.response_status, 0),
+    coalesce(webhook_deliveries.last_error, ''),
+    webhook_deliveries.created_at
+  FROM webhook_deliveries
+  JOIN party_webhooks ON party_webhooks.id_webhook = webhook_deliveries.id_webhook
+  WHERE party_webhooks.id_party = $1
+  ORDER BY webhook_deliveries.id_delivery DESC
+  LIMIT $2;
+`
+
+// GetDeliveries reads the latest deliveries to the party's webhooks, newest first
+func (w *WebhooksRepository) GetDeliveries(ctx context.Context, idParty, limit int, assignFn func(DeliveryResult)) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.GetDeliveries")
+	defer span.End()
+
+	rows, err := w.db.Query(ctx, getDeliveriesQuery, idParty, limit)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var res DeliveryResult
+		err = rows.Scan(
+			&res.ID,
+			&res.IDWebhook,
+			&res.URL,
+			&res.EventType,
+			&res.Status,
+			&res.Attempts,
+			&res.NextAttemptAt,
+			&res.LastAttemptAt,
+			&res.ResponseStatus,
+			&res.LastError,
+			&res.CreatedAt,
+		)
+		if err != nil {
+			return err
+		}
+		assignFn(res)
+	}
+
+	return rows.Err()
+}
+
+// PendingDeliveryResult is a delivery that's been claimed to be sent, Attempts includes the attempt being made
+type PendingDeliveryResult struct {
+	ID        int
+	IDWebhook int
+	URL       string
+	Secret    string
+	EventType string
+	Body      string
+	Attempts  int
+}
+
+// the claimed delivery's next attempt is pushed back to $2 so it's tried again if whoever claimed it never records how
+// it went
+const claimDueDeliveryQuery = `
+  UPDATE webhook_deliveries
+  SET attempts = webhook_deliveries.attempts + 1, next_attempt_at = $2
+  FROM party_webhooks
+  WHERE party_webhooks.id_webhook = webhook_deliveries.id_webhook
+    AND webhook_deliveries.id_delivery = (
+      SELECT id_delivery
+      FROM webhook_deliveries
+      WHERE status = 'pending' AND next_attempt_at <= $1
+      ORDER BY next_attempt_at
+      LIMIT 1
+      FOR UPDATE SKIP LOCKED
+    )
+  RETURNING
+    webhook_deliveries.id_delivery,
+    webhook_deliveries.id_webhook,
+    party_webhooks.url,
+    party_webhooks.secret,
+    webhook_deliveries.event_type,
+    webhook_deliveries.body,
+    webhook_deliveries.attempts;
+`
+
+// ClaimDueDelivery claims the pending delivery that's been due the longest at now, it won't be claimed again until
+// retryAt. ErrNoRecord is returned when nothing is due
+func (w *WebhooksRepository) ClaimDueDelivery(ctx context.Context, now, retryAt time.Time) (PendingDeliveryResult, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.ClaimDueDelivery")
+	defer span.End()
+
+	var res PendingDeliveryResult
+	err := w.db.QueryRow(ctx, claimDueDeliveryQuery, now, retryAt).Scan(
+		&res.ID,
+		&res.IDWebhook,
+		&res.URL,
+		&res.Secret,
+		&res.EventType,
+		&res.Body,
+		&res.Attempts,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return PendingDeliveryResult{}, ErrNoRecord
+	}
+
+	if err != nil {
+		return PendingDeliveryResult{}, err
+	}
+
+	return res, nil
+}
+
+// DeliveryAttemptParams is how sending a delivery went, NextAttemptAt is only used when it's still pending.
+// ResponseStatus is 0 when no response was received
+type DeliveryAttemptParams struct {
+	IDDelivery     int
+	Status         WebhookDeliveryStatusEnum
+	ResponseStatus int
+	Error          string
+	AttemptedAt    time.Time
+	NextAttemptAt  time.Time
+}
+
+const recordDeliveryAttemptQuery = `
+  UPDATE webhook_deliveries
+  SET
+    status = $2,
+    response_status = nullif($3::int, 0),
+    last_error = nullif($4, ''),
+    last_attempt_at = $5,
+    next_attempt_at = $6
+  WHERE id_delivery = $1;
+`
+
+func (w *WebhooksRepository) RecordDeliveryAttempt(ctx context.Context, params DeliveryAttemptParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhooksRepository.RecordDeliveryAttempt")
+	defer span.End()
+
+	tag, err := w.db.Exec(
+		ctx,
+		recordDeliveryAttemptQuery,
+		params.IDDelivery,
+		params.Status,
+		params.ResponseStatus,
+		params.Error,
+		params.AttemptedAt,
+		params.NextAttemptAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNoRecord
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package store_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestWebhookDeliveries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Parallel()
+	schemaName := fmt.Sprintf("%s_webhooks_schema", baseSchemaName)
+	connPool := testhelpers.SetupConnPool(ctx, t, schemaName)
+	repo := store.NewWebhooksRepository(connPool)
+
+	idParty := seedParty(ctx, t, connPool, "hooked-party", "hookeda")
+	idOwner := seedProfile(ctx, t, connPool)
+	seedPartyMember(ctx, t, connPool, idParty, idOwner)
+
+	_, err := connPool.Exec(ctx, "update parties set id_owner = $2 where id_party = $1", idParty, idOwner)
+	testhelpers.Ok(t, err, "failed to set party owner")
+
+	party, err := repo.GetWebhookParty(ctx, idParty)
+	testhelpers.Ok(t, err, "failed to get webhook party")
+	testhelpers.Equals(t, store.WebhookPartyResult{Name: "hooked-party", IDOwner: idOwner}, party)
+
+	idWebhook, err := repo.CreateWebhook(ctx, store.WebhookParams{
+		IDParty:        idParty,
+		URL:            "https://example.com/hook",
+		Format:         store.WebhookFormatDiscord,
+		Secret:         "shh",
+		OnMovieAdded:   true,
+		OnMovieWatched: true,
+		IDCreatedBy:    idOwner,
+	})
+	testhelpers.Ok(t, err, "failed to create webhook")
+
+	webhook, err := repo.GetWebhook(ctx, idParty, idWebhook)
+	testhelpers.Ok(t, err, "failed to get webhook")
+	testhelpers.Equals(t, store.WebhookFormatDiscord, webhook.Format)
+	testhelpers.Assert(t, webhook.OnMovieAdded && !webhook.OnMovieSelected && webhook.OnMovieWatched, "unexpected events %+v", webhook)
+
+	_, err = repo.GetWebhook(ctx, idParty+1000, idWebhook)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	idDelivery, err := repo.CreateDelivery(ctx, idWebhook, "movie_added", `{"content":"hi"}`)
+	testhelpers.Ok(t, err, "failed to create delivery")
+
+	now := time.Now().UTC()
+	claimed, err := repo.ClaimDueDelivery(ctx, now, now.Add(time.Minute))
+	testhelpers.Ok(t, err, "failed to claim delivery")
+	testhelpers.Equals(t, store.PendingDeliveryResult{
+		ID:        idDelivery,
+		IDWebhook: idWebhook,
+		URL:       "https://example.com/hook",
+		Secret:    "shh",
+		EventType: "movie_added",
+		Body:      `{"content":"hi"}`,
+		Attempts:  1,
+	}, claimed)
+
+	// it isn't handed out again while it's claimed
+	_, err = repo.ClaimDueDelivery(ctx, now, now.Add(time.Minute))
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	retryAt := now.Add(30 * time.Second)
+	err = repo.RecordDeliveryAttempt(ctx, store.DeliveryAttemptParams{
+		IDDelivery:     idDelivery,
+		Status:         store.WebhookDeliveryStatusPending,
+		ResponseStatus: 500,
+		Error:          "webhook responded with 500",
+		AttemptedAt:    now,
+		NextAttemptAt:  retryAt,
+	})
+	testhelpers.Ok(t, err, "failed to record failed attempt")
+
+	_, err = repo.ClaimDueDelivery(ctx, now, now.Add(time.Minute))
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+
+	claimed, err = repo.ClaimDueDelivery(ctx, retryAt, retryAt.Add(time.Minute))
+	testhelpers.Ok(t, err, "failed to claim delivery for retry")
+	testhelpers.Equals(t, 2, claimed.Attempts)
+
+	err = repo.RecordDeliveryAttempt(ctx, store.DeliveryAttemptParams{
+		IDDelivery:     idDelivery,
+		Status:         store.WebhookDeliveryStatusSucceeded,
+		ResponseStatus: 204,
+		AttemptedAt:    retryAt,
+		NextAttemptAt:  retryAt,
+	})
+	testhelpers.Ok(t, err, "failed to record successful attempt")
+
+	deliveries := make([]store.DeliveryResult, 0)
+	err = repo.GetDeliveries(ctx, idParty, 10, func(res store.DeliveryResult) {
+		deliveries = append(deliveries, res)
+	})
+	testhelpers.Ok(t, err, "failed to get deliveries")
+	testhelpers.Equals(t, 1, len(deliveries))
+	testhelpers.Equals(t, store.WebhookDeliveryStatusSucceeded, deliveries[0].Status)
+	testhelpers.Equals(t, 2, deliveries[0].Attempts)
+	testhelpers.Equals(t, 204, deliveries[0].ResponseStatus)
+	testhelpers.Equals(t, "", deliveries[0].LastError)
+
+	err = repo.DeleteWebhook(ctx, idParty, idWebhook)
+	testhelpers.Ok(t, err, "failed to delete webhook")
+
+	err = repo.DeleteWebhook(ctx, idParty, idWebhook)
+	testhelpers.Assert(t, errors.Is(err, store.ErrNoRecord), "expected %v, got %v", store.ErrNoRecord, err)
+}
@@ -9,21 +9,68 @@ import (
 	"log/slog"
 	"net/http"
 	"net/url"
+	"slices"
+	"sync"
 
 	"github.com/hashicorp/go-retryablehttp"
 	"github.com/jm96441n/movieswithfriends/metrics"
 	"github.com/jm96441n/movieswithfriends/partymgmt/store"
 )
 
+// DefaultLanguage is the language used for TMDB requests when a user hasn't picked one
+const DefaultLanguage = "en"
+
 type TMDBClient struct {
 	client     *retryablehttp.Client
 	tmdbKey    string
 	baseURL    string
-	genreCache genreCache
+	genreCache *genreCache
 }
 
+// genreCache holds the genres for every language that has been requested, keyed by language and then genre id
 type genreCache struct {
-	genres map[int]Genre
+	mu     sync.RWMutex
+	genres map[string]map[int]Genre
+}
+
+func newGenreCache() *genreCache {
+	return &genreCache{genres: make(map[string]map[int]Genre)}
+}
+
+func (c *genreCache) get(language string, genreID int) (Genre, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	genre, ok := c.genres[language][genreID]
+	return genre, ok
+}
+
+func (c *genreCache) hasLanguage(language string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.genres[language]
+	return ok
+}
+
+// set replaces all of the genres for a language so genres removed upstream are dropped as well
+func (c *genreCache) set(language string, genres []Genre) {
+	byID := make(map[int]Genre, len(genres))
+	for _, genre := range genres {
+		byID[genre.ID] = genre
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.genres[language] = byID
+}
+
+func (c *genreCache) languages() []string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	languages := make([]string, 0, len(c.genres))
+	for language := range c.genres {
+		languages = append(languages, language)
+	}
+	return languages
 }
 
 type trailerResults struct {
@@ -53,6 +100,8 @@ type TMDBMovie struct {
 	GenreIDs    []int   `json:"genre_ids"`
 	TMDBID      int     `json:"id"`
 	Budget      int     `json:"budget"`
+	// Language is the language the movie details were requested in
+	Language string `json:"-"`
 }
 
 type Genre struct {
@@ -60,61 +109,129 @@ type Genre struct {
 	Name string `json:"name"`
 }
 
-func NewTMDBClient(baseURL, apiKey string, logger *slog.Logger) (*TMDBClient, error) {
+// NewTMDBClient returns a client with the default language's genres already cached, when they can't be fetched the
+// cache is left empty and filled the first time a genre is asked for
+func NewTMDBClient(baseURL, apiKey string, logger *slog.Logger) *TMDBClient {
 	httpClient := retryablehttp.NewClient()
 	httpClient.Logger = logger
 	client := &TMDBClient{
 		client:     httpClient,
 		baseURL:    baseURL,
 		tmdbKey:    apiKey,
-		genreCache: genreCache{genres: make(map[int]Genre)},
+		genreCache: newGenreCache(),
 	}
-	err := client.fillCache()
+	err := client.fillCache(context.Background(), DefaultLanguage)
 	if err != nil {
-		return nil, errors.New("failed to fill cache")
+		logger.Error("failed to fill genre cache", slog.Any("err", err))
 	}
-	return client, nil
+	return client
 }
 
-func (t *TMDBClient) GetGenre(genreID int) (Genre, error) {
-	if genre, ok := t.genreCache.genres[genreID]; ok {
+var ErrGenreNotFound = errors.New("genre not found")
+
+// GetGenre returns the genre in the requested language, the genres for a language are fetched the first time that
+// language is requested. If the genre has no translation it falls back to the default language.
+func (t *TMDBClient) GetGenre(ctx context.Context, language string, genreID int) (Genre, error) {
+	if language == "" {
+		language = DefaultLanguage
+	}
+
+	var fillErr error
+	if !t.genreCache.hasLanguage(language) {
+		fillErr = t.fillCache(ctx, language)
+		// there's nothing to fall back to when the default language can't be fetched, other languages fall back to
+		// the default language's genres below
+		if fillErr != nil && language == DefaultLanguage {
+			return Genre{}, fillErr
+		}
+	}
+
+	if genre, ok := t.genreCache.get(language, genreID); ok {
 		return genre, nil
 	}
-	return Genre{}, fmt.Errorf("genre not found")
+
+	if genre, ok := t.genreCache.get(DefaultLanguage, genreID); ok {
+		return genre, nil
+	}
+
+	if fillErr != nil {
+		return Genre{}, fillErr
+	}
+
+	return Genre{}, fmt.Errorf("%w: %d", ErrGenreNotFound, genreID)
+}
+
+// LoadGenres seeds the cache for a language, used to warm the cache from genres that have been persisted
+func (t *TMDBClient) LoadGenres(language string, genres []Genre) {
+	if len(genres) == 0 {
+		return
+	}
+	t.genreCache.set(language, genres)
+}
+
+// RefreshGenres refetches the genres for every cached language along with any extra languages requested,
+// returning the fresh genres by language
+func (t *TMDBClient) RefreshGenres(ctx context.Context, extraLanguages ...string) (map[string][]Genre, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.RefreshGenres")
+	defer span.End()
+
+	languages := t.genreCache.languages()
+	for _, language := range extraLanguages {
+		if !slices.Contains(languages, language) {
+			languages = append(languages, language)
+		}
+	}
+
+	refreshed := make(map[string][]Genre, len(languages))
+	var errs error
+	for _, language := range languages {
+		genres, err := t.FetchGenres(ctx, language)
+		if err != nil {
+			errs = errors.Join(errs, fmt.Errorf("refreshing genres for %q: %w", language, err))
+			continue
+		}
+		t.genreCache.set(language, genres)
+		refreshed[language] = genres
+	}
+
+	return refreshed, errs
 }
 
 type GenreList struct {
 	Genres []Genre `json:"genres"`
 }
 
-func (t *TMDBClient) fillCache() error {
-	req, err := t.newRequest(context.Background(), http.MethodGet, fmt.Sprintf("%s/genre/movie/list?language=en", t.baseURL))
-	if err != nil {
-		return err
-	}
-	res, err := t.client.Do(req)
+// ErrNoGenres is returned when TMDB responds without any genres, an empty list is never a real answer so it is
+// treated as a failure rather than replacing the genres that are already cached
+var ErrNoGenres = errors.New("tmdb returned no genres")
+
+// FetchGenres requests the list of movie genres for a language from TMDB
+func (t *TMDBClient) FetchGenres(ctx context.Context, language string) ([]Genre, error) {
+	genres := GenreList{}
+	err := t.getJSON(ctx, fmt.Sprintf("%s/genre/movie/list?language=%s", t.baseURL, url.QueryEscape(language)), &genres)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer res.Body.Close()
-	respBody, err := io.ReadAll(res.Body)
-	if err != nil {
-		return err
+
+	if len(genres.Genres) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrNoGenres, language)
 	}
-	genres := GenreList{}
-	err = json.Unmarshal(respBody, &genres)
+
+	return genres.Genres, nil
+}
+
+func (t *TMDBClient) fillCache(ctx context.Context, language string) error {
+	genres, err := t.FetchGenres(ctx, language)
 	if err != nil {
 		return err
 	}
-	for _, genre := range genres.Genres {
-		t.genreCache.genres[genre.ID] = genre
-	}
+	t.genreCache.set(language, genres)
 	return nil
 }
 
-func (t *TMDBClient) Search(ctx context.Context, term string, page int) (SearchResults, error) {
+func (t *TMDBClient) Search(ctx context.Context, term string, page int, language string) (SearchResults, error) {
 	term = url.QueryEscape(term)
-	req, err := t.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/search/movie?query=%s&page=%d", t.baseURL, term, page))
+	req, err := t.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/search/movie?query=%s&page=%d&language=%s", t.baseURL, term, page, url.QueryEscape(language)))
 	if err != nil {
 		return SearchResults{}, err
 	}
@@ -140,10 +257,10 @@ func (t *TMDBClient) Search(ctx context.Context, term string, page int) (SearchR
 	return result, nil
 }
 
-func (t *TMDBClient) GetMovie(ctx context.Context, id int) (*TMDBMovie, error) {
+func (t *TMDBClient) GetMovie(ctx context.Context, id int, language string) (*TMDBMovie, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.GetMovie")
 	defer span.End()
-	req, err := t.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/movie/%d?append_to_response=credits", t.baseURL, id))
+	req, err := t.newRequest(ctx, http.MethodGet, fmt.Sprintf("%s/movie/%d?append_to_response=credits&language=%s", t.baseURL, id, url.QueryEscape(language)))
 	if err != nil {
 		return nil, err
 	}
@@ -159,7 +276,7 @@ func (t *TMDBClient) GetMovie(ctx context.Context, id int) (*TMDBMovie, error) {
 		return nil, err
 	}
 
-	result := &TMDBMovie{}
+	result := &TMDBMovie{Language: language}
 	err = json.Unmarshal(respBody, result)
 	if err != nil {
 		return nil, err
@@ -203,6 +320,166 @@ func (t *TMDBClient) GetMovie(ctx context.Context, id int) (*TMDBMovie, error) {
 	return result, nil
 }
 
+// SearchByYear searches for movies with a title released in a year, a year of 0 searches every year
+func (t *TMDBClient) SearchByYear(ctx context.Context, term string, year int, language string) ([]TMDBMovie, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.SearchByYear")
+	defer span.End()
+
+	reqURL := fmt.Sprintf("%s/search/movie?query=%s&page=1&language=%s", t.baseURL, url.QueryEscape(term), url.QueryEscape(language))
+	if year > 0 {
+		reqURL = fmt.Sprintf("%s&primary_release_year=%d", reqURL, year)
+	}
+
+	result := SearchResults{}
+	err := t.getJSON(ctx, reqURL, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Movies, nil
+}
+
+type findResults struct {
+	Movies []TMDBMovie `json:"movie_results"`
+}
+
+// FindByIMDbID returns the movies TMDB has linked to an IMDb id, there's normally only one but nothing stops TMDB
+// from linking more
+func (t *TMDBClient) FindByIMDbID(ctx context.Context, imdbID string) ([]TMDBMovie, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.FindByIMDbID")
+	defer span.End()
+
+	result := findResults{}
+	err := t.getJSON(ctx, fmt.Sprintf("%s/find/%s?external_source=imdb_id", t.baseURL, url.PathEscape(imdbID)), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Movies, nil
+}
+
+// GetRecommendations returns the movies TMDB recommends to people who liked a movie
+func (t *TMDBClient) GetRecommendations(ctx context.Context, tmdbID int, language string) ([]TMDBMovie, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.GetRecommendations")
+	defer span.End()
+
+	result := SearchResults{}
+	err := t.getJSON(ctx, fmt.Sprintf("%s/movie/%d/recommendations?page=1&language=%s", t.baseURL, tmdbID, url.QueryEscape(language)), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Movies, nil
+}
+
+// GetSimilar returns the movies TMDB considers similar to a movie based on its genres and keywords
+func (t *TMDBClient) GetSimilar(ctx context.Context, tmdbID int, language string) ([]TMDBMovie, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.GetSimilar")
+	defer span.End()
+
+	result := SearchResults{}
+	err := t.getJSON(ctx, fmt.Sprintf("%s/movie/%d/similar?page=1&language=%s", t.baseURL, tmdbID, url.QueryEscape(language)), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Movies, nil
+}
+
+// WatchProvider is a streaming, rental or purchase service a movie is available on
+type WatchProvider struct {
+	ID              int    `json:"provider_id"`
+	Name            string `json:"provider_name"`
+	LogoPath        string `json:"logo_path"`
+	DisplayPriority int    `json:"display_priority"`
+}
+
+func (w WatchProvider) LogoURL() string {
+	if w.LogoPath == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://image.tmdb.org/t/p/w92%s", w.LogoPath)
+}
+
+// RegionWatchProviders are the providers for a movie within a single region grouped by how the movie is offered
+type RegionWatchProviders struct {
+	Link     string          `json:"link"`
+	Flatrate []WatchProvider `json:"flatrate"`
+	Free     []WatchProvider `json:"free"`
+	Ads      []WatchProvider `json:"ads"`
+	Rent     []WatchProvider `json:"rent"`
+	Buy      []WatchProvider `json:"buy"`
+}
+
+type watchProvidersResult struct {
+	Results map[string]RegionWatchProviders `json:"results"`
+}
+
+// GetWatchProviders returns where a movie can be watched keyed by region
+func (t *TMDBClient) GetWatchProviders(ctx context.Context, tmdbID int) (map[string]RegionWatchProviders, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.GetWatchProviders")
+	defer span.End()
+
+	result := watchProvidersResult{}
+	err := t.getJSON(ctx, fmt.Sprintf("%s/movie/%d/watch/providers", t.baseURL, tmdbID), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Results, nil
+}
+
+type watchProviderList struct {
+	Results []WatchProvider `json:"results"`
+}
+
+// ListWatchProviders returns every movie provider TMDB knows about in a region, ordered by how prominent they are
+func (t *TMDBClient) ListWatchProviders(ctx context.Context, region string) ([]WatchProvider, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "TMDBClient.ListWatchProviders")
+	defer span.End()
+
+	result := watchProviderList{}
+	err := t.getJSON(ctx, fmt.Sprintf("%s/watch/providers/movie?watch_region=%s", t.baseURL, url.QueryEscape(region)), &result)
+	if err != nil {
+		return nil, err
+	}
+
+	slices.SortStableFunc(result.Results, func(a, b WatchProvider) int {
+		return a.DisplayPriority - b.DisplayPriority
+	})
+
+	return result.Results, nil
+}
+
+var ErrUnexpectedTMDBStatus = errors.New("unexpected status from tmdb")
+
+// getJSON requests url and decodes the response into dst, a non 200 response is returned as an error so an empty
+// body isn't mistaken for an empty result
+func (t *TMDBClient) getJSON(ctx context.Context, url string, dst any) error {
+	req, err := t.newRequest(ctx, http.MethodGet, url)
+	if err != nil {
+		return err
+	}
+
+	res, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("%w: %d", ErrUnexpectedTMDBStatus, res.StatusCode)
+	}
+
+	respBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(respBody, dst)
+}
+
 func (t *TMDBClient) newRequest(ctx context.Context, method, url string) (*retryablehttp.Request, error) {
 	req, err := retryablehttp.NewRequestWithContext(ctx, method, url, nil)
 	if err != nil {
@@ -215,8 +492,15 @@ func (t *TMDBClient) newRequest(ctx context.Context, method, url string) (*retry
 
 func (t *TMDBMovie) ToStoreMovie() store.CreateMovieParams {
 	genres := make([]string, len(t.Genres))
+	genreIDs := make([]int, len(t.Genres))
 	for i, genre := range t.Genres {
 		genres[i] = genre.Name
+		genreIDs[i] = genre.ID
+	}
+
+	language := t.Language
+	if language == "" {
+		language = DefaultLanguage
 	}
 
 	return store.CreateMovieParams{
@@ -230,6 +514,8 @@ func (t *TMDBMovie) ToStoreMovie() store.CreateMovieParams {
 		Runtime:     t.Runtime,
 		Rating:      t.Rating,
 		Genres:      genres,
+		GenreIDs:    genreIDs,
 		Budget:      t.Budget,
+		Language:    language,
 	}
 }
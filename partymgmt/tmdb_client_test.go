@@ -0,0 +1,305 @@
+package partymgmt_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+	"github.com/jm96441n/movieswithfriends/tmdbfake"
+)
+
+func newTestTMDBClient(t *testing.T) *partymgmt.TMDBClient {
+	t.Helper()
+	server := tmdbfake.NewServer(t, tmdbfake.WithAPIKey("test-key"))
+	return partymgmt.NewTMDBClient(server.URL+tmdbfake.BasePath, "test-key", slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestTMDBClient_Search(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestTMDBClient(t)
+
+	testCases := map[string]struct {
+		term           string
+		expectedTitles []string
+	}{
+		"multipleMatches": {
+			term:           "the matrix",
+			expectedTitles: []string{"The Matrix", "The Matrix Reloaded", "The Matrix Revolutions", "The Matrix Resurrections"},
+		},
+		"singleMatch": {
+			term:           "Inception",
+			expectedTitles: []string{"Inception"},
+		},
+		"noMatches": {
+			term:           "not a real movie",
+			expectedTitles: []string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			results, err := client.Search(ctx, tc.term, 1, partymgmt.DefaultLanguage)
+			testhelpers.Ok(t, err, "failed to search")
+
+			titles := make([]string, 0, len(results.Movies))
+			for _, movie := range results.Movies {
+				titles = append(titles, movie.Title)
+			}
+			testhelpers.Equals(t, tc.expectedTitles, titles)
+		})
+	}
+}
+
+func TestTMDBClient_GetMovie(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestTMDBClient(t)
+
+	movie, err := client.GetMovie(ctx, 603, "es")
+	testhelpers.Ok(t, err, "failed to get movie")
+
+	testhelpers.Equals(t, "The Matrix", movie.Title)
+	testhelpers.Equals(t, "https://www.youtube.com/watch?v=vKQi3bBA1y8", movie.TrailerURL)
+	testhelpers.Equals(t, []partymgmt.Genre{{ID: 28, Name: "Acción"}, {ID: 878, Name: "Ciencia ficción"}}, movie.Genres)
+	testhelpers.Equals(t, "es", movie.ToStoreMovie().Language)
+}
+
+func TestTMDBClient_GetGenre(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestTMDBClient(t)
+
+	testCases := map[string]struct {
+		language     string
+		genreID      int
+		expectedName string
+		expectedErr  error
+	}{
+		"defaultLanguage": {
+			language:     partymgmt.DefaultLanguage,
+			genreID:      878,
+			expectedName: "Science Fiction",
+		},
+		"emptyLanguageUsesDefault": {
+			language:     "",
+			genreID:      878,
+			expectedName: "Science Fiction",
+		},
+		"translatedLanguage": {
+			language:     "es",
+			genreID:      878,
+			expectedName: "Ciencia ficción",
+		},
+		"untranslatedLanguageFallsBack": {
+			language:     "ja",
+			genreID:      27,
+			expectedName: "Horror",
+		},
+		"unknownGenre": {
+			language:    partymgmt.DefaultLanguage,
+			genreID:     -1,
+			expectedErr: partymgmt.ErrGenreNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			genre, err := client.GetGenre(ctx, tc.language, tc.genreID)
+			if tc.expectedErr != nil {
+				testhelpers.Assert(t, errors.Is(err, tc.expectedErr), "expected error %v, got %v", tc.expectedErr, err)
+				return
+			}
+			testhelpers.Ok(t, err, "failed to get genre")
+			testhelpers.Equals(t, tc.expectedName, genre.Name)
+		})
+	}
+}
+
+func TestTMDBClient_GetWatchProviders(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestTMDBClient(t)
+
+	providers, err := client.GetWatchProviders(ctx, 603)
+	testhelpers.Ok(t, err, "failed to get watch providers")
+
+	us, ok := providers["US"]
+	testhelpers.Assert(t, ok, "expected providers for the US")
+	testhelpers.Equals(t, 1, len(us.Flatrate))
+	testhelpers.Equals(t, "Max", us.Flatrate[0].Name)
+	testhelpers.Equals(t, "https://image.tmdb.org/t/p/w92"+us.Flatrate[0].LogoPath, us.Flatrate[0].LogoURL())
+
+	_, err = client.GetWatchProviders(ctx, -1)
+	testhelpers.Assert(t, errors.Is(err, partymgmt.ErrUnexpectedTMDBStatus), "expected an unexpected status error, got %v", err)
+}
+
+func TestTMDBClient_ListWatchProviders(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestTMDBClient(t)
+
+	testCases := map[string]struct {
+		region        string
+		expectedFirst string
+		expectedCount int
+	}{
+		"US": {
+			region:        "US",
+			expectedFirst: "Netflix",
+			expectedCount: 9,
+		},
+		"Germany": {
+			region:        "DE",
+			expectedFirst: "Netflix",
+			expectedCount: 5,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			providers, err := client.ListWatchProviders(ctx, tc.region)
+			testhelpers.Ok(t, err, "failed to list watch providers")
+			testhelpers.Equals(t, tc.expectedCount, len(providers))
+			testhelpers.Equals(t, tc.expectedFirst, providers[0].Name)
+		})
+	}
+}
+
+func TestTMDBClient_GetRelatedMovies(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	client := newTestTMDBClient(t)
+
+	testCases := map[string]struct {
+		fetch          func(ctx context.Context, tmdbID int, language string) ([]partymgmt.TMDBMovie, error)
+		tmdbID         int
+		expectedTitles []string
+	}{
+		"recommendations": {
+			fetch:          client.GetRecommendations,
+			tmdbID:         27205,
+			expectedTitles: []string{"Interstellar", "The Matrix", "Pulp Fiction"},
+		},
+		"similar": {
+			fetch:          client.GetSimilar,
+			tmdbID:         603,
+			expectedTitles: []string{"The Matrix Resurrections", "The Matrix Reloaded", "The Matrix Revolutions"},
+		},
+		"no related movies": {
+			fetch:          client.GetRecommendations,
+			tmdbID:         1234567,
+			expectedTitles: []string{},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			movies, err := tc.fetch(ctx, tc.tmdbID, "en-US")
+			testhelpers.Ok(t, err, "failed to get related movies")
+
+			titles := make([]string, 0, len(movies))
+			for _, movie := range movies {
+				titles = append(titles, movie.Title)
+			}
+			testhelpers.Equals(t, tc.expectedTitles, titles)
+		})
+	}
+}
+
+func TestTMDBClient_GetRelatedMovies_UnknownMovie(t *testing.T) {
+	t.Parallel()
+	client := newTestTMDBClient(t)
+
+	_, err := client.GetRecommendations(context.Background(), 999999999, "en-US")
+	testhelpers.Assert(t, errors.Is(err, partymgmt.ErrUnexpectedTMDBStatus), "expected ErrUnexpectedTMDBStatus, got %v", err)
+}
+
+// newFlakyGenreServer serves English genres until failing is set, every other language and every request after that
+// gets status back
+func newFlakyGenreServer(t *testing.T, status int, failing *atomic.Bool) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failing.Load() || r.URL.Query().Get("language") != partymgmt.DefaultLanguage {
+			w.WriteHeader(status)
+			fmt.Fprint(w, `{"status_message":"nope","genres":[]}`)
+			return
+		}
+		fmt.Fprint(w, `{"genres":[{"id":28,"name":"Action"},{"id":878,"name":"Science Fiction"}]}`)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestTMDBClient_GenresOnErrorStatus(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	t.Run("failingOnStartup", func(t *testing.T) {
+		t.Parallel()
+		failing := &atomic.Bool{}
+		failing.Store(true)
+		server := newFlakyGenreServer(t, http.StatusUnauthorized, failing)
+		client := partymgmt.NewTMDBClient(server.URL, "bad-key", logger)
+
+		_, err := client.GetGenre(ctx, partymgmt.DefaultLanguage, 28)
+		testhelpers.Assert(t, errors.Is(err, partymgmt.ErrUnexpectedTMDBStatus), "expected ErrUnexpectedTMDBStatus, got %v", err)
+
+		// the cache fills itself once TMDB can be reached
+		failing.Store(false)
+		genre, err := client.GetGenre(ctx, partymgmt.DefaultLanguage, 28)
+		testhelpers.Ok(t, err, "expected the genres to be fetched lazily")
+		testhelpers.Equals(t, "Action", genre.Name)
+	})
+
+	t.Run("otherLanguageFallsBackToDefault", func(t *testing.T) {
+		t.Parallel()
+		server := newFlakyGenreServer(t, http.StatusUnauthorized, &atomic.Bool{})
+		client := partymgmt.NewTMDBClient(server.URL, "test-key", logger)
+
+		genre, err := client.GetGenre(ctx, "es", 878)
+		testhelpers.Ok(t, err, "expected to fall back to the default language")
+		testhelpers.Equals(t, "Science Fiction", genre.Name)
+	})
+
+	t.Run("refreshKeepsCachedGenres", func(t *testing.T) {
+		t.Parallel()
+		failing := &atomic.Bool{}
+		server := newFlakyGenreServer(t, http.StatusUnauthorized, failing)
+		client := partymgmt.NewTMDBClient(server.URL, "test-key", logger)
+
+		failing.Store(true)
+		refreshed, err := client.RefreshGenres(ctx)
+		testhelpers.Assert(t, errors.Is(err, partymgmt.ErrUnexpectedTMDBStatus), "expected ErrUnexpectedTMDBStatus, got %v", err)
+		testhelpers.Equals(t, 0, len(refreshed))
+
+		genre, err := client.GetGenre(ctx, partymgmt.DefaultLanguage, 28)
+		testhelpers.Ok(t, err, "expected the cached genres to survive a failed refresh")
+		testhelpers.Equals(t, "Action", genre.Name)
+	})
+
+	t.Run("emptyGenreList", func(t *testing.T) {
+		t.Parallel()
+		failing := &atomic.Bool{}
+		failing.Store(true)
+		server := newFlakyGenreServer(t, http.StatusOK, failing)
+
+		client := partymgmt.NewTMDBClient(server.URL, "test-key", logger)
+
+		_, err := client.GetGenre(ctx, partymgmt.DefaultLanguage, 28)
+		testhelpers.Assert(t, errors.Is(err, partymgmt.ErrNoGenres), "expected ErrNoGenres, got %v", err)
+	})
+}
@@ -0,0 +1,173 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrCannotRemoveMovie   = errors.New("only the member who added the movie or the party's owner can remove it")
+	ErrMovieAlreadyWatched = errors.New("the party has watched the movie so it can't be removed")
+	ErrCannotLiftVeto      = errors.New("only the member who vetoed the movie or the party's owner can lift the veto")
+	ErrVetoNotFound        = errors.New("veto not found")
+)
+
+// Veto is a member stopping a movie from being picked for the party
+type Veto struct {
+	IDWatcher int
+	Name      FullName
+}
+
+// RemoveMovie takes a movie the party hasn't watched out of it, only the member who added it or the party's owner can
+// remove it
+func (s PartyService) RemoveMovie(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "PartyService.RemoveMovie")
+	defer span.End()
+
+	owners, err := s.getPartyMovieOwners(ctx, idParty, idMovie, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if idWatcher != owners.IDAddedBy && idWatcher != owners.IDPartyOwner {
+		return ErrCannotRemoveMovie
+	}
+
+	if owners.Watched {
+		return ErrMovieAlreadyWatched
+	}
+
+	err = s.db.DeletePartyMovie(ctx, idParty, idMovie)
+	// it was watched or removed by someone else in the meantime
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrPartyMovieNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to remove movie from party", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// VetoMovie stops the movie from being picked for the party until the watcher lifts their veto, vetoing the movie
+// that's picked un-picks it
+func (s PartyService) VetoMovie(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "PartyService.VetoMovie")
+	defer span.End()
+
+	err := checkPartyMembership(ctx, s.db, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.RunInTransaction(ctx, func(ctx context.Context, db store.PartyRepository) error {
+		status, err := getPartyMovieStatus(ctx, db, idParty, idMovie)
+		if err != nil {
+			return err
+		}
+
+		err = db.VetoPartyMovie(ctx, idParty, idMovie, idWatcher)
+		if err != nil {
+			return err
+		}
+
+		if status.Status != store.WatchStatusSelected {
+			return nil
+		}
+
+		return changeMovieStatus(ctx, logger, db, idParty, idMovie, idWatcher, store.StatusChangeUnselect, func(ctx context.Context, db store.PartyRepository) error {
+			return db.UnselectMovieForParty(ctx, idParty, idMovie)
+		})
+	})
+	if err != nil && !errors.Is(err, ErrPartyMovieNotFound) {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to veto movie", slog.Any("error", err))
+	}
+
+	return err
+}
+
+// LiftVeto lets the movie be picked again as far as idVetoedBy is concerned, only they or the party's owner can lift
+// their veto
+func (s PartyService) LiftVeto(ctx context.Context, logger *slog.Logger, idParty, idMovie, idVetoedBy, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "PartyService.LiftVeto")
+	defer span.End()
+
+	owners, err := s.getPartyMovieOwners(ctx, idParty, idMovie, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if idWatcher != idVetoedBy && idWatcher != owners.IDPartyOwner {
+		return ErrCannotLiftVeto
+	}
+
+	err = s.db.LiftVeto(ctx, idParty, idMovie, idVetoedBy)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrVetoNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to lift veto", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// LoadVetoes fills in who has vetoed each of the unwatched movies, the selected movie is never vetoed since vetoing it
+// un-picks it
+func (p *Party) LoadVetoes(ctx context.Context) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "Party.LoadVetoes")
+	defer span.End()
+
+	vetoesByMovie := make(map[int][]Veto)
+	err := p.db.GetVetoes(ctx, p.ID, func(res store.VetoResult) {
+		vetoesByMovie[res.IDMovie] = append(vetoesByMovie[res.IDMovie], Veto{
+			IDWatcher: res.IDWatcher,
+			Name:      FullName{FirstName: res.FirstName, LastName: res.LastName},
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for idx := range p.MoviesByStatus.UnwatchedMovies {
+		movie := &p.MoviesByStatus.UnwatchedMovies[idx]
+		movie.Vetoes = vetoesByMovie[movie.ID]
+	}
+
+	return nil
+}
+
+// VetoedBy returns whether the watcher has vetoed the movie
+func (m PartyMovie) VetoedBy(idWatcher int) bool {
+	for _, veto := range m.Vetoes {
+		if veto.IDWatcher == idWatcher {
+			return true
+		}
+	}
+	return false
+}
+
+func (s PartyService) getPartyMovieOwners(ctx context.Context, idParty, idMovie, idWatcher int) (store.PartyMovieOwnersResult, error) {
+	err := checkPartyMembership(ctx, s.db, idParty, idWatcher)
+	if err != nil {
+		return store.PartyMovieOwnersResult{}, err
+	}
+
+	owners, err := s.db.GetPartyMovieOwners(ctx, idParty, idMovie)
+	if errors.Is(err, store.ErrNoRecord) {
+		return store.PartyMovieOwnersResult{}, ErrPartyMovieNotFound
+	}
+
+	return owners, err
+}
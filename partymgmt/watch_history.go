@@ -0,0 +1,308 @@
+package partymgmt
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+const (
+	DefaultWatchHistoryPageSize = 10
+	MaxMovieRating              = 5
+)
+
+// WatchHistoryPageSizes are the page sizes that can be picked for the watch history
+var WatchHistoryPageSizes = []int{5, 10, 25, 50}
+
+var (
+	ErrInvalidWatchHistoryCursor = errors.New("invalid watch history cursor")
+	ErrInvalidRating             = fmt.Errorf("ratings must be between 1 and %d", MaxMovieRating)
+	ErrCannotRateMovie           = errors.New("the movie hasn't been watched by a party the watcher is in")
+	ErrCannotSetAttendance       = errors.New("the movie hasn't been watched by a party the watcher is in")
+)
+
+type WatchHistorySort = store.WatchHistorySortColumn
+
+const (
+	WatchHistorySortWatchDate = store.WatchHistorySortWatchDate
+	WatchHistorySortTitle     = store.WatchHistorySortTitle
+	WatchHistorySortRuntime   = store.WatchHistorySortRuntime
+)
+
+// WatchHistoryQuery is the filters, sort and page for a watch history, the zero value is the newest movies watched
+// across every party
+type WatchHistoryQuery struct {
+	IDParty int
+	IDGenre int
+	// WatchedFrom and WatchedTo are dates, both days are included
+	WatchedFrom *time.Time
+	WatchedTo   *time.Time
+	MinRating   int
+	Search      string
+	Sort        WatchHistorySort
+	Ascending   bool
+	PageSize    int
+	// After and Before are cursors from a WatchHistoryPage
+	After  string
+	Before string
+}
+
+// Normalize fills in defaults and drops anything out of range so a query built from user input is always usable
+func (q WatchHistoryQuery) Normalize() WatchHistoryQuery {
+	switch q.Sort {
+	case WatchHistorySortWatchDate, WatchHistorySortTitle, WatchHistorySortRuntime:
+	default:
+		q.Sort = WatchHistorySortWatchDate
+	}
+
+	if !slices.Contains(WatchHistoryPageSizes, q.PageSize) {
+		q.PageSize = DefaultWatchHistoryPageSize
+	}
+
+	if q.MinRating < 0 || q.MinRating > MaxMovieRating {
+		q.MinRating = 0
+	}
+
+	q.Search = strings.TrimSpace(q.Search)
+
+	if q.WatchedFrom != nil && q.WatchedTo != nil && q.WatchedTo.Before(*q.WatchedFrom) {
+		q.WatchedFrom, q.WatchedTo = q.WatchedTo, q.WatchedFrom
+	}
+
+	return q
+}
+
+// IsFiltered is true when anything other than the sort or page has been picked
+func (q WatchHistoryQuery) IsFiltered() bool {
+	return q.IDParty != 0 || q.IDGenre != 0 || q.WatchedFrom != nil || q.WatchedTo != nil || q.MinRating != 0 || q.Search != ""
+}
+
+type WatchHistoryPage struct {
+	Movies []PartyMovie
+	Query  WatchHistoryQuery
+	// NextCursor and PrevCursor are used as After and Before to get the pages on either side, they're empty when there
+	// isn't a page in that direction
+	NextCursor string
+	PrevCursor string
+}
+
+// GetWatchHistory returns a page of the movies watched by the parties the watcher is in
+func (w Watcher) GetWatchHistory(ctx context.Context, logger *slog.Logger, query WatchHistoryQuery) (WatchHistoryPage, error) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "Watcher.GetWatchHistory")
+	defer span.End()
+
+	query = query.Normalize()
+
+	params := store.WatchHistoryParams{
+		IDWatcher:  w.ID,
+		IDParty:    query.IDParty,
+		IDGenre:    query.IDGenre,
+		MinRating:  query.MinRating,
+		Search:     query.Search,
+		Sort:       query.Sort,
+		Descending: !query.Ascending,
+		// one extra row is read to know if there's another page
+		Limit: query.PageSize + 1,
+	}
+
+	if query.WatchedFrom != nil {
+		from := startOfDay(*query.WatchedFrom)
+		params.WatchedFrom = &from
+	}
+
+	if query.WatchedTo != nil {
+		before := startOfDay(*query.WatchedTo).AddDate(0, 0, 1)
+		params.WatchedBefore = &before
+	}
+
+	var err error
+	switch {
+	case query.After != "":
+		params.After, err = decodeWatchHistoryCursor(query.After, query.Sort)
+	case query.Before != "":
+		params.Before, err = decodeWatchHistoryCursor(query.Before, query.Sort)
+	}
+
+	// a cursor from a different sort or one that's been tampered with just starts over from the first page
+	if err != nil {
+		logger.DebugContext(ctx, "ignoring watch history cursor", slog.Any("error", err))
+		query.After, query.Before = "", ""
+		params.After, params.Before = nil, nil
+	}
+
+	results := make([]store.WatchHistoryResult, 0, params.Limit)
+	err = w.db.GetWatchHistory(ctx, params, func(res store.WatchHistoryResult) {
+		results = append(results, res)
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get watch history", slog.Any("error", err))
+		return WatchHistoryPage{}, err
+	}
+
+	hasMore := len(results) > query.PageSize
+	if hasMore {
+		// when paging backwards the extra row is the one furthest back, which comes first
+		if params.Before != nil {
+			results = results[1:]
+		} else {
+			results = results[:query.PageSize]
+		}
+	}
+
+	page := WatchHistoryPage{
+		Movies: make([]PartyMovie, 0, len(results)),
+		Query:  query,
+	}
+
+	for _, res := range results {
+		page.Movies = append(page.Movies, PartyMovie{
+			ID:        res.IDMovie,
+			Title:     res.Title,
+			Runtime:   res.Runtime,
+			WatchDate: res.WatchDate,
+			IDParty:   res.IDParty,
+			PartyName: res.PartyName,
+			OwnRating: res.Rating,
+		})
+	}
+
+	if len(results) == 0 {
+		return page, nil
+	}
+
+	first := encodeWatchHistoryCursor(query.Sort, results[0].Cursor)
+	last := encodeWatchHistoryCursor(query.Sort, results[len(results)-1].Cursor)
+
+	switch {
+	case params.Before != nil:
+		page.NextCursor = last
+		if hasMore {
+			page.PrevCursor = first
+		}
+	case params.After != nil:
+		page.PrevCursor = first
+		if hasMore {
+			page.NextCursor = last
+		}
+	default:
+		if hasMore {
+			page.NextCursor = last
+		}
+	}
+
+	return page, nil
+}
+
+// RateMovie sets the watcher's rating, from 1 to 5, for a movie one of their parties has watched
+func (w Watcher) RateMovie(ctx context.Context, idParty, idMovie, rating int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "Watcher.RateMovie")
+	defer span.End()
+
+	if rating < 1 || rating > MaxMovieRating {
+		return ErrInvalidRating
+	}
+
+	err := w.db.RateMovie(ctx, idParty, idMovie, w.ID, rating)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrCannotRateMovie
+	}
+
+	return err
+}
+
+// SetAttendance records whether the watcher was there when one of their parties watched a movie, only the movies
+// they were there for count towards their watch history and stats
+func (w Watcher) SetAttendance(ctx context.Context, idParty, idMovie int, attended bool) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "Watcher.SetAttendance")
+	defer span.End()
+
+	err := w.db.SetAttendance(ctx, idParty, idMovie, w.ID, attended)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrCannotSetAttendance
+	}
+
+	return err
+}
+
+// GetPartyNames returns every party the watcher is in with only the id and name set
+func (w Watcher) GetPartyNames(ctx context.Context) ([]Party, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "Watcher.GetPartyNames")
+	defer span.End()
+
+	parties := make([]Party, 0)
+	err := w.db.GetPartyNamesForWatcher(ctx, w.ID, func(id int, name string) {
+		parties = append(parties, Party{ID: id, Name: name})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return parties, nil
+}
+
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, t.Location())
+}
+
+// cursors are the sort, the id of the viewing and the value being sorted on joined together, the value goes last
+// since it's the only part that can have the separator in it
+const watchHistoryCursorSeparator = "|"
+
+// postgresTimestampLayouts are the ways postgres writes a timestamptz as text, the offset only has minutes when the
+// session's time zone isn't on the hour
+var postgresTimestampLayouts = []string{
+	"2006-01-02 15:04:05.999999-07",
+	"2006-01-02 15:04:05.999999-07:00",
+}
+
+func encodeWatchHistoryCursor(sort WatchHistorySort, cursor store.WatchHistoryCursor) string {
+	raw := strings.Join([]string{string(sort), strconv.Itoa(cursor.IDViewing), cursor.Value}, watchHistoryCursorSeparator)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeWatchHistoryCursor(encoded string, sort WatchHistorySort) (*store.WatchHistoryCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidWatchHistoryCursor, err)
+	}
+
+	parts := strings.SplitN(string(raw), watchHistoryCursorSeparator, 3)
+	if len(parts) != 3 || WatchHistorySort(parts[0]) != sort {
+		return nil, ErrInvalidWatchHistoryCursor
+	}
+
+	id, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidWatchHistoryCursor, err)
+	}
+
+	// the value is cast by the database, checking it here means a bad one is skipped instead of failing the query
+	switch sort {
+	case WatchHistorySortRuntime:
+		_, err = strconv.Atoi(parts[2])
+	case WatchHistorySortWatchDate:
+		err = ErrInvalidWatchHistoryCursor
+		for _, layout := range postgresTimestampLayouts {
+			if _, parseErr := time.Parse(layout, parts[2]); parseErr == nil {
+				err = nil
+				break
+			}
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidWatchHistoryCursor, err)
+	}
+
+	return &store.WatchHistoryCursor{Value: parts[2], IDViewing: id}, nil
+}
@@ -0,0 +1,100 @@
+package partymgmt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestWatchHistoryQueryNormalize(t *testing.T) {
+	t.Parallel()
+
+	jan := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	feb := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		query    partymgmt.WatchHistoryQuery
+		expected partymgmt.WatchHistoryQuery
+	}{
+		"defaults": {
+			query: partymgmt.WatchHistoryQuery{},
+			expected: partymgmt.WatchHistoryQuery{
+				Sort:     partymgmt.WatchHistorySortWatchDate,
+				PageSize: partymgmt.DefaultWatchHistoryPageSize,
+			},
+		},
+		"keeps valid values": {
+			query: partymgmt.WatchHistoryQuery{
+				Sort:      partymgmt.WatchHistorySortRuntime,
+				Ascending: true,
+				PageSize:  25,
+				MinRating: 4,
+				Search:    "matrix",
+			},
+			expected: partymgmt.WatchHistoryQuery{
+				Sort:      partymgmt.WatchHistorySortRuntime,
+				Ascending: true,
+				PageSize:  25,
+				MinRating: 4,
+				Search:    "matrix",
+			},
+		},
+		"drops out of range values": {
+			query: partymgmt.WatchHistoryQuery{
+				Sort:      "popularity",
+				PageSize:  1000,
+				MinRating: 6,
+				Search:    "  matrix ",
+			},
+			expected: partymgmt.WatchHistoryQuery{
+				Sort:     partymgmt.WatchHistorySortWatchDate,
+				PageSize: partymgmt.DefaultWatchHistoryPageSize,
+				Search:   "matrix",
+			},
+		},
+		"swaps a backwards date range": {
+			query: partymgmt.WatchHistoryQuery{
+				WatchedFrom: &feb,
+				WatchedTo:   &jan,
+			},
+			expected: partymgmt.WatchHistoryQuery{
+				Sort:        partymgmt.WatchHistorySortWatchDate,
+				PageSize:    partymgmt.DefaultWatchHistoryPageSize,
+				WatchedFrom: &jan,
+				WatchedTo:   &feb,
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testhelpers.Equals(t, tc.expected, tc.query.Normalize())
+		})
+	}
+}
+
+func TestWatchHistoryQueryIsFiltered(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		query    partymgmt.WatchHistoryQuery
+		expected bool
+	}{
+		"nothing picked":       {query: partymgmt.WatchHistoryQuery{}, expected: false},
+		"only sort and paging": {query: partymgmt.WatchHistoryQuery{Sort: partymgmt.WatchHistorySortTitle, PageSize: 50, After: "abc"}, expected: false},
+		"party":                {query: partymgmt.WatchHistoryQuery{IDParty: 1}, expected: true},
+		"genre":                {query: partymgmt.WatchHistoryQuery{IDGenre: 28}, expected: true},
+		"rating":               {query: partymgmt.WatchHistoryQuery{MinRating: 3}, expected: true},
+		"search":               {query: partymgmt.WatchHistoryQuery{Search: "alien"}, expected: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			testhelpers.Equals(t, tc.expected, tc.query.IsFiltered())
+		})
+	}
+}
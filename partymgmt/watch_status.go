@@ -0,0 +1,330 @@
+package partymgmt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrPartyMovieNotFound  = errors.New("movie is not in the party")
+	ErrInvalidStatusChange = errors.New("the movie can't be changed like that")
+	ErrWatchDateInFuture   = errors.New("the watch date can't be in the future")
+)
+
+// statusChangesLimit is how many of the latest changes to a party's movies are shown
+const statusChangesLimit = 10
+
+// watchStatusTransitions is the status a party movie moves to when each change is made to it, a change that isn't listed
+// for the movie's current status can't be made
+var watchStatusTransitions = map[store.WatchStatusEnum]map[store.StatusChangeEnum]store.WatchStatusEnum{
+	store.WatchStatusUnwatched: {
+		store.StatusChangeSelect: store.WatchStatusSelected,
+		store.StatusChangeWatch:  store.WatchStatusWatched,
+	},
+	store.WatchStatusSelected: {
+		store.StatusChangeUnselect: store.WatchStatusUnwatched,
+		store.StatusChangeWatch:    store.WatchStatusWatched,
+	},
+	store.WatchStatusWatched: {
+		store.StatusChangeSelect:        store.WatchStatusSelected,
+		store.StatusChangeWatch:         store.WatchStatusWatched,
+		store.StatusChangeUnwatch:       store.WatchStatusUnwatched,
+		store.StatusChangeEditWatchDate: store.WatchStatusWatched,
+	},
+}
+
+// NextWatchStatus returns the status a movie moves to when the change is made, viewings is how many times the party has
+// watched it. Un-selecting a rewatch or undoing a rewatch leaves the movie watched
+func NextWatchStatus(status store.WatchStatusEnum, change store.StatusChangeEnum, viewings int) (store.WatchStatusEnum, error) {
+	next, ok := watchStatusTransitions[status][change]
+	if !ok {
+		return "", fmt.Errorf("%w: can't %s a movie that's %s", ErrInvalidStatusChange, change, status)
+	}
+
+	switch {
+	case change == store.StatusChangeUnselect && viewings > 0:
+		return store.WatchStatusWatched, nil
+	case change == store.StatusChangeUnwatch && viewings > 1:
+		return store.WatchStatusWatched, nil
+	}
+
+	return next, nil
+}
+
+// MovieStatusChange is a change someone in the party made to one of its movies
+type MovieStatusChange struct {
+	IDMovie   int
+	Title     string
+	ChangedBy FullName
+	Change    store.StatusChangeEnum
+	// FromStatus and ToStatus are the movie's status before and after the change
+	FromStatus store.WatchStatusEnum
+	ToStatus   store.WatchStatusEnum
+	// FromWatchDate and ToWatchDate are when the movie was last watched before and after the change, nil when it hadn't
+	// been
+	FromWatchDate *time.Time
+	ToWatchDate   *time.Time
+	ChangedAt     time.Time
+}
+
+// Description is what was done to the movie, to be shown after who did it
+func (c MovieStatusChange) Description() string {
+	switch c.Change {
+	case store.StatusChangeSelect:
+		return "picked"
+	case store.StatusChangeUnselect:
+		return "un-picked"
+	case store.StatusChangeWatch:
+		return "marked as watched"
+	case store.StatusChangeUnwatch:
+		return "undid watching"
+	case store.StatusChangeEditWatchDate:
+		return "changed the watch date of"
+	default:
+		return string(c.Change)
+	}
+}
+
+// SelectMovie randomly picks the movie the party is watching next in place of the one that's picked, ErrNoRecord from the
+// store is returned when there's nothing that can be picked
+func (s PartyService) SelectMovie(ctx context.Context, logger *slog.Logger, idParty, idWatcher int, params store.SelectMovieParams) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.SelectMovie")
+	defer span.End()
+
+	err := checkPartyMembership(ctx, s.db, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	var idMovie int
+	err = s.db.RunInTransaction(ctx, func(ctx context.Context, db store.PartyRepository) error {
+		res, err := db.SelectMovieForParty(ctx, idParty, params)
+		if err != nil {
+			return err
+		}
+
+		idMovie = res.IDMovie
+
+		if res.IDUnselectedMovie != 0 {
+			err = recordStatusChange(ctx, db, idParty, res.IDUnselectedMovie, idWatcher, store.StatusChangeUnselect, store.WatchStatusSelected)
+			if err != nil {
+				logger.ErrorContext(ctx, "failed to record un-selecting the movie", slog.Any("error", err))
+				return err
+			}
+		}
+
+		// the query only picks movies that can be selected, this makes sure it never drifts from the transitions
+		_, err = NextWatchStatus(res.FromStatus, store.StatusChangeSelect, 0)
+		if err != nil {
+			return err
+		}
+
+		err = recordStatusChange(ctx, db, idParty, res.IDMovie, idWatcher, store.StatusChangeSelect, res.FromStatus)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to record selecting the movie", slog.Any("error", err))
+			return err
+		}
+
+		return db.RecordActivity(ctx, store.ActivityParams{IDParty: idParty, Type: store.ActivityTypeMovieSelected, IDActor: idWatcher, IDMovie: res.IDMovie})
+	})
+	if err != nil {
+		return err
+	}
+
+	publishPartyEvent(ctx, logger, s.db, s.events, PartyEvent{IDParty: idParty, Type: EventMovieSelected, IDWatcher: idWatcher, IDMovie: idMovie})
+	return nil
+}
+
+// UnselectMovie puts the picked movie back to how it was before it was picked
+func (s PartyService) UnselectMovie(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.UnselectMovie")
+	defer span.End()
+
+	return changeMovieStatus(ctx, logger, s.db, idParty, idMovie, idWatcher, store.StatusChangeUnselect, func(ctx context.Context, db store.PartyRepository) error {
+		return db.UnselectMovieForParty(ctx, idParty, idMovie)
+	})
+}
+
+// MarkMovieAsWatched records the party watching the movie on watchDate
+func (s PartyService) MarkMovieAsWatched(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher int, watchDate time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.MarkMovieAsWatched")
+	defer span.End()
+
+	err := markMovieAsWatched(ctx, logger, s.db, idParty, idMovie, idWatcher, watchDate)
+	if err != nil {
+		return err
+	}
+
+	publishPartyEvent(ctx, logger, s.db, s.events, PartyEvent{IDParty: idParty, Type: EventMovieWatched, IDWatcher: idWatcher, IDMovie: idMovie})
+	return nil
+}
+
+// UnwatchMovie undoes the latest time the party watched the movie, it goes back to being unwatched unless it's been
+// watched before that
+func (s PartyService) UnwatchMovie(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher int) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.UnwatchMovie")
+	defer span.End()
+
+	return changeMovieStatus(ctx, logger, s.db, idParty, idMovie, idWatcher, store.StatusChangeUnwatch, func(ctx context.Context, db store.PartyRepository) error {
+		return db.UnwatchPartyMovie(ctx, idParty, idMovie)
+	})
+}
+
+// EditWatchDate changes when the party last watched the movie, now is used to make sure the date isn't in the future
+func (s PartyService) EditWatchDate(ctx context.Context, logger *slog.Logger, idParty, idMovie, idWatcher int, watchDate, now time.Time) error {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.EditWatchDate")
+	defer span.End()
+
+	if watchDate.After(now) {
+		return ErrWatchDateInFuture
+	}
+
+	return changeMovieStatus(ctx, logger, s.db, idParty, idMovie, idWatcher, store.StatusChangeEditWatchDate, func(ctx context.Context, db store.PartyRepository) error {
+		return db.UpdateWatchDate(ctx, idParty, idMovie, watchDate)
+	})
+}
+
+// GetStatusChanges returns the latest changes members have made to the party's movies, newest first
+func (s PartyService) GetStatusChanges(ctx context.Context, idParty int) ([]MovieStatusChange, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "PartyService.GetStatusChanges")
+	defer span.End()
+
+	changes := make([]MovieStatusChange, 0, statusChangesLimit)
+	err := s.db.GetStatusChanges(ctx, idParty, statusChangesLimit, func(res store.StatusChangeResult) {
+		changes = append(changes, MovieStatusChange{
+			IDMovie:       res.IDMovie,
+			Title:         res.Title,
+			ChangedBy:     FullName{FirstName: res.FirstName, LastName: res.LastName},
+			Change:        res.Change,
+			FromStatus:    res.FromStatus,
+			ToStatus:      res.ToStatus,
+			FromWatchDate: res.FromWatchDate,
+			ToWatchDate:   res.ToWatchDate,
+			ChangedAt:     res.ChangedAt,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return changes, nil
+}
+
+// markMovieAsWatched is shared with completing a movie night so both end up in the audit log and the activity log
+func markMovieAsWatched(ctx context.Context, logger *slog.Logger, db store.PartyRepository, idParty, idMovie, idWatcher int, watchDate time.Time) error {
+	return changeMovieStatus(ctx, logger, db, idParty, idMovie, idWatcher, store.StatusChangeWatch, func(ctx context.Context, db store.PartyRepository) error {
+		err := db.MarkPartyMovieAsWatched(ctx, idParty, idMovie, watchDate)
+		if err != nil {
+			return err
+		}
+
+		return db.RecordActivity(ctx, store.ActivityParams{IDParty: idParty, Type: store.ActivityTypeMovieWatched, IDActor: idWatcher, IDMovie: idMovie})
+	})
+}
+
+// changeMovieStatus makes the change to the party's movie with it locked, apply is only called when the change can be
+// made from the movie's status and the change is recorded in the audit log along with it
+func changeMovieStatus(
+	ctx context.Context,
+	logger *slog.Logger,
+	db store.PartyRepository,
+	idParty, idMovie, idWatcher int,
+	change store.StatusChangeEnum,
+	apply func(context.Context, store.PartyRepository) error,
+) error {
+	err := checkPartyMembership(ctx, db, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	return db.RunInTransaction(ctx, func(ctx context.Context, db store.PartyRepository) error {
+		from, err := getPartyMovieStatus(ctx, db, idParty, idMovie)
+		if err != nil {
+			return err
+		}
+
+		next, err := NextWatchStatus(from.Status, change, from.Viewings)
+		if err != nil {
+			return err
+		}
+
+		err = apply(ctx, db)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to change movie status", slog.String("change", string(change)), slog.Any("error", err))
+			return err
+		}
+
+		to, err := getPartyMovieStatus(ctx, db, idParty, idMovie)
+		if err != nil {
+			return err
+		}
+
+		// what's stored is what's recorded, but the two disagreeing means the queries and transitions have drifted apart
+		if to.Status != next {
+			logger.WarnContext(ctx, "movie status doesn't match the transition", slog.String("change", string(change)), slog.String("expected", string(next)), slog.String("actual", string(to.Status)))
+		}
+
+		err = db.RecordStatusChange(ctx, store.StatusChangeParams{
+			IDParty:   idParty,
+			IDMovie:   idMovie,
+			IDWatcher: idWatcher,
+			Change:    change,
+			From:      from,
+			To:        to,
+		})
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to record movie status change", slog.String("change", string(change)), slog.Any("error", err))
+			return err
+		}
+
+		return nil
+	})
+}
+
+// recordStatusChange records a change that's already been made, fromStatus is the status the movie had before it
+func recordStatusChange(ctx context.Context, db store.PartyRepository, idParty, idMovie, idWatcher int, change store.StatusChangeEnum, fromStatus store.WatchStatusEnum) error {
+	to, err := getPartyMovieStatus(ctx, db, idParty, idMovie)
+	if err != nil {
+		return err
+	}
+
+	// picking or un-picking a movie doesn't change when it was watched
+	from := to
+	from.Status = fromStatus
+
+	return db.RecordStatusChange(ctx, store.StatusChangeParams{
+		IDParty:   idParty,
+		IDMovie:   idMovie,
+		IDWatcher: idWatcher,
+		Change:    change,
+		From:      from,
+		To:        to,
+	})
+}
+
+func getPartyMovieStatus(ctx context.Context, db store.PartyRepository, idParty, idMovie int) (store.PartyMovieStatusResult, error) {
+	status, err := db.GetPartyMovieStatus(ctx, idParty, idMovie)
+	if errors.Is(err, store.ErrNoRecord) {
+		return store.PartyMovieStatusResult{}, ErrPartyMovieNotFound
+	}
+	return status, err
+}
+
+func checkPartyMembership(ctx context.Context, db store.PartyRepository, idParty, idWatcher int) error {
+	isMember, err := db.IsPartyMember(ctx, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	if !isMember {
+		return ErrNotPartyMember
+	}
+
+	return nil
+}
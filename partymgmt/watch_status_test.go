@@ -0,0 +1,54 @@
+package partymgmt_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestNextWatchStatus(t *testing.T) {
+	t.Parallel()
+
+	testCases := map[string]struct {
+		status      store.WatchStatusEnum
+		change      store.StatusChangeEnum
+		viewings    int
+		expected    store.WatchStatusEnum
+		expectedErr error
+	}{
+		"pick an unwatched movie":       {status: store.WatchStatusUnwatched, change: store.StatusChangeSelect, expected: store.WatchStatusSelected},
+		"pick a watched movie":          {status: store.WatchStatusWatched, change: store.StatusChangeSelect, viewings: 1, expected: store.WatchStatusSelected},
+		"pick the picked movie":         {status: store.WatchStatusSelected, change: store.StatusChangeSelect, expectedErr: partymgmt.ErrInvalidStatusChange},
+		"un-pick a movie":               {status: store.WatchStatusSelected, change: store.StatusChangeUnselect, expected: store.WatchStatusUnwatched},
+		"un-pick a rewatch":             {status: store.WatchStatusSelected, change: store.StatusChangeUnselect, viewings: 2, expected: store.WatchStatusWatched},
+		"un-pick an unwatched movie":    {status: store.WatchStatusUnwatched, change: store.StatusChangeUnselect, expectedErr: partymgmt.ErrInvalidStatusChange},
+		"watch the picked movie":        {status: store.WatchStatusSelected, change: store.StatusChangeWatch, expected: store.WatchStatusWatched},
+		"watch an unwatched movie":      {status: store.WatchStatusUnwatched, change: store.StatusChangeWatch, expected: store.WatchStatusWatched},
+		"watch a watched movie again":   {status: store.WatchStatusWatched, change: store.StatusChangeWatch, viewings: 1, expected: store.WatchStatusWatched},
+		"undo the only watch":           {status: store.WatchStatusWatched, change: store.StatusChangeUnwatch, viewings: 1, expected: store.WatchStatusUnwatched},
+		"undo a rewatch":                {status: store.WatchStatusWatched, change: store.StatusChangeUnwatch, viewings: 2, expected: store.WatchStatusWatched},
+		"undo watching the picked one":  {status: store.WatchStatusSelected, change: store.StatusChangeUnwatch, viewings: 1, expectedErr: partymgmt.ErrInvalidStatusChange},
+		"undo watching an unwatched":    {status: store.WatchStatusUnwatched, change: store.StatusChangeUnwatch, expectedErr: partymgmt.ErrInvalidStatusChange},
+		"change the watch date":         {status: store.WatchStatusWatched, change: store.StatusChangeEditWatchDate, viewings: 1, expected: store.WatchStatusWatched},
+		"change date of an unwatched":   {status: store.WatchStatusUnwatched, change: store.StatusChangeEditWatchDate, expectedErr: partymgmt.ErrInvalidStatusChange},
+		"change date of the picked one": {status: store.WatchStatusSelected, change: store.StatusChangeEditWatchDate, viewings: 1, expectedErr: partymgmt.ErrInvalidStatusChange},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := partymgmt.NextWatchStatus(tc.status, tc.change, tc.viewings)
+			if tc.expectedErr != nil {
+				testhelpers.Assert(t, errors.Is(err, tc.expectedErr), "expected %v, got %v", tc.expectedErr, err)
+				return
+			}
+
+			testhelpers.Ok(t, err, "failed to get next status")
+			testhelpers.Equals(t, tc.expected, got)
+		})
+	}
+}
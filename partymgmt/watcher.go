@@ -51,38 +51,6 @@ func (s WatcherService) GetWatcherByEmail(ctx context.Context, email string) (Wa
 	return w, nil
 }
 
-func (s WatcherService) GetWatchHistory(ctx context.Context, logger *slog.Logger, memberID, offset int) ([]store.WatchedMoviesForWatcherResult, int, error) {
-	ctx, span, _ := metrics.SpanFromContext(ctx, "Watcher.GetWatchHistory")
-	defer span.End()
-	watchedMovies, err := s.db.GetWatchedMoviesForWatcher(ctx, memberID, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	numRecords, err := s.db.GetWatchedMoviesCountForMember(ctx, logger, memberID)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	return watchedMovies, numRecords, nil
-}
-
-func (w Watcher) GetWatchHistory(ctx context.Context, logger *slog.Logger, offset int) ([]store.WatchedMoviesForWatcherResult, int, error) {
-	ctx, span, _ := metrics.SpanFromContext(ctx, "Watcher.GetWatchHistory")
-	defer span.End()
-	watchedMovies, err := w.db.GetWatchedMoviesForWatcher(ctx, w.ID, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	numRecords, err := w.db.GetWatchedMoviesCountForMember(ctx, logger, w.ID)
-	if err != nil {
-		return nil, 0, err
-	}
-
-	return watchedMovies, numRecords, nil
-}
-
 func (w Watcher) GetPartiesAndInvitedParties(ctx context.Context, ps PartyService) ([]Party, []Party, error) {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "Watcher.GetPartiesAndInvitedParties")
 	defer span.End()
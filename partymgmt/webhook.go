@@ -0,0 +1,832 @@
+package partymgmt
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/metrics"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
+)
+
+var (
+	ErrCannotManageWebhooks = errors.New("only the party's owner can manage its webhooks")
+	ErrWebhookNotFound      = errors.New("webhook not found")
+	ErrInvalidWebhookURL    = errors.New("webhook urls have to be full http or https urls")
+	ErrInvalidWebhookFormat = errors.New("unknown webhook format")
+	ErrNoWebhookEvents      = errors.New("webhooks have to be sent at least one event")
+	ErrWebhookAddressDenied = errors.New("webhooks can't be sent to private or local addresses")
+)
+
+// EventWebhookTest is only ever sent to a webhook when its owner asks for a test event, it's never published
+const EventWebhookTest PartyEventType = "test"
+
+// the headers sent with every delivery, the signature is an HMAC-SHA256 of the timestamp and body joined by a "."
+// keyed with the webhook's secret
+const (
+	WebhookEventHeader     = "X-Movieswithfriends-Event"
+	WebhookDeliveryHeader  = "X-Movieswithfriends-Delivery"
+	WebhookTimestampHeader = "X-Movieswithfriends-Timestamp"
+	WebhookSignatureHeader = "X-Movieswithfriends-Signature"
+)
+
+const (
+	// maxWebhookAttempts is how many times a delivery is sent before it's given up on
+	maxWebhookAttempts = 6
+	// webhookRetryBaseDelay is how long to wait before the first retry, it doubles with each one after that
+	webhookRetryBaseDelay = 30 * time.Second
+	// webhookTimeout is how long a webhook has to respond, deliveries that take longer are retried
+	webhookTimeout = 10 * time.Second
+	// webhookClaimTimeout is how long a claimed delivery is left before it's sent again, in case whoever claimed it
+	// stopped before recording how it went
+	webhookClaimTimeout = time.Minute
+	// webhookDeliveriesLimit is how many of the latest deliveries are shown in the party's delivery log
+	webhookDeliveriesLimit = 50
+)
+
+// WebhookFormat is how the events sent to a webhook are written, generic json for anything or messages chat apps
+// can post as they are
+type WebhookFormat string
+
+const (
+	WebhookFormatJSON    WebhookFormat = "json"
+	WebhookFormatSlack   WebhookFormat = "slack"
+	WebhookFormatDiscord WebhookFormat = "discord"
+)
+
+// WebhookFormats are every format a webhook can be sent in, in the order they're offered
+var WebhookFormats = []WebhookFormat{WebhookFormatJSON, WebhookFormatSlack, WebhookFormatDiscord}
+
+func ParseWebhookFormat(value string) (WebhookFormat, error) {
+	switch WebhookFormat(value) {
+	case WebhookFormatJSON, WebhookFormatSlack, WebhookFormatDiscord:
+		return WebhookFormat(value), nil
+	default:
+		return "", ErrInvalidWebhookFormat
+	}
+}
+
+func (f WebhookFormat) Label() string {
+	switch f {
+	case WebhookFormatSlack:
+		return "Slack"
+	case WebhookFormatDiscord:
+		return "Discord"
+	default:
+		return "JSON"
+	}
+}
+
+// WebhookEvents are the party events that can be sent to a webhook
+var WebhookEvents = []PartyEventType{EventMovieAdded, EventMovieSelected, EventMovieWatched}
+
+// WebhookEventLabel is how the event is described to the owner choosing what's sent
+func WebhookEventLabel(eventType PartyEventType) string {
+	switch eventType {
+	case EventMovieAdded:
+		return "A movie is added"
+	case EventMovieSelected:
+		return "A movie is picked"
+	case EventMovieWatched:
+		return "A movie is watched"
+	case EventWebhookTest:
+		return "Test event"
+	default:
+		return string(eventType)
+	}
+}
+
+type Webhook struct {
+	ID      int
+	IDParty int
+	URL     string
+	Format  WebhookFormat
+	// Secret signs every delivery so the receiver can check it came from us
+	Secret    string
+	Events    []PartyEventType
+	CreatedAt time.Time
+}
+
+func (w Webhook) Sends(eventType PartyEventType) bool {
+	return slices.Contains(w.Events, eventType)
+}
+
+type WebhookDelivery struct {
+	ID             int
+	IDWebhook      int
+	URL            string
+	EventType      PartyEventType
+	Status         string
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	LastError      string
+	CreatedAt      time.Time
+}
+
+func (d WebhookDelivery) Succeeded() bool {
+	return d.Status == string(store.WebhookDeliveryStatusSucceeded)
+}
+
+func (d WebhookDelivery) Failed() bool {
+	return d.Status == string(store.WebhookDeliveryStatusFailed)
+}
+
+func (d WebhookDelivery) EventLabel() string {
+	return WebhookEventLabel(d.EventType)
+}
+
+// PartyWebhooks are a party's webhooks with the latest deliveries to them
+type PartyWebhooks struct {
+	IDParty    int
+	PartyName  string
+	Webhooks   []Webhook
+	Deliveries []WebhookDelivery
+}
+
+// WebhookInput is a new webhook as the owner entered it
+type WebhookInput struct {
+	URL    string
+	Format string
+	Events []string
+}
+
+func (i WebhookInput) validate() (string, WebhookFormat, []PartyEventType, error) {
+	webhookURL := strings.TrimSpace(i.URL)
+	parsed, err := url.Parse(webhookURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return "", "", nil, ErrInvalidWebhookURL
+	}
+
+	format, err := ParseWebhookFormat(i.Format)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	events := make([]PartyEventType, 0, len(WebhookEvents))
+	for _, eventType := range WebhookEvents {
+		if slices.Contains(i.Events, string(eventType)) {
+			events = append(events, eventType)
+		}
+	}
+
+	if len(events) == 0 {
+		return "", "", nil, ErrNoWebhookEvents
+	}
+
+	return webhookURL, format, events, nil
+}
+
+// WebhookService sends the party's events to the webhooks its owner has added. Events are queued as deliveries when
+// they happen and sent by a worker in the background so a slow or broken webhook never holds anything up, failed
+// deliveries are retried with a backoff until they've been tried maxWebhookAttempts times
+type WebhookService struct {
+	db     *store.WebhooksRepository
+	client *http.Client
+	// wake lets a newly queued delivery be sent without waiting for the worker's next poll
+	wake chan struct{}
+}
+
+func NewWebhookService(db *store.WebhooksRepository, client *http.Client) *WebhookService {
+	if client == nil {
+		client = NewWebhookClient(false)
+	}
+
+	return &WebhookService{
+		db:     db,
+		client: client,
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// GetWebhooks returns the party's webhooks and the latest deliveries to them, only the party's owner can see them
+func (s *WebhookService) GetWebhooks(ctx context.Context, idParty, idWatcher int) (PartyWebhooks, error) {
+	ctx, span, _ := metrics.SpanFromContext(ctx, "WebhookService.GetWebhooks")
+	defer span.End()
+
+	party, err := s.getManagedParty(ctx, idParty, idWatcher)
+	if err != nil {
+		return PartyWebhooks{}, err
+	}
+
+	webhooks := PartyWebhooks{
+		IDParty:    idParty,
+		PartyName:  party.Name,
+		Webhooks:   make([]Webhook, 0),
+		Deliveries: make([]WebhookDelivery, 0),
+	}
+
+	err = s.db.GetWebhooks(ctx, idParty, func(res store.WebhookResult) {
+		webhooks.Webhooks = append(webhooks.Webhooks, newWebhook(res))
+	})
+	if err != nil {
+		return PartyWebhooks{}, err
+	}
+
+	err = s.db.GetDeliveries(ctx, idParty, webhookDeliveriesLimit, func(res store.DeliveryResult) {
+		webhooks.Deliveries = append(webhooks.Deliveries, WebhookDelivery{
+			ID:             res.ID,
+			IDWebhook:      res.IDWebhook,
+			URL:            res.URL,
+			EventType:      PartyEventType(res.EventType),
+			Status:         string(res.Status),
+			Attempts:       res.Attempts,
+			NextAttemptAt:  res.NextAttemptAt,
+			LastAttemptAt:  res.LastAttemptAt,
+			ResponseStatus: res.ResponseStatus,
+			LastError:      res.LastError,
+			CreatedAt:      res.CreatedAt,
+		})
+	})
+	if err != nil {
+		return PartyWebhooks{}, err
+	}
+
+	return webhooks, nil
+}
+
+// CreateWebhook adds a webhook to the party with a new secret to sign its deliveries with
+func (s *WebhookService) CreateWebhook(ctx context.Context, logger *slog.Logger, idParty, idWatcher int, input WebhookInput) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "WebhookService.CreateWebhook")
+	defer span.End()
+
+	webhookURL, format, events, err := input.validate()
+	if err != nil {
+		return err
+	}
+
+	_, err = s.getManagedParty(ctx, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to create webhook secret", slog.Any("error", err))
+		return err
+	}
+
+	_, err = s.db.CreateWebhook(ctx, store.WebhookParams{
+		IDParty:         idParty,
+		URL:             webhookURL,
+		Format:          store.WebhookFormatEnum(format),
+		Secret:          secret,
+		OnMovieAdded:    slices.Contains(events, EventMovieAdded),
+		OnMovieSelected: slices.Contains(events, EventMovieSelected),
+		OnMovieWatched:  slices.Contains(events, EventMovieWatched),
+		IDCreatedBy:     idWatcher,
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to create webhook", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// DeleteWebhook stops sending events to the webhook, its deliveries are deleted with it
+func (s *WebhookService) DeleteWebhook(ctx context.Context, logger *slog.Logger, idParty, idWebhook, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "WebhookService.DeleteWebhook")
+	defer span.End()
+
+	_, err := s.getManagedParty(ctx, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.DeleteWebhook(ctx, idParty, idWebhook)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrWebhookNotFound
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to delete webhook", slog.Any("error", err))
+		return err
+	}
+
+	return nil
+}
+
+// SendTestEvent queues a test event for the webhook so the owner can check it's set up right, how it went shows up in
+// the delivery log
+func (s *WebhookService) SendTestEvent(ctx context.Context, logger *slog.Logger, idParty, idWebhook, idWatcher int) error {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "WebhookService.SendTestEvent")
+	defer span.End()
+
+	party, err := s.getManagedParty(ctx, idParty, idWatcher)
+	if err != nil {
+		return err
+	}
+
+	res, err := s.db.GetWebhook(ctx, idParty, idWebhook)
+	if errors.Is(err, store.ErrNoRecord) {
+		return ErrWebhookNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	event := PartyEvent{
+		IDParty:    idParty,
+		Type:       EventWebhookTest,
+		IDWatcher:  idWatcher,
+		OccurredAt: time.Now().UTC(),
+	}
+
+	err = s.queueDelivery(ctx, newWebhook(res), party.Name, event)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to queue test event", slog.Any("error", err))
+		return err
+	}
+
+	s.wakeWorker()
+	return nil
+}
+
+// HandlePartyEvent queues the event for every one of the party's webhooks that wants it, it's added to the party's
+// event bus so it sees everything that's published. Nothing that happened is undone when the event can't be queued
+// so errors are only logged
+func (s *WebhookService) HandlePartyEvent(ctx context.Context, logger *slog.Logger, event PartyEvent) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "WebhookService.HandlePartyEvent")
+	defer span.End()
+
+	if !slices.Contains(WebhookEvents, event.Type) {
+		return
+	}
+
+	webhooks := make([]Webhook, 0)
+	err := s.db.GetWebhooks(ctx, event.IDParty, func(res store.WebhookResult) {
+		webhook := newWebhook(res)
+		if webhook.Sends(event.Type) {
+			webhooks = append(webhooks, webhook)
+		}
+	})
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get webhooks for party event", slog.String("type", string(event.Type)), slog.Any("error", err))
+		return
+	}
+
+	if len(webhooks) == 0 {
+		return
+	}
+
+	party, err := s.db.GetWebhookParty(ctx, event.IDParty)
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to get party for webhooks", slog.Any("error", err))
+		return
+	}
+
+	for _, webhook := range webhooks {
+		err = s.queueDelivery(ctx, webhook, party.Name, event)
+		if err != nil {
+			labeler.Add(metrics.ErrorOccurredAttribute())
+			logger.ErrorContext(ctx, "failed to queue webhook delivery", slog.Int("webhookID", webhook.ID), slog.Any("error", err))
+		}
+	}
+
+	s.wakeWorker()
+}
+
+// StartDeliveryWorker sends queued deliveries in the background until the context is cancelled, they're checked for
+// on an interval and as soon as one is queued
+func (s *WebhookService) StartDeliveryWorker(ctx context.Context, logger *slog.Logger, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			s.sendDueDeliveries(ctx, logger)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+		}
+	}()
+}
+
+// sendDueDeliveries sends every delivery that's due, including any a crashed worker claimed and never finished
+func (s *WebhookService) sendDueDeliveries(ctx context.Context, logger *slog.Logger) {
+	for ctx.Err() == nil {
+		now := time.Now().UTC()
+		delivery, err := s.db.ClaimDueDelivery(ctx, now, now.Add(webhookClaimTimeout))
+		if errors.Is(err, store.ErrNoRecord) {
+			return
+		}
+
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to claim webhook delivery", slog.Any("error", err))
+			return
+		}
+
+		s.sendDelivery(ctx, logger, delivery)
+	}
+}
+
+func (s *WebhookService) sendDelivery(ctx context.Context, logger *slog.Logger, delivery store.PendingDeliveryResult) {
+	ctx, span, labeler := metrics.SpanFromContext(ctx, "WebhookService.sendDelivery")
+	defer span.End()
+
+	logger = logger.With(slog.Int("deliveryID", delivery.ID), slog.Int("webhookID", delivery.IDWebhook))
+
+	attemptedAt := time.Now().UTC()
+	status, err := SendWebhook(ctx, s.client, WebhookRequest{
+		IDDelivery: delivery.ID,
+		URL:        delivery.URL,
+		Secret:     delivery.Secret,
+		EventType:  PartyEventType(delivery.EventType),
+		Body:       []byte(delivery.Body),
+		SentAt:     attemptedAt,
+	})
+
+	params := store.DeliveryAttemptParams{
+		IDDelivery:     delivery.ID,
+		Status:         store.WebhookDeliveryStatusSucceeded,
+		ResponseStatus: status,
+		AttemptedAt:    attemptedAt,
+		NextAttemptAt:  attemptedAt,
+	}
+
+	if err != nil {
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		params.Error = err.Error()
+
+		if delivery.Attempts >= maxWebhookAttempts {
+			logger.WarnContext(ctx, "giving up on webhook delivery", slog.Int("attempts", delivery.Attempts), slog.Any("error", err))
+			params.Status = store.WebhookDeliveryStatusFailed
+		} else {
+			logger.InfoContext(ctx, "webhook delivery failed, retrying", slog.Int("attempts", delivery.Attempts), slog.Any("error", err))
+			params.Status = store.WebhookDeliveryStatusPending
+			params.NextAttemptAt = attemptedAt.Add(WebhookRetryDelay(delivery.Attempts))
+		}
+	}
+
+	err = s.db.RecordDeliveryAttempt(ctx, params)
+	if err != nil {
+		// the delivery is left claimed so it's sent again once the claim runs out
+		labeler.Add(metrics.ErrorOccurredAttribute())
+		logger.ErrorContext(ctx, "failed to record webhook delivery attempt", slog.Any("error", err))
+	}
+}
+
+func (s *WebhookService) queueDelivery(ctx context.Context, webhook Webhook, partyName string, event PartyEvent) error {
+	body, err := WebhookBody(webhook.Format, partyName, event)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.CreateDelivery(ctx, webhook.ID, string(event.Type), string(body))
+	return err
+}
+
+func (s *WebhookService) wakeWorker() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// getManagedParty returns the party after checking the watcher is its owner, they're the only one who can manage its
+// webhooks since the secrets are shown with them
+func (s *WebhookService) getManagedParty(ctx context.Context, idParty, idWatcher int) (store.WebhookPartyResult, error) {
+	party, err := s.db.GetWebhookParty(ctx, idParty)
+	if errors.Is(err, store.ErrNoRecord) {
+		return store.WebhookPartyResult{}, ErrCannotManageWebhooks
+	}
+
+	if err != nil {
+		return store.WebhookPartyResult{}, err
+	}
+
+	if party.IDOwner != idWatcher {
+		return store.WebhookPartyResult{}, ErrCannotManageWebhooks
+	}
+
+	return party, nil
+}
+
+func newWebhook(res store.WebhookResult) Webhook {
+	webhook := Webhook{
+		ID:        res.ID,
+		IDParty:   res.IDParty,
+		URL:       res.URL,
+		Format:    WebhookFormat(res.Format),
+		Secret:    res.Secret,
+		Events:    make([]PartyEventType, 0, len(WebhookEvents)),
+		CreatedAt: res.CreatedAt,
+	}
+
+	if res.OnMovieAdded {
+		webhook.Events = append(webhook.Events, EventMovieAdded)
+	}
+
+	if res.OnMovieSelected {
+		webhook.Events = append(webhook.Events, EventMovieSelected)
+	}
+
+	if res.OnMovieWatched {
+		webhook.Events = append(webhook.Events, EventMovieWatched)
+	}
+
+	return webhook
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
+
+// WebhookRetryDelay is how long to wait before sending a delivery again after it's failed attempts times
+func WebhookRetryDelay(attempts int) time.Duration {
+	if attempts < 1 {
+		attempts = 1
+	}
+	return webhookRetryBaseDelay << (attempts - 1)
+}
+
+type jsonWebhookParty struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type jsonWebhookMovie struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
+
+type jsonWebhookWatcher struct {
+	ID        int    `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+type jsonWebhookBody struct {
+	Event      PartyEventType      `json:"event"`
+	Text       string              `json:"text"`
+	Party      jsonWebhookParty    `json:"party"`
+	Watcher    *jsonWebhookWatcher `json:"watcher,omitempty"`
+	Movie      *jsonWebhookMovie   `json:"movie,omitempty"`
+	OccurredAt time.Time           `json:"occurred_at"`
+}
+
+type slackWebhookBody struct {
+	Text string `json:"text"`
+}
+
+type discordAllowedMentions struct {
+	Parse []string `json:"parse"`
+}
+
+type discordWebhookBody struct {
+	Content string `json:"content"`
+	// AllowedMentions is always empty so nothing anyone named a party or movie pings the channel
+	AllowedMentions discordAllowedMentions `json:"allowed_mentions"`
+}
+
+// WebhookBody is what's sent to a webhook in the format for the event in the party
+func WebhookBody(format WebhookFormat, partyName string, event PartyEvent) ([]byte, error) {
+	switch format {
+	case WebhookFormatSlack:
+		return marshalWebhookBody(slackWebhookBody{Text: webhookText(partyName, event, slackEmphasis)})
+	case WebhookFormatDiscord:
+		return marshalWebhookBody(discordWebhookBody{
+			Content:         webhookText(partyName, event, discordEmphasis),
+			AllowedMentions: discordAllowedMentions{Parse: []string{}},
+		})
+	case WebhookFormatJSON:
+		body := jsonWebhookBody{
+			Event:      event.Type,
+			Text:       webhookText(partyName, event, func(s string) string { return s }),
+			Party:      jsonWebhookParty{ID: event.IDParty, Name: partyName},
+			OccurredAt: event.OccurredAt,
+		}
+
+		if event.Type != EventWebhookTest {
+			body.Watcher = &jsonWebhookWatcher{ID: event.IDWatcher, FirstName: event.Watcher.FirstName, LastName: event.Watcher.LastName}
+		}
+
+		if event.IDMovie != 0 {
+			body.Movie = &jsonWebhookMovie{ID: event.IDMovie, Title: event.MovieTitle}
+		}
+
+		return marshalWebhookBody(body)
+	default:
+		return nil, ErrInvalidWebhookFormat
+	}
+}
+
+// marshalWebhookBody writes the body as json without escaping html, nothing sent to a webhook ends up in a page as is
+// and chat apps show the escapes instead of what they stand for
+func marshalWebhookBody(body any) ([]byte, error) {
+	var b bytes.Buffer
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+
+	err := encoder.Encode(body)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.TrimSuffix(b.Bytes(), []byte("\n")), nil
+}
+
+// webhookText is the event as a sentence, emphasize marks up the names in it the way the chat app it's sent to
+// expects
+func webhookText(partyName string, event PartyEvent, emphasize func(string) string) string {
+	who := emphasize(nameOrSomeone(event.Watcher))
+	party := emphasize(partyName)
+	movie := emphasize(event.MovieTitle)
+
+	switch event.Type {
+	case EventMovieAdded:
+		return fmt.Sprintf("%s added %s to %s", who, movie, party)
+	case EventMovieSelected:
+		return fmt.Sprintf("%s picked %s as the next movie for %s", who, movie, party)
+	case EventMovieWatched:
+		return fmt.Sprintf("%s watched %s", party, movie)
+	case EventWebhookTest:
+		return fmt.Sprintf("This is a test event from %s, your webhook is working", party)
+	default:
+		return fmt.Sprintf("%s %s %s", who, event.Description(), party)
+	}
+}
+
+var slackEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+
+func slackEmphasis(s string) string {
+	return "*" + slackEscaper.Replace(s) + "*"
+}
+
+var discordEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "|", `\|`, ">", `\>`)
+
+func discordEmphasis(s string) string {
+	return "**" + discordEscaper.Replace(s) + "**"
+}
+
+// WebhookRequest is one attempt at sending a delivery to a webhook
+type WebhookRequest struct {
+	IDDelivery int
+	URL        string
+	Secret     string
+	EventType  PartyEventType
+	Body       []byte
+	SentAt     time.Time
+}
+
+// NewWebhookClient is the client deliveries are sent with. Any owner can point a webhook anywhere, so unless
+// allowPrivateAddresses is set it refuses to connect to any address WebhookAddressAllowed doesn't allow. That's checked on the address being dialed after the host has been resolved, so a host that resolves to
+// one is refused as well. Redirects are never followed, a receiver has to be sent to directly.
+func NewWebhookClient(allowPrivateAddresses bool) *http.Client {
+	dialer := &net.Dialer{Timeout: webhookTimeout}
+	if !allowPrivateAddresses {
+		dialer.Control = denyPrivateAddresses
+	}
+
+	return &http.Client{
+		Timeout: webhookTimeout,
+		// there's no proxy, it would be the one dialing the webhook and the address wouldn't be checked
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// deniedWebhookPrefixes are the special purpose ranges that aren't covered by netip's checks but can still reach
+// something that isn't on the internet
+var deniedWebhookPrefixes = []netip.Prefix{
+	// "this network", some systems send these to the host itself
+	netip.MustParsePrefix("0.0.0.0/8"),
+	// shared address space behind carrier grade NAT
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// IETF protocol assignments
+	netip.MustParsePrefix("192.0.0.0/24"),
+	// benchmarking
+	netip.MustParsePrefix("198.18.0.0/15"),
+	// reserved for future use and broadcast
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// NAT64, these are translated to the IPv4 address in the last 32 bits which can be a private one
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+	// 6to4 and Teredo tunnel to an IPv4 address embedded in the address
+	netip.MustParsePrefix("2002::/16"),
+	netip.MustParsePrefix("2001::/32"),
+}
+
+// WebhookAddressAllowed is whether a webhook can be delivered to the address, only addresses on the internet are
+func WebhookAddressAllowed(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() || addr.IsUnspecified() {
+		return false
+	}
+
+	for _, prefix := range deniedWebhookPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func denyPrivateAddresses(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+
+	if !WebhookAddressAllowed(addr) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddressDenied, addr.Unmap())
+	}
+
+	return nil
+}
+
+// SendWebhook posts the delivery's body to the webhook signed with its secret, any response other than a 2xx is an
+// error. The status of the response is returned whenever there was one, the body of a failed one never is since the
+// error ends up in the delivery log
+func SendWebhook(ctx context.Context, client *http.Client, webhookReq WebhookRequest) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookReq.URL, bytes.NewReader(webhookReq.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(webhookReq.SentAt.Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "movieswithfriends-webhooks")
+	req.Header.Set(WebhookEventHeader, string(webhookReq.EventType))
+	req.Header.Set(WebhookDeliveryHeader, strconv.Itoa(webhookReq.IDDelivery))
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, SignWebhookBody(webhookReq.Secret, timestamp, webhookReq.Body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// the address that was resolved for the webhook isn't shown to its owner
+		if errors.Is(err, ErrWebhookAddressDenied) {
+			return 0, ErrWebhookAddressDenied
+		}
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// the body is read so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded with %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// SignWebhookBody is the signature sent with a delivery, receivers work it out the same way to check it
+func SignWebhookBody(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
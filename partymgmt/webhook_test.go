@@ -0,0 +1,256 @@
+package partymgmt_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+	"github.com/jm96441n/movieswithfriends/webhookfake"
+)
+
+func TestWebhookBody(t *testing.T) {
+	t.Parallel()
+
+	occurredAt := time.Date(2025, 6, 8, 20, 0, 0, 0, time.UTC)
+	selected := partymgmt.PartyEvent{
+		IDParty:    1,
+		Type:       partymgmt.EventMovieSelected,
+		IDWatcher:  2,
+		Watcher:    partymgmt.FullName{FirstName: "Ada", LastName: "Lovelace"},
+		IDMovie:    3,
+		MovieTitle: "<Heat> & *Friends*",
+		OccurredAt: occurredAt,
+	}
+	test := partymgmt.PartyEvent{IDParty: 1, Type: partymgmt.EventWebhookTest, IDWatcher: 2, OccurredAt: occurredAt}
+
+	testCases := map[string]struct {
+		format   partymgmt.WebhookFormat
+		event    partymgmt.PartyEvent
+		expected string
+	}{
+		"json": {
+			format:   partymgmt.WebhookFormatJSON,
+			event:    selected,
+			expected: `{"event":"movie_selected","text":"Ada Lovelace picked <Heat> & *Friends* as the next movie for Movie Club","party":{"id":1,"name":"Movie Club"},"watcher":{"id":2,"first_name":"Ada","last_name":"Lovelace"},"movie":{"id":3,"title":"<Heat> & *Friends*"},"occurred_at":"2025-06-08T20:00:00Z"}`,
+		},
+		"json test event": {
+			format:   partymgmt.WebhookFormatJSON,
+			event:    test,
+			expected: `{"event":"test","text":"This is a test event from Movie Club, your webhook is working","party":{"id":1,"name":"Movie Club"},"occurred_at":"2025-06-08T20:00:00Z"}`,
+		},
+		"slack escapes what it would treat as markup": {
+			format:   partymgmt.WebhookFormatSlack,
+			event:    selected,
+			expected: `{"text":"*Ada Lovelace* picked *&lt;Heat&gt; &amp; *Friends** as the next movie for *Movie Club*"}`,
+		},
+		"discord escapes markdown and never pings anyone": {
+			format:   partymgmt.WebhookFormatDiscord,
+			event:    selected,
+			expected: `{"content":"**Ada Lovelace** picked **<Heat\\> & \\*Friends\\*** as the next movie for **Movie Club**","allowed_mentions":{"parse":[]}}`,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			body, err := partymgmt.WebhookBody(tc.format, "Movie Club", tc.event)
+			testhelpers.Ok(t, err, "failed to build webhook body")
+			testhelpers.Equals(t, tc.expected, string(body))
+		})
+	}
+}
+
+func TestSendWebhook(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	body := []byte(`{"event":"test"}`)
+	sentAt := time.Date(2025, 6, 8, 20, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		opts           []webhookfake.Option
+		secret         string
+		expectedStatus int
+		expectedErr    bool
+	}{
+		"signed with the secret": {
+			opts:           []webhookfake.Option{webhookfake.WithSecret("shh")},
+			secret:         "shh",
+			expectedStatus: http.StatusNoContent,
+		},
+		"signed with the wrong secret": {
+			opts:           []webhookfake.Option{webhookfake.WithSecret("shh")},
+			secret:         "not it",
+			expectedStatus: http.StatusUnauthorized,
+			expectedErr:    true,
+		},
+		"receiver fails": {
+			opts:           []webhookfake.Option{webhookfake.WithFailures(1)},
+			secret:         "shh",
+			expectedStatus: http.StatusInternalServerError,
+			expectedErr:    true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			server, receiver := webhookfake.NewServer(t, tc.opts...)
+			status, err := partymgmt.SendWebhook(ctx, server.Client(), partymgmt.WebhookRequest{
+				IDDelivery: 7,
+				URL:        server.URL + "/hooks/party",
+				Secret:     tc.secret,
+				EventType:  partymgmt.EventWebhookTest,
+				Body:       body,
+				SentAt:     sentAt,
+			})
+			testhelpers.Equals(t, tc.expectedStatus, status)
+			testhelpers.Assert(t, (err != nil) == tc.expectedErr, "expected error %v, got %v", tc.expectedErr, err)
+
+			deliveries := receiver.Deliveries()
+			testhelpers.Equals(t, 1, len(deliveries))
+			testhelpers.Equals(t, "test", deliveries[0].Event)
+			testhelpers.Equals(t, "7", deliveries[0].IDDelivery)
+			testhelpers.Equals(t, "1749412800", deliveries[0].Timestamp)
+			testhelpers.Equals(t, partymgmt.SignWebhookBody(tc.secret, "1749412800", body), deliveries[0].Signature)
+			testhelpers.Equals(t, body, deliveries[0].Body)
+		})
+	}
+}
+
+func TestSendWebhookUnreachable(t *testing.T) {
+	t.Parallel()
+
+	server, _ := webhookfake.NewServer(t)
+	server.Close()
+
+	status, err := partymgmt.SendWebhook(context.Background(), http.DefaultClient, partymgmt.WebhookRequest{URL: server.URL, SentAt: time.Now()})
+	testhelpers.Equals(t, 0, status)
+	testhelpers.Assert(t, err != nil, "expected an error sending to a closed server")
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	t.Parallel()
+
+	expected := []time.Duration{30 * time.Second, time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute}
+	for i, delay := range expected {
+		testhelpers.Equals(t, delay, partymgmt.WebhookRetryDelay(i+1))
+	}
+}
+
+func TestParseWebhookFormat(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range partymgmt.WebhookFormats {
+		parsed, err := partymgmt.ParseWebhookFormat(string(format))
+		testhelpers.Ok(t, err, "failed to parse webhook format")
+		testhelpers.Equals(t, format, parsed)
+	}
+
+	_, err := partymgmt.ParseWebhookFormat("teams")
+	testhelpers.Assert(t, errors.Is(err, partymgmt.ErrInvalidWebhookFormat), "expected %v, got %v", partymgmt.ErrInvalidWebhookFormat, err)
+}
+
+func TestSendWebhookToPrivateAddress(t *testing.T) {
+	t.Parallel()
+
+	// httptest servers listen on loopback
+	server, receiver := webhookfake.NewServer(t)
+
+	status, err := partymgmt.SendWebhook(context.Background(), partymgmt.NewWebhookClient(false), partymgmt.WebhookRequest{
+		URL:    server.URL + "/hooks/party",
+		Body:   []byte(`{}`),
+		SentAt: time.Now(),
+	})
+	testhelpers.Equals(t, 0, status)
+	testhelpers.Assert(t, errors.Is(err, partymgmt.ErrWebhookAddressDenied), "expected %v, got %v", partymgmt.ErrWebhookAddressDenied, err)
+	testhelpers.Equals(t, partymgmt.ErrWebhookAddressDenied.Error(), err.Error())
+	testhelpers.Equals(t, 0, len(receiver.Deliveries()))
+}
+
+func TestWebhookAddressAllowed(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]bool{
+		"93.184.215.14":         true,
+		"2606:2800:21f:cb07::1": true,
+		"127.0.0.1":             false,
+		"10.1.2.3":              false,
+		"169.254.169.254":       false,
+		"0.1.2.3":               false,
+		"100.64.0.1":            false,
+		"100.127.255.254":       false,
+		"192.0.0.170":           false,
+		"198.18.0.1":            false,
+		"198.19.255.254":        false,
+		"255.255.255.255":       false,
+		"::ffff:100.64.0.1":     false,
+		"64:ff9b::a00:1":        false,
+		"64:ff9b::7f00:1":       false,
+		"64:ff9b:1::a00:1":      false,
+		"2002:a00:1::1":         false,
+		"2001:0:a00:1::1":       false,
+		"fd00::1":               false,
+		"::1":                   false,
+	}
+
+	for address, expected := range tests {
+		t.Run(address, func(t *testing.T) {
+			t.Parallel()
+
+			testhelpers.Equals(t, expected, partymgmt.WebhookAddressAllowed(netip.MustParseAddr(address)))
+		})
+	}
+}
+
+func TestSendWebhookRedirects(t *testing.T) {
+	t.Parallel()
+
+	target, receiver := webhookfake.NewServer(t)
+
+	testCases := map[string]string{
+		"to a private address":      "http://169.254.169.254/latest/meta-data/",
+		"to another local receiver": target.URL + "/hooks/party",
+	}
+
+	for name, location := range testCases {
+		t.Run(name, func(t *testing.T) {
+			redirector := httptest.NewServer(http.RedirectHandler(location, http.StatusTemporaryRedirect))
+			t.Cleanup(redirector.Close)
+
+			// private addresses are allowed so the redirector can be reached, the redirect still isn't followed
+			status, err := partymgmt.SendWebhook(context.Background(), partymgmt.NewWebhookClient(true), partymgmt.WebhookRequest{
+				URL:    redirector.URL,
+				Body:   []byte(`{}`),
+				SentAt: time.Now(),
+			})
+			testhelpers.Equals(t, http.StatusTemporaryRedirect, status)
+			testhelpers.Assert(t, err != nil, "expected a redirect to be an error")
+		})
+	}
+
+	testhelpers.Equals(t, 0, len(receiver.Deliveries()))
+}
+
+func TestSendWebhookKeepsResponseBodyOutOfError(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprint(w, "internal details")
+	}))
+	t.Cleanup(server.Close)
+
+	status, err := partymgmt.SendWebhook(context.Background(), server.Client(), partymgmt.WebhookRequest{URL: server.URL, SentAt: time.Now()})
+	testhelpers.Equals(t, http.StatusInternalServerError, status)
+	testhelpers.Equals(t, "webhook responded with 500", err.Error())
+}
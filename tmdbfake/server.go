@@ -0,0 +1,510 @@
+// Package tmdbfake is an offline stand-in for the parts of the TMDB API the app uses, serving canned fixtures so
+// the app and its tests can run without network access or an API key.
+package tmdbfake
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+const (
+	// BasePath is the path prefix the fake is served under, matching the version prefix on the real API
+	BasePath = "/3"
+
+	defaultLanguage = "en"
+	pageSize        = 20
+)
+
+type movie struct {
+	ID          int     `json:"id"`
+	IMDBID      string  `json:"imdb_id"`
+	Title       string  `json:"title"`
+	ReleaseDate string  `json:"release_date"`
+	Overview    string  `json:"overview"`
+	Tagline     string  `json:"tagline"`
+	PosterPath  string  `json:"poster_path"`
+	Runtime     int     `json:"runtime"`
+	VoteAverage float64 `json:"vote_average"`
+	Budget      int     `json:"budget"`
+	Genres      []int   `json:"genres"`
+	Videos      []video `json:"videos"`
+	// WatchProviders are the provider ids for each region keyed by how the movie is offered, e.g. "flatrate"
+	WatchProviders map[string]map[string][]int `json:"watch_providers"`
+	// Recommendations and Similar are the ids of the movies returned from those endpoints
+	Recommendations []int `json:"recommendations"`
+	Similar         []int `json:"similar"`
+}
+
+type provider struct {
+	ID              int      `json:"provider_id"`
+	Name            string   `json:"provider_name"`
+	LogoPath        string   `json:"logo_path"`
+	DisplayPriority int      `json:"display_priority"`
+	Regions         []string `json:"regions,omitempty"`
+}
+
+type video struct {
+	Key  string `json:"key"`
+	Type string `json:"type"`
+	Site string `json:"site"`
+}
+
+type genre struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type genreList struct {
+	Genres []genre `json:"genres"`
+}
+
+type searchResult struct {
+	ID          int     `json:"id"`
+	Title       string  `json:"title"`
+	ReleaseDate string  `json:"release_date"`
+	Overview    string  `json:"overview"`
+	PosterPath  string  `json:"poster_path"`
+	VoteAverage float64 `json:"vote_average"`
+	GenreIDs    []int   `json:"genre_ids"`
+}
+
+type searchResponse struct {
+	Page         int            `json:"page"`
+	Results      []searchResult `json:"results"`
+	TotalPages   int            `json:"total_pages"`
+	TotalResults int            `json:"total_results"`
+}
+
+type movieResponse struct {
+	ID          int     `json:"id"`
+	IMDBID      string  `json:"imdb_id"`
+	Title       string  `json:"title"`
+	ReleaseDate string  `json:"release_date"`
+	Overview    string  `json:"overview"`
+	Tagline     string  `json:"tagline"`
+	PosterPath  string  `json:"poster_path"`
+	Runtime     int     `json:"runtime"`
+	VoteAverage float64 `json:"vote_average"`
+	Budget      int     `json:"budget"`
+	Genres      []genre `json:"genres"`
+}
+
+type videosResponse struct {
+	ID      int     `json:"id"`
+	Results []video `json:"results"`
+}
+
+type watchProvidersResponse struct {
+	ID      int                       `json:"id"`
+	Results map[string]map[string]any `json:"results"`
+}
+
+type providerListResponse struct {
+	Results []provider `json:"results"`
+}
+
+type errorResponse struct {
+	StatusCode    int    `json:"status_code"`
+	StatusMessage string `json:"status_message"`
+	Success       bool   `json:"success"`
+}
+
+// Handler serves the fixture data over the same routes and response shapes as the TMDB API
+type Handler struct {
+	apiKey string
+	logger *slog.Logger
+	movies []movie
+	byID   map[int]movie
+	// providers is keyed by the provider id
+	providers map[int]provider
+	// genres is keyed by language, any language without fixtures is served in the default language
+	genres map[string][]genre
+	mux    *http.ServeMux
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithAPIKey makes the fake reject any request that doesn't send the key as a bearer token, by default any key is
+// accepted
+func WithAPIKey(apiKey string) Option {
+	return func(h *Handler) {
+		h.apiKey = apiKey
+	}
+}
+
+// WithLogger logs every request the fake serves
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+func NewHandler(opts ...Option) (*Handler, error) {
+	h := &Handler{
+		byID:      make(map[int]movie),
+		providers: make(map[int]provider),
+		genres:    make(map[string][]genre),
+		mux:       http.NewServeMux(),
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	movieData, err := fixtures.ReadFile("fixtures/movies.json")
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(movieData, &h.movies)
+	if err != nil {
+		return nil, fmt.Errorf("parsing movie fixtures: %w", err)
+	}
+
+	for _, m := range h.movies {
+		h.byID[m.ID] = m
+	}
+
+	providerData, err := fixtures.ReadFile("fixtures/providers.json")
+	if err != nil {
+		return nil, err
+	}
+
+	providers := []provider{}
+	err = json.Unmarshal(providerData, &providers)
+	if err != nil {
+		return nil, fmt.Errorf("parsing provider fixtures: %w", err)
+	}
+
+	for _, p := range providers {
+		h.providers[p.ID] = p
+	}
+
+	genreFiles, err := fixtures.ReadDir("fixtures")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range genreFiles {
+		language, ok := strings.CutPrefix(strings.TrimSuffix(file.Name(), ".json"), "genres_")
+		if !ok {
+			continue
+		}
+
+		data, err := fixtures.ReadFile("fixtures/" + file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		list := genreList{}
+		err = json.Unmarshal(data, &list)
+		if err != nil {
+			return nil, fmt.Errorf("parsing genre fixtures for %q: %w", language, err)
+		}
+		h.genres[language] = list.Genres
+	}
+
+	if _, ok := h.genres[defaultLanguage]; !ok {
+		return nil, fmt.Errorf("missing genre fixtures for %q", defaultLanguage)
+	}
+
+	h.mux.HandleFunc("GET "+BasePath+"/search/movie", h.search)
+	h.mux.HandleFunc("GET "+BasePath+"/movie/{id}", h.getMovie)
+	h.mux.HandleFunc("GET "+BasePath+"/movie/{id}/videos", h.getVideos)
+	h.mux.HandleFunc("GET "+BasePath+"/movie/{id}/watch/providers", h.getWatchProviders)
+	h.mux.HandleFunc("GET "+BasePath+"/movie/{id}/recommendations", h.getRelated(func(m movie) []int { return m.Recommendations }))
+	h.mux.HandleFunc("GET "+BasePath+"/movie/{id}/similar", h.getRelated(func(m movie) []int { return m.Similar }))
+	h.mux.HandleFunc("GET "+BasePath+"/find/{external_id}", h.find)
+	h.mux.HandleFunc("GET "+BasePath+"/genre/movie/list", h.listGenres)
+	h.mux.HandleFunc("GET "+BasePath+"/watch/providers/movie", h.listProviders)
+
+	return h, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.logger != nil {
+		h.logger.Info("request", "method", r.Method, "path", r.URL.Path, "query", r.URL.RawQuery)
+	}
+
+	if h.apiKey != "" && r.Header.Get("Authorization") != "Bearer "+h.apiKey {
+		writeError(w, http.StatusUnauthorized, 7, "Invalid API key: You must be granted a valid key.")
+		return
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// NewServer starts the fake on a local port for use in tests, the server is closed when the test finishes. Point
+// the TMDB client at server.URL + BasePath.
+func NewServer(t interface {
+	Helper()
+	Cleanup(func())
+	Fatalf(format string, args ...any)
+}, opts ...Option,
+) *httptest.Server {
+	t.Helper()
+
+	handler, err := NewHandler(opts...)
+	if err != nil {
+		t.Fatalf("failed to create fake tmdb handler: %v", err)
+	}
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func (h *Handler) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("query")))
+	if query == "" {
+		writeJSON(w, http.StatusOK, searchResponse{Page: 1, Results: []searchResult{}})
+		return
+	}
+
+	page := 1
+	if rawPage := r.URL.Query().Get("page"); rawPage != "" {
+		var err error
+		page, err = strconv.Atoi(rawPage)
+		if err != nil || page < 1 {
+			writeError(w, http.StatusBadRequest, 22, "Invalid page: Pages start at 1 and max at 500.")
+			return
+		}
+	}
+
+	// TMDB accepts either, year also matches on release dates in other countries but the fixtures only have one
+	year := r.URL.Query().Get("primary_release_year")
+	if year == "" {
+		year = r.URL.Query().Get("year")
+	}
+
+	matches := make([]searchResult, 0)
+	for _, m := range h.movies {
+		if !strings.Contains(strings.ToLower(m.Title), query) {
+			continue
+		}
+		if year != "" && !strings.HasPrefix(m.ReleaseDate, year+"-") {
+			continue
+		}
+		matches = append(matches, m.toSearchResult())
+	}
+
+	writeJSON(w, http.StatusOK, paginate(matches, page))
+}
+
+type findResponse struct {
+	MovieResults []searchResult `json:"movie_results"`
+}
+
+// find looks up movies by their id on another site, only imdb ids are supported
+func (h *Handler) find(w http.ResponseWriter, r *http.Request) {
+	if source := r.URL.Query().Get("external_source"); source != "imdb_id" {
+		writeError(w, http.StatusBadRequest, 5, "Invalid parameters: Your request parameters are incorrect.")
+		return
+	}
+
+	externalID := r.PathValue("external_id")
+	results := make([]searchResult, 0)
+	for _, m := range h.movies {
+		if m.IMDBID != "" && m.IMDBID == externalID {
+			results = append(results, m.toSearchResult())
+		}
+	}
+
+	writeJSON(w, http.StatusOK, findResponse{MovieResults: results})
+}
+
+// getRelated serves the recommendations or similar movies for a movie, related picks which list of ids is served
+func (h *Handler) getRelated(related func(movie) []int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		m, ok := h.movieFromPath(w, r)
+		if !ok {
+			return
+		}
+
+		results := make([]searchResult, 0)
+		for _, id := range related(m) {
+			if relatedMovie, ok := h.byID[id]; ok {
+				results = append(results, relatedMovie.toSearchResult())
+			}
+		}
+
+		writeJSON(w, http.StatusOK, paginate(results, 1))
+	}
+}
+
+func (m movie) toSearchResult() searchResult {
+	return searchResult{
+		ID:          m.ID,
+		Title:       m.Title,
+		ReleaseDate: m.ReleaseDate,
+		Overview:    m.Overview,
+		PosterPath:  m.PosterPath,
+		VoteAverage: m.VoteAverage,
+		GenreIDs:    m.Genres,
+	}
+}
+
+func paginate(results []searchResult, page int) searchResponse {
+	totalPages := (len(results) + pageSize - 1) / pageSize
+	start := min((page-1)*pageSize, len(results))
+	end := min(start+pageSize, len(results))
+
+	return searchResponse{
+		Page:         page,
+		Results:      results[start:end],
+		TotalPages:   totalPages,
+		TotalResults: len(results),
+	}
+}
+
+func (h *Handler) getMovie(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.movieFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	genres := make([]genre, 0, len(m.Genres))
+	for _, id := range m.Genres {
+		if g, ok := h.genre(r.URL.Query().Get("language"), id); ok {
+			genres = append(genres, g)
+		}
+	}
+
+	writeJSON(w, http.StatusOK, movieResponse{
+		ID:          m.ID,
+		IMDBID:      m.IMDBID,
+		Title:       m.Title,
+		ReleaseDate: m.ReleaseDate,
+		Overview:    m.Overview,
+		Tagline:     m.Tagline,
+		PosterPath:  m.PosterPath,
+		Runtime:     m.Runtime,
+		VoteAverage: m.VoteAverage,
+		Budget:      m.Budget,
+		Genres:      genres,
+	})
+}
+
+func (h *Handler) getVideos(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.movieFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	videos := m.Videos
+	if videos == nil {
+		videos = []video{}
+	}
+
+	writeJSON(w, http.StatusOK, videosResponse{ID: m.ID, Results: videos})
+}
+
+func (h *Handler) getWatchProviders(w http.ResponseWriter, r *http.Request) {
+	m, ok := h.movieFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	results := make(map[string]map[string]any, len(m.WatchProviders))
+	for region, byType := range m.WatchProviders {
+		regionResult := map[string]any{
+			"link": fmt.Sprintf("https://www.themoviedb.org/movie/%d/watch?locale=%s", m.ID, region),
+		}
+		for monetizationType, ids := range byType {
+			providers := make([]provider, 0, len(ids))
+			for _, id := range ids {
+				p, ok := h.providers[id]
+				if !ok {
+					continue
+				}
+				p.Regions = nil
+				providers = append(providers, p)
+			}
+			regionResult[monetizationType] = providers
+		}
+		results[region] = regionResult
+	}
+
+	writeJSON(w, http.StatusOK, watchProvidersResponse{ID: m.ID, Results: results})
+}
+
+// listProviders returns the providers available in the watch_region, or every provider when no region is sent
+func (h *Handler) listProviders(w http.ResponseWriter, r *http.Request) {
+	region := strings.ToUpper(r.URL.Query().Get("watch_region"))
+
+	providers := make([]provider, 0, len(h.providers))
+	for _, p := range h.providers {
+		if region != "" && !slices.Contains(p.Regions, region) {
+			continue
+		}
+		p.Regions = nil
+		providers = append(providers, p)
+	}
+
+	slices.SortFunc(providers, func(a, b provider) int {
+		return a.DisplayPriority - b.DisplayPriority
+	})
+
+	writeJSON(w, http.StatusOK, providerListResponse{Results: providers})
+}
+
+func (h *Handler) listGenres(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, genreList{Genres: h.genresFor(r.URL.Query().Get("language"))})
+}
+
+func (h *Handler) movieFromPath(w http.ResponseWriter, r *http.Request) (movie, bool) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return movie{}, false
+	}
+
+	m, ok := h.byID[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, 34, "The resource you requested could not be found.")
+		return movie{}, false
+	}
+
+	return m, true
+}
+
+// genresFor returns the genres for a language, TMDB accepts both "es" and "es-ES" style codes so only the primary
+// subtag is used to pick the fixtures
+func (h *Handler) genresFor(language string) []genre {
+	language, _, _ = strings.Cut(language, "-")
+	if genres, ok := h.genres[strings.ToLower(language)]; ok {
+		return genres
+	}
+	return h.genres[defaultLanguage]
+}
+
+func (h *Handler) genre(language string, id int) (genre, bool) {
+	for _, g := range h.genresFor(language) {
+		if g.ID == id {
+			return g, true
+		}
+	}
+	return genre{}, false
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json;charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+func writeError(w http.ResponseWriter, status, code int, message string) {
+	writeJSON(w, status, errorResponse{StatusCode: code, StatusMessage: message})
+}
@@ -4,6 +4,7 @@ document.addEventListener('DOMContentLoaded', () => {
   addEventListenerForPasswordEditFields()
   addAddPartyModalAfterSwapListener()
   addListenerForMovieAddedToPartiesEvent()
+  fillBrowserTimeZoneFields()
 })
 
 function addAddPartyModalAfterSwapListener() {
@@ -18,6 +19,27 @@ function addAddPartyModalAfterSwapListener() {
   })
 }
 
+// movie nights are saved in the time zone of whoever scheduled them, so fill in
+// the browser's zone for any field that doesn't have one yet
+function fillBrowserTimeZoneFields() {
+  const timeZone = Intl.DateTimeFormat().resolvedOptions().timeZone
+  if (!timeZone) {
+    return
+  }
+
+  document.querySelectorAll('[data-browser-time-zone]').forEach((field) => {
+    if (field.value === '') {
+      field.value = timeZone
+    }
+  })
+
+  document.querySelectorAll('[data-time-zone-label]').forEach((label) => {
+    if (label.textContent.trim() === '') {
+      label.textContent = `Times are in ${timeZone}`
+    }
+  })
+}
+
 function addListenerForMovieAddedToPartiesEvent() {
   document.body.addEventListener('MovieAddedToParties', function (evt) {
     const modal = bootstrap.Modal.getInstance(
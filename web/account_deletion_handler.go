@@ -0,0 +1,150 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// the form has a handoff-<id_party> field for each party the watcher owns, it's the member the party goes to or
+// "delete"
+const handoffFieldPrefix = "handoff-"
+
+// AccountDeletionShowHandler is where the logged in watcher asks for their account to be deleted, or sees when it's
+// going to be and can cancel
+func (a *Application) AccountDeletionShowHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AccountDeletionShowHandler")
+
+	profile, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewProfilesTemplateData(r, w, "/profile")
+	templateData.Profile = profile
+	templateData.DeletionGracePeriodDays = int(identityaccess.AccountDeletionGracePeriod.Hours() / 24)
+
+	templateData.OwnedParties, err = a.AccountDeletionService.GetOwnedParties(ctx, profile.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get owned parties", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	a.render(w, r, http.StatusOK, "profiles/delete.gohtml", templateData)
+}
+
+// AccountDeletionHandler schedules the logged in watcher's account to be deleted after they've confirmed their
+// password and said what should happen to the parties they own
+func (a *Application) AccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AccountDeletionHandler")
+
+	profile, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	newOwners, err := parseHandoffs(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse party handoffs", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.AccountDeletionService.RequestDeletion(ctx, logger, profile, r.PostForm.Get("password"), newOwners)
+	switch {
+	case errors.Is(err, identityaccess.ErrInvalidCredentials):
+		a.setErrorFlashMessage(w, r, "That password isn't right, your account hasn't been deleted.")
+		http.Redirect(w, r, "/profile/delete", http.StatusSeeOther)
+		return
+	case errors.Is(err, partymgmt.ErrInvalidHandoff):
+		a.setErrorFlashMessage(w, r, "Your parties can only be handed to someone who's in them, have another look.")
+		http.Redirect(w, r, "/profile/delete", http.StatusSeeOther)
+		return
+	case err != nil:
+		a.setErrorFlashMessage(w, r, "There was an issue deleting your account, try again.")
+		http.Redirect(w, r, "/profile/delete", http.StatusSeeOther)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, fmt.Sprintf("Your account will be deleted on %s, you can change your mind any time before then.", profile.DeletionScheduledFor.Format("January 2")))
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+// CancelAccountDeletionHandler keeps the logged in watcher's account
+func (a *Application) CancelAccountDeletionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "CancelAccountDeletionHandler")
+
+	profile, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	err = a.AccountDeletionService.CancelDeletion(ctx, logger, profile)
+	switch {
+	case errors.Is(err, identityaccess.ErrAccountDeletionNotScheduled):
+		a.setInfoFlashMessage(w, r, "Your account isn't going to be deleted.")
+	case err != nil:
+		a.setErrorFlashMessage(w, r, "There was an issue keeping your account, try again.")
+	default:
+		a.setInfoFlashMessage(w, r, "Welcome back! Your account won't be deleted.")
+	}
+
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+// parseHandoffs reads who each party goes to from the form, keyed by party with 0 for parties being deleted
+func parseHandoffs(r *http.Request) (map[int]int, error) {
+	newOwners := make(map[int]int)
+	for field, values := range r.PostForm {
+		partyID, found := strings.CutPrefix(field, handoffFieldPrefix)
+		if !found {
+			continue
+		}
+
+		idParty, err := strconv.Atoi(partyID)
+		if err != nil {
+			return nil, err
+		}
+
+		if values[0] == "delete" {
+			newOwners[idParty] = 0
+			continue
+		}
+
+		idNewOwner, err := strconv.Atoi(values[0])
+		if err != nil {
+			return nil, err
+		}
+
+		if idNewOwner <= 0 {
+			return nil, fmt.Errorf("invalid new owner %d for party %d", idNewOwner, idParty)
+		}
+
+		newOwners[idParty] = idNewOwner
+	}
+
+	return newOwners, nil
+}
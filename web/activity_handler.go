@@ -0,0 +1,97 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// PartyActivityHandler renders the page of the party's activity that's loaded when "Load more" is clicked
+func (a *Application) PartyActivityHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyActivityHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	idBefore, err := strconv.Atoi(r.URL.Query().Get("before"))
+	if err != nil || idBefore < 0 {
+		logger.ErrorContext(ctx, "invalid before", slog.String("before", r.URL.Query().Get("before")))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	page, err := a.PartyService.GetActivity(ctx, idParty, watcher.ID, idBefore)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party activity", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	a.renderPartial(w, r, http.StatusOK, "parties/partials/activity_items.gohtml", page)
+}
+
+// PartyActivityFeedHandler sends the party's activity as an Atom feed, feed readers can't log in so it's found by the
+// watcher's calendar token like their calendar feeds are
+func (a *Application) PartyActivityFeedHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyActivityFeedHandler")
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	idWatcher, err := a.MovieNightService.GetCalendarOwner(ctx, r.PathValue("token"))
+	if errors.Is(err, partymgmt.ErrCalendarNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get calendar owner", slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	feed, err := a.PartyService.GetActivityFeed(ctx, logger, idParty, idWatcher)
+	if errors.Is(err, partymgmt.ErrActivityFeedNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	partyURL := absoluteURL(r, fmt.Sprintf("/parties/%d", idParty))
+	err = feed.WriteAtom(w, absoluteURL(r, r.URL.Path), partyURL, time.Now())
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to write activity feed", slog.Any("error", err))
+	}
+}
@@ -0,0 +1,243 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// AdminHandler is the admin area's home, it searches accounts, parties and movies when there's something to search for
+func (a *Application) AdminHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AdminHandler")
+
+	admin, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewAdminTemplateData(r, w, "/admin")
+	templateData.SearchValue = strings.TrimSpace(r.URL.Query().Get("q"))
+
+	if templateData.SearchValue != "" {
+		templateData.SearchResults, err = a.AdminService.Search(ctx, logger, admin, templateData.SearchValue)
+		if err != nil {
+			a.adminError(w, r, logger, err)
+			return
+		}
+	}
+
+	a.render(w, r, http.StatusOK, "admin/index.gohtml", templateData)
+}
+
+// AdminUserHandler shows an account along with the parties it's in, the ones it's been invited to and the logins for
+// it that didn't work
+func (a *Application) AdminUserHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AdminUserHandler")
+
+	admin, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	profileID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	templateData := a.NewAdminTemplateData(r, w, "/admin")
+	templateData.Account, err = a.AdminService.GetAccount(ctx, logger, admin, profileID)
+	if errors.Is(err, identityaccess.ErrAccountNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		a.adminError(w, r, logger, err)
+		return
+	}
+
+	a.render(w, r, http.StatusOK, "admin/user.gohtml", templateData)
+}
+
+// AdminDisableUserHandler stops an account from logging in and logs it out of everywhere
+func (a *Application) AdminDisableUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.changeAccount(w, r, "AdminDisableUserHandler", "Account disabled, they've been logged out.", a.AdminService.DisableAccount)
+}
+
+// AdminEnableUserHandler lets a disabled account log in again
+func (a *Application) AdminEnableUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.changeAccount(w, r, "AdminEnableUserHandler", "Account enabled, they can log in again.", a.AdminService.EnableAccount)
+}
+
+// AdminLogoutUserHandler logs an account out of every session it has
+func (a *Application) AdminLogoutUserHandler(w http.ResponseWriter, r *http.Request) {
+	a.changeAccount(w, r, "AdminLogoutUserHandler", "They've been logged out everywhere.", a.AdminService.ForceLogout)
+}
+
+// changeAccount makes a change to the account of the profile in the path and sends the admin back to it
+func (a *Application) changeAccount(w http.ResponseWriter, r *http.Request, handler, successMsg string, change func(ctx context.Context, logger *slog.Logger, admin *identityaccess.Profile, profileID int) error) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", handler)
+
+	admin, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	profileID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	userPath := fmt.Sprintf("/admin/users/%d", profileID)
+
+	err = change(ctx, logger, admin, profileID)
+	switch {
+	case errors.Is(err, identityaccess.ErrAccountNotFound):
+		http.NotFound(w, r)
+		return
+	case errors.Is(err, identityaccess.ErrCannotDisableOwnAccount):
+		a.setErrorFlashMessage(w, r, "You can't disable your own account.")
+		http.Redirect(w, r, userPath, http.StatusSeeOther)
+		return
+	case errors.Is(err, identityaccess.ErrAccountAlreadyDisabled):
+		a.setErrorFlashMessage(w, r, "That account is already disabled.")
+		http.Redirect(w, r, userPath, http.StatusSeeOther)
+		return
+	case errors.Is(err, identityaccess.ErrAccountNotDisabled):
+		a.setErrorFlashMessage(w, r, "That account isn't disabled.")
+		http.Redirect(w, r, userPath, http.StatusSeeOther)
+		return
+	case err != nil:
+		a.adminError(w, r, logger, err)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, successMsg)
+	http.Redirect(w, r, userPath, http.StatusSeeOther)
+}
+
+// AdminRefreshMovieHandler refetches a saved movie from TMDB and goes back to the search it was found in
+func (a *Application) AdminRefreshMovieHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AdminRefreshMovieHandler")
+
+	admin, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	idMovie, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get movie ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	searchPath := "/admin?q=" + url.QueryEscape(r.PostForm.Get("q"))
+
+	movie, err := a.AdminService.RefreshMovie(ctx, logger, admin, idMovie)
+	switch {
+	case errors.Is(err, partymgmt.ErrMovieDoesNotExist):
+		a.setErrorFlashMessage(w, r, "That movie couldn't be found.")
+		http.Redirect(w, r, searchPath, http.StatusSeeOther)
+		return
+	case errors.Is(err, identityaccess.ErrNotAdmin):
+		a.adminError(w, r, logger, err)
+		return
+	case err != nil:
+		// TMDB being down shouldn't look like the app is broken
+		logger.ErrorContext(ctx, "failed to refresh movie", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "The movie couldn't be refreshed from TMDB, try again in a bit.")
+		http.Redirect(w, r, searchPath, http.StatusSeeOther)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, fmt.Sprintf("Refreshed %s from TMDB.", movie.Title))
+	http.Redirect(w, r, searchPath, http.StatusSeeOther)
+}
+
+// AdminFailedLoginsHandler shows the most recent logins that didn't work for every account
+func (a *Application) AdminFailedLoginsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AdminFailedLoginsHandler")
+
+	admin, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewAdminTemplateData(r, w, "/admin")
+	templateData.FailedLogins, err = a.AdminService.GetFailedLogins(ctx, logger, admin)
+	if err != nil {
+		a.adminError(w, r, logger, err)
+		return
+	}
+
+	a.render(w, r, http.StatusOK, "admin/failed_logins.gohtml", templateData)
+}
+
+// AdminAuditLogHandler shows the most recent things done in the admin area
+func (a *Application) AdminAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AdminAuditLogHandler")
+
+	admin, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewAdminTemplateData(r, w, "/admin")
+	templateData.AuditLog, err = a.AdminService.GetAuditLog(ctx, logger, admin)
+	if err != nil {
+		a.adminError(w, r, logger, err)
+		return
+	}
+
+	a.render(w, r, http.StatusOK, "admin/audit_log.gohtml", templateData)
+}
+
+// adminError responds to an admin request that failed, someone who isn't an admin is told the page doesn't exist the
+// same as the admin middleware does
+func (a *Application) adminError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error) {
+	if errors.Is(err, identityaccess.ErrNotAdmin) {
+		http.NotFound(w, r)
+		return
+	}
+
+	logger.ErrorContext(r.Context(), "admin request failed", slog.Any("error", err))
+	a.serverError(w, r, err)
+}
@@ -3,12 +3,15 @@ package web
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"html/template"
 	"log/slog"
 	"net/http"
 	"path/filepath"
+	"strings"
 
 	"github.com/gorilla/sessions"
 	"github.com/jm96441n/movieswithfriends/identityaccess"
@@ -37,6 +40,19 @@ type AppConfig struct {
 	ProfilesService          *identityaccess.ProfileService
 	ProfileAggregatorService *services.ProfileAggregatorService
 	InvitationsService       partymgmt.InvitationsService
+	ImportService            *partymgmt.ImportService
+	ExportService            partymgmt.ExportService
+	AccountExportService     *services.AccountExportService
+	AccountDeletionService   *services.AccountDeletionService
+	AdminService             *services.AdminService
+	PartyStatsService        partymgmt.PartyStatsService
+	RecapService             partymgmt.RecapService
+	MovieNightService        partymgmt.MovieNightService
+	NotificationService      partymgmt.NotificationService
+	CommentService           partymgmt.CommentService
+	WebhookService           *partymgmt.WebhookService
+	FriendsService           partymgmt.FriendsService
+	PublicPartyService       partymgmt.PublicPartyService
 	Auth                     *identityaccess.Authenticator
 	AssetLoader              *Loader
 }
@@ -54,6 +70,19 @@ type Application struct {
 	ProfilesService          *identityaccess.ProfileService
 	ProfileAggregatorService *services.ProfileAggregatorService
 	InvitationsService       partymgmt.InvitationsService
+	ImportService            *partymgmt.ImportService
+	ExportService            partymgmt.ExportService
+	AccountExportService     *services.AccountExportService
+	AccountDeletionService   *services.AccountDeletionService
+	AdminService             *services.AdminService
+	PartyStatsService        partymgmt.PartyStatsService
+	RecapService             partymgmt.RecapService
+	MovieNightService        partymgmt.MovieNightService
+	NotificationService      partymgmt.NotificationService
+	CommentService           partymgmt.CommentService
+	WebhookService           *partymgmt.WebhookService
+	FriendsService           partymgmt.FriendsService
+	PublicPartyService       partymgmt.PublicPartyService
 	Auth                     *identityaccess.Authenticator
 	AssetLoader              *Loader
 }
@@ -71,6 +100,19 @@ func NewApplication(cfg AppConfig) *Application {
 		ProfilesService:          cfg.ProfilesService,
 		ProfileAggregatorService: cfg.ProfileAggregatorService,
 		InvitationsService:       cfg.InvitationsService,
+		ImportService:            cfg.ImportService,
+		ExportService:            cfg.ExportService,
+		AccountExportService:     cfg.AccountExportService,
+		AccountDeletionService:   cfg.AccountDeletionService,
+		AdminService:             cfg.AdminService,
+		PartyStatsService:        cfg.PartyStatsService,
+		RecapService:             cfg.RecapService,
+		MovieNightService:        cfg.MovieNightService,
+		NotificationService:      cfg.NotificationService,
+		CommentService:           cfg.CommentService,
+		WebhookService:           cfg.WebhookService,
+		FriendsService:           cfg.FriendsService,
+		PublicPartyService:       cfg.PublicPartyService,
 		Auth:                     cfg.Auth,
 		AssetLoader:              cfg.AssetLoader,
 	}
@@ -152,6 +194,47 @@ func (a *Application) renderPartial(w http.ResponseWriter, r *http.Request, stat
 	buf.WriteTo(w)
 }
 
+// renderCacheable renders the page with an ETag of what's on it, a browser or proxy that already has the page gets a
+// 304 instead of it being sent again
+func (a *Application) renderCacheable(w http.ResponseWriter, r *http.Request, page string, data interface{}) {
+	ts, ok := a.templateCache[page]
+	if !ok {
+		a.serverError(w, r, fmt.Errorf("template does not exist for page %q", page))
+		return
+	}
+
+	buf := bytes.NewBuffer([]byte{})
+	err := ts.ExecuteTemplate(buf, "base", data)
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	w.Header().Set("ETag", etag)
+
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	buf.WriteTo(w)
+}
+
+// etagMatches is whether any of the ETags in an If-None-Match header is the etag, they're compared weakly since a page
+// is only ever sent whole
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
 func (a *Application) getAccountIDFromSession(ctx context.Context, r *http.Request) (int, error) {
 	_, span, _ := metrics.SpanFromContext(ctx, "getAccountIDFromSession")
 	defer span.End()
@@ -174,6 +257,21 @@ func (a *Application) getAccountIDFromSession(ctx context.Context, r *http.Reque
 	return accountID, nil
 }
 
+// getSessionVersionFromSession returns the session version the account had when it logged in, sessions from before
+// there were versions are on the first one
+func (a *Application) getSessionVersionFromSession(ctx context.Context, r *http.Request) int {
+	_, span, _ := metrics.SpanFromContext(ctx, "getSessionVersionFromSession")
+	defer span.End()
+
+	session, err := a.SessionStore.Get(r, sessionName)
+	if err != nil {
+		return 0
+	}
+
+	sessionVersion, _ := session.Values["sessionVersion"].(int)
+	return sessionVersion
+}
+
 func (a *Application) getProfileFromSession(r *http.Request) (*identityaccess.Profile, error) {
 	ctx, span, _ := metrics.SpanFromContext(r.Context(), "Application.getProfileFromSession")
 	defer span.End()
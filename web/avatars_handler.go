@@ -0,0 +1,111 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/jm96441n/movieswithfriends/identityaccess"
+)
+
+// avatarFormOverhead is room for the rest of the multipart form around the picture, the picture itself is held to
+// identityaccess.MaxAvatarUploadSize when it's processed
+const avatarFormOverhead = 64 << 10
+
+// ProfileAvatarHandler replaces the logged in watcher's avatar with the uploaded image
+func (a *Application) ProfileAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "ProfileAvatarHandler")
+
+	profile, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, identityaccess.MaxAvatarUploadSize+avatarFormOverhead)
+	err = r.ParseMultipartForm(identityaccess.MaxAvatarUploadSize + avatarFormOverhead)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse upload", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "The picture couldn't be uploaded, pictures can be at most 5MB.")
+		http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+		return
+	}
+
+	file, _, err := r.FormFile("avatar")
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get file from form", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "Choose a picture to upload.")
+		http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+		return
+	}
+
+	defer file.Close()
+
+	err = a.ProfilesService.SetAvatar(ctx, logger, profile, file)
+	switch {
+	case errors.Is(err, identityaccess.ErrAvatarTooLarge) || errors.Is(err, identityaccess.ErrAvatarDimensionsTooLarge) || errors.Is(err, identityaccess.ErrUnsupportedAvatarFormat):
+		a.setErrorFlashMessage(w, r, fmt.Sprintf("That picture couldn't be used, %s.", err))
+	case err != nil:
+		a.setErrorFlashMessage(w, r, "There was an issue uploading your picture, try again.")
+	default:
+		a.setInfoFlashMessage(w, r, "Updated your picture!")
+	}
+
+	http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+}
+
+// DeleteProfileAvatarHandler takes the logged in watcher back to not having an avatar
+func (a *Application) DeleteProfileAvatarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "DeleteProfileAvatarHandler")
+
+	profile, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	err = a.ProfilesService.RemoveAvatar(ctx, logger, profile)
+	if err != nil {
+		a.setErrorFlashMessage(w, r, "There was an issue removing your picture, try again.")
+	} else {
+		a.setInfoFlashMessage(w, r, "Removed your picture.")
+	}
+
+	http.Redirect(w, r, "/profile/edit", http.StatusSeeOther)
+}
+
+// AvatarHandler serves an uploaded avatar. a new avatar is stored under a new name so what's at a name never changes
+// and browsers can keep it for as long as they like
+func (a *Application) AvatarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AvatarHandler")
+
+	avatar, err := a.ProfilesService.GetAvatar(ctx, r.PathValue("name"))
+	if errors.Is(err, identityaccess.ErrAvatarNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get avatar", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	defer avatar.Close()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+
+	_, err = io.Copy(w, avatar)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to write avatar", slog.Any("error", err))
+	}
+}
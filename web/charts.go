@@ -0,0 +1,132 @@
+package web
+
+import "strconv"
+
+// the charts are laid out here and drawn as SVG by the chart partials so pages don't need a JS charting library, sizes
+// are in SVG user units and the charts scale to fit their container through the viewBox
+
+const (
+	columnChartWidth        = 600.0
+	columnChartHeight       = 220.0
+	columnChartLabelSpace   = 24.0
+	columnChartValueSpace   = 18.0
+	columnChartBarFraction  = 0.6
+	barChartWidth           = 600.0
+	barChartRowHeight       = 28.0
+	barChartLabelWidth      = 150.0
+	barChartValueSpace      = 40.0
+	barChartBarHeightFactor = 0.7
+)
+
+// ChartBar is one bar in a chart along with where its label and value are drawn
+type ChartBar struct {
+	Label  string
+	Value  int
+	X      float64
+	Y      float64
+	Width  float64
+	Height float64
+	// LabelX and LabelY are where the label is anchored, ValueX and ValueY are where the value is
+	LabelX float64
+	LabelY float64
+	ValueX float64
+	ValueY float64
+}
+
+type Chart struct {
+	Width  float64
+	Height float64
+	// Baseline is the y coordinate of the axis the columns of a column chart stand on
+	Baseline float64
+	Bars     []ChartBar
+}
+
+// ViewBox is the value for the svg element's viewBox attribute
+func (c Chart) ViewBox() string {
+	return "0 0 " + strconv.FormatFloat(c.Width, 'f', -1, 64) + " " + strconv.FormatFloat(c.Height, 'f', -1, 64)
+}
+
+// newColumnChart lays out vertical bars side by side with the labels underneath them, labels and values must be the
+// same length
+func newColumnChart(labels []string, values []int) Chart {
+	chart := Chart{
+		Width:    columnChartWidth,
+		Height:   columnChartHeight,
+		Baseline: columnChartHeight - columnChartLabelSpace,
+		Bars:     make([]ChartBar, 0, len(values)),
+	}
+
+	if len(values) == 0 {
+		return chart
+	}
+
+	maxValue := maxChartValue(values)
+	plotHeight := chart.Baseline - columnChartValueSpace
+	slotWidth := chart.Width / float64(len(values))
+	barWidth := slotWidth * columnChartBarFraction
+
+	for i, value := range values {
+		height := float64(value) / float64(maxValue) * plotHeight
+		center := slotWidth*float64(i) + slotWidth/2
+		chart.Bars = append(chart.Bars, ChartBar{
+			Label:  labels[i],
+			Value:  value,
+			X:      center - barWidth/2,
+			Y:      chart.Baseline - height,
+			Width:  barWidth,
+			Height: height,
+			LabelX: center,
+			LabelY: chart.Height - columnChartLabelSpace/3,
+			ValueX: center,
+			ValueY: chart.Baseline - height - 4,
+		})
+	}
+
+	return chart
+}
+
+// newBarChart lays out horizontal bars one above the other with the labels to their left, labels and values must be
+// the same length
+func newBarChart(labels []string, values []int) Chart {
+	chart := Chart{
+		Width:  barChartWidth,
+		Height: barChartRowHeight * float64(max(len(values), 1)),
+		Bars:   make([]ChartBar, 0, len(values)),
+	}
+
+	if len(values) == 0 {
+		return chart
+	}
+
+	maxValue := maxChartValue(values)
+	plotWidth := chart.Width - barChartLabelWidth - barChartValueSpace
+	barHeight := barChartRowHeight * barChartBarHeightFactor
+
+	for i, value := range values {
+		width := float64(value) / float64(maxValue) * plotWidth
+		middle := barChartRowHeight*float64(i) + barChartRowHeight/2
+		chart.Bars = append(chart.Bars, ChartBar{
+			Label:  labels[i],
+			Value:  value,
+			X:      barChartLabelWidth,
+			Y:      middle - barHeight/2,
+			Width:  width,
+			Height: barHeight,
+			LabelX: barChartLabelWidth - 8,
+			LabelY: middle,
+			ValueX: barChartLabelWidth + width + 6,
+			ValueY: middle,
+		})
+	}
+
+	return chart
+}
+
+// maxChartValue is the largest value, never less than 1 so an empty chart doesn't divide by zero
+func maxChartValue(values []int) int {
+	maxValue := 1
+	for _, value := range values {
+		maxValue = max(maxValue, value)
+	}
+	return maxValue
+}
@@ -0,0 +1,211 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// CommentsHandler shows the discussion about a party's movie, htmx loads it into the party page as a panel and
+// everything else gets it as its own page
+func (a *Application) CommentsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "CommentsHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	a.renderComments(w, r, logger, idParty, idMovie, watcher.ID, "")
+}
+
+func (a *Application) CreateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "CreateCommentHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	// comments that start a thread don't have a parent
+	idParent := 0
+	if rawParent := r.PostForm.Get("parent_id"); rawParent != "" {
+		idParent, err = strconv.Atoi(rawParent)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid parent comment", slog.String("parent_id", rawParent))
+			a.clientError(w, r, http.StatusBadRequest, "uh oh")
+			return
+		}
+	}
+
+	mentioned, err := a.CommentService.CreateComment(ctx, logger, idParty, idMovie, watcher.ID, idParent, r.PostForm.Get("body"))
+	if err == nil {
+		a.NotificationService.NotifyMentioned(ctx, logger, idParty, idMovie, watcher.ID, mentioned)
+	}
+
+	a.handleCommentChange(w, r, logger, idParty, idMovie, watcher.ID, err, "Your comment has been posted.")
+}
+
+func (a *Application) EditCommentHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "EditCommentHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, idComment, ok := a.getCommentIDsFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	mentioned, err := a.CommentService.EditComment(ctx, logger, idParty, idMovie, idComment, watcher.ID, r.PostForm.Get("body"))
+	if err == nil {
+		a.NotificationService.NotifyMentioned(ctx, logger, idParty, idMovie, watcher.ID, mentioned)
+	}
+
+	a.handleCommentChange(w, r, logger, idParty, idMovie, watcher.ID, err, "Your comment has been updated.")
+}
+
+func (a *Application) DeleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "DeleteCommentHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, idComment, ok := a.getCommentIDsFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	err = a.CommentService.DeleteComment(ctx, logger, idParty, idMovie, idComment, watcher.ID)
+	a.handleCommentChange(w, r, logger, idParty, idMovie, watcher.ID, err, "The comment has been deleted.")
+}
+
+// handleCommentChange shows the discussion again once a comment has been changed, htmx gets the panel back with
+// anything the watcher can fix in it and everything else is redirected to the discussion's page with a flash
+func (a *Application) handleCommentChange(
+	w http.ResponseWriter,
+	r *http.Request,
+	logger *slog.Logger,
+	idParty, idMovie, idWatcher int,
+	err error,
+	successMsg string,
+) {
+	var errorMsg string
+	switch {
+	case errors.Is(err, partymgmt.ErrNotPartyMember), errors.Is(err, partymgmt.ErrPartyMovieNotFound):
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	case errors.Is(err, partymgmt.ErrInvalidComment),
+		errors.Is(err, partymgmt.ErrCannotEditComment),
+		errors.Is(err, partymgmt.ErrCannotDeleteComment):
+		errorMsg = fmt.Sprintf("Sorry, %s.", err)
+	case errors.Is(err, partymgmt.ErrCommentNotFound):
+		errorMsg = "That comment has been deleted."
+	case err != nil:
+		logger.ErrorContext(r.Context(), "failed to change comment", slog.Any("error", err))
+		errorMsg = "There was an issue saving the comment, try again."
+	}
+
+	if r.Header.Get("HX-Request") != "" {
+		a.renderComments(w, r, logger, idParty, idMovie, idWatcher, errorMsg)
+		return
+	}
+
+	if errorMsg != "" {
+		a.setErrorFlashMessage(w, r, errorMsg)
+	} else {
+		a.setInfoFlashMessage(w, r, successMsg)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/movies/%d/comments", idParty, idMovie), http.StatusSeeOther)
+}
+
+func (a *Application) renderComments(w http.ResponseWriter, r *http.Request, logger *slog.Logger, idParty, idMovie, idWatcher int, errorMsg string) {
+	ctx := r.Context()
+
+	thread, err := a.CommentService.GetThread(ctx, idParty, idMovie, idWatcher)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) || errors.Is(err, partymgmt.ErrPartyMovieNotFound) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get comments", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "" {
+		templateData := CommentsTemplateData{Thread: thread, ErrorMsg: errorMsg}
+		a.renderPartial(w, r, http.StatusOK, "parties/partials/comments.gohtml", templateData)
+		return
+	}
+
+	templateData := a.NewCommentsTemplateData(r, w, "/parties", thread)
+	templateData.ErrorMsg = errorMsg
+	a.render(w, r, http.StatusOK, "parties/comments.gohtml", templateData)
+}
+
+func (a *Application) getCommentIDsFromPath(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (int, int, int, bool) {
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return 0, 0, 0, false
+	}
+
+	idComment, err := strconv.Atoi(r.PathValue("comment_id"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to get comment ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return 0, 0, 0, false
+	}
+
+	return idParty, idMovie, idComment, true
+}
@@ -0,0 +1,110 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+func (a *Application) PartyExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyExportHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	format, err := partymgmt.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		logger.ErrorContext(ctx, "invalid export format", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.ExportService.CanExportParty(ctx, idParty, watcher.ID)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to check party membership", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	setAttachmentHeaders(w, format.ContentType(), format.Filename(fmt.Sprintf("party-%d-movies", idParty)))
+
+	// the headers are already sent once the export starts so a failure part way through can only be logged
+	_ = a.ExportService.ExportPartyMovies(ctx, logger, idParty, format, w)
+}
+
+func (a *Application) WatchHistoryExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "WatchHistoryExportHandler")
+
+	profileID, err := a.getProfileIDFromSession(ctx, r)
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	format, err := partymgmt.ParseExportFormat(r.URL.Query().Get("format"))
+	if err != nil {
+		logger.ErrorContext(ctx, "invalid export format", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	setAttachmentHeaders(w, format.ContentType(), format.Filename("watch-history"))
+
+	_ = a.ExportService.ExportWatchHistory(ctx, logger, profileID, format, w)
+}
+
+func (a *Application) AccountExportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AccountExportHandler")
+
+	profileID, err := a.getProfileIDFromSession(ctx, r)
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	export, err := a.AccountExportService.LoadAccountExport(ctx, profileID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load account data", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	filename := fmt.Sprintf("movieswithfriends-%s.zip", time.Now().Format("2006-01-02"))
+	setAttachmentHeaders(w, "application/zip", filename)
+
+	err = export.Write(ctx, logger, w)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to export account data", slog.Any("error", err))
+	}
+}
+
+func setAttachmentHeaders(w http.ResponseWriter, contentType, filename string) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	w.Header().Set("Cache-Control", "no-store")
+}
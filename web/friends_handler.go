@@ -0,0 +1,155 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+type InviteSuggestionsTemplateData struct {
+	PartyID     int
+	Search      string
+	Suggestions []partymgmt.InviteSuggestion
+}
+
+// InviteSuggestionsHandler suggests friends and people from the watcher's other parties to invite as they type a
+// name into the invite modal
+func (a *Application) InviteSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "InviteSuggestionsHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	search := r.URL.Query().Get("q")
+	suggestions, err := a.FriendsService.GetInviteSuggestions(ctx, idParty, watcher.ID, search)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		a.clientError(w, r, http.StatusNotFound, "uh oh")
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get invite suggestions", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := InviteSuggestionsTemplateData{
+		PartyID:     idParty,
+		Search:      search,
+		Suggestions: suggestions,
+	}
+	a.renderPartial(w, r, http.StatusOK, "parties/partials/invite_suggestions.gohtml", templateData)
+}
+
+// SendFriendRequestHandler sends a friend request to one of the watcher's suggested contacts or to whoever has the
+// email they typed in
+func (a *Application) SendFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "SendFriendRequestHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	if rawFriend := r.PostForm.Get("friend_id"); rawFriend != "" {
+		idFriend, convErr := strconv.Atoi(rawFriend)
+		if convErr != nil {
+			logger.ErrorContext(ctx, "invalid friend", slog.String("friend_id", rawFriend))
+			a.clientError(w, r, http.StatusBadRequest, "uh oh")
+			return
+		}
+		err = a.FriendsService.SendFriendRequest(ctx, logger, watcher.ID, idFriend)
+	} else {
+		err = a.FriendsService.SendFriendRequestByEmail(ctx, logger, a.WatcherService, watcher.ID, r.PostForm.Get("email"))
+	}
+
+	a.handleFriendChange(w, r, logger, err, "Your friend request has been sent.")
+}
+
+func (a *Application) AcceptFriendRequestHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AcceptFriendRequestHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idFriendship, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get friendship ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.FriendsService.AcceptFriendRequest(ctx, logger, watcher.ID, idFriendship)
+	a.handleFriendChange(w, r, logger, err, "You're now friends.")
+}
+
+// RemoveFriendHandler removes a friend, and declines or cancels a friend request
+func (a *Application) RemoveFriendHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "RemoveFriendHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idFriendship, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get friendship ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.FriendsService.RemoveFriend(ctx, logger, watcher.ID, idFriendship)
+	a.handleFriendChange(w, r, logger, err, "Done, they've been removed from your friends.")
+}
+
+// handleFriendChange sends the watcher back to the friends on their profile with how the change went
+func (a *Application) handleFriendChange(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error, successMsg string) {
+	switch {
+	case err == nil:
+		a.setInfoFlashMessage(w, r, successMsg)
+	case errors.Is(err, partymgmt.ErrWatcherNotFound):
+		a.setErrorFlashMessage(w, r, "We couldn't find that person.")
+	case errors.Is(err, partymgmt.ErrFriendNotFound), errors.Is(err, partymgmt.ErrFriendRequestNotFound):
+		a.setErrorFlashMessage(w, r, "That friend request has already been answered.")
+	case errors.Is(err, partymgmt.ErrCannotFriendSelf),
+		errors.Is(err, partymgmt.ErrAlreadyFriends),
+		errors.Is(err, partymgmt.ErrFriendRequestPending):
+		a.setErrorFlashMessage(w, r, fmt.Sprintf("Sorry, %s.", err))
+	default:
+		logger.ErrorContext(r.Context(), "failed to change friends", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue updating your friends, try again.")
+	}
+
+	http.Redirect(w, r, "/profile#friends", http.StatusSeeOther)
+}
@@ -0,0 +1,258 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// htmxStopPollingStatus tells htmx to stop polling once an import has finished
+const htmxStopPollingStatus = 286
+
+func (a *Application) NewImportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "NewImportHandler")
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	templateData := a.NewImportsTemplateData(r, w, "/parties", idParty)
+	a.render(w, r, http.StatusOK, "imports/new.gohtml", templateData)
+}
+
+func (a *Application) CreateImportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "CreateImportHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idPartyParam := r.PathValue("party_id")
+	idParty, err := strconv.Atoi(idPartyParam)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	newImportPath := fmt.Sprintf("/parties/%d/imports/new", idParty)
+
+	r.Body = http.MaxBytesReader(w, r.Body, partymgmt.MaxImportFileSize)
+	err = r.ParseMultipartForm(partymgmt.MaxImportFileSize)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse upload", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "The file couldn't be uploaded, files can be at most 1MB.")
+		http.Redirect(w, r, newImportPath, http.StatusSeeOther)
+		return
+	}
+
+	file, header, err := r.FormFile("watchlist")
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get file from form", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "Choose a file to import.")
+		http.Redirect(w, r, newImportPath, http.StatusSeeOther)
+		return
+	}
+
+	defer file.Close()
+
+	idImport, err := a.ImportService.PreviewImport(ctx, logger, idParty, watcher.ID, header.Filename, file)
+	if errors.Is(err, partymgmt.ErrImportEmpty) || errors.Is(err, partymgmt.ErrImportMissingColumns) || errors.Is(err, partymgmt.ErrImportTooManyRows) {
+		a.setErrorFlashMessage(w, r, fmt.Sprintf("That file couldn't be imported, %s.", err))
+		http.Redirect(w, r, newImportPath, http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to preview import", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an error reading that file, make sure it's a CSV and try again.")
+		http.Redirect(w, r, newImportPath, http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/imports/%d", idParty, idImport), http.StatusSeeOther)
+}
+
+func (a *Application) ImportShowHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "ImportShowHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	imp, ok := a.getImportFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	// only the person who uploaded the file gets to decide what's imported from it
+	if imp.IsPreviewing() && imp.IDWatcher != watcher.ID {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	templateData := a.NewImportsTemplateData(r, w, "/parties", imp.IDParty)
+	templateData.Import = imp
+	a.render(w, r, http.StatusOK, "imports/show.gohtml", templateData)
+}
+
+func (a *Application) ConfirmImportHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "ConfirmImportHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	imp, ok := a.getImportFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	if imp.IDWatcher != watcher.ID {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	importPath := fmt.Sprintf("/parties/%d/imports/%d", imp.IDParty, imp.ID)
+
+	selections, err := parseImportSelections(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse import selections", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue with the movies you picked, try again.")
+		http.Redirect(w, r, importPath, http.StatusSeeOther)
+		return
+	}
+
+	if len(selections) == 0 {
+		a.setErrorFlashMessage(w, r, "Pick at least one movie to import.")
+		http.Redirect(w, r, importPath, http.StatusSeeOther)
+		return
+	}
+
+	err = a.ImportService.ConfirmImport(ctx, logger, imp, selections)
+	if errors.Is(err, partymgmt.ErrImportAlreadyConfirmed) {
+		a.setInfoFlashMessage(w, r, "This import has already been started.")
+		http.Redirect(w, r, fmt.Sprintf("/parties/%d", imp.IDParty), http.StatusSeeOther)
+		return
+	}
+
+	if errors.Is(err, partymgmt.ErrInvalidImportSelection) {
+		logger.ErrorContext(ctx, "invalid import selection", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue with the movies you picked, try again.")
+		http.Redirect(w, r, importPath, http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to confirm import", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, fmt.Sprintf("Importing %d movies, they'll show up as they're added.", len(selections)))
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d", imp.IDParty), http.StatusSeeOther)
+}
+
+func (a *Application) ImportProgressHandler(w http.ResponseWriter, r *http.Request) {
+	logger := a.Logger.With("handler", "ImportProgressHandler")
+
+	imp, ok := a.getImportFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	status := http.StatusOK
+	if imp.IsFinished() {
+		status = htmxStopPollingStatus
+	}
+
+	a.renderPartial(w, r, status, "partials/import_progress.gohtml", imp)
+}
+
+// getImportFromPath loads the import in the path, writing the error response and returning false if it can't be found
+// or isn't for the party in the path
+func (a *Application) getImportFromPath(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (partymgmt.Import, bool) {
+	ctx := r.Context()
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return partymgmt.Import{}, false
+	}
+
+	idImport, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get import ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return partymgmt.Import{}, false
+	}
+
+	imp, err := a.ImportService.GetImport(ctx, idImport)
+	if errors.Is(err, partymgmt.ErrImportNotFound) || (err == nil && imp.IDParty != idParty) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return partymgmt.Import{}, false
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get import", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return partymgmt.Import{}, false
+	}
+
+	return imp, true
+}
+
+// parseImportSelections reads the movie picked for each line of the import, the fields are named line_<line> and an
+// empty value means the line was skipped
+func parseImportSelections(r *http.Request) (map[int]int, error) {
+	selections := make(map[int]int)
+	for key, values := range r.PostForm {
+		lineParam, ok := strings.CutPrefix(key, "line_")
+		if !ok || len(values) == 0 || values[0] == "" {
+			continue
+		}
+
+		line, err := strconv.Atoi(lineParam)
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %q: %w", lineParam, err)
+		}
+
+		tmdbID, err := strconv.Atoi(values[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid movie for line %d: %w", line, err)
+		}
+
+		selections[line] = tmdbID
+	}
+
+	return selections, nil
+}
@@ -22,7 +22,7 @@ func (a *Application) CreateInviteHandler(w http.ResponseWriter, r *http.Request
 	defer span.End()
 	logger := a.Logger.With("handler", "InvitationsHandler")
 
-	partyID, email, err := parseInviteForm(r)
+	partyID, idFriend, email, err := parseInviteForm(r)
 	if err != nil {
 		logger.ErrorContext(ctx, "Failed to parse form", slog.Any("error", err))
 		a.setErrorFlashMessage(w, r, "There was an error inviting this user, try again.")
@@ -34,10 +34,26 @@ func (a *Application) CreateInviteHandler(w http.ResponseWriter, r *http.Request
 		PartyID:   partyID,
 		ShowModal: true,
 	}
-	err = a.InvitationsService.CreateInvite(ctx, a.WatcherService, partyID, email)
+	// the invite stands without the inviter's name on the notification and activity log
+	idInviter, err := a.getProfileIDFromSession(ctx, r)
+	if err != nil {
+		logger.ErrorContext(ctx, "Failed to get inviter from session", slog.Any("error", err))
+	}
+
+	// suggested contacts are invited by id so their email is never sent to the inviter
+	if idFriend != 0 {
+		email, err = a.FriendsService.GetContactEmail(ctx, idInviter, idFriend)
+	}
+
+	if err == nil {
+		err = a.InvitationsService.CreateInvite(ctx, logger, a.WatcherService, partyID, idInviter, email)
+	}
+
 	if err != nil {
 		logger.ErrorContext(ctx, "Failed to create invite", slog.Any("error", err))
 		templateData.CreateErrorMsg = "There was an error inviting this member, try again."
+	} else {
+		a.NotificationService.NotifyInvited(ctx, logger, partyID, idInviter, email)
 	}
 
 	invited, err := a.InvitationsService.GetInvitationsForParty(ctx, partyID)
@@ -115,18 +131,28 @@ func (a *Application) AcceptInviteHandler(w http.ResponseWriter, r *http.Request
 	// and then cause a re-render of the parties listing
 }
 
-func parseInviteForm(r *http.Request) (int, string, error) {
+// parseInviteForm returns who is being invited to which party, they're either one of the inviter's contacts picked
+// from the suggestions or an email that was typed in
+func parseInviteForm(r *http.Request) (int, int, string, error) {
 	err := r.ParseForm()
 	if err != nil {
-		return 0, "", err
+		return 0, 0, "", err
 	}
 
 	partyID, err := strconv.Atoi(r.FormValue("partyID"))
 	if err != nil {
-		return 0, "", err
+		return 0, 0, "", err
+	}
+
+	idFriend := 0
+	if rawFriend := r.FormValue("friendID"); rawFriend != "" {
+		idFriend, err = strconv.Atoi(rawFriend)
+		if err != nil {
+			return 0, 0, "", err
+		}
 	}
 
 	email := r.FormValue("email")
 
-	return partyID, email, nil
+	return partyID, idFriend, email, nil
 }
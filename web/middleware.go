@@ -6,17 +6,22 @@ import (
 	"log/slog"
 	"net/http"
 
+	"github.com/jm96441n/movieswithfriends/identityaccess"
 	"github.com/jm96441n/movieswithfriends/metrics"
 )
 
 type contextKey string
 
 const (
-	isAuthenticatedContextKey = contextKey("isAuthenticated")
-	fullNameContextKey        = contextKey("fullName")
-	currentPartyIDContextKey  = contextKey("currentPartyID")
-	emailContextKey           = contextKey("email")
-	sessionName               = "moviesWithFriendsCookie"
+	isAuthenticatedContextKey      = contextKey("isAuthenticated")
+	isAdminContextKey              = contextKey("isAdmin")
+	displayNameContextKey          = contextKey("displayName")
+	avatarKeyContextKey            = contextKey("avatarKey")
+	deletionScheduledForContextKey = contextKey("deletionScheduledFor")
+	currentPartyIDContextKey       = contextKey("currentPartyID")
+	emailContextKey                = contextKey("email")
+	languageContextKey             = contextKey("language")
+	sessionName                    = "moviesWithFriendsCookie"
 )
 
 func (a *Application) authenticateMiddleware() func(http.HandlerFunc) http.HandlerFunc {
@@ -54,9 +59,26 @@ func (a *Application) authenticateMiddleware() func(http.HandlerFunc) http.Handl
 					return
 				}
 
+				// sessions end when the account is disabled or an admin logs it out of everywhere
+				if !profile.Account.CanUseSession(a.getSessionVersionFromSession(ctx, req)) {
+					logger.InfoContext(ctx, "session has ended, logging user out", slog.Int("accountID", profile.Account.ID))
+					a.logout(w, req)
+					if profile.Account.Disabled() {
+						a.setErrorFlashMessage(w, req, "This account has been disabled.")
+					} else {
+						a.setErrorFlashMessage(w, req, "Your session has ended, please log in again.")
+					}
+					http.Redirect(w, req, "/login", http.StatusSeeOther)
+					return
+				}
+
 				ctx := context.WithValue(req.Context(), isAuthenticatedContextKey, true)
+				ctx = context.WithValue(ctx, isAdminContextKey, profile.Account.IsAdmin())
 				ctx = context.WithValue(ctx, emailContextKey, profile.Account.Email)
-				ctx = context.WithValue(ctx, fullNameContextKey, profile.FirstName+" "+profile.LastName)
+				ctx = context.WithValue(ctx, displayNameContextKey, profile.Name())
+				ctx = context.WithValue(ctx, avatarKeyContextKey, profile.AvatarKey)
+				ctx = context.WithValue(ctx, deletionScheduledForContextKey, profile.DeletionScheduledFor)
+				ctx = context.WithValue(ctx, languageContextKey, profile.PreferredLanguage)
 
 				req = req.WithContext(ctx)
 			}
@@ -84,6 +106,30 @@ func (a *Application) authenticatedMiddleware() func(http.HandlerFunc) http.Hand
 	}
 }
 
+// adminMiddleware only lets admins through, everyone else is told the page doesn't exist so the admin area isn't
+// advertised. It's checked on top of being logged in
+func (a *Application) adminMiddleware() func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			ctx, span, _ := metrics.SpanFromContext(req.Context(), "adminMiddleware")
+			defer span.End()
+
+			if !isAdmin(ctx) {
+				a.Logger.WarnContext(ctx, "non admin tried to reach the admin area", slog.String("path", req.URL.Path))
+				http.NotFound(w, req)
+				return
+			}
+
+			next.ServeHTTP(w, req)
+		})
+	}
+}
+
+func isAdmin(ctx context.Context) bool {
+	isAdmin, ok := ctx.Value(isAdminContextKey).(bool)
+	return ok && isAdmin
+}
+
 func isAuthenticated(ctx context.Context) bool {
 	ctx, span, _ := metrics.SpanFromContext(ctx, "isAuthenticated")
 	defer span.End()
@@ -93,3 +139,12 @@ func isAuthenticated(ctx context.Context) bool {
 	}
 	return isAuthenticated.(bool)
 }
+
+// preferredLanguage returns the language of the logged in user, or the default language when nobody is logged in
+func preferredLanguage(ctx context.Context) string {
+	language, ok := ctx.Value(languageContextKey).(string)
+	if !ok || language == "" {
+		return identityaccess.DefaultLanguage
+	}
+	return language
+}
@@ -0,0 +1,439 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+func (a *Application) MovieNightsIndexHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "MovieNightsIndexHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	nights, err := a.MovieNightService.GetPartyMovieNights(ctx, logger, idParty, watcher.ID)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, fmt.Sprintf("/parties/%d", idParty))
+		return
+	}
+
+	templateData := a.NewMovieNightsTemplateData(r, w, "/parties", idParty)
+	templateData.MovieNights = nights
+
+	// the page still works without the calendar feed
+	token, err := a.MovieNightService.GetCalendarToken(ctx, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get calendar token", slog.Any("error", err))
+	} else {
+		templateData.CalendarURL = absoluteURL(r, fmt.Sprintf("/calendars/%s/parties/%d/movie_nights.ics", token, idParty))
+	}
+
+	a.render(w, r, http.StatusOK, "movie_nights/index.gohtml", templateData)
+}
+
+func (a *Application) NewMovieNightHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "NewMovieNightHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	// only members see the party's movies in the form
+	_, err = a.MovieNightService.GetPartyMovieNights(ctx, logger, idParty, watcher.ID)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, fmt.Sprintf("/parties/%d", idParty))
+		return
+	}
+
+	a.renderMovieNightForm(w, r, logger, idParty, partymgmt.MovieNight{ReminderMinutes: 60})
+}
+
+func (a *Application) CreateMovieNightHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "CreateMovieNightHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	newPath := fmt.Sprintf("/parties/%d/movie_nights/new", idParty)
+
+	input, err := movieNightInputFromRequest(r)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, newPath)
+		return
+	}
+
+	_, err = a.MovieNightService.CreateMovieNight(ctx, logger, idParty, watcher.ID, input)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, newPath)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, "Movie night scheduled!")
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/movie_nights", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) EditMovieNightHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "EditMovieNightHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovieNight, ok := a.getMovieNightIDsFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	night, err := a.MovieNightService.GetMovieNight(ctx, logger, idParty, idMovieNight, watcher.ID)
+	if err == nil && !night.CanManage {
+		err = partymgmt.ErrNotMovieNightOrganizer
+	}
+
+	if err == nil && night.IsCompleted() {
+		err = partymgmt.ErrMovieNightCompleted
+	}
+
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, fmt.Sprintf("/parties/%d/movie_nights", idParty))
+		return
+	}
+
+	a.renderMovieNightForm(w, r, logger, idParty, night)
+}
+
+func (a *Application) UpdateMovieNightHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "UpdateMovieNightHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovieNight, ok := a.getMovieNightIDsFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	editPath := fmt.Sprintf("/parties/%d/movie_nights/%d/edit", idParty, idMovieNight)
+
+	input, err := movieNightInputFromRequest(r)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, editPath)
+		return
+	}
+
+	err = a.MovieNightService.UpdateMovieNight(ctx, logger, idParty, idMovieNight, watcher.ID, input)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, editPath)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, "Movie night updated!")
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/movie_nights", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) DeleteMovieNightHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "DeleteMovieNightHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovieNight, ok := a.getMovieNightIDsFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	indexPath := fmt.Sprintf("/parties/%d/movie_nights", idParty)
+
+	err = a.MovieNightService.DeleteMovieNight(ctx, logger, idParty, idMovieNight, watcher.ID)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, indexPath)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, "Movie night cancelled.")
+	http.Redirect(w, r, indexPath, http.StatusSeeOther)
+}
+
+func (a *Application) RSVPMovieNightHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "RSVPMovieNightHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovieNight, ok := a.getMovieNightIDsFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	response, err := partymgmt.ParseRSVPResponse(r.PostForm.Get("response"))
+	if err != nil {
+		logger.ErrorContext(ctx, "invalid rsvp response", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	indexPath := fmt.Sprintf("/parties/%d/movie_nights", idParty)
+
+	night, err := a.MovieNightService.SetRSVP(ctx, logger, idParty, idMovieNight, watcher.ID, response)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, indexPath)
+		return
+	}
+
+	if r.Header.Get("HX-Request") != "" {
+		a.renderPartial(w, r, http.StatusOK, "movie_nights/partials/movie_night.gohtml", night)
+		return
+	}
+
+	http.Redirect(w, r, indexPath, http.StatusSeeOther)
+}
+
+func (a *Application) CompleteMovieNightHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "CompleteMovieNightHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovieNight, ok := a.getMovieNightIDsFromPath(w, r, logger)
+	if !ok {
+		return
+	}
+
+	indexPath := fmt.Sprintf("/parties/%d/movie_nights", idParty)
+
+	night, err := a.MovieNightService.CompleteMovieNight(ctx, logger, idParty, idMovieNight, watcher.ID)
+	if err != nil {
+		a.handleMovieNightError(w, r, logger, err, indexPath)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, fmt.Sprintf("%s has been marked as watched.", night.Movie.Title))
+	http.Redirect(w, r, indexPath, http.StatusSeeOther)
+}
+
+func (a *Application) ResetCalendarTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "ResetCalendarTokenHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	_, err = a.MovieNightService.ResetCalendarToken(ctx, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to reset calendar token", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue resetting your calendar and feed links, try again.")
+	} else {
+		a.setInfoFlashMessage(w, r, "Your calendar and feed links have been reset, calendars and feed readers using the old links will stop updating.")
+	}
+
+	http.Redirect(w, r, "/profile", http.StatusSeeOther)
+}
+
+func (a *Application) WatcherCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "WatcherCalendarHandler")
+
+	calendar, err := a.MovieNightService.GetWatcherCalendar(ctx, logger, r.PathValue("token"))
+	a.writeCalendar(w, r, logger, calendar, err)
+}
+
+func (a *Application) PartyCalendarHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyCalendarHandler")
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	calendar, err := a.MovieNightService.GetPartyCalendar(ctx, logger, r.PathValue("token"), idParty)
+	a.writeCalendar(w, r, logger, calendar, err)
+}
+
+// writeCalendar sends the calendar feed, calendar apps are the ones asking for it so errors are plain responses rather
+// than pages
+func (a *Application) writeCalendar(w http.ResponseWriter, r *http.Request, logger *slog.Logger, calendar partymgmt.Calendar, err error) {
+	if errors.Is(err, partymgmt.ErrCalendarNotFound) {
+		http.NotFound(w, r)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to get calendar", slog.Any("error", err))
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, max-age=300")
+
+	err = calendar.WriteICS(w, time.Now())
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to write calendar", slog.Any("error", err))
+	}
+}
+
+func (a *Application) renderMovieNightForm(w http.ResponseWriter, r *http.Request, logger *slog.Logger, idParty int, night partymgmt.MovieNight) {
+	ctx := r.Context()
+
+	movies, err := a.MovieNightService.GetSchedulableMovies(ctx, idParty)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get schedulable movies", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewMovieNightsTemplateData(r, w, "/parties", idParty)
+	templateData.MovieNight = night
+	templateData.Movies = movies
+	a.render(w, r, http.StatusOK, "movie_nights/form.gohtml", templateData)
+}
+
+func (a *Application) getMovieNightIDsFromPath(w http.ResponseWriter, r *http.Request, logger *slog.Logger) (int, int, bool) {
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return 0, 0, false
+	}
+
+	idMovieNight, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(r.Context(), "failed to get movie night ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return 0, 0, false
+	}
+
+	return idParty, idMovieNight, true
+}
+
+// handleMovieNightError shows a 404 for movie nights the watcher can't see and sends them back to redirectPath with
+// a message for anything they can fix
+func (a *Application) handleMovieNightError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error, redirectPath string) {
+	var validationErr *partymgmt.MovieNightValidationError
+
+	switch {
+	case errors.Is(err, partymgmt.ErrNotPartyMember), errors.Is(err, partymgmt.ErrMovieNightNotFound):
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+	case errors.As(err, &validationErr):
+		a.setErrorFlashMessage(w, r, fmt.Sprintf("The movie night couldn't be saved, %s.", validationErr.Reason))
+		http.Redirect(w, r, redirectPath, http.StatusSeeOther)
+	case errors.Is(err, partymgmt.ErrNotMovieNightOrganizer):
+		a.setErrorFlashMessage(w, r, "Only the person who scheduled this movie night or the party owner can change it.")
+		http.Redirect(w, r, redirectPath, http.StatusSeeOther)
+	case errors.Is(err, partymgmt.ErrMovieNightCompleted):
+		a.setErrorFlashMessage(w, r, "This movie night has already happened.")
+		http.Redirect(w, r, redirectPath, http.StatusSeeOther)
+	case errors.Is(err, partymgmt.ErrMovieNightHasNoMovie):
+		a.setErrorFlashMessage(w, r, "Pick a movie for this movie night before marking it as done.")
+		http.Redirect(w, r, redirectPath, http.StatusSeeOther)
+	default:
+		logger.ErrorContext(r.Context(), "movie night request failed", slog.Any("error", err))
+		a.serverError(w, r, err)
+	}
+}
+
+// movieNightInputFromRequest reads the movie night form, problems with what was entered are returned as a
+// MovieNightValidationError
+func movieNightInputFromRequest(r *http.Request) (partymgmt.MovieNightInput, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return partymgmt.MovieNightInput{}, err
+	}
+
+	timeZone := r.PostForm.Get("time_zone")
+	startsAt, err := partymgmt.ParseMovieNightStart(r.PostForm.Get("starts_at"), timeZone)
+	if err != nil {
+		return partymgmt.MovieNightInput{}, err
+	}
+
+	input := partymgmt.MovieNightInput{
+		StartsAt:  startsAt,
+		TimeZone:  timeZone,
+		Location:  strings.TrimSpace(r.PostForm.Get("location")),
+		StreamURL: strings.TrimSpace(r.PostForm.Get("stream_url")),
+	}
+
+	if movie := r.PostForm.Get("id_movie"); movie != "" {
+		input.IDMovie, err = strconv.Atoi(movie)
+		if err != nil {
+			return partymgmt.MovieNightInput{}, &partymgmt.MovieNightValidationError{Reason: "pick one of the party's movies"}
+		}
+	}
+
+	input.ReminderMinutes, err = strconv.Atoi(r.PostForm.Get("reminder_minutes"))
+	if err != nil {
+		return partymgmt.MovieNightInput{}, &partymgmt.MovieNightValidationError{Reason: "pick one of the reminder options"}
+	}
+
+	return input, nil
+}
@@ -25,7 +25,7 @@ func (a *Application) MoviesIndexHandler(w http.ResponseWriter, r *http.Request)
 	templateData.SearchValue = queryParams.Get("search")
 	term := strings.TrimSpace(queryParams.Get("search"))
 
-	movies, err := a.MoviesService.SearchMovies(ctx, logger, term)
+	movies, err := a.MoviesService.SearchMovies(ctx, logger, term, preferredLanguage(ctx))
 	if err != nil {
 		logger.ErrorContext(ctx, "failed to search movies", slog.Any("error", err))
 		a.serverError(w, r, err)
@@ -99,6 +99,9 @@ func (a *Application) MoviesShowHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	movie = a.MoviesService.LocalizeMovie(ctx, logger, movie, preferredLanguage(ctx))
+	movie.Genres = a.MoviesService.GetLocalizedGenres(ctx, logger, movie, preferredLanguage(ctx))
+
 	templateData := a.NewMoviesTemplateData(r, w, "/movie")
 	templateData.Movie = movie
 	a.render(w, r, http.StatusOK, "movies/show.gohtml", templateData)
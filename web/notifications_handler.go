@@ -0,0 +1,183 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+func (a *Application) NotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "NotificationsHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	notifications, err := a.NotificationService.GetNotifications(ctx, watcher.ID, partymgmt.NotificationsPageLimit)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get notifications", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	preferences, err := a.NotificationService.GetPreferences(ctx, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get notification preferences", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewNotificationsTemplateData(r, w, "/notifications", notifications)
+	templateData.Preferences = preferences
+
+	a.render(w, r, http.StatusOK, "notifications/index.gohtml", templateData)
+}
+
+// NotificationsMenuHandler renders the latest notifications into the nav's dropdown when it's opened
+func (a *Application) NotificationsMenuHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "NotificationsMenuHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	notifications, err := a.NotificationService.GetNotifications(ctx, watcher.ID, partymgmt.NotificationsMenuLimit)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get notifications", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := NotificationsTemplateData{Notifications: notifications}
+	a.renderPartial(w, r, http.StatusOK, "notifications/partials/notifications_menu.gohtml", templateData)
+}
+
+// OpenNotificationHandler marks the notification as read and takes the watcher to what it's about
+func (a *Application) OpenNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "OpenNotificationHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idNotification, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get notification ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	notification, err := a.NotificationService.OpenNotification(ctx, watcher.ID, idNotification)
+	if errors.Is(err, partymgmt.ErrNotificationNotFound) {
+		a.setErrorFlashMessage(w, r, "That notification doesn't exist.")
+		http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to open notification", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, notification.URL(), http.StatusSeeOther)
+}
+
+func (a *Application) MarkNotificationReadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "MarkNotificationReadHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idNotification, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get notification ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.NotificationService.MarkAsRead(ctx, watcher.ID, idNotification)
+	switch {
+	case errors.Is(err, partymgmt.ErrNotificationNotFound):
+		a.setErrorFlashMessage(w, r, "That notification doesn't exist.")
+	case err != nil:
+		logger.ErrorContext(ctx, "failed to mark notification as read", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue marking the notification as read, try again.")
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+func (a *Application) MarkAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "MarkAllNotificationsReadHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	err = a.NotificationService.MarkAllAsRead(ctx, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to mark all notifications as read", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue marking your notifications as read, try again.")
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
+
+func (a *Application) UpdateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "UpdateNotificationPreferencesHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	// unchecked boxes aren't sent so every type that isn't in the form is turned off
+	enabled := make([]partymgmt.NotificationType, 0, len(r.Form["enabled"]))
+	for _, rawType := range r.Form["enabled"] {
+		notificationType, err := partymgmt.ParseNotificationType(rawType)
+		if err != nil {
+			logger.ErrorContext(ctx, "invalid notification type", slog.String("type", rawType))
+			a.clientError(w, r, http.StatusBadRequest, "uh oh")
+			return
+		}
+		enabled = append(enabled, notificationType)
+	}
+
+	err = a.NotificationService.SetPreferences(ctx, logger, watcher.ID, enabled)
+	if err != nil {
+		a.setErrorFlashMessage(w, r, "There was an issue saving your notification settings, try again.")
+	} else {
+		a.setInfoFlashMessage(w, r, "Your notification settings have been saved.")
+	}
+
+	http.Redirect(w, r, "/notifications", http.StatusSeeOther)
+}
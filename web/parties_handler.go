@@ -1,10 +1,16 @@
 package web
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	"github.com/jm96441n/movieswithfriends/partymgmt/store"
 )
 
 func (a *Application) NewPartyHandler(w http.ResponseWriter, r *http.Request) {
@@ -93,6 +99,39 @@ func (a *Application) PartyShowHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// the page shows the availability already stored rather than waiting on TMDB, only members looking at it set off
+	// the requests to TMDB to bring it up to date
+	err = a.PartyService.CheckMembership(ctx, id, watcher.ID)
+	if err == nil {
+		a.MoviesService.RefreshPartyWatchProvidersInBackground(ctx, logger, id)
+	} else if !errors.Is(err, partymgmt.ErrNotPartyMember) {
+		logger.ErrorContext(ctx, "failed to check party membership", slog.Any("error", err))
+	}
+
+	// the party is still usable without knowing who was there for each movie
+	err = party.LoadAttendance(ctx, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get attendance", slog.Any("error", err))
+	}
+
+	// vetoed movies still can't be picked when they can't be shown
+	err = party.LoadVetoes(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get vetoes", slog.Any("error", err))
+	}
+
+	// the flags are still used to pick a movie when they can't be shown
+	err = party.LoadMovieFlags(ctx, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get movie flags", slog.Any("error", err))
+	}
+
+	// the discussions can still be opened without knowing how long they are
+	err = a.CommentService.LoadCommentCounts(ctx, &party)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get comment counts", slog.Any("error", err))
+	}
+
 	currentWatcherIsOwner := watcher.ID == party.IDOwner
 
 	invites, err := a.InvitationsService.GetInvitationsForParty(ctx, id)
@@ -103,11 +142,53 @@ func (a *Application) PartyShowHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	imports, err := a.ImportService.GetRecentImports(ctx, id)
+	if err != nil {
+		// the party is still usable without seeing how its imports are going
+		logger.ErrorContext(ctx, "failed to get imports", slog.Any("error", err))
+	}
+
+	statusChanges, err := a.PartyService.GetStatusChanges(ctx, id)
+	if err != nil {
+		// or without seeing who changed what
+		logger.ErrorContext(ctx, "failed to get status changes", slog.Any("error", err))
+	}
+
+	activity, err := a.PartyService.GetActivity(ctx, id, watcher.ID, 0)
+	if err != nil {
+		// or what's happened in it
+		logger.ErrorContext(ctx, "failed to get party activity", slog.Any("error", err))
+	}
+
 	templateData := a.NewPartiesTemplateData(r, w, "/parties")
 	templateData.Party = party
+	templateData.Imports = imports
+	templateData.StatusChanges = statusChanges
+	templateData.Activity = activity
+
+	// the feed shares the watcher's calendar token, the page is fine without a link to it
+	token, err := a.MovieNightService.GetCalendarToken(ctx, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get calendar token", slog.Any("error", err))
+	} else {
+		templateData.ActivityFeedURL = absoluteURL(r, fmt.Sprintf("/feeds/%s/parties/%d/activity.atom", token, id))
+	}
+	// the party is still usable without showing whether it's public
+	visibility, err := a.PublicPartyService.GetVisibility(ctx, id)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party visibility", slog.Any("error", err))
+	} else {
+		templateData.Visibility = visibility
+		if visibility.IsPublic {
+			templateData.PublicURL = absoluteURL(r, visibility.Path)
+		}
+	}
+	// genreNames is empty when the genres can't be loaded so members are shown without their favourites
+	templateData.GenreNames = a.genreNames(ctx, logger)
 	templateData.ModalData.PendingInvites = invites
 	templateData.ModalData.PartyID = id
 	templateData.CurrentWatcherIsOwner = currentWatcherIsOwner
+	templateData.CurrentWatcherID = watcher.ID
 
 	a.render(w, r, http.StatusOK, "parties/show.gohtml", templateData)
 }
@@ -137,32 +218,119 @@ func (a *Application) PartiesIndexHandler(w http.ResponseWriter, r *http.Request
 
 func (a *Application) MarkMovieAsWatchedHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
-	idPartyParam := r.PathValue("party_id")
-	idMovieParam := r.PathValue("id")
-	idMovie, err := strconv.Atoi(idMovieParam)
+	logger := a.Logger.With("handler", "MarkMovieAsWatchedHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
 	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
 		a.clientError(w, r, http.StatusBadRequest, "uh oh")
 		return
 	}
 
-	idParty, err := strconv.Atoi(idPartyParam)
+	err = a.PartyService.MarkMovieAsWatched(ctx, logger, idParty, idMovie, watcher.ID, time.Now())
+	a.handleMovieStatusChange(w, r, logger, idParty, err, "Marked as watched.")
+}
+
+func (a *Application) UnselectMovieHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "UnselectMovieHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
 	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
 		a.clientError(w, r, http.StatusBadRequest, "uh oh")
 		return
 	}
 
-	err = a.PartiesRepository.MarkPartyMovieAsWatched(ctx, idParty, idMovie)
+	err = a.PartyService.UnselectMovie(ctx, logger, idParty, idMovie, watcher.ID)
+	a.handleMovieStatusChange(w, r, logger, idParty, err, "The movie has been un-picked.")
+}
+
+func (a *Application) UnwatchMovieHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "UnwatchMovieHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
 	if err != nil {
-		a.serverError(w, r, err)
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
 		return
 	}
 
-	http.Redirect(w, r, "/parties/"+idPartyParam, http.StatusSeeOther)
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.PartyService.UnwatchMovie(ctx, logger, idParty, idMovie, watcher.ID)
+	a.handleMovieStatusChange(w, r, logger, idParty, err, "The latest watch has been undone.")
+}
+
+func (a *Application) EditWatchDateHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "EditWatchDateHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	now := time.Now()
+	watchDate, err := parseWatchDate(r.PostForm.Get("watch_date"), now)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse watch date", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.PartyService.EditWatchDate(ctx, logger, idParty, idMovie, watcher.ID, watchDate, now)
+	if errors.Is(err, partymgmt.ErrWatchDateInFuture) {
+		a.setErrorFlashMessage(w, r, "The watch date can't be in the future.")
+		http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+		return
+	}
+
+	a.handleMovieStatusChange(w, r, logger, idParty, err, "The watch date has been changed.")
 }
 
 func (a *Application) SelectMovieForParty(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	logger := a.Logger.With("handler", "SelectMovieForParty")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
 	idPartyParam := r.PathValue("party_id")
 	idParty, err := strconv.Atoi(idPartyParam)
 	if err != nil {
@@ -171,7 +339,78 @@ func (a *Application) SelectMovieForParty(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	err = a.PartiesRepository.SelectMovieForParty(ctx, idParty)
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	params := store.SelectMovieParams{
+		OnlyStreamable: r.FormValue("onlyStreamable") == "on",
+	}
+
+	// a missing or bad value only picks from unwatched movies
+	rewatchAfterMonths, err := strconv.Atoi(r.FormValue("rewatchAfterMonths"))
+	if err == nil && rewatchAfterMonths > 0 {
+		params.RewatchAfterMonths = rewatchAfterMonths
+	}
+
+	// nobody being ticked is treated the same as everyone watching
+	for _, value := range r.Form["attendee_ids"] {
+		idAttendee, err := strconv.Atoi(value)
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to convert attendee id to int", slog.Any("error", err))
+			a.clientError(w, r, http.StatusBadRequest, "uh oh")
+			return
+		}
+		params.AttendeeIDs = append(params.AttendeeIDs, idAttendee)
+	}
+
+	// checked before anything is fetched from TMDB for the party
+	err = a.PartyService.CheckMembership(ctx, idParty, watcher.ID)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to check party membership", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	// stale availability is picked from if TMDB can't be reached in time
+	if params.OnlyStreamable {
+		refreshCtx, cancel := context.WithTimeout(ctx, partymgmt.WatchProvidersRefreshTimeout)
+		err = a.MoviesService.RefreshPartyWatchProviders(refreshCtx, logger, idParty)
+		cancel()
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to refresh watch providers", slog.Any("error", err))
+		}
+	}
+
+	err = a.PartyService.SelectMovie(ctx, logger, idParty, watcher.ID, params)
+	if errors.Is(err, store.ErrNoRecord) {
+		switch {
+		case params.OnlyStreamable:
+			a.setErrorFlashMessage(w, r, "None of the movies that can be picked are streaming on a service someone watching has.")
+		case params.RewatchAfterMonths > 0:
+			a.setErrorFlashMessage(w, r, "There are no unwatched movies or movies due a rewatch to pick from, add some movies first!")
+		default:
+			a.setErrorFlashMessage(w, r, "There are no unwatched movies that everyone watching is up for, add some movies first!")
+		}
+		http.Redirect(w, r, "/parties/"+idPartyParam, http.StatusSeeOther)
+		return
+	}
+
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
 	if err != nil {
 		logger.ErrorContext(ctx, "failed to select movie for party", slog.Any("error", err))
 		a.serverError(w, r, err)
@@ -180,3 +419,182 @@ func (a *Application) SelectMovieForParty(w http.ResponseWriter, r *http.Request
 
 	http.Redirect(w, r, "/parties/"+idPartyParam, http.StatusSeeOther)
 }
+
+// handleMovieStatusChange responds to a change to one of the party's movies, a change that can't be made from the
+// movie's status is most likely someone else in the party having changed it first so they're sent back to see it
+func (a *Application) handleMovieStatusChange(w http.ResponseWriter, r *http.Request, logger *slog.Logger, idParty int, err error, successMessage string) {
+	ctx := r.Context()
+
+	switch {
+	case errors.Is(err, partymgmt.ErrNotPartyMember), errors.Is(err, partymgmt.ErrPartyMovieNotFound):
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	case errors.Is(err, partymgmt.ErrInvalidStatusChange):
+		logger.InfoContext(ctx, "invalid movie status change", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "That movie has changed since you loaded the page, take another look.")
+	case err != nil:
+		logger.ErrorContext(ctx, "failed to change movie status", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	default:
+		a.setInfoFlashMessage(w, r, successMessage)
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+}
+
+func partyMovieIDsFromPath(r *http.Request) (int, int, error) {
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	idMovie, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return idParty, idMovie, nil
+}
+
+// watchDateLocation is the time zone watch dates are shown in, so an edited date is read in it too
+const watchDateLocation = "America/New_York"
+
+// parseWatchDate reads a date entered in a form, the party is taken to have watched it in the evening unless that's
+// still to come today
+func parseWatchDate(value string, now time.Time) (time.Time, error) {
+	loc, err := time.LoadLocation(watchDateLocation)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, value, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	watchDate := date.Add(20 * time.Hour)
+	if watchDate.After(now) && !date.After(now) {
+		return now, nil
+	}
+
+	return watchDate, nil
+}
+
+func (a *Application) PartyRecommendationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyRecommendationsHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParam := r.PathValue("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	// recommendations are built from the party's watch history so only its members get to see them
+	err = a.PartyService.CheckMembership(ctx, id, watcher.ID)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to check party membership", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	recommendations, err := a.MoviesService.RecommendForParty(ctx, logger, id, preferredLanguage(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get recommendations", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewPartiesTemplateData(r, w, "/parties")
+	templateData.Party = a.PartyService.NewParty(ctx, id, "", 0, 0, 0)
+	templateData.Recommendations = recommendations
+
+	a.renderPartial(w, r, http.StatusOK, "parties/partials/recommendations.gohtml", templateData)
+}
+
+func (a *Application) AddRecommendationToPartyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "AddRecommendationToPartyHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get watcher from session", slog.Any("error", err))
+		a.clientError(w, r, http.StatusInternalServerError, "failed to get watcher from session")
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	tmdbID, err := strconv.Atoi(r.FormValue("tmdb_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to convert tmdb id to int", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "no tmdb id")
+		return
+	}
+
+	err = a.PartyService.CheckMembership(ctx, idParty, watcher.ID)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to check party membership", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	movieID, err := a.MoviesService.GetOrCreateMovie(ctx, logger, partymgmt.MovieID{TMDBID: &tmdbID})
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get or create movie", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	party := a.PartyService.NewParty(ctx, idParty, "", 0, 0, 0)
+	err = party.AddMovie(ctx, logger, watcher.ID, movieID)
+	// someone else in the party added it since the recommendations were loaded
+	if errors.Is(err, partymgmt.ErrMovieAlreadyInParty) {
+		a.renderPartial(w, r, http.StatusOK, "parties/partials/recommendation_added.gohtml", RecommendationAddedTemplateData{AlreadyInParty: true})
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to add movie to party", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	w.Header().Set("HX-Trigger", "MovieAddedToParties")
+
+	a.renderPartial(w, r, http.StatusOK, "parties/partials/recommendation_added.gohtml", RecommendationAddedTemplateData{})
+}
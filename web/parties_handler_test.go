@@ -0,0 +1,190 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gorilla/sessions"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+	partymgmtstore "github.com/jm96441n/movieswithfriends/partymgmt/store"
+	"github.com/jm96441n/movieswithfriends/testhelpers"
+)
+
+func TestPartyRecommendationsHandler(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connPool := testhelpers.SetupConnPool(ctx, t, "web_party_recommendations_schema")
+	app, tmdbRequests := newPartiesTestApplication(t, connPool)
+
+	idParty := seedTestParty(ctx, t, connPool)
+	idMember := seedTestProfile(ctx, t, connPool)
+	idOutsider := seedTestProfile(ctx, t, connPool)
+	_, err := connPool.Exec(ctx, "insert into party_members (id_party, id_member) values($1, $2)", idParty, idMember)
+	testhelpers.Ok(t, err, "failed to insert party member")
+
+	tests := map[string]struct {
+		idWatcher      int
+		expectedStatus int
+	}{
+		"member":    {idWatcher: idMember, expectedStatus: http.StatusOK},
+		"nonMember": {idWatcher: idOutsider, expectedStatus: http.StatusNotFound},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/parties/%d/recommendations", idParty), nil)
+			req.SetPathValue("id", strconv.Itoa(idParty))
+			req.AddCookie(newTestSessionCookie(t, app.SessionStore, tc.idWatcher))
+			rec := httptest.NewRecorder()
+
+			app.PartyRecommendationsHandler(rec, req)
+
+			testhelpers.Equals(t, tc.expectedStatus, rec.Code)
+		})
+	}
+
+	testhelpers.Equals(t, int32(0), tmdbRequests.Load())
+}
+
+func TestAddRecommendationToPartyHandler(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connPool := testhelpers.SetupConnPool(ctx, t, "web_add_recommendation_schema")
+	app, tmdbRequests := newPartiesTestApplication(t, connPool)
+
+	idParty := seedTestParty(ctx, t, connPool)
+	idOutsider := seedTestProfile(ctx, t, connPool)
+
+	form := url.Values{"tmdb_id": {"603"}}
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/parties/%d/recommendations", idParty), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("party_id", strconv.Itoa(idParty))
+	req.AddCookie(newTestSessionCookie(t, app.SessionStore, idOutsider))
+	rec := httptest.NewRecorder()
+
+	app.AddRecommendationToPartyHandler(rec, req)
+
+	testhelpers.Equals(t, http.StatusNotFound, rec.Code)
+	testhelpers.Equals(t, int32(0), tmdbRequests.Load())
+
+	var movieCount, activityCount int
+	err := connPool.QueryRow(ctx, "select count(*) from party_movies where id_party = $1", idParty).Scan(&movieCount)
+	testhelpers.Ok(t, err, "failed to count party movies")
+	testhelpers.Equals(t, 0, movieCount)
+
+	err = connPool.QueryRow(ctx, "select count(*) from party_activities where id_party = $1", idParty).Scan(&activityCount)
+	testhelpers.Ok(t, err, "failed to count party activity")
+	testhelpers.Equals(t, 0, activityCount)
+}
+
+func TestSelectMovieForPartyHandler(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	connPool := testhelpers.SetupConnPool(ctx, t, "web_select_movie_schema")
+	app, tmdbRequests := newPartiesTestApplication(t, connPool)
+
+	idParty := seedTestParty(ctx, t, connPool)
+	idMember := seedTestProfile(ctx, t, connPool)
+	idOutsider := seedTestProfile(ctx, t, connPool)
+	_, err := connPool.Exec(ctx, "insert into party_members (id_party, id_member) values($1, $2)", idParty, idMember)
+	testhelpers.Ok(t, err, "failed to insert party member")
+
+	// a movie whose watch providers have never been fetched
+	var idMovie int
+	err = connPool.QueryRow(ctx, "insert into movies (title, poster_url, tmdb_id, overview, tagline) values('Alien', '', 348, '', '') returning id_movie").Scan(&idMovie)
+	testhelpers.Ok(t, err, "failed to insert movie")
+	_, err = connPool.Exec(ctx, "insert into party_movies (id_party, id_movie, id_added_by) values($1, $2, $3)", idParty, idMovie, idMember)
+	testhelpers.Ok(t, err, "failed to insert party movie")
+
+	form := url.Values{"onlyStreamable": {"on"}}
+	req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/parties/%d/movies", idParty), strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetPathValue("party_id", strconv.Itoa(idParty))
+	req.AddCookie(newTestSessionCookie(t, app.SessionStore, idOutsider))
+	rec := httptest.NewRecorder()
+
+	app.SelectMovieForParty(rec, req)
+
+	testhelpers.Equals(t, http.StatusNotFound, rec.Code)
+	testhelpers.Equals(t, int32(0), tmdbRequests.Load())
+
+	var status string
+	err = connPool.QueryRow(ctx, "select watch_status from party_movies where id_party = $1 and id_movie = $2", idParty, idMovie).Scan(&status)
+	testhelpers.Ok(t, err, "failed to get watch status")
+	testhelpers.Equals(t, "unwatched", status)
+}
+
+// newPartiesTestApplication wires the party handlers to the database, the TMDB stand-in only serves genres and counts
+// every other request made to it
+func newPartiesTestApplication(t *testing.T, connPool *pgxpool.Pool) (*Application, *atomic.Int32) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tmdbRequests := &atomic.Int32{}
+	tmdbServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/genre/movie/list" {
+			fmt.Fprint(w, `{"genres":[{"id":28,"name":"Action"}]}`)
+			return
+		}
+		tmdbRequests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	t.Cleanup(tmdbServer.Close)
+
+	tmdbClient := partymgmt.NewTMDBClient(tmdbServer.URL, "test-key", logger)
+	partyRepo := partymgmtstore.NewPartyRepository(connPool)
+	eventBus := partymgmt.NewEventBus(partymgmtstore.NewEventsRepository(connPool))
+
+	return NewApplication(AppConfig{
+		Logger:       logger,
+		SessionStore: sessions.NewCookieStore([]byte("parties-handler-test-session-key")),
+		MoviesService: partymgmt.NewMovieService(
+			tmdbClient,
+			partymgmtstore.NewMoviesRepository(connPool),
+			partymgmtstore.NewGenresRepository(connPool),
+			partymgmtstore.NewWatchProvidersRepository(connPool),
+		),
+		PartyService:      partymgmt.NewPartyService(logger, partyRepo, eventBus),
+		PartiesRepository: partyRepo,
+		WatcherService:    partymgmt.NewWatcherService(partymgmtstore.NewWatcherRepository(connPool)),
+	}), tmdbRequests
+}
+
+func newTestSessionCookie(t *testing.T, sessionStore *sessions.CookieStore, idProfile int) *http.Cookie {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	session, err := sessionStore.New(req, sessionName)
+	testhelpers.Ok(t, err, "failed to create session")
+	session.Values["profileID"] = idProfile
+	testhelpers.Ok(t, session.Save(req, rec), "failed to save session")
+
+	return rec.Result().Cookies()[0]
+}
+
+func seedTestParty(ctx context.Context, t *testing.T, conn *pgxpool.Pool) int {
+	t.Helper()
+	var idParty int
+	err := conn.QueryRow(ctx, "insert into parties (name, short_id) values($1, $2) returning id_party", "party", "webtst").Scan(&idParty)
+	testhelpers.Ok(t, err, "failed to insert party")
+	return idParty
+}
+
+func seedTestProfile(ctx context.Context, t *testing.T, conn *pgxpool.Pool) int {
+	t.Helper()
+	var idProfile int
+	err := conn.QueryRow(ctx, "insert into profiles (first_name, last_name) values($1, $2) returning id_profile", "tom", "bomba").Scan(&idProfile)
+	testhelpers.Ok(t, err, "failed to insert profile")
+	return idProfile
+}
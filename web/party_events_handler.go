@@ -0,0 +1,123 @@
+package web
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// partyEventsHeartbeatInterval keeps the stream from being closed as idle by anything between the browser and the app
+const partyEventsHeartbeatInterval = 30 * time.Second
+
+const partyEventTemplate = "parties/partials/party_event.gohtml"
+
+// PartyEventsHandler streams the party's events to the party page as server-sent events, each event's data is the
+// partial announcing it which HTMX's SSE extension swaps in, the event's name is what the page refreshes on
+func (a *Application) PartyEventsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyEventsHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	events, unsubscribe, err := a.PartyService.SubscribeToEvents(ctx, idParty, watcher.ID)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to subscribe to party events", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+
+	// the stream is open for as long as the page is so the server's write timeout can't apply to it, the browser
+	// reconnects if it's cut off anyway
+	err = rc.SetWriteDeadline(time.Time{})
+	if err != nil {
+		logger.WarnContext(ctx, "failed to clear the write deadline for party events", slog.Any("error", err))
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	err = rc.Flush()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to start party events stream", slog.Any("error", err))
+		return
+	}
+
+	heartbeat := time.NewTicker(partyEventsHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-heartbeat.C:
+			_, err = io.WriteString(w, ": heartbeat\n\n")
+		case event := <-events:
+			err = a.writePartyEvent(w, event)
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+
+		// the browser has gone away
+		if err != nil {
+			logger.DebugContext(ctx, "stopped streaming party events", slog.Any("error", err))
+			return
+		}
+	}
+}
+
+// writePartyEvent writes the event in the server-sent events format, every line of the partial gets its own data
+// field since a field can't hold a newline
+func (a *Application) writePartyEvent(w io.Writer, event partymgmt.PartyEvent) error {
+	ts, ok := a.templateCache[partyEventTemplate]
+	if !ok {
+		return fmt.Errorf("template does not exist for page %q", partyEventTemplate)
+	}
+
+	var buf bytes.Buffer
+	err := ts.ExecuteTemplate(&buf, "party_event", event)
+	if err != nil {
+		return err
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "event: %s\n", event.Type)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		fmt.Fprintf(&msg, "data: %s\n", line)
+	}
+	msg.WriteString("\n")
+
+	_, err = io.WriteString(w, msg.String())
+	return err
+}
@@ -1,6 +1,8 @@
 package web
 
 import (
+	"errors"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"strconv"
@@ -65,6 +67,7 @@ func (a *Application) AddMovieToPartiesHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	alreadyInParty := false
 	for _, partyID := range partyIDs {
 		id, err := strconv.Atoi(partyID)
 		if err != nil {
@@ -75,7 +78,20 @@ func (a *Application) AddMovieToPartiesHandler(w http.ResponseWriter, r *http.Re
 
 		party := a.PartyService.NewParty(ctx, id, "", 0, 0, 0)
 		party.ID = id
-		party.AddMovie(ctx, watcher.ID, movieID)
+		err = party.AddMovie(ctx, logger, watcher.ID, movieID)
+		if errors.Is(err, partymgmt.ErrMovieAlreadyInParty) {
+			alreadyInParty = true
+			continue
+		}
+
+		if err != nil {
+			logger.ErrorContext(ctx, "failed to add movie to party", slog.Any("error", err), slog.Int("partyID", id))
+		}
+	}
+
+	// the modal only offers parties it isn't in yet, so this is someone else in the party having added it first
+	if alreadyInParty {
+		a.setInfoFlashMessage(w, r, "That movie was already on the list for some of those parties.")
 	}
 
 	w.Header().Set("HX-Trigger", "MovieAddedToParties")
@@ -130,3 +146,175 @@ func (a *Application) GetAddMovieToPartyModal(w http.ResponseWriter, r *http.Req
 
 	a.renderPartial(w, r, http.StatusOK, "movies/partials/add_to_party_modal.gohtml", tmplData)
 }
+
+func (a *Application) RemovePartyMovieHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "RemovePartyMovieHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.PartyService.RemoveMovie(ctx, logger, idParty, idMovie, watcher.ID)
+	switch {
+	case errors.Is(err, partymgmt.ErrNotPartyMember), errors.Is(err, partymgmt.ErrPartyMovieNotFound):
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	case errors.Is(err, partymgmt.ErrCannotRemoveMovie):
+		a.setErrorFlashMessage(w, r, "Only whoever added the movie or the party's owner can remove it.")
+	case errors.Is(err, partymgmt.ErrMovieAlreadyWatched):
+		a.setErrorFlashMessage(w, r, "The party has watched that movie so it's staying in the watch history.")
+	case err != nil:
+		a.serverError(w, r, err)
+		return
+	default:
+		a.setInfoFlashMessage(w, r, "The movie has been removed from the party.")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) VetoMovieHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "VetoMovieHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.PartyService.VetoMovie(ctx, logger, idParty, idMovie, watcher.ID)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) || errors.Is(err, partymgmt.ErrPartyMovieNotFound) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, "You've vetoed the movie, it won't be picked until you lift your veto.")
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) LiftVetoHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "LiftVetoHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	idVetoedBy, err := strconv.Atoi(r.PathValue("watcher_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get watcher ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.PartyService.LiftVeto(ctx, logger, idParty, idMovie, idVetoedBy, watcher.ID)
+	switch {
+	case errors.Is(err, partymgmt.ErrNotPartyMember), errors.Is(err, partymgmt.ErrPartyMovieNotFound):
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	case errors.Is(err, partymgmt.ErrCannotLiftVeto):
+		a.setErrorFlashMessage(w, r, "Only whoever vetoed the movie or the party's owner can lift the veto.")
+	// it's already been lifted, which is what they wanted
+	case errors.Is(err, partymgmt.ErrVetoNotFound):
+	case err != nil:
+		a.serverError(w, r, err)
+		return
+	default:
+		a.setInfoFlashMessage(w, r, "The veto has been lifted.")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) SetMovieFlagHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "SetMovieFlagHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idMovie, err := partyMovieIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party movie from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	flag, err := partymgmt.ParseMovieFlag(r.PostForm.Get("flag"))
+	if err != nil {
+		logger.ErrorContext(ctx, "invalid movie flag", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.PartyService.SetMovieFlag(ctx, logger, idParty, idMovie, watcher.ID, flag)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) || errors.Is(err, partymgmt.ErrCannotFlagMovie) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	if r.Header.Get("HX-Request") == "" {
+		http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+		return
+	}
+
+	flags, err := a.PartyService.GetMovieFlags(ctx, idParty, idMovie, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get movie flags", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	a.renderPartial(w, r, http.StatusOK, "parties/partials/movie_flags.gohtml", flags)
+}
@@ -0,0 +1,43 @@
+package web
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+func (a *Application) PartyStatsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyStatsHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	stats, err := a.PartyStatsService.GetPartyStats(ctx, logger, idParty, watcher.ID, preferredLanguage(ctx))
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewPartyStatsTemplateData(r, w, "/parties", stats)
+	a.render(w, r, http.StatusOK, "parties/stats.gohtml", templateData)
+}
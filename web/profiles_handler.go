@@ -1,13 +1,14 @@
 package web
 
 import (
+	"cmp"
+	"context"
 	"errors"
 	"log/slog"
 	"net/http"
 	"strconv"
 
 	"github.com/jm96441n/movieswithfriends/identityaccess"
-	"github.com/jm96441n/movieswithfriends/identityaccess/services"
 	"github.com/jm96441n/movieswithfriends/identityaccess/store"
 )
 
@@ -44,9 +45,34 @@ func (a *Application) ProfileShowHandler(w http.ResponseWriter, r *http.Request)
 	templateData.Profile = pageData.Profile
 	templateData.Parties = pageData.Parties
 	templateData.InvitedParties = pageData.InvitedParties
-	templateData.WatchedMovies = pageData.WatchedMovies
-	templateData.CurPage = pageData.CurPage
-	templateData.NumPages = pageData.NumPages
+	setWatchHistoryTemplateData(&templateData, pageData.WatchHistory)
+
+	// the profile is still usable without the names of the favourite genres
+	genreNames := a.genreNames(ctx, logger)
+	for _, idGenre := range pageData.Profile.FavoriteGenres {
+		if name, ok := genreNames[idGenre]; ok {
+			templateData.FavoriteGenreNames = append(templateData.FavoriteGenreNames, name)
+		}
+	}
+
+	// the profile is still usable without the movie nights coming up
+	templateData.UpcomingMovieNights, err = a.MovieNightService.GetUpcomingMovieNights(ctx, logger, profileID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get upcoming movie nights", slog.Any("error", err))
+	}
+
+	token, err := a.MovieNightService.GetCalendarToken(ctx, profileID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get calendar token", slog.Any("error", err))
+	} else {
+		templateData.CalendarURL = absoluteURL(r, "/calendars/"+token+"/movie_nights.ics")
+	}
+
+	// the profile is still usable without the watcher's friends
+	templateData.Friends, err = a.FriendsService.GetFriends(ctx, profileID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get friends", slog.Any("error", err))
+	}
 
 	logger.InfoContext(ctx, "successfully loaded profile info")
 	a.render(w, r, http.StatusOK, "profiles/show.gohtml", templateData)
@@ -70,11 +96,102 @@ func (a *Application) ProfileEditPageHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
+	err = profile.LoadSubscriptions(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load subscriptions", slog.Any("error", err))
+	}
+
+	err = profile.LoadFavoriteGenres(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load favorite genres", slog.Any("error", err))
+	}
+
 	templateData := a.NewProfilesTemplateData(r, w, "/profile")
 	templateData.Profile = profile
+	a.setSubscriptionOptions(ctx, logger, &templateData, profile.WatchRegion, profile.Subscriptions)
+	a.setFavoriteGenreOptions(ctx, logger, &templateData, profile.FavoriteGenres)
 	a.render(w, r, http.StatusOK, "profiles/edit.gohtml", templateData)
 }
 
+// ProfileSubscriptionsHandler renders the streaming services for a region so they can be swapped in when the region on
+// the edit form changes
+func (a *Application) ProfileSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "ProfileSubscriptionsHandler")
+
+	profile, err := a.getProfileFromSession(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get profile from session", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	err = profile.LoadSubscriptions(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load subscriptions", slog.Any("error", err))
+	}
+
+	region := r.URL.Query().Get("watchRegion")
+	if !identityaccess.IsSupportedRegion(region) {
+		region = profile.WatchRegion
+	}
+
+	templateData := a.NewProfilesTemplateData(r, w, "/profile")
+	templateData.Profile = profile
+	a.setSubscriptionOptions(ctx, logger, &templateData, region, profile.Subscriptions)
+	a.renderPartial(w, r, http.StatusOK, "profiles/partials/subscriptions.gohtml", templateData)
+}
+
+// setSubscriptionOptions fills in the services that can be picked for a region, when TMDB can't be reached the list is
+// left empty and the form says so
+func (a *Application) setSubscriptionOptions(ctx context.Context, logger *slog.Logger, templateData *ProfilesTemplateData, region string, subscriptions []int) {
+	templateData.Subscribed = make(map[int]bool, len(subscriptions))
+	for _, idProvider := range subscriptions {
+		templateData.Subscribed[idProvider] = true
+	}
+
+	providers, err := a.MoviesService.ListWatchProviders(ctx, cmp.Or(region, identityaccess.DefaultWatchRegion))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list watch providers", slog.Any("error", err), slog.String("region", region))
+		return
+	}
+
+	templateData.WatchProviders = providers
+}
+
+// setFavoriteGenreOptions fills in the genres that can be picked as favourites, the form says so when they can't be
+// loaded
+func (a *Application) setFavoriteGenreOptions(ctx context.Context, logger *slog.Logger, templateData *ProfilesTemplateData, favorites []int) {
+	templateData.FavoriteGenres = make(map[int]bool, len(favorites))
+	for _, idGenre := range favorites {
+		templateData.FavoriteGenres[idGenre] = true
+	}
+
+	genres, err := a.MoviesService.ListGenres(ctx, preferredLanguage(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list genres", slog.Any("error", err))
+		return
+	}
+
+	templateData.FavoriteGenreOptions = genres
+}
+
+// genreNames are the names of every genre in the watcher's language keyed by id, it's empty when they can't be loaded
+func (a *Application) genreNames(ctx context.Context, logger *slog.Logger) map[int]string {
+	genres, err := a.MoviesService.ListGenres(ctx, preferredLanguage(ctx))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to list genres", slog.Any("error", err))
+		return map[int]string{}
+	}
+
+	names := make(map[int]string, len(genres))
+	for _, genre := range genres {
+		names[genre.ID] = genre.Name
+	}
+
+	return names
+}
+
 func (a *Application) ProfileEditHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	logger := a.Logger.With("handler", "ProfileEditHandler")
@@ -97,6 +214,8 @@ func (a *Application) ProfileEditHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		templateData := a.NewProfilesTemplateData(r, w, "/profile")
 		templateData.Profile = profile
+		a.setSubscriptionOptions(ctx, logger, &templateData, profile.WatchRegion, req.Subscriptions)
+		a.setFavoriteGenreOptions(ctx, logger, &templateData, req.FavoriteGenres)
 		a.setErrorFlashMessage(w, r, "There was an error editing your profile, please try again")
 
 		a.render(w, r, http.StatusBadRequest, "profiles/edit.gohtml", templateData)
@@ -109,6 +228,8 @@ func (a *Application) ProfileEditHandler(w http.ResponseWriter, r *http.Request)
 	if err != nil {
 		templateData := a.NewProfilesTemplateData(r, w, "/profile")
 		templateData.Profile = profile
+		a.setSubscriptionOptions(ctx, logger, &templateData, cmp.Or(req.WatchRegion, profile.WatchRegion), req.Subscriptions)
+		a.setFavoriteGenreOptions(ctx, logger, &templateData, req.FavoriteGenres)
 
 		var editErr *identityaccess.ProfileEditValidationError
 
@@ -131,6 +252,26 @@ func (a *Application) ProfileEditHandler(w http.ResponseWriter, r *http.Request)
 			if editErr.LastNameError != nil {
 				*templateData.HasLastNameError = true
 			}
+
+			if editErr.LanguageError != nil {
+				*templateData.HasLanguageError = true
+			}
+
+			if editErr.RegionError != nil {
+				*templateData.HasRegionError = true
+			}
+
+			if editErr.DisplayNameError != nil {
+				*templateData.HasDisplayNameError = true
+			}
+
+			if editErr.BioError != nil {
+				*templateData.HasBioError = true
+			}
+
+			if editErr.FavoriteGenresError != nil {
+				*templateData.HasFavoriteGenresError = true
+			}
 		}
 
 		a.render(w, r, http.StatusBadRequest, "profiles/edit.gohtml", templateData)
@@ -146,12 +287,40 @@ func parseEditProfileForm(r *http.Request) (identityaccess.ProfileUpdateReq, err
 	req := identityaccess.ProfileUpdateReq{
 		FirstName:               r.FormValue("firstName"),
 		LastName:                r.FormValue("lastName"),
+		DisplayName:             r.FormValue("displayName"),
+		Bio:                     r.FormValue("bio"),
+		PreferredLanguage:       r.FormValue("preferredLanguage"),
+		WatchRegion:             r.FormValue("watchRegion"),
 		Email:                   r.FormValue("email"),
 		CurrentPassword:         r.FormValue("currentPassword"),
 		NewPassword:             r.FormValue("newPassword"),
 		NewPasswordConfirmation: r.FormValue("confirmPassword"),
 	}
 
+	// unchecked boxes aren't sent, so the form marks when the subscriptions were on it to tell clearing them all apart
+	// from not editing them
+	if r.FormValue("subscriptionsSubmitted") != "" {
+		req.Subscriptions = make([]int, 0, len(r.Form["subscriptions"]))
+		for _, rawID := range r.Form["subscriptions"] {
+			idProvider, err := strconv.Atoi(rawID)
+			if err != nil {
+				return req, err
+			}
+			req.Subscriptions = append(req.Subscriptions, idProvider)
+		}
+	}
+
+	if r.FormValue("favoriteGenresSubmitted") != "" {
+		req.FavoriteGenres = make([]int, 0, len(r.Form["favoriteGenres"]))
+		for _, rawID := range r.Form["favoriteGenres"] {
+			idGenre, err := strconv.Atoi(rawID)
+			if err != nil {
+				return req, err
+			}
+			req.FavoriteGenres = append(req.FavoriteGenres, idGenre)
+		}
+	}
+
 	return req, nil
 }
 
@@ -160,37 +329,26 @@ func (a *Application) GetPaginatedWatchHistoryHandler(w http.ResponseWriter, r *
 	logger := a.Logger.With("handler", "GetPaginatedWatchHistoryHandler")
 	logger.DebugContext(ctx, "getting paginated movies list")
 
-	profileID, err := a.getProfileIDFromSession(ctx, r)
-	if err != nil {
-		a.serverError(w, r, err)
+	// the full page has the filters, this only renders the list for swapping in
+	if r.Header.Get("HX-Request") == "" {
+		http.Redirect(w, r, "/watched_movies?"+r.URL.RawQuery, http.StatusSeeOther)
 		return
 	}
 
-	page := r.URL.Query().Get("page")
-
-	if page == "" {
-		page = "1"
-	}
-
-	pageNum, err := strconv.Atoi(page)
+	profileID, err := a.getProfileIDFromSession(ctx, r)
 	if err != nil {
 		a.serverError(w, r, err)
 		return
 	}
 
-	movieData, err := a.ProfileAggregatorService.GetWatchPaginatedHistory(ctx, logger, profileID, services.PageInfo{PageNum: pageNum})
+	page, err := a.ProfileAggregatorService.GetWatchHistory(ctx, logger, profileID, watchHistoryQueryFromRequest(r))
 	if err != nil {
 		a.serverError(w, r, err)
 		return
 	}
 
 	templateData := a.NewProfilesTemplateData(r, w, "/profile")
-	templateData.WatchedMovies = movieData.WatchedMovies
-	templateData.CurPage = pageNum
-	templateData.NumPages = movieData.NumPages
+	setWatchHistoryTemplateData(&templateData, page)
 
-	if r.Header.Get("HX-Request") != "" {
-		a.renderPartial(w, r, http.StatusOK, "profiles/partials/watch_list.gohtml", templateData)
-		return
-	}
+	a.renderPartial(w, r, http.StatusOK, "profiles/partials/watch_list.gohtml", templateData)
 }
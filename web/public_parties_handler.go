@@ -0,0 +1,84 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+// PublicPartyHandler shows a public party to anyone, logged in or not. the page is checked against the ETag the
+// browser already has so it's only sent again when something on it has changed
+func (a *Application) PublicPartyHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PublicPartyHandler")
+
+	party, err := a.PublicPartyService.GetPublicParty(ctx, r.PathValue("slug"))
+	if errors.Is(err, partymgmt.ErrPublicPartyNotFound) {
+		data := a.NewTemplateData(r, w, "/p")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get public party", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewPublicPartyTemplateData(r, w, "/p", party)
+
+	// the nav is different for whoever is logged in so only their browser can keep their copy
+	w.Header().Set("Vary", "Cookie")
+	if templateData.IsAuthenticated {
+		w.Header().Set("Cache-Control", "private, no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, no-cache")
+	}
+
+	a.renderCacheable(w, r, "parties/public.gohtml", templateData)
+}
+
+// SetPartyVisibilityHandler lets the party's owner make it public or private again
+func (a *Application) SetPartyVisibilityHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "SetPartyVisibilityHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	isPublic := r.PostForm.Get("public") == "true"
+	err = a.PublicPartyService.SetPublic(ctx, logger, idParty, watcher.ID, isPublic)
+	switch {
+	case errors.Is(err, partymgmt.ErrCannotChangeVisibility):
+		a.setErrorFlashMessage(w, r, "Only the party's owner can change who can see it.")
+	case err != nil:
+		a.setErrorFlashMessage(w, r, "There was an issue changing who can see this party, try again.")
+	case isPublic:
+		a.setInfoFlashMessage(w, r, "The party is public, anyone with the link can see its movies.")
+	default:
+		a.setInfoFlashMessage(w, r, "The party is private again, only its members can see it.")
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+}
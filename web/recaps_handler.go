@@ -0,0 +1,198 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+func (a *Application) ProfileRecapHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "ProfileRecapHandler")
+
+	profileID, err := a.getProfileIDFromSession(ctx, r)
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	// a missing or bad year shows the latest one
+	year, _ := strconv.Atoi(r.URL.Query().Get("year"))
+
+	recap, err := a.RecapService.GetWatcherRecap(ctx, logger, profileID, year, preferredLanguage(ctx))
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewRecapTemplateData(r, w, "/profile", recap)
+	templateData.ShareAction = "/profile/recap/share"
+	templateData.BackURL = "/profile"
+	a.render(w, r, http.StatusOK, "recaps/show.gohtml", templateData)
+}
+
+func (a *Application) ShareProfileRecapHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "ShareProfileRecapHandler")
+
+	profileID, err := a.getProfileIDFromSession(ctx, r)
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	year, shared, err := parseRecapShareForm(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse recap share form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	_, err = a.RecapService.SetWatcherRecapShared(ctx, profileID, year, shared)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to change recap sharing", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue changing who can see your recap, try again.")
+	} else {
+		a.setInfoFlashMessage(w, r, recapSharedMessage(shared))
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/profile/recap?year=%d", year), http.StatusSeeOther)
+}
+
+func (a *Application) PartyRecapHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "PartyRecapHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	year, _ := strconv.Atoi(r.URL.Query().Get("year"))
+
+	recap, err := a.RecapService.GetPartyRecap(ctx, logger, idParty, watcher.ID, year, preferredLanguage(ctx))
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewRecapTemplateData(r, w, "/parties", recap)
+	templateData.ShareAction = fmt.Sprintf("/parties/%d/recap/share", idParty)
+	templateData.BackURL = fmt.Sprintf("/parties/%d", idParty)
+	a.render(w, r, http.StatusOK, "recaps/show.gohtml", templateData)
+}
+
+func (a *Application) SharePartyRecapHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "SharePartyRecapHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	year, shared, err := parseRecapShareForm(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse recap share form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	_, err = a.RecapService.SetPartyRecapShared(ctx, idParty, watcher.ID, year, shared)
+	if errors.Is(err, partymgmt.ErrNotPartyMember) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to change recap sharing", slog.Any("error", err))
+		a.setErrorFlashMessage(w, r, "There was an issue changing who can see this recap, try again.")
+	} else {
+		a.setInfoFlashMessage(w, r, recapSharedMessage(shared))
+	}
+
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/recap?year=%d", idParty, year), http.StatusSeeOther)
+}
+
+func (a *Application) SharedRecapHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "SharedRecapHandler")
+
+	recap, err := a.RecapService.GetSharedRecap(ctx, logger, r.PathValue("token"), preferredLanguage(ctx))
+	if errors.Is(err, partymgmt.ErrRecapNotShared) {
+		data := a.NewTemplateData(r, w, "/recaps")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		a.serverError(w, r, err)
+		return
+	}
+
+	templateData := a.NewRecapTemplateData(r, w, "/recaps", recap)
+	templateData.IsPublic = true
+	a.render(w, r, http.StatusOK, "recaps/show.gohtml", templateData)
+}
+
+func parseRecapShareForm(r *http.Request) (int, bool, error) {
+	err := r.ParseForm()
+	if err != nil {
+		return 0, false, err
+	}
+
+	year, err := strconv.Atoi(r.PostForm.Get("year"))
+	if err != nil {
+		return 0, false, err
+	}
+
+	if year <= 0 {
+		return 0, false, fmt.Errorf("invalid recap year %d", year)
+	}
+
+	return year, r.PostForm.Get("shared") == "true", nil
+}
+
+func recapSharedMessage(shared bool) string {
+	if shared {
+		return "Your recap has a public link, anyone with it can see the recap."
+	}
+	return "The public link has been turned off."
+}
+
+// absoluteURL is the path on the host the request was made to, proxies in front of the app tell it whether that was
+// over https
+func absoluteURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + path
+}
@@ -14,6 +14,8 @@ type Route struct {
 	path               string
 	handler            http.HandlerFunc
 	authenticatedRoute bool
+	// adminRoute is only for admins, it has to be an authenticatedRoute too
+	adminRoute bool
 }
 
 func (a *Application) Routes() http.Handler {
@@ -27,6 +29,12 @@ func (a *Application) Routes() http.Handler {
 	profileRoutes := a.profileRoutes()
 	partyMemberRoutes := a.partyMemberRoutes()
 	invitationRoutes := a.invitationRoutes()
+	watcherRoutes := a.watcherRoutes()
+	recapRoutes := a.recapRoutes()
+	publicPartyRoutes := a.publicPartyRoutes()
+	calendarRoutes := a.calendarRoutes()
+	notificationRoutes := a.notificationRoutes()
+	adminRoutes := a.adminRoutes()
 
 	// allocate capacity for all routes
 	routes := make([]Route, 0)
@@ -41,10 +49,17 @@ func (a *Application) Routes() http.Handler {
 		profileRoutes,
 		invitationRoutes,
 		partyMemberRoutes,
+		watcherRoutes,
+		recapRoutes,
+		publicPartyRoutes,
+		calendarRoutes,
+		notificationRoutes,
+		adminRoutes,
 	)
 
 	authenticatorMW := a.authenticateMiddleware()
 	requireAuthMW := a.authenticatedMiddleware()
+	requireAdminMW := a.adminMiddleware()
 
 	fsys, err := fs.Sub(ui.TemplateFS, "dist")
 	if err != nil {
@@ -57,6 +72,9 @@ func (a *Application) Routes() http.Handler {
 
 	for _, r := range routes {
 		handlerFunc := r.handler
+		if r.adminRoute {
+			handlerFunc = requireAdminMW(handlerFunc)
+		}
 		if r.authenticatedRoute {
 			handlerFunc = requireAuthMW(handlerFunc)
 		}
@@ -147,6 +165,196 @@ func (a *Application) partyRoutes() []Route {
 			handler:            a.SelectMovieForParty,
 			authenticatedRoute: true,
 		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/unselect",
+			handler:            a.UnselectMovieHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/unwatch",
+			handler:            a.UnwatchMovieHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/watch_date",
+			handler:            a.EditWatchDateHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/remove",
+			handler:            a.RemovePartyMovieHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/vetoes",
+			handler:            a.VetoMovieHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/vetoes/{watcher_id}/lift",
+			handler:            a.LiftVetoHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/flag",
+			handler:            a.SetMovieFlagHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/movies/{id}/comments",
+			handler:            a.CommentsHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/comments",
+			handler:            a.CreateCommentHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/comments/{comment_id}",
+			handler:            a.EditCommentHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/comments/{comment_id}/delete",
+			handler:            a.DeleteCommentHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/events",
+			handler:            a.PartyEventsHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{id}/recommendations",
+			handler:            a.PartyRecommendationsHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/recommendations",
+			handler:            a.AddRecommendationToPartyHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{id}/activity",
+			handler:            a.PartyActivityHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{id}/export",
+			handler:            a.PartyExportHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{id}/stats",
+			handler:            a.PartyStatsHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{id}/recap",
+			handler:            a.PartyRecapHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{id}/recap/share",
+			handler:            a.SharePartyRecapHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/movie_nights",
+			handler:            a.MovieNightsIndexHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/movie_nights/new",
+			handler:            a.NewMovieNightHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movie_nights",
+			handler:            a.CreateMovieNightHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/movie_nights/{id}/edit",
+			handler:            a.EditMovieNightHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movie_nights/{id}",
+			handler:            a.UpdateMovieNightHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movie_nights/{id}/delete",
+			handler:            a.DeleteMovieNightHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movie_nights/{id}/rsvp",
+			handler:            a.RSVPMovieNightHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movie_nights/{id}/complete",
+			handler:            a.CompleteMovieNightHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{id}/visibility",
+			handler:            a.SetPartyVisibilityHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/invite_suggestions",
+			handler:            a.InviteSuggestionsHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/webhooks",
+			handler:            a.WebhooksIndexHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/webhooks",
+			handler:            a.CreateWebhookHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/webhooks/{id}/delete",
+			handler:            a.DeleteWebhookHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/webhooks/{id}/test",
+			handler:            a.SendTestWebhookHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/imports/new",
+			handler:            a.NewImportHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/imports",
+			handler:            a.CreateImportHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/imports/{id}",
+			handler:            a.ImportShowHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/imports/{id}",
+			handler:            a.ConfirmImportHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /parties/{party_id}/imports/{id}/progress",
+			handler:            a.ImportProgressHandler,
+			authenticatedRoute: true,
+		},
 		{
 			path:               "POST /parties",
 			handler:            a.CreatePartyHandler,
@@ -210,15 +418,25 @@ func (a *Application) partyMemberRoutes() []Route {
 	}
 }
 
-// func (a *Application) watcherRoutes() []Route {
-// 	return []Route{
-// 		{
-// 			path:               "GET /watched_movies",
-// 			handler:            a.WatchedMoviesHandler,
-// 			authenticatedRoute: true,
-// 		},
-// 	}
-// }
+func (a *Application) watcherRoutes() []Route {
+	return []Route{
+		{
+			path:               "GET /watched_movies",
+			handler:            a.WatchedMoviesHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/rating",
+			handler:            a.RateMovieHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /parties/{party_id}/movies/{id}/attendance",
+			handler:            a.SetAttendanceHandler,
+			authenticatedRoute: true,
+		},
+	}
+}
 
 func (a *Application) profileRoutes() []Route {
 	return []Route{
@@ -237,10 +455,214 @@ func (a *Application) profileRoutes() []Route {
 			handler:            a.ProfileEditHandler,
 			authenticatedRoute: true,
 		},
+		{
+			path:               "GET /profile/subscriptions",
+			handler:            a.ProfileSubscriptionsHandler,
+			authenticatedRoute: true,
+		},
 		{
 			path:               "GET /profile/watched",
 			handler:            a.GetPaginatedWatchHistoryHandler,
 			authenticatedRoute: true,
 		},
+		{
+			path:               "GET /profile/watched/export",
+			handler:            a.WatchHistoryExportHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /profile/export",
+			handler:            a.AccountExportHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /profile/recap",
+			handler:            a.ProfileRecapHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /profile/recap/share",
+			handler:            a.ShareProfileRecapHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /profile/calendar/reset",
+			handler:            a.ResetCalendarTokenHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /profile/avatar",
+			handler:            a.ProfileAvatarHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /profile/avatar/delete",
+			handler:            a.DeleteProfileAvatarHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /profile/delete",
+			handler:            a.AccountDeletionShowHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /profile/delete",
+			handler:            a.AccountDeletionHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /profile/delete/cancel",
+			handler:            a.CancelAccountDeletionHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /avatars/{name}",
+			handler:            a.AvatarHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /friends",
+			handler:            a.SendFriendRequestHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /friends/{id}/accept",
+			handler:            a.AcceptFriendRequestHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /friends/{id}/delete",
+			handler:            a.RemoveFriendHandler,
+			authenticatedRoute: true,
+		},
+	}
+}
+
+func (a *Application) publicPartyRoutes() []Route {
+	return []Route{
+		{
+			path:               "GET /p/{slug}",
+			handler:            a.PublicPartyHandler,
+			authenticatedRoute: false,
+		},
+	}
+}
+
+func (a *Application) recapRoutes() []Route {
+	return []Route{
+		{
+			path:               "GET /recaps/{token}",
+			handler:            a.SharedRecapHandler,
+			authenticatedRoute: false,
+		},
+	}
+}
+
+// calendar apps and feed readers can't log in so the feeds are found by the token in their path instead
+func (a *Application) calendarRoutes() []Route {
+	return []Route{
+		{
+			path:               "GET /calendars/{token}/movie_nights.ics",
+			handler:            a.WatcherCalendarHandler,
+			authenticatedRoute: false,
+		},
+		{
+			path:               "GET /calendars/{token}/parties/{party_id}/movie_nights.ics",
+			handler:            a.PartyCalendarHandler,
+			authenticatedRoute: false,
+		},
+		{
+			path:               "GET /feeds/{token}/parties/{party_id}/activity.atom",
+			handler:            a.PartyActivityFeedHandler,
+			authenticatedRoute: false,
+		},
+	}
+}
+
+func (a *Application) notificationRoutes() []Route {
+	return []Route{
+		{
+			path:               "GET /notifications",
+			handler:            a.NotificationsHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /notifications/menu",
+			handler:            a.NotificationsMenuHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "GET /notifications/{id}",
+			handler:            a.OpenNotificationHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /notifications/{id}/read",
+			handler:            a.MarkNotificationReadHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /notifications/read_all",
+			handler:            a.MarkAllNotificationsReadHandler,
+			authenticatedRoute: true,
+		},
+		{
+			path:               "POST /notifications/preferences",
+			handler:            a.UpdateNotificationPreferencesHandler,
+			authenticatedRoute: true,
+		},
+	}
+}
+
+func (a *Application) adminRoutes() []Route {
+	return []Route{
+		{
+			path:               "GET /admin",
+			handler:            a.AdminHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
+		{
+			path:               "GET /admin/users/{id}",
+			handler:            a.AdminUserHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
+		{
+			path:               "POST /admin/users/{id}/disable",
+			handler:            a.AdminDisableUserHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
+		{
+			path:               "POST /admin/users/{id}/enable",
+			handler:            a.AdminEnableUserHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
+		{
+			path:               "POST /admin/users/{id}/logout",
+			handler:            a.AdminLogoutUserHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
+		{
+			path:               "POST /admin/movies/{id}/refresh",
+			handler:            a.AdminRefreshMovieHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
+		{
+			path:               "GET /admin/failed_logins",
+			handler:            a.AdminFailedLoginsHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
+		{
+			path:               "GET /admin/audit_log",
+			handler:            a.AdminAuditLogHandler,
+			authenticatedRoute: true,
+			adminRoute:         true,
+		},
 	}
 }
@@ -3,6 +3,7 @@ package web
 import (
 	"errors"
 	"log/slog"
+	"net"
 	"net/http"
 
 	"github.com/jm96441n/movieswithfriends/identityaccess"
@@ -24,7 +25,7 @@ func (a *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	profile, err := a.Auth.Authenticate(r.Context(), logger, r.FormValue("email"), r.FormValue("password"))
+	profile, err := a.Auth.Authenticate(r.Context(), logger, r.FormValue("email"), r.FormValue("password"), clientIP(r))
 	if err != nil {
 		if errors.Is(err, identityaccess.ErrInvalidCredentials) {
 			a.setErrorFlashMessage(w, r, "Email/Password combination is incorrect")
@@ -34,6 +35,12 @@ func (a *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
+		if errors.Is(err, identityaccess.ErrAccountDisabled) {
+			a.setErrorFlashMessage(w, r, "This account has been disabled.")
+			http.Redirect(w, r, "/login", http.StatusSeeOther)
+			return
+		}
+
 		logger.ErrorContext(ctx, "error authenticating", slog.Any("error", err))
 		a.serverError(w, r, err)
 		return
@@ -50,6 +57,7 @@ func (a *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	session.Values["profileID"] = profile.ID
 	session.Values["fullName"] = profile.FirstName + " " + profile.LastName
 	session.Values["email"] = profile.Account.Email
+	session.Values["sessionVersion"] = profile.Account.SessionVersion
 
 	err = session.Save(r, w)
 	if err != nil {
@@ -61,6 +69,15 @@ func (a *Application) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/profile", http.StatusSeeOther)
 }
 
+// clientIP is the address the request came from without its port
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func (a *Application) LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	err := a.logout(w, r)
 	if err != nil {
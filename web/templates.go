@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"html/template"
 	"io/fs"
+	"log/slog"
 	"net/http"
 	"regexp"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"unicode"
 
 	"github.com/jm96441n/movieswithfriends/identityaccess"
+	"github.com/jm96441n/movieswithfriends/identityaccess/services"
 	"github.com/jm96441n/movieswithfriends/partymgmt"
 )
 
@@ -28,8 +30,17 @@ type BaseTemplateData struct {
 	WarningFlashes  []interface{}
 	IsAuthenticated bool
 	CurrentYear     int
-	FullName        string
-	UserEmail       string
+	// DisplayName is what the logged in watcher is shown as, their legal name when they haven't set one
+	DisplayName string
+	// AvatarKey is where the logged in watcher's avatar is kept, it's empty when they haven't uploaded one
+	AvatarKey string
+	UserEmail string
+	// UnreadNotifications is the badge on the nav's notification bell
+	UnreadNotifications int
+	// DeletionScheduledFor is when the logged in watcher's account is going to be deleted, it's zero when it isn't
+	DeletionScheduledFor time.Time
+	// IsAdmin is whether the nav links to the admin area
+	IsAdmin bool
 }
 
 type AddMovieToPartiesModalTemplateData struct {
@@ -48,17 +59,49 @@ type MoviesTemplateData struct {
 }
 
 type ProfilesTemplateData struct {
-	Profile           *identityaccess.Profile
-	WatchedMovies     []partymgmt.PartyMovie
-	Parties           []partymgmt.Party
-	InvitedParties    []partymgmt.Party
-	CurrentUserID     int
-	NumPages          int
-	CurPage           int
-	HasEmailError     *bool
-	HasPasswordError  *bool
-	HasFirstNameError *bool
-	HasLastNameError  *bool
+	Profile        *identityaccess.Profile
+	Parties        []partymgmt.Party
+	InvitedParties []partymgmt.Party
+	CurrentUserID  int
+	WatchHistory   partymgmt.WatchHistoryPage
+	// NextPageURL and PrevPageURL load the pages either side of the watch history, they're empty when there isn't one
+	NextPageURL string
+	PrevPageURL string
+	// HistoryParties and Genres are the options the watch history can be filtered by
+	HistoryParties         []partymgmt.Party
+	Genres                 []partymgmt.Genre
+	PageSizes              []int
+	HasEmailError          *bool
+	HasPasswordError       *bool
+	HasFirstNameError      *bool
+	HasLastNameError       *bool
+	HasLanguageError       *bool
+	HasRegionError         *bool
+	HasDisplayNameError    *bool
+	HasBioError            *bool
+	HasFavoriteGenresError *bool
+	Languages              []identityaccess.Language
+	Regions                []identityaccess.Region
+	// WatchProviders are the services that can be subscribed to in the region being edited
+	WatchProviders []partymgmt.WatchProvider
+	// Subscribed is the set of provider ids the profile subscribes to
+	Subscribed map[int]bool
+	// FavoriteGenreOptions are the genres that can be picked as favourites on the edit form
+	FavoriteGenreOptions []partymgmt.Genre
+	// FavoriteGenres is the set of genre ids the profile has picked as favourites
+	FavoriteGenres map[int]bool
+	// FavoriteGenreNames are the names of the profile's favourite genres in the watcher's language
+	FavoriteGenreNames []string
+	// UpcomingMovieNights are the movie nights coming up in the watcher's parties
+	UpcomingMovieNights []partymgmt.MovieNight
+	// CalendarURL is the watcher's calendar feed of every movie night in their parties
+	CalendarURL string
+	// Friends are the watcher's friends, their friend requests and the people they could send one to
+	Friends partymgmt.Friends
+	// OwnedParties are the parties the watcher owns, each has to be handed over or deleted with their account
+	OwnedParties []partymgmt.OwnedParty
+	// DeletionGracePeriodDays is how many days a deleted account can still be got back for
+	DeletionGracePeriodDays int
 	BaseTemplateData
 }
 
@@ -67,16 +110,42 @@ func (s *ProfilesTemplateData) InitHasErrorFields() {
 	s.HasPasswordError = new(bool)
 	s.HasFirstNameError = new(bool)
 	s.HasLastNameError = new(bool)
+	s.HasLanguageError = new(bool)
+	s.HasRegionError = new(bool)
+	s.HasDisplayNameError = new(bool)
+	s.HasBioError = new(bool)
+	s.HasFavoriteGenresError = new(bool)
 }
 
 type PartiesTemplateData struct {
 	Party                 partymgmt.Party
+	CurrentWatcherID      int
 	CurrentWatcherIsOwner bool
 	Members               []partymgmt.PartyMember
 	ModalData             InviteModalTemplateData
+	Recommendations       []partymgmt.Recommendation
+	// Imports are the imports into the party that are running or finished recently
+	Imports []partymgmt.Import
+	// StatusChanges are the latest changes members made to the party's movies
+	StatusChanges []partymgmt.MovieStatusChange
+	// Activity is the latest of what's happened in the party
+	Activity partymgmt.ActivityPage
+	// ActivityFeedURL is the party's Atom feed of its activity for the current watcher
+	ActivityFeedURL string
+	// GenreNames are the names of the genres members have picked as favourites, keyed by id
+	GenreNames map[int]string
+	// Visibility is whether anyone can see the party, PublicURL is the full link to it when they can
+	Visibility partymgmt.PartyVisibility
+	PublicURL  string
 	BaseTemplateData
 }
 
+// RecommendationAddedTemplateData replaces a recommendation's add button once it's been added
+type RecommendationAddedTemplateData struct {
+	// AlreadyInParty is whether someone else had already put it on the party's list
+	AlreadyInParty bool
+}
+
 type PartiesIndexTemplateData struct {
 	Parties        []partymgmt.Party
 	InvitedParties []partymgmt.Party
@@ -84,6 +153,96 @@ type PartiesIndexTemplateData struct {
 	BaseTemplateData
 }
 
+type PartyStatsTemplateData struct {
+	Stats        partymgmt.PartyStats
+	WatchesChart Chart
+	GenresChart  Chart
+	BaseTemplateData
+}
+
+type RecapTemplateData struct {
+	Recap       partymgmt.Recap
+	GenresChart Chart
+	// ShareURL is the full link to the recap's public page, empty when it isn't shared
+	ShareURL string
+	// ShareAction is where the form turning the public page on and off posts to
+	ShareAction string
+	BackURL     string
+	// IsPublic is set when the page is being viewed through its share link
+	IsPublic bool
+	BaseTemplateData
+}
+
+type PublicPartyTemplateData struct {
+	Party partymgmt.PublicParty
+	// URL is the full link to the page so it can be shared
+	URL string
+	BaseTemplateData
+}
+
+type MovieNightsTemplateData struct {
+	PartyID     int
+	MovieNights partymgmt.PartyMovieNights
+	// MovieNight is the movie night being edited, it's empty when a new one is being scheduled
+	MovieNight      partymgmt.MovieNight
+	Movies          []partymgmt.SchedulableMovie
+	ReminderOptions []partymgmt.ReminderOption
+	// CalendarURL is the party's calendar feed for the current watcher
+	CalendarURL string
+	BaseTemplateData
+}
+
+type ImportsTemplateData struct {
+	PartyID int
+	Import  partymgmt.Import
+	BaseTemplateData
+}
+
+type NotificationsTemplateData struct {
+	Notifications []partymgmt.Notification
+	Preferences   []partymgmt.NotificationPreference
+	BaseTemplateData
+}
+
+type WebhooksTemplateData struct {
+	Webhooks partymgmt.PartyWebhooks
+	Formats  []partymgmt.WebhookFormat
+	Events   []partymgmt.PartyEventType
+	BaseTemplateData
+}
+
+type CommentsTemplateData struct {
+	Thread   partymgmt.CommentThread
+	ErrorMsg string
+	BaseTemplateData
+}
+
+// commentFormData is one of the forms for writing a comment, it edits the comment when IDComment is set and replies
+// to IDParent otherwise
+type commentFormData struct {
+	IDMovie     int
+	IDParent    int
+	Action      string
+	Label       string
+	Placeholder string
+	Body        string
+}
+
+// memberAboutData is what a party member has said about themselves with the names their favourite genres are shown by
+type memberAboutData struct {
+	Member     partymgmt.PartyMember
+	GenreNames map[int]string
+}
+
+type AdminTemplateData struct {
+	SearchValue   string
+	SearchResults services.AdminSearchResults
+	Account       services.AdminAccountDetails
+	FailedLogins  []identityaccess.FailedLogin
+	AuditLog      []identityaccess.AuditEntry
+	BaseTemplateData
+}
+
 type SignupTemplateData struct {
 	HasEmailError     *bool
 	HasPasswordError  *bool
@@ -111,6 +270,8 @@ func (a *Application) NewMoviesTemplateData(r *http.Request, w http.ResponseWrit
 
 func (a *Application) NewProfilesTemplateData(r *http.Request, w http.ResponseWriter, path string) ProfilesTemplateData {
 	return ProfilesTemplateData{
+		Languages:        identityaccess.SupportedLanguages,
+		Regions:          identityaccess.SupportedRegions,
 		BaseTemplateData: a.newBaseTemplateData(r, w, path),
 	}
 }
@@ -130,6 +291,102 @@ func (a *Application) NewPartiesTemplateData(r *http.Request, w http.ResponseWri
 	}
 }
 
+func (a *Application) NewPartyStatsTemplateData(r *http.Request, w http.ResponseWriter, path string, stats partymgmt.PartyStats) PartyStatsTemplateData {
+	monthLabels := make([]string, 0, len(stats.WatchesPerMonth))
+	monthCounts := make([]int, 0, len(stats.WatchesPerMonth))
+	for _, month := range stats.WatchesPerMonth {
+		monthLabels = append(monthLabels, month.Month.Format("Jan"))
+		monthCounts = append(monthCounts, month.Count)
+	}
+
+	genreLabels := make([]string, 0, len(stats.Genres))
+	genreCounts := make([]int, 0, len(stats.Genres))
+	for _, genre := range stats.Genres {
+		genreLabels = append(genreLabels, genre.Name)
+		genreCounts = append(genreCounts, genre.Count)
+	}
+
+	return PartyStatsTemplateData{
+		Stats:            stats,
+		WatchesChart:     newColumnChart(monthLabels, monthCounts),
+		GenresChart:      newBarChart(genreLabels, genreCounts),
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
+func (a *Application) NewPublicPartyTemplateData(r *http.Request, w http.ResponseWriter, path string, party partymgmt.PublicParty) PublicPartyTemplateData {
+	return PublicPartyTemplateData{
+		Party:            party,
+		URL:              absoluteURL(r, party.Path),
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
+func (a *Application) NewRecapTemplateData(r *http.Request, w http.ResponseWriter, path string, recap partymgmt.Recap) RecapTemplateData {
+	genreLabels := make([]string, 0, len(recap.TopGenres))
+	genreCounts := make([]int, 0, len(recap.TopGenres))
+	for _, genre := range recap.TopGenres {
+		genreLabels = append(genreLabels, genre.Name)
+		genreCounts = append(genreCounts, genre.Count)
+	}
+
+	templateData := RecapTemplateData{
+		Recap:            recap,
+		GenresChart:      newBarChart(genreLabels, genreCounts),
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+
+	if recap.ShareToken != "" {
+		templateData.ShareURL = absoluteURL(r, "/recaps/"+recap.ShareToken)
+	}
+
+	return templateData
+}
+
+func (a *Application) NewMovieNightsTemplateData(r *http.Request, w http.ResponseWriter, path string, idParty int) MovieNightsTemplateData {
+	return MovieNightsTemplateData{
+		PartyID:          idParty,
+		ReminderOptions:  partymgmt.ReminderOptions,
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
+func (a *Application) NewImportsTemplateData(r *http.Request, w http.ResponseWriter, path string, idParty int) ImportsTemplateData {
+	return ImportsTemplateData{
+		PartyID:          idParty,
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
+func (a *Application) NewNotificationsTemplateData(r *http.Request, w http.ResponseWriter, path string, notifications []partymgmt.Notification) NotificationsTemplateData {
+	return NotificationsTemplateData{
+		Notifications:    notifications,
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
+func (a *Application) NewWebhooksTemplateData(r *http.Request, w http.ResponseWriter, path string, webhooks partymgmt.PartyWebhooks) WebhooksTemplateData {
+	return WebhooksTemplateData{
+		Webhooks:         webhooks,
+		Formats:          partymgmt.WebhookFormats,
+		Events:           partymgmt.WebhookEvents,
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
+func (a *Application) NewCommentsTemplateData(r *http.Request, w http.ResponseWriter, path string, thread partymgmt.CommentThread) CommentsTemplateData {
+	return CommentsTemplateData{
+		Thread:           thread,
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
+func (a *Application) NewAdminTemplateData(r *http.Request, w http.ResponseWriter, path string) AdminTemplateData {
+	return AdminTemplateData{
+		BaseTemplateData: a.newBaseTemplateData(r, w, path),
+	}
+}
+
 func (a *Application) NewSignupTemplateData(r *http.Request, w http.ResponseWriter, path string) *SignupTemplateData {
 	return &SignupTemplateData{
 		BaseTemplateData: a.newBaseTemplateData(r, w, path),
@@ -140,13 +397,19 @@ func (a *Application) newBaseTemplateData(r *http.Request, w http.ResponseWriter
 	authed := isAuthenticated(r.Context())
 
 	var (
-		fullName string
-		email    string
+		displayName          string
+		avatarKey            string
+		email                string
+		unreadNotifications  int
+		deletionScheduledFor time.Time
 	)
 
 	if authed {
-		fullName = r.Context().Value(fullNameContextKey).(string)
+		displayName = r.Context().Value(displayNameContextKey).(string)
+		avatarKey = r.Context().Value(avatarKeyContextKey).(string)
 		email = r.Context().Value(emailContextKey).(string)
+		unreadNotifications = a.unreadNotificationCount(r)
+		deletionScheduledFor, _ = r.Context().Value(deletionScheduledForContextKey).(time.Time)
 	}
 
 	var (
@@ -163,17 +426,41 @@ func (a *Application) newBaseTemplateData(r *http.Request, w http.ResponseWriter
 	}
 
 	return BaseTemplateData{
-		ErrorFlashes:    errorFlashes,
-		InfoFlashes:     infoFlashes,
-		WarningFlashes:  warningFlashes,
-		CurrentPagePath: path,
-		CurrentYear:     2025,
-		IsAuthenticated: authed,
-		FullName:        fullName,
-		UserEmail:       email,
+		ErrorFlashes:         errorFlashes,
+		InfoFlashes:          infoFlashes,
+		WarningFlashes:       warningFlashes,
+		CurrentPagePath:      path,
+		CurrentYear:          2025,
+		IsAuthenticated:      authed,
+		DisplayName:          displayName,
+		AvatarKey:            avatarKey,
+		UserEmail:            email,
+		UnreadNotifications:  unreadNotifications,
+		DeletionScheduledFor: deletionScheduledFor,
+		IsAdmin:              isAdmin(r.Context()),
 	}
 }
 
+// unreadNotificationCount is how many notifications the logged in watcher hasn't read, the badge is left off rather
+// than failing the page when it can't be counted
+func (a *Application) unreadNotificationCount(r *http.Request) int {
+	ctx := r.Context()
+
+	profileID, err := a.getProfileIDFromSession(ctx, r)
+	if err != nil {
+		a.Logger.ErrorContext(ctx, "failed to get profile id for unread notifications", slog.Any("error", err))
+		return 0
+	}
+
+	count, err := a.NotificationService.GetUnreadCount(ctx, profileID)
+	if err != nil {
+		a.Logger.ErrorContext(ctx, "failed to count unread notifications", slog.Any("error", err))
+		return 0
+	}
+
+	return count
+}
+
 func (a *Application) templFunctions() template.FuncMap {
 	return template.FuncMap{
 		"navClasses": func(currentPath, targetPath string) string {
@@ -193,6 +480,14 @@ func (a *Application) templFunctions() template.FuncMap {
 			return ""
 		},
 		"join": strings.Join,
+		// avatarURL is where the avatar at the key is served from, a blank placeholder of the size is used when
+		// there isn't one
+		"avatarURL": func(key string, size int) string {
+			if path := identityaccess.AvatarPath(key); path != "" {
+				return path
+			}
+			return fmt.Sprintf("https://placehold.co/%dx%d?text=", size, size)
+		},
 		"joinGenres": func(genres []partymgmt.Genre) string {
 			res := ""
 			for i, g := range genres {
@@ -229,39 +524,77 @@ func (a *Application) templFunctions() template.FuncMap {
 
 			return "is-valid"
 		},
-		"activeIfCurrentPageForPagination": func(currentPage, targetPage int) string {
-			if currentPage == targetPage {
-				return "active"
+		"ratingStars": func() []int {
+			stars := make([]int, 0, partymgmt.MaxMovieRating)
+			for i := 1; i <= partymgmt.MaxMovieRating; i++ {
+				stars = append(stars, i)
 			}
-			return ""
+			return stars
 		},
-		"pageNums": func(curPage, numPages int) []int {
-			pages := make([]int, 0, 3)
-			// when on the first page show the following 2 pages
-			// when on the last page show the previous 2 pages
-			// when on a page in the middle show the previous page, current page, and next page
-			switch curPage {
-			case 1:
-				for i := curPage; i <= numPages && i < curPage+3; i++ {
-					pages = append(pages, i)
-				}
-			case numPages:
-				for i := max(1, curPage-2); i <= numPages; i++ {
-					pages = append(pages, i)
-				}
-			default:
-				for i := curPage - 1; i <= curPage+1; i++ {
-					pages = append(pages, i)
-				}
+		"formatInputDate": func(date *time.Time) string {
+			if date == nil {
+				return ""
 			}
-
-			return pages
+			return date.Format(dateInputFormat)
+		},
+		"formatWatchDateInput": func(date time.Time) string {
+			loc, err := time.LoadLocation(watchDateLocation)
+			if err != nil {
+				return ""
+			}
+			return date.In(loc).Format(time.DateOnly)
+		},
+		"formatLongDate": func(date time.Time) string {
+			return date.Format("Monday, January 2, 2006")
+		},
+		"formatMovieNightTime": func(date time.Time) string {
+			return date.Format("Mon, Jan 2 at 3:04 PM MST")
+		},
+		// formatTimestamp is for logs where the time something happened matters, e.g. webhook deliveries
+		"formatTimestamp": func(date time.Time) string {
+			return date.UTC().Format("Jan 02, 2006 15:04 MST")
+		},
+		"formatMovieNightInput": func(date time.Time) string {
+			if date.IsZero() {
+				return ""
+			}
+			return date.Format(partymgmt.MovieNightInputLayout)
+		},
+		// webcalURL is the link calendar apps open to subscribe to a feed, it's marked safe since html/template only
+		// trusts http(s) and mailto links
+		"webcalURL": func(feedURL string) template.URL {
+			_, rest, found := strings.Cut(feedURL, "://")
+			if !found {
+				return template.URL(feedURL)
+			}
+			return template.URL("webcal://" + rest)
 		},
 		"showSidebar": func(path string) bool {
 			_, ok := nonsidebarPaths[path]
 			return !ok
 		},
-		"assetPath": a.assetPath,
+		"assetPath":         a.assetPath,
+		"webhookEventLabel": partymgmt.WebhookEventLabel,
+		"commentForm": func(idParty, idMovie, idComment, idParent int, label, body string) commentFormData {
+			form := commentFormData{
+				IDMovie:     idMovie,
+				IDParent:    idParent,
+				Action:      fmt.Sprintf("/parties/%d/movies/%d/comments", idParty, idMovie),
+				Label:       label,
+				Placeholder: "What did you think?",
+				Body:        body,
+			}
+			switch {
+			case idComment != 0:
+				form.Action = fmt.Sprintf("%s/%d", form.Action, idComment)
+			case idParent != 0:
+				form.Placeholder = "Write a reply"
+			}
+			return form
+		},
+		"memberAbout": func(member partymgmt.PartyMember, genreNames map[int]string) memberAboutData {
+			return memberAboutData{Member: member, GenreNames: genreNames}
+		},
 		"movieWatched": func(id int, tmdbIDS map[int]struct{}) bool {
 			_, ok := tmdbIDS[id]
 			return ok
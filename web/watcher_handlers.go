@@ -1,50 +1,254 @@
 package web
 
 import (
+	"errors"
+	"log/slog"
 	"net/http"
+	"net/url"
 	"strconv"
+	"time"
 
-	"github.com/jm96441n/movieswithfriends/identityaccess/services"
+	"github.com/jm96441n/movieswithfriends/partymgmt"
 )
 
+const dateInputFormat = "2006-01-02"
+
 func (a *Application) WatchedMoviesHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	logger := a.Logger.With("handler", "WatchedMoviesHandler")
-	logger.DebugContext(ctx, "getting paginated movies list")
+	logger.DebugContext(ctx, "getting watch history")
 
-	profileID, err := a.getProfileIDFromSession(ctx, r)
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	page, err := watcher.GetWatchHistory(ctx, logger, watchHistoryQueryFromRequest(r))
 	if err != nil {
 		a.serverError(w, r, err)
 		return
 	}
 
-	page := r.URL.Query().Get("page")
+	templateData := a.NewProfilesTemplateData(r, w, "/watched_movies")
+	setWatchHistoryTemplateData(&templateData, page)
+	templateData.PageSizes = partymgmt.WatchHistoryPageSizes
 
-	if page == "" {
-		page = "1"
+	templateData.HistoryParties, err = watcher.GetPartyNames(ctx)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to load parties to filter by", slog.Any("error", err))
 	}
 
-	pageNum, err := strconv.Atoi(page)
+	templateData.Genres, err = a.MoviesService.ListGenres(ctx, preferredLanguage(ctx))
 	if err != nil {
-		a.serverError(w, r, err)
+		logger.ErrorContext(ctx, "failed to load genres to filter by", slog.Any("error", err))
+	}
+
+	a.render(w, r, http.StatusOK, "profiles/history.gohtml", templateData)
+}
+
+func (a *Application) RateMovieHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "RateMovieHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
 		return
 	}
 
-	movieData, err := a.ProfileAggregatorService.GetWatchPaginatedHistory(ctx, logger, profileID, services.PageInfo{PageNum: pageNum, PageSize: 15})
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
 	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	idMovie, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get movie ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	rating, err := strconv.Atoi(r.PostForm.Get("rating"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse rating", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = watcher.RateMovie(ctx, idParty, idMovie, rating)
+	if errors.Is(err, partymgmt.ErrInvalidRating) {
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	if errors.Is(err, partymgmt.ErrCannotRateMovie) {
+		data := a.NewTemplateData(r, w, "/watched_movies")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
+
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to rate movie", slog.Any("error", err))
 		a.serverError(w, r, err)
 		return
 	}
 
-	templateData := a.NewProfilesTemplateData(r, w, "/watched_movies")
-	templateData.WatchedMovies = movieData.WatchedMovies
-	templateData.CurPage = pageNum
-	templateData.NumPages = movieData.NumPages
+	a.renderPartial(w, r, http.StatusOK, "profiles/partials/movie_rating.gohtml", partymgmt.PartyMovie{
+		ID:        idMovie,
+		IDParty:   idParty,
+		OwnRating: rating,
+	})
+}
+
+func (a *Application) SetAttendanceHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "SetAttendanceHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	idMovie, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get movie ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	attended, err := strconv.ParseBool(r.PostForm.Get("attended"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse attended", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = watcher.SetAttendance(ctx, idParty, idMovie, attended)
+	if errors.Is(err, partymgmt.ErrCannotSetAttendance) {
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+		return
+	}
 
-	if r.Header.Get("HX-Request") != "" {
-		a.renderPartial(w, r, http.StatusOK, "profiles/partials/watch_list.gohtml", templateData)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to set attendance", slog.Any("error", err))
+		a.serverError(w, r, err)
+		return
+	}
+
+	attendance, err := a.PartyService.GetMovieAttendance(ctx, idParty, idMovie, watcher.ID)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get attendance", slog.Any("error", err))
+		a.serverError(w, r, err)
 		return
 	}
 
-	a.render(w, r, http.StatusOK, "", templateData)
+	a.renderPartial(w, r, http.StatusOK, "parties/partials/movie_attendance.gohtml", attendance)
+}
+
+// watchHistoryQueryFromRequest reads the watch history filters from the query string, anything that can't be parsed is
+// left out so a bad link still shows the history
+func watchHistoryQueryFromRequest(r *http.Request) partymgmt.WatchHistoryQuery {
+	params := r.URL.Query()
+
+	query := partymgmt.WatchHistoryQuery{
+		Search:    params.Get("q"),
+		Sort:      partymgmt.WatchHistorySort(params.Get("sort")),
+		Ascending: params.Get("dir") == "asc",
+		After:     params.Get("after"),
+		Before:    params.Get("before"),
+	}
+
+	query.IDParty, _ = strconv.Atoi(params.Get("party"))
+	query.IDGenre, _ = strconv.Atoi(params.Get("genre"))
+	query.MinRating, _ = strconv.Atoi(params.Get("rating"))
+	query.PageSize, _ = strconv.Atoi(params.Get("size"))
+
+	if from, err := time.Parse(dateInputFormat, params.Get("from")); err == nil {
+		query.WatchedFrom = &from
+	}
+
+	if to, err := time.Parse(dateInputFormat, params.Get("to")); err == nil {
+		query.WatchedTo = &to
+	}
+
+	return query.Normalize()
+}
+
+// watchHistoryURL is the link to the watch history partial with the same filters as query, with after and before set as
+// the page to load
+func watchHistoryURL(query partymgmt.WatchHistoryQuery, after, before string) string {
+	params := url.Values{}
+
+	if query.IDParty != 0 {
+		params.Set("party", strconv.Itoa(query.IDParty))
+	}
+	if query.IDGenre != 0 {
+		params.Set("genre", strconv.Itoa(query.IDGenre))
+	}
+	if query.WatchedFrom != nil {
+		params.Set("from", query.WatchedFrom.Format(dateInputFormat))
+	}
+	if query.WatchedTo != nil {
+		params.Set("to", query.WatchedTo.Format(dateInputFormat))
+	}
+	if query.MinRating != 0 {
+		params.Set("rating", strconv.Itoa(query.MinRating))
+	}
+	if query.Search != "" {
+		params.Set("q", query.Search)
+	}
+	if query.Sort != partymgmt.WatchHistorySortWatchDate {
+		params.Set("sort", string(query.Sort))
+	}
+	if query.Ascending {
+		params.Set("dir", "asc")
+	}
+	params.Set("size", strconv.Itoa(query.PageSize))
+
+	if after != "" {
+		params.Set("after", after)
+	}
+	if before != "" {
+		params.Set("before", before)
+	}
+
+	return "/profile/watched?" + params.Encode()
+}
+
+func setWatchHistoryTemplateData(data *ProfilesTemplateData, page partymgmt.WatchHistoryPage) {
+	data.WatchHistory = page
+
+	if page.NextCursor != "" {
+		data.NextPageURL = watchHistoryURL(page.Query, page.NextCursor, "")
+	}
+
+	if page.PrevCursor != "" {
+		data.PrevPageURL = watchHistoryURL(page.Query, "", page.PrevCursor)
+	}
 }
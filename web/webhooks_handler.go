@@ -0,0 +1,165 @@
+package web
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/jm96441n/movieswithfriends/partymgmt"
+)
+
+func (a *Application) WebhooksIndexHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "WebhooksIndexHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	webhooks, err := a.WebhookService.GetWebhooks(ctx, idParty, watcher.ID)
+	if err != nil {
+		a.handleWebhookError(w, r, logger, err, idParty)
+		return
+	}
+
+	templateData := a.NewWebhooksTemplateData(r, w, "/parties", webhooks)
+	a.render(w, r, http.StatusOK, "webhooks/index.gohtml", templateData)
+}
+
+func (a *Application) CreateWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "CreateWebhookHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get party ID from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = r.ParseForm()
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to parse form", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	input := partymgmt.WebhookInput{
+		URL:    r.PostForm.Get("url"),
+		Format: r.PostForm.Get("format"),
+		Events: r.PostForm["events"],
+	}
+
+	err = a.WebhookService.CreateWebhook(ctx, logger, idParty, watcher.ID, input)
+	if err != nil {
+		a.handleWebhookError(w, r, logger, err, idParty)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, "The webhook has been added, send it a test event to make sure it works.")
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/webhooks", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "DeleteWebhookHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idWebhook, err := webhookIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get webhook from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.WebhookService.DeleteWebhook(ctx, logger, idParty, idWebhook, watcher.ID)
+	if err != nil {
+		a.handleWebhookError(w, r, logger, err, idParty)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, "The webhook has been deleted.")
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/webhooks", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) SendTestWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	logger := a.Logger.With("handler", "SendTestWebhookHandler")
+
+	watcher, err := a.getWatcherFromSession(ctx, r)
+	if err != nil {
+		a.handleFailedToGetWatcherFromSession(ctx, logger, w, r, err)
+		return
+	}
+
+	idParty, idWebhook, err := webhookIDsFromPath(r)
+	if err != nil {
+		logger.ErrorContext(ctx, "failed to get webhook from path", slog.Any("error", err))
+		a.clientError(w, r, http.StatusBadRequest, "uh oh")
+		return
+	}
+
+	err = a.WebhookService.SendTestEvent(ctx, logger, idParty, idWebhook, watcher.ID)
+	if err != nil {
+		a.handleWebhookError(w, r, logger, err, idParty)
+		return
+	}
+
+	a.setInfoFlashMessage(w, r, "A test event is on its way, refresh to see how it went in the delivery log.")
+	http.Redirect(w, r, fmt.Sprintf("/parties/%d/webhooks", idParty), http.StatusSeeOther)
+}
+
+func (a *Application) handleWebhookError(w http.ResponseWriter, r *http.Request, logger *slog.Logger, err error, idParty int) {
+	switch {
+	case errors.Is(err, partymgmt.ErrCannotManageWebhooks):
+		a.setErrorFlashMessage(w, r, "Only the party's owner can manage its webhooks.")
+		http.Redirect(w, r, fmt.Sprintf("/parties/%d", idParty), http.StatusSeeOther)
+	case errors.Is(err, partymgmt.ErrWebhookNotFound):
+		data := a.NewTemplateData(r, w, "/parties")
+		a.render(w, r, http.StatusNotFound, "404.gohtml", data)
+	case errors.Is(err, partymgmt.ErrInvalidWebhookURL),
+		errors.Is(err, partymgmt.ErrInvalidWebhookFormat),
+		errors.Is(err, partymgmt.ErrNoWebhookEvents):
+		a.setErrorFlashMessage(w, r, fmt.Sprintf("The webhook couldn't be added, %s.", err))
+		http.Redirect(w, r, fmt.Sprintf("/parties/%d/webhooks", idParty), http.StatusSeeOther)
+	default:
+		logger.ErrorContext(r.Context(), "webhook request failed", slog.Any("error", err))
+		a.serverError(w, r, err)
+	}
+}
+
+func webhookIDsFromPath(r *http.Request) (int, int, error) {
+	idParty, err := strconv.Atoi(r.PathValue("party_id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	idWebhook, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return idParty, idWebhook, nil
+}
@@ -0,0 +1,156 @@
+// Package webhookfake is a stand-in for the services party webhooks are sent to, it records every delivery it
+// receives so the app and its tests can send webhooks without network access.
+package webhookfake
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+)
+
+// the headers the app sends with every delivery
+const (
+	eventHeader     = "X-Movieswithfriends-Event"
+	deliveryHeader  = "X-Movieswithfriends-Delivery"
+	timestampHeader = "X-Movieswithfriends-Timestamp"
+	signatureHeader = "X-Movieswithfriends-Signature"
+)
+
+// Delivery is a request the fake received
+type Delivery struct {
+	Event      string
+	IDDelivery string
+	Timestamp  string
+	Signature  string
+	Body       []byte
+}
+
+type Handler struct {
+	secret string
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// failures is how many more requests are answered with an error
+	failures   int
+	deliveries []Delivery
+}
+
+// Option configures a Handler
+type Option func(*Handler)
+
+// WithSecret makes the fake reject any delivery that isn't signed with the secret, by default signatures aren't
+// checked
+func WithSecret(secret string) Option {
+	return func(h *Handler) {
+		h.secret = secret
+	}
+}
+
+// WithFailures makes the fake answer the first n deliveries with a 500 so retries can be tried out, they're still
+// recorded
+func WithFailures(n int) Option {
+	return func(h *Handler) {
+		h.failures = n
+	}
+}
+
+// WithLogger logs every delivery the fake receives
+func WithLogger(logger *slog.Logger) Option {
+	return func(h *Handler) {
+		h.logger = logger
+	}
+}
+
+func NewHandler(opts ...Option) *Handler {
+	h := &Handler{deliveries: make([]Delivery, 0)}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "webhooks are sent with POST", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	delivery := Delivery{
+		Event:      r.Header.Get(eventHeader),
+		IDDelivery: r.Header.Get(deliveryHeader),
+		Timestamp:  r.Header.Get(timestampHeader),
+		Signature:  r.Header.Get(signatureHeader),
+		Body:       body,
+	}
+
+	if h.logger != nil {
+		h.logger.Info("delivery", "event", delivery.Event, "delivery", delivery.IDDelivery, "body", string(body))
+	}
+
+	h.mu.Lock()
+	h.deliveries = append(h.deliveries, delivery)
+	fail := h.failures > 0
+	if fail {
+		h.failures--
+	}
+	h.mu.Unlock()
+
+	if fail {
+		http.Error(w, "failing on purpose", http.StatusInternalServerError)
+		return
+	}
+
+	if h.secret != "" && !hmac.Equal([]byte(delivery.Signature), []byte(sign(h.secret, delivery.Timestamp, body))) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Deliveries returns every delivery received so far, oldest first
+func (h *Handler) Deliveries() []Delivery {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	deliveries := make([]Delivery, len(h.deliveries))
+	copy(deliveries, h.deliveries)
+	return deliveries
+}
+
+// sign works out a delivery's signature the way a receiver would, independently of how the app does it
+func sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// NewServer starts the fake on a local port for use in tests, the server is closed when the test finishes. Point
+// the webhook at server.URL.
+func NewServer(t interface {
+	Helper()
+	Cleanup(func())
+}, opts ...Option,
+) (*httptest.Server, *Handler) {
+	t.Helper()
+
+	handler := NewHandler(opts...)
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	return server, handler
+}